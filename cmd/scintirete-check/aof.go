@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// aofKey identifies the database and collection a command applies to
+type aofKey struct {
+	Database   string
+	Collection string
+}
+
+// aofStats counts the commands logged for a database or collection
+type aofStats struct {
+	Commands int64
+	Inserted int64 // Vectors inserted
+	Deleted  int64 // Vector IDs deleted
+}
+
+// checkAOF validates an AOF file and prints its statistics to stdout. With opts.Dump
+// the commands are printed to stdout as JSON lines and the summary goes to stderr.
+func checkAOF(ctx context.Context, path string, opts checkOptions, stdout, stderr io.Writer) (*checkResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &checkResult{}
+
+	// Torn tails are truncated by the server on its own, so only a strict replay tells them from corruption
+	_, strictErr := aof.ReplayFile(ctx, path, aof.ReplayOptions{Keys: opts.Keys}, func(types.AOFCommand) error { return nil })
+	if strictErr != nil && utils.GetErrorCode(strictErr) != utils.ErrorCodeCorruptedData {
+		return nil, strictErr
+	}
+
+	summary := stdout
+	encoder := json.NewEncoder(stdout)
+	if opts.Dump {
+		summary = stderr
+	}
+
+	stats := make(map[aofKey]*aofStats)
+	commandCounts := make(map[string]int64)
+	report, err := aof.ReplayFile(ctx, path, aof.ReplayOptions{Repair: true, Keys: opts.Keys}, func(command types.AOFCommand) error {
+		if opts.Dump {
+			if err := encoder.Encode(command); err != nil {
+				return err
+			}
+		}
+
+		key := aofKey{Database: command.Database, Collection: command.Collection}
+		s, exists := stats[key]
+		if !exists {
+			s = &aofStats{}
+			stats[key] = s
+		}
+		s.Commands++
+		if vectors, ok := command.Args["vectors"].([]types.Vector); ok {
+			s.Inserted += int64(len(vectors))
+		}
+		if ids, ok := command.Args["ids"].([]string); ok {
+			s.Deleted += int64(len(ids))
+		}
+		commandCounts[command.Command]++
+		return nil
+	})
+	if err != nil {
+		if utils.GetErrorCode(err) != utils.ErrorCodeCorruptedData {
+			return nil, err
+		}
+		result.problem("%v", err)
+	}
+
+	if strictErr != nil {
+		result.problem("the server refuses to start: %v", strictErr)
+	}
+	if report.SkippedRecords > 0 {
+		result.problem("%d corrupt records (%d bytes) are skipped by --aof-repair", report.SkippedRecords, report.SkippedBytes)
+	}
+	if report.TruncatedBytes > 0 {
+		if strictErr == nil {
+			result.warning("torn tail of %d bytes is truncated on the next start", report.TruncatedBytes)
+		} else {
+			result.warning("the last %d bytes can't be read and are cut off by --aof-repair", report.TruncatedBytes)
+		}
+	}
+
+	fmt.Fprintf(summary, "File:     %s\n", path)
+	fmt.Fprintf(summary, "Type:     AOF (format version %d)\n", report.Version)
+	if report.KeyID != "" {
+		fmt.Fprintf(summary, "Key:      %s\n", report.KeyID)
+	}
+	fmt.Fprintf(summary, "Size:     %d bytes\n", info.Size())
+	fmt.Fprintf(summary, "Commands: %d\n", report.Commands)
+
+	if len(commandCounts) > 0 {
+		fmt.Fprintln(summary)
+		printCommandCounts(summary, commandCounts)
+		fmt.Fprintln(summary)
+		printAOFStats(summary, stats)
+	}
+
+	if opts.RepairPath != "" {
+		repaired, err := aof.RepairFile(ctx, path, opts.RepairPath, opts.Keys)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(summary, "\nRepaired file written to %s: %d commands kept, %d corrupt records skipped, %d bytes cut off\n",
+			opts.RepairPath, repaired.Commands, repaired.SkippedRecords, repaired.TruncatedBytes)
+	}
+
+	result.print(summary)
+	return result, nil
+}
+
+// printCommandCounts prints how often each command type was logged
+func printCommandCounts(w io.Writer, counts map[string]int64) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMMAND\tCOUNT")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%d\n", name, counts[name])
+	}
+	tw.Flush()
+}
+
+// printAOFStats prints the command statistics per database and collection
+func printAOFStats(w io.Writer, stats map[aofKey]*aofStats) {
+	keys := make([]aofKey, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Database != keys[j].Database {
+			return keys[i].Database < keys[j].Database
+		}
+		return keys[i].Collection < keys[j].Collection
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATABASE\tCOLLECTION\tCOMMANDS\tINSERTED\tDELETED")
+	for _, key := range keys {
+		s := stats[key]
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", orDash(key.Database), orDash(key.Collection), s.Commands, s.Inserted, s.Deleted)
+	}
+	tw.Flush()
+}
+
+// orDash returns "-" for empty names
+func orDash(name string) string {
+	if name == "" {
+		return "-"
+	}
+	return name
+}
@@ -0,0 +1,291 @@
+// Package main provides unit tests for the offline checker.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/core/database"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// writeTestAOF logs a database, a collection and an insert, returning the file size after each record
+func writeTestAOF(t *testing.T, path string) []int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	logger, err := aof.NewAOFLogger(path, aof.SyncAlways)
+	if err != nil {
+		t.Fatalf("Failed to create AOF logger: %v", err)
+	}
+	defer logger.Close()
+
+	builder := aof.NewCommandBuilder()
+	commands := []types.AOFCommand{
+		builder.CreateDatabase("db"),
+		builder.CreateCollection("db", "vectors", types.CollectionConfig{Name: "vectors", Metric: types.DistanceMetricL2, HNSWParams: types.DefaultHNSWParams()}),
+		builder.InsertVectors("db", "vectors", []types.Vector{{ID: 1, Elements: []float32{1, 2}}, {ID: 2, Elements: []float32{3, 4}}}),
+	}
+
+	var ends []int64
+	for _, command := range commands {
+		if err := logger.WriteCommand(ctx, command); err != nil {
+			t.Fatalf("Failed to write command: %v", err)
+		}
+		ends = append(ends, logger.GetStats().FileSize)
+	}
+	return ends
+}
+
+// writeTestRDB saves a snapshot of a collection with vectors in two partitions
+func writeTestRDB(t *testing.T, path string) {
+	t.Helper()
+	ctx := context.Background()
+
+	engine := database.NewEngine()
+	if err := engine.CreateDatabase(ctx, "db"); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	db, _ := engine.GetDatabase(ctx, "db")
+	config := types.CollectionConfig{
+		Name:       "vectors",
+		Metric:     types.DistanceMetricL2,
+		HNSWParams: types.HNSWParams{M: 4, EfConstruction: 50, EfSearch: 50, MaxLayers: 16, Seed: 12345},
+	}
+	if err := db.CreateCollection(ctx, config); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "vectors")
+	if err := collection.CreatePartition(ctx, "extra"); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+
+	vectors := make([]types.Vector, 200)
+	for i := range vectors {
+		vectors[i] = types.Vector{Elements: []float32{float32(i % 17), float32(i % 5), float32(i)}}
+		if i%4 == 0 {
+			vectors[i].Partition = "extra"
+		}
+	}
+	if err := collection.Insert(ctx, vectors); err != nil {
+		t.Fatalf("Failed to insert vectors: %v", err)
+	}
+	if _, err := collection.Delete(ctx, []string{"3", "10", "11"}); err != nil {
+		t.Fatalf("Failed to delete vectors: %v", err)
+	}
+
+	state, err := engine.GetDatabaseState(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get database state: %v", err)
+	}
+	manager, err := rdb.NewRDBManager(path)
+	if err != nil {
+		t.Fatalf("Failed to create RDB manager: %v", err)
+	}
+	if err := manager.Save(ctx, manager.CreateSnapshot(state)); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+}
+
+func TestCheckAOF(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	ends := writeTestAOF(t, path)
+
+	var stdout, stderr bytes.Buffer
+	result, err := checkAOF(ctx, path, checkOptions{}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Failed to check AOF: %v", err)
+	}
+	if !result.OK() {
+		t.Fatalf("Expected intact AOF, got %v", result.Problems)
+	}
+	if !strings.Contains(stdout.String(), "Commands: 3") || !strings.Contains(stdout.String(), "INSERT_VECTORS") {
+		t.Errorf("Unexpected summary:\n%s", stdout.String())
+	}
+
+	// Dumping writes one JSON object per command and keeps the summary out of the way
+	stdout.Reset()
+	stderr.Reset()
+	if _, err := checkAOF(ctx, path, checkOptions{Dump: true}, &stdout, &stderr); err != nil {
+		t.Fatalf("Failed to dump AOF: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 JSON lines, got %d:\n%s", len(lines), stdout.String())
+	}
+	var command types.AOFCommand
+	if err := json.Unmarshal([]byte(lines[0]), &command); err != nil || command.Command != "CREATE_DATABASE" {
+		t.Errorf("Expected CREATE_DATABASE as first line, got %s (%v)", lines[0], err)
+	}
+	if !strings.Contains(stderr.String(), "Status: OK") {
+		t.Errorf("Expected summary on stderr, got:\n%s", stderr.String())
+	}
+
+	// Damage the collection record and repair into a new file
+	data, _ := os.ReadFile(path)
+	data[ends[1]-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to damage AOF: %v", err)
+	}
+
+	repairedPath := filepath.Join(t.TempDir(), "repaired.aof")
+	stdout.Reset()
+	result, err = checkAOF(ctx, path, checkOptions{RepairPath: repairedPath}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Failed to check damaged AOF: %v", err)
+	}
+	if result.OK() {
+		t.Fatalf("Expected problems in damaged AOF, got:\n%s", stdout.String())
+	}
+
+	result, err = checkAOF(ctx, repairedPath, checkOptions{}, &stdout, &stderr)
+	if err != nil || !result.OK() {
+		t.Fatalf("Expected repaired AOF to be intact, got %v, %v", result, err)
+	}
+	var replayed []string
+	if _, err := aof.ReplayFile(ctx, repairedPath, aof.ReplayOptions{}, func(command types.AOFCommand) error {
+		replayed = append(replayed, command.Command)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to replay repaired AOF: %v", err)
+	}
+	if strings.Join(replayed, ",") != "CREATE_DATABASE,INSERT_VECTORS" {
+		t.Errorf("Expected the damaged record to be dropped, got %v", replayed)
+	}
+}
+
+func TestCheckAOF_TornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	ends := writeTestAOF(t, path)
+	if err := os.Truncate(path, ends[2]-1); err != nil {
+		t.Fatalf("Failed to truncate AOF: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	result, err := checkAOF(context.Background(), path, checkOptions{}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Failed to check AOF: %v", err)
+	}
+
+	// The server truncates a torn tail by itself
+	if !result.OK() || len(result.Warnings) != 1 {
+		t.Errorf("Expected a warning only, got problems %v and warnings %v", result.Problems, result.Warnings)
+	}
+
+	// Checking never modifies the file
+	if info, _ := os.Stat(path); info.Size() != ends[2]-1 {
+		t.Errorf("Expected file size %d to be unchanged, got %d", ends[2]-1, info.Size())
+	}
+}
+
+func TestCheckRDB(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vector.rdb")
+	writeTestRDB(t, path)
+
+	var out bytes.Buffer
+	result, err := checkRDB(ctx, path, checkOptions{}, &out)
+	if err != nil {
+		t.Fatalf("Failed to check RDB: %v", err)
+	}
+	if !result.OK() {
+		t.Fatalf("Expected graphs built by the server to pass, got %v", result.Problems)
+	}
+	if !strings.Contains(out.String(), "_default") || !strings.Contains(out.String(), "extra") {
+		t.Errorf("Expected both partitions in the summary:\n%s", out.String())
+	}
+
+	// Break the default partition's graph
+	manager, _ := rdb.NewRDBManager(path)
+	snapshot, err := manager.Load(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	collSnapshot := snapshot.Databases["db"].Collections["vectors"]
+	collSnapshot.HNSWGraph.EntryPointID = "999999"
+	collSnapshot.HNSWGraph.Nodes[0].LayerConnections[0].ConnectedNodeIDs[0] = "424242"
+	if err := manager.Save(ctx, *snapshot); err != nil {
+		t.Fatalf("Failed to save damaged snapshot: %v", err)
+	}
+
+	repairedPath := filepath.Join(t.TempDir(), "repaired.rdb")
+	out.Reset()
+	result, err = checkRDB(ctx, path, checkOptions{RepairPath: repairedPath}, &out)
+	if err != nil {
+		t.Fatalf("Failed to check damaged RDB: %v", err)
+	}
+	if len(result.Problems) != 2 {
+		t.Fatalf("Expected entry point and edge problems, got %v", result.Problems)
+	}
+
+	// The rebuilt graph passes and the repaired snapshot restores
+	out.Reset()
+	result, err = checkRDB(ctx, repairedPath, checkOptions{}, &out)
+	if err != nil || !result.OK() {
+		t.Fatalf("Expected repaired RDB to be intact, got %v, %v:\n%s", result, err, out.String())
+	}
+	repaired, err := loadSnapshot(ctx, repairedPath, nil)
+	if err != nil {
+		t.Fatalf("Failed to load repaired snapshot: %v", err)
+	}
+	engine := database.NewEngine()
+	if err := engine.RestoreFromSnapshot(ctx, repaired); err != nil {
+		t.Fatalf("Failed to restore repaired snapshot: %v", err)
+	}
+	db, _ := engine.GetDatabase(ctx, "db")
+	info, err := db.GetCollectionInfo(ctx, "vectors")
+	if err != nil || info.VectorCount != 197 {
+		t.Errorf("Expected 197 vectors after restore, got %+v, %v", info, err)
+	}
+}
+
+func TestCheckRDB_Unreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vector.rdb")
+	if err := os.WriteFile(path, []byte("not a snapshot at all"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	var out bytes.Buffer
+	result, err := checkRDB(context.Background(), path, checkOptions{}, &out)
+	if err != nil {
+		t.Fatalf("Failed to check RDB: %v", err)
+	}
+	if result.OK() {
+		t.Errorf("Expected garbage to fail the check:\n%s", out.String())
+	}
+}
+
+func TestDetectFileType(t *testing.T) {
+	dir := t.TempDir()
+	aofPath := filepath.Join(dir, "log")
+	writeTestAOF(t, aofPath)
+
+	tests := []struct {
+		path string
+		kind string
+		want string
+	}{
+		{"data/appendonly.aof", "auto", "aof"},
+		{"data/vector.rdb", "auto", "rdb"},
+		{aofPath, "auto", "aof"},
+		{"anything", "rdb", "rdb"},
+	}
+	for _, tt := range tests {
+		got, err := detectFileType(tt.path, tt.kind)
+		if err != nil || got != tt.want {
+			t.Errorf("detectFileType(%s, %s) = %s, %v, want %s", tt.path, tt.kind, got, err, tt.want)
+		}
+	}
+
+	if _, err := detectFileType(aofPath, "json"); err == nil {
+		t.Error("Expected invalid type to fail")
+	}
+}
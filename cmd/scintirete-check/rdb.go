@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/scintirete/scintirete/internal/core/algorithm"
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// checkRDB validates an RDB snapshot, verifies the HNSW graph of every loaded partition
+// and prints statistics to w. The repaired copy rebuilds invalid graphs from the vectors.
+func checkRDB(ctx context.Context, path string, opts checkOptions, w io.Writer) (*checkResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &checkResult{}
+
+	fmt.Fprintf(w, "File:         %s\n", path)
+	fmt.Fprintf(w, "Type:         RDB\n")
+	fmt.Fprintf(w, "Size:         %d bytes\n", info.Size())
+
+	compression, err := rdb.DetectCompression(path)
+	if err != nil {
+		result.problem("cannot read header: %v", err)
+		result.print(w)
+		return result, nil
+	}
+	fmt.Fprintf(w, "Compression:  %s\n", compression)
+	if keyID, err := rdb.DetectKeyID(path); err == nil && keyID != "" {
+		fmt.Fprintf(w, "Key:          %s\n", keyID)
+	}
+
+	snapshot, err := loadSnapshot(ctx, path, opts.Keys)
+	if utils.GetErrorCode(err) == utils.ErrorCodeConfig {
+		return nil, err // Missing or wrong keys say nothing about the file
+	}
+	if err != nil {
+		result.problem("cannot load snapshot: %v", err)
+		if opts.RepairPath != "" {
+			result.warning("an unreadable snapshot can't be repaired, no file was written")
+		}
+		result.print(w)
+		return result, nil
+	}
+
+	fmt.Fprintf(w, "Version:      %s\n", snapshot.Version)
+	fmt.Fprintf(w, "Timestamp:    %s\n", snapshot.Timestamp.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Databases:    %d\n\n", len(snapshot.Databases))
+
+	// Graphs failing verification, rebuilt when repairing
+	var invalid []graphRef
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATABASE\tCOLLECTION\tPARTITION\tVECTORS\tDIMENSION\tMETRIC\tNODES\tLAYERS\tGRAPH")
+	for _, dbName := range sortedKeys(snapshot.Databases) {
+		dbSnapshot := snapshot.Databases[dbName]
+		if len(dbSnapshot.Collections) == 0 {
+			fmt.Fprintf(tw, "%s\t-\t-\t0\t-\t-\t-\t-\t-\n", dbName)
+		}
+
+		for _, collName := range sortedKeys(dbSnapshot.Collections) {
+			collSnapshot := dbSnapshot.Collections[collName]
+			for _, ref := range collectionGraphs(dbName, collSnapshot) {
+				vectors := ref.vectors(collSnapshot)
+				dimension := "-"
+				if len(vectors) > 0 {
+					dimension = strconv.Itoa(len(vectors[0].Elements))
+				}
+
+				nodes, layers, status := "-", "-", "released"
+				if !ref.released {
+					graph := ref.graph(collSnapshot)
+					status = "ok"
+					if graph != nil {
+						nodes = strconv.Itoa(len(graph.Nodes))
+						layers = strconv.Itoa(graph.MaxLayer + 1)
+					}
+					if problems := verifyGraph(graph, vectors, collSnapshot.Config.HNSWParams); len(problems) > 0 {
+						status = "invalid"
+						invalid = append(invalid, ref)
+						for _, problem := range problems {
+							result.problem("%s/%s partition %s: %s", dbName, collName, ref.partition, problem)
+						}
+					}
+				}
+
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+					dbName, collName, ref.partition, len(vectors), dimension, collSnapshot.Config.Metric, nodes, layers, status)
+			}
+		}
+	}
+	tw.Flush()
+
+	if opts.RepairPath != "" {
+		if err := repairRDB(ctx, snapshot, invalid, opts.RepairPath, compression, opts.Keys); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(w, "\nRepaired file written to %s: %d graphs rebuilt\n", opts.RepairPath, len(invalid))
+	}
+
+	result.print(w)
+	return result, nil
+}
+
+// loadSnapshot loads an RDB file. Malformed FlatBuffers can make the generated accessors
+// panic, which is reported as an error instead.
+func loadSnapshot(ctx context.Context, path string, keys encryption.KeyProvider) (snapshot *rdb.RDBSnapshot, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			snapshot, err = nil, fmt.Errorf("malformed snapshot: %v", r)
+		}
+	}()
+
+	manager, err := rdb.NewRDBManager(path)
+	if err != nil {
+		return nil, err
+	}
+	manager.SetEncryption(keys)
+	return manager.Load(ctx)
+}
+
+// graphRef locates the HNSW graph of one partition inside a snapshot
+type graphRef struct {
+	database   string
+	collection string
+	partition  string
+	index      int // Index into CollectionSnapshot.Partitions, -1 for snapshots without partitions
+	released   bool
+}
+
+// collectionGraphs lists the partitions of a collection, default partition first
+func collectionGraphs(dbName string, collSnapshot rdb.CollectionSnapshot) []graphRef {
+	// Snapshots written before partitions existed only carry the default partition
+	if len(collSnapshot.Partitions) == 0 {
+		return []graphRef{{database: dbName, collection: collSnapshot.Name, partition: types.DefaultPartitionName, index: -1}}
+	}
+
+	refs := make([]graphRef, 0, len(collSnapshot.Partitions))
+	for i, partitionSnapshot := range collSnapshot.Partitions {
+		refs = append(refs, graphRef{
+			database:   dbName,
+			collection: collSnapshot.Name,
+			partition:  partitionSnapshot.Name,
+			index:      i,
+			released:   partitionSnapshot.Released,
+		})
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].partition == types.DefaultPartitionName && refs[j].partition != types.DefaultPartitionName
+	})
+	return refs
+}
+
+// graph returns the graph of the partition. The default partition's graph is kept in the collection.
+func (r graphRef) graph(collSnapshot rdb.CollectionSnapshot) *rdb.HNSWGraphSnapshot {
+	if r.partition == types.DefaultPartitionName || r.index < 0 {
+		return collSnapshot.HNSWGraph
+	}
+	return collSnapshot.Partitions[r.index].HNSWGraph
+}
+
+// setGraph replaces the graph of the partition
+func (r graphRef) setGraph(collSnapshot *rdb.CollectionSnapshot, graph *rdb.HNSWGraphSnapshot) {
+	if r.partition == types.DefaultPartitionName || r.index < 0 {
+		collSnapshot.HNSWGraph = graph
+	}
+	if r.index >= 0 {
+		collSnapshot.Partitions[r.index].HNSWGraph = graph
+	}
+}
+
+// vectors returns the vectors stored in the partition ordered by ID
+func (r graphRef) vectors(collSnapshot rdb.CollectionSnapshot) []types.Vector {
+	var vectors []types.Vector
+	for _, vector := range collSnapshot.Vectors {
+		partition := vector.Partition
+		if partition == "" {
+			partition = types.DefaultPartitionName
+		}
+		if partition == r.partition {
+			vectors = append(vectors, vector)
+		}
+	}
+	sort.Slice(vectors, func(i, j int) bool { return vectors[i].ID < vectors[j].ID })
+	return vectors
+}
+
+// graphIssues collects graph problems, reporting each kind once with its first occurrence
+type graphIssues struct {
+	kinds    []string
+	counts   map[string]int
+	examples map[string]string
+}
+
+// add records an occurrence of a kind of problem
+func (g *graphIssues) add(kind, example string) {
+	if g.counts == nil {
+		g.counts = make(map[string]int)
+		g.examples = make(map[string]string)
+	}
+	if g.counts[kind] == 0 {
+		g.kinds = append(g.kinds, kind)
+		g.examples[kind] = example
+	}
+	g.counts[kind]++
+}
+
+// list formats the recorded problems
+func (g *graphIssues) list() []string {
+	problems := make([]string, 0, len(g.kinds))
+	for _, kind := range g.kinds {
+		if g.counts[kind] == 1 {
+			problems = append(problems, fmt.Sprintf("%s (%s)", kind, g.examples[kind]))
+		} else {
+			problems = append(problems, fmt.Sprintf("%s (%d times, first: %s)", kind, g.counts[kind], g.examples[kind]))
+		}
+	}
+	return problems
+}
+
+// verifyGraph checks the invariants of an HNSW graph against the vectors of its partition:
+// unique numeric node IDs, one live node per vector, a size matching the live nodes, a live
+// entry point on the top layer, layers within bounds and edges to existing nodes within the
+// connection limit of the layer.
+func verifyGraph(graph *rdb.HNSWGraphSnapshot, vectors []types.Vector, params types.HNSWParams) []string {
+	if graph == nil {
+		return []string{"HNSW graph is missing"}
+	}
+
+	var issues graphIssues
+	nodes := make(map[string]*rdb.HNSWNodeSnapshot, len(graph.Nodes))
+	live := 0
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		if id, err := strconv.ParseUint(node.ID, 10, 64); err != nil || id == 0 {
+			issues.add("invalid node ID", fmt.Sprintf("%q", node.ID))
+			continue
+		}
+		if _, exists := nodes[node.ID]; exists {
+			issues.add("duplicate node", "node "+node.ID)
+			continue
+		}
+		nodes[node.ID] = node
+		if !node.Deleted {
+			live++
+		}
+	}
+
+	if graph.Size != live {
+		issues.add("graph size does not match live nodes", fmt.Sprintf("size %d, %d live nodes", graph.Size, live))
+	}
+
+	// Every vector has a live node and every live node a vector
+	vectorIDs := make(map[string]bool, len(vectors))
+	for _, vector := range vectors {
+		id := strconv.FormatUint(vector.ID, 10)
+		vectorIDs[id] = true
+		node, exists := nodes[id]
+		switch {
+		case !exists || node.Deleted:
+			issues.add("vector missing from graph", "vector "+id)
+		case len(node.Elements) != len(vector.Elements):
+			issues.add("node dimension differs from its vector", fmt.Sprintf("node %s has %d dimensions, vector %d", id, len(node.Elements), len(vector.Elements)))
+		}
+	}
+
+	// Visit nodes in file order so reports are stable
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		if nodes[node.ID] != node {
+			continue
+		}
+		if !node.Deleted && !vectorIDs[node.ID] {
+			issues.add("live node without vector", "node "+node.ID)
+		}
+		if node.MaxLayer < 0 || (!node.Deleted && node.MaxLayer > graph.MaxLayer) {
+			issues.add("node layer out of range", fmt.Sprintf("node %s on layer %d, graph max layer %d", node.ID, node.MaxLayer, graph.MaxLayer))
+		}
+
+		seenLayers := make(map[int]bool, len(node.LayerConnections))
+		for _, layerConn := range node.LayerConnections {
+			layer := layerConn.Layer
+			if layer < 0 || layer > node.MaxLayer {
+				issues.add("connections above node layer", fmt.Sprintf("node %s has connections on layer %d but reaches layer %d", node.ID, layer, node.MaxLayer))
+			}
+			if seenLayers[layer] {
+				issues.add("layer listed twice", fmt.Sprintf("node %s layer %d", node.ID, layer))
+			}
+			seenLayers[layer] = true
+
+			limit := params.M
+			if layer == 0 {
+				limit = params.M * 2
+			}
+			if params.M > 0 && len(layerConn.ConnectedNodeIDs) > limit {
+				issues.add("too many connections", fmt.Sprintf("node %s has %d on layer %d, limit %d", node.ID, len(layerConn.ConnectedNodeIDs), layer, limit))
+			}
+
+			neighbors := make(map[string]bool, len(layerConn.ConnectedNodeIDs))
+			for _, neighborID := range layerConn.ConnectedNodeIDs {
+				switch {
+				case neighborID == node.ID:
+					issues.add("self loop", fmt.Sprintf("node %s on layer %d", node.ID, layer))
+				case nodes[neighborID] == nil:
+					issues.add("edge to missing node", fmt.Sprintf("node %s links to %s on layer %d", node.ID, neighborID, layer))
+				case neighbors[neighborID]:
+					issues.add("duplicate edge", fmt.Sprintf("node %s links to %s twice on layer %d", node.ID, neighborID, layer))
+				}
+				neighbors[neighborID] = true
+			}
+		}
+	}
+
+	// Searches start at the entry point, which has to be live and on the top layer
+	if live > 0 {
+		entry, exists := nodes[graph.EntryPointID]
+		switch {
+		case !exists:
+			issues.add("entry point does not exist", "node "+graph.EntryPointID)
+		case entry.Deleted:
+			issues.add("entry point is deleted", "node "+graph.EntryPointID)
+		case entry.MaxLayer != graph.MaxLayer:
+			issues.add("entry point is not on the top layer", fmt.Sprintf("node %s on layer %d, graph max layer %d", entry.ID, entry.MaxLayer, graph.MaxLayer))
+		}
+	}
+
+	return issues.list()
+}
+
+// repairRDB writes a copy of the snapshot to path with the given graphs rebuilt from their
+// vectors, encrypted with keys if set
+func repairRDB(ctx context.Context, snapshot *rdb.RDBSnapshot, invalid []graphRef, path string, compression rdb.Compression, keys encryption.KeyProvider) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	for _, ref := range invalid {
+		collSnapshot := snapshot.Databases[ref.database].Collections[ref.collection]
+		graph, err := rebuildGraph(ctx, collSnapshot.Config, ref.vectors(collSnapshot))
+		if err != nil {
+			return fmt.Errorf("failed to rebuild graph of %s/%s partition %s: %w", ref.database, ref.collection, ref.partition, err)
+		}
+
+		// Partitions share the collection's slice, copy it before changing an entry
+		collSnapshot.Partitions = append([]rdb.PartitionSnapshot(nil), collSnapshot.Partitions...)
+		ref.setGraph(&collSnapshot, graph)
+		snapshot.Databases[ref.database].Collections[ref.collection] = collSnapshot
+	}
+
+	manager, err := rdb.NewRDBManagerWithCompression(path, compression)
+	if err != nil {
+		return err
+	}
+	manager.SetEncryption(keys)
+	return manager.Save(ctx, *snapshot)
+}
+
+// rebuildGraph builds a new HNSW graph from vectors the way the server indexes a partition
+func rebuildGraph(ctx context.Context, config types.CollectionConfig, vectors []types.Vector) (*rdb.HNSWGraphSnapshot, error) {
+	index, err := algorithm.NewHNSW(config.HNSWParams, config.Metric)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Build(ctx, vectors); err != nil {
+		return nil, err
+	}
+
+	state := index.ExportGraphState()
+	return rdb.ConvertHNSWGraphState(&state), nil
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
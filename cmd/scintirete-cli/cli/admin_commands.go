@@ -3,6 +3,8 @@ package cli
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
 )
@@ -68,3 +70,302 @@ func (c *CLI) bgsaveCommand(args []string) error {
 
 	return nil
 }
+
+// backupCommand handles backup operations
+func (c *CLI) backupCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: backup <create|list|restore|delete> [args...]")
+	}
+
+	subCommand := strings.ToLower(args[0])
+	subArgs := args[1:]
+
+	switch subCommand {
+	case "create":
+		return c.createBackupCommand(subArgs)
+	case "list":
+		return c.listBackupsCommand(subArgs)
+	case "restore":
+		if len(subArgs) != 1 {
+			return fmt.Errorf("usage: backup restore <name>")
+		}
+		return c.restoreBackupCommand(subArgs)
+	case "delete":
+		if len(subArgs) != 1 {
+			return fmt.Errorf("usage: backup delete <name>")
+		}
+		return c.deleteBackupCommand(subArgs)
+	default:
+		return fmt.Errorf("unknown backup sub-command: %s", subCommand)
+	}
+}
+
+// createBackupCommand saves a snapshot into the backup directory
+func (c *CLI) createBackupCommand(args []string) error {
+	resp, err := c.client.CreateBackup(context.Background(), &pb.CreateBackupRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %v", err)
+	}
+
+	fmt.Printf("Backup '%s' created (%d bytes)\n", resp.Backup.GetName(), resp.Backup.GetSize())
+	fmt.Printf("Duration: %.3f seconds\n", resp.DurationSeconds)
+	if targets := resp.Backup.GetTargets(); len(targets) > 0 {
+		fmt.Printf("Copied to: %s\n", strings.Join(targets, ", "))
+	}
+	for target, message := range resp.UploadErrors {
+		fmt.Printf("Failed to copy to %s: %s\n", target, message)
+	}
+	for _, name := range resp.Pruned {
+		fmt.Printf("Removed by retention policy: %s\n", name)
+	}
+
+	return nil
+}
+
+// listBackupsCommand lists the backups, newest first
+func (c *CLI) listBackupsCommand(args []string) error {
+	resp, err := c.client.ListBackups(context.Background(), &pb.ListBackupsRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %v", err)
+	}
+
+	if len(resp.Backups) == 0 {
+		fmt.Println("No backups found.")
+		return nil
+	}
+
+	fmt.Println("Backups:")
+	for i, backup := range resp.Backups {
+		created := time.Unix(backup.CreatedAt, 0).Format(time.DateTime)
+		locations := backup.Targets
+		if backup.Local {
+			locations = append([]string{"local"}, locations...)
+		}
+		fmt.Printf("%d) %s  %s  %d bytes  [%s]\n", i+1, backup.Name, created, backup.Size, strings.Join(locations, ", "))
+	}
+
+	return nil
+}
+
+// restoreBackupCommand replaces all data on the server with a backup
+func (c *CLI) restoreBackupCommand(args []string) error {
+	resp, err := c.client.RestoreBackup(context.Background(), &pb.RestoreBackupRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+		Name: args[0],
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %v", err)
+	}
+
+	fmt.Printf("Backup '%s' restored\n", args[0])
+	fmt.Printf("Duration: %.3f seconds\n", resp.DurationSeconds)
+	return nil
+}
+
+// deleteBackupCommand deletes a backup
+func (c *CLI) deleteBackupCommand(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := c.client.DeleteBackup(ctx, &pb.DeleteBackupRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+		Name: args[0],
+	}); err != nil {
+		return fmt.Errorf("failed to delete backup: %v", err)
+	}
+
+	fmt.Printf("Backup '%s' deleted\n", args[0])
+	return nil
+}
+
+// replicaOfCommand makes the server a replica of a primary, or a primary again with "no one"
+func (c *CLI) replicaOfCommand(args []string) error {
+	req := &pb.ReplicaOfRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+	}
+	switch {
+	case len(args) == 2 && strings.EqualFold(args[0], "no") && strings.EqualFold(args[1], "one"):
+		// An empty primary stops replication
+	case len(args) == 1 || len(args) == 2:
+		req.Primary = args[0]
+		if len(args) == 2 {
+			req.PrimaryPassword = args[1]
+		}
+	default:
+		return fmt.Errorf("usage: replicaof <host:port> [primary_password] | replicaof no one")
+	}
+
+	resp, err := c.client.ReplicaOf(context.Background(), req)
+	if err != nil {
+		return fmt.Errorf("failed to change replication: %v", err)
+	}
+
+	fmt.Println(resp.Message)
+	return nil
+}
+
+// replicationCommand shows the role of the server and the state of its replication
+func (c *CLI) replicationCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: replication")
+	}
+
+	resp, err := c.client.GetServerInfo(context.Background(), &pb.GetServerInfoRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get server info: %v", err)
+	}
+
+	info := resp.Replication
+	fmt.Printf("Role: %s\n", info.GetRole())
+	fmt.Printf("Replication ID: %s\n", info.GetReplicationId())
+	fmt.Printf("Offset: %d\n", info.GetOffset())
+	if info.GetRole() == "replica" {
+		link := "down"
+		if info.LinkUp {
+			link = "up"
+		}
+		fmt.Printf("Primary: %s (link %s)\n", info.Primary, link)
+		fmt.Printf("Lag: %d commands, %.3f seconds\n", info.LagCommands, info.LagSeconds)
+		fmt.Printf("Last message from primary: %.1f seconds ago\n", info.LastIoSeconds)
+		fmt.Printf("Resynchronizations: %d full, %d partial\n", info.FullSyncs, info.PartialSyncs)
+		if info.LastError != "" {
+			fmt.Printf("Last error: %s\n", info.LastError)
+		}
+		return nil
+	}
+
+	fmt.Printf("Backlog: from offset %d, %d bytes\n", info.GetBacklogFirstOffset(), info.GetBacklogBytes())
+	if len(info.GetReplicas()) == 0 {
+		fmt.Println("No replicas connected.")
+		return nil
+	}
+	fmt.Println("Replicas:")
+	for i, replica := range info.Replicas {
+		connected := time.Unix(replica.ConnectedAt, 0).Format(time.DateTime)
+		fmt.Printf("%d) %s  %s  offset %d  lag %d commands  connected %s\n", i+1, replica.Name, replica.Address, replica.Offset, replica.LagCommands, connected)
+	}
+	return nil
+}
+
+// webhooksCommand shows the delivery metrics of the webhooks configured on the server
+func (c *CLI) webhooksCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: webhooks")
+	}
+
+	resp, err := c.client.GetServerInfo(context.Background(), &pb.GetServerInfoRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get server info: %v", err)
+	}
+
+	if len(resp.Webhooks) == 0 {
+		fmt.Println("No webhooks configured.")
+		return nil
+	}
+	for i, hook := range resp.Webhooks {
+		fmt.Printf("%d) %s  %s\n", i+1, hook.Name, hook.Url)
+		fmt.Printf("   Delivered: %d  Retries: %d  Dead-lettered: %d  Missed: %d  Pending: %d\n",
+			hook.Delivered, hook.Retries, hook.DeadLettered, hook.Missed, hook.Pending)
+		if hook.LastDeliveryAt > 0 {
+			fmt.Printf("   Last delivery: %s (%.1f ms)\n", time.Unix(hook.LastDeliveryAt, 0).Format(time.DateTime), hook.LastLatencyMs)
+		}
+		if hook.LastError != "" {
+			fmt.Printf("   Last error: %s\n", hook.LastError)
+		}
+	}
+	return nil
+}
+
+// clusterCommand handles cluster membership operations
+func (c *CLI) clusterCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: cluster <status|add|remove> [args...]")
+	}
+
+	subCommand := strings.ToLower(args[0])
+	subArgs := args[1:]
+
+	switch subCommand {
+	case "status":
+		return c.clusterStatusCommand(subArgs)
+	case "add":
+		if len(subArgs) != 3 {
+			return fmt.Errorf("usage: cluster add <node_id> <raft_address> <grpc_address>")
+		}
+		return c.addClusterNodeCommand(subArgs)
+	case "remove":
+		if len(subArgs) != 1 {
+			return fmt.Errorf("usage: cluster remove <node_id>")
+		}
+		return c.removeClusterNodeCommand(subArgs)
+	default:
+		return fmt.Errorf("unknown cluster sub-command: %s", subCommand)
+	}
+}
+
+// clusterStatusCommand shows the state of the node and the members of its cluster
+func (c *CLI) clusterStatusCommand(args []string) error {
+	resp, err := c.client.GetClusterStatus(context.Background(), &pb.GetClusterStatusRequest{
+		Auth: &pb.AuthInfo{Password: c.password},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get cluster status: %v", err)
+	}
+
+	fmt.Printf("Node: %s (%s)\n", resp.NodeId, resp.State)
+	if resp.LeaderId != "" {
+		fmt.Printf("Leader: %s %s\n", resp.LeaderId, resp.LeaderAddress)
+	} else {
+		fmt.Println("Leader: none")
+	}
+	fmt.Printf("Term: %d, commit index %d, applied index %d\n", resp.Term, resp.CommitIndex, resp.AppliedIndex)
+	fmt.Println("Nodes:")
+	for i, node := range resp.Nodes {
+		role := "voter"
+		if node.Leader {
+			role = "leader"
+		} else if !node.Voter {
+			role = "non-voter"
+		}
+		fmt.Printf("%d) %s  raft %s  grpc %s  %s\n", i+1, node.NodeId, node.RaftAddress, node.Address, role)
+	}
+	return nil
+}
+
+// addClusterNodeCommand adds a node to the cluster
+func (c *CLI) addClusterNodeCommand(args []string) error {
+	resp, err := c.client.AddClusterNode(context.Background(), &pb.AddClusterNodeRequest{
+		Auth:        &pb.AuthInfo{Password: c.password},
+		NodeId:      args[0],
+		RaftAddress: args[1],
+		Address:     args[2],
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add cluster node: %v", err)
+	}
+
+	fmt.Println(resp.Message)
+	return nil
+}
+
+// removeClusterNodeCommand removes a node from the cluster
+func (c *CLI) removeClusterNodeCommand(args []string) error {
+	resp, err := c.client.RemoveClusterNode(context.Background(), &pb.RemoveClusterNodeRequest{
+		Auth:   &pb.AuthInfo{Password: c.password},
+		NodeId: args[0],
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove cluster node: %v", err)
+	}
+
+	fmt.Println(resp.Message)
+	return nil
+}
@@ -20,7 +20,7 @@ func (c *CLI) collectionCommand(args []string) error {
 	}
 
 	if len(args) == 0 {
-		return fmt.Errorf("usage: collection <list|create|drop|info> [args...]")
+		return fmt.Errorf("usage: collection <list|create|create-sharded|drop|info|rename|clone|copy|reshard|export> [args...]")
 	}
 
 	subCommand := strings.ToLower(args[0])
@@ -34,6 +34,11 @@ func (c *CLI) collectionCommand(args []string) error {
 			return fmt.Errorf("usage: collection create <name> <metric> [m] [ef_construction]")
 		}
 		return c.createCollectionCommand(subArgs)
+	case "create-sharded":
+		if len(subArgs) < 3 {
+			return fmt.Errorf("usage: collection create-sharded <name> <metric> <shard1> [shard2] ...")
+		}
+		return c.createShardedCollectionCommand(subArgs)
 	case "drop":
 		if len(subArgs) < 1 {
 			return fmt.Errorf("usage: collection drop <name>")
@@ -44,6 +49,31 @@ func (c *CLI) collectionCommand(args []string) error {
 			return fmt.Errorf("usage: collection info <name>")
 		}
 		return c.collectionInfoCommand(subArgs)
+	case "rename":
+		if len(subArgs) < 2 {
+			return fmt.Errorf("usage: collection rename <name> <new_name>")
+		}
+		return c.renameCollectionCommand(subArgs)
+	case "clone":
+		if len(subArgs) < 2 {
+			return fmt.Errorf("usage: collection clone <name> <target_name>")
+		}
+		return c.cloneCollectionCommand(subArgs)
+	case "copy":
+		if len(subArgs) < 2 {
+			return fmt.Errorf("usage: collection copy <name> <target_db> [target_name]")
+		}
+		return c.copyCollectionCommand(subArgs)
+	case "reshard":
+		if len(subArgs) < 2 {
+			return fmt.Errorf("usage: collection reshard <name> <shard1> [shard2] ...")
+		}
+		return c.reshardCollectionCommand(subArgs)
+	case "export":
+		if len(subArgs) < 2 {
+			return fmt.Errorf("usage: collection export <name> <file> [options]")
+		}
+		return c.exportCollectionCommand(subArgs)
 	default:
 		return fmt.Errorf("unknown collection sub-command: %s", subCommand)
 	}
@@ -91,18 +121,9 @@ func (c *CLI) createCollectionCommand(args []string) error {
 	}
 
 	name := args[0]
-	metricStr := strings.ToUpper(args[1])
-
-	var metric pb.DistanceMetric
-	switch metricStr {
-	case "L2", "EUCLIDEAN":
-		metric = pb.DistanceMetric_L2
-	case "COSINE":
-		metric = pb.DistanceMetric_COSINE
-	case "INNER_PRODUCT", "IP":
-		metric = pb.DistanceMetric_INNER_PRODUCT
-	default:
-		return fmt.Errorf("invalid metric: %s. Use L2, COSINE, or INNER_PRODUCT", metricStr)
+	metric, err := parseMetric(args[1])
+	if err != nil {
+		return err
 	}
 
 	req := &pb.CreateCollectionRequest{
@@ -136,7 +157,7 @@ func (c *CLI) createCollectionCommand(args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := c.client.CreateCollection(ctx, req)
+	_, err = c.client.CreateCollection(ctx, req)
 	if err != nil {
 		return fmt.Errorf("failed to create collection: %v", err)
 	}
@@ -145,6 +166,54 @@ func (c *CLI) createCollectionCommand(args []string) error {
 	return nil
 }
 
+// createShardedCollectionCommand creates a collection whose vectors are spread over shards
+func (c *CLI) createShardedCollectionCommand(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: collection create-sharded <name> <metric> <shard1> [shard2] ...")
+	}
+
+	if currentDatabase == "" {
+		return fmt.Errorf("no database selected. Use 'use <database>' first")
+	}
+
+	metric, err := parseMetric(args[1])
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err = c.client.CreateCollection(ctx, &pb.CreateCollectionRequest{
+		Auth:           &pb.AuthInfo{Password: c.password},
+		DbName:         currentDatabase,
+		CollectionName: args[0],
+		MetricType:     metric,
+		Shards:         args[2:],
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %v", err)
+	}
+
+	fmt.Printf("Collection '%s' created on %d shards.\n", args[0], len(args)-2)
+	return nil
+}
+
+// parseMetric parses a distance metric name
+func parseMetric(name string) (pb.DistanceMetric, error) {
+	switch strings.ToUpper(name) {
+	case "L2", "EUCLIDEAN":
+		return pb.DistanceMetric_L2, nil
+	case "COSINE":
+		return pb.DistanceMetric_COSINE, nil
+	case "INNER_PRODUCT", "IP":
+		return pb.DistanceMetric_INNER_PRODUCT, nil
+	default:
+		return pb.DistanceMetric_DISTANCE_METRIC_UNSPECIFIED,
+			fmt.Errorf("invalid metric: %s. Use L2, COSINE, or INNER_PRODUCT", strings.ToUpper(name))
+	}
+}
+
 // dropCollectionCommand drops a collection
 func (c *CLI) dropCollectionCommand(args []string) error {
 	if len(args) < 1 {
@@ -204,7 +273,132 @@ func (c *CLI) collectionInfoCommand(args []string) error {
 	if resp.HnswConfig != nil {
 		fmt.Printf("HNSW Config: M=%d, EfConstruction=%d\n", resp.HnswConfig.M, resp.HnswConfig.EfConstruction)
 	}
+	if len(resp.Shards) > 0 {
+		fmt.Printf("Shards: %s\n", strings.Join(resp.Shards, ", "))
+	}
+	if len(resp.ReshardingFrom) > 0 {
+		fmt.Printf("Resharding From: %s\n", strings.Join(resp.ReshardingFrom, ", "))
+	}
+
+	return nil
+}
+
+// renameCollectionCommand renames a collection
+func (c *CLI) renameCollectionCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: collection rename <name> <new_name>")
+	}
+
+	if currentDatabase == "" {
+		return fmt.Errorf("no database selected. Use 'use <database>' first")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := c.client.RenameCollection(ctx, &pb.RenameCollectionRequest{
+		Auth:           &pb.AuthInfo{Password: c.password},
+		DbName:         currentDatabase,
+		CollectionName: args[0],
+		NewName:        args[1],
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to rename collection: %v", err)
+	}
+
+	fmt.Printf("Collection '%s' renamed to '%s'.\n", args[0], args[1])
+	return nil
+}
+
+// cloneCollectionCommand clones a collection within the current database
+func (c *CLI) cloneCollectionCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: collection clone <name> <target_name>")
+	}
+
+	if currentDatabase == "" {
+		return fmt.Errorf("no database selected. Use 'use <database>' first")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.client.CloneCollection(ctx, &pb.CloneCollectionRequest{
+		Auth:           &pb.AuthInfo{Password: c.password},
+		DbName:         currentDatabase,
+		CollectionName: args[0],
+		TargetName:     args[1],
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to clone collection: %v", err)
+	}
+
+	fmt.Printf("Collection '%s' cloned to '%s' (%d vectors).\n", args[0], args[1], resp.VectorCount)
+	return nil
+}
+
+// copyCollectionCommand copies a collection into another database
+func (c *CLI) copyCollectionCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: collection copy <name> <target_db> [target_name]")
+	}
+
+	if currentDatabase == "" {
+		return fmt.Errorf("no database selected. Use 'use <database>' first")
+	}
+
+	targetName := args[0]
+	if len(args) >= 3 {
+		targetName = args[2]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := c.client.CopyCollection(ctx, &pb.CopyCollectionRequest{
+		Auth:           &pb.AuthInfo{Password: c.password},
+		DbName:         currentDatabase,
+		CollectionName: args[0],
+		TargetDbName:   args[1],
+		TargetName:     targetName,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to copy collection: %v", err)
+	}
+
+	fmt.Printf("Collection '%s' copied to '%s.%s' (%d vectors).\n", args[0], args[1], targetName, resp.VectorCount)
+	return nil
+}
+
+// reshardCollectionCommand moves a sharded collection to a new list of shards
+func (c *CLI) reshardCollectionCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: collection reshard <name> <shard1> [shard2] ...")
+	}
+
+	if currentDatabase == "" {
+		return fmt.Errorf("no database selected. Use 'use <database>' first")
+	}
+
+	// Moving the vectors may take a while
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	resp, err := c.client.ReshardCollection(ctx, &pb.ReshardCollectionRequest{
+		Auth:           &pb.AuthInfo{Password: c.password},
+		DbName:         currentDatabase,
+		CollectionName: args[0],
+		Shards:         args[1:],
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reshard collection: %v", err)
+	}
 
+	fmt.Printf("Collection '%s' resharded to %d shards (%d vectors moved in %.2fs).\n",
+		args[0], len(args)-1, resp.MovedVectors, resp.DurationSeconds)
 	return nil
 }
 
@@ -24,18 +24,24 @@ func SetVersion(version, commit string) {
 // GetCommands returns the commands registry
 func GetCommands() map[string]Command {
 	return map[string]Command{
-		"help":       {Name: "help", Description: "Show help information", Usage: "help [command]", Handler: (*CLI).helpCommand},
-		"quit":       {Name: "quit", Description: "Exit the CLI", Usage: "quit", Handler: (*CLI).quitCommand},
-		"exit":       {Name: "exit", Description: "Exit the CLI", Usage: "exit", Handler: (*CLI).quitCommand},
-		"ping":       {Name: "ping", Description: "Test connection to server", Usage: "ping", Handler: (*CLI).pingCommand},
-		"version":    {Name: "version", Description: "Show version information", Usage: "version", Handler: (*CLI).versionCommand},
-		"use":        {Name: "use", Description: "Switch to a database", Usage: "use <database>", Handler: (*CLI).useCommand},
-		"database":   {Name: "database", Description: "Database operations", Usage: "database <list|create|drop> [args...]", Handler: (*CLI).databaseCommand},
-		"collection": {Name: "collection", Description: "Collection operations", Usage: "collection <list|create|drop|info> [args...]", Handler: (*CLI).collectionCommand},
-		"vector":     {Name: "vector", Description: "Vector operations", Usage: "vector <insert|search|delete> [args...]", Handler: (*CLI).vectorCommand},
-		"text":       {Name: "text", Description: "Text embedding operations", Usage: "text <insert|search|models> <args...>", Handler: (*CLI).textCommand},
-		"save":       {Name: "save", Description: "Synchronously save RDB snapshot", Usage: "save", Handler: (*CLI).saveCommand},
-		"bgsave":     {Name: "bgsave", Description: "Asynchronously save RDB snapshot", Usage: "bgsave", Handler: (*CLI).bgsaveCommand},
+		"help":        {Name: "help", Description: "Show help information", Usage: "help [command]", Handler: (*CLI).helpCommand},
+		"quit":        {Name: "quit", Description: "Exit the CLI", Usage: "quit", Handler: (*CLI).quitCommand},
+		"exit":        {Name: "exit", Description: "Exit the CLI", Usage: "exit", Handler: (*CLI).quitCommand},
+		"ping":        {Name: "ping", Description: "Test connection to server", Usage: "ping", Handler: (*CLI).pingCommand},
+		"version":     {Name: "version", Description: "Show version information", Usage: "version", Handler: (*CLI).versionCommand},
+		"use":         {Name: "use", Description: "Switch to a database", Usage: "use <database>", Handler: (*CLI).useCommand},
+		"database":    {Name: "database", Description: "Database operations", Usage: "database <list|create|drop> [args...]", Handler: (*CLI).databaseCommand},
+		"collection":  {Name: "collection", Description: "Collection operations", Usage: "collection <list|create|create-sharded|drop|info|rename|clone|copy|reshard|export> [args...]", Handler: (*CLI).collectionCommand},
+		"vector":      {Name: "vector", Description: "Vector operations", Usage: "vector <insert|search|delete> [args...]", Handler: (*CLI).vectorCommand},
+		"import":      {Name: "import", Description: "Bulk import vectors from a local file", Usage: "import <collection> <file> [options]", Handler: (*CLI).importCommand},
+		"text":        {Name: "text", Description: "Text embedding operations", Usage: "text <insert|search|models> <args...>", Handler: (*CLI).textCommand},
+		"save":        {Name: "save", Description: "Synchronously save RDB snapshot", Usage: "save", Handler: (*CLI).saveCommand},
+		"bgsave":      {Name: "bgsave", Description: "Asynchronously save RDB snapshot", Usage: "bgsave", Handler: (*CLI).bgsaveCommand},
+		"backup":      {Name: "backup", Description: "Backup operations", Usage: "backup <create|list|restore|delete> [args...]", Handler: (*CLI).backupCommand},
+		"replicaof":   {Name: "replicaof", Description: "Follow a primary as a read-only replica", Usage: "replicaof <host:port> [primary_password] | replicaof no one", Handler: (*CLI).replicaOfCommand},
+		"replication": {Name: "replication", Description: "Show the replication state", Usage: "replication", Handler: (*CLI).replicationCommand},
+		"cluster":     {Name: "cluster", Description: "Cluster membership operations", Usage: "cluster <status|add|remove> [args...]", Handler: (*CLI).clusterCommand},
+		"webhooks":    {Name: "webhooks", Description: "Show the delivery metrics of the webhooks", Usage: "webhooks", Handler: (*CLI).webhooksCommand},
 	}
 }
 
@@ -58,15 +64,35 @@ func (c *CLI) helpCommand(args []string) error {
 		fmt.Println("  collection create <name> <metric> [m] [ef_construction]  Create a collection")
 		fmt.Println("  collection drop <name>     Drop a collection")
 		fmt.Println("  collection info <name>     Get collection information")
+		fmt.Println("  collection rename <name> <new_name>                      Rename a collection")
+		fmt.Println("  collection clone <name> <target_name>                    Clone a collection (including HNSW graph)")
+		fmt.Println("  collection copy <name> <target_db> [target_name]         Copy a collection to another database")
+		fmt.Println("  collection create-sharded <name> <metric> <shard1> [shard2] ...  Create a collection spread over shards")
+		fmt.Println("  collection reshard <name> <shard1> [shard2] ...          Move a sharded collection to new shards")
+		fmt.Println("  collection export <name> <file> [options]               Export a collection to JSONL or Parquet")
 		fmt.Println()
 		fmt.Println("  vector insert <collection> <vector> [metadata]          Insert vectors (ID auto-generated)")
 		fmt.Println("  vector search <collection> <vector> [top-k] [ef-search] Search vectors")
 		fmt.Println("  vector delete <collection> <id1> [id2] ...              Delete vectors")
 		fmt.Println()
+		fmt.Println("  import <collection> <file> [options]                    Bulk import vectors from JSONL, Parquet, .npy, .fvecs or .bvecs")
+		fmt.Println()
 		fmt.Println("  text insert <collection> [model] <text> [metadata]      Insert text with embedding (ID auto-generated)")
 		fmt.Println("  text search <collection> [model] <text> [top-k] [ef-search] Search text with embedding")
 		fmt.Println("  text models                                               List available embedding models")
 		fmt.Println()
+		fmt.Println("  backup create              Save a snapshot into the backup directory")
+		fmt.Println("  backup list                List backups, newest first")
+		fmt.Println("  backup restore <name>      Replace all data on the server with a backup")
+		fmt.Println("  backup delete <name>       Delete a backup")
+		fmt.Println()
+		fmt.Println("  replicaof <host:port> [primary_password]                Follow a primary as a read-only replica")
+		fmt.Println("  replicaof no one                                         Stop replicating and accept writes again")
+		fmt.Println()
+		fmt.Println("  cluster status                                           Show the node and the members of its cluster")
+		fmt.Println("  cluster add <node_id> <raft_address> <grpc_address>      Add a node to the cluster")
+		fmt.Println("  cluster remove <node_id>                                 Remove a node from the cluster")
+		fmt.Println()
 		fmt.Println("Type 'help <command>' for detailed usage information.")
 	} else {
 		cmdName := strings.ToLower(args[0])
@@ -89,17 +115,55 @@ func (c *CLI) helpCommand(args []string) error {
 				fmt.Println("    Optional params: <m> <ef_construction>")
 				fmt.Println("  drop <name>                      Drop a collection")
 				fmt.Println("  info <name>                      Get collection information")
+				fmt.Println("  rename <name> <new_name>         Rename a collection")
+				fmt.Println("  clone <name> <target_name>       Clone a collection within the current database")
+				fmt.Println("  copy <name> <target_db> [target_name]  Copy a collection to another database")
+				fmt.Println("  create-sharded <name> <metric> <shard1> [shard2] ...  Create a collection spread over shards")
+				fmt.Println("    Shards: gRPC addresses host:port, vectors are hash-partitioned by ID")
+				fmt.Println("  reshard <name> <shard1> [shard2] ...  Move a sharded collection to new shards")
+				fmt.Println("  export <name> <file> [options]   Export the vectors (id, elements, metadata) to a local file")
+				fmt.Println("    Formats: .jsonl or .parquet, read back by import")
+				fmt.Println("    Options: --format <jsonl|parquet>, --partition <name>, --graph <file> (HNSW graph as JSON lines),")
+				fmt.Println("             --batch-size <n>, --no-progress")
 			case "vector":
 				fmt.Println("\nSub-commands:")
 				fmt.Println("  insert <collection> <vector> [metadata]          Insert vectors (ID auto-generated)")
 				fmt.Println("    Vector format: JSON array, e.g., [1.0, 2.0, 3.0]")
 				fmt.Println("  search <collection> <vector> [top-k] [ef-search] Search vectors")
 				fmt.Println("  delete <collection> <id1> [id2] ...              Delete vectors")
+			case "import":
+				fmt.Println("\nFormats (detected by extension):")
+				fmt.Println("  .jsonl           One object per line: {\"elements\": [...], \"metadata\": {...}}")
+				fmt.Println("  .parquet         A list column of elements, other columns become metadata")
+				fmt.Println("  .npy             A two-dimensional array, metadata from a --metadata sidecar")
+				fmt.Println("  .fvecs, .bvecs   ANN benchmark vectors, metadata from a --metadata sidecar")
+				fmt.Println("\nOptions:")
+				fmt.Println("  --format <name>       jsonl, parquet, npy, fvecs or bvecs instead of the extension")
+				fmt.Println("  --metadata <file>     JSON lines sidecar, one metadata object per vector")
+				fmt.Println("  --column <name>       Parquet column of the elements")
+				fmt.Println("  --partition <name>    Partition to insert into")
+				fmt.Println("  --id-field <name>     Keep the IDs of the file, or row numbers, in this metadata field")
+				fmt.Println("  --batch-size <n>      Vectors per insert (default 500)")
+				fmt.Println("  --workers <n>         Concurrent inserts (default 4)")
+				fmt.Println("  --checkpoint <file>   Checkpoint to resume from (default <file>.checkpoint)")
+				fmt.Println("  --restart             Ignore the checkpoint and start over")
+				fmt.Println("  --no-progress         Don't draw the progress bar")
 			case "text":
 				fmt.Println("\nSub-commands:")
 				fmt.Println("  insert <collection> [model] <text> [metadata]      Insert text with embedding (ID auto-generated)")
 				fmt.Println("  search <collection> [model] <text> [top-k] [ef-search] Search text with embedding")
 				fmt.Println("  models                                               List available embedding models")
+			case "backup":
+				fmt.Println("\nSub-commands:")
+				fmt.Println("  create             Save a snapshot into the backup directory")
+				fmt.Println("  list               List backups, newest first")
+				fmt.Println("  restore <name>     Replace all data on the server with a backup")
+				fmt.Println("  delete <name>      Delete a backup")
+			case "cluster":
+				fmt.Println("\nSub-commands:")
+				fmt.Println("  status                                        Show the node and the members of its cluster")
+				fmt.Println("  add <node_id> <raft_address> <grpc_address>   Add a node to the cluster")
+				fmt.Println("  remove <node_id>                              Remove a node from the cluster")
 			}
 		} else {
 			return fmt.Errorf("unknown command: %s", cmdName)
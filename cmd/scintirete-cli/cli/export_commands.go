@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/vectorfile"
+	"google.golang.org/grpc"
+)
+
+// exportOptions configures an export
+type exportOptions struct {
+	format    vectorfile.Format
+	partition string
+	graph     string // JSON lines file the HNSW graph is written to, none if empty
+	batchSize int
+	progress  bool
+}
+
+// graphHeaderLine starts the graph of a partition in a graph file
+type graphHeaderLine struct {
+	Partition  string `json:"partition"`
+	EntryPoint uint64 `json:"entry_point"`
+	MaxLayer   int32  `json:"max_layer"`
+	NodeCount  int64  `json:"node_count"`
+}
+
+// graphNodeLine is a node of the graph of the partition of the last header
+type graphNodeLine struct {
+	ID      uint64     `json:"id"`
+	Deleted bool       `json:"deleted,omitempty"`
+	Layers  [][]uint64 `json:"layers"` // Neighbors on each layer, from layer 0 up
+}
+
+// parseExportArgs parses the arguments of collection export: the collection, the file and options
+func parseExportArgs(args []string) (string, string, exportOptions, error) {
+	options := exportOptions{progress: true}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if name == "no-progress" {
+			options.progress = false
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", "", options, fmt.Errorf("option --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		var err error
+		switch name {
+		case "format":
+			options.format, err = vectorfile.ParseFormat(value)
+		case "partition":
+			options.partition = value
+		case "graph":
+			options.graph = value
+		case "batch-size":
+			options.batchSize, err = strconv.Atoi(value)
+			if err == nil && options.batchSize <= 0 {
+				err = fmt.Errorf("batch size must be positive, got %d", options.batchSize)
+			}
+		default:
+			err = fmt.Errorf("unknown option --%s", name)
+		}
+		if err != nil {
+			return "", "", options, err
+		}
+	}
+
+	if len(positional) != 2 {
+		return "", "", options, fmt.Errorf("usage: collection export <name> <file> [options]")
+	}
+	if options.format == "" {
+		format, err := vectorfile.DetectFormat(positional[1])
+		if err != nil {
+			return "", "", options, err
+		}
+		options.format = format
+	}
+	if options.format != vectorfile.FormatJSONL && options.format != vectorfile.FormatParquet {
+		return "", "", options, fmt.Errorf("collections are exported as jsonl or parquet, not %s", options.format)
+	}
+	return positional[0], positional[1], options, nil
+}
+
+// exportCollectionCommand writes the vectors of a collection of the current database
+// to a local JSON lines or Parquet file, which import reads back, and optionally its
+// HNSW graph to a JSON lines file. Incomplete files are removed if the export fails.
+func (c *CLI) exportCollectionCommand(args []string) error {
+	if currentDatabase == "" {
+		return fmt.Errorf("no database selected. Use 'use <database>' first")
+	}
+	collection, path, options, err := parseExportArgs(args)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	exported, nodes, err := c.runExport(collection, path, options)
+	if err != nil {
+		os.Remove(path)
+		if options.graph != "" {
+			os.Remove(options.graph)
+		}
+		return fmt.Errorf("failed to export collection: %v", err)
+	}
+
+	fmt.Printf("Exported %d vectors of '%s.%s' to %s in %.1fs.\n", exported, currentDatabase, collection, path, time.Since(start).Seconds())
+	if options.graph != "" {
+		fmt.Printf("Exported %d HNSW graph nodes to %s.\n", nodes, options.graph)
+	}
+	return nil
+}
+
+// runExport streams the export of a collection into the files of options. It returns
+// the vectors and graph nodes written.
+func (c *CLI) runExport(collection, path string, options exportOptions) (int64, int64, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.client.ExportCollection(ctx, &pb.ExportCollectionRequest{
+		Auth:           &pb.AuthInfo{Password: c.password},
+		DbName:         currentDatabase,
+		CollectionName: collection,
+		PartitionName:  options.partition,
+		BatchSize:      int32(options.batchSize),
+		IncludeGraph:   options.graph != "",
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The collection info comes first, errors such as a missing collection with it
+	first, err := stream.Recv()
+	if err != nil {
+		return 0, 0, err
+	}
+	if first.Info == nil {
+		return 0, 0, errors.New("export stream didn't start with the collection info")
+	}
+	total := first.Info.VectorCount
+	if options.partition != "" {
+		total = -1
+		for _, partition := range first.Info.Partitions {
+			if partition.Name == options.partition {
+				total = partition.VectorCount
+			}
+		}
+	}
+
+	writer, err := vectorfile.Create(path, options.format)
+	if err != nil {
+		return 0, 0, err
+	}
+	var graph *os.File
+	var graphBuffer *bufio.Writer
+	var graphOut *json.Encoder
+	if options.graph != "" {
+		if graph, err = os.Create(options.graph); err != nil {
+			writer.Close()
+			return 0, 0, err
+		}
+		graphBuffer = bufio.NewWriterSize(graph, 1<<20)
+		graphOut = json.NewEncoder(graphBuffer)
+	}
+
+	exported, nodes, err := receiveExport(stream, writer, graphOut, newProgressBar(total, 0, options.progress))
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if graph != nil {
+		if flushErr := graphBuffer.Flush(); err == nil {
+			err = flushErr
+		}
+		if closeErr := graph.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return exported, nodes, err
+}
+
+// receiveExport writes the vectors and graph nodes of an export stream until it ends
+func receiveExport(stream grpc.ServerStreamingClient[pb.ExportCollectionResponse], writer vectorfile.Writer, graph *json.Encoder, progress *progressBar) (int64, int64, error) {
+	var exported, nodes int64
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			progress.finish(exported)
+			return exported, nodes, nil
+		}
+		if err != nil {
+			return exported, nodes, err
+		}
+
+		for _, vector := range resp.Vectors {
+			record := vectorfile.Record{
+				ID:       vector.GetId(),
+				Elements: vector.Elements,
+				ExpireAt: vector.GetExpireAt(),
+			}
+			if vector.Metadata != nil {
+				record.Metadata = vector.Metadata.AsMap()
+			}
+			if err := writer.Write(record); err != nil {
+				return exported, nodes, err
+			}
+			exported++
+		}
+		progress.update(exported)
+
+		if graph == nil {
+			continue
+		}
+		if header := resp.Graph; header != nil {
+			if err := graph.Encode(graphHeaderLine{
+				Partition:  header.PartitionName,
+				EntryPoint: header.EntryPoint,
+				MaxLayer:   header.MaxLayer,
+				NodeCount:  header.NodeCount,
+			}); err != nil {
+				return exported, nodes, err
+			}
+		}
+		for _, node := range resp.GraphNodes {
+			layers := make([][]uint64, len(node.Layers))
+			for i, layer := range node.Layers {
+				layers[i] = layer.Ids
+				if layers[i] == nil {
+					layers[i] = []uint64{}
+				}
+			}
+			if err := graph.Encode(graphNodeLine{ID: node.Id, Deleted: node.Deleted, Layers: layers}); err != nil {
+				return exported, nodes, err
+			}
+			nodes++
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package cli provides unit tests for the collection export command.
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/vectorfile"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// mockExportStream replays export messages, then ends with err or io.EOF
+type mockExportStream struct {
+	grpc.ClientStream
+	messages []*pb.ExportCollectionResponse
+	err      error
+}
+
+func (s *mockExportStream) Recv() (*pb.ExportCollectionResponse, error) {
+	if len(s.messages) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	message := s.messages[0]
+	s.messages = s.messages[1:]
+	return message, nil
+}
+
+func exportTestMessages() []*pb.ExportCollectionResponse {
+	id1, id2 := uint64(1), uint64(2)
+	expireAt := int64(1700000000)
+	metadata, _ := structpb.NewStruct(map[string]interface{}{"tag": "a"})
+	return []*pb.ExportCollectionResponse{
+		{Info: &pb.CollectionInfo{Name: "docs", VectorCount: 2}},
+		{Vectors: []*pb.Vector{
+			{Id: &id1, Elements: []float32{1, 2}, Metadata: metadata},
+			{Id: &id2, Elements: []float32{3, 4}, ExpireAt: &expireAt},
+		}},
+		{Graph: &pb.HnswGraphHeader{PartitionName: "_default", EntryPoint: 1, NodeCount: 2}},
+		{GraphNodes: []*pb.HnswGraphNode{
+			{Id: 1, Layers: []*pb.HnswNeighbors{{Ids: []uint64{2}}}},
+			{Id: 2, Layers: []*pb.HnswNeighbors{{Ids: []uint64{1}}}},
+		}},
+	}
+}
+
+func TestParseExportArgs(t *testing.T) {
+	collection, path, options, err := parseExportArgs([]string{"docs", "out.parquet", "--partition=p1", "--graph", "graph.jsonl", "--no-progress"})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if collection != "docs" || path != "out.parquet" || options.format != vectorfile.FormatParquet {
+		t.Errorf("Unexpected arguments '%s', '%s', %+v", collection, path, options)
+	}
+	if options.partition != "p1" || options.graph != "graph.jsonl" || options.progress {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+
+	for _, args := range [][]string{
+		{"docs"},
+		{"docs", "out.npy"},
+		{"docs", "out", "--format", "fvecs"},
+		{"docs", "out.jsonl", "--batch-size", "-1"},
+	} {
+		if _, _, _, err := parseExportArgs(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
+
+func TestExportCommand_WritesVectorsAndGraph(t *testing.T) {
+	SetCurrentDatabase("testdb")
+	defer SetCurrentDatabase("")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docs.jsonl")
+	graphPath := filepath.Join(dir, "docs.graph.jsonl")
+	cli := &CLI{password: "test-password", client: &MockScintireteServiceClient{
+		ExportCollectionFunc: func(ctx context.Context, req *pb.ExportCollectionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.ExportCollectionResponse], error) {
+			if req.DbName != "testdb" || req.CollectionName != "docs" || !req.IncludeGraph {
+				t.Errorf("Unexpected request %+v", req)
+			}
+			return &mockExportStream{messages: exportTestMessages()}, nil
+		},
+	}}
+	if err := cli.exportCollectionCommand([]string{"docs", path, "--graph", graphPath, "--no-progress"}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	// The export imports back
+	reader, err := vectorfile.Open(path, vectorfile.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	var records []vectorfile.Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0].ID != 1 || records[0].Metadata["tag"] != "a" || records[1].ExpireAt != 1700000000 {
+		t.Errorf("Unexpected records exported: %+v", records)
+	}
+
+	graph, err := os.ReadFile(graphPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(graph)), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"node_count":2`) || lines[1] != `{"id":1,"layers":[[2]]}` {
+		t.Errorf("Unexpected graph file:\n%s", graph)
+	}
+}
+
+func TestExportCommand_RemovesIncompleteFiles(t *testing.T) {
+	SetCurrentDatabase("testdb")
+	defer SetCurrentDatabase("")
+
+	path := filepath.Join(t.TempDir(), "docs.parquet")
+	cli := &CLI{password: "test-password", client: &MockScintireteServiceClient{
+		ExportCollectionFunc: func(ctx context.Context, req *pb.ExportCollectionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.ExportCollectionResponse], error) {
+			return &mockExportStream{messages: exportTestMessages()[:2], err: errors.New("connection lost")}, nil
+		},
+	}}
+	err := cli.exportCollectionCommand([]string{"docs", path, "--no-progress"})
+	if err == nil || !strings.Contains(err.Error(), "connection lost") {
+		t.Fatalf("Expected the export to fail, got: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the incomplete export removed")
+	}
+}
@@ -0,0 +1,456 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/vectorfile"
+)
+
+const (
+	defaultImportBatchSize = 500
+	defaultImportWorkers   = 4
+	importBatchTimeout     = 2 * time.Minute
+	progressInterval       = 200 * time.Millisecond
+)
+
+// importOptions configures an import
+type importOptions struct {
+	format     vectorfile.Format
+	metadata   string // JSON lines sidecar of npy, fvecs and bvecs files
+	column     string // Parquet column of the elements
+	partition  string
+	idField    string // Metadata field the IDs of the file are kept in, none if empty
+	batchSize  int
+	workers    int
+	checkpoint string
+	restart    bool // Ignore an existing checkpoint
+	progress   bool
+}
+
+// parseImportArgs parses the arguments of import: the collection, the file and options
+func parseImportArgs(args []string) (string, string, importOptions, error) {
+	options := importOptions{batchSize: defaultImportBatchSize, workers: defaultImportWorkers, progress: true}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		switch name {
+		case "restart":
+			options.restart = true
+			continue
+		case "no-progress":
+			options.progress = false
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", "", options, fmt.Errorf("option --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		var err error
+		switch name {
+		case "format":
+			options.format, err = vectorfile.ParseFormat(value)
+		case "metadata":
+			options.metadata = value
+		case "column":
+			options.column = value
+		case "partition":
+			options.partition = value
+		case "id-field":
+			options.idField = value
+		case "checkpoint":
+			options.checkpoint = value
+		case "batch-size":
+			options.batchSize, err = strconv.Atoi(value)
+			if err == nil && options.batchSize <= 0 {
+				err = fmt.Errorf("batch size must be positive, got %d", options.batchSize)
+			}
+		case "workers":
+			options.workers, err = strconv.Atoi(value)
+			if err == nil && options.workers <= 0 {
+				err = fmt.Errorf("workers must be positive, got %d", options.workers)
+			}
+		default:
+			err = fmt.Errorf("unknown option --%s", name)
+		}
+		if err != nil {
+			return "", "", options, err
+		}
+	}
+
+	if len(positional) != 2 {
+		return "", "", options, fmt.Errorf("usage: import <collection> <file> [options]")
+	}
+	if options.checkpoint == "" {
+		options.checkpoint = positional[1] + ".checkpoint"
+	}
+	return positional[0], positional[1], options, nil
+}
+
+// importCommand bulk inserts the vectors of a local file into a collection of the
+// current database, in batches inserted concurrently. Progress is checkpointed after
+// every batch, so an interrupted import resumes where it stopped when run again.
+func (c *CLI) importCommand(args []string) error {
+	if currentDatabase == "" {
+		return fmt.Errorf("no database selected. Use 'use <database>' first")
+	}
+	collection, path, options, err := parseImportArgs(args)
+	if err != nil {
+		return err
+	}
+
+	reader, err := vectorfile.Open(path, vectorfile.Options{
+		Format:       options.format,
+		MetadataPath: options.metadata,
+		Column:       options.column,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	checkpoint, err := loadImportCheckpoint(options.checkpoint, path, currentDatabase, collection, options.batchSize, options.restart)
+	if err != nil {
+		return err
+	}
+	if checkpoint.Inserted > 0 {
+		fmt.Printf("Resuming import of %s, %d vectors already inserted.\n", path, checkpoint.Inserted)
+	} else {
+		fmt.Printf("Importing %s into '%s.%s'...\n", path, currentDatabase, collection)
+	}
+
+	start := time.Now()
+	before := checkpoint.Inserted
+	err = c.runImport(reader, collection, options, checkpoint)
+	if err != nil {
+		return fmt.Errorf("import stopped after %d vectors: %v. Run the same command again to resume", checkpoint.Inserted, err)
+	}
+	if err := os.Remove(options.checkpoint); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("import finished but its checkpoint could not be removed: %v", err)
+	}
+
+	elapsed := time.Since(start)
+	fmt.Printf("Imported %d vectors in %.1fs (%.0f vectors/s).\n", checkpoint.Inserted-before, elapsed.Seconds(),
+		float64(checkpoint.Inserted-before)/max(elapsed.Seconds(), 0.001))
+	return nil
+}
+
+// importBatch is a batch of vectors and the position of its first record in the file
+type importBatch struct {
+	start   int64
+	vectors []*pb.Vector
+}
+
+type importResult struct {
+	batch importBatch
+	err   error
+}
+
+// runImport reads batches of records from reader, skipping the ones checkpoint has
+// inserted, and inserts them with concurrent workers
+func (c *CLI) runImport(reader vectorfile.Reader, collection string, options importOptions, checkpoint *importCheckpoint) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan importBatch, options.workers)
+	results := make(chan importResult, options.workers)
+	readErr := make(chan error, 1)
+	inserted := checkpoint.insertedBefore()
+	go func() {
+		defer close(batches)
+		readErr <- readImportBatches(ctx, reader, options, inserted, batches)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < options.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				results <- importResult{batch: batch, err: c.insertBatch(ctx, collection, options.partition, batch)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	progress := newProgressBar(reader.Total(), checkpoint.Inserted, options.progress)
+	var firstErr error
+	for result := range results {
+		if result.err == nil {
+			checkpoint.complete(result.batch.start, len(result.batch.vectors))
+			result.err = checkpoint.save(options.checkpoint)
+		}
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+				cancel()
+			}
+			continue
+		}
+		progress.update(checkpoint.Inserted)
+	}
+	progress.finish(checkpoint.Inserted)
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return <-readErr
+}
+
+// readImportBatches sends the batches of records not yet inserted
+func readImportBatches(ctx context.Context, reader vectorfile.Reader, options importOptions, inserted func(int64) bool, batches chan<- importBatch) error {
+	batch := importBatch{start: 0}
+	send := func() error {
+		if len(batch.vectors) == 0 {
+			return nil
+		}
+		select {
+		case batches <- batch:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var position int64
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return send()
+		}
+		if err != nil {
+			return err
+		}
+
+		// Batches keep their boundaries across runs, so inserted ones are skipped whole
+		if !inserted(batch.start) {
+			vector, err := importVector(record, options.idField)
+			if err != nil {
+				return fmt.Errorf("record %d: %v", position, err)
+			}
+			batch.vectors = append(batch.vectors, vector)
+		}
+		position++
+		if position-batch.start == int64(options.batchSize) {
+			if err := send(); err != nil {
+				return err
+			}
+			batch = importBatch{start: position}
+		}
+	}
+}
+
+// importVector converts a record to the vector inserted
+func importVector(record vectorfile.Record, idField string) (*pb.Vector, error) {
+	vector := &pb.Vector{Elements: record.Elements}
+
+	metadata := record.Metadata
+	if idField != "" {
+		if metadata == nil {
+			metadata = make(map[string]interface{}, 1)
+		}
+		metadata[idField] = record.ID
+	}
+	if len(metadata) > 0 {
+		metadataStruct, err := ConvertToStruct(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert metadata: %v", err)
+		}
+		vector.Metadata = metadataStruct
+	}
+
+	if record.TTLSeconds > 0 {
+		ttl := record.TTLSeconds
+		vector.TtlSeconds = &ttl
+	}
+	if record.ExpireAt > 0 {
+		expireAt := record.ExpireAt
+		vector.ExpireAt = &expireAt
+	}
+	return vector, nil
+}
+
+// insertBatch inserts a batch of vectors
+func (c *CLI) insertBatch(ctx context.Context, collection, partition string, batch importBatch) error {
+	ctx, cancel := context.WithTimeout(ctx, importBatchTimeout)
+	defer cancel()
+
+	_, err := c.client.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           &pb.AuthInfo{Password: c.password},
+		DbName:         currentDatabase,
+		CollectionName: collection,
+		Vectors:        batch.vectors,
+		PartitionName:  partition,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert the batch at record %d: %v", batch.start, err)
+	}
+	return nil
+}
+
+// importCheckpoint records the batches of an import that were inserted
+type importCheckpoint struct {
+	File       string  `json:"file"`
+	Size       int64   `json:"size"`
+	Database   string  `json:"database"`
+	Collection string  `json:"collection"`
+	BatchSize  int     `json:"batch_size"`
+	Done       int64   `json:"done"`              // Records before it are all inserted
+	Batches    []int64 `json:"batches,omitempty"` // Batches after Done inserted, by their first record
+	Inserted   int64   `json:"inserted"`
+}
+
+// loadImportCheckpoint returns the checkpoint of an import of file, a new one if there
+// is none at path or restart is set
+func loadImportCheckpoint(path, file, database, collection string, batchSize int, restart bool) (*importCheckpoint, error) {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &importCheckpoint{
+		File:       absolute,
+		Size:       stat.Size(),
+		Database:   database,
+		Collection: collection,
+		BatchSize:  batchSize,
+	}
+	if restart {
+		return checkpoint, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved importCheckpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	if saved.File != checkpoint.File || saved.Size != checkpoint.Size || saved.Database != database ||
+		saved.Collection != collection || saved.BatchSize != batchSize {
+		return nil, fmt.Errorf("checkpoint %s belongs to an import of %s (%d bytes) into '%s.%s' with batch size %d, pass --restart to start over",
+			path, saved.File, saved.Size, saved.Database, saved.Collection, saved.BatchSize)
+	}
+	return &saved, nil
+}
+
+// insertedBefore returns whether the batch starting at a record was inserted before the
+// import resumed, by a copy of the checkpoint that later batches don't change
+func (c *importCheckpoint) insertedBefore() func(start int64) bool {
+	done, batches := c.Done, slices.Clone(c.Batches)
+	return func(start int64) bool {
+		_, found := slices.BinarySearch(batches, start)
+		return start < done || found
+	}
+}
+
+// complete records the insert of count records starting at record start
+func (c *importCheckpoint) complete(start int64, count int) {
+	c.Inserted += int64(count)
+	if start != c.Done {
+		i, _ := slices.BinarySearch(c.Batches, start)
+		c.Batches = slices.Insert(c.Batches, i, start)
+		return
+	}
+
+	// Batches completed out of order follow on
+	c.Done += int64(c.BatchSize)
+	for len(c.Batches) > 0 && c.Batches[0] == c.Done {
+		c.Batches = c.Batches[1:]
+		c.Done += int64(c.BatchSize)
+	}
+}
+
+// save writes the checkpoint to path, replacing the previous one atomically
+func (c *importCheckpoint) save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	return nil
+}
+
+// progressBar draws the progress of an import or export on one terminal line
+type progressBar struct {
+	total   int64 // -1 if unknown
+	initial int64
+	enabled bool
+	start   time.Time
+	drawn   time.Time
+}
+
+func newProgressBar(total, initial int64, enabled bool) *progressBar {
+	return &progressBar{total: total, initial: initial, enabled: enabled, start: time.Now()}
+}
+
+// update redraws the bar, at most every progressInterval
+func (p *progressBar) update(done int64) {
+	if !p.enabled || time.Since(p.drawn) < progressInterval {
+		return
+	}
+	p.drawn = time.Now()
+	fmt.Print("\r" + p.line(done))
+}
+
+// finish draws the final state and ends the line
+func (p *progressBar) finish(done int64) {
+	if !p.enabled {
+		return
+	}
+	fmt.Println("\r" + p.line(done))
+}
+
+func (p *progressBar) line(done int64) string {
+	elapsed := time.Since(p.start).Seconds()
+	rate := float64(done-p.initial) / max(elapsed, 0.001)
+	if p.total <= 0 {
+		return fmt.Sprintf("%d vectors  %.0f vectors/s", done, rate)
+	}
+
+	const width = 30
+	fraction := min(float64(done)/float64(p.total), 1)
+	filled := int(fraction * width)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	eta := "-"
+	if rate > 0 {
+		eta = (time.Duration(float64(p.total-done)/rate) * time.Second).String()
+	}
+	return fmt.Sprintf("[%s] %5.1f%%  %d/%d vectors  %.0f vectors/s  ETA %-8s", bar, 100*fraction, done, p.total, rate, eta)
+}
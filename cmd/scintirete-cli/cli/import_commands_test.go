@@ -0,0 +1,140 @@
+// Package cli provides unit tests for the import command.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc"
+)
+
+func TestParseImportArgs(t *testing.T) {
+	collection, path, options, err := parseImportArgs([]string{"docs", "vectors.npy", "--metadata", "meta.jsonl", "--batch-size=100", "--workers", "2", "--restart", "--no-progress"})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if collection != "docs" || path != "vectors.npy" {
+		t.Errorf("Expected collection 'docs' and file 'vectors.npy', got '%s' and '%s'", collection, path)
+	}
+	if options.metadata != "meta.jsonl" || options.batchSize != 100 || options.workers != 2 || !options.restart || options.progress {
+		t.Errorf("Unexpected options: %+v", options)
+	}
+	if options.checkpoint != "vectors.npy.checkpoint" {
+		t.Errorf("Expected the checkpoint next to the file, got '%s'", options.checkpoint)
+	}
+
+	for _, args := range [][]string{
+		{"docs"},
+		{"docs", "vectors.jsonl", "--batch-size", "0"},
+		{"docs", "vectors.jsonl", "--workers"},
+		{"docs", "vectors.jsonl", "--format", "csv"},
+		{"docs", "vectors.jsonl", "--unknown", "1"},
+	} {
+		if _, _, _, err := parseImportArgs(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
+
+func TestImportCommand_ResumesFromCheckpoint(t *testing.T) {
+	SetCurrentDatabase("testdb")
+	defer SetCurrentDatabase("")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vectors.jsonl")
+	var lines []string
+	for i := 0; i < 5; i++ {
+		lines = append(lines, fmt.Sprintf(`{"elements": [%d, 1], "metadata": {"n": %d}}`, i, i))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	checkpointPath := path + ".checkpoint"
+	args := []string{"docs", path, "--batch-size", "2", "--workers", "1", "--no-progress", "--id-field", "row"}
+
+	// Batches fail from the second on
+	var mu sync.Mutex
+	calls := 0
+	cli := &CLI{password: "test-password", client: &MockScintireteServiceClient{
+		InsertVectorsFunc: func(ctx context.Context, req *pb.InsertVectorsRequest, opts ...grpc.CallOption) (*pb.InsertVectorsResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			if calls >= 2 {
+				return nil, errors.New("connection lost")
+			}
+			return &pb.InsertVectorsResponse{InsertedCount: int32(len(req.Vectors))}, nil
+		},
+	}}
+	if err := cli.importCommand(args); err == nil {
+		t.Fatal("Expected the import to stop at the failed batch")
+	}
+
+	data, err := os.ReadFile(checkpointPath)
+	if err != nil {
+		t.Fatalf("Expected a checkpoint: %v", err)
+	}
+	var checkpoint importCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Done != 2 || checkpoint.Inserted != 2 || checkpoint.Collection != "docs" {
+		t.Errorf("Expected the first batch checkpointed, got %+v", checkpoint)
+	}
+
+	// Run again, the first batch isn't inserted twice
+	var rows []float64
+	cli.client = &MockScintireteServiceClient{
+		InsertVectorsFunc: func(ctx context.Context, req *pb.InsertVectorsRequest, opts ...grpc.CallOption) (*pb.InsertVectorsResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if req.DbName != "testdb" || req.CollectionName != "docs" || req.Auth.GetPassword() != "test-password" {
+				t.Errorf("Unexpected request target %s.%s", req.DbName, req.CollectionName)
+			}
+			for _, vector := range req.Vectors {
+				metadata := vector.Metadata.AsMap()
+				if metadata["n"] != metadata["row"] {
+					t.Errorf("Expected the row number kept next to the metadata, got %v", metadata)
+				}
+				rows = append(rows, metadata["row"].(float64))
+			}
+			return &pb.InsertVectorsResponse{InsertedCount: int32(len(req.Vectors))}, nil
+		},
+	}
+	if err := cli.importCommand(args); err != nil {
+		t.Fatalf("Expected the import to resume, got: %v", err)
+	}
+	if fmt.Sprint(rows) != "[2 3 4]" {
+		t.Errorf("Expected rows 2 to 4 inserted on resume, got %v", rows)
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint removed after the import finished")
+	}
+}
+
+func TestImportCheckpoint_CompletesOutOfOrder(t *testing.T) {
+	checkpoint := &importCheckpoint{BatchSize: 10}
+	checkpoint.complete(20, 10)
+	checkpoint.complete(40, 5)
+	if checkpoint.Done != 0 || fmt.Sprint(checkpoint.Batches) != "[20 40]" {
+		t.Errorf("Expected batches after a gap kept apart, got %+v", checkpoint)
+	}
+
+	inserted := checkpoint.insertedBefore()
+	checkpoint.complete(0, 10)
+	checkpoint.complete(10, 10)
+	if checkpoint.Done != 30 || fmt.Sprint(checkpoint.Batches) != "[40]" || checkpoint.Inserted != 35 {
+		t.Errorf("Expected the gap closed, got %+v", checkpoint)
+	}
+	if inserted(0) || !inserted(20) || !inserted(40) || inserted(30) {
+		t.Errorf("Expected batches inserted before resuming to be those of the copy")
+	}
+}
@@ -14,6 +14,8 @@ import (
 type MockScintireteServiceClient struct {
 	pb.ScintireteServiceClient
 	ListEmbeddingModelsFunc func(ctx context.Context, req *pb.ListEmbeddingModelsRequest, opts ...grpc.CallOption) (*pb.ListEmbeddingModelsResponse, error)
+	InsertVectorsFunc       func(ctx context.Context, req *pb.InsertVectorsRequest, opts ...grpc.CallOption) (*pb.InsertVectorsResponse, error)
+	ExportCollectionFunc    func(ctx context.Context, req *pb.ExportCollectionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.ExportCollectionResponse], error)
 }
 
 func (m *MockScintireteServiceClient) ListEmbeddingModels(ctx context.Context, req *pb.ListEmbeddingModelsRequest, opts ...grpc.CallOption) (*pb.ListEmbeddingModelsResponse, error) {
@@ -23,6 +25,20 @@ func (m *MockScintireteServiceClient) ListEmbeddingModels(ctx context.Context, r
 	return nil, errors.New("not implemented")
 }
 
+func (m *MockScintireteServiceClient) InsertVectors(ctx context.Context, req *pb.InsertVectorsRequest, opts ...grpc.CallOption) (*pb.InsertVectorsResponse, error) {
+	if m.InsertVectorsFunc != nil {
+		return m.InsertVectorsFunc(ctx, req, opts...)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockScintireteServiceClient) ExportCollection(ctx context.Context, req *pb.ExportCollectionRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.ExportCollectionResponse], error) {
+	if m.ExportCollectionFunc != nil {
+		return m.ExportCollectionFunc(ctx, req, opts...)
+	}
+	return nil, errors.New("not implemented")
+}
+
 func TestTextCommand_Models(t *testing.T) {
 	tests := []struct {
 		name           string
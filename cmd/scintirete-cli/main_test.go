@@ -41,6 +41,16 @@ func TestCLI_SaveCommand(t *testing.T) {
 	if bgsaveCmd.Description == "" {
 		t.Error("BgSave command should have a description")
 	}
+
+	// Test that backup command is registered
+	backupCmd, exists := commands["backup"]
+	if !exists {
+		t.Error("Backup command should be registered")
+	}
+
+	if backupCmd.Name != "backup" {
+		t.Errorf("Expected command name 'backup', got '%s'", backupCmd.Name)
+	}
 }
 
 func TestCLI_BgSaveCommand(t *testing.T) {
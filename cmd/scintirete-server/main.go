@@ -21,9 +21,11 @@ import (
 	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
 	"github.com/scintirete/scintirete/internal/config"
 	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
 	"github.com/scintirete/scintirete/internal/server"
 	grpcserver "github.com/scintirete/scintirete/internal/server/grpc"
 	httpserver "github.com/scintirete/scintirete/internal/server/http"
+	respserver "github.com/scintirete/scintirete/internal/server/resp"
 )
 
 var (
@@ -32,6 +34,8 @@ var (
 	pprofEnabled = flag.Bool("pprof", false, "Enable pprof profiling server")
 	pprofPort    = flag.Int("pprof-port", 6060, "Port for pprof server")
 	traceFile    = flag.String("trace", "", "Enable tracing and write to file")
+	aofRepair    = flag.Bool("aof-repair", false, "Skip corrupt AOF records during recovery instead of refusing to start")
+	restoreTo    = flag.String("restore-to", "", "Restore the state at an RFC 3339 time or <increment>:<commands> position from archived AOF files")
 	help         = flag.Bool("help", false, "Show help message")
 )
 
@@ -88,6 +92,28 @@ func main() {
 
 	// Note: Configuration validation can be added here if needed
 
+	// Parse the point in time to restore
+	var restoreTarget *aof.RestoreTarget
+	if *restoreTo != "" {
+		target, err := aof.ParseRestoreTarget(*restoreTo)
+		if err != nil {
+			log.Fatalf("Invalid --restore-to: %v", err)
+		}
+		restoreTarget = &target
+		// The state of a cluster node comes from its Raft log
+		if cfg.Cluster.Enabled {
+			log.Fatalf("--restore-to cannot be used in cluster mode")
+		}
+	}
+
+	// Load the keys the data files are encrypted with
+	keys, err := cfg.ToKeyProvider()
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	loadPolicy := cfg.ToLoadPolicy()
+	loadPolicy.Keys = keys
+
 	// Create server configuration
 	serverConfig := server.ServerConfig{
 		Passwords: cfg.Server.Passwords,
@@ -95,14 +121,31 @@ func main() {
 			DataDir:         cfg.Persistence.DataDir,
 			RDBFilename:     cfg.Persistence.RDBFilename,
 			AOFFilename:     cfg.Persistence.AOFFilename,
+			AOFDirname:      cfg.Persistence.AOFDirname,
+			AOFArchiveDir:   cfg.Persistence.AOFArchiveDir,
 			AOFSyncStrategy: cfg.Persistence.AOFSyncStrategy,
+			RDBCompression:  cfg.Persistence.RDBCompression,
+			BackupDir:       cfg.Backup.Dir,
+			BackupRetention: cfg.ToBackupRetention(),
+			BackupTargets:   cfg.ToBackupTargets(),
+			BackupInterval:  time.Duration(cfg.Backup.IntervalMinutes) * time.Minute,
 			RDBInterval:     time.Duration(cfg.Persistence.RDBIntervalMinutes) * time.Minute,
 			AOFRewriteSize:  int64(cfg.Persistence.AOFRewriteSizeMB) * 1024 * 1024,
+			AOFRepair:       *aofRepair,
+			RestoreTo:       restoreTarget,
+			KeyProvider:     keys,
 		},
-		EmbeddingConfig:  cfg.ToEmbeddingConfig(),
-		EnableMetrics:    cfg.Observability.MetricsEnabled,
-		EnableAuditLog:   cfg.Log.EnableAuditLog,
-		MonitoringConfig: cfg.ToMonitoringConfig(),
+		EmbeddingConfig:   cfg.ToEmbeddingConfig(),
+		EnableMetrics:     cfg.Observability.MetricsEnabled,
+		EnableAuditLog:    cfg.Log.EnableAuditLog,
+		MonitoringConfig:  cfg.ToMonitoringConfig(),
+		MemoryLimit:       cfg.ToMemoryLimit(),
+		LoadPolicy:        loadPolicy,
+		ReplicationConfig: cfg.ToReplicationConfig(),
+		ClusterConfig:     cfg.ToClusterConfig(),
+		ShardingConfig:    cfg.ToShardingConfig(),
+		CDCConfig:         cfg.ToCDCConfig(),
+		WebhookConfig:     cfg.ToWebhookConfig(),
 	}
 
 	// Create gRPC server
@@ -153,15 +196,35 @@ func main() {
 		}
 	}()
 
+	// Start the optional Redis protocol front-end
+	respServer := respserver.NewServer(grpcServer)
+	respAddr := cfg.GetRESPAddress()
+	if respAddr != "" {
+		lis, err := net.Listen("tcp", respAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", respAddr, err)
+		}
+		go func() {
+			log.Printf("Starting RESP server on %s", respAddr)
+			if err := respServer.Serve(lis); err != nil {
+				log.Fatalf("Failed to serve RESP: %v", err)
+			}
+		}()
+	}
+
 	log.Printf("Scintirete server started successfully")
 	log.Printf("gRPC endpoint: %s", grpcAddr)
 	log.Printf("HTTP endpoint: %s", httpAddr)
+	if respAddr != "" {
+		log.Printf("RESP endpoint: %s", respAddr)
+	}
 
 	// Wait for shutdown signal
 	<-shutdown
 	log.Println("Shutting down server...")
 
 	// Graceful shutdown
+	respServer.Close()
 	cancel()
 	if err := grpcServer.Stop(ctx); err != nil {
 		log.Printf("Error during server shutdown: %v", err)
@@ -0,0 +1,129 @@
+package cdc
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// eventFromCommand converts a logged command to a change event. An update of a vector
+// is logged, and so reported, as a delete of the old version followed by an insert.
+func eventFromCommand(command types.AOFCommand) (*pb.WatchEvent, error) {
+	event := &pb.WatchEvent{
+		Timestamp:      command.Timestamp.Unix(),
+		DbName:         command.Database,
+		CollectionName: command.Collection,
+	}
+
+	switch command.Command {
+	case "CREATE_DATABASE":
+		event.Type = pb.ChangeType_CREATE_DATABASE
+	case "DROP_DATABASE":
+		event.Type = pb.ChangeType_DROP_DATABASE
+
+	case "CREATE_COLLECTION":
+		event.Type = pb.ChangeType_CREATE_COLLECTION
+		config, ok := command.Args["config"].(types.CollectionConfig)
+		if !ok {
+			return nil, fmt.Errorf("CREATE_COLLECTION command without collection config")
+		}
+		event.MetricType = config.Metric.ToProto()
+		event.HnswConfig = &pb.HnswConfig{
+			M:              int32(config.HNSWParams.M),
+			EfConstruction: int32(config.HNSWParams.EfConstruction),
+		}
+		event.DefaultTtlSeconds = config.DefaultTTLSeconds
+	case "DROP_COLLECTION":
+		event.Type = pb.ChangeType_DROP_COLLECTION
+
+	case "INSERT_VECTORS":
+		event.Type = pb.ChangeType_INSERT
+		vectors, ok := command.Args["vectors"].([]types.Vector)
+		if !ok {
+			return nil, fmt.Errorf("INSERT_VECTORS command without vectors")
+		}
+		event.Vectors = make([]*pb.Vector, len(vectors))
+		for i, vector := range vectors {
+			pbVector, err := vectorToProto(vector)
+			if err != nil {
+				return nil, err
+			}
+			event.Vectors[i] = pbVector
+		}
+		if len(vectors) > 0 {
+			event.PartitionName = vectors[0].Partition
+		}
+
+	case "DELETE_VECTORS":
+		event.Type = pb.ChangeType_DELETE
+		ids, ok := command.Args["ids"].([]string)
+		if !ok {
+			return nil, fmt.Errorf("DELETE_VECTORS command without ids")
+		}
+		event.Ids = make([]uint64, len(ids))
+		for i, id := range ids {
+			parsed, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid vector ID %q: %w", id, err)
+			}
+			event.Ids[i] = parsed
+		}
+		if partitions, ok := command.Args["partition_names"].([]string); ok {
+			event.PartitionNames = slices.Clone(partitions)
+		}
+
+	case "RENAME_COLLECTION":
+		event.Type = pb.ChangeType_RENAME_COLLECTION
+		event.TargetName = stringArg(command, "new_name")
+	case "CLONE_COLLECTION":
+		event.Type = pb.ChangeType_CLONE_COLLECTION
+		event.TargetName = stringArg(command, "target_name")
+	case "COPY_COLLECTION":
+		event.Type = pb.ChangeType_COPY_COLLECTION
+		event.TargetDbName = stringArg(command, "target_database")
+		event.TargetName = stringArg(command, "target_name")
+
+	case "CREATE_PARTITION":
+		event.Type = pb.ChangeType_CREATE_PARTITION
+		event.PartitionName = stringArg(command, "partition")
+	case "DROP_PARTITION":
+		event.Type = pb.ChangeType_DROP_PARTITION
+		event.PartitionName = stringArg(command, "partition")
+
+	default:
+		return nil, fmt.Errorf("unknown command: %s", command.Command)
+	}
+
+	return event, nil
+}
+
+// vectorToProto copies an inserted vector into its event, as the engine owns the original
+func vectorToProto(vector types.Vector) (*pb.Vector, error) {
+	id := vector.ID
+	pbVector := &pb.Vector{
+		Id:       &id,
+		Elements: slices.Clone(vector.Elements),
+	}
+	if vector.Metadata != nil {
+		metadata, err := structpb.NewStruct(vector.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata of vector %d: %w", vector.ID, err)
+		}
+		pbVector.Metadata = metadata
+	}
+	if vector.ExpireAt != 0 {
+		expireAt := vector.ExpireAt
+		pbVector.ExpireAt = &expireAt
+	}
+	return pbVector, nil
+}
+
+// stringArg returns a string argument of command, empty if it is missing
+func stringArg(command types.AOFCommand, name string) string {
+	value, _ := command.Args[name].(string)
+	return value
+}
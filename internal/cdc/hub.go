@@ -0,0 +1,286 @@
+// Package cdc provides change data capture: every command logged to the AOF becomes a
+// change event numbered with an offset, kept in a bounded backlog and streamed to
+// watchers of a database or collection. Watchers resume after the last offset they
+// received while it is still in the backlog.
+package cdc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultBacklogSize is the size of the backlog if none is configured
+	DefaultBacklogSize = 64 * 1024 * 1024
+
+	// HeartbeatInterval is how often a watcher is told the offset it reached while no
+	// event matches it
+	HeartbeatInterval = time.Second
+
+	readBatch = 256 // Events read from the backlog at a time
+)
+
+// ErrOffsetUnavailable reports that the events from an offset are not in the backlog:
+// they were dropped because the watcher fell behind, or belong to a stream of an earlier
+// run of the server. The watcher has to reload the data and watch from the current offset.
+var ErrOffsetUnavailable = errors.New("offset is not available")
+
+// Config configures the change backlog
+type Config struct {
+	BacklogSize int64 // Bytes of events kept for watchers to resume from, DefaultBacklogSize if 0
+}
+
+// Filter selects the events a watcher receives
+type Filter struct {
+	Database   string // Empty for all databases
+	Collection string // Empty for the whole database
+}
+
+// Matches reports whether the watcher of f receives event. Heartbeats and resets reach
+// every watcher, and events of a database reach the watchers of its collections.
+func (f Filter) Matches(event *pb.WatchEvent) bool {
+	if f.Database == "" || event.Type == pb.ChangeType_HEARTBEAT || event.Type == pb.ChangeType_RESET {
+		return true
+	}
+	if event.DbName == f.Database && (f.Collection == "" || event.CollectionName == "" || event.CollectionName == f.Collection) {
+		return true
+	}
+
+	// Collections created by copying or renaming another one
+	if event.TargetName == "" {
+		return false
+	}
+	targetDB := event.TargetDbName
+	if targetDB == "" {
+		targetDB = event.DbName
+	}
+	return targetDB == f.Database && (f.Collection == "" || event.TargetName == f.Collection)
+}
+
+// entry is an event in the backlog
+type entry struct {
+	event *pb.WatchEvent // Shared by all watchers, never modified
+	size  int64
+}
+
+// Hub keeps the backlog of change events and serves it to watchers. It implements
+// persistence.CommandFeed. Appending never waits for watchers: a watcher that falls
+// further behind than the backlog reaches is dropped with ErrOffsetUnavailable.
+type Hub struct {
+	mu       sync.Mutex
+	id       string        // Stream ID, new for every run of the server
+	offset   int64         // Offset of the last event appended
+	entries  []entry       // The most recent events, oldest first
+	size     int64         // Bytes of the entries
+	maxSize  int64         // Oldest entries are dropped beyond this size
+	notify   chan struct{} // Closed and replaced when events are appended
+	watchers int
+	logger   core.Logger
+}
+
+// NewHub creates a hub with a new stream ID and an empty backlog
+func NewHub(config Config, logger core.Logger) *Hub {
+	if config.BacklogSize <= 0 {
+		config.BacklogSize = DefaultBacklogSize
+	}
+	return &Hub{
+		id:      newStreamID(),
+		maxSize: config.BacklogSize,
+		notify:  make(chan struct{}),
+		logger:  logger,
+	}
+}
+
+// Append adds the event of a logged command to the backlog
+func (h *Hub) Append(command types.AOFCommand) {
+	event, err := eventFromCommand(command)
+	if err != nil {
+		h.logger.Error(context.Background(), "Failed to convert command to change event", err, map[string]interface{}{
+			"command": command.Command,
+		})
+		return
+	}
+	h.append(event)
+}
+
+// Reset appends a RESET event, as the state was replaced other than by logged commands
+func (h *Hub) Reset() {
+	h.append(&pb.WatchEvent{Type: pb.ChangeType_RESET, Timestamp: time.Now().Unix()})
+}
+
+// append numbers event and adds it to the backlog
+func (h *Hub) append(event *pb.WatchEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.offset++
+	event.Offset = h.offset
+	event.StreamId = h.id
+	size := int64(proto.Size(event))
+
+	h.entries = append(h.entries, entry{event: event, size: size})
+	h.size += size
+	for len(h.entries) > 1 && h.size > h.maxSize {
+		h.size -= h.entries[0].size
+		h.entries[0] = entry{}
+		h.entries = h.entries[1:]
+	}
+
+	close(h.notify)
+	h.notify = make(chan struct{})
+}
+
+// Position returns the stream ID and the offset of the last event appended
+func (h *Hub) Position() (string, int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.id, h.offset
+}
+
+// Backlog returns the offset of the oldest event in the backlog, or the next offset if
+// it is empty, the size of the backlog in bytes and the number of watchers
+func (h *Hub) Backlog() (int64, int64, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	first := h.offset + 1
+	if len(h.entries) > 0 {
+		first = h.entries[0].event.Offset
+	}
+	return first, h.size, h.watchers
+}
+
+// read returns up to max events from offset on. If there are none yet, it returns a
+// channel that is closed once there are.
+func (h *Hub) read(offset int64, max int) ([]*pb.WatchEvent, <-chan struct{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if offset > h.offset {
+		return nil, h.notify, nil
+	}
+	if len(h.entries) == 0 || h.entries[0].event.Offset > offset {
+		return nil, nil, h.unavailable(offset)
+	}
+
+	start := int(offset - h.entries[0].event.Offset)
+	end := min(start+max, len(h.entries))
+	events := make([]*pb.WatchEvent, 0, end-start)
+	for _, e := range h.entries[start:end] {
+		events = append(events, e.event)
+	}
+	return events, nil, nil
+}
+
+// unavailable returns the error for a watcher at offset that the backlog doesn't reach.
+// mu must be held.
+func (h *Hub) unavailable(offset int64) error {
+	first := h.offset + 1
+	if len(h.entries) > 0 {
+		first = h.entries[0].event.Offset
+	}
+	return fmt.Errorf("%w: events from offset %d were dropped from the change backlog, which starts at offset %d",
+		ErrOffsetUnavailable, offset, first)
+}
+
+// Watch sends the events matching filter from offset from on until ctx ends or send
+// fails. A from of 0 starts with the next change; streamID, unless empty, must be the
+// stream the offset belongs to. A HEARTBEAT event with the offset reached comes first,
+// and whenever no event was sent for HeartbeatInterval. Events are read from the backlog
+// as send returns, so a slow watcher holds up no one but itself.
+func (h *Hub) Watch(ctx context.Context, filter Filter, streamID string, from int64, send func(*pb.WatchEvent) error) error {
+	h.mu.Lock()
+	id := h.id
+	if streamID != "" && streamID != id {
+		h.mu.Unlock()
+		return fmt.Errorf("%w: stream %s belongs to an earlier run of the server, the current stream is %s",
+			ErrOffsetUnavailable, streamID, id)
+	}
+	switch {
+	case from <= 0:
+		from = h.offset + 1
+	case from > h.offset+1:
+		h.mu.Unlock()
+		return fmt.Errorf("%w: offset %d is ahead of the last event, offset %d", ErrOffsetUnavailable, from, h.offset)
+	case from <= h.offset && (len(h.entries) == 0 || h.entries[0].event.Offset > from):
+		err := h.unavailable(from)
+		h.mu.Unlock()
+		return err
+	}
+	h.watchers++
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		h.watchers--
+		h.mu.Unlock()
+	}()
+
+	heartbeat := func(next int64) error {
+		return send(&pb.WatchEvent{Offset: next - 1, StreamId: id, Type: pb.ChangeType_HEARTBEAT, Timestamp: time.Now().Unix()})
+	}
+
+	next := from
+	if err := heartbeat(next); err != nil {
+		return err
+	}
+	lastSent := time.Now()
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		events, notify, err := h.read(next, readBatch)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			next = event.Offset + 1
+			if !filter.Matches(event) {
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+			lastSent = time.Now()
+		}
+
+		// Tell a watcher whose events are rare how far it got, so it resumes from there
+		if time.Since(lastSent) >= HeartbeatInterval {
+			if err := heartbeat(next); err != nil {
+				return err
+			}
+			lastSent = time.Now()
+		}
+		if notify == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// newStreamID returns a random 32 character stream ID
+func newStreamID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic("cdc: failed to generate stream ID: " + err.Error())
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,194 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/observability/logger"
+	"github.com/scintirete/scintirete/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHub(t *testing.T, backlogSize int64) *Hub {
+	t.Helper()
+	log, err := logger.NewFromConfigString("error", "text")
+	require.NoError(t, err)
+	return NewHub(Config{BacklogSize: backlogSize}, log)
+}
+
+func insertVectors(db, coll string, ids ...uint64) types.AOFCommand {
+	vectors := make([]types.Vector, len(ids))
+	for i, id := range ids {
+		vectors[i] = types.Vector{ID: id, Elements: []float32{1, 2, 3}, Metadata: map[string]interface{}{"tag": "a"}}
+	}
+	return types.AOFCommand{
+		Timestamp:  time.Unix(1700000000, 0),
+		Command:    "INSERT_VECTORS",
+		Args:       map[string]interface{}{"vectors": vectors},
+		Database:   db,
+		Collection: coll,
+	}
+}
+
+// watchUntil watches hub until it received count events other than heartbeats
+func watchUntil(t *testing.T, hub *Hub, filter Filter, streamID string, from int64, count int) []*pb.WatchEvent {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []*pb.WatchEvent
+	done := errors.New("done")
+	err := hub.Watch(ctx, filter, streamID, from, func(event *pb.WatchEvent) error {
+		if event.Type == pb.ChangeType_HEARTBEAT {
+			return nil
+		}
+		events = append(events, event)
+		if len(events) == count {
+			return done
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, done)
+	return events
+}
+
+func TestEventFromCommand(t *testing.T) {
+	event, err := eventFromCommand(insertVectors("db", "coll", 7))
+	require.NoError(t, err)
+	assert.Equal(t, pb.ChangeType_INSERT, event.Type)
+	assert.Equal(t, int64(1700000000), event.Timestamp)
+	require.Len(t, event.Vectors, 1)
+	assert.Equal(t, uint64(7), event.Vectors[0].GetId())
+	assert.Equal(t, []float32{1, 2, 3}, event.Vectors[0].Elements)
+	assert.Equal(t, "a", event.Vectors[0].Metadata.AsMap()["tag"])
+
+	event, err = eventFromCommand(types.AOFCommand{
+		Command:    "DELETE_VECTORS",
+		Args:       map[string]interface{}{"ids": []string{"3", "4"}, "partition_names": []string{"p1"}},
+		Database:   "db",
+		Collection: "coll",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, pb.ChangeType_DELETE, event.Type)
+	assert.Equal(t, []uint64{3, 4}, event.Ids)
+	assert.Equal(t, []string{"p1"}, event.PartitionNames)
+
+	event, err = eventFromCommand(types.AOFCommand{
+		Command:    "CREATE_COLLECTION",
+		Args:       map[string]interface{}{"name": "coll", "config": types.CollectionConfig{Name: "coll", Metric: types.DistanceMetricCosine, HNSWParams: types.HNSWParams{M: 8, EfConstruction: 64}, DefaultTTLSeconds: 60}},
+		Database:   "db",
+		Collection: "coll",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, pb.DistanceMetric_COSINE, event.MetricType)
+	assert.Equal(t, int32(8), event.HnswConfig.GetM())
+	assert.Equal(t, int64(60), event.DefaultTtlSeconds)
+
+	_, err = eventFromCommand(types.AOFCommand{Command: "UNKNOWN"})
+	assert.Error(t, err)
+}
+
+func TestFilterMatches(t *testing.T) {
+	insert := &pb.WatchEvent{Type: pb.ChangeType_INSERT, DbName: "db", CollectionName: "coll"}
+	dropDB := &pb.WatchEvent{Type: pb.ChangeType_DROP_DATABASE, DbName: "db"}
+	copied := &pb.WatchEvent{Type: pb.ChangeType_COPY_COLLECTION, DbName: "other", CollectionName: "src", TargetDbName: "db", TargetName: "coll"}
+	renamed := &pb.WatchEvent{Type: pb.ChangeType_RENAME_COLLECTION, DbName: "db", CollectionName: "old", TargetName: "coll"}
+	reset := &pb.WatchEvent{Type: pb.ChangeType_RESET}
+
+	all := Filter{}
+	database := Filter{Database: "db"}
+	collection := Filter{Database: "db", Collection: "coll"}
+	other := Filter{Database: "db", Collection: "else"}
+
+	for _, event := range []*pb.WatchEvent{insert, dropDB, copied, renamed, reset} {
+		assert.True(t, all.Matches(event), event.Type.String())
+		assert.True(t, database.Matches(event), event.Type.String())
+		assert.True(t, collection.Matches(event), event.Type.String())
+	}
+	assert.False(t, other.Matches(insert))
+	assert.False(t, other.Matches(copied))
+	assert.False(t, other.Matches(renamed))
+	assert.True(t, other.Matches(dropDB))
+	assert.True(t, other.Matches(reset))
+	assert.False(t, Filter{Database: "else"}.Matches(insert))
+}
+
+func TestHubWatchResumesAfterOffset(t *testing.T) {
+	hub := newTestHub(t, 0)
+	id, offset := hub.Position()
+	assert.Len(t, id, 32)
+	assert.Zero(t, offset)
+
+	hub.Append(insertVectors("db", "a", 1))
+	hub.Append(insertVectors("db", "b", 2))
+	hub.Append(insertVectors("db", "a", 3))
+
+	events := watchUntil(t, hub, Filter{Database: "db", Collection: "a"}, id, 1, 2)
+	assert.Equal(t, []int64{1, 3}, []int64{events[0].Offset, events[1].Offset})
+	assert.Equal(t, id, events[0].StreamId)
+
+	events = watchUntil(t, hub, Filter{}, id, 2, 2)
+	assert.Equal(t, []int64{2, 3}, []int64{events[0].Offset, events[1].Offset})
+
+	// Events appended while watching arrive as they are appended
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		hub.Append(insertVectors("db", "a", 4))
+	}()
+	events = watchUntil(t, hub, Filter{Database: "db"}, "", 0, 1)
+	assert.Equal(t, int64(4), events[0].Offset)
+
+	// A reset reaches every watcher
+	hub.Reset()
+	events = watchUntil(t, hub, Filter{Database: "else"}, id, 5, 1)
+	assert.Equal(t, pb.ChangeType_RESET, events[0].Type)
+}
+
+func TestHubWatchStartsWithHeartbeat(t *testing.T) {
+	hub := newTestHub(t, 0)
+	hub.Append(insertVectors("db", "a", 1))
+
+	var first *pb.WatchEvent
+	stop := errors.New("stop")
+	err := hub.Watch(context.Background(), Filter{}, "", 0, func(event *pb.WatchEvent) error {
+		first = event
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	assert.Equal(t, pb.ChangeType_HEARTBEAT, first.Type)
+	assert.Equal(t, int64(1), first.Offset)
+}
+
+func TestHubDropsOldestEventsBeyondBacklog(t *testing.T) {
+	// Room for about two events
+	probe := newTestHub(t, 0)
+	probe.Append(insertVectors("db", "a", 1))
+	_, size, _ := probe.Backlog()
+
+	hub := newTestHub(t, 2*size+size/2)
+	for i := uint64(1); i <= 5; i++ {
+		hub.Append(insertVectors("db", "a", i))
+	}
+	first, backlog, watchers := hub.Backlog()
+	assert.Equal(t, int64(4), first)
+	assert.LessOrEqual(t, backlog, 2*size+size/2)
+	assert.Zero(t, watchers)
+
+	id, _ := hub.Position()
+	err := hub.Watch(context.Background(), Filter{}, id, 2, func(*pb.WatchEvent) error { return nil })
+	assert.ErrorIs(t, err, ErrOffsetUnavailable)
+
+	err = hub.Watch(context.Background(), Filter{}, id, 7, func(*pb.WatchEvent) error { return nil })
+	assert.ErrorIs(t, err, ErrOffsetUnavailable)
+
+	err = hub.Watch(context.Background(), Filter{}, "earlier", 4, func(*pb.WatchEvent) error { return nil })
+	assert.ErrorIs(t, err, ErrOffsetUnavailable)
+
+	events := watchUntil(t, hub, Filter{}, id, 4, 2)
+	assert.Equal(t, int64(5), events[1].Offset)
+}
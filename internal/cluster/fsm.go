@@ -0,0 +1,243 @@
+// Package cluster provides the Raft state machine of a cluster node.
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+
+	"github.com/hashicorp/raft"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// Types of Raft log entries, stored in their first byte
+const (
+	entryCommand byte = 1 // An AOF command in the format of the AOF records
+	entryNode    byte = 2 // The gRPC address of a node, a JSON-encoded nodeEntry
+)
+
+// Store is the state the Raft log is applied to
+type Store interface {
+	// ApplyCommand applies a committed command and logs it locally
+	ApplyCommand(ctx context.Context, command types.AOFCommand) (Result, error)
+	// CutSnapshot freezes the state between two applied commands, to be written while
+	// later commands are applied
+	CutSnapshot(ctx context.Context) (Snapshot, error)
+	// LoadSnapshot replaces the state with a snapshot written by a Snapshot
+	LoadSnapshot(ctx context.Context, reader io.Reader) error
+}
+
+// Snapshot is state frozen by Store.CutSnapshot
+type Snapshot interface {
+	// WriteTo writes the snapshot to w
+	WriteTo(ctx context.Context, w io.Writer) error
+	// Release frees the state kept for the snapshot
+	Release()
+}
+
+// Result is what applying a command returned, passed back to the node that proposed it
+type Result struct {
+	Index       uint64   // Index of the command in the Raft log
+	InsertedIDs []uint64 // IDs generated for the vectors of INSERT_VECTORS
+	Deleted     int64    // Vectors removed by DELETE_VECTORS and DROP_PARTITION
+}
+
+// applyResponse is what fsm.Apply returns to the future of the proposing node
+type applyResponse struct {
+	result Result
+	err    error
+}
+
+// nodeEntry records the gRPC address of a node, an empty address removes it
+type nodeEntry struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+}
+
+// snapshotHeader precedes the state in a Raft snapshot
+type snapshotHeader struct {
+	Applied uint64            `json:"applied"`
+	Nodes   map[string]string `json:"nodes"`
+}
+
+// fsm applies the Raft log to a store. Besides the commands it keeps the gRPC addresses
+// of the nodes, so that followers know where to forward writes.
+type fsm struct {
+	store  Store
+	logger core.Logger
+
+	mu      sync.Mutex
+	nodes   map[string]string // gRPC addresses by node ID
+	applied uint64            // Index of the last entry applied
+	changed chan struct{}     // Closed when applied moves on
+}
+
+func newFSM(store Store, logger core.Logger) *fsm {
+	return &fsm{
+		store:   store,
+		logger:  logger,
+		nodes:   make(map[string]string),
+		changed: make(chan struct{}),
+	}
+}
+
+// Apply applies a committed log entry
+func (f *fsm) Apply(log *raft.Log) interface{} {
+	ctx := context.Background()
+	defer f.setApplied(log.Index)
+
+	if len(log.Data) == 0 {
+		return applyResponse{err: fmt.Errorf("empty log entry at index %d", log.Index)}
+	}
+	data := log.Data[1:]
+
+	switch log.Data[0] {
+	case entryCommand:
+		command, err := aof.DecodeCommand(data)
+		if err != nil {
+			return applyResponse{err: fmt.Errorf("malformed command at index %d: %w", log.Index, err)}
+		}
+		result, err := f.store.ApplyCommand(ctx, command)
+		if err != nil {
+			// Every node fails the same way, as all of them apply the same commands
+			f.logger.Debug(ctx, "Command from the Raft log failed", map[string]interface{}{
+				"index":   log.Index,
+				"command": command.Command,
+				"error":   err.Error(),
+			})
+		}
+		result.Index = log.Index
+		return applyResponse{result: result, err: err}
+
+	case entryNode:
+		var node nodeEntry
+		if err := json.Unmarshal(data, &node); err != nil {
+			return applyResponse{err: fmt.Errorf("malformed node entry at index %d: %w", log.Index, err)}
+		}
+		f.mu.Lock()
+		if node.Address == "" {
+			delete(f.nodes, node.ID)
+		} else {
+			f.nodes[node.ID] = node.Address
+		}
+		f.mu.Unlock()
+		return applyResponse{result: Result{Index: log.Index}}
+
+	default:
+		return applyResponse{err: fmt.Errorf("unknown log entry type %d at index %d", log.Data[0], log.Index)}
+	}
+}
+
+// Snapshot freezes the state at the last applied entry. Raft calls it between two
+// applied entries and persists the snapshot while later ones are applied.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	f.mu.Lock()
+	header := snapshotHeader{Applied: f.applied, Nodes: maps.Clone(f.nodes)}
+	f.mu.Unlock()
+
+	snapshot, err := f.store.CutSnapshot(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &fsmSnapshot{header: header, snapshot: snapshot}, nil
+}
+
+// Restore replaces the state with a snapshot, such as the one a leader sends a follower
+// too far behind to catch up from the log
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	reader := bufio.NewReader(rc)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	var header snapshotHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return fmt.Errorf("malformed snapshot header: %w", err)
+	}
+
+	if err := f.store.LoadSnapshot(context.Background(), reader); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.nodes = header.Nodes
+	if f.nodes == nil {
+		f.nodes = make(map[string]string)
+	}
+	f.mu.Unlock()
+	f.setApplied(header.Applied)
+	return nil
+}
+
+// setApplied records the index of the last entry applied
+func (f *fsm) setApplied(index uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.applied = index
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// appliedIndex returns the index of the last entry applied
+func (f *fsm) appliedIndex() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.applied
+}
+
+// waitApplied waits until the entry at index is applied
+func (f *fsm) waitApplied(ctx context.Context, index uint64) error {
+	for {
+		f.mu.Lock()
+		applied, changed := f.applied, f.changed
+		f.mu.Unlock()
+		if applied >= index {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// address returns the gRPC address of a node, empty if it is unknown
+func (f *fsm) address(id string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nodes[id]
+}
+
+// fsmSnapshot is the state of the fsm frozen by Snapshot
+type fsmSnapshot struct {
+	header   snapshotHeader
+	snapshot Snapshot
+}
+
+// Persist writes the header line followed by the state
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	err := json.NewEncoder(sink).Encode(s.header)
+	if err == nil {
+		err = s.snapshot.WriteTo(context.Background(), sink)
+	}
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {
+	s.snapshot.Release()
+}
@@ -0,0 +1,471 @@
+// Package cluster provides a high-availability cluster mode: the AOF command stream is the
+// replicated log of a Raft group, RDB snapshots are its snapshots, and followers forward
+// writes to the leader.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// applyTimeout bounds how long a proposal waits to be applied without a deadline
+	applyTimeout = 10 * time.Second
+
+	retainSnapshots  = 2
+	maxTransportPool = 3
+	transportTimeout = 10 * time.Second
+	maxRecvSize      = 256 * 1024 * 1024 // A forwarded command can be as large as the leader accepts
+)
+
+var (
+	// ErrNotLeader is returned by operations only the leader performs
+	ErrNotLeader = raft.ErrNotLeader
+	// ErrNoLeader is returned when writes cannot be forwarded, such as during an election
+	ErrNoLeader = errors.New("cluster has no leader")
+)
+
+// IsUnavailable reports whether err means the cluster could not take a command right now,
+// such as while a leader is elected. Commands interrupted by a leadership change may or
+// may not have been applied.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrNotLeader) || errors.Is(err, ErrNoLeader) ||
+		errors.Is(err, raft.ErrLeadershipLost) || errors.Is(err, raft.ErrLeadershipTransferInProgress) ||
+		errors.Is(err, raft.ErrEnqueueTimeout) || errors.Is(err, raft.ErrRaftShutdown)
+}
+
+// Config configures a cluster node
+type Config struct {
+	NodeID        string // Unique within the cluster; cluster mode is off without one
+	BindAddr      string // Address the Raft transport listens on, host:port
+	AdvertiseAddr string // Raft address other nodes connect to, BindAddr if empty
+	Address       string // gRPC address other nodes forward writes to, host:port
+	DataDir       string // Directory of the Raft log and snapshots
+	Bootstrap     bool   // Start a new cluster with this node as its only member
+	Password      string // Password the leader accepts for forwarded writes
+
+	SnapshotThreshold uint64        // Log entries between Raft snapshots, 0 for the Raft default
+	SnapshotInterval  time.Duration // How often to check for a Raft snapshot, 0 for the Raft default
+}
+
+// Member is a member of the Raft group
+type Member struct {
+	ID          string
+	RaftAddress string
+	Address     string // gRPC address, empty if not known yet
+	Voter       bool
+	Leader      bool
+}
+
+// Status describes the state of a node and its cluster
+type Status struct {
+	NodeID        string
+	State         string // leader, follower, candidate or shutdown
+	LeaderID      string
+	LeaderAddress string
+	Term          uint64
+	CommitIndex   uint64
+	AppliedIndex  uint64
+	Members       []Member
+}
+
+// Node is a member of a Raft group that applies the replicated command log to a store
+type Node struct {
+	config    Config
+	raft      *raft.Raft
+	fsm       *fsm
+	transport *raft.NetworkTransport
+	logs      *raftboltdb.BoltStore
+	logger    core.Logger
+	done      chan struct{}
+
+	connMu   sync.Mutex
+	conn     *grpc.ClientConn // Connection to the leader
+	connAddr string
+}
+
+// Start starts a cluster node. Its state is restored from the latest Raft snapshot and
+// rebuilt from the log that follows, so store should start out empty.
+func Start(config Config, store Store, logger core.Logger) (*Node, error) {
+	if config.NodeID == "" {
+		return nil, errors.New("cluster node ID cannot be empty")
+	}
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cluster data directory: %w", err)
+	}
+
+	raftLogger := hclog.New(&hclog.LoggerOptions{
+		Name:        "raft",
+		Level:       hclog.Info,
+		Output:      logWriter{logger: logger},
+		DisableTime: true,
+	})
+
+	logs, err := raftboltdb.NewBoltStore(filepath.Join(config.DataDir, "raft.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Raft log: %w", err)
+	}
+	snapshots, err := raft.NewFileSnapshotStoreWithLogger(config.DataDir, retainSnapshots, raftLogger)
+	if err != nil {
+		logs.Close()
+		return nil, fmt.Errorf("failed to open Raft snapshots: %w", err)
+	}
+
+	var advertise net.Addr
+	if config.AdvertiseAddr != "" {
+		if advertise, err = net.ResolveTCPAddr("tcp", config.AdvertiseAddr); err != nil {
+			logs.Close()
+			return nil, fmt.Errorf("invalid Raft advertise address: %w", err)
+		}
+	}
+	transport, err := raft.NewTCPTransportWithLogger(config.BindAddr, advertise, maxTransportPool, transportTimeout, raftLogger)
+	if err != nil {
+		logs.Close()
+		return nil, fmt.Errorf("failed to start Raft transport: %w", err)
+	}
+
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(config.NodeID)
+	raftConfig.Logger = raftLogger
+	if config.SnapshotThreshold > 0 {
+		raftConfig.SnapshotThreshold = config.SnapshotThreshold
+	}
+	if config.SnapshotInterval > 0 {
+		raftConfig.SnapshotInterval = config.SnapshotInterval
+	}
+
+	closeAll := func() {
+		transport.Close()
+		logs.Close()
+	}
+
+	if config.Bootstrap {
+		existing, err := raft.HasExistingState(logs, logs, snapshots)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to check Raft state: %w", err)
+		}
+		// A node that was bootstrapped before rejoins the cluster it knows
+		if !existing {
+			if err := raft.BootstrapCluster(raftConfig, logs, logs, snapshots, transport, raft.Configuration{
+				Servers: []raft.Server{{
+					Suffrage: raft.Voter,
+					ID:       raftConfig.LocalID,
+					Address:  transport.LocalAddr(),
+				}},
+			}); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to bootstrap cluster: %w", err)
+			}
+		}
+	}
+
+	f := newFSM(store, logger)
+	r, err := raft.NewRaft(raftConfig, f, logs, logs, snapshots, transport)
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to start Raft: %w", err)
+	}
+
+	n := &Node{
+		config:    config,
+		raft:      r,
+		fsm:       f,
+		transport: transport,
+		logs:      logs,
+		logger:    logger,
+		done:      make(chan struct{}),
+	}
+	go n.run()
+
+	logger.Info(context.Background(), "Cluster node started", map[string]interface{}{
+		"node_id":      config.NodeID,
+		"raft_address": string(transport.LocalAddr()),
+		"address":      config.Address,
+		"bootstrap":    config.Bootstrap,
+	})
+	return n, nil
+}
+
+// Shutdown leaves the Raft group running without this node until it starts again
+func (n *Node) Shutdown() error {
+	close(n.done)
+	err := n.raft.Shutdown().Error()
+
+	n.connMu.Lock()
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+	n.connMu.Unlock()
+
+	n.transport.Close()
+	if closeErr := n.logs.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ID returns the ID of the node
+func (n *Node) ID() string {
+	return n.config.NodeID
+}
+
+// RaftAddress returns the address the Raft transport listens on
+func (n *Node) RaftAddress() string {
+	return string(n.transport.LocalAddr())
+}
+
+// IsLeader reports whether the node is the leader
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+// Apply applies command to every node of the cluster: the leader proposes it, a follower
+// forwards it to the leader. It returns once the command is applied on this node too.
+func (n *Node) Apply(ctx context.Context, command types.AOFCommand) (Result, error) {
+	if n.IsLeader() {
+		result, err := n.Propose(ctx, command)
+		if !errors.Is(err, ErrNotLeader) {
+			return result, err
+		}
+	}
+	return n.forward(ctx, command)
+}
+
+// Propose appends command to the Raft log and waits until the leader applied it. Only the
+// leader proposes.
+func (n *Node) Propose(ctx context.Context, command types.AOFCommand) (Result, error) {
+	data, err := aof.EncodeCommand(command)
+	if err != nil {
+		return Result{}, err
+	}
+	response, err := n.propose(ctx, append([]byte{entryCommand}, data...))
+	if err != nil {
+		return Result{}, err
+	}
+	return response.result, response.err
+}
+
+// propose appends an entry to the Raft log and waits until it is applied
+func (n *Node) propose(ctx context.Context, entry []byte) (applyResponse, error) {
+	future := n.raft.Apply(entry, timeout(ctx))
+	if err := future.Error(); err != nil {
+		return applyResponse{}, err
+	}
+	return future.Response().(applyResponse), nil
+}
+
+// forward has the leader apply command, then waits until this node applied it too, so
+// that the write can be read from this node afterwards
+func (n *Node) forward(ctx context.Context, command types.AOFCommand) (Result, error) {
+	client, err := n.LeaderClient()
+	if err != nil {
+		return Result{}, err
+	}
+	data, err := aof.EncodeCommand(command)
+	if err != nil {
+		return Result{}, err
+	}
+
+	resp, err := client.ClusterApply(ctx, &pb.ClusterApplyRequest{
+		Auth:    &pb.AuthInfo{Password: n.config.Password},
+		Command: data,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if err := n.fsm.waitApplied(ctx, resp.Index); err != nil {
+		return Result{}, fmt.Errorf("command applied at index %d, but not on this node yet: %w", resp.Index, err)
+	}
+
+	return Result{
+		Index:       resp.Index,
+		InsertedIDs: resp.InsertedIds,
+		Deleted:     resp.DeletedCount,
+	}, nil
+}
+
+// LeaderClient returns a client of the gRPC service of the leader
+func (n *Node) LeaderClient() (pb.ScintireteServiceClient, error) {
+	_, id := n.raft.LeaderWithID()
+	if id == "" {
+		return nil, ErrNoLeader
+	}
+	address := n.fsm.address(string(id))
+	if address == "" {
+		return nil, fmt.Errorf("%w: address of leader %s is not known yet", ErrNoLeader, id)
+	}
+
+	n.connMu.Lock()
+	defer n.connMu.Unlock()
+
+	if n.conn == nil || n.connAddr != address {
+		conn, err := grpc.NewClient(address,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxRecvSize)),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n.conn != nil {
+			n.conn.Close()
+		}
+		n.conn, n.connAddr = conn, address
+	}
+	return pb.NewScintireteServiceClient(n.conn), nil
+}
+
+// AddNode adds a node to the Raft group as a voter. Only the leader adds nodes.
+func (n *Node) AddNode(ctx context.Context, id, raftAddress, address string) error {
+	if err := n.setAddress(ctx, id, address); err != nil {
+		return err
+	}
+	if err := n.raft.AddVoter(raft.ServerID(id), raft.ServerAddress(raftAddress), 0, timeout(ctx)).Error(); err != nil {
+		return err
+	}
+
+	n.logger.Info(ctx, "Added cluster node", map[string]interface{}{
+		"node_id":      id,
+		"raft_address": raftAddress,
+		"address":      address,
+	})
+	return nil
+}
+
+// RemoveNode removes a node from the Raft group. Only the leader removes nodes; a leader
+// that removes itself steps down.
+func (n *Node) RemoveNode(ctx context.Context, id string) error {
+	if err := n.raft.RemoveServer(raft.ServerID(id), 0, timeout(ctx)).Error(); err != nil {
+		return err
+	}
+	if id != n.config.NodeID {
+		if err := n.setAddress(ctx, id, ""); err != nil {
+			return err
+		}
+	}
+
+	n.logger.Info(ctx, "Removed cluster node", map[string]interface{}{
+		"node_id": id,
+	})
+	return nil
+}
+
+// Status returns the state of the node and the members of its cluster
+func (n *Node) Status() (Status, error) {
+	future := n.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return Status{}, err
+	}
+
+	_, leaderID := n.raft.LeaderWithID()
+	status := Status{
+		NodeID:        n.config.NodeID,
+		State:         strings.ToLower(n.raft.State().String()),
+		LeaderID:      string(leaderID),
+		LeaderAddress: n.fsm.address(string(leaderID)),
+		Term:          n.raft.CurrentTerm(),
+		CommitIndex:   n.raft.CommitIndex(),
+		AppliedIndex:  n.fsm.appliedIndex(),
+	}
+	for _, server := range future.Configuration().Servers {
+		status.Members = append(status.Members, Member{
+			ID:          string(server.ID),
+			RaftAddress: string(server.Address),
+			Address:     n.fsm.address(string(server.ID)),
+			Voter:       server.Suffrage == raft.Voter,
+			Leader:      server.ID == leaderID,
+		})
+	}
+	return status, nil
+}
+
+// setAddress records the gRPC address of a node in the Raft log
+func (n *Node) setAddress(ctx context.Context, id, address string) error {
+	entry, err := json.Marshal(nodeEntry{ID: id, Address: address})
+	if err != nil {
+		return err
+	}
+	response, err := n.propose(ctx, append([]byte{entryNode}, entry...))
+	if err != nil {
+		return err
+	}
+	return response.err
+}
+
+// run records the gRPC address of the node whenever it becomes the leader, so that a
+// bootstrapped node or one that moved is found by the followers
+func (n *Node) run() {
+	ctx := context.Background()
+	for {
+		select {
+		case <-n.done:
+			return
+		case leader := <-n.raft.LeaderCh():
+			if !leader || n.config.Address == "" {
+				continue
+			}
+			// The address book is up to date once the entries of earlier terms are applied
+			if err := n.raft.Barrier(applyTimeout).Error(); err != nil {
+				continue
+			}
+			if n.fsm.address(n.config.NodeID) == n.config.Address {
+				continue
+			}
+			if err := n.setAddress(ctx, n.config.NodeID, n.config.Address); err != nil {
+				n.logger.Warn(ctx, "Failed to record the address of the leader", map[string]interface{}{
+					"address": n.config.Address,
+					"error":   err.Error(),
+				})
+			}
+		}
+	}
+}
+
+// timeout returns how long to wait for ctx, applyTimeout without a deadline
+func timeout(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return max(time.Until(deadline), time.Millisecond)
+	}
+	return applyTimeout
+}
+
+// logWriter passes the lines Raft logs to a logger at their level
+type logWriter struct {
+	logger core.Logger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	ctx := context.Background()
+	line := strings.TrimSpace(string(p))
+	fields := map[string]interface{}{"component": "raft"}
+	switch {
+	case strings.Contains(line, "[ERROR]"):
+		w.logger.Error(ctx, line, nil, fields)
+	case strings.Contains(line, "[WARN]"):
+		w.logger.Warn(ctx, line, fields)
+	case strings.Contains(line, "[INFO]"):
+		w.logger.Info(ctx, line, fields)
+	default:
+		w.logger.Debug(ctx, line, fields)
+	}
+	return len(p), nil
+}
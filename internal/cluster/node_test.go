@@ -0,0 +1,252 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/observability/logger"
+	"github.com/scintirete/scintirete/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is a store of database names, created by CREATE_DATABASE commands, and of
+// the vectors of INSERT_VECTORS commands
+type memoryStore struct {
+	mu      sync.Mutex
+	names   []string
+	vectors []types.Vector
+}
+
+func (s *memoryStore) ApplyCommand(ctx context.Context, command types.AOFCommand) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if command.Command == "INSERT_VECTORS" {
+		vectors, _ := command.Args["vectors"].([]types.Vector)
+		s.vectors = append(s.vectors, vectors...)
+		return Result{}, nil
+	}
+	name, _ := command.Args["name"].(string)
+	if slices.Contains(s.names, name) {
+		return Result{}, fmt.Errorf("database %s already exists", name)
+	}
+	s.names = append(s.names, name)
+	return Result{InsertedIDs: []uint64{uint64(len(s.names))}}, nil
+}
+
+func (s *memoryStore) CutSnapshot(ctx context.Context) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memorySnapshot(slices.Clone(s.names)), nil
+}
+
+func (s *memoryStore) LoadSnapshot(ctx context.Context, reader io.Reader) error {
+	var names []string
+	if err := json.NewDecoder(reader).Decode(&names); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names = names
+	return nil
+}
+
+func (s *memoryStore) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.names)
+}
+
+func (s *memoryStore) Vectors() []types.Vector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.vectors)
+}
+
+type memorySnapshot []string
+
+func (s memorySnapshot) WriteTo(ctx context.Context, w io.Writer) error {
+	return json.NewEncoder(w).Encode([]string(s))
+}
+
+func (s memorySnapshot) Release() {}
+
+type testNode struct {
+	*Node
+	store  *memoryStore
+	config Config
+}
+
+func startTestNode(t *testing.T, config Config, store *memoryStore) *testNode {
+	t.Helper()
+	log, err := logger.NewFromConfigString("error", "text")
+	require.NoError(t, err)
+
+	if config.BindAddr == "" {
+		config.BindAddr = "127.0.0.1:0"
+	}
+	if config.DataDir == "" {
+		config.DataDir = t.TempDir()
+	}
+	node, err := Start(config, store, log)
+	require.NoError(t, err)
+	// Restarts reuse the port the transport got
+	config.BindAddr = node.RaftAddress()
+	return &testNode{Node: node, store: store, config: config}
+}
+
+func createDatabase(name string) types.AOFCommand {
+	return types.AOFCommand{Command: "CREATE_DATABASE", Args: map[string]interface{}{"name": name}}
+}
+
+// waitLeader waits until one of nodes leads the cluster
+func waitLeader(t *testing.T, nodes ...*testNode) *testNode {
+	t.Helper()
+	var leader *testNode
+	require.Eventually(t, func() bool {
+		for _, node := range nodes {
+			if node.IsLeader() {
+				leader = node
+				return true
+			}
+		}
+		return false
+	}, 10*time.Second, 20*time.Millisecond, "no leader elected")
+	return leader
+}
+
+func TestClusterReplicatesCommands(t *testing.T) {
+	ctx := context.Background()
+
+	first := startTestNode(t, Config{NodeID: "n1", Address: "n1:9090", Bootstrap: true}, &memoryStore{})
+	waitLeader(t, first)
+	second := startTestNode(t, Config{NodeID: "n2", Address: "n2:9090"}, &memoryStore{})
+	third := startTestNode(t, Config{NodeID: "n3", Address: "n3:9090"}, &memoryStore{})
+	nodes := []*testNode{first, second, third}
+	defer func() {
+		for _, node := range nodes {
+			node.Shutdown()
+		}
+	}()
+
+	require.NoError(t, first.AddNode(ctx, "n2", second.RaftAddress(), "n2:9090"))
+	require.NoError(t, first.AddNode(ctx, "n3", third.RaftAddress(), "n3:9090"))
+
+	// The leader applies a command before Propose returns, followers soon after
+	result, err := first.Propose(ctx, createDatabase("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1}, result.InsertedIDs)
+	assert.NotZero(t, result.Index)
+	assert.Equal(t, []string{"a"}, first.store.Names())
+	for _, node := range nodes[1:] {
+		require.NoError(t, node.fsm.waitApplied(ctx, result.Index))
+		assert.Equal(t, []string{"a"}, node.store.Names())
+	}
+
+	// Failing commands fail on every node alike
+	_, err = first.Propose(ctx, createDatabase("a"))
+	assert.EqualError(t, err, "database a already exists")
+
+	// Only the leader proposes
+	_, err = second.Propose(ctx, createDatabase("b"))
+	assert.ErrorIs(t, err, ErrNotLeader)
+	assert.True(t, IsUnavailable(err))
+
+	status, err := second.Status()
+	require.NoError(t, err)
+	assert.Equal(t, "follower", status.State)
+	assert.Equal(t, "n1", status.LeaderID)
+	assert.Equal(t, "n1:9090", status.LeaderAddress)
+	require.Len(t, status.Members, 3)
+	for _, member := range status.Members {
+		assert.Equal(t, member.ID+":9090", member.Address)
+		assert.True(t, member.Voter)
+		assert.Equal(t, member.ID == "n1", member.Leader)
+	}
+}
+
+func TestClusterReplicatesVectorMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	first := startTestNode(t, Config{NodeID: "n1", Address: "n1:9090", Bootstrap: true}, &memoryStore{})
+	waitLeader(t, first)
+	second := startTestNode(t, Config{NodeID: "n2", Address: "n2:9090"}, &memoryStore{})
+	defer func() {
+		first.Shutdown()
+		second.Shutdown()
+	}()
+	require.NoError(t, first.AddNode(ctx, "n2", second.RaftAddress(), "n2:9090"))
+
+	vectors := []types.Vector{
+		{ID: 1, Elements: []float32{1, 2}, Metadata: map[string]interface{}{"label": "a", "rank": float64(3)}, Partition: "p1"},
+		{ID: 2, Elements: []float32{3, 4}},
+	}
+	result, err := first.Propose(ctx, types.AOFCommand{
+		Command:    "INSERT_VECTORS",
+		Database:   "db",
+		Collection: "docs",
+		Args:       map[string]interface{}{"vectors": vectors},
+	})
+	require.NoError(t, err)
+
+	// Every node, the leader included, applies the vectors as decoded from the log
+	assert.Equal(t, vectors, first.store.Vectors())
+	require.NoError(t, second.fsm.waitApplied(ctx, result.Index))
+	assert.Equal(t, vectors, second.store.Vectors())
+}
+
+func TestClusterFailover(t *testing.T) {
+	ctx := context.Background()
+
+	first := startTestNode(t, Config{NodeID: "n1", Address: "n1:9090", Bootstrap: true}, &memoryStore{})
+	waitLeader(t, first)
+	second := startTestNode(t, Config{NodeID: "n2", Address: "n2:9090"}, &memoryStore{})
+	third := startTestNode(t, Config{NodeID: "n3", Address: "n3:9090"}, &memoryStore{})
+	require.NoError(t, first.AddNode(ctx, "n2", second.RaftAddress(), "n2:9090"))
+	require.NoError(t, first.AddNode(ctx, "n3", third.RaftAddress(), "n3:9090"))
+	defer func() {
+		second.Shutdown()
+		third.Shutdown()
+	}()
+
+	_, err := first.Propose(ctx, createDatabase("a"))
+	require.NoError(t, err)
+	// A snapshot followed by more log restores the node when it restarts
+	require.NoError(t, first.raft.Snapshot().Error())
+	_, err = first.Propose(ctx, createDatabase("b"))
+	require.NoError(t, err)
+	require.NoError(t, first.Shutdown())
+
+	// The others elect a new leader, which records its address for forwarding
+	leader := waitLeader(t, second, third)
+	result, err := leader.Propose(ctx, createDatabase("c"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, leader.store.Names())
+	require.Eventually(t, func() bool {
+		status, err := leader.Status()
+		return err == nil && status.LeaderAddress == leader.config.Address
+	}, 10*time.Second, 20*time.Millisecond)
+
+	// The old leader rejoins as a follower and catches up
+	restarted := startTestNode(t, first.config, &memoryStore{})
+	defer restarted.Shutdown()
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	require.NoError(t, restarted.fsm.waitApplied(waitCtx, result.Index))
+	assert.Equal(t, []string{"a", "b", "c"}, restarted.store.Names())
+	assert.False(t, restarted.IsLeader())
+
+	// Removed nodes leave the membership and the address book
+	require.NoError(t, leader.RemoveNode(ctx, "n1"))
+	status, err := leader.Status()
+	require.NoError(t, err)
+	assert.Len(t, status.Members, 2)
+	assert.Empty(t, leader.fsm.address("n1"))
+}
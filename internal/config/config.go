@@ -3,14 +3,25 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/scintirete/scintirete/internal/cdc"
+	"github.com/scintirete/scintirete/internal/cluster"
 	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/core/database"
 	"github.com/scintirete/scintirete/internal/embedding"
 	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/persistence/backup"
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/replication"
+	"github.com/scintirete/scintirete/internal/sharding"
+	"github.com/scintirete/scintirete/internal/webhook"
 )
 
 // Config represents the complete Scintirete configuration.
@@ -22,6 +33,14 @@ type Config struct {
 	Observability ObservabilityConfig `toml:"observability"`
 	Algorithm     AlgorithmConfig     `toml:"algorithm"`
 	Monitoring    MonitoringConfig    `toml:"monitoring"`
+	Memory        MemoryConfig        `toml:"memory"`
+	Backup        BackupConfig        `toml:"backup"`
+	Encryption    EncryptionConfig    `toml:"encryption"`
+	Replication   ReplicationConfig   `toml:"replication"`
+	Cluster       ClusterConfig       `toml:"cluster"`
+	Sharding      ShardingConfig      `toml:"sharding"`
+	CDC           CDCConfig           `toml:"cdc"`
+	Webhook       WebhookConfig       `toml:"webhook"`
 }
 
 // ServerConfig contains network and authentication settings.
@@ -30,6 +49,8 @@ type ServerConfig struct {
 	GRPCPort  int      `toml:"grpc_port"`
 	HTTPHost  string   `toml:"http_host"`
 	HTTPPort  int      `toml:"http_port"`
+	RESPHost  string   `toml:"resp_host"`
+	RESPPort  int      `toml:"resp_port"` // Redis protocol listener, 0 disables it
 	Passwords []string `toml:"passwords"`
 }
 
@@ -44,8 +65,11 @@ type LogConfig struct {
 type PersistenceConfig struct {
 	DataDir            string `toml:"data_dir"`             // Directory to store data files
 	RDBFilename        string `toml:"rdb_filename"`         // RDB snapshot filename
-	AOFFilename        string `toml:"aof_filename"`         // AOF log filename
+	AOFFilename        string `toml:"aof_filename"`         // AOF log filename, prefix of the files in AOFDirname
+	AOFDirname         string `toml:"aof_dirname"`          // Directory within DataDir holding the multi-part AOF
+	AOFArchiveDir      string `toml:"aof_archive_dir"`      // Directory replaced AOF files are archived in for point-in-time recovery; empty disables
 	AOFSyncStrategy    string `toml:"aof_sync_strategy"`    // AOF sync strategy: always, everysec, no
+	RDBCompression     string `toml:"rdb_compression"`      // RDB snapshot compression: none, zstd, lz4
 	RDBIntervalMinutes int    `toml:"rdb_interval_minutes"` // How often to create RDB snapshots (in minutes)
 	AOFRewriteSizeMB   int    `toml:"aof_rewrite_size_mb"`  // Rewrite AOF when it exceeds this size (in MB)
 }
@@ -100,6 +124,104 @@ type MonitoringConfig struct {
 	DiskThreshold   int     `toml:"disk_threshold"`   // 磁盘使用阈值（MB）
 }
 
+// MemoryConfig contains the memory limit and collection loading settings.
+type MemoryConfig struct {
+	MaxMemoryMB        int    `toml:"maxmemory_mb"`         // Memory limit in MB, 0 means unlimited
+	MaxMemoryPolicy    string `toml:"maxmemory_policy"`     // noeviction, allcollections-lru or volatile-ttl
+	AutoLoad           string `toml:"auto_load"`            // eager, lazy or manual
+	MaxConcurrentLoads int    `toml:"max_concurrent_loads"` // Collections loaded from disk at the same time, 0 means unlimited
+}
+
+// BackupConfig contains where backups are kept and how many of them.
+type BackupConfig struct {
+	Dir        string `toml:"dir"`         // Directory backups are kept in, relative to the data directory
+	KeepLast   int    `toml:"keep_last"`   // Number of most recent backups to keep
+	KeepDaily  int    `toml:"keep_daily"`  // Number of most recent days to keep the newest backup of
+	KeepWeekly int    `toml:"keep_weekly"` // Number of most recent weeks to keep the newest backup of
+
+	IntervalMinutes int                  `toml:"interval_minutes"` // How often to take a backup and copy it to the targets; 0 disables
+	Targets         []BackupTargetConfig `toml:"targets"`          // Where backups are copied to so they outlive the node
+}
+
+// BackupTargetConfig describes a place backups are copied to.
+type BackupTargetConfig struct {
+	Name string `toml:"name"` // Defaults to the type
+	Type string `toml:"type"` // dir or s3
+
+	Path string `toml:"path"` // dir: directory, may be a mounted remote filesystem such as NFS or SSHFS
+
+	Endpoint   string `toml:"endpoint"`     // s3: such as https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Region     string `toml:"region"`       // s3: defaults to us-east-1
+	Bucket     string `toml:"bucket"`       // s3
+	Prefix     string `toml:"prefix"`       // s3: prepended to object names
+	AccessKey  string `toml:"access_key"`   // s3: defaults to AWS_ACCESS_KEY_ID
+	SecretKey  string `toml:"secret_key"`   // s3: defaults to AWS_SECRET_ACCESS_KEY
+	PathStyle  bool   `toml:"path_style"`   // s3: address the bucket in the path, as most S3-compatible stores need
+	PartSizeMB int    `toml:"part_size_mb"` // s3: size of multipart upload parts, at least 5
+}
+
+// EncryptionConfig contains where the keys the AOF, RDB snapshots and backups are
+// encrypted with come from. Files are written unencrypted if neither is set.
+type EncryptionConfig struct {
+	KeyFile string `toml:"key_file"` // File holding "id:key" lines, the first key encrypts new files
+	KeyEnv  string `toml:"key_env"`  // Environment variable holding the keys in the same format
+}
+
+// ReplicationConfig contains the primary a server follows and the backlog it keeps for
+// its own replicas.
+type ReplicationConfig struct {
+	ReplicaOf       string `toml:"replica_of"`       // gRPC address of the primary to follow, host:port; empty runs as a primary
+	PrimaryPassword string `toml:"primary_password"` // Password the primary accepts
+	ReplicaName     string `toml:"replica_name"`     // Shown in the replication info of the primary, defaults to the host name
+	BacklogMB       int    `toml:"backlog_mb"`       // Commands kept for partial resynchronizations of replicas (in MB), 0 for the default
+}
+
+// ClusterConfig contains the Raft group a server is a node of in cluster mode, where
+// writes are replicated through the Raft log and followers forward them to the leader.
+type ClusterConfig struct {
+	Enabled                 bool   `toml:"enabled"`
+	NodeID                  string `toml:"node_id"`                   // Unique within the cluster
+	RaftBind                string `toml:"raft_bind"`                 // Address the Raft transport listens on, host:port
+	RaftAdvertise           string `toml:"raft_advertise"`            // Raft address other nodes connect to, raft_bind if empty
+	GRPCAdvertise           string `toml:"grpc_advertise"`            // gRPC address other nodes forward writes to, grpc_host:grpc_port if empty
+	DataDir                 string `toml:"data_dir"`                  // Raft log and snapshots, <persistence.data_dir>/raft if empty
+	Bootstrap               bool   `toml:"bootstrap"`                 // Start a new cluster with this node as its only member
+	SnapshotThreshold       uint64 `toml:"snapshot_threshold"`        // Log entries between Raft snapshots, 0 for the default
+	SnapshotIntervalSeconds int    `toml:"snapshot_interval_seconds"` // How often to check for a Raft snapshot, 0 for the default
+}
+
+// ShardingConfig contains how a coordinator connects to the shards of the sharded
+// collections it created.
+type ShardingConfig struct {
+	Password              string `toml:"password"`                // Password the shards accept, the first server password if empty
+	RequestTimeoutSeconds int    `toml:"request_timeout_seconds"` // Bounds each request to a shard, 0 for the default
+}
+
+// CDCConfig contains the change events a server keeps for watchers to resume from.
+type CDCConfig struct {
+	BacklogMB int `toml:"backlog_mb"` // Change events kept for watchers to resume from (in MB), 0 for the default
+}
+
+// WebhookConfig contains the endpoints change events are posted to.
+type WebhookConfig struct {
+	DeadLetterFile      string              `toml:"dead_letter_file"`      // Undeliverable payloads are appended here, relative to the data directory; empty disables
+	RetryBackoffSeconds int                 `toml:"retry_backoff_seconds"` // Wait before the first retry, doubled for each further one; 0 for the default
+	Hooks               []WebhookHookConfig `toml:"hooks"`
+}
+
+// WebhookHookConfig contains an endpoint and the events posted to it.
+type WebhookHookConfig struct {
+	Name           string   `toml:"name"`
+	URL            string   `toml:"url"`
+	Secret         string   `toml:"secret"`          // Signs payloads with HMAC-SHA256, unsigned if empty
+	Database       string   `toml:"database"`        // Empty for all databases
+	Collection     string   `toml:"collection"`      // Empty for the whole database
+	Events         []string `toml:"events"`          // ddl, insert, delete and snapshot; empty for all
+	IncludeVectors bool     `toml:"include_vectors"` // Insert payloads carry the vectors, not only their IDs
+	MaxRetries     int      `toml:"max_retries"`     // 0 for the default, negative for none
+	TimeoutSeconds int      `toml:"timeout_seconds"` // Bounds each attempt, 0 for the default
+}
+
 // DefaultConfig returns a configuration with sensible defaults.
 func DefaultConfig() *Config {
 	return &Config{
@@ -108,6 +230,7 @@ func DefaultConfig() *Config {
 			GRPCPort:  9090,
 			HTTPHost:  "127.0.0.1",
 			HTTPPort:  8080,
+			RESPHost:  "127.0.0.1",
 			Passwords: []string{"default-password"},
 		},
 		Log: LogConfig{
@@ -119,7 +242,9 @@ func DefaultConfig() *Config {
 			DataDir:            "./data",
 			RDBFilename:        "dump.rdb",
 			AOFFilename:        "appendonly.aof",
+			AOFDirname:         "appendonlydir",
 			AOFSyncStrategy:    "everysec",
+			RDBCompression:     "none",
 			RDBIntervalMinutes: 0,  // 0 minutes, consistent with persistence.DefaultConfig
 			AOFRewriteSizeMB:   64, // 64MB, consistent with persistence.DefaultConfig
 		},
@@ -157,6 +282,26 @@ func DefaultConfig() *Config {
 			DiskEnabled:     false, // 默认不监控磁盘
 			DiskThreshold:   10240, // 10GB阈值
 		},
+		Memory: MemoryConfig{
+			MaxMemoryMB:     0, // Unlimited
+			MaxMemoryPolicy: string(database.MaxMemoryPolicyNoEviction),
+
+			AutoLoad:           string(database.AutoLoadEager),
+			MaxConcurrentLoads: 2,
+		},
+		Backup: BackupConfig{
+			Dir: persistence.DefaultBackupDir,
+			// No retention rule keeps every backup
+		},
+		Replication: ReplicationConfig{
+			BacklogMB: 64, // Consistent with replication.DefaultBacklogSize
+		},
+		CDC: CDCConfig{
+			BacklogMB: 64, // Consistent with cdc.DefaultBacklogSize
+		},
+		Webhook: WebhookConfig{
+			DeadLetterFile: "webhooks-dead-letter.jsonl",
+		},
 	}
 }
 
@@ -211,6 +356,9 @@ func (c *Config) Validate() error {
 	if c.Server.HTTPPort <= 0 || c.Server.HTTPPort > 65535 {
 		return fmt.Errorf("invalid HTTP port: %d", c.Server.HTTPPort)
 	}
+	if c.Server.RESPPort < 0 || c.Server.RESPPort > 65535 {
+		return fmt.Errorf("invalid RESP port: %d", c.Server.RESPPort)
+	}
 	if len(c.Server.Passwords) == 0 {
 		return fmt.Errorf("at least one password must be configured")
 	}
@@ -248,6 +396,10 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid AOF sync strategy: %s", c.Persistence.AOFSyncStrategy)
 	}
 
+	if _, err := rdb.ParseCompression(c.Persistence.RDBCompression); err != nil {
+		return err
+	}
+
 	if c.Persistence.RDBIntervalMinutes < 0 {
 		return fmt.Errorf("RDB interval minutes must be non-negative: %d", c.Persistence.RDBIntervalMinutes)
 	}
@@ -303,6 +455,94 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate memory config
+	if c.Memory.MaxMemoryMB < 0 {
+		return fmt.Errorf("maxmemory must be non-negative: %d", c.Memory.MaxMemoryMB)
+	}
+	if _, err := database.ParseMaxMemoryPolicy(c.Memory.MaxMemoryPolicy); err != nil {
+		return err
+	}
+	if _, err := database.ParseAutoLoadPolicy(c.Memory.AutoLoad); err != nil {
+		return err
+	}
+	if c.Memory.MaxConcurrentLoads < 0 {
+		return fmt.Errorf("max concurrent loads must be non-negative: %d", c.Memory.MaxConcurrentLoads)
+	}
+
+	// Validate backup config
+	if c.Backup.KeepLast < 0 || c.Backup.KeepDaily < 0 || c.Backup.KeepWeekly < 0 {
+		return fmt.Errorf("backup retention counts must be non-negative")
+	}
+	if c.Backup.IntervalMinutes < 0 {
+		return fmt.Errorf("backup interval must be non-negative: %d", c.Backup.IntervalMinutes)
+	}
+	names := make(map[string]bool, len(c.Backup.Targets))
+	for _, target := range c.ToBackupTargets() {
+		switch target.Type {
+		case "dir":
+			if target.Path == "" {
+				return fmt.Errorf("backup target '%s' needs a path", target.Name)
+			}
+		case "s3":
+			if target.Endpoint == "" || target.Bucket == "" {
+				return fmt.Errorf("backup target '%s' needs an endpoint and a bucket", target.Name)
+			}
+			if target.PartSize != 0 && target.PartSize < backup.MinPartSize {
+				return fmt.Errorf("backup target '%s' part size must be at least 5 MB", target.Name)
+			}
+		default:
+			return fmt.Errorf("unknown backup target type '%s', expected dir or s3", target.Type)
+		}
+		if names[target.Name] {
+			return fmt.Errorf("duplicate backup target name '%s'", target.Name)
+		}
+		names[target.Name] = true
+	}
+
+	// Validate encryption config
+	if c.Encryption.KeyFile != "" && c.Encryption.KeyEnv != "" {
+		return fmt.Errorf("encryption key_file and key_env are mutually exclusive")
+	}
+
+	// Validate replication config
+	if c.Replication.BacklogMB < 0 {
+		return fmt.Errorf("replication backlog must be non-negative: %d MB", c.Replication.BacklogMB)
+	}
+
+	// Validate cluster config
+	if c.Cluster.Enabled {
+		if c.Cluster.NodeID == "" {
+			return fmt.Errorf("cluster node_id is required in cluster mode")
+		}
+		if _, _, err := net.SplitHostPort(c.Cluster.RaftBind); err != nil {
+			return fmt.Errorf("invalid cluster raft_bind '%s': %w", c.Cluster.RaftBind, err)
+		}
+		if c.Replication.ReplicaOf != "" {
+			return fmt.Errorf("replication replica_of cannot be used in cluster mode")
+		}
+		if c.Cluster.SnapshotIntervalSeconds < 0 {
+			return fmt.Errorf("cluster snapshot interval must be non-negative: %d", c.Cluster.SnapshotIntervalSeconds)
+		}
+	}
+
+	// Validate sharding config
+	if c.Sharding.RequestTimeoutSeconds < 0 {
+		return fmt.Errorf("sharding request timeout must be non-negative: %d", c.Sharding.RequestTimeoutSeconds)
+	}
+
+	// Validate cdc config
+	if c.CDC.BacklogMB < 0 {
+		return fmt.Errorf("cdc backlog must be non-negative: %d MB", c.CDC.BacklogMB)
+	}
+
+	// Validate webhook config
+	if c.Webhook.RetryBackoffSeconds < 0 {
+		return fmt.Errorf("webhook retry backoff must be non-negative: %d", c.Webhook.RetryBackoffSeconds)
+	}
+	if err := c.ToWebhookConfig().Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -316,6 +556,23 @@ func (c *Config) resolvePaths(configFilePath string) error {
 		c.Persistence.DataDir = filepath.Join(rootDir, c.Persistence.DataDir)
 	}
 
+	// Resolve directory backup target paths
+	for i, target := range c.Backup.Targets {
+		if target.Type == "dir" && target.Path != "" && !filepath.IsAbs(target.Path) {
+			c.Backup.Targets[i].Path = filepath.Join(rootDir, target.Path)
+		}
+	}
+
+	// Resolve encryption key file path
+	if c.Encryption.KeyFile != "" && !filepath.IsAbs(c.Encryption.KeyFile) {
+		c.Encryption.KeyFile = filepath.Join(rootDir, c.Encryption.KeyFile)
+	}
+
+	// Resolve cluster data directory path
+	if c.Cluster.DataDir != "" && !filepath.IsAbs(c.Cluster.DataDir) {
+		c.Cluster.DataDir = filepath.Join(rootDir, c.Cluster.DataDir)
+	}
+
 	return nil
 }
 
@@ -329,9 +586,9 @@ func (c *Config) GetRDBPath() string {
 	return filepath.Join(c.Persistence.DataDir, c.Persistence.RDBFilename)
 }
 
-// GetAOFPath returns the full path to the AOF file.
+// GetAOFPath returns the full path to the AOF directory.
 func (c *Config) GetAOFPath() string {
-	return filepath.Join(c.Persistence.DataDir, c.Persistence.AOFFilename)
+	return filepath.Join(c.Persistence.DataDir, c.Persistence.AOFDirname)
 }
 
 // GetGRPCAddress returns the gRPC server address.
@@ -344,6 +601,14 @@ func (c *Config) GetHTTPAddress() string {
 	return fmt.Sprintf("%s:%d", c.Server.HTTPHost, c.Server.HTTPPort)
 }
 
+// GetRESPAddress returns the RESP server address, empty if the listener is disabled.
+func (c *Config) GetRESPAddress() string {
+	if c.Server.RESPPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.Server.RESPHost, c.Server.RESPPort)
+}
+
 // GetMetricsAddress returns the metrics server address.
 func (c *Config) GetMetricsAddress() string {
 	return fmt.Sprintf(":%d", c.Observability.MetricsPort)
@@ -380,6 +645,7 @@ func (c *Config) Clone() *Config {
 	// Deep copy slices
 	clone.Server.Passwords = make([]string, len(c.Server.Passwords))
 	copy(clone.Server.Passwords, c.Server.Passwords)
+	clone.Backup.Targets = append([]BackupTargetConfig(nil), c.Backup.Targets...)
 
 	return &clone
 }
@@ -390,13 +656,163 @@ func (c *Config) ToPersistenceConfig(logger core.Logger) persistence.Config {
 		DataDir:         c.Persistence.DataDir,
 		RDBFilename:     c.Persistence.RDBFilename,
 		AOFFilename:     c.Persistence.AOFFilename,
+		AOFDirname:      c.Persistence.AOFDirname,
+		AOFArchiveDir:   c.Persistence.AOFArchiveDir,
 		AOFSyncStrategy: c.Persistence.AOFSyncStrategy,
+		RDBCompression:  c.Persistence.RDBCompression,
+		BackupDir:       c.Backup.Dir,
+		BackupRetention: c.ToBackupRetention(),
+		BackupTargets:   c.ToBackupTargets(),
+		BackupInterval:  time.Duration(c.Backup.IntervalMinutes) * time.Minute,
 		RDBInterval:     time.Duration(c.Persistence.RDBIntervalMinutes) * time.Minute,
 		AOFRewriteSize:  int64(c.Persistence.AOFRewriteSizeMB) * 1024 * 1024, // Convert MB to bytes
 		Logger:          logger,
 	}
 }
 
+// ToBackupRetention converts the backup config to the retention policy applied to backups
+func (c *Config) ToBackupRetention() rdb.RetentionPolicy {
+	return rdb.RetentionPolicy{
+		KeepLast:   c.Backup.KeepLast,
+		KeepDaily:  c.Backup.KeepDaily,
+		KeepWeekly: c.Backup.KeepWeekly,
+	}
+}
+
+// ToBackupTargets converts the backup config to the targets backups are copied to
+func (c *Config) ToBackupTargets() []backup.TargetConfig {
+	targets := make([]backup.TargetConfig, 0, len(c.Backup.Targets))
+	for _, target := range c.Backup.Targets {
+		name := target.Name
+		if name == "" {
+			name = target.Type
+		}
+		targets = append(targets, backup.TargetConfig{
+			Name:      name,
+			Type:      target.Type,
+			Path:      target.Path,
+			Endpoint:  target.Endpoint,
+			Region:    target.Region,
+			Bucket:    target.Bucket,
+			Prefix:    target.Prefix,
+			AccessKey: target.AccessKey,
+			SecretKey: target.SecretKey,
+			PathStyle: target.PathStyle,
+			PartSize:  int64(target.PartSizeMB) * 1024 * 1024, // Convert MB to bytes
+		})
+	}
+	return targets
+}
+
+// ToKeyProvider loads the encryption keys from the key file or environment variable.
+// It returns nil if encryption is not configured.
+func (c *Config) ToKeyProvider() (encryption.KeyProvider, error) {
+	return encryption.NewKeyProvider(c.Encryption.KeyFile, c.Encryption.KeyEnv)
+}
+
+// ToReplicationConfig converts the replication config to the one the server follows its
+// primary and serves its replicas with
+func (c *Config) ToReplicationConfig() replication.Config {
+	return replication.Config{
+		ReplicaOf:       c.Replication.ReplicaOf,
+		PrimaryPassword: c.Replication.PrimaryPassword,
+		ReplicaName:     c.Replication.ReplicaName,
+		BacklogSize:     int64(c.Replication.BacklogMB) * 1024 * 1024, // Convert MB to bytes
+	}
+}
+
+// ToClusterConfig converts the cluster config to the one the server joins its Raft group
+// with. Cluster mode is off, with an empty node ID, unless it is enabled.
+func (c *Config) ToClusterConfig() cluster.Config {
+	if !c.Cluster.Enabled {
+		return cluster.Config{}
+	}
+
+	address := c.Cluster.GRPCAdvertise
+	if address == "" {
+		address = net.JoinHostPort(c.Server.GRPCHost, strconv.Itoa(c.Server.GRPCPort))
+	}
+	dataDir := c.Cluster.DataDir
+	if dataDir == "" {
+		dataDir = filepath.Join(c.Persistence.DataDir, "raft")
+	}
+
+	return cluster.Config{
+		NodeID:            c.Cluster.NodeID,
+		BindAddr:          c.Cluster.RaftBind,
+		AdvertiseAddr:     c.Cluster.RaftAdvertise,
+		Address:           address,
+		DataDir:           dataDir,
+		Bootstrap:         c.Cluster.Bootstrap,
+		SnapshotThreshold: c.Cluster.SnapshotThreshold,
+		SnapshotInterval:  time.Duration(c.Cluster.SnapshotIntervalSeconds) * time.Second,
+	}
+}
+
+// ToShardingConfig converts the sharding config to the one the server connects to shards with
+func (c *Config) ToShardingConfig() sharding.Config {
+	return sharding.Config{
+		Password: c.Sharding.Password,
+		Timeout:  time.Duration(c.Sharding.RequestTimeoutSeconds) * time.Second,
+	}
+}
+
+// ToCDCConfig converts the cdc config to the one the server keeps change events with
+func (c *Config) ToCDCConfig() cdc.Config {
+	return cdc.Config{
+		BacklogSize: int64(c.CDC.BacklogMB) * 1024 * 1024, // Convert MB to bytes
+	}
+}
+
+// ToWebhookConfig converts the webhook config to the one the server delivers change events with
+func (c *Config) ToWebhookConfig() webhook.Config {
+	config := webhook.Config{
+		RetryBackoff: time.Duration(c.Webhook.RetryBackoffSeconds) * time.Second,
+	}
+	if c.Webhook.DeadLetterFile != "" {
+		config.DeadLetterPath = c.Webhook.DeadLetterFile
+		if !filepath.IsAbs(config.DeadLetterPath) {
+			config.DeadLetterPath = filepath.Join(c.Persistence.DataDir, config.DeadLetterPath)
+		}
+	}
+	for _, hook := range c.Webhook.Hooks {
+		config.Hooks = append(config.Hooks, webhook.HookConfig{
+			Name:           hook.Name,
+			URL:            hook.URL,
+			Secret:         hook.Secret,
+			Database:       hook.Database,
+			Collection:     hook.Collection,
+			Events:         hook.Events,
+			IncludeVectors: hook.IncludeVectors,
+			MaxRetries:     hook.MaxRetries,
+			Timeout:        time.Duration(hook.TimeoutSeconds) * time.Second,
+		})
+	}
+	return config
+}
+
+// ToMemoryLimit converts the memory config to the engine memory limit.
+func (c *Config) ToMemoryLimit() database.MemoryLimit {
+	policy, _ := database.ParseMaxMemoryPolicy(c.Memory.MaxMemoryPolicy) // Validated on load
+
+	return database.MemoryLimit{
+		MaxBytes: int64(c.Memory.MaxMemoryMB) * 1024 * 1024, // Convert MB to bytes
+		Policy:   policy,
+	}
+}
+
+// ToLoadPolicy converts the memory config to the engine collection load policy.
+// Released and evicted collections are written below the data directory.
+func (c *Config) ToLoadPolicy() database.LoadPolicy {
+	autoLoad, _ := database.ParseAutoLoadPolicy(c.Memory.AutoLoad) // Validated on load
+
+	return database.LoadPolicy{
+		AutoLoad:           autoLoad,
+		MaxConcurrentLoads: c.Memory.MaxConcurrentLoads,
+		SegmentDir:         filepath.Join(c.Persistence.DataDir, "segments"),
+	}
+}
+
 // ToEmbeddingConfig converts config.EmbeddingConfig to embedding.Config
 func (c *Config) ToEmbeddingConfig() embedding.Config {
 	embeddingModels := make([]embedding.EmbeddingModel, len(c.Embedding.Models))
@@ -188,9 +188,13 @@ func (h *HNSW) Insert(ctx context.Context, vector types.Vector) error {
 
 // insertVector performs the actual vector insertion (must be called with lock held)
 func (h *HNSW) insertVector(vector types.Vector) error {
-	// Check if vector already exists
-	if _, exists := h.nodes[vector.ID]; exists {
-		return utils.ErrInvalidParameters(fmt.Sprintf("vector with ID %d already exists", vector.ID))
+	// Check if vector already exists. A deleted node makes way for the new vector; the
+	// links other nodes still have to it lead to the new node.
+	if existing, exists := h.nodes[vector.ID]; exists {
+		if !existing.Deleted {
+			return utils.ErrInvalidParameters(fmt.Sprintf("vector with ID %d already exists", vector.ID))
+		}
+		delete(h.nodes, vector.ID)
 	}
 
 	// Determine the layer for this node
@@ -234,6 +238,9 @@ func (h *HNSW) insertVector(vector types.Vector) error {
 
 		// Add connections
 		for _, neighborID := range selectedNeighbors {
+			if neighborID == vector.ID {
+				continue // Found through a link to the deleted node it replaces
+			}
 			node.AddConnection(lc, neighborID)
 
 			// Add reverse connection
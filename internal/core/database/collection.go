@@ -4,34 +4,49 @@ package database
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/scintirete/scintirete/internal/core"
-	"github.com/scintirete/scintirete/internal/core/algorithm"
 	"github.com/scintirete/scintirete/internal/utils"
 	"github.com/scintirete/scintirete/pkg/types"
 )
 
-// Collection represents a collection of vectors with an associated index
+// Collection represents a collection of vectors, split into named partitions
+// that each have their own index
 type Collection struct {
 	mu         sync.RWMutex
 	name       string
 	config     types.CollectionConfig
 	vectors    map[uint64]*types.Vector // vector ID -> vector
 	deletedIDs map[uint64]bool          // soft deletion tracking
-	index      core.VectorIndex
+	partitions map[string]*partition    // partition name -> partition, always contains the default partition
 	createdAt  time.Time
 	updatedAt  time.Time
 
 	// ID generation
 	nextID uint64 // Auto-incrementing ID counter
 
+	// TTL tracking
+	expiring   map[uint64]int64 // live vector ID -> expire_at (Unix seconds)
+	nextExpiry int64            // earliest expire_at in expiring, 0 if none
+
 	// Statistics
 	vectorCount  int64
 	deletedCount int64
 	memoryBytes  int64
+
+	// Recency of searches, used for least recently searched eviction (UnixNano)
+	lastSearchAt atomic.Int64
+
+	// Snapshot cut that still has to copy this collection, see preserveLocked
+	cut atomic.Pointer[snapshotCut]
+
+	// Set once spillCollection moved the collection to a segment, see checkOpenLocked
+	spilled bool
 }
 
 // NewCollection creates a new collection with the specified configuration
@@ -52,18 +67,21 @@ func NewCollection(name string, config types.CollectionConfig) (*Collection, err
 		config:     config,
 		vectors:    make(map[uint64]*types.Vector),
 		deletedIDs: make(map[uint64]bool),
+		partitions: make(map[string]*partition),
+		expiring:   make(map[uint64]int64),
 		createdAt:  now,
 		updatedAt:  now,
 		nextID:     1, // Start ID generation from 1
 	}
 
-	// Create index based on configuration
-	index, err := algorithm.NewHNSW(config.HNSWParams, config.Metric)
+	// Create the default partition and its index based on configuration
+	defaultPartition, err := newPartition(types.DefaultPartitionName, config)
 	if err != nil {
-		return nil, utils.ErrInvalidInput("failed to create HNSW index: " + err.Error())
+		return nil, err
 	}
+	defaultPartition.createdAt = now
 
-	collection.index = index
+	collection.partitions[types.DefaultPartitionName] = defaultPartition
 	return collection, nil
 }
 
@@ -72,10 +90,16 @@ func (c *Collection) Insert(ctx context.Context, vectors []types.Vector) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if err := c.checkOpenLocked(); err != nil {
+		return err
+	}
+
 	if len(vectors) == 0 {
 		return utils.ErrInvalidInput("no vectors provided")
 	}
 
+	c.preserveLocked()
+
 	// Validate dimensions
 	if c.vectorCount > 0 {
 		// Existing collection - check dimensions match
@@ -108,34 +132,81 @@ func (c *Collection) Insert(ctx context.Context, vectors []types.Vector) error {
 		}
 	}
 
+	// Resolve target partitions up front so a bad partition name inserts nothing
+	targets := make([]*partition, len(vectors))
+	for i := range vectors {
+		p, exists := c.partitions[partitionNameOf(&vectors[i])]
+		if !exists {
+			return utils.ErrPartitionNotFound(c.name, vectors[i].Partition)
+		}
+		targets[i] = p
+	}
+
+	// Vectors with an ID keep it, such as those the coordinator of a sharded collection
+	// routes to a shard, or those of a replayed log. It must not be taken by a live vector;
+	// a deleted one makes way, as when a resharding moves a vector back to a shard.
+	ids := make(map[uint64]bool)
+	for i := range vectors {
+		id := vectors[i].ID
+		if id == 0 {
+			continue
+		}
+		if _, exists := c.vectors[id]; (exists && !c.deletedIDs[id]) || ids[id] {
+			return utils.ErrInvalidInput(fmt.Sprintf("vector[%d]: ID %d already exists", i, id))
+		}
+		ids[id] = true
+	}
+
 	// Insert vectors with auto-generated IDs
 	var insertedCount int64
+	now := time.Now()
 	for i := range vectors {
-		// Generate new unique ID for each vector
-		newID := c.nextID
-		c.nextID++
+		// Generate new unique ID for each vector without one
+		newID := vectors[i].ID
+		if newID == 0 {
+			for ids[c.nextID] {
+				c.nextID++
+			}
+			newID = c.nextID
+		}
+		c.nextID = max(c.nextID, newID+1)
+
+		// Resolve expiry: explicit expire_at wins, otherwise fall back to the collection default
+		expireAt := vectors[i].ExpireAt
+		if expireAt == 0 && c.config.DefaultTTLSeconds > 0 {
+			expireAt = now.Unix() + c.config.DefaultTTLSeconds
+		}
 
 		// Create a copy with auto-generated ID
 		vectorCopy := types.Vector{
-			ID:       newID,
-			Elements: make([]float32, len(vectors[i].Elements)),
-			Metadata: make(map[string]interface{}),
+			ID:        newID,
+			Elements:  make([]float32, len(vectors[i].Elements)),
+			Metadata:  make(map[string]interface{}),
+			ExpireAt:  expireAt,
+			Partition: storedPartitionName(vectors[i].Partition),
 		}
 		copy(vectorCopy.Elements, vectors[i].Elements)
 		for k, v := range vectors[i].Metadata {
 			vectorCopy.Metadata[k] = v
 		}
 
-		// Always insert as new vector (no ID conflicts since we generate unique IDs)
+		// Replace a deleted vector of the same ID (IDs were checked to be unique above)
+		if replaced, exists := c.vectors[newID]; exists {
+			c.forgetDeletedLocked(replaced)
+		}
 		c.vectors[newID] = &vectorCopy
 		insertedCount++
 
-		// Update the original vector with the generated ID for caller reference
+		// Update the original vector with the generated ID and resolved expiry for caller reference,
+		// so the AOF records an absolute expire_at and replay stays deterministic
 		vectors[i].ID = newID
+		vectors[i].ExpireAt = expireAt
+		c.trackExpiry(newID, expireAt)
 
-		// Add to index
-		if c.index != nil {
-			if err := c.index.Insert(ctx, vectorCopy); err != nil {
+		// Add to the partition index; released partitions are indexed when loaded again
+		targets[i].vectorCount++
+		if targets[i].loaded() {
+			if err := targets[i].index.Insert(ctx, vectorCopy); err != nil {
 				return utils.ErrIndexOperationFailed("failed to insert into index: " + err.Error())
 			}
 		}
@@ -148,59 +219,247 @@ func (c *Collection) Insert(ctx context.Context, vectors []types.Vector) error {
 	return nil
 }
 
+// forgetDeletedLocked drops the counts of a deleted vector about to be replaced. Its node
+// stays in the index, where a new vector of the same ID replaces it. Caller must hold c.mu.
+func (c *Collection) forgetDeletedLocked(vector *types.Vector) {
+	delete(c.deletedIDs, vector.ID)
+	c.deletedCount--
+	c.vectorCount--
+	if p, exists := c.partitions[partitionNameOf(vector)]; exists {
+		p.deletedCount--
+		p.vectorCount--
+	}
+}
+
 // Delete marks vectors as deleted by their IDs
 func (c *Collection) Delete(ctx context.Context, ids []string) (int, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return c.DeleteFromPartitions(ctx, ids, nil)
+}
 
-	if len(ids) == 0 {
-		return 0, utils.ErrInvalidInput("no IDs provided")
+// deleteLocked tombstones a single vector. Caller must hold c.mu.
+func (c *Collection) deleteLocked(ctx context.Context, id uint64) (bool, error) {
+	vector, exists := c.vectors[id]
+	if !exists {
+		return false, nil // Skip non-existent vectors
 	}
 
-	var deletedCount int
-	for _, idStr := range ids {
-		// Convert string ID to uint64
-		id, err := strconv.ParseUint(idStr, 10, 64)
+	if c.deletedIDs[id] {
+		return false, nil
+	}
+
+	c.deletedIDs[id] = true
+	c.deletedCount++
+	delete(c.expiring, id)
+
+	// Remove from the partition index
+	p, exists := c.partitions[partitionNameOf(vector)]
+	if !exists {
+		return true, nil
+	}
+	p.deletedCount++
+	if p.loaded() {
+		if err := p.index.Delete(ctx, strconv.FormatUint(id, 10)); err != nil {
+			return true, utils.ErrIndexOperationFailed("failed to delete from index: " + err.Error())
+		}
+	}
+
+	return true, nil
+}
+
+// Search finds the most similar vectors to the query
+func (c *Collection) Search(ctx context.Context, query []float32, params types.SearchParams) ([]types.SearchResult, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return nil, err
+	}
+	c.lastSearchAt.Store(time.Now().UnixNano())
+
+	targets, err := c.searchTargetsLocked(params.Partitions)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return []types.SearchResult{}, nil
+	}
+
+	// Fast path: a single partition without TTL vectors
+	// The index already handles deleted vectors and sorts results by distance
+	if len(targets) == 1 && len(c.expiring) == 0 {
+		results, err := targets[0].index.Search(ctx, query, params)
 		if err != nil {
-			continue // Skip invalid IDs
+			return nil, err
+		}
+		for i := range results {
+			results[i].Vector.Partition = storedPartitionName(targets[0].name)
 		}
+		return results, nil
+	}
 
-		if _, exists := c.vectors[id]; !exists {
-			continue // Skip non-existent vectors
+	// Expired vectors may still be in the index until the expirer tombstones them,
+	// so widen the search by the number of pending expirations and filter them out
+	now := time.Now()
+	pending := c.countExpiredLocked(now)
+	searchParams := params
+	if pending > 0 {
+		searchParams.TopK = params.TopK + pending
+		ef := c.config.HNSWParams.EfSearch
+		if params.EfSearch != nil && *params.EfSearch > 0 {
+			ef = *params.EfSearch
 		}
+		if ef < searchParams.TopK {
+			ef = searchParams.TopK
+		}
+		searchParams.EfSearch = &ef
+	}
 
-		if !c.deletedIDs[id] {
-			c.deletedIDs[id] = true
-			c.deletedCount++
-			deletedCount++
-
-			// Remove from index
-			if c.index != nil {
-				if err := c.index.Delete(ctx, idStr); err != nil {
-					return deletedCount, utils.ErrIndexOperationFailed("failed to delete from index: " + err.Error())
-				}
-			}
+	// Search every target partition and merge the results by distance
+	var results []types.SearchResult
+	for _, p := range targets {
+		partitionResults, err := p.index.Search(ctx, query, searchParams)
+		if err != nil {
+			return nil, err
 		}
+		results = append(results, partitionResults...)
 	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
 
-	c.updatedAt = time.Now()
-	c.updateMemoryUsage()
+	filtered := make([]types.SearchResult, 0, min(len(results), params.TopK))
+	for _, result := range results {
+		vector, exists := c.vectors[result.Vector.ID]
+		if !exists || vector.IsExpired(now) {
+			continue
+		}
+		result.Vector.ExpireAt = vector.ExpireAt
+		result.Vector.Partition = vector.Partition
+		filtered = append(filtered, result)
+		if len(filtered) >= params.TopK {
+			break
+		}
+	}
 
-	return deletedCount, nil
+	return filtered, nil
 }
 
-// Search finds the most similar vectors to the query
-func (c *Collection) Search(ctx context.Context, query []float32, params types.SearchParams) ([]types.SearchResult, error) {
+// ExpireVectors tombstones every vector whose TTL has elapsed at the given time.
+// It returns the expired IDs in ascending order so callers can log them deterministically.
+func (c *Collection) ExpireVectors(ctx context.Context, now time.Time) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checkOpenLocked() != nil {
+		return nil, nil // Released or closed, nothing is left to expire
+	}
+
+	if c.nextExpiry == 0 || c.nextExpiry > now.Unix() {
+		return nil, nil
+	}
+
+	c.preserveLocked()
+
+	expiredIDs := c.expiredIDsLocked(now)
+	ids := make([]string, 0, len(expiredIDs))
+	for _, id := range expiredIDs {
+		deleted, err := c.deleteLocked(ctx, id)
+		if err != nil {
+			return ids, err
+		}
+		if deleted {
+			ids = append(ids, strconv.FormatUint(id, 10))
+		}
+	}
+
+	c.refreshNextExpiry()
+	if len(ids) > 0 {
+		c.updatedAt = time.Now()
+		c.updateMemoryUsage()
+	}
+
+	return ids, nil
+}
+
+// ExpiredIDs returns the IDs of the live vectors whose TTL has elapsed at the given time in
+// ascending order, without tombstoning them
+func (c *Collection) ExpiredIDs(now time.Time) []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if c.index == nil {
-		return nil, utils.ErrInvalidInput("index not initialized")
+	if c.checkOpenLocked() != nil {
+		return nil // Released or closed, nothing is left to expire
 	}
 
-	// Perform search using the index
-	// The index already handles deleted vectors and sorts results by distance
-	return c.index.Search(ctx, query, params)
+	if c.nextExpiry == 0 || c.nextExpiry > now.Unix() {
+		return nil
+	}
+
+	expiredIDs := c.expiredIDsLocked(now)
+	ids := make([]string, len(expiredIDs))
+	for i, id := range expiredIDs {
+		ids[i] = strconv.FormatUint(id, 10)
+	}
+	return ids
+}
+
+// expiredIDsLocked returns the IDs of live vectors whose TTL has elapsed in ascending
+// order. Caller must hold c.mu.
+func (c *Collection) expiredIDsLocked(now time.Time) []uint64 {
+	expiredIDs := make([]uint64, 0)
+	for id, expireAt := range c.expiring {
+		if expireAt <= now.Unix() {
+			expiredIDs = append(expiredIDs, id)
+		}
+	}
+	sort.Slice(expiredIDs, func(i, j int) bool { return expiredIDs[i] < expiredIDs[j] })
+	return expiredIDs
+}
+
+// countExpiredLocked counts live vectors whose TTL has elapsed. Caller must hold c.mu.
+func (c *Collection) countExpiredLocked(now time.Time) int {
+	if c.nextExpiry == 0 || c.nextExpiry > now.Unix() {
+		return 0
+	}
+
+	count := 0
+	for _, expireAt := range c.expiring {
+		if expireAt <= now.Unix() {
+			count++
+		}
+	}
+	return count
+}
+
+// trackExpiry registers a live vector's expiry. Caller must hold c.mu.
+func (c *Collection) trackExpiry(id uint64, expireAt int64) {
+	if expireAt <= 0 {
+		return
+	}
+
+	c.expiring[id] = expireAt
+	if c.nextExpiry == 0 || expireAt < c.nextExpiry {
+		c.nextExpiry = expireAt
+	}
+}
+
+// refreshNextExpiry recomputes the earliest pending expiry. Caller must hold c.mu.
+func (c *Collection) refreshNextExpiry() {
+	c.nextExpiry = 0
+	for _, expireAt := range c.expiring {
+		if c.nextExpiry == 0 || expireAt < c.nextExpiry {
+			c.nextExpiry = expireAt
+		}
+	}
+}
+
+// rebuildExpiry rebuilds TTL tracking from the stored vectors. Caller must hold c.mu.
+func (c *Collection) rebuildExpiry() {
+	c.expiring = make(map[uint64]int64)
+	c.nextExpiry = 0
+	for id, vector := range c.vectors {
+		if !c.deletedIDs[id] {
+			c.trackExpiry(id, vector.ExpireAt)
+		}
+	}
 }
 
 // Get retrieves a vector by ID
@@ -208,6 +467,10 @@ func (c *Collection) Get(ctx context.Context, id string) (*types.Vector, error)
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if err := c.checkOpenLocked(); err != nil {
+		return nil, err
+	}
+
 	// Convert string ID to uint64
 	vectorID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
@@ -219,22 +482,12 @@ func (c *Collection) Get(ctx context.Context, id string) (*types.Vector, error)
 		return nil, utils.ErrVectorNotFound(id)
 	}
 
-	if c.deletedIDs[vectorID] {
+	if c.deletedIDs[vectorID] || vector.IsExpired(time.Now()) {
 		return nil, utils.ErrVectorNotFound(id)
 	}
 
 	// Return a copy to prevent external mutation
-	vectorCopy := types.Vector{
-		ID:       vector.ID,
-		Elements: make([]float32, len(vector.Elements)),
-		Metadata: make(map[string]interface{}),
-	}
-	copy(vectorCopy.Elements, vector.Elements)
-	for k, v := range vector.Metadata {
-		vectorCopy.Metadata[k] = v
-	}
-
-	return &vectorCopy, nil
+	return copyVector(vector), nil
 }
 
 // Count returns the total number of vectors in the collection (excluding deleted)
@@ -242,6 +495,10 @@ func (c *Collection) Count(ctx context.Context) (int64, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if err := c.checkOpenLocked(); err != nil {
+		return 0, err
+	}
+
 	return c.vectorCount - c.deletedCount, nil
 }
 
@@ -250,7 +507,12 @@ func (c *Collection) GetMultiple(ctx context.Context, ids []string) ([]types.Vec
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if err := c.checkOpenLocked(); err != nil {
+		return nil, err
+	}
+
 	var results []types.Vector
+	now := time.Now()
 	for _, idStr := range ids {
 		// Convert string ID to uint64
 		id, err := strconv.ParseUint(idStr, 10, 64)
@@ -259,53 +521,115 @@ func (c *Collection) GetMultiple(ctx context.Context, ids []string) ([]types.Vec
 		}
 
 		vector, exists := c.vectors[id]
-		if !exists || c.deletedIDs[id] {
-			continue // Skip deleted or non-existent vectors
+		if !exists || c.deletedIDs[id] || vector.IsExpired(now) {
+			continue // Skip deleted, expired or non-existent vectors
 		}
 
 		// Return a copy to prevent external mutation
-		vectorCopy := types.Vector{
-			ID:       vector.ID,
-			Elements: make([]float32, len(vector.Elements)),
-			Metadata: make(map[string]interface{}),
+		results = append(results, *copyVector(vector))
+	}
+
+	return results, nil
+}
+
+// ExistingIDs returns the IDs among ids that the collection holds live, so that inserting
+// them again would fail
+func (c *Collection) ExistingIDs(ids []uint64) []uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.checkOpenLocked() != nil {
+		return nil
+	}
+
+	var existing []uint64
+	for _, id := range ids {
+		if _, exists := c.vectors[id]; exists && !c.deletedIDs[id] {
+			existing = append(existing, id)
 		}
-		copy(vectorCopy.Elements, vector.Elements)
-		for k, v := range vector.Metadata {
-			vectorCopy.Metadata[k] = v
+	}
+	return existing
+}
+
+// Scan returns up to limit live vectors with an ID greater than afterID in ascending
+// order of their IDs. Passing the last ID returned pages through the collection.
+func (c *Collection) Scan(ctx context.Context, afterID uint64, limit int) ([]types.Vector, error) {
+	if limit <= 0 {
+		return nil, utils.ErrInvalidInput("scan limit must be positive")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ids := make([]uint64, 0)
+	for id, vector := range c.vectors {
+		if id > afterID && !c.deletedIDs[id] && !vector.IsExpired(now) {
+			ids = append(ids, id)
 		}
-		results = append(results, vectorCopy)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
 	}
 
+	results := make([]types.Vector, len(ids))
+	for i, id := range ids {
+		results[i] = *copyVector(c.vectors[id])
+	}
 	return results, nil
 }
 
-// Compact removes deleted vectors and rebuilds the index
+// GraphStates exports the HNSW graph of every loaded partition, by partition name.
+// Released partitions have no graph and are left out.
+func (c *Collection) GraphStates(ctx context.Context) (map[string]core.HNSWGraphState, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return nil, err
+	}
+
+	graphs := make(map[string]core.HNSWGraphState, len(c.partitions))
+	for name, p := range c.partitions {
+		if !p.loaded() {
+			continue
+		}
+		if hnswIndex, ok := p.index.(core.HNSWIndex); ok {
+			graphs[name] = hnswIndex.ExportGraphState()
+		}
+	}
+	return graphs, nil
+}
+
+// Compact removes deleted vectors and rebuilds the index of every partition
 func (c *Collection) Compact(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Remove deleted vectors from memory
+	if err := c.checkOpenLocked(); err != nil {
+		return err
+	}
+
+	c.preserveLocked()
+	for _, name := range c.sortedPartitionNamesLocked() {
+		if err := c.compactPartitionLocked(ctx, c.partitions[name]); err != nil {
+			return err
+		}
+	}
+
+	// Drop tombstones that no longer belong to any partition
 	for id := range c.deletedIDs {
 		delete(c.vectors, id)
 	}
-
-	// Clear deleted IDs
 	c.deletedIDs = make(map[uint64]bool)
 	c.deletedCount = 0
 	c.vectorCount = int64(len(c.vectors))
 
-	// Rebuild index if it exists
-	if c.index != nil {
-		vectors := make([]types.Vector, 0, len(c.vectors))
-		for _, vector := range c.vectors {
-			vectors = append(vectors, *vector)
-		}
-
-		if err := c.index.Build(ctx, vectors); err != nil {
-			return utils.ErrIndexOperationFailed("failed to rebuild index: " + err.Error())
-		}
-	}
-
 	c.updatedAt = time.Now()
 	c.updateMemoryUsage()
 
@@ -328,11 +652,27 @@ func (c *Collection) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.preserveLocked()
+
 	// Clear data structures
 	c.vectors = nil
 	c.deletedIDs = nil
-	c.index = nil
+	c.expiring = nil
+	c.partitions = nil
+
+	return nil
+}
 
+// checkOpenLocked fails operations on a closed collection, or on one spilled to a segment
+// while a request still held it, which a retry finds again through its database.
+// Caller must hold c.mu.
+func (c *Collection) checkOpenLocked() error {
+	if c.spilled {
+		return utils.ErrCollectionReleased(c.name)
+	}
+	if c.partitions == nil {
+		return utils.ErrCollectionOperationFailed("collection " + c.name + " is closed")
+	}
 	return nil
 }
 
@@ -341,6 +681,28 @@ func (c *Collection) Info() types.CollectionInfo {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	return c.infoLocked()
+}
+
+// MemoryUsage returns the estimated memory used by the collection's vectors and indexes
+func (c *Collection) MemoryUsage() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.memoryBytes
+}
+
+// LastSearchAt returns when the collection was last searched, or the zero time if never
+func (c *Collection) LastSearchAt() time.Time {
+	nanos := c.lastSearchAt.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// infoLocked returns metadata about this collection. Caller must hold c.mu.
+func (c *Collection) infoLocked() types.CollectionInfo {
 	var dimension int
 	if len(c.vectors) > 0 {
 		for _, vector := range c.vectors {
@@ -359,36 +721,63 @@ func (c *Collection) Info() types.CollectionInfo {
 		HNSWConfig:   c.config.HNSWParams,
 		CreatedAt:    c.createdAt,
 		UpdatedAt:    c.updatedAt,
+
+		DefaultTTLSeconds: c.config.DefaultTTLSeconds,
+		Partitions:        c.partitionInfosLocked(),
+		LoadState:         types.LoadStateLoaded,
 	}
 }
 
-// updateMemoryUsage calculates and updates the memory usage estimate
+// updateMemoryUsage calculates and updates the memory usage estimate,
+// both per partition and for the whole collection
 func (c *Collection) updateMemoryUsage() {
 	// Rough estimation of memory usage
-	var totalBytes int64
+	partitionBytes := make(map[string]int64, len(c.partitions))
 
 	// Vector data
-	for _, vector := range c.vectors {
-		totalBytes += 8                                // ID (uint64 = 8 bytes)
-		totalBytes += int64(len(vector.Elements) * 4)  // float32 elements
-		totalBytes += int64(len(vector.Metadata) * 32) // rough metadata size
-	}
+	for id, vector := range c.vectors {
+		vectorBytes := vectorMemoryBytes(vector)
 
-	// Deleted IDs map
-	for range c.deletedIDs {
-		totalBytes += 8 // uint64 = 8 bytes
+		// Deleted IDs map
+		if c.deletedIDs[id] {
+			vectorBytes += 8 // uint64 = 8 bytes
+		}
+
+		partitionBytes[partitionNameOf(vector)] += vectorBytes
 	}
 
-	// Index memory (rough estimation)
-	if c.index != nil {
-		// HNSW typically uses 4-8 bytes per vector per connection
-		avgConnections := c.config.HNSWParams.M * 2 // rough estimate
-		totalBytes += c.vectorCount * int64(avgConnections) * 8
+	var totalBytes int64
+	for name, p := range c.partitions {
+		bytes := partitionBytes[name]
+
+		// Index memory (rough estimation), only while the partition is loaded
+		if p.loaded() {
+			bytes += p.vectorCount * indexMemoryBytesPerVector(c.config.HNSWParams)
+		}
+
+		p.memoryBytes = bytes
+		totalBytes += bytes
 	}
 
 	c.memoryBytes = totalBytes
 }
 
+// vectorMemoryBytes estimates the memory used by a stored vector
+func vectorMemoryBytes(vector *types.Vector) int64 {
+	var bytes int64
+	bytes += 8                                // ID (uint64 = 8 bytes)
+	bytes += int64(len(vector.Elements) * 4)  // float32 elements
+	bytes += int64(len(vector.Metadata) * 32) // rough metadata size
+	return bytes
+}
+
+// indexMemoryBytesPerVector estimates the index memory used per indexed vector
+func indexMemoryBytesPerVector(params types.HNSWParams) int64 {
+	// HNSW typically uses 4-8 bytes per vector per connection
+	avgConnections := params.M * 2 // rough estimate
+	return int64(avgConnections) * 8
+}
+
 // validateCollectionConfig validates the collection configuration
 func validateCollectionConfig(config types.CollectionConfig) error {
 	if config.Name == "" {
@@ -408,5 +797,121 @@ func validateCollectionConfig(config types.CollectionConfig) error {
 		return utils.ErrInvalidInput("HNSW EfConstruction parameter must be positive")
 	}
 
+	if config.DefaultTTLSeconds < 0 {
+		return utils.ErrInvalidInput("default TTL must not be negative")
+	}
+
 	return nil
 }
+
+// Clone creates a deep copy of the collection under a new name.
+// The HNSW graph is exported and imported directly, so no index rebuild happens.
+func (c *Collection) Clone(name string) (*Collection, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return nil, err
+	}
+
+	config := c.config
+	config.Name = name
+
+	clone, err := NewCollection(name, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy vectors and soft deletion state
+	for id, vector := range c.vectors {
+		clone.vectors[id] = copyVector(vector)
+	}
+	for id, deleted := range c.deletedIDs {
+		clone.deletedIDs[id] = deleted
+	}
+	for id, expireAt := range c.expiring {
+		clone.expiring[id] = expireAt
+	}
+	clone.nextExpiry = c.nextExpiry
+
+	// Copy every partition's HNSW graph, pointing nodes at the cloned vector data
+	for partitionName, p := range c.partitions {
+		clonePartition, exists := clone.partitions[partitionName]
+		if !exists {
+			clonePartition, err = newPartition(partitionName, config)
+			if err != nil {
+				return nil, err
+			}
+			clone.partitions[partitionName] = clonePartition
+		}
+		clonePartition.createdAt = p.createdAt
+		clonePartition.vectorCount = p.vectorCount
+		clonePartition.deletedCount = p.deletedCount
+		clonePartition.memoryBytes = p.memoryBytes
+
+		if !p.loaded() {
+			clonePartition.index = nil
+			continue
+		}
+
+		hnswIndex, ok := p.index.(core.HNSWIndex)
+		if !ok {
+			return nil, utils.ErrIndexOperationFailed(fmt.Sprintf("collection %s does not use HNSW index", c.name))
+		}
+
+		graphState := hnswIndex.ExportGraphState()
+		for id, node := range graphState.Nodes {
+			if vector, exists := clone.vectors[id]; exists {
+				node.Vector = vector.Elements
+				node.Metadata = vector.Metadata
+			} else {
+				copied := copyVector(&types.Vector{ID: id, Elements: node.Vector, Metadata: node.Metadata})
+				node.Vector = copied.Elements
+				node.Metadata = copied.Metadata
+			}
+		}
+
+		cloneIndex, ok := clonePartition.index.(core.HNSWIndex)
+		if !ok {
+			return nil, utils.ErrIndexOperationFailed(fmt.Sprintf("collection %s does not use HNSW index", name))
+		}
+		if err := cloneIndex.ImportGraphState(graphState); err != nil {
+			return nil, utils.ErrIndexOperationFailed("failed to import HNSW graph state: " + err.Error())
+		}
+	}
+
+	clone.nextID = c.nextID
+	clone.vectorCount = c.vectorCount
+	clone.deletedCount = c.deletedCount
+	clone.memoryBytes = c.memoryBytes
+
+	return clone, nil
+}
+
+// setName updates the collection name after a rename
+func (c *Collection) setName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.preserveLocked()
+
+	c.name = name
+	c.config.Name = name
+	c.updatedAt = time.Now()
+}
+
+// copyVector returns a deep copy of a vector
+func copyVector(vector *types.Vector) *types.Vector {
+	vectorCopy := &types.Vector{
+		ID:        vector.ID,
+		Elements:  make([]float32, len(vector.Elements)),
+		Metadata:  make(map[string]interface{}, len(vector.Metadata)),
+		ExpireAt:  vector.ExpireAt,
+		Partition: vector.Partition,
+	}
+	copy(vectorCopy.Elements, vector.Elements)
+	for k, v := range vector.Metadata {
+		vectorCopy.Metadata[k] = v
+	}
+	return vectorCopy
+}
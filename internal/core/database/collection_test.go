@@ -2,7 +2,10 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/scintirete/scintirete/internal/persistence/rdb"
 	"github.com/scintirete/scintirete/pkg/types"
@@ -207,3 +210,154 @@ func TestVectorCountAfterDeleteAndRestore(t *testing.T) {
 
 	t.Logf("Test passed: Vector count after insert/delete/restore cycle is correct")
 }
+
+// TestCollectionVectorTTL verifies that expired vectors are hidden from search before the
+// expirer runs and that ExpireVectors tombstones them in a deterministic order
+func TestCollectionVectorTTL(t *testing.T) {
+	ctx := context.Background()
+
+	config := types.CollectionConfig{
+		Name:              "ttl_collection",
+		Metric:            types.DistanceMetricL2,
+		HNSWParams:        types.HNSWParams{M: 16, EfConstruction: 200, EfSearch: 50, MaxLayers: 16, Seed: 12345},
+		DefaultTTLSeconds: 3600,
+	}
+	collection, err := NewCollection("ttl_collection", config)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	now := time.Now()
+	past := now.Add(-time.Minute).Unix()
+	vectors := []types.Vector{
+		{Elements: []float32{0, 0, 0}, ExpireAt: past},
+		{Elements: []float32{0.1, 0.1, 0.1}},
+		{Elements: []float32{0.2, 0.2, 0.2}, ExpireAt: past},
+		{Elements: []float32{5, 5, 5}},
+	}
+	if err := collection.Insert(ctx, vectors); err != nil {
+		t.Fatalf("Failed to insert vectors: %v", err)
+	}
+
+	// The collection default TTL is resolved to an absolute expiry on insert
+	if vectors[1].ExpireAt < now.Unix()+3600 {
+		t.Errorf("Expected default TTL to be applied, got expire_at=%d", vectors[1].ExpireAt)
+	}
+
+	// Expired vectors are filtered out even before the expirer tombstones them
+	results, err := collection.Search(ctx, []float32{0, 0, 0}, types.SearchParams{TopK: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Vector.ID == vectors[0].ID || result.Vector.ID == vectors[2].ID {
+			t.Errorf("Search returned expired vector %d", result.Vector.ID)
+		}
+	}
+
+	if _, err := collection.Get(ctx, fmt.Sprintf("%d", vectors[0].ID)); err == nil {
+		t.Errorf("Expected expired vector to be hidden from Get")
+	}
+
+	expired, err := collection.ExpireVectors(ctx, now)
+	if err != nil {
+		t.Fatalf("ExpireVectors failed: %v", err)
+	}
+	expected := []string{fmt.Sprintf("%d", vectors[0].ID), fmt.Sprintf("%d", vectors[2].ID)}
+	if !reflect.DeepEqual(expired, expected) {
+		t.Errorf("Expected expired IDs %v, got %v", expected, expired)
+	}
+
+	// A second pass has nothing left to expire
+	expired, err = collection.ExpireVectors(ctx, now)
+	if err != nil {
+		t.Fatalf("ExpireVectors failed: %v", err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("Expected no vectors to expire on second pass, got %v", expired)
+	}
+
+	count, err := collection.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count vectors: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 live vectors after expiry, got %d", count)
+	}
+}
+
+// TestCollectionInsertKeepsIDs verifies that vectors inserted with an ID keep it, that
+// taken IDs are rejected and that Scan pages through the live vectors by ID
+func TestCollectionInsertKeepsIDs(t *testing.T) {
+	ctx := context.Background()
+
+	config := types.CollectionConfig{
+		Name:       "id_collection",
+		Metric:     types.DistanceMetricL2,
+		HNSWParams: types.HNSWParams{M: 16, EfConstruction: 200, EfSearch: 50, MaxLayers: 16, Seed: 12345},
+	}
+	collection, err := NewCollection("id_collection", config)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	vectors := []types.Vector{
+		{ID: 10, Elements: []float32{1, 0}},
+		{Elements: []float32{0, 1}},
+		{ID: 3, Elements: []float32{1, 1}},
+	}
+	if err := collection.Insert(ctx, vectors); err != nil {
+		t.Fatalf("Failed to insert vectors: %v", err)
+	}
+	// Generated IDs follow the largest ID seen so far
+	if vectors[0].ID != 10 || vectors[1].ID != 11 || vectors[2].ID != 3 {
+		t.Errorf("Expected IDs [10 11 3], got [%d %d %d]", vectors[0].ID, vectors[1].ID, vectors[2].ID)
+	}
+
+	// An ID that is taken by a live vector inserts nothing
+	if err := collection.Insert(ctx, []types.Vector{{ID: 20, Elements: []float32{2, 2}}, {ID: 10, Elements: []float32{2, 2}}}); err == nil {
+		t.Errorf("Expected inserting a taken ID to fail")
+	}
+	if _, err := collection.Delete(ctx, []string{"3"}); err != nil {
+		t.Fatalf("Failed to delete vector: %v", err)
+	}
+	if existing := collection.ExistingIDs([]uint64{3, 10, 20}); !reflect.DeepEqual(existing, []uint64{10}) {
+		t.Errorf("Expected existing IDs [10], got %v", existing)
+	}
+
+	// The ID of a deleted vector is taken over
+	if err := collection.Insert(ctx, []types.Vector{{ID: 3, Elements: []float32{3, 3}}}); err != nil {
+		t.Fatalf("Failed to insert over a deleted vector: %v", err)
+	}
+	if info := collection.Info(); info.VectorCount != 3 || info.DeletedCount != 0 {
+		t.Errorf("Expected 3 vectors and none deleted, got %d and %d", info.VectorCount, info.DeletedCount)
+	}
+	results, err := collection.Search(ctx, []float32{3, 3}, types.SearchParams{TopK: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Vector.ID != 3 || results[0].Vector.Elements[0] != 3 {
+		t.Errorf("Expected the new vector 3, got %v", results)
+	}
+	if _, err := collection.Delete(ctx, []string{"3"}); err != nil {
+		t.Fatalf("Failed to delete vector: %v", err)
+	}
+
+	page, err := collection.Scan(ctx, 0, 1)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != 10 {
+		t.Fatalf("Expected first page [10], got %v", page)
+	}
+	page, err = collection.Scan(ctx, page[0].ID, 10)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != 11 {
+		t.Fatalf("Expected second page [11], got %v", page)
+	}
+}
@@ -4,11 +4,14 @@ package database
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
 	"github.com/scintirete/scintirete/internal/persistence/rdb"
 	"github.com/scintirete/scintirete/internal/utils"
 	"github.com/scintirete/scintirete/pkg/types"
@@ -25,16 +28,42 @@ type Engine struct {
 	lastOpTime      time.Time
 	totalDuration   time.Duration
 	averageDuration time.Duration
+
+	// Memory limit
+	memoryLimit        MemoryLimit
+	evictionMu         sync.Mutex
+	evictedVectors     atomic.Int64
+	evictedCollections atomic.Int64
+	rejectedWrites     atomic.Int64
+
+	// Loading of released collections, shared by all databases
+	loader *collectionLoader
+
+	// Whether the expirer skips its passes, see PauseExpiry
+	expiryPaused atomic.Bool
+
+	// Snapshot isolation, see CutSnapshot
+	writes sync.RWMutex                // Read-held from a change until it is logged, write-held while cutting
+	cut    atomic.Pointer[snapshotCut] // Cut that is not released yet
 }
 
 // NewEngine creates a new database engine
 func NewEngine() *Engine {
 	return &Engine{
-		databases: make(map[string]*Database),
-		startTime: time.Now(),
+		databases:   make(map[string]*Database),
+		startTime:   time.Now(),
+		memoryLimit: MemoryLimit{Policy: MaxMemoryPolicyNoEviction},
+		loader:      newCollectionLoader(),
 	}
 }
 
+// newDatabase creates a database that shares the engine's collection loader
+func (e *Engine) newDatabase(name string) *Database {
+	db := NewDatabase(name)
+	db.loader = e.loader
+	return db
+}
+
 // CreateDatabase creates a new database
 func (e *Engine) CreateDatabase(ctx context.Context, name string) error {
 	startTime := time.Now()
@@ -45,7 +74,7 @@ func (e *Engine) CreateDatabase(ctx context.Context, name string) error {
 		return utils.ErrDatabaseExists(name)
 	}
 
-	db := NewDatabase(name)
+	db := e.newDatabase(name)
 	e.databases[name] = db
 	e.updateStatsWithDuration(time.Since(startTime))
 
@@ -87,6 +116,92 @@ func (e *Engine) GetDatabase(ctx context.Context, name string) (core.Database, e
 	return db, nil
 }
 
+// RenameCollection renames a collection within a database
+func (e *Engine) RenameCollection(ctx context.Context, dbName, oldName, newName string) error {
+	startTime := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	db, exists := e.databases[dbName]
+	if !exists {
+		return utils.ErrDatabaseNotFound(dbName)
+	}
+
+	if err := db.RenameCollection(ctx, oldName, newName); err != nil {
+		return err
+	}
+
+	e.updateStatsWithDuration(time.Since(startTime))
+	return nil
+}
+
+// CloneCollection deep copies a collection within a database
+func (e *Engine) CloneCollection(ctx context.Context, dbName, srcName, dstName string) error {
+	return e.copyCollection(ctx, dbName, srcName, dbName, dstName)
+}
+
+// CopyCollection deep copies a collection into another (or the same) database
+func (e *Engine) CopyCollection(ctx context.Context, srcDB, srcColl, dstDB, dstColl string) error {
+	return e.copyCollection(ctx, srcDB, srcColl, dstDB, dstColl)
+}
+
+// copyCollection builds the copy without the engine lock, so other databases and
+// collections stay usable while a large collection is copied. The engine lock is
+// only taken to install the copy.
+func (e *Engine) copyCollection(ctx context.Context, srcDB, srcColl, dstDB, dstColl string) error {
+	startTime := time.Now()
+
+	if dstColl == "" {
+		return utils.ErrInvalidInput("target collection name cannot be empty")
+	}
+
+	e.mu.RLock()
+	source, sourceExists := e.databases[srcDB]
+	target, targetExists := e.databases[dstDB]
+	e.mu.RUnlock()
+	if !sourceExists {
+		return utils.ErrDatabaseNotFound(srcDB)
+	}
+	if !targetExists {
+		return utils.ErrDatabaseNotFound(dstDB)
+	}
+
+	// A spilled source is loaded outside the database lock, as GetCollection loads it
+	sourceCollection, err := source.GetCollection(ctx, srcColl)
+	if err != nil {
+		return err
+	}
+	collection := sourceCollection.(*Collection)
+
+	// Check the target first so we don't copy data just to throw it away
+	if _, err := target.GetCollectionInfo(ctx, dstColl); err == nil {
+		return utils.ErrCollectionExists(dstColl)
+	}
+
+	// Clone snapshots the source under its read lock
+	clone, err := collection.Clone(dstColl)
+	if err != nil {
+		if utils.GetErrorCode(err) == utils.ErrorCodeCollectionReleased {
+			return err
+		}
+		return utils.ErrCollectionCreationFailed("failed to copy collection: " + err.Error())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// The target database may have been dropped while the copy was built
+	if e.databases[dstDB] != target {
+		return utils.ErrDatabaseNotFound(dstDB)
+	}
+	if err := target.addCollection(clone); err != nil {
+		return err
+	}
+
+	e.updateStatsWithDuration(time.Since(startTime))
+	return nil
+}
+
 // ListDatabases returns a list of all database names
 func (e *Engine) ListDatabases(ctx context.Context) ([]string, error) {
 	startTime := time.Now()
@@ -115,15 +230,30 @@ func (e *Engine) GetStats(ctx context.Context) (types.DatabaseStats, error) {
 	var totalVectors, totalCollections int64
 	var totalMemory int64
 
+	var spilledCollections int
+
 	for _, db := range e.databases {
 		dbStats := db.GetStats()
 		totalCollections += int64(len(dbStats.Collections))
+		db.mu.RLock()
+		spilledCollections += len(db.spilled)
+		db.mu.RUnlock()
 		for _, collStats := range dbStats.Collections {
 			totalVectors += collStats.VectorCount
 			totalMemory += collStats.MemoryBytes
 		}
 	}
 
+	memory := types.MemoryStats{
+		UsedBytes:          totalMemory,
+		MaxBytes:           e.memoryLimit.MaxBytes,
+		Policy:             string(e.memoryLimit.Policy),
+		EvictedVectors:     e.evictedVectors.Load(),
+		EvictedCollections: e.evictedCollections.Load(),
+		RejectedWrites:     e.rejectedWrites.Load(),
+		SpilledCollections: spilledCollections,
+	}
+
 	return types.DatabaseStats{
 		TotalVectors:     totalVectors,
 		TotalCollections: int(totalCollections),
@@ -131,6 +261,7 @@ func (e *Engine) GetStats(ctx context.Context) (types.DatabaseStats, error) {
 		MemoryUsage:      totalMemory,
 		RequestsTotal:    e.totalOps,
 		RequestDuration:  float64(e.averageDuration.Nanoseconds()) / 1e6, // Convert to milliseconds
+		Memory:           memory,
 	}, nil
 }
 
@@ -174,6 +305,8 @@ type Database struct {
 	mu          sync.RWMutex
 	name        string
 	collections map[string]*Collection
+	spilled     map[string]*spilledCollection // collections released or evicted to disk segments
+	loader      *collectionLoader
 	createdAt   time.Time
 	lastAccess  time.Time
 }
@@ -184,6 +317,8 @@ func NewDatabase(name string) *Database {
 	return &Database{
 		name:        name,
 		collections: make(map[string]*Collection),
+		spilled:     make(map[string]*spilledCollection),
+		loader:      newCollectionLoader(),
 		createdAt:   now,
 		lastAccess:  now,
 	}
@@ -204,7 +339,7 @@ func (d *Database) CreateCollection(ctx context.Context, config types.Collection
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if _, exists := d.collections[config.Name]; exists {
+	if d.hasCollectionLocked(config.Name) {
 		return utils.ErrCollectionExists(config.Name)
 	}
 
@@ -224,6 +359,16 @@ func (d *Database) DropCollection(ctx context.Context, name string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	// Spilled collections only need their segment removed
+	if spilled, exists := d.spilled[name]; exists {
+		if err := spilled.remove(); err != nil {
+			return utils.ErrCollectionOperationFailed("failed to remove collection segment: " + err.Error())
+		}
+		delete(d.spilled, name)
+		d.lastAccess = time.Now()
+		return nil
+	}
+
 	collection, exists := d.collections[name]
 	if !exists {
 		return utils.ErrCollectionNotFound(d.name, name)
@@ -240,30 +385,162 @@ func (d *Database) DropCollection(ctx context.Context, name string) error {
 	return nil
 }
 
-// GetCollection retrieves a collection by name
+// RenameCollection renames a collection within the database
+func (d *Database) RenameCollection(ctx context.Context, oldName, newName string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if newName == "" {
+		return utils.ErrInvalidInput("new collection name cannot be empty")
+	}
+
+	if !d.hasCollectionLocked(oldName) {
+		return utils.ErrCollectionNotFound(d.name, oldName)
+	}
+
+	if d.hasCollectionLocked(newName) {
+		return utils.ErrCollectionExists(newName)
+	}
+
+	collection, err := d.residentCollectionLocked(ctx, oldName)
+	if err != nil {
+		return err
+	}
+
+	collection.setName(newName)
+	d.collections[newName] = collection
+	delete(d.collections, oldName)
+	d.lastAccess = time.Now()
+
+	return nil
+}
+
+// addCollection inserts an already built collection into the database
+func (d *Database) addCollection(collection *Collection) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.hasCollectionLocked(collection.name) {
+		return utils.ErrCollectionExists(collection.name)
+	}
+
+	d.collections[collection.name] = collection
+	d.lastAccess = time.Now()
+
+	return nil
+}
+
+// GetCollection retrieves a collection by name.
+// Released collections are loaded back into memory first unless the auto_load policy is manual.
 func (d *Database) GetCollection(ctx context.Context, name string) (core.Collection, error) {
 	d.mu.RLock()
-	defer d.mu.RUnlock()
-
 	collection, exists := d.collections[name]
+	_, released := d.spilled[name]
+	d.mu.RUnlock()
+	if exists {
+		return collection, nil
+	}
+	if !released {
+		return nil, utils.ErrCollectionNotFound(d.name, name)
+	}
+	if !d.loader.autoLoad() {
+		return nil, utils.ErrCollectionNotLoaded(d.name, name)
+	}
+
+	return d.loadCollection(ctx, name)
+}
+
+// hasCollectionLocked reports whether a collection exists, in memory or spilled. Caller must hold d.mu.
+func (d *Database) hasCollectionLocked(name string) bool {
+	if _, exists := d.collections[name]; exists {
+		return true
+	}
+	_, exists := d.spilled[name]
+	return exists
+}
+
+// residentCollectionLocked returns an in-memory collection, loading it from its segment
+// if it was released. Caller must hold d.mu for writing.
+func (d *Database) residentCollectionLocked(ctx context.Context, name string) (*Collection, error) {
+	if collection, exists := d.collections[name]; exists {
+		return collection, nil
+	}
+
+	spilled, exists := d.spilled[name]
 	if !exists {
 		return nil, utils.ErrCollectionNotFound(d.name, name)
 	}
+	if !d.loader.autoLoad() {
+		return nil, utils.ErrCollectionNotLoaded(d.name, name)
+	}
 
-	d.lastAccess = time.Now()
+	// A concurrent loadCollection finding the collection resident discards its own copy
+	collection, err := d.loader.load(ctx, spilled)
+	if err != nil {
+		return nil, utils.ErrCollectionOperationFailed("failed to load collection from disk: " + err.Error())
+	}
+	if err := spilled.remove(); err != nil {
+		return nil, utils.ErrCollectionOperationFailed("failed to remove collection segment: " + err.Error())
+	}
+
+	delete(d.spilled, name)
+	d.collections[name] = collection
 	return collection, nil
 }
 
+// forEachCollection calls fn for every collection of the database in name order.
+// Released collections are loaded temporarily and stay on disk.
+func (d *Database) forEachCollection(ctx context.Context, fn func(*Collection) error) error {
+	d.mu.RLock()
+	names := make([]string, 0, len(d.collections)+len(d.spilled))
+	for name := range d.collections {
+		names = append(names, name)
+	}
+	for name := range d.spilled {
+		names = append(names, name)
+	}
+	d.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		d.mu.RLock()
+		collection, resident := d.collections[name]
+		spilled, isSpilled := d.spilled[name]
+		d.mu.RUnlock()
+
+		switch {
+		case resident:
+		case isSpilled:
+			var err error
+			collection, err = d.loader.load(ctx, spilled)
+			if err != nil {
+				return fmt.Errorf("failed to load collection %s from disk: %w", name, err)
+			}
+		default:
+			continue // Dropped in the meantime
+		}
+
+		if err := fn(collection); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ListCollections returns information about all collections in the database
 func (d *Database) ListCollections(ctx context.Context) ([]types.CollectionInfo, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	infos := make([]types.CollectionInfo, 0, len(d.collections))
+	infos := make([]types.CollectionInfo, 0, len(d.collections)+len(d.spilled))
 	for _, collection := range d.collections {
 		info := collection.Info()
 		infos = append(infos, info)
 	}
+	for _, spilled := range d.spilled {
+		infos = append(infos, spilled.currentInfo())
+	}
 
 	return infos, nil
 }
@@ -273,6 +550,10 @@ func (d *Database) GetCollectionInfo(ctx context.Context, name string) (types.Co
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
+	if spilled, exists := d.spilled[name]; exists {
+		return spilled.currentInfo(), nil
+	}
+
 	collection, exists := d.collections[name]
 	if !exists {
 		return types.CollectionInfo{}, utils.ErrCollectionNotFound(d.name, name)
@@ -290,6 +571,9 @@ func (d *Database) GetStats() types.DatabaseInfo {
 	for name, collection := range d.collections {
 		collections[name] = collection.Info()
 	}
+	for name, spilled := range d.spilled {
+		collections[name] = spilled.currentInfo()
+	}
 
 	return types.DatabaseInfo{
 		Name:        d.name,
@@ -311,6 +595,13 @@ func (d *Database) Close(ctx context.Context) error {
 		}
 	}
 
+	// Segments only cache spilled collections; AOF and RDB hold the durable copy
+	for name, spilled := range d.spilled {
+		if err := spilled.remove(); err != nil {
+			errors = append(errors, fmt.Errorf("failed to remove segment of collection %s: %w", name, err))
+		}
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf("errors closing collections: %v", errors)
 	}
@@ -328,74 +619,129 @@ func (e *Engine) GetDatabaseState(ctx context.Context) (map[string]rdb.DatabaseS
 	databases := make(map[string]rdb.DatabaseState)
 
 	for name, db := range e.databases {
-		dbInfo := db.GetStats()
-
 		// Convert collections to RDB format
 		rdbCollections := make(map[string]rdb.CollectionState)
-		for collName, collInfo := range dbInfo.Collections {
-			// Get collection for vector extraction
-			collection, err := db.GetCollection(ctx, collName)
-			if err != nil {
-				continue // Skip collections that can't be accessed
-			}
-
-			// Extract all vectors from collection and HNSW graph state
-			vectors := make([]types.Vector, 0)
-			var hnswGraphState *core.HNSWGraphState
-
-			if dbCollection, ok := collection.(*Collection); ok {
-				dbCollection.mu.RLock()
-				for _, vector := range dbCollection.vectors {
-					if !dbCollection.deletedIDs[vector.ID] {
-						// Create a copy of the vector
-						vectorCopy := types.Vector{
-							ID:       vector.ID,
-							Elements: make([]float32, len(vector.Elements)),
-							Metadata: make(map[string]interface{}),
-						}
-						copy(vectorCopy.Elements, vector.Elements)
-						for k, v := range vector.Metadata {
-							vectorCopy.Metadata[k] = v
-						}
-						vectors = append(vectors, vectorCopy)
-					}
-				}
-
-				// Export HNSW graph state if index exists
-				if dbCollection.index != nil {
-					if hnswIndex, ok := dbCollection.index.(core.HNSWIndex); ok {
-						graphState := hnswIndex.ExportGraphState()
-						hnswGraphState = &graphState
-					}
-				}
-
-				dbCollection.mu.RUnlock()
-			}
-
-			rdbCollections[collName] = rdb.CollectionState{
-				Name:         collInfo.Name,
-				Config:       convertCollectionInfoToConfig(collInfo),
-				Vectors:      vectors,
-				HNSWGraph:    hnswGraphState,      // Include HNSW graph state
-				VectorCount:  int64(len(vectors)), // Fix: Use actual count of saved vectors
-				DeletedCount: 0,                   // Fix: No deleted vectors in snapshot
-				CreatedAt:    collInfo.CreatedAt,
-				UpdatedAt:    collInfo.UpdatedAt,
-			}
+		err := db.forEachCollection(ctx, func(collection *Collection) error {
+			state := collectionState(collection)
+			rdbCollections[state.Name] = state
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to export database %s: %w", name, err)
 		}
 
 		databases[name] = rdb.DatabaseState{
 			Name:        name,
 			Collections: rdbCollections,
-			CreatedAt:   dbInfo.CreatedAt,
+			CreatedAt:   db.createdAt,
 		}
 	}
 
 	return databases, nil
 }
 
+// StreamDatabaseState passes the state of every database and collection to visitor one
+// collection at a time. A collection is read-locked only while it is copied and no
+// engine lock is held while visitor runs, so requests keep being served during a save.
+// Collections are copied at different moments, so the result isn't a point-in-time view
+// across collections; CutSnapshot provides one.
+func (e *Engine) StreamDatabaseState(ctx context.Context, visitor rdb.StateVisitor) error {
+	e.mu.RLock()
+	names := make([]string, 0, len(e.databases))
+	for name := range e.databases {
+		names = append(names, name)
+	}
+	e.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		e.mu.RLock()
+		db, exists := e.databases[name]
+		e.mu.RUnlock()
+		if !exists {
+			continue // Dropped in the meantime
+		}
+
+		if err := visitor.VisitDatabase(rdb.DatabaseState{Name: name, CreatedAt: db.createdAt}); err != nil {
+			return err
+		}
+		err := db.forEachCollection(ctx, func(collection *Collection) error {
+			return visitor.VisitCollection(name, collectionState(collection))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export database %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// collectionState exports the live vectors and the HNSW graph of every partition of a collection
+func collectionState(collection *Collection) rdb.CollectionState {
+	collection.mu.RLock()
+	defer collection.mu.RUnlock()
+
+	return collectionStateLocked(collection)
+}
+
+// collectionStateLocked is collectionState for callers that already hold collection.mu
+func collectionStateLocked(collection *Collection) rdb.CollectionState {
+	collInfo := collection.infoLocked()
+
+	// Extract all vectors from collection and HNSW graph state of every partition
+	vectors := make([]types.Vector, 0)
+	var hnswGraphState *core.HNSWGraphState
+	var partitions []rdb.PartitionState
+
+	for _, vector := range collection.vectors {
+		if !collection.deletedIDs[vector.ID] {
+			// Create a copy of the vector
+			vectors = append(vectors, *copyVector(vector))
+		}
+	}
+
+	// Export HNSW graph state of loaded partitions
+	for _, partitionName := range collection.sortedPartitionNamesLocked() {
+		p := collection.partitions[partitionName]
+		partitionState := rdb.PartitionState{
+			Name:      partitionName,
+			Released:  !p.loaded(),
+			CreatedAt: p.createdAt,
+		}
+		if p.loaded() {
+			if hnswIndex, ok := p.index.(core.HNSWIndex); ok {
+				graphState := hnswIndex.ExportGraphState()
+				partitionState.HNSWGraph = &graphState
+			}
+		}
+		if partitionName == types.DefaultPartitionName {
+			hnswGraphState = partitionState.HNSWGraph
+		}
+		partitions = append(partitions, partitionState)
+	}
+
+	return rdb.CollectionState{
+		Name:         collInfo.Name,
+		Config:       convertCollectionInfoToConfig(collInfo),
+		Vectors:      vectors,
+		HNSWGraph:    hnswGraphState, // Include HNSW graph state of the default partition
+		Partitions:   partitions,
+		VectorCount:  int64(len(vectors)), // Fix: Use actual count of saved vectors
+		DeletedCount: 0,                   // Fix: No deleted vectors in snapshot
+		CreatedAt:    collInfo.CreatedAt,
+		UpdatedAt:    collInfo.UpdatedAt,
+	}
+}
+
 // RestoreFromSnapshot restores the database state from an RDB snapshot
 func (e *Engine) RestoreFromSnapshot(ctx context.Context, snapshot *rdb.RDBSnapshot) error {
+	return e.RestoreFromSnapshotStream(ctx, snapshot.Walk)
+}
+
+// RestoreFromSnapshotStream replaces all databases with the snapshot that load passes to
+// its visitor. Collections are restored as they arrive, so a snapshot read from disk with
+// RDBManager.LoadStream is never held in memory as a whole.
+func (e *Engine) RestoreFromSnapshotStream(ctx context.Context, load func(rdb.SnapshotVisitor) error) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -408,85 +754,156 @@ func (e *Engine) RestoreFromSnapshot(ctx context.Context, snapshot *rdb.RDBSnaps
 		delete(e.databases, name)
 	}
 
-	// Restore databases from snapshot
-	for dbName, dbSnapshot := range snapshot.Databases {
-		// Create new database
-		db := NewDatabase(dbName)
-		db.createdAt = dbSnapshot.CreatedAt
+	// With the lazy policy collections go straight to their segments instead of memory
+	policy := e.loader.currentPolicy()
+	return load(&snapshotRestorer{
+		ctx:        ctx,
+		engine:     e,
+		lazy:       policy.AutoLoad == AutoLoadLazy && policy.SegmentDir != "",
+		segmentDir: policy.SegmentDir,
+		keys:       policy.Keys,
+	})
+}
 
-		// Restore collections
-		for collName, collSnapshot := range dbSnapshot.Collections {
-			// Create collection with config
-			config := collSnapshot.Config
-			if err := db.CreateCollection(ctx, config); err != nil {
-				return fmt.Errorf("failed to create collection %s in database %s: %w", collName, dbName, err)
-			}
+// snapshotRestorer restores databases and collections as they are visited.
+// The engine lock is held for the whole restore.
+type snapshotRestorer struct {
+	ctx        context.Context
+	engine     *Engine
+	lazy       bool // Write collections to segments instead of memory
+	segmentDir string
+	keys       encryption.KeyProvider // Encrypt the segments
+}
 
-			// Get the created collection
-			collection, err := db.GetCollection(ctx, collName)
-			if err != nil {
-				return fmt.Errorf("failed to get collection %s after creation: %w", collName, err)
-			}
+func (r *snapshotRestorer) VisitDatabase(dbSnapshot rdb.DatabaseSnapshot) error {
+	db := r.engine.newDatabase(dbSnapshot.Name)
+	db.createdAt = dbSnapshot.CreatedAt
+	r.engine.databases[dbSnapshot.Name] = db
+	return nil
+}
 
-			// Restore vectors and HNSW graph efficiently
-			if dbCollection, ok := collection.(*Collection); ok {
-				dbCollection.mu.Lock()
-
-				// Directly restore vectors to collection state (避免触发索引重建)
-				if len(collSnapshot.Vectors) > 0 {
-					for _, vector := range collSnapshot.Vectors {
-						vectorCopy := &types.Vector{
-							ID:       vector.ID,
-							Elements: make([]float32, len(vector.Elements)),
-							Metadata: make(map[string]interface{}),
-						}
-						copy(vectorCopy.Elements, vector.Elements)
-						for k, v := range vector.Metadata {
-							vectorCopy.Metadata[k] = v
-						}
-						dbCollection.vectors[vector.ID] = vectorCopy
-					}
-				}
+func (r *snapshotRestorer) VisitCollection(dbName string, collSnapshot rdb.CollectionSnapshot) error {
+	db, exists := r.engine.databases[dbName]
+	if !exists {
+		return fmt.Errorf("collection %s restored before its database %s", collSnapshot.Name, dbName)
+	}
 
-				// Update metadata
-				dbCollection.createdAt = collSnapshot.CreatedAt
-				dbCollection.updatedAt = collSnapshot.UpdatedAt
-				dbCollection.vectorCount = collSnapshot.VectorCount
-				dbCollection.deletedCount = collSnapshot.DeletedCount
-				dbCollection.updateNextID() // Ensure nextID is set correctly
+	if r.lazy {
+		dbSnapshot := rdb.DatabaseSnapshot{Name: dbName, CreatedAt: db.createdAt}
+		spilled, err := writeSegment(r.ctx, r.segmentDir, r.keys, dbSnapshot, collSnapshot)
+		if err != nil {
+			return fmt.Errorf("failed to write segment of collection %s in database %s: %w", collSnapshot.Name, dbName, err)
+		}
+		db.spilled[collSnapshot.Name] = spilled
+		return nil
+	}
 
-				dbCollection.mu.Unlock()
+	collection, err := restoreCollection(r.ctx, collSnapshot)
+	if err != nil {
+		return fmt.Errorf("failed to restore collection %s in database %s: %w", collSnapshot.Name, dbName, err)
+	}
+	db.collections[collSnapshot.Name] = collection
+	return nil
+}
 
-				// 强制要求HNSW图状态存在，不再支持fallback重建
-				if collSnapshot.HNSWGraph == nil {
-					return fmt.Errorf("HNSW graph state missing in RDB for collection %s - cannot restore without graph data", collName)
-				}
+// restoreCollection rebuilds a collection from its snapshot, importing the HNSW graph
+// of every loaded partition instead of rebuilding the indexes
+func restoreCollection(ctx context.Context, collSnapshot rdb.CollectionSnapshot) (*Collection, error) {
+	// Create collection with config
+	config := collSnapshot.Config
+	config.Name = collSnapshot.Name
+	collection, err := NewCollection(collSnapshot.Name, config)
+	if err != nil {
+		return nil, utils.ErrCollectionCreationFailed("failed to create collection: " + err.Error())
+	}
 
-				// Convert RDB HNSWGraphSnapshot to core.HNSWGraphState
-				graphState, err := rdb.ConvertHNSWGraphSnapshot(collSnapshot.HNSWGraph)
-				if err != nil {
-					return fmt.Errorf("failed to convert HNSW graph snapshot for collection %s: %w", collName, err)
-				}
+	collection.mu.Lock()
 
-				// Import graph state directly into HNSW index
-				if dbCollection.index == nil {
-					return fmt.Errorf("HNSW index not initialized for collection %s", collName)
-				}
+	// Directly restore vectors to collection state (避免触发索引重建)
+	for i := range collSnapshot.Vectors {
+		collection.vectors[collSnapshot.Vectors[i].ID] = copyVector(&collSnapshot.Vectors[i])
+	}
 
-				hnswIndex, ok := dbCollection.index.(core.HNSWIndex)
-				if !ok {
-					return fmt.Errorf("collection %s does not use HNSW index", collName)
-				}
+	// Update metadata
+	collection.createdAt = collSnapshot.CreatedAt
+	collection.updatedAt = collSnapshot.UpdatedAt
+	collection.vectorCount = collSnapshot.VectorCount
+	collection.deletedCount = collSnapshot.DeletedCount
+	collection.updateNextID() // Ensure nextID is set correctly
+	collection.rebuildExpiry()
 
-				if err := hnswIndex.ImportGraphState(*graphState); err != nil {
-					return fmt.Errorf("failed to import HNSW graph state for collection %s: %w", collName, err)
-				}
-			} else {
-				return fmt.Errorf("collection %s is not a database Collection type", collName)
-			}
+	collection.mu.Unlock()
+
+	// Snapshots written before partitions existed only carry the default partition
+	partitions := collSnapshot.Partitions
+	if len(partitions) == 0 {
+		partitions = []rdb.PartitionSnapshot{{Name: types.DefaultPartitionName}}
+	}
+
+	for _, partitionSnapshot := range partitions {
+		if err := restorePartition(collection, collSnapshot, partitionSnapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	collection.mu.Lock()
+	collection.rebuildPartitionStats()
+	collection.updateMemoryUsage()
+	collection.mu.Unlock()
+
+	return collection, nil
+}
+
+// restorePartition recreates a partition from a snapshot and imports its HNSW graph.
+// The default partition's graph is stored in the collection snapshot for backwards compatibility.
+func restorePartition(collection *Collection, collSnapshot rdb.CollectionSnapshot, partitionSnapshot rdb.PartitionSnapshot) error {
+	collection.mu.Lock()
+	defer collection.mu.Unlock()
+
+	collName := collSnapshot.Name
+	p, exists := collection.partitions[partitionSnapshot.Name]
+	if !exists {
+		var err error
+		p, err = newPartition(partitionSnapshot.Name, collection.config)
+		if err != nil {
+			return fmt.Errorf("failed to create partition %s for collection %s: %w", partitionSnapshot.Name, collName, err)
 		}
+		collection.partitions[partitionSnapshot.Name] = p
+	}
+	if !partitionSnapshot.CreatedAt.IsZero() {
+		p.createdAt = partitionSnapshot.CreatedAt
+	}
+
+	// Released partitions are restored released; their index is built on the next load
+	if partitionSnapshot.Released {
+		p.index = nil
+		return nil
+	}
+
+	graphSnapshot := partitionSnapshot.HNSWGraph
+	if partitionSnapshot.Name == types.DefaultPartitionName {
+		graphSnapshot = collSnapshot.HNSWGraph
+	}
+
+	// 强制要求HNSW图状态存在，不再支持fallback重建
+	if graphSnapshot == nil {
+		return fmt.Errorf("HNSW graph state missing in RDB for collection %s partition %s - cannot restore without graph data", collName, partitionSnapshot.Name)
+	}
+
+	// Convert RDB HNSWGraphSnapshot to core.HNSWGraphState
+	graphState, err := rdb.ConvertHNSWGraphSnapshot(graphSnapshot)
+	if err != nil {
+		return fmt.Errorf("failed to convert HNSW graph snapshot for collection %s: %w", collName, err)
+	}
+
+	// Import graph state directly into HNSW index
+	hnswIndex, ok := p.index.(core.HNSWIndex)
+	if !ok {
+		return fmt.Errorf("collection %s does not use HNSW index", collName)
+	}
 
-		e.databases[dbName] = db
+	if err := hnswIndex.ImportGraphState(*graphState); err != nil {
+		return fmt.Errorf("failed to import HNSW graph state for collection %s: %w", collName, err)
 	}
 
 	return nil
@@ -550,6 +967,48 @@ func (e *Engine) ApplyCommand(ctx context.Context, command types.AOFCommand) err
 
 		return db.DropCollection(ctx, collName)
 
+	case "RENAME_COLLECTION":
+		dbName := command.Database
+		collName, ok := command.Args["name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid collection name in RENAME_COLLECTION command")
+		}
+		newName, ok := command.Args["new_name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid new collection name in RENAME_COLLECTION command")
+		}
+
+		return e.RenameCollection(ctx, dbName, collName, newName)
+
+	case "CLONE_COLLECTION":
+		dbName := command.Database
+		collName, ok := command.Args["name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid collection name in CLONE_COLLECTION command")
+		}
+		targetName, ok := command.Args["target_name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid target collection name in CLONE_COLLECTION command")
+		}
+
+		return e.CloneCollection(ctx, dbName, collName, targetName)
+
+	case "COPY_COLLECTION":
+		collName, ok := command.Args["name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid collection name in COPY_COLLECTION command")
+		}
+		targetDB, ok := command.Args["target_database"].(string)
+		if !ok {
+			return fmt.Errorf("invalid target database in COPY_COLLECTION command")
+		}
+		targetName, ok := command.Args["target_name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid target collection name in COPY_COLLECTION command")
+		}
+
+		return e.CopyCollection(ctx, command.Database, collName, targetDB, targetName)
+
 	case "INSERT_VECTORS":
 		dbName := command.Database
 		collName := command.Collection
@@ -604,7 +1063,43 @@ func (e *Engine) ApplyCommand(ctx context.Context, command types.AOFCommand) err
 			return fmt.Errorf("invalid ids in DELETE_VECTORS command: %w", err)
 		}
 
-		_, err = collection.Delete(ctx, ids)
+		// Optional partition filter
+		var partitions []string
+		if partitionsInterface, ok := command.Args["partition_names"]; ok {
+			partitions, err = extractStringSlice(partitionsInterface)
+			if err != nil {
+				return fmt.Errorf("invalid partition names in DELETE_VECTORS command: %w", err)
+			}
+		}
+
+		_, err = collection.DeleteFromPartitions(ctx, ids, partitions)
+		return err
+
+	case "CREATE_PARTITION", "DROP_PARTITION":
+		dbName := command.Database
+		collName, ok := command.Args["name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid collection name in %s command", command.Command)
+		}
+		partition, ok := command.Args["partition"].(string)
+		if !ok {
+			return fmt.Errorf("invalid partition name in %s command", command.Command)
+		}
+
+		db, err := e.GetDatabase(ctx, dbName)
+		if err != nil {
+			return fmt.Errorf("database %s not found for %s: %w", dbName, command.Command, err)
+		}
+
+		collection, err := db.GetCollection(ctx, collName)
+		if err != nil {
+			return fmt.Errorf("collection %s not found for %s: %w", collName, command.Command, err)
+		}
+
+		if command.Command == "CREATE_PARTITION" {
+			return collection.CreatePartition(ctx, partition)
+		}
+		_, err = collection.DropPartition(ctx, partition)
 		return err
 
 	default:
@@ -632,81 +1127,94 @@ func (e *Engine) GetOptimizedCommands(ctx context.Context) ([]types.AOFCommand,
 		})
 
 		// Create collection commands
-		dbInfo := db.GetStats()
-		for collName, collInfo := range dbInfo.Collections {
-			// Get collection for detailed info
-			collection, err := db.GetCollection(ctx, collName)
-			if err != nil {
-				continue // Skip collections that can't be accessed
-			}
+		err := db.forEachCollection(ctx, func(collection *Collection) error {
+			commands = append(commands, collectionCommands(dbName, collection)...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to export database %s: %w", dbName, err)
+		}
+	}
 
-			config := convertCollectionInfoToConfig(collInfo)
-			commands = append(commands, types.AOFCommand{
-				Timestamp: collInfo.CreatedAt,
-				Command:   "CREATE_COLLECTION",
-				Args: map[string]interface{}{
-					"name":   collName,
-					"config": config,
-				},
-				Database:   dbName,
-				Collection: collName,
-			})
-
-			// Insert vector commands (batch by reasonable size)
-			if dbCollection, ok := collection.(*Collection); ok {
-				dbCollection.mu.RLock()
-				var batchVectors []types.Vector
-				const batchSize = 100 // Insert in batches of 100
-
-				for _, vector := range dbCollection.vectors {
-					if !dbCollection.deletedIDs[vector.ID] {
-						// Create a copy of the vector
-						vectorCopy := types.Vector{
-							ID:       vector.ID,
-							Elements: make([]float32, len(vector.Elements)),
-							Metadata: make(map[string]interface{}),
-						}
-						copy(vectorCopy.Elements, vector.Elements)
-						for k, v := range vector.Metadata {
-							vectorCopy.Metadata[k] = v
-						}
-						batchVectors = append(batchVectors, vectorCopy)
-
-						// Insert batch when it reaches batchSize
-						if len(batchVectors) >= batchSize {
-							commands = append(commands, types.AOFCommand{
-								Timestamp: collInfo.UpdatedAt,
-								Command:   "INSERT_VECTORS",
-								Args: map[string]interface{}{
-									"vectors": batchVectors,
-								},
-								Database:   dbName,
-								Collection: collName,
-							})
-							batchVectors = make([]types.Vector, 0, batchSize)
-						}
-					}
-				}
+	return commands, nil
+}
 
-				// Insert remaining vectors if any
-				if len(batchVectors) > 0 {
-					commands = append(commands, types.AOFCommand{
-						Timestamp: collInfo.UpdatedAt,
-						Command:   "INSERT_VECTORS",
-						Args: map[string]interface{}{
-							"vectors": batchVectors,
-						},
-						Database:   dbName,
-						Collection: collName,
-					})
-				}
+// collectionCommands returns the commands that recreate a collection, its partitions and its live vectors
+func collectionCommands(dbName string, collection *Collection) []types.AOFCommand {
+	collInfo := collection.Info()
+	collName := collInfo.Name
+
+	config := convertCollectionInfoToConfig(collInfo)
+	commands := []types.AOFCommand{{
+		Timestamp: collInfo.CreatedAt,
+		Command:   "CREATE_COLLECTION",
+		Args: map[string]interface{}{
+			"name":   collName,
+			"config": config,
+		},
+		Database:   dbName,
+		Collection: collName,
+	}}
+
+	collection.mu.RLock()
+	defer collection.mu.RUnlock()
+
+	// Recreate partitions before inserting their vectors
+	for _, partitionName := range collection.sortedPartitionNamesLocked() {
+		if partitionName == types.DefaultPartitionName {
+			continue
+		}
+		commands = append(commands, types.AOFCommand{
+			Timestamp: collection.partitions[partitionName].createdAt,
+			Command:   "CREATE_PARTITION",
+			Args: map[string]interface{}{
+				"name":      collName,
+				"partition": partitionName,
+			},
+			Database:   dbName,
+			Collection: collName,
+		})
+	}
 
-				dbCollection.mu.RUnlock()
+	// Insert vector commands (batch by reasonable size)
+	var batchVectors []types.Vector
+	const batchSize = 100 // Insert in batches of 100
+
+	for _, vector := range collection.vectors {
+		if !collection.deletedIDs[vector.ID] {
+			// Create a copy of the vector
+			batchVectors = append(batchVectors, *copyVector(vector))
+
+			// Insert batch when it reaches batchSize
+			if len(batchVectors) >= batchSize {
+				commands = append(commands, types.AOFCommand{
+					Timestamp: collInfo.UpdatedAt,
+					Command:   "INSERT_VECTORS",
+					Args: map[string]interface{}{
+						"vectors": batchVectors,
+					},
+					Database:   dbName,
+					Collection: collName,
+				})
+				batchVectors = make([]types.Vector, 0, batchSize)
 			}
 		}
 	}
 
-	return commands, nil
+	// Insert remaining vectors if any
+	if len(batchVectors) > 0 {
+		commands = append(commands, types.AOFCommand{
+			Timestamp: collInfo.UpdatedAt,
+			Command:   "INSERT_VECTORS",
+			Args: map[string]interface{}{
+				"vectors": batchVectors,
+			},
+			Database:   dbName,
+			Collection: collName,
+		})
+	}
+
+	return commands
 }
 
 // Helper functions for data conversion
@@ -714,9 +1222,10 @@ func (e *Engine) GetOptimizedCommands(ctx context.Context) ([]types.AOFCommand,
 // convertCollectionInfoToConfig converts CollectionInfo to CollectionConfig
 func convertCollectionInfoToConfig(info types.CollectionInfo) types.CollectionConfig {
 	return types.CollectionConfig{
-		Name:       info.Name,
-		Metric:     info.MetricType,
-		HNSWParams: info.HNSWConfig,
+		Name:              info.Name,
+		Metric:            info.MetricType,
+		HNSWParams:        info.HNSWConfig,
+		DefaultTTLSeconds: info.DefaultTTLSeconds,
 	}
 }
 
@@ -736,6 +1245,13 @@ func extractCollectionConfig(configInterface interface{}, collName string) (type
 			collConfig.Metric = types.DistanceMetricL2 // default
 		}
 
+		switch ttl := config["default_ttl_seconds"].(type) {
+		case int64:
+			collConfig.DefaultTTLSeconds = ttl
+		case float64:
+			collConfig.DefaultTTLSeconds = int64(ttl)
+		}
+
 		// Parse HNSW parameters
 		if hnswInterface, ok := config["hnsw_params"]; ok {
 			if hnswMap, ok := hnswInterface.(map[string]interface{}); ok {
@@ -840,6 +1356,13 @@ func extractSingleVector(vectorInterface interface{}) (types.Vector, error) {
 			result.Metadata = make(map[string]interface{})
 		}
 
+		switch expireAt := vector["expire_at"].(type) {
+		case int64:
+			result.ExpireAt = expireAt
+		case float64:
+			result.ExpireAt = int64(expireAt)
+		}
+
 		return result, nil
 	default:
 		return types.Vector{}, fmt.Errorf("unsupported vector type: %T", vectorInterface)
@@ -0,0 +1,330 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// newTestEngineWithCollection creates an engine with one database and a populated collection
+func newTestEngineWithCollection(t *testing.T, dbName, collName string) *Engine {
+	t.Helper()
+	ctx := context.Background()
+
+	engine := NewEngine()
+	if err := engine.CreateDatabase(ctx, dbName); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	db, err := engine.GetDatabase(ctx, dbName)
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+
+	config := types.CollectionConfig{
+		Name:   collName,
+		Metric: types.DistanceMetricL2,
+		HNSWParams: types.HNSWParams{
+			M:              16,
+			EfConstruction: 200,
+			EfSearch:       50,
+			MaxLayers:      16,
+			Seed:           12345,
+		},
+	}
+	if err := db.CreateCollection(ctx, config); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	collection, err := db.GetCollection(ctx, collName)
+	if err != nil {
+		t.Fatalf("Failed to get collection: %v", err)
+	}
+
+	vectors := make([]types.Vector, 20)
+	for i := range vectors {
+		vectors[i] = types.Vector{
+			Elements: []float32{float32(i), float32(i * 2), float32(i * 3)},
+			Metadata: map[string]interface{}{"index": i},
+		}
+	}
+	if err := collection.Insert(ctx, vectors); err != nil {
+		t.Fatalf("Failed to insert vectors: %v", err)
+	}
+
+	if _, err := collection.Delete(ctx, []string{"1"}); err != nil {
+		t.Fatalf("Failed to delete vector: %v", err)
+	}
+
+	return engine
+}
+
+func TestRenameCollection(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngineWithCollection(t, "test_db", "source")
+
+	if err := engine.RenameCollection(ctx, "test_db", "source", "renamed"); err != nil {
+		t.Fatalf("Failed to rename collection: %v", err)
+	}
+
+	db, _ := engine.GetDatabase(ctx, "test_db")
+	if _, err := db.GetCollection(ctx, "source"); err == nil {
+		t.Error("Old collection name should no longer exist")
+	}
+
+	info, err := db.GetCollectionInfo(ctx, "renamed")
+	if err != nil {
+		t.Fatalf("Failed to get renamed collection: %v", err)
+	}
+	if info.Name != "renamed" {
+		t.Errorf("Expected collection name 'renamed', got '%s'", info.Name)
+	}
+	if info.VectorCount != 19 {
+		t.Errorf("Expected 19 vectors after rename, got %d", info.VectorCount)
+	}
+
+	// Renaming onto an existing collection must fail
+	if err := engine.CloneCollection(ctx, "test_db", "renamed", "other"); err != nil {
+		t.Fatalf("Failed to clone collection: %v", err)
+	}
+	if err := engine.RenameCollection(ctx, "test_db", "renamed", "other"); err == nil {
+		t.Error("Renaming onto an existing collection should fail")
+	}
+	if err := engine.RenameCollection(ctx, "test_db", "missing", "new"); err == nil {
+		t.Error("Renaming a missing collection should fail")
+	}
+}
+
+func TestCloneCollectionPreservesGraph(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngineWithCollection(t, "test_db", "source")
+
+	if err := engine.CloneCollection(ctx, "test_db", "source", "clone"); err != nil {
+		t.Fatalf("Failed to clone collection: %v", err)
+	}
+
+	db, _ := engine.GetDatabase(ctx, "test_db")
+	source, _ := db.GetCollection(ctx, "source")
+	clone, err := db.GetCollection(ctx, "clone")
+	if err != nil {
+		t.Fatalf("Failed to get cloned collection: %v", err)
+	}
+
+	sourceInfo := source.Info()
+	cloneInfo := clone.Info()
+	if cloneInfo.VectorCount != sourceInfo.VectorCount || cloneInfo.DeletedCount != sourceInfo.DeletedCount {
+		t.Errorf("Clone counts (%d, %d) differ from source (%d, %d)",
+			cloneInfo.VectorCount, cloneInfo.DeletedCount, sourceInfo.VectorCount, sourceInfo.DeletedCount)
+	}
+
+	// The graph is imported as-is, so the structure must match exactly
+	sourceGraph := source.(*Collection).partitions[types.DefaultPartitionName].index.(core.HNSWIndex).ExportGraphState()
+	cloneGraph := clone.(*Collection).partitions[types.DefaultPartitionName].index.(core.HNSWIndex).ExportGraphState()
+	if sourceGraph.EntryPoint != cloneGraph.EntryPoint || sourceGraph.MaxLayer != cloneGraph.MaxLayer {
+		t.Errorf("Clone graph entry point/max layer differ from source")
+	}
+	for id, node := range sourceGraph.Nodes {
+		cloneNode, ok := cloneGraph.Nodes[id]
+		if !ok {
+			t.Fatalf("Node %d missing from cloned graph", id)
+		}
+		if len(node.Connections) != len(cloneNode.Connections) || node.Deleted != cloneNode.Deleted {
+			t.Errorf("Node %d differs between source and clone", id)
+		}
+	}
+
+	// Search results must match
+	query := []float32{5, 10, 15}
+	params := types.SearchParams{TopK: 5}
+	sourceResults, err := source.Search(ctx, query, params)
+	if err != nil {
+		t.Fatalf("Source search failed: %v", err)
+	}
+	cloneResults, err := clone.Search(ctx, query, params)
+	if err != nil {
+		t.Fatalf("Clone search failed: %v", err)
+	}
+	if len(sourceResults) != len(cloneResults) {
+		t.Fatalf("Expected %d clone results, got %d", len(sourceResults), len(cloneResults))
+	}
+	for i := range sourceResults {
+		if sourceResults[i].Vector.ID != cloneResults[i].Vector.ID {
+			t.Errorf("Result %d: source ID %d, clone ID %d", i, sourceResults[i].Vector.ID, cloneResults[i].Vector.ID)
+		}
+	}
+
+	// Writes to the clone must not leak into the source
+	if err := clone.Insert(ctx, []types.Vector{{Elements: []float32{100, 200, 300}}}); err != nil {
+		t.Fatalf("Failed to insert into clone: %v", err)
+	}
+	if _, err := clone.Delete(ctx, []string{"2"}); err != nil {
+		t.Fatalf("Failed to delete from clone: %v", err)
+	}
+	if count, _ := source.Count(ctx); count != 19 {
+		t.Errorf("Source count changed after modifying clone: %d", count)
+	}
+	if _, err := source.Get(ctx, "2"); err != nil {
+		t.Errorf("Vector deleted from clone should still exist in source: %v", err)
+	}
+}
+
+func TestCopyCollectionAcrossDatabases(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngineWithCollection(t, "src_db", "source")
+
+	if err := engine.CopyCollection(ctx, "src_db", "source", "dst_db", "copy"); err == nil {
+		t.Error("Copying into a missing database should fail")
+	}
+
+	if err := engine.CreateDatabase(ctx, "dst_db"); err != nil {
+		t.Fatalf("Failed to create target database: %v", err)
+	}
+	if err := engine.CopyCollection(ctx, "src_db", "source", "dst_db", "copy"); err != nil {
+		t.Fatalf("Failed to copy collection: %v", err)
+	}
+	if err := engine.CopyCollection(ctx, "src_db", "source", "dst_db", "copy"); err == nil {
+		t.Error("Copying onto an existing collection should fail")
+	}
+
+	dstDB, _ := engine.GetDatabase(ctx, "dst_db")
+	copied, err := dstDB.GetCollection(ctx, "copy")
+	if err != nil {
+		t.Fatalf("Failed to get copied collection: %v", err)
+	}
+
+	if count, _ := copied.Count(ctx); count != 19 {
+		t.Errorf("Expected 19 vectors in copy, got %d", count)
+	}
+
+	vector, err := copied.Get(ctx, "5")
+	if err != nil {
+		t.Fatalf("Failed to get vector from copy: %v", err)
+	}
+	if vector.Metadata["index"] != 4 {
+		t.Errorf("Expected metadata index 4, got %v", vector.Metadata["index"])
+	}
+
+	// New IDs continue from the source's counter
+	vectors := []types.Vector{{Elements: []float32{1, 1, 1}}}
+	if err := copied.Insert(ctx, vectors); err != nil {
+		t.Fatalf("Failed to insert into copy: %v", err)
+	}
+	if vectors[0].ID != 21 {
+		t.Errorf("Expected next ID 21, got %d", vectors[0].ID)
+	}
+}
+
+func TestCopyCollectionDoesNotBlockEngine(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngineWithCollection(t, "test_db", "source")
+
+	db, _ := engine.GetDatabase(ctx, "test_db")
+	source, _ := db.GetCollection(ctx, "source")
+
+	// Stall the copy on the source collection's lock
+	source.(*Collection).mu.Lock()
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.CloneCollection(ctx, "test_db", "source", "clone")
+	}()
+
+	created := make(chan error, 1)
+	go func() {
+		created <- engine.CreateDatabase(ctx, "other_db")
+	}()
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Errorf("Failed to create database during copy: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Creating a database waited for the copy to finish")
+	}
+
+	source.(*Collection).mu.Unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to clone collection: %v", err)
+	}
+	if _, err := db.GetCollection(ctx, "clone"); err != nil {
+		t.Errorf("Failed to get cloned collection: %v", err)
+	}
+}
+
+// writingVisitor inserts into the collection being saved while the save visits it
+type writingVisitor struct {
+	engine      *Engine
+	collections []string
+}
+
+func (v *writingVisitor) VisitDatabase(db rdb.DatabaseState) error {
+	return nil
+}
+
+func (v *writingVisitor) VisitCollection(dbName string, coll rdb.CollectionState) error {
+	v.collections = append(v.collections, dbName+"/"+coll.Name)
+
+	// Deadlocks if the save still held a lock of the engine or the collection
+	db, err := v.engine.GetDatabase(context.Background(), dbName)
+	if err != nil {
+		return err
+	}
+	collection, err := db.GetCollection(context.Background(), coll.Name)
+	if err != nil {
+		return err
+	}
+	return collection.Insert(context.Background(), []types.Vector{{Elements: []float32{1, 1, 1}}})
+}
+
+func TestStreamDatabaseState(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestEngineWithCollection(t, "db", "vectors")
+	if err := engine.CreateDatabase(ctx, "empty"); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	visitor := &writingVisitor{engine: engine}
+	if err := engine.StreamDatabaseState(ctx, visitor); err != nil {
+		t.Fatalf("StreamDatabaseState failed: %v", err)
+	}
+	if len(visitor.collections) != 1 || visitor.collections[0] != "db/vectors" {
+		t.Errorf("Expected db/vectors to be visited, got %v", visitor.collections)
+	}
+
+	// A streamed save restores through the streaming loader
+	manager, err := rdb.NewRDBManagerWithCompression(filepath.Join(t.TempDir(), "dump.rdb"), rdb.CompressionZstd)
+	if err != nil {
+		t.Fatalf("Failed to create RDB manager: %v", err)
+	}
+	if err := manager.SaveStream(ctx, engine); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored := NewEngine()
+	err = restored.RestoreFromSnapshotStream(ctx, func(visitor rdb.SnapshotVisitor) error {
+		_, err := manager.LoadStream(ctx, visitor)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RestoreFromSnapshotStream failed: %v", err)
+	}
+
+	databases, _ := restored.ListDatabases(ctx)
+	if len(databases) != 2 {
+		t.Errorf("Expected 2 restored databases, got %v", databases)
+	}
+	db, _ := restored.GetDatabase(ctx, "db")
+	info, err := db.GetCollectionInfo(ctx, "vectors")
+	if err != nil || info.VectorCount != 20 {
+		t.Fatalf("Expected 19 vectors plus the one inserted during the first save, got %+v, %v", info, err)
+	}
+	collection, _ := db.GetCollection(ctx, "vectors")
+	results, err := collection.Search(ctx, []float32{2, 4, 6}, types.SearchParams{TopK: 1})
+	if err != nil || len(results) != 1 || results[0].Vector.ID != 3 {
+		t.Errorf("Expected vector 3 as nearest neighbour, got %+v, %v", results, err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package database provides TTL expiry for vectors.
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// DefaultExpireInterval is how often the background expirer scans for expired vectors
+const DefaultExpireInterval = time.Second
+
+// ExpiredVectors describes the vectors tombstoned in one collection by an expiry pass
+// or by volatile-ttl eviction
+type ExpiredVectors struct {
+	Database   string
+	Collection string
+	IDs        []string // Ascending order, suitable for a deterministic DELETE_VECTORS record
+}
+
+// ExpireVectors tombstones every vector whose TTL has elapsed at the given time.
+// Databases and collections are visited in name order so the result is deterministic.
+func (e *Engine) ExpireVectors(ctx context.Context, now time.Time) ([]ExpiredVectors, error) {
+	return e.collectExpired(func(collection *Collection) ([]string, error) {
+		return collection.ExpireVectors(ctx, now)
+	})
+}
+
+// ExpiredVectors returns the vectors whose TTL has elapsed at the given time without
+// tombstoning them, such as for the leader of a cluster to delete them through the Raft
+// log. Databases and collections are visited in name order.
+func (e *Engine) ExpiredVectors(now time.Time) []ExpiredVectors {
+	results, _ := e.collectExpired(func(collection *Collection) ([]string, error) {
+		return collection.ExpiredIDs(now), nil
+	})
+	return results
+}
+
+// collectExpired runs expire on every collection in name order and gathers the IDs it
+// returns, stopping at the first error
+func (e *Engine) collectExpired(expire func(*Collection) ([]string, error)) ([]ExpiredVectors, error) {
+	e.mu.RLock()
+	dbNames := make([]string, 0, len(e.databases))
+	for name := range e.databases {
+		dbNames = append(dbNames, name)
+	}
+	e.mu.RUnlock()
+	sort.Strings(dbNames)
+
+	var results []ExpiredVectors
+	for _, dbName := range dbNames {
+		e.mu.RLock()
+		db, exists := e.databases[dbName]
+		e.mu.RUnlock()
+		if !exists {
+			continue
+		}
+
+		db.mu.RLock()
+		collNames := make([]string, 0, len(db.collections))
+		for name := range db.collections {
+			collNames = append(collNames, name)
+		}
+		db.mu.RUnlock()
+		sort.Strings(collNames)
+
+		for _, collName := range collNames {
+			db.mu.RLock()
+			collection, exists := db.collections[collName]
+			db.mu.RUnlock()
+			if !exists {
+				continue
+			}
+
+			ids, err := expire(collection)
+			if len(ids) > 0 {
+				results = append(results, ExpiredVectors{
+					Database:   dbName,
+					Collection: collName,
+					IDs:        ids,
+				})
+			}
+			if err != nil {
+				return results, err
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// StartExpirer runs ExpireVectors every interval until ctx is cancelled.
+// onExpire is called for every collection that had vectors tombstoned, typically to log
+// the deletes to the AOF. If a pass fails, onExpire is called once more with an empty
+// batch and the error.
+func (e *Engine) StartExpirer(ctx context.Context, interval time.Duration, onExpire func(context.Context, ExpiredVectors, error)) {
+	if interval <= 0 {
+		interval = DefaultExpireInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				e.expire(ctx, now, onExpire)
+			}
+		}
+	}()
+}
+
+// PauseExpiry stops or resumes the passes of the expirer. A replica pauses them, because
+// it removes expired vectors when the deletes of its primary arrive, and so does a cluster
+// node, which removes them when the deletes of the leader are applied.
+func (e *Engine) PauseExpiry(paused bool) {
+	e.expiryPaused.Store(paused)
+}
+
+// expire runs one expiry pass. Snapshot cuts are held off until onExpire logged the deletes.
+func (e *Engine) expire(ctx context.Context, now time.Time, onExpire func(context.Context, ExpiredVectors, error)) {
+	if e.expiryPaused.Load() {
+		return
+	}
+
+	e.BeginWrite()
+	defer e.EndWrite()
+
+	expired, err := e.ExpireVectors(ctx, now)
+	for _, batch := range expired {
+		onExpire(ctx, batch, nil)
+	}
+	if err != nil {
+		onExpire(ctx, ExpiredVectors{}, err)
+	}
+}
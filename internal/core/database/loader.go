@@ -0,0 +1,305 @@
+// Package database provides loading and releasing of collections to disk segments.
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// AutoLoadPolicy decides when collections stored on disk are loaded into memory
+type AutoLoadPolicy string
+
+const (
+	// AutoLoadEager loads every collection at startup; released collections are loaded on first access
+	AutoLoadEager AutoLoadPolicy = "eager"
+	// AutoLoadLazy keeps collections on disk at startup and loads them on first access
+	AutoLoadLazy AutoLoadPolicy = "lazy"
+	// AutoLoadManual loads every collection at startup; released collections must be loaded with LoadCollection
+	AutoLoadManual AutoLoadPolicy = "manual"
+)
+
+// ParseAutoLoadPolicy validates a policy name. An empty name selects eager.
+func ParseAutoLoadPolicy(name string) (AutoLoadPolicy, error) {
+	switch policy := AutoLoadPolicy(name); policy {
+	case "":
+		return AutoLoadEager, nil
+	case AutoLoadEager, AutoLoadLazy, AutoLoadManual:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid auto_load policy: %s", name)
+	}
+}
+
+// LoadPolicy configures how collections move between memory and disk segments
+type LoadPolicy struct {
+	AutoLoad           AutoLoadPolicy // When collections on disk are loaded
+	MaxConcurrentLoads int            // Segment loads running at the same time, 0 means unlimited
+	SegmentDir         string         // Where released and evicted collections are stored
+	// Keys encrypt the segments written from now on, nil writes them unencrypted
+	Keys encryption.KeyProvider
+}
+
+// collectionLoader reads collections back from their segments.
+// It is shared by all databases of an engine so the concurrency limit applies globally.
+type collectionLoader struct {
+	mu     sync.RWMutex
+	policy LoadPolicy
+	slots  chan struct{} // nil means unlimited
+}
+
+// newCollectionLoader creates a loader with the eager policy and no segment directory
+func newCollectionLoader() *collectionLoader {
+	return &collectionLoader{
+		policy: LoadPolicy{AutoLoad: AutoLoadEager},
+	}
+}
+
+// setPolicy replaces the load policy. Loads already waiting keep their old slots.
+func (l *collectionLoader) setPolicy(policy LoadPolicy) {
+	if policy.AutoLoad == "" {
+		policy.AutoLoad = AutoLoadEager
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.policy = policy
+	l.slots = nil
+	if policy.MaxConcurrentLoads > 0 {
+		l.slots = make(chan struct{}, policy.MaxConcurrentLoads)
+	}
+}
+
+// currentPolicy returns the active load policy
+func (l *collectionLoader) currentPolicy() LoadPolicy {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.policy
+}
+
+// autoLoad reports whether released collections are loaded on first access
+func (l *collectionLoader) autoLoad() bool {
+	return l.currentPolicy().AutoLoad != AutoLoadManual
+}
+
+// load reads a segment into a new collection, waiting for a free load slot first
+func (l *collectionLoader) load(ctx context.Context, spilled *spilledCollection) (*Collection, error) {
+	l.mu.RLock()
+	slots := l.slots
+	l.mu.RUnlock()
+
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return spilled.load(ctx)
+}
+
+// loadCall tracks a segment load in progress so concurrent requests share its result
+type loadCall struct {
+	done       chan struct{}
+	collection *Collection
+	err        error
+}
+
+// SetLoadPolicy configures when collections are loaded and where released collections are stored.
+// It must be called before RestoreFromSnapshot for the lazy policy to take effect at startup.
+func (e *Engine) SetLoadPolicy(policy LoadPolicy) {
+	e.loader.setPolicy(policy)
+}
+
+// LoadCollection loads a released collection of a database back into memory
+func (e *Engine) LoadCollection(ctx context.Context, dbName, collName string) error {
+	startTime := time.Now()
+
+	// Loading can take a while, so don't hold the engine lock
+	e.mu.RLock()
+	db, exists := e.databases[dbName]
+	e.mu.RUnlock()
+	if !exists {
+		return utils.ErrDatabaseNotFound(dbName)
+	}
+
+	if err := db.LoadCollection(ctx, collName); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.updateStatsWithDuration(time.Since(startTime))
+	e.mu.Unlock()
+	return nil
+}
+
+// ReleaseCollection writes a collection of a database to disk and frees its memory.
+// It returns the estimated number of bytes released.
+func (e *Engine) ReleaseCollection(ctx context.Context, dbName, collName string) (int64, error) {
+	startTime := time.Now()
+
+	e.mu.RLock()
+	db, exists := e.databases[dbName]
+	e.mu.RUnlock()
+	if !exists {
+		return 0, utils.ErrDatabaseNotFound(dbName)
+	}
+
+	freed, err := db.ReleaseCollection(ctx, collName)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	e.updateStatsWithDuration(time.Since(startTime))
+	e.mu.Unlock()
+	return freed, nil
+}
+
+// LoadCollection loads a released collection back into memory.
+// Loading a collection that is already in memory does nothing.
+func (d *Database) LoadCollection(ctx context.Context, name string) error {
+	_, err := d.loadCollection(ctx, name)
+	return err
+}
+
+// ReleaseCollection writes a collection to its segment on disk and frees its memory.
+// It returns the estimated number of bytes released; releasing a released collection does nothing.
+func (d *Database) ReleaseCollection(ctx context.Context, name string) (int64, error) {
+	dir := d.loader.currentPolicy().SegmentDir
+	if dir == "" {
+		return 0, utils.ErrConfig("releasing collections requires a segment directory")
+	}
+
+	freed, err := d.spillCollection(ctx, name, dir)
+	if utils.GetErrorCode(err) == utils.ErrorCodeCollectionNotFound {
+		d.mu.RLock()
+		_, released := d.spilled[name]
+		d.mu.RUnlock()
+		if released {
+			return 0, nil
+		}
+	}
+
+	return freed, err
+}
+
+// loadCollection returns an in-memory collection, loading it from its segment if it was released.
+// The segment is read without holding d.mu; concurrent requests for the same collection wait
+// for a single load.
+func (d *Database) loadCollection(ctx context.Context, name string) (*Collection, error) {
+	d.mu.Lock()
+	if collection, exists := d.collections[name]; exists {
+		d.mu.Unlock()
+		return collection, nil
+	}
+
+	spilled, exists := d.spilled[name]
+	if !exists {
+		d.mu.Unlock()
+		return nil, utils.ErrCollectionNotFound(d.name, name)
+	}
+
+	// Another request is loading the collection already
+	if call := spilled.loading; call != nil {
+		d.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.collection, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	call := &loadCall{done: make(chan struct{})}
+	spilled.loading = call
+	d.mu.Unlock()
+
+	collection, err := d.loader.load(ctx, spilled)
+
+	d.mu.Lock()
+	spilled.loading = nil
+	switch {
+	case err != nil:
+		call.err = utils.ErrCollectionOperationFailed("failed to load collection from disk: " + err.Error())
+	case d.spilled[name] == spilled:
+		if err := spilled.remove(); err != nil {
+			call.err = utils.ErrCollectionOperationFailed("failed to remove collection segment: " + err.Error())
+			break
+		}
+		delete(d.spilled, name)
+		d.collections[name] = collection
+		call.collection = collection
+	default:
+		// Loaded, dropped or renamed by someone else in the meantime
+		collection.Close()
+		if current, exists := d.collections[name]; exists {
+			call.collection = current
+		} else {
+			call.err = utils.ErrCollectionNotFound(d.name, name)
+		}
+	}
+	d.lastAccess = time.Now()
+	d.mu.Unlock()
+
+	close(call.done)
+	return call.collection, call.err
+}
+
+// snapshotCollectionInfo derives the info of a released collection from its snapshot
+// without building the collection in memory
+func snapshotCollectionInfo(collSnapshot rdb.CollectionSnapshot) types.CollectionInfo {
+	var dimension int
+	partitionCounts := make(map[string]int64)
+	for i := range collSnapshot.Vectors {
+		dimension = len(collSnapshot.Vectors[i].Elements)
+		partitionCounts[partitionNameOf(&collSnapshot.Vectors[i])]++
+	}
+
+	// Snapshots written before partitions existed only carry the default partition
+	partitionSnapshots := collSnapshot.Partitions
+	if len(partitionSnapshots) == 0 {
+		partitionSnapshots = []rdb.PartitionSnapshot{{Name: types.DefaultPartitionName}}
+	}
+
+	partitions := make([]types.PartitionInfo, 0, len(partitionSnapshots))
+	for _, partitionSnapshot := range partitionSnapshots {
+		partitions = append(partitions, types.PartitionInfo{
+			Name:        partitionSnapshot.Name,
+			VectorCount: partitionCounts[partitionSnapshot.Name],
+			Loaded:      !partitionSnapshot.Released,
+			CreatedAt:   partitionSnapshot.CreatedAt,
+		})
+	}
+	sort.SliceStable(partitions, func(i, j int) bool {
+		if partitions[j].Name == types.DefaultPartitionName {
+			return false
+		}
+		return partitions[i].Name == types.DefaultPartitionName || partitions[i].Name < partitions[j].Name
+	})
+
+	return types.CollectionInfo{
+		Name:        collSnapshot.Name,
+		Dimension:   dimension,
+		VectorCount: int64(len(collSnapshot.Vectors)),
+		MetricType:  collSnapshot.Config.Metric,
+		HNSWConfig:  collSnapshot.Config.HNSWParams,
+		CreatedAt:   collSnapshot.CreatedAt,
+		UpdatedAt:   collSnapshot.UpdatedAt,
+
+		DefaultTTLSeconds: collSnapshot.Config.DefaultTTLSeconds,
+		Partitions:        partitions,
+		LoadState:         types.LoadStateReleased,
+	}
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+func TestReleaseAndLoadCollection(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 10, "vectors")
+	db, _ := engine.GetDatabase(ctx, "db")
+
+	// Releasing needs somewhere to put the segment
+	if _, err := engine.ReleaseCollection(ctx, "db", "vectors"); utils.GetErrorCode(err) != utils.ErrorCodeConfig {
+		t.Fatalf("Expected config error without segment dir, got %v", err)
+	}
+
+	engine.SetLoadPolicy(LoadPolicy{SegmentDir: t.TempDir()})
+
+	usage := engine.MemoryUsage()
+	freed, err := engine.ReleaseCollection(ctx, "db", "vectors")
+	if err != nil {
+		t.Fatalf("Failed to release collection: %v", err)
+	}
+	if freed != usage || engine.MemoryUsage() != 0 {
+		t.Errorf("Expected %d bytes freed and no usage left, got %d freed and %d used", usage, freed, engine.MemoryUsage())
+	}
+
+	info, err := db.GetCollectionInfo(ctx, "vectors")
+	if err != nil {
+		t.Fatalf("Failed to get released collection info: %v", err)
+	}
+	if info.LoadState != types.LoadStateReleased || info.VectorCount != 10 {
+		t.Errorf("Expected released collection with 10 vectors, got %s with %d", info.LoadState, info.VectorCount)
+	}
+
+	// Releasing again does nothing
+	if freed, err := engine.ReleaseCollection(ctx, "db", "vectors"); err != nil || freed != 0 {
+		t.Errorf("Expected releasing a released collection to be a no-op, got %d, %v", freed, err)
+	}
+
+	if err := engine.LoadCollection(ctx, "db", "vectors"); err != nil {
+		t.Fatalf("Failed to load collection: %v", err)
+	}
+	info, _ = db.GetCollectionInfo(ctx, "vectors")
+	if info.LoadState != types.LoadStateLoaded || info.MemoryBytes != usage {
+		t.Errorf("Expected loaded collection using %d bytes, got %s using %d", usage, info.LoadState, info.MemoryBytes)
+	}
+
+	// Loading again does nothing
+	if err := engine.LoadCollection(ctx, "db", "vectors"); err != nil {
+		t.Errorf("Expected loading a loaded collection to be a no-op, got %v", err)
+	}
+
+	if err := engine.LoadCollection(ctx, "db", "missing"); utils.GetErrorCode(err) != utils.ErrorCodeCollectionNotFound {
+		t.Errorf("Expected CollectionNotFound, got %v", err)
+	}
+}
+
+func TestCopyReleasedCollection(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 10, "vectors")
+	engine.SetLoadPolicy(LoadPolicy{SegmentDir: t.TempDir()})
+	if _, err := engine.ReleaseCollection(ctx, "db", "vectors"); err != nil {
+		t.Fatalf("Failed to release collection: %v", err)
+	}
+
+	// The source is loaded back to be copied
+	if err := engine.CloneCollection(ctx, "db", "vectors", "copy"); err != nil {
+		t.Fatalf("Failed to clone released collection: %v", err)
+	}
+	db, _ := engine.GetDatabase(ctx, "db")
+	for _, name := range []string{"vectors", "copy"} {
+		info, err := db.GetCollectionInfo(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to get collection info of %s: %v", name, err)
+		}
+		if info.VectorCount != 10 || info.LoadState != types.LoadStateLoaded {
+			t.Errorf("Expected %s loaded with 10 vectors, got %d vectors %s", name, info.VectorCount, info.LoadState)
+		}
+	}
+}
+
+func TestReleasedCollectionHandle(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 10, "vectors")
+	engine.SetLoadPolicy(LoadPolicy{SegmentDir: t.TempDir()})
+	db, _ := engine.GetDatabase(ctx, "db")
+
+	// A request that resolved the collection before it was released
+	held, err := db.GetCollection(ctx, "vectors")
+	if err != nil {
+		t.Fatalf("Failed to get collection: %v", err)
+	}
+	if _, err := engine.ReleaseCollection(ctx, "db", "vectors"); err != nil {
+		t.Fatalf("Failed to release collection: %v", err)
+	}
+
+	vectors := []types.Vector{{Elements: []float32{1, 2, 3}}}
+	if err := held.Insert(ctx, vectors); utils.GetErrorCode(err) != utils.ErrorCodeCollectionReleased {
+		t.Errorf("Expected CollectionReleased on insert, got %v", err)
+	}
+	if _, err := held.Search(ctx, []float32{1, 2, 3}, types.SearchParams{TopK: 1}); utils.GetErrorCode(err) != utils.ErrorCodeCollectionReleased {
+		t.Errorf("Expected CollectionReleased on search, got %v", err)
+	}
+	if _, err := held.Count(ctx); utils.GetErrorCode(err) != utils.ErrorCodeCollectionReleased {
+		t.Errorf("Expected CollectionReleased on count, got %v", err)
+	}
+
+	// Retrying resolves the collection again and loads it back
+	collection, err := db.GetCollection(ctx, "vectors")
+	if err != nil {
+		t.Fatalf("Failed to get collection after release: %v", err)
+	}
+	if err := collection.Insert(ctx, vectors); err != nil {
+		t.Fatalf("Failed to insert after reload: %v", err)
+	}
+	if count, _ := collection.Count(ctx); count != 11 {
+		t.Errorf("Expected 11 vectors, got %d", count)
+	}
+}
+
+func TestManualAutoLoad(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 10, "vectors")
+	engine.SetLoadPolicy(LoadPolicy{AutoLoad: AutoLoadManual, SegmentDir: t.TempDir()})
+	db, _ := engine.GetDatabase(ctx, "db")
+
+	if _, err := engine.ReleaseCollection(ctx, "db", "vectors"); err != nil {
+		t.Fatalf("Failed to release collection: %v", err)
+	}
+
+	if _, err := db.GetCollection(ctx, "vectors"); utils.GetErrorCode(err) != utils.ErrorCodeCollectionNotLoaded {
+		t.Fatalf("Expected CollectionNotLoaded, got %v", err)
+	}
+	if err := engine.RenameCollection(ctx, "db", "vectors", "renamed"); utils.GetErrorCode(err) != utils.ErrorCodeCollectionNotLoaded {
+		t.Errorf("Expected CollectionNotLoaded on rename, got %v", err)
+	}
+
+	if err := engine.LoadCollection(ctx, "db", "vectors"); err != nil {
+		t.Fatalf("Failed to load collection: %v", err)
+	}
+	collection, err := db.GetCollection(ctx, "vectors")
+	if err != nil {
+		t.Fatalf("Failed to get loaded collection: %v", err)
+	}
+	if count, _ := collection.Count(ctx); count != 10 {
+		t.Errorf("Expected 10 vectors, got %d", count)
+	}
+}
+
+func TestLazyAutoLoadRestore(t *testing.T) {
+	ctx := context.Background()
+	source := newMemoryTestEngine(t, 10, "first", "second")
+
+	state, err := source.GetDatabaseState(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get database state: %v", err)
+	}
+	manager, err := rdb.NewRDBManager(filepath.Join(t.TempDir(), "dump.rdb"))
+	if err != nil {
+		t.Fatalf("Failed to create RDB manager: %v", err)
+	}
+	snapshot := manager.CreateSnapshot(state)
+
+	engine := NewEngine()
+	engine.SetLoadPolicy(LoadPolicy{AutoLoad: AutoLoadLazy, MaxConcurrentLoads: 1, SegmentDir: t.TempDir()})
+	if err := engine.RestoreFromSnapshot(ctx, &snapshot); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+
+	// Nothing is in memory yet, but all collections are listed
+	if engine.MemoryUsage() != 0 {
+		t.Errorf("Expected no memory usage after lazy restore, got %d", engine.MemoryUsage())
+	}
+	db, _ := engine.GetDatabase(ctx, "db")
+	infos, _ := db.ListCollections(ctx)
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 collections, got %d", len(infos))
+	}
+	for _, info := range infos {
+		if info.LoadState != types.LoadStateReleased || info.VectorCount != 10 || info.Dimension != 3 {
+			t.Errorf("Unexpected info for %s: %s, %d vectors, dimension %d", info.Name, info.LoadState, info.VectorCount, info.Dimension)
+		}
+		if len(info.Partitions) != 1 || info.Partitions[0].Name != types.DefaultPartitionName || info.Partitions[0].VectorCount != 10 {
+			t.Errorf("Unexpected partitions for %s: %+v", info.Name, info.Partitions)
+		}
+	}
+
+	// Concurrent first accesses share a single load
+	const requests = 8
+	collections := make([]core.Collection, requests)
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			collections[i], errs[i] = db.GetCollection(ctx, "first")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < requests; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Failed to load collection: %v", errs[i])
+		}
+		if collections[i] != collections[0] {
+			t.Fatal("Expected all requests to get the same loaded collection")
+		}
+	}
+
+	results, err := collections[0].Search(ctx, []float32{3, 1, 2}, types.SearchParams{TopK: 1})
+	if err != nil || len(results) != 1 || results[0].Vector.ID != 4 {
+		t.Errorf("Expected loaded collection to be searchable, got %v, %v", results, err)
+	}
+
+	if info, _ := db.GetCollectionInfo(ctx, "second"); info.LoadState != types.LoadStateReleased {
+		t.Errorf("Expected untouched collection to stay released, got %s", info.LoadState)
+	}
+	if stats := engine.MemoryStats(); stats.SpilledCollections != 1 {
+		t.Errorf("Expected 1 released collection, got %d", stats.SpilledCollections)
+	}
+}
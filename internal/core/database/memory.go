@@ -0,0 +1,369 @@
+// Package database provides the maxmemory limit and eviction policies.
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// MaxMemoryPolicy decides what happens when a write would exceed the memory limit
+type MaxMemoryPolicy string
+
+const (
+	// MaxMemoryPolicyNoEviction rejects writes that would exceed the limit
+	MaxMemoryPolicyNoEviction MaxMemoryPolicy = "noeviction"
+	// MaxMemoryPolicyAllCollectionsLRU spills the least recently searched collections to disk
+	MaxMemoryPolicyAllCollectionsLRU MaxMemoryPolicy = "allcollections-lru"
+	// MaxMemoryPolicyVolatileTTL evicts the vectors closest to expiry first
+	MaxMemoryPolicyVolatileTTL MaxMemoryPolicy = "volatile-ttl"
+)
+
+// ParseMaxMemoryPolicy validates a policy name. An empty name selects noeviction.
+func ParseMaxMemoryPolicy(name string) (MaxMemoryPolicy, error) {
+	switch policy := MaxMemoryPolicy(name); policy {
+	case "":
+		return MaxMemoryPolicyNoEviction, nil
+	case MaxMemoryPolicyNoEviction, MaxMemoryPolicyAllCollectionsLRU, MaxMemoryPolicyVolatileTTL:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid maxmemory policy: %s", name)
+	}
+}
+
+// MemoryLimit configures the engine memory ceiling.
+// allcollections-lru writes evicted collections to the segment directory of the LoadPolicy.
+type MemoryLimit struct {
+	MaxBytes int64           // Limit on the summed collection MemoryUsage(), 0 disables it
+	Policy   MaxMemoryPolicy // What to do when the limit is reached
+}
+
+// SetMemoryLimit configures the memory ceiling enforced by ReserveMemory
+func (e *Engine) SetMemoryLimit(limit MemoryLimit) {
+	if limit.Policy == "" {
+		limit.Policy = MaxMemoryPolicyNoEviction
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.memoryLimit = limit
+}
+
+// MemoryUsage returns the summed MemoryUsage() of all collections held in memory
+func (e *Engine) MemoryUsage() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var total int64
+	for _, db := range e.databases {
+		db.mu.RLock()
+		for _, collection := range db.collections {
+			total += collection.MemoryUsage()
+		}
+		db.mu.RUnlock()
+	}
+
+	return total
+}
+
+// MemoryStats reports memory usage against the limit together with eviction counters
+func (e *Engine) MemoryStats() types.MemoryStats {
+	used := e.MemoryUsage()
+
+	e.mu.RLock()
+	limit := e.memoryLimit
+	var spilled int
+	for _, db := range e.databases {
+		db.mu.RLock()
+		spilled += len(db.spilled)
+		db.mu.RUnlock()
+	}
+	e.mu.RUnlock()
+
+	return types.MemoryStats{
+		UsedBytes:          used,
+		MaxBytes:           limit.MaxBytes,
+		Policy:             string(limit.Policy),
+		EvictedVectors:     e.evictedVectors.Load(),
+		EvictedCollections: e.evictedCollections.Load(),
+		RejectedWrites:     e.rejectedWrites.Load(),
+		SpilledCollections: spilled,
+	}
+}
+
+// ReserveMemory makes room for inserting vectors into a collection according to the
+// maxmemory policy. It returns the vectors evicted by volatile-ttl, which callers must
+// log as deletes, and ErrOutOfMemory if the write still does not fit.
+// The target collection itself is never spilled to disk.
+func (e *Engine) ReserveMemory(ctx context.Context, dbName, collName string, vectors []types.Vector) ([]ExpiredVectors, error) {
+	e.mu.RLock()
+	limit := e.memoryLimit
+	e.mu.RUnlock()
+
+	if limit.MaxBytes <= 0 {
+		return nil, nil
+	}
+
+	incoming := e.estimateInsertBytes(dbName, collName, vectors)
+
+	// Serialize eviction passes so concurrent writers don't evict twice for the same room
+	e.evictionMu.Lock()
+	defer e.evictionMu.Unlock()
+
+	used := e.MemoryUsage()
+	if used+incoming <= limit.MaxBytes {
+		return nil, nil
+	}
+
+	var evicted []ExpiredVectors
+	var err error
+	switch limit.Policy {
+	case MaxMemoryPolicyAllCollectionsLRU:
+		err = e.evictCollections(ctx, used+incoming-limit.MaxBytes, e.loader.currentPolicy().SegmentDir, dbName, collName)
+	case MaxMemoryPolicyVolatileTTL:
+		evicted, err = e.evictExpiringVectors(ctx, used+incoming-limit.MaxBytes)
+	}
+	if err != nil {
+		return evicted, err
+	}
+
+	used = e.MemoryUsage()
+	if used+incoming > limit.MaxBytes {
+		e.rejectedWrites.Add(1)
+		return evicted, utils.ErrOutOfMemory(used+incoming, limit.MaxBytes)
+	}
+
+	return evicted, nil
+}
+
+// estimateInsertBytes estimates how much memory inserting vectors into a collection adds
+func (e *Engine) estimateInsertBytes(dbName, collName string, vectors []types.Vector) int64 {
+	params := types.DefaultHNSWParams()
+
+	e.mu.RLock()
+	if db, exists := e.databases[dbName]; exists {
+		db.mu.RLock()
+		if collection, exists := db.collections[collName]; exists {
+			params = collection.config.HNSWParams
+		}
+		db.mu.RUnlock()
+	}
+	e.mu.RUnlock()
+
+	var bytes int64
+	for i := range vectors {
+		bytes += vectorMemoryBytes(&vectors[i]) + indexMemoryBytesPerVector(params)
+	}
+	return bytes
+}
+
+// residentCollection identifies an in-memory collection considered for eviction
+type residentCollection struct {
+	dbName     string
+	collName   string
+	db         *Database
+	collection *Collection
+}
+
+// residentCollections lists all in-memory collections ordered by database and collection name
+func (e *Engine) residentCollections() []residentCollection {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var resident []residentCollection
+	for dbName, db := range e.databases {
+		db.mu.RLock()
+		for collName, collection := range db.collections {
+			resident = append(resident, residentCollection{
+				dbName:     dbName,
+				collName:   collName,
+				db:         db,
+				collection: collection,
+			})
+		}
+		db.mu.RUnlock()
+	}
+
+	sort.Slice(resident, func(i, j int) bool {
+		if resident[i].dbName != resident[j].dbName {
+			return resident[i].dbName < resident[j].dbName
+		}
+		return resident[i].collName < resident[j].collName
+	})
+	return resident
+}
+
+// evictCollections spills the least recently searched collections to disk until at
+// least need bytes are released. The collection being written to is skipped.
+func (e *Engine) evictCollections(ctx context.Context, need int64, segmentDir, skipDB, skipColl string) error {
+	if segmentDir == "" {
+		return utils.ErrConfig("allcollections-lru requires a segment directory")
+	}
+
+	candidates := e.residentCollections()
+	lastSearch := make([]time.Time, len(candidates))
+	for i, candidate := range candidates {
+		lastSearch[i] = candidate.collection.LastSearchAt()
+	}
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return lastSearch[order[i]].Before(lastSearch[order[j]])
+	})
+
+	var freed int64
+	for _, i := range order {
+		if freed >= need {
+			break
+		}
+
+		candidate := candidates[i]
+		if candidate.dbName == skipDB && candidate.collName == skipColl {
+			continue
+		}
+
+		bytes, err := candidate.db.spillCollection(ctx, candidate.collName, segmentDir)
+		if err != nil {
+			if utils.GetErrorCode(err) == utils.ErrorCodeCollectionNotFound {
+				continue // Dropped or spilled in the meantime
+			}
+			return err
+		}
+
+		freed += bytes
+		e.evictedCollections.Add(1)
+	}
+
+	return nil
+}
+
+// expiringCandidate is a vector with a TTL that may be evicted under memory pressure
+type expiringCandidate struct {
+	owner    int // Index into the resident collection list
+	id       uint64
+	expireAt int64
+	bytes    int64
+}
+
+// evictExpiringVectors removes vectors with a TTL, soonest expiry first, until at least
+// need bytes are estimated to be released. It returns the evicted IDs per collection in
+// database and collection name order.
+func (e *Engine) evictExpiringVectors(ctx context.Context, need int64) ([]ExpiredVectors, error) {
+	resident := e.residentCollections()
+
+	var candidates []expiringCandidate
+	for i, r := range resident {
+		c := r.collection
+		c.mu.RLock()
+		indexBytes := indexMemoryBytesPerVector(c.config.HNSWParams)
+		for id, expireAt := range c.expiring {
+			candidates = append(candidates, expiringCandidate{
+				owner:    i,
+				id:       id,
+				expireAt: expireAt,
+				bytes:    vectorMemoryBytes(c.vectors[id]) + indexBytes,
+			})
+		}
+		c.mu.RUnlock()
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].expireAt != candidates[j].expireAt {
+			return candidates[i].expireAt < candidates[j].expireAt
+		}
+		if candidates[i].owner != candidates[j].owner {
+			return candidates[i].owner < candidates[j].owner
+		}
+		return candidates[i].id < candidates[j].id
+	})
+
+	selected := make(map[int][]uint64)
+	var freed int64
+	for _, candidate := range candidates {
+		if freed >= need {
+			break
+		}
+		selected[candidate.owner] = append(selected[candidate.owner], candidate.id)
+		freed += candidate.bytes
+	}
+
+	var results []ExpiredVectors
+	for i, r := range resident {
+		ids, exists := selected[i]
+		if !exists {
+			continue
+		}
+
+		evicted, err := r.collection.evictVectors(ctx, ids)
+		if len(evicted) > 0 {
+			results = append(results, ExpiredVectors{
+				Database:   r.dbName,
+				Collection: r.collName,
+				IDs:        evicted,
+			})
+			e.evictedVectors.Add(int64(len(evicted)))
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// evictVectors deletes vectors and compacts their partitions right away so the memory
+// is actually released. It returns the deleted IDs in ascending order.
+func (c *Collection) evictVectors(ctx context.Context, ids []uint64) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checkOpenLocked() != nil {
+		return nil, nil // Released or closed, nothing is left to evict
+	}
+
+	c.preserveLocked()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	affected := make(map[string]*partition)
+	evicted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		vector, exists := c.vectors[id]
+		if !exists {
+			continue
+		}
+
+		deleted, err := c.deleteLocked(ctx, id)
+		if err != nil {
+			return evicted, err
+		}
+		if !deleted {
+			continue
+		}
+
+		evicted = append(evicted, strconv.FormatUint(id, 10))
+		if p, exists := c.partitions[partitionNameOf(vector)]; exists {
+			affected[p.name] = p
+		}
+	}
+
+	for _, p := range affected {
+		if err := c.compactPartitionLocked(ctx, p); err != nil {
+			return evicted, err
+		}
+	}
+
+	c.refreshNextExpiry()
+	c.updatedAt = time.Now()
+	c.updateMemoryUsage()
+
+	return evicted, nil
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// newMemoryTestEngine creates an engine with database "db" and the given collections,
+// each holding count 3-dimensional vectors
+func newMemoryTestEngine(t *testing.T, count int, collections ...string) *Engine {
+	t.Helper()
+	ctx := context.Background()
+
+	engine := NewEngine()
+	if err := engine.CreateDatabase(ctx, "db"); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	db, _ := engine.GetDatabase(ctx, "db")
+
+	for _, name := range collections {
+		config := types.CollectionConfig{
+			Name:       name,
+			Metric:     types.DistanceMetricL2,
+			HNSWParams: types.HNSWParams{M: 16, EfConstruction: 200, EfSearch: 50, MaxLayers: 16, Seed: 12345},
+		}
+		if err := db.CreateCollection(ctx, config); err != nil {
+			t.Fatalf("Failed to create collection %s: %v", name, err)
+		}
+
+		vectors := make([]types.Vector, count)
+		for i := range vectors {
+			vectors[i] = types.Vector{Elements: []float32{float32(i), 1, 2}}
+		}
+		collection, _ := db.GetCollection(ctx, name)
+		if err := collection.Insert(ctx, vectors); err != nil {
+			t.Fatalf("Failed to insert vectors into %s: %v", name, err)
+		}
+	}
+
+	return engine
+}
+
+func TestReserveMemory_NoEviction(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 10, "vectors")
+	incoming := []types.Vector{{Elements: []float32{1, 1, 1}}}
+
+	// Unlimited by default
+	if _, err := engine.ReserveMemory(ctx, "db", "vectors", incoming); err != nil {
+		t.Fatalf("Expected no limit by default, got %v", err)
+	}
+
+	engine.SetMemoryLimit(MemoryLimit{MaxBytes: engine.MemoryUsage()})
+
+	_, err := engine.ReserveMemory(ctx, "db", "vectors", incoming)
+	if utils.GetErrorCode(err) != utils.ErrorCodeResource {
+		t.Fatalf("Expected resource error, got %v", err)
+	}
+
+	stats := engine.MemoryStats()
+	if stats.RejectedWrites != 1 {
+		t.Errorf("Expected 1 rejected write, got %d", stats.RejectedWrites)
+	}
+	if stats.Policy != string(MaxMemoryPolicyNoEviction) {
+		t.Errorf("Expected noeviction policy, got %s", stats.Policy)
+	}
+	if stats.UsedBytes != stats.MaxBytes {
+		t.Errorf("Expected usage %d to be unchanged, got %d", stats.MaxBytes, stats.UsedBytes)
+	}
+}
+
+func TestReserveMemory_VolatileTTL(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 5, "vectors")
+
+	db, _ := engine.GetDatabase(ctx, "db")
+	collection, _ := db.GetCollection(ctx, "vectors")
+
+	// IDs 6, 7 and 8 expire in that order
+	now := time.Now().Unix()
+	expiring := []types.Vector{
+		{Elements: []float32{10, 1, 2}, ExpireAt: now + 100},
+		{Elements: []float32{11, 1, 2}, ExpireAt: now + 200},
+		{Elements: []float32{12, 1, 2}, ExpireAt: now + 300},
+	}
+	if err := collection.Insert(ctx, expiring); err != nil {
+		t.Fatalf("Failed to insert expiring vectors: %v", err)
+	}
+
+	engine.SetMemoryLimit(MemoryLimit{MaxBytes: engine.MemoryUsage(), Policy: MaxMemoryPolicyVolatileTTL})
+
+	evicted, err := engine.ReserveMemory(ctx, "db", "vectors", []types.Vector{{Elements: []float32{1, 1, 1}}})
+	if err != nil {
+		t.Fatalf("Expected eviction to make room, got %v", err)
+	}
+	if len(evicted) != 1 || len(evicted[0].IDs) != 1 || evicted[0].IDs[0] != "6" {
+		t.Fatalf("Expected the soonest expiring vector 6 to be evicted, got %+v", evicted)
+	}
+	if evicted[0].Database != "db" || evicted[0].Collection != "vectors" {
+		t.Errorf("Unexpected eviction target %s/%s", evicted[0].Database, evicted[0].Collection)
+	}
+
+	if _, err := collection.Get(ctx, "6"); utils.GetErrorCode(err) != utils.ErrorCodeVectorNotFound {
+		t.Errorf("Expected evicted vector to be gone, got %v", err)
+	}
+	if count, _ := collection.Count(ctx); count != 7 {
+		t.Errorf("Expected 7 vectors after eviction, got %d", count)
+	}
+	if stats := engine.MemoryStats(); stats.EvictedVectors != 1 {
+		t.Errorf("Expected 1 evicted vector, got %d", stats.EvictedVectors)
+	}
+
+	// Vectors without a TTL are never evicted
+	engine.SetMemoryLimit(MemoryLimit{MaxBytes: 1, Policy: MaxMemoryPolicyVolatileTTL})
+	if _, err := engine.ReserveMemory(ctx, "db", "vectors", []types.Vector{{Elements: []float32{1, 1, 1}}}); utils.GetErrorCode(err) != utils.ErrorCodeResource {
+		t.Errorf("Expected resource error once no TTL vectors remain, got %v", err)
+	}
+	if count, _ := collection.Count(ctx); count != 5 {
+		t.Errorf("Expected only vectors without TTL to remain, got %d", count)
+	}
+}
+
+func TestReserveMemory_AllCollectionsLRU(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 10, "cold", "warm", "target")
+
+	db, _ := engine.GetDatabase(ctx, "db")
+	warm, _ := db.GetCollection(ctx, "warm")
+	if _, err := warm.Search(ctx, []float32{1, 1, 2}, types.SearchParams{TopK: 1}); err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+
+	// Without a segment directory nothing can be spilled
+	engine.SetMemoryLimit(MemoryLimit{MaxBytes: engine.MemoryUsage(), Policy: MaxMemoryPolicyAllCollectionsLRU})
+	if _, err := engine.ReserveMemory(ctx, "db", "target", []types.Vector{{Elements: []float32{1, 1, 1}}}); utils.GetErrorCode(err) != utils.ErrorCodeConfig {
+		t.Fatalf("Expected config error without segment dir, got %v", err)
+	}
+
+	engine.SetLoadPolicy(LoadPolicy{SegmentDir: t.TempDir()})
+	engine.SetMemoryLimit(MemoryLimit{MaxBytes: engine.MemoryUsage(), Policy: MaxMemoryPolicyAllCollectionsLRU})
+	if _, err := engine.ReserveMemory(ctx, "db", "target", []types.Vector{{Elements: []float32{1, 1, 1}}}); err != nil {
+		t.Fatalf("Expected spilling to make room, got %v", err)
+	}
+
+	stats := engine.MemoryStats()
+	if stats.EvictedCollections != 1 || stats.SpilledCollections != 1 {
+		t.Fatalf("Expected one spilled collection, got %+v", stats)
+	}
+
+	// The never searched collection is spilled but still listed
+	info, err := db.GetCollectionInfo(ctx, "cold")
+	if err != nil {
+		t.Fatalf("Failed to get spilled collection info: %v", err)
+	}
+	if info.VectorCount != 10 || info.MemoryBytes != 0 {
+		t.Errorf("Expected 10 vectors and no memory for spilled collection, got %d vectors and %d bytes", info.VectorCount, info.MemoryBytes)
+	}
+	if warm.MemoryUsage() == 0 {
+		t.Error("Expected recently searched collection to stay in memory")
+	}
+
+	// Snapshots include spilled collections
+	state, err := engine.GetDatabaseState(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get database state: %v", err)
+	}
+	if len(state["db"].Collections["cold"].Vectors) != 10 {
+		t.Errorf("Expected spilled collection in database state, got %d vectors", len(state["db"].Collections["cold"].Vectors))
+	}
+
+	// Accessing the collection loads it back
+	cold, err := db.GetCollection(ctx, "cold")
+	if err != nil {
+		t.Fatalf("Failed to reload spilled collection: %v", err)
+	}
+	results, err := cold.Search(ctx, []float32{3, 1, 2}, types.SearchParams{TopK: 1})
+	if err != nil || len(results) != 1 || results[0].Vector.ID != 4 {
+		t.Errorf("Expected reloaded collection to be searchable, got %v, %v", results, err)
+	}
+	if stats := engine.MemoryStats(); stats.SpilledCollections != 0 {
+		t.Errorf("Expected no spilled collections after reload, got %d", stats.SpilledCollections)
+	}
+}
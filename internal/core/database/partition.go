@@ -0,0 +1,443 @@
+// Package database provides partition management for collections.
+package database
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/core/algorithm"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// maxPartitionNameLength limits partition names to keep AOF records and responses small
+const maxPartitionNameLength = 255
+
+// partition is a named subset of a collection with its own index.
+// Vectors and tombstones are owned by the collection; the partition only
+// holds the index and per-partition statistics.
+type partition struct {
+	name      string
+	index     core.VectorIndex // nil while the partition is released
+	createdAt time.Time
+
+	// Statistics
+	vectorCount  int64
+	deletedCount int64
+	memoryBytes  int64
+}
+
+// newPartition creates an empty, loaded partition using the collection's index configuration
+func newPartition(name string, config types.CollectionConfig) (*partition, error) {
+	index, err := algorithm.NewHNSW(config.HNSWParams, config.Metric)
+	if err != nil {
+		return nil, utils.ErrInvalidInput("failed to create HNSW index: " + err.Error())
+	}
+
+	return &partition{
+		name:      name,
+		index:     index,
+		createdAt: time.Now(),
+	}, nil
+}
+
+// loaded reports whether the partition index is in memory and searchable
+func (p *partition) loaded() bool {
+	return p.index != nil
+}
+
+// info returns statistics about the partition
+func (p *partition) info() types.PartitionInfo {
+	return types.PartitionInfo{
+		Name:         p.name,
+		VectorCount:  p.vectorCount - p.deletedCount,
+		DeletedCount: p.deletedCount,
+		MemoryBytes:  p.memoryBytes,
+		Loaded:       p.loaded(),
+		CreatedAt:    p.createdAt,
+	}
+}
+
+// partitionNameOf returns the partition a vector is stored in
+func partitionNameOf(vector *types.Vector) string {
+	if vector.Partition == "" {
+		return types.DefaultPartitionName
+	}
+	return vector.Partition
+}
+
+// storedPartitionName normalizes a partition name for storage on a vector.
+// The default partition is stored as an empty string to keep persisted records compact.
+func storedPartitionName(name string) string {
+	if name == types.DefaultPartitionName {
+		return ""
+	}
+	return name
+}
+
+// validatePartitionName validates a user supplied partition name
+func validatePartitionName(name string) error {
+	if name == "" {
+		return utils.ErrInvalidInput("partition name cannot be empty")
+	}
+	if len(name) > maxPartitionNameLength {
+		return utils.ErrInvalidInput("partition name too long (max 255 characters)")
+	}
+	return nil
+}
+
+// CreatePartition adds an empty partition to the collection
+func (c *Collection) CreatePartition(ctx context.Context, name string) error {
+	if err := validatePartitionName(name); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return err
+	}
+
+	if _, exists := c.partitions[name]; exists {
+		return utils.ErrPartitionAlreadyExists(c.name, name)
+	}
+
+	c.preserveLocked()
+
+	p, err := newPartition(name, c.config)
+	if err != nil {
+		return err
+	}
+
+	c.partitions[name] = p
+	c.updatedAt = time.Now()
+
+	return nil
+}
+
+// DropPartition removes a partition together with all of its vectors.
+// It returns the number of live vectors dropped. The default partition cannot be dropped.
+func (c *Collection) DropPartition(ctx context.Context, name string) (int64, error) {
+	if name == types.DefaultPartitionName {
+		return 0, utils.ErrInvalidInput("the default partition cannot be dropped")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return 0, err
+	}
+
+	p, exists := c.partitions[name]
+	if !exists {
+		return 0, utils.ErrPartitionNotFound(c.name, name)
+	}
+
+	c.preserveLocked()
+	dropped := p.vectorCount - p.deletedCount
+	for id, vector := range c.vectors {
+		if partitionNameOf(vector) != name {
+			continue
+		}
+		delete(c.vectors, id)
+		delete(c.deletedIDs, id)
+		delete(c.expiring, id)
+	}
+
+	c.vectorCount -= p.vectorCount
+	c.deletedCount -= p.deletedCount
+	delete(c.partitions, name)
+
+	c.refreshNextExpiry()
+	c.updatedAt = time.Now()
+	c.updateMemoryUsage()
+
+	return dropped, nil
+}
+
+// LoadPartition builds the partition index from its live vectors so it can be searched again.
+// Loading an already loaded partition is a no-op.
+func (c *Collection) LoadPartition(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return err
+	}
+
+	p, exists := c.partitions[name]
+	if !exists {
+		return utils.ErrPartitionNotFound(c.name, name)
+	}
+	if p.loaded() {
+		return nil
+	}
+
+	c.preserveLocked()
+	index, err := algorithm.NewHNSW(c.config.HNSWParams, c.config.Metric)
+	if err != nil {
+		return utils.ErrInvalidInput("failed to create HNSW index: " + err.Error())
+	}
+
+	if err := index.Build(ctx, c.partitionVectorsLocked(name)); err != nil {
+		return utils.ErrIndexOperationFailed("failed to build partition index: " + err.Error())
+	}
+
+	p.index = index
+	c.updateMemoryUsage()
+
+	return nil
+}
+
+// ReleasePartition frees the partition index. Its vectors are kept and still
+// accept inserts and deletes, but the partition is skipped by searches until loaded again.
+func (c *Collection) ReleasePartition(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return err
+	}
+
+	p, exists := c.partitions[name]
+	if !exists {
+		return utils.ErrPartitionNotFound(c.name, name)
+	}
+
+	c.preserveLocked()
+	p.index = nil
+	c.updateMemoryUsage()
+
+	return nil
+}
+
+// CompactPartition removes deleted vectors of a single partition and rebuilds its index
+func (c *Collection) CompactPartition(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return err
+	}
+
+	p, exists := c.partitions[name]
+	if !exists {
+		return utils.ErrPartitionNotFound(c.name, name)
+	}
+
+	c.preserveLocked()
+	if err := c.compactPartitionLocked(ctx, p); err != nil {
+		return err
+	}
+
+	c.updatedAt = time.Now()
+	c.updateMemoryUsage()
+
+	return nil
+}
+
+// ListPartitions returns statistics for every partition, default partition first
+func (c *Collection) ListPartitions(ctx context.Context) []types.PartitionInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.partitionInfosLocked()
+}
+
+// GetPartitionInfo returns statistics for a single partition
+func (c *Collection) GetPartitionInfo(ctx context.Context, name string) (types.PartitionInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return types.PartitionInfo{}, err
+	}
+
+	p, exists := c.partitions[name]
+	if !exists {
+		return types.PartitionInfo{}, utils.ErrPartitionNotFound(c.name, name)
+	}
+
+	return p.info(), nil
+}
+
+// DeleteFromPartitions marks vectors as deleted, skipping vectors that are not stored
+// in one of the given partitions. An empty partition list behaves like Delete.
+func (c *Collection) DeleteFromPartitions(ctx context.Context, ids []string, partitions []string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.checkOpenLocked(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, utils.ErrInvalidInput("no IDs provided")
+	}
+
+	var allowed map[string]bool
+	if len(partitions) > 0 {
+		allowed = make(map[string]bool, len(partitions))
+		for _, name := range partitions {
+			if _, exists := c.partitions[name]; !exists {
+				return 0, utils.ErrPartitionNotFound(c.name, name)
+			}
+			allowed[name] = true
+		}
+	}
+
+	c.preserveLocked()
+	var deletedCount int
+	for _, idStr := range ids {
+		// Convert string ID to uint64
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			continue // Skip invalid IDs
+		}
+
+		if allowed != nil {
+			vector, exists := c.vectors[id]
+			if !exists || !allowed[partitionNameOf(vector)] {
+				continue
+			}
+		}
+
+		deleted, err := c.deleteLocked(ctx, id)
+		if err != nil {
+			return deletedCount, err
+		}
+		if deleted {
+			deletedCount++
+		}
+	}
+
+	c.updatedAt = time.Now()
+	c.updateMemoryUsage()
+
+	return deletedCount, nil
+}
+
+// compactPartitionLocked drops tombstoned vectors of a partition and rebuilds its index
+// if loaded. Caller must hold c.mu.
+func (c *Collection) compactPartitionLocked(ctx context.Context, p *partition) error {
+	for id := range c.deletedIDs {
+		vector, exists := c.vectors[id]
+		if !exists || partitionNameOf(vector) != p.name {
+			continue
+		}
+		delete(c.vectors, id)
+		delete(c.deletedIDs, id)
+		c.deletedCount--
+		c.vectorCount--
+	}
+
+	p.vectorCount -= p.deletedCount
+	p.deletedCount = 0
+
+	if p.loaded() {
+		if err := p.index.Build(ctx, c.partitionVectorsLocked(p.name)); err != nil {
+			return utils.ErrIndexOperationFailed("failed to rebuild index: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// partitionVectorsLocked returns copies of the live vectors stored in a partition,
+// ordered by ID so index builds are reproducible. Caller must hold c.mu.
+func (c *Collection) partitionVectorsLocked(name string) []types.Vector {
+	vectors := make([]types.Vector, 0)
+	for id, vector := range c.vectors {
+		if c.deletedIDs[id] || partitionNameOf(vector) != name {
+			continue
+		}
+		vectors = append(vectors, *vector)
+	}
+	sort.Slice(vectors, func(i, j int) bool { return vectors[i].ID < vectors[j].ID })
+	return vectors
+}
+
+// searchTargetsLocked resolves the partitions a search should visit.
+// Without explicit names every loaded partition is searched; explicitly named
+// partitions must exist and be loaded. Caller must hold c.mu.
+func (c *Collection) searchTargetsLocked(names []string) ([]*partition, error) {
+	targets := make([]*partition, 0, len(c.partitions))
+
+	if len(names) == 0 {
+		for _, name := range c.sortedPartitionNamesLocked() {
+			if p := c.partitions[name]; p.loaded() {
+				targets = append(targets, p)
+			}
+		}
+		return targets, nil
+	}
+
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		p, exists := c.partitions[name]
+		if !exists {
+			return nil, utils.ErrPartitionNotFound(c.name, name)
+		}
+		if !p.loaded() {
+			return nil, utils.ErrPartitionNotLoaded(c.name, name)
+		}
+		targets = append(targets, p)
+	}
+
+	return targets, nil
+}
+
+// sortedPartitionNamesLocked returns partition names with the default partition first
+// and the rest in lexical order. Caller must hold c.mu.
+func (c *Collection) sortedPartitionNamesLocked() []string {
+	names := make([]string, 0, len(c.partitions))
+	for name := range c.partitions {
+		if name != types.DefaultPartitionName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if _, exists := c.partitions[types.DefaultPartitionName]; exists {
+		names = append([]string{types.DefaultPartitionName}, names...)
+	}
+	return names
+}
+
+// partitionInfosLocked returns statistics for all partitions. Caller must hold c.mu.
+func (c *Collection) partitionInfosLocked() []types.PartitionInfo {
+	infos := make([]types.PartitionInfo, 0, len(c.partitions))
+	for _, name := range c.sortedPartitionNamesLocked() {
+		infos = append(infos, c.partitions[name].info())
+	}
+	return infos
+}
+
+// rebuildPartitionStats recomputes per-partition counters from the stored vectors. Caller must hold c.mu.
+func (c *Collection) rebuildPartitionStats() {
+	for _, p := range c.partitions {
+		p.vectorCount = 0
+		p.deletedCount = 0
+	}
+
+	for id, vector := range c.vectors {
+		p, exists := c.partitions[partitionNameOf(vector)]
+		if !exists {
+			continue
+		}
+		p.vectorCount++
+		if c.deletedIDs[id] {
+			p.deletedCount++
+		}
+	}
+}
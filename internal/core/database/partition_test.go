@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// newPartitionedCollection creates a collection with vectors spread over the default partition and "hot"
+func newPartitionedCollection(t *testing.T) *Collection {
+	t.Helper()
+	ctx := context.Background()
+
+	config := types.CollectionConfig{
+		Name:       "partitioned",
+		Metric:     types.DistanceMetricL2,
+		HNSWParams: types.HNSWParams{M: 16, EfConstruction: 200, EfSearch: 50, MaxLayers: 16, Seed: 12345},
+	}
+	collection, err := NewCollection("partitioned", config)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	if err := collection.CreatePartition(ctx, "hot"); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+
+	defaultVectors := make([]types.Vector, 5)
+	hotVectors := make([]types.Vector, 3)
+	for i := range defaultVectors {
+		defaultVectors[i] = types.Vector{Elements: []float32{float32(i), 0, 0}}
+	}
+	for i := range hotVectors {
+		hotVectors[i] = types.Vector{Elements: []float32{float32(i) + 0.5, 0, 0}, Partition: "hot"}
+	}
+	if err := collection.Insert(ctx, defaultVectors); err != nil {
+		t.Fatalf("Failed to insert default vectors: %v", err)
+	}
+	if err := collection.Insert(ctx, hotVectors); err != nil {
+		t.Fatalf("Failed to insert hot vectors: %v", err)
+	}
+
+	return collection
+}
+
+func TestCollectionPartitions(t *testing.T) {
+	ctx := context.Background()
+	collection := newPartitionedCollection(t)
+
+	if err := collection.CreatePartition(ctx, "hot"); utils.GetErrorCode(err) != utils.ErrorCodePartitionAlreadyExists {
+		t.Errorf("Expected PartitionAlreadyExists, got %v", err)
+	}
+	if err := collection.Insert(ctx, []types.Vector{{Elements: []float32{1, 1, 1}, Partition: "missing"}}); utils.GetErrorCode(err) != utils.ErrorCodePartitionNotFound {
+		t.Errorf("Expected PartitionNotFound on insert, got %v", err)
+	}
+
+	// Restricting a search to one partition only returns its vectors
+	results, err := collection.Search(ctx, []float32{0, 0, 0}, types.SearchParams{TopK: 10, Partitions: []string{"hot"}})
+	if err != nil {
+		t.Fatalf("Partition search failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results from hot partition, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Vector.Partition != "hot" {
+			t.Errorf("Expected only hot vectors, got partition %q", result.Vector.Partition)
+		}
+	}
+
+	// Searching all partitions merges results by distance
+	results, err = collection.Search(ctx, []float32{0, 0, 0}, types.SearchParams{TopK: 4})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Distance < results[i-1].Distance {
+			t.Errorf("Results not ordered by distance at %d", i)
+		}
+	}
+
+	// Released partitions are skipped by default and rejected when named explicitly
+	if err := collection.ReleasePartition(ctx, "hot"); err != nil {
+		t.Fatalf("Failed to release partition: %v", err)
+	}
+	if _, err := collection.Search(ctx, []float32{0, 0, 0}, types.SearchParams{TopK: 10, Partitions: []string{"hot"}}); utils.GetErrorCode(err) != utils.ErrorCodePartitionNotLoaded {
+		t.Errorf("Expected PartitionNotLoaded, got %v", err)
+	}
+	results, err = collection.Search(ctx, []float32{0, 0, 0}, types.SearchParams{TopK: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("Expected 5 results with hot released, got %d", len(results))
+	}
+
+	// Deletes restricted to a partition leave other partitions alone
+	hotID := fmt.Sprintf("%d", 6) // IDs 1-5 are in the default partition
+	deleted, err := collection.DeleteFromPartitions(ctx, []string{"1", hotID}, []string{"hot"})
+	if err != nil {
+		t.Fatalf("DeleteFromPartitions failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 deleted vector, got %d", deleted)
+	}
+
+	if err := collection.LoadPartition(ctx, "hot"); err != nil {
+		t.Fatalf("Failed to load partition: %v", err)
+	}
+	results, err = collection.Search(ctx, []float32{0, 0, 0}, types.SearchParams{TopK: 10, Partitions: []string{"hot"}})
+	if err != nil {
+		t.Fatalf("Partition search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results after load, got %d", len(results))
+	}
+
+	info, err := collection.GetPartitionInfo(ctx, "hot")
+	if err != nil {
+		t.Fatalf("GetPartitionInfo failed: %v", err)
+	}
+	if info.VectorCount != 2 || info.DeletedCount != 1 || !info.Loaded {
+		t.Errorf("Unexpected hot partition info: %+v", info)
+	}
+
+	if err := collection.CompactPartition(ctx, "hot"); err != nil {
+		t.Fatalf("CompactPartition failed: %v", err)
+	}
+	info, _ = collection.GetPartitionInfo(ctx, "hot")
+	if info.DeletedCount != 0 || info.VectorCount != 2 {
+		t.Errorf("Unexpected hot partition info after compact: %+v", info)
+	}
+
+	collInfo := collection.Info()
+	if len(collInfo.Partitions) != 2 || collInfo.Partitions[0].Name != types.DefaultPartitionName {
+		t.Fatalf("Unexpected partitions in collection info: %+v", collInfo.Partitions)
+	}
+	if collInfo.VectorCount != 7 {
+		t.Errorf("Expected 7 vectors, got %d", collInfo.VectorCount)
+	}
+
+	if _, err := collection.DropPartition(ctx, types.DefaultPartitionName); err == nil {
+		t.Errorf("Expected dropping the default partition to fail")
+	}
+	dropped, err := collection.DropPartition(ctx, "hot")
+	if err != nil {
+		t.Fatalf("DropPartition failed: %v", err)
+	}
+	if dropped != 2 {
+		t.Errorf("Expected 2 dropped vectors, got %d", dropped)
+	}
+	if count, _ := collection.Count(ctx); count != 5 {
+		t.Errorf("Expected 5 vectors after drop, got %d", count)
+	}
+}
+
+func TestPartitionSnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	engine := NewEngine()
+	if err := engine.CreateDatabase(ctx, "db"); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	database := engine.databases["db"]
+	collection := newPartitionedCollection(t)
+	database.collections["partitioned"] = collection
+
+	if err := collection.CreatePartition(ctx, "cold"); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+	if err := collection.Insert(ctx, []types.Vector{{Elements: []float32{9, 9, 9}, Partition: "cold"}}); err != nil {
+		t.Fatalf("Failed to insert cold vector: %v", err)
+	}
+	if err := collection.ReleasePartition(ctx, "cold"); err != nil {
+		t.Fatalf("Failed to release partition: %v", err)
+	}
+
+	state, err := engine.GetDatabaseState(ctx)
+	if err != nil {
+		t.Fatalf("GetDatabaseState failed: %v", err)
+	}
+
+	manager, err := rdb.NewRDBManager(filepath.Join(t.TempDir(), "dump.rdb"))
+	if err != nil {
+		t.Fatalf("Failed to create RDB manager: %v", err)
+	}
+	if err := manager.Save(ctx, manager.CreateSnapshot(state)); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	snapshot, err := manager.Load(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	restored := NewEngine()
+	if err := restored.RestoreFromSnapshot(ctx, snapshot); err != nil {
+		t.Fatalf("RestoreFromSnapshot failed: %v", err)
+	}
+
+	restoredDB, err := restored.GetDatabase(ctx, "db")
+	if err != nil {
+		t.Fatalf("Failed to get restored database: %v", err)
+	}
+	restoredColl, err := restoredDB.GetCollection(ctx, "partitioned")
+	if err != nil {
+		t.Fatalf("Failed to get restored collection: %v", err)
+	}
+
+	expected := collection.ListPartitions(ctx)
+	actual := restoredColl.ListPartitions(ctx)
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d partitions, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if actual[i].Name != expected[i].Name || actual[i].VectorCount != expected[i].VectorCount || actual[i].Loaded != expected[i].Loaded {
+			t.Errorf("Partition %d mismatch: expected %+v, got %+v", i, expected[i], actual[i])
+		}
+	}
+
+	results, err := restoredColl.Search(ctx, []float32{0, 0, 0}, types.SearchParams{TopK: 10, Partitions: []string{"hot"}})
+	if err != nil {
+		t.Fatalf("Search on restored partition failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 results from restored hot partition, got %d", len(results))
+	}
+}
@@ -0,0 +1,168 @@
+// Package database provides on-disk collection segments for collections evicted from memory.
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// spilledCollection is a collection whose data lives in a segment file instead of memory.
+// A segment is an RDB snapshot containing a single database with a single collection.
+type spilledCollection struct {
+	dbName  string
+	path    string
+	info    types.CollectionInfo   // Info at spill time; MemoryBytes is reported as 0
+	keys    encryption.KeyProvider // Keys the segment was written with, nil if it is unencrypted
+	loading *loadCall              // Load in progress, guarded by the database lock
+}
+
+// currentInfo returns the collection info including its load state. Caller must hold the database lock.
+func (s *spilledCollection) currentInfo() types.CollectionInfo {
+	info := s.info
+	info.LoadState = types.LoadStateReleased
+	if s.loading != nil {
+		info.LoadState = types.LoadStateLoading
+	}
+	return info
+}
+
+// segmentPath returns the segment file of a collection inside dir.
+// Names are escaped so any database or collection name maps to a single file.
+func segmentPath(dir, dbName, collName string) string {
+	return filepath.Join(dir, url.PathEscape(dbName), url.PathEscape(collName)+".rdb")
+}
+
+// load reads the segment back into a new in-memory collection
+func (s *spilledCollection) load(ctx context.Context) (*Collection, error) {
+	manager, err := rdb.NewRDBManager(s.path)
+	if err != nil {
+		return nil, err
+	}
+	manager.SetEncryption(s.keys)
+
+	snapshot, err := manager.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("segment %s not found", s.path)
+	}
+
+	collSnapshot, exists := snapshot.Databases[s.dbName].Collections[s.info.Name]
+	if !exists {
+		return nil, fmt.Errorf("segment %s does not contain collection %s", s.path, s.info.Name)
+	}
+
+	return restoreCollection(ctx, collSnapshot)
+}
+
+// writeSegment stores a collection snapshot as a segment in dir without building it in
+// memory, encrypted with keys if set
+func writeSegment(ctx context.Context, dir string, keys encryption.KeyProvider, dbSnapshot rdb.DatabaseSnapshot, collSnapshot rdb.CollectionSnapshot) (*spilledCollection, error) {
+	path := segmentPath(dir, dbSnapshot.Name, collSnapshot.Name)
+	manager, err := rdb.NewRDBManager(path)
+	if err != nil {
+		return nil, err
+	}
+	manager.SetEncryption(keys)
+
+	snapshot := manager.CreateSnapshot(nil)
+	snapshot.Databases[dbSnapshot.Name] = rdb.DatabaseSnapshot{
+		Name:        dbSnapshot.Name,
+		Collections: map[string]rdb.CollectionSnapshot{collSnapshot.Name: collSnapshot},
+		CreatedAt:   dbSnapshot.CreatedAt,
+	}
+	if err := manager.Save(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	return &spilledCollection{
+		dbName: dbSnapshot.Name,
+		path:   path,
+		info:   snapshotCollectionInfo(collSnapshot),
+		keys:   keys,
+	}, nil
+}
+
+// remove deletes the segment file
+func (s *spilledCollection) remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// spillCollection writes a collection to a segment file in dir and frees its memory.
+// It returns the number of bytes released. Requests still holding the collection
+// fail with ErrCollectionReleased once it is spilled and have to look it up again.
+func (d *Database) spillCollection(ctx context.Context, name, dir string) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	collection, exists := d.collections[name]
+	if !exists {
+		return 0, utils.ErrCollectionNotFound(d.name, name)
+	}
+
+	path := segmentPath(dir, d.name, name)
+	manager, err := rdb.NewRDBManager(path)
+	if err != nil {
+		return 0, err
+	}
+	keys := d.loader.currentPolicy().Keys
+	manager.SetEncryption(keys)
+
+	// Hold the collection lock from export to close so no write is lost in between
+	collection.mu.Lock()
+	defer collection.mu.Unlock()
+
+	info := collection.infoLocked()
+	state := collectionStateLocked(collection)
+	if cut := collection.cut.Swap(nil); cut != nil {
+		cut.preserve(collection, state) // A snapshot in progress takes the exported state as is
+	}
+	snapshot := manager.CreateSnapshot(map[string]rdb.DatabaseState{
+		d.name: {
+			Name:        d.name,
+			Collections: map[string]rdb.CollectionState{name: state},
+			CreatedAt:   d.createdAt,
+		},
+	})
+	if err := manager.Save(ctx, snapshot); err != nil {
+		return 0, err
+	}
+
+	// Nothing stays in memory, and deleted vectors are not part of the segment
+	freed := info.MemoryBytes
+	info.MemoryBytes = 0
+	info.DeletedCount = 0
+	for i := range info.Partitions {
+		info.Partitions[i].MemoryBytes = 0
+		info.Partitions[i].DeletedCount = 0
+	}
+
+	// Clear data structures
+	collection.spilled = true
+	collection.vectors = nil
+	collection.deletedIDs = nil
+	collection.expiring = nil
+	collection.partitions = nil
+
+	delete(d.collections, name)
+	d.spilled[name] = &spilledCollection{
+		dbName: d.name,
+		path:   path,
+		info:   info,
+		keys:   keys,
+	}
+
+	return freed, nil
+}
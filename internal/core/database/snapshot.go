@@ -0,0 +1,258 @@
+// Package database provides point-in-time snapshot cuts of the engine.
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// BeginWrite holds off snapshot cuts until EndWrite. Callers that change the engine and
+// log the change to the AOF hold it across both, so a cut never falls between a change
+// and its log record. It must not be taken twice by the same goroutine.
+func (e *Engine) BeginWrite() {
+	e.writes.RLock()
+}
+
+// EndWrite ends a write started with BeginWrite
+func (e *Engine) EndWrite() {
+	e.writes.RUnlock()
+}
+
+// HoldWrites runs fn while no write is between the engine and the AOF, for changes that
+// replace the whole state and the AOF along with it, such as restoring a backup
+func (e *Engine) HoldWrites(fn func() error) error {
+	e.writes.Lock()
+	defer e.writes.Unlock()
+	return fn()
+}
+
+// CutSnapshot freezes the state of all databases at a single point in time. mark runs
+// while no write is between the engine and the AOF, typically to record the AOF position
+// the snapshot corresponds to. The returned cut streams the state as it was when mark
+// ran, while requests keep changing the engine: a collection changed before the cut
+// reached it hands over a copy of its old state first. Only one cut can be open at a time.
+func (e *Engine) CutSnapshot(ctx context.Context, mark func() error) (rdb.SnapshotCut, error) {
+	e.writes.Lock()
+	defer e.writes.Unlock()
+
+	cut := &snapshotCut{
+		engine:    e,
+		preserved: make(map[*Collection]rdb.CollectionState),
+	}
+	if !e.cut.CompareAndSwap(nil, cut) {
+		return nil, utils.ErrPersistenceFailed("another snapshot is in progress")
+	}
+
+	if err := mark(); err != nil {
+		e.cut.Store(nil)
+		return nil, err
+	}
+	if err := cut.capture(); err != nil {
+		cut.Release()
+		return nil, err
+	}
+
+	return cut, nil
+}
+
+// snapshotCut is the state of the engine at the moment CutSnapshot ran
+type snapshotCut struct {
+	engine    *Engine
+	databases []cutDatabase
+
+	mu        sync.Mutex
+	preserved map[*Collection]rdb.CollectionState // Copies of collections changed after the cut, nil once released
+}
+
+// cutDatabase lists the collections a database had at the cut
+type cutDatabase struct {
+	name        string
+	createdAt   time.Time
+	collections []cutCollection
+}
+
+// cutCollection is a collection at the cut, either in memory or in a segment
+type cutCollection struct {
+	name       string
+	collection *Collection            // nil for released collections
+	dbName     string                 // Database the segment was written for
+	segment    *os.File               // Opened at the cut so the content stays readable when the segment is replaced or removed
+	keys       encryption.KeyProvider // Keys the segment was written with
+}
+
+// capture records every database and collection and marks the collections as owed to the cut
+func (c *snapshotCut) capture() error {
+	e := c.engine
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	dbNames := make([]string, 0, len(e.databases))
+	for name := range e.databases {
+		dbNames = append(dbNames, name)
+	}
+	sort.Strings(dbNames)
+
+	for _, dbName := range dbNames {
+		db := e.databases[dbName]
+		c.databases = append(c.databases, cutDatabase{name: dbName, createdAt: db.createdAt})
+		if err := c.captureDatabase(db, &c.databases[len(c.databases)-1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// captureDatabase records the collections of a database
+func (c *snapshotCut) captureDatabase(db *Database, cutDB *cutDatabase) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	names := make([]string, 0, len(db.collections)+len(db.spilled))
+	for name := range db.collections {
+		names = append(names, name)
+	}
+	for name := range db.spilled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if collection, resident := db.collections[name]; resident {
+			collection.cut.Store(c)
+			cutDB.collections = append(cutDB.collections, cutCollection{name: name, collection: collection})
+			continue
+		}
+
+		spilled := db.spilled[name]
+		segment, err := os.Open(spilled.path)
+		if err != nil {
+			return fmt.Errorf("failed to open segment of collection %s: %w", name, err)
+		}
+		cutDB.collections = append(cutDB.collections, cutCollection{name: name, dbName: spilled.dbName, segment: segment, keys: spilled.keys})
+	}
+
+	return nil
+}
+
+// StreamDatabaseState passes the state at the cut to visitor one collection at a time
+func (c *snapshotCut) StreamDatabaseState(ctx context.Context, visitor rdb.StateVisitor) error {
+	for _, db := range c.databases {
+		if err := visitor.VisitDatabase(rdb.DatabaseState{Name: db.name, CreatedAt: db.createdAt}); err != nil {
+			return err
+		}
+
+		for _, coll := range db.collections {
+			state, err := c.collectionState(coll)
+			if err != nil {
+				return fmt.Errorf("failed to export collection %s of database %s: %w", coll.name, db.name, err)
+			}
+			if err := visitor.VisitCollection(db.name, state); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectionState returns the state a collection had at the cut. An unchanged collection
+// is copied now, a changed one has handed over its copy already.
+func (c *snapshotCut) collectionState(coll cutCollection) (rdb.CollectionState, error) {
+	if coll.segment != nil {
+		return segmentState(coll.segment, coll.keys, coll.dbName, coll.name)
+	}
+
+	collection := coll.collection
+	collection.mu.RLock()
+	if collection.cut.CompareAndSwap(c, nil) {
+		defer collection.mu.RUnlock()
+		return collectionStateLocked(collection), nil
+	}
+	collection.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, exists := c.preserved[collection]
+	if !exists {
+		return rdb.CollectionState{}, fmt.Errorf("state at the snapshot cut is missing")
+	}
+	delete(c.preserved, collection)
+	return state, nil
+}
+
+// preserve keeps the state a collection had at the cut before it changes
+func (c *snapshotCut) preserve(collection *Collection, state rdb.CollectionState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.preserved != nil {
+		c.preserved[collection] = state
+	}
+}
+
+// Release frees the copies and segments kept for the cut and allows the next cut
+func (c *snapshotCut) Release() {
+	for _, db := range c.databases {
+		for _, coll := range db.collections {
+			if coll.collection != nil {
+				coll.collection.cut.CompareAndSwap(c, nil)
+			}
+			if coll.segment != nil {
+				coll.segment.Close()
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.preserved = nil
+	c.mu.Unlock()
+
+	c.engine.cut.CompareAndSwap(c, nil)
+}
+
+// preserveLocked hands the current state to a cut still owed this collection, before a
+// change makes it diverge. Caller must hold c.mu for writing.
+func (c *Collection) preserveLocked() {
+	if cut := c.cut.Swap(nil); cut != nil {
+		cut.preserve(c, collectionStateLocked(c))
+	}
+}
+
+// segmentState reads the state of a collection from its segment
+func segmentState(segment *os.File, keys encryption.KeyProvider, dbName, collName string) (rdb.CollectionState, error) {
+	picker := &collectionPicker{dbName: dbName, collName: collName}
+	if _, err := rdb.ReadStreamWithKeys(segment, keys, picker); err != nil {
+		return rdb.CollectionState{}, err
+	}
+	if picker.found == nil {
+		return rdb.CollectionState{}, fmt.Errorf("segment %s does not contain collection %s", segment.Name(), collName)
+	}
+
+	return rdb.CollectionStateFromSnapshot(*picker.found)
+}
+
+// collectionPicker keeps a single collection of a snapshot
+type collectionPicker struct {
+	dbName   string
+	collName string
+	found    *rdb.CollectionSnapshot
+}
+
+func (p *collectionPicker) VisitDatabase(rdb.DatabaseSnapshot) error { return nil }
+
+func (p *collectionPicker) VisitCollection(dbName string, coll rdb.CollectionSnapshot) error {
+	if dbName == p.dbName && coll.Name == p.collName {
+		p.found = &coll
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+func TestCutSnapshot(t *testing.T) {
+	ctx := context.Background()
+	engine := newMemoryTestEngine(t, 10, "changed", "dropped", "partition", "released", "renamed", "spilled", "unchanged")
+	engine.SetLoadPolicy(LoadPolicy{SegmentDir: t.TempDir()})
+	if _, err := engine.ReleaseCollection(ctx, "db", "released"); err != nil {
+		t.Fatalf("Failed to release collection: %v", err)
+	}
+
+	marked := false
+	cut, err := engine.CutSnapshot(ctx, func() error {
+		marked = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to cut snapshot: %v", err)
+	}
+	if !marked {
+		t.Error("Expected mark to run during the cut")
+	}
+	if _, err := engine.CutSnapshot(ctx, func() error { return nil }); err == nil {
+		t.Error("Expected a second cut to fail while the first is open")
+	}
+
+	// Change everything after the cut
+	db, _ := engine.GetDatabase(ctx, "db")
+	changed, _ := db.GetCollection(ctx, "changed")
+	if err := changed.Insert(ctx, []types.Vector{{Elements: []float32{9, 9, 9}}}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if _, err := changed.Delete(ctx, []string{"1", "2"}); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	partition, _ := db.GetCollection(ctx, "partition")
+	if err := partition.ReleasePartition(ctx, types.DefaultPartitionName); err != nil {
+		t.Fatalf("Failed to release partition: %v", err)
+	}
+	if err := db.DropCollection(ctx, "dropped"); err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	released, _ := db.GetCollection(ctx, "released") // Loads the collection and removes its segment
+	if err := released.Insert(ctx, []types.Vector{{Elements: []float32{9, 9, 9}}}); err != nil {
+		t.Fatalf("Failed to insert into loaded collection: %v", err)
+	}
+	if err := engine.RenameCollection(ctx, "db", "renamed", "moved"); err != nil {
+		t.Fatalf("Failed to rename collection: %v", err)
+	}
+	if _, err := engine.ReleaseCollection(ctx, "db", "spilled"); err != nil {
+		t.Fatalf("Failed to release collection: %v", err)
+	}
+	if err := engine.CreateDatabase(ctx, "late"); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	manager, err := rdb.NewRDBManager(filepath.Join(t.TempDir(), "cut.rdb"))
+	if err != nil {
+		t.Fatalf("Failed to create RDB manager: %v", err)
+	}
+	if err := manager.SaveStream(ctx, cut); err != nil {
+		t.Fatalf("Failed to save cut: %v", err)
+	}
+	cut.Release()
+
+	snapshot, err := manager.Load(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if len(snapshot.Databases) != 1 {
+		t.Errorf("Expected only the database existing at the cut, got %d databases", len(snapshot.Databases))
+	}
+	collections := snapshot.Databases["db"].Collections
+	for _, name := range []string{"changed", "dropped", "partition", "released", "renamed", "spilled", "unchanged"} {
+		coll, exists := collections[name]
+		if !exists {
+			t.Errorf("Expected collection %s in the snapshot", name)
+			continue
+		}
+		if len(coll.Vectors) != 10 || coll.HNSWGraph == nil {
+			t.Errorf("Expected %s with 10 vectors and a graph as at the cut, got %d vectors", name, len(coll.Vectors))
+		}
+	}
+	if len(collections) != 7 {
+		t.Errorf("Expected 7 collections, got %d", len(collections))
+	}
+
+	// The live engine kept every change
+	info, _ := db.GetCollectionInfo(ctx, "changed")
+	if info.VectorCount != 9 {
+		t.Errorf("Expected 9 live vectors after the changes, got %d", info.VectorCount)
+	}
+
+	// Releasing allows the next cut, a failing mark releases it again
+	markErr := errors.New("mark failed")
+	if _, err := engine.CutSnapshot(ctx, func() error { return markErr }); !errors.Is(err, markErr) {
+		t.Fatalf("Expected the mark error, got %v", err)
+	}
+	cut, err = engine.CutSnapshot(ctx, func() error { return nil })
+	if err != nil {
+		t.Fatalf("Failed to cut snapshot after release: %v", err)
+	}
+	cut.Release()
+}
@@ -79,6 +79,42 @@ type Collection interface {
 	// Compact removes deleted vectors and rebuilds the index for better performance.
 	Compact(ctx context.Context) error
 
+	// MemoryUsage returns the estimated memory used by the collection's vectors and indexes in bytes.
+	MemoryUsage() int64
+
+	// DeleteFromPartitions is like Delete but only removes vectors stored in the given partitions.
+	DeleteFromPartitions(ctx context.Context, ids []string, partitions []string) (int, error)
+
+	// CreatePartition adds an empty named partition that shares the collection schema.
+	CreatePartition(ctx context.Context, name string) error
+
+	// DropPartition removes a partition and its vectors. Returns the number of vectors dropped.
+	DropPartition(ctx context.Context, name string) (int64, error)
+
+	// LoadPartition builds the partition index so the partition can be searched.
+	LoadPartition(ctx context.Context, name string) error
+
+	// ReleasePartition frees the partition index; its vectors are kept but not searchable.
+	ReleasePartition(ctx context.Context, name string) error
+
+	// CompactPartition removes deleted vectors from one partition and rebuilds its index.
+	CompactPartition(ctx context.Context, name string) error
+
+	// ListPartitions returns statistics for every partition in the collection.
+	ListPartitions(ctx context.Context) []types.PartitionInfo
+
+	// GetPartitionInfo returns statistics for a single partition.
+	GetPartitionInfo(ctx context.Context, name string) (types.PartitionInfo, error)
+
+	// Scan returns up to limit live vectors with an ID greater than afterID in ascending ID order.
+	Scan(ctx context.Context, afterID uint64, limit int) ([]types.Vector, error)
+
+	// ExistingIDs returns the IDs among ids that the collection holds, live or deleted.
+	ExistingIDs(ids []uint64) []uint64
+
+	// GraphStates exports the HNSW graph of every loaded partition, by partition name.
+	GraphStates(ctx context.Context) (map[string]HNSWGraphState, error)
+
 	// Close closes the collection and releases resources.
 	Close() error
 }
@@ -56,6 +56,13 @@ type PrometheusCollector struct {
 	deletedCount      *Gauge
 	memoryUsage       *Gauge
 
+	// Memory limit metrics
+	maxMemory          *Gauge
+	evictedVectors     *Gauge
+	evictedCollections *Gauge
+	rejectedWrites     *Gauge
+	spilledCollections *Gauge
+
 	// System metrics
 	uptime    *Gauge
 	startTime time.Time
@@ -145,6 +152,36 @@ func NewPrometheusCollector(config Config) *PrometheusCollector {
 		map[string]string{},
 	)
 
+	collector.maxMemory = newGauge(
+		prefix+"_maxmemory_bytes",
+		"Configured memory limit in bytes, 0 if unlimited",
+		map[string]string{},
+	)
+
+	collector.evictedVectors = newGauge(
+		prefix+"_evicted_vectors_total",
+		"Total number of vectors evicted by the volatile-ttl policy",
+		map[string]string{},
+	)
+
+	collector.evictedCollections = newGauge(
+		prefix+"_evicted_collections_total",
+		"Total number of collections spilled to disk by the allcollections-lru policy",
+		map[string]string{},
+	)
+
+	collector.rejectedWrites = newGauge(
+		prefix+"_rejected_writes_total",
+		"Total number of writes rejected because the memory limit was reached",
+		map[string]string{},
+	)
+
+	collector.spilledCollections = newGauge(
+		prefix+"_spilled_collections",
+		"Number of collections currently spilled to disk",
+		map[string]string{},
+	)
+
 	collector.uptime = newGauge(
 		prefix+"_uptime_seconds",
 		"Server uptime in seconds",
@@ -197,6 +234,13 @@ func (c *PrometheusCollector) UpdateGaugeMetrics(snapshot types.MetricsSnapshot)
 	// Update memory usage
 	c.memoryUsage.Set(float64(snapshot.MemoryUsage))
 
+	// Update memory limit and eviction metrics
+	c.maxMemory.Set(float64(snapshot.Memory.MaxBytes))
+	c.evictedVectors.Set(float64(snapshot.Memory.EvictedVectors))
+	c.evictedCollections.Set(float64(snapshot.Memory.EvictedCollections))
+	c.rejectedWrites.Set(float64(snapshot.Memory.RejectedWrites))
+	c.spilledCollections.Set(float64(snapshot.Memory.SpilledCollections))
+
 	// Update uptime
 	c.uptime.Set(time.Since(c.startTime).Seconds())
 }
@@ -232,6 +276,11 @@ func (c *PrometheusCollector) GetMetrics() ([]byte, error) {
 	writeMetric(c.vectorCount)
 	writeMetric(c.deletedCount)
 	writeMetric(c.memoryUsage)
+	writeMetric(c.maxMemory)
+	writeMetric(c.evictedVectors)
+	writeMetric(c.evictedCollections)
+	writeMetric(c.rejectedWrites)
+	writeMetric(c.spilledCollections)
 	writeMetric(c.uptime)
 
 	// Write custom metrics
@@ -4,7 +4,7 @@ package aof
 import (
 	"bufio"
 	"context"
-	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +15,7 @@ import (
 
 	flatbuffers "github.com/google/flatbuffers/go"
 	fbaof "github.com/scintirete/scintirete/internal/flatbuffers/aof"
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
 	"github.com/scintirete/scintirete/internal/utils"
 	"github.com/scintirete/scintirete/pkg/types"
 )
@@ -23,7 +24,7 @@ import (
 type SyncStrategy string
 
 const (
-	SyncAlways   SyncStrategy = "always"   // Sync after every write
+	SyncAlways   SyncStrategy = "always"   // Acknowledge every write after it is synced, batching concurrent writes into one sync
 	SyncEverySec SyncStrategy = "everysec" // Sync every second
 	SyncNo       SyncStrategy = "no"       // Smart sync: sync when buffer ≥4KB or every 1 minute
 )
@@ -33,22 +34,57 @@ type AOFStats struct {
 	CommandCount int64     `json:"command_count"`
 	FileSize     int64     `json:"file_size"`
 	LastSync     time.Time `json:"last_sync"`
+	SyncCount    int64     `json:"sync_count"`
 	SyncStrategy string    `json:"sync_strategy"`
+	IncrFiles    int       `json:"incr_files,omitempty"` // Increments of a multi-part AOF
+	BaseFile     string    `json:"base_file,omitempty"`  // Base of a multi-part AOF, if any
 }
 
-// AOFLogger handles append-only file logging using FlatBuffers with Length-Prefix format
+// ReplayOptions controls how Replay handles damaged records
+type ReplayOptions struct {
+	// Repair skips corrupt records instead of failing. After a damaged length prefix,
+	// replay resumes at the next record with a valid checksum, if there is one.
+	Repair bool
+	// Keys decrypt encrypted files. Loggers default to the keys they were created with.
+	Keys encryption.KeyProvider
+}
+
+// ReplayReport describes what Replay found in the AOF file
+type ReplayReport struct {
+	Version        uint32 // Format version of the file
+	KeyID          string // Master key an encrypted file is encrypted with
+	Commands       int64  // Commands replayed
+	SkippedRecords int64  // Corrupt records skipped in repair mode
+	SkippedBytes   int64  // Bytes skipped to find the record after a damaged length prefix
+	TruncatedBytes int64  // Bytes cut off the end of the file
+}
+
+// AOFLogger handles append-only file logging using FlatBuffers with checksummed Length-Prefix records
 type AOFLogger struct {
 	mu           sync.Mutex
 	file         *os.File
 	writer       *bufio.Writer
 	filePath     string
 	syncStrategy SyncStrategy
+	version      uint32                 // Format version records are appended in
+	needsHeader  bool                   // The file is empty and gets a header before the first record
+	keys         encryption.KeyProvider // New and rewritten files are encrypted with these if set
+	key          *encryption.FileKey    // Data key records are sealed with, nil for unencrypted files
+	closed       bool
 
 	// Background sync for everysec strategy
 	syncTicker *time.Ticker
 	stopSync   chan struct{}
+	stopOnce   sync.Once
 	syncWG     sync.WaitGroup
 
+	// Group commit for always strategy. Writers append to the buffer and wait for the
+	// batch they joined, which the committer goroutine flushes and syncs as a whole.
+	commitMu      sync.Mutex    // Held while syncing a batch outside mu so the file isn't swapped; acquired before mu
+	pendingCommit *commitBatch  // Batch collecting writes until the next sync
+	commitReady   chan struct{} // Wakes the committer when a batch is pending
+	commitWG      sync.WaitGroup
+
 	// Smart sync for SyncNo strategy (buffer size or timeout based)
 	smartSyncTicker *time.Ticker
 	smartSyncWG     sync.WaitGroup
@@ -58,10 +94,25 @@ type AOFLogger struct {
 	// Statistics
 	commandCount int64
 	lastSync     time.Time
+	syncCount    int64
+}
+
+// commitBatch is a group of writes made durable by a single sync
+type commitBatch struct {
+	done chan struct{} // Closed once the batch is synced or failed
+	err  error
 }
 
-// NewAOFLogger creates a new FlatBuffers AOF logger with Length-Prefix format
+// NewAOFLogger creates a new FlatBuffers AOF logger. New files are written in FormatVersion.
 func NewAOFLogger(filePath string, syncStrategy SyncStrategy) (*AOFLogger, error) {
+	return NewAOFLoggerWithKeys(filePath, syncStrategy, nil)
+}
+
+// NewAOFLoggerWithKeys creates an AOF logger that encrypts new files with keys. Each new,
+// truncated or rewritten file gets its own data key, wrapped with the current master key
+// of keys, so a new master key is used from the next rewrite on. An existing file keeps
+// the format and data key it was created with.
+func NewAOFLoggerWithKeys(filePath string, syncStrategy SyncStrategy, keys encryption.KeyProvider) (*AOFLogger, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -69,17 +120,52 @@ func NewAOFLogger(filePath string, syncStrategy SyncStrategy) (*AOFLogger, error
 	}
 
 	// Open file for append
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, utils.ErrPersistenceFailedWithCause("failed to open AOF file", err)
 	}
 
+	// Keep appending in the format of an existing file
+	header, torn, err := readHeader(file)
+	if err != nil {
+		file.Close()
+		return nil, utils.ErrPersistenceFailedWithCause("failed to read AOF header", err)
+	}
+	if torn {
+		if err := file.Truncate(0); err != nil {
+			file.Close()
+			return nil, utils.ErrPersistenceFailedWithCause("failed to remove torn AOF header", err)
+		}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, utils.ErrPersistenceFailedWithCause("failed to stat AOF file", err)
+	}
+
+	version := header.version
+	var key *encryption.FileKey
+	if info.Size() == 0 {
+		version, key, err = newFormat(keys)
+	} else if header.envelope != nil {
+		key, err = encryption.OpenFileKey(context.Background(), keys, *header.envelope)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
 	logger := &AOFLogger{
 		file:            file,
 		writer:          bufio.NewWriter(file),
 		filePath:        filePath,
 		syncStrategy:    syncStrategy,
+		version:         version,
+		needsHeader:     info.Size() == 0,
+		keys:            keys,
+		key:             key,
 		stopSync:        make(chan struct{}),
+		commitReady:     make(chan struct{}, 1),
 		lastSync:        time.Now(),
 		bufferThreshold: 6 * 1024,        // 6KB buffer threshold
 		timeThreshold:   5 * time.Minute, // 5 minute time threshold
@@ -87,6 +173,8 @@ func NewAOFLogger(filePath string, syncStrategy SyncStrategy) (*AOFLogger, error
 
 	// Start background sync if needed
 	switch syncStrategy {
+	case SyncAlways:
+		logger.startGroupCommit()
 	case SyncEverySec:
 		logger.startBackgroundSync()
 	case SyncNo:
@@ -96,31 +184,57 @@ func NewAOFLogger(filePath string, syncStrategy SyncStrategy) (*AOFLogger, error
 	return logger, nil
 }
 
-// WriteCommand writes a command to the AOF log using FlatBuffers + Length-Prefix format
+// WriteCommand writes a command to the AOF log as a checksummed FlatBuffers record.
+// With SyncAlways it returns once the record is synced to disk.
 func (a *AOFLogger) WriteCommand(ctx context.Context, command types.AOFCommand) error {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
 	// Set timestamp if not provided
 	if command.Timestamp.IsZero() {
 		command.Timestamp = time.Now()
 	}
 
-	// Convert command to FlatBuffers
+	// Convert command to FlatBuffers before taking the lock
 	data, err := a.commandToFlatBuffers(command)
 	if err != nil {
 		return utils.ErrPersistenceFailedWithCause("failed to serialize AOF command", err)
 	}
 
-	// Write length prefix (4 bytes, little-endian)
-	length := uint32(len(data))
-	if err := binary.Write(a.writer, binary.LittleEndian, length); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to write AOF length prefix", err)
+	batch, err := a.appendCommand(data)
+	if err != nil || batch == nil {
+		return err
+	}
+
+	// Wait for the committer to sync the batch this record joined
+	select {
+	case a.commitReady <- struct{}{}:
+	default: // A wake-up is already pending
 	}
+	<-batch.done
+	return batch.err
+}
 
-	// Write FlatBuffers data
-	if _, err := a.writer.Write(data); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to write AOF command data", err)
+// appendCommand appends a record to the buffer. With SyncAlways it returns the batch
+// the record has to wait for instead of syncing.
+func (a *AOFLogger) appendCommand(data []byte) (*commitBatch, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, utils.ErrPersistenceFailed("AOF logger is closed")
+	}
+
+	if a.needsHeader {
+		if err := writeHeader(a.writer, a.version, a.key); err != nil {
+			return nil, utils.ErrPersistenceFailedWithCause("failed to write AOF header", err)
+		}
+		a.needsHeader = false
+	}
+	if a.key != nil {
+		data = a.key.Seal(data)
+	}
+
+	// Write length prefix, checksum and FlatBuffers data
+	if err := appendRecord(a.writer, a.version, data); err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to write AOF command", err)
 	}
 
 	a.commandCount++
@@ -128,77 +242,256 @@ func (a *AOFLogger) WriteCommand(ctx context.Context, command types.AOFCommand)
 	// Sync based on strategy
 	switch a.syncStrategy {
 	case SyncAlways:
-		if err := a.syncToFile(); err != nil {
-			return err
+		// Group commit handles this
+		if a.pendingCommit == nil {
+			a.pendingCommit = &commitBatch{done: make(chan struct{})}
 		}
+		return a.pendingCommit, nil
 	case SyncEverySec:
 		// Background sync handles this
 	case SyncNo:
 		// Smart sync handles this - check if we need immediate sync due to buffer size
 		if a.writer.Buffered() >= a.bufferThreshold {
 			if err := a.syncToFile(); err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
-	return nil
+	return nil, nil
 }
 
-// Replay reads and replays all commands from the AOF file using Length-Prefix format
+// Replay reads and replays all commands from the AOF file.
+// A record cut off at the end of the file by a crash is truncated away; any other
+// corruption fails the replay.
 func (a *AOFLogger) Replay(ctx context.Context, handler func(types.AOFCommand) error) error {
+	_, err := a.ReplayWithOptions(ctx, ReplayOptions{}, handler)
+	return err
+}
+
+// ReplayWithOptions replays all commands from the AOF file and reports what it found.
+//
+// A torn tail is cut off the file: a record incomplete at the end of the file, a final
+// record failing its checksum, or a zero-filled remainder. Other corrupt records fail
+// with ErrCorruptedData, or are skipped when opts.Repair is set.
+func (a *AOFLogger) ReplayWithOptions(ctx context.Context, opts ReplayOptions, handler func(types.AOFCommand) error) (ReplayReport, error) {
 	// Close current file handle for reading
 	a.mu.Lock()
 	if a.file != nil {
 		a.syncToFile() // Ensure any buffered data is written
 	}
+	if opts.Keys == nil {
+		opts.Keys = a.keys
+	}
 	a.mu.Unlock()
 
 	// Open file for reading
 	file, err := os.Open(a.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil // No AOF file exists yet, that's OK
+			return ReplayReport{}, nil // No AOF file exists yet, that's OK
 		}
-		return utils.ErrRecoveryFailed("failed to open AOF file for replay: " + err.Error())
+		return ReplayReport{}, utils.ErrRecoveryFailed("failed to open AOF file for replay: " + err.Error())
 	}
 	defer file.Close()
 
-	commandNum := 0
+	report, tail, err := replayFile(ctx, file, opts, func(command types.AOFCommand, _ []byte) error {
+		return handler(command)
+	})
+	if err != nil || tail < 0 {
+		return report, err
+	}
 
-	for {
-		commandNum++
+	return report, a.truncateTail(tail)
+}
 
-		// Read length prefix (4 bytes, little-endian) directly from file
-		var length uint32
-		if err := binary.Read(file, binary.LittleEndian, &length); err != nil {
-			if errors.Is(err, io.EOF) {
-				break // End of file
-			}
-			return utils.ErrCorruptedData(fmt.Sprintf("invalid length prefix at command %d: %v", commandNum, err))
+// ReplayFile replays an AOF file without modifying it, for offline inspection.
+// TruncatedBytes reports the tail a logger would cut off when replaying the file.
+func ReplayFile(ctx context.Context, filePath string, opts ReplayOptions, handler func(types.AOFCommand) error) (ReplayReport, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ReplayReport{}, utils.ErrRecoveryFailed("failed to open AOF file for replay: " + err.Error())
+	}
+	defer file.Close()
+
+	report, _, err := replayFile(ctx, file, opts, func(command types.AOFCommand, _ []byte) error {
+		return handler(command)
+	})
+	return report, err
+}
+
+// RepairFile copies the intact records of an AOF file to a new file in the current
+// format, skipping corrupt records like Replay in repair mode. The target must not exist.
+// keys decrypt an encrypted source; if set, the repaired file is encrypted with a new
+// data key wrapped with their current master key.
+func RepairFile(ctx context.Context, srcPath, dstPath string, keys encryption.KeyProvider) (report ReplayReport, err error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return report, utils.ErrRecoveryFailed("failed to open AOF file for repair: " + err.Error())
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return report, utils.ErrPersistenceFailedWithCause("failed to create repaired AOF file", err)
+	}
+	defer func() {
+		dst.Close()
+		if err != nil {
+			os.Remove(dstPath) // Clean up on error
 		}
+	}()
 
-		// Validate length
-		if length == 0 || length > 100*1024*1024 { // Max 100MB per command
-			return utils.ErrCorruptedData(fmt.Sprintf("invalid command length %d at command %d", length, commandNum))
+	version, key, err := newFormat(keys)
+	if err != nil {
+		return report, err
+	}
+	writer := bufio.NewWriter(dst)
+	if err = writeHeader(writer, version, key); err != nil {
+		return report, utils.ErrPersistenceFailedWithCause("failed to write repaired AOF header", err)
+	}
+
+	// Copy payloads as they are so nothing is lost to a decode and encode round trip
+	report, _, err = replayFile(ctx, src, ReplayOptions{Repair: true, Keys: keys}, func(_ types.AOFCommand, data []byte) error {
+		if key != nil {
+			data = key.Seal(data)
 		}
+		return appendRecord(writer, version, data)
+	})
+	if err != nil {
+		return report, err
+	}
 
-		// Read FlatBuffers data directly from file
-		data := make([]byte, length)
-		if _, err := io.ReadFull(file, data); err != nil {
-			return utils.ErrCorruptedData(fmt.Sprintf("failed to read command data at command %d: %v", commandNum, err))
+	if err = writer.Flush(); err != nil {
+		return report, utils.ErrPersistenceFailedWithCause("failed to flush repaired AOF file", err)
+	}
+	if err = dst.Sync(); err != nil {
+		return report, utils.ErrPersistenceFailedWithCause("failed to sync repaired AOF file", err)
+	}
+	return report, nil
+}
+
+// replayFile replays the records of an open AOF file, passing each command with its raw
+// payload to handler. It returns the offset the file has to be truncated at, or -1 if
+// the file ends cleanly.
+func replayFile(ctx context.Context, file *os.File, opts ReplayOptions, handler func(types.AOFCommand, []byte) error) (ReplayReport, int64, error) {
+	var report ReplayReport
+
+	info, err := file.Stat()
+	if err != nil {
+		return report, -1, utils.ErrRecoveryFailed("failed to stat AOF file for replay: " + err.Error())
+	}
+	size := info.Size()
+
+	header, torn, err := readHeader(file)
+	if err != nil {
+		return report, -1, utils.ErrCorruptedData("invalid AOF header: " + err.Error())
+	}
+	report.Version = header.version
+	if torn {
+		report.TruncatedBytes = size
+		return report, 0, nil
+	}
+
+	var key *encryption.FileKey
+	if header.envelope != nil {
+		report.KeyID = header.envelope.KeyID
+		if key, err = encryption.OpenFileKey(ctx, opts.Keys, *header.envelope); err != nil {
+			return report, -1, err
+		}
+	}
+
+	reader, err := newRecordReader(file, header, key)
+	if err != nil {
+		return report, -1, utils.ErrRecoveryFailed("failed to read AOF file for replay: " + err.Error())
+	}
+
+	// The tail from start on is cut off
+	truncateAt := func(start int64) (ReplayReport, int64, error) {
+		report.TruncatedBytes = size - start
+		return report, start, nil
+	}
+
+	decoder := &AOFLogger{}
+	commandNum := 0
+
+	for {
+		commandNum++
+		start := reader.offset
+
+		data, err := reader.next()
+		switch {
+		case err == nil:
+		case errors.Is(err, io.EOF):
+			return report, -1, nil
+		case errors.Is(err, errChecksumMismatch):
+			if reader.offset == size {
+				// The last record was only partially persisted
+				return truncateAt(start)
+			}
+			if !opts.Repair {
+				return report, -1, utils.ErrCorruptedData(fmt.Sprintf("checksum mismatch at command %d (offset %d)", commandNum, start))
+			}
+			report.SkippedRecords++
+			continue
+		case errors.Is(err, errDecryptFailed):
+			if !opts.Repair {
+				return report, -1, utils.ErrCorruptedData(fmt.Sprintf("command %d (offset %d) failed to decrypt", commandNum, start))
+			}
+			report.SkippedRecords++
+			continue
+		case errors.Is(err, errTornRecord), errors.Is(err, errInvalidLength):
+			// A damaged length prefix can look like a torn tail, so the tail is only cut
+			// off if no intact record follows
+			next, findErr := findRecord(file, start+1, size, header.version)
+			if findErr != nil {
+				return report, -1, utils.ErrRecoveryFailed("failed to read AOF file for replay: " + findErr.Error())
+			}
+			if next < 0 && errors.Is(err, errTornRecord) {
+				return truncateAt(start)
+			}
+			if next < 0 {
+				zeroed, zeroErr := isZeroFilled(file, start)
+				if zeroErr != nil {
+					return report, -1, utils.ErrRecoveryFailed("failed to read AOF file for replay: " + zeroErr.Error())
+				}
+				if zeroed || opts.Repair {
+					return truncateAt(start)
+				}
+			}
+			if !opts.Repair {
+				if errors.Is(err, errTornRecord) {
+					err = errInvalidLength // Intact records follow, so the file isn't torn
+				}
+				return report, -1, utils.ErrCorruptedData(fmt.Sprintf("%v at command %d (offset %d)", err, commandNum, start))
+			}
+
+			// Resume at the next record
+			if err := reader.seek(file, next); err != nil {
+				return report, -1, utils.ErrRecoveryFailed("failed to read AOF file for replay: " + err.Error())
+			}
+			report.SkippedRecords++
+			report.SkippedBytes += next - start
+			continue
+		default:
+			return report, -1, utils.ErrRecoveryFailed(fmt.Sprintf("failed to read AOF command %d: %v", commandNum, err))
 		}
 
 		// Parse FlatBuffers command
-		command, err := a.flatBuffersToCommand(data)
+		command, err := decoder.decodeCommand(data)
 		if err != nil {
-			return utils.ErrCorruptedData(fmt.Sprintf("invalid FlatBuffers command at command %d: %v", commandNum, err))
+			if !opts.Repair {
+				return report, -1, utils.ErrCorruptedData(fmt.Sprintf("invalid FlatBuffers command at command %d (offset %d): %v", commandNum, start, err))
+			}
+			report.SkippedRecords++
+			continue
 		}
 
 		// Execute command
-		if err := handler(*command); err != nil {
-			return utils.ErrRecoveryFailed(fmt.Sprintf("failed to replay AOF command at command %d: %v", commandNum, err))
+		if err := handler(*command, data); err != nil {
+			return report, -1, utils.ErrRecoveryFailed(fmt.Sprintf("failed to replay AOF command at command %d: %v", commandNum, err))
 		}
+		report.Commands++
 
 		// 立即释放命令数据，避免内存累积
 		data = nil
@@ -207,92 +500,196 @@ func (a *AOFLogger) Replay(ctx context.Context, handler func(types.AOFCommand) e
 		// Check for context cancellation
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return report, -1, ctx.Err()
 		default:
 		}
 	}
+}
+
+// truncateTail cuts the AOF file at offset, dropping a torn or unreadable tail
+func (a *AOFLogger) truncateTail(offset int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.file.Truncate(offset); err != nil {
+		return utils.ErrRecoveryFailed("failed to truncate torn AOF tail: " + err.Error())
+	}
+	if offset == 0 {
+		// Nothing valid is left, start over in the current format
+		return a.resetFormat()
+	}
 
 	return nil
 }
 
-// Rewrite creates a new AOF file with optimized commands using FlatBuffers + Length-Prefix
+// resetFormat switches an emptied file to the current format, with a new data key if
+// the logger has keys. The header is written with the next record.
+func (a *AOFLogger) resetFormat() error {
+	version, key, err := newFormat(a.keys)
+	if err != nil {
+		return err
+	}
+	a.version = version
+	a.key = key
+	a.needsHeader = true
+	return nil
+}
+
+// newFormat returns the format and data key of a new file: EncryptedFormatVersion with a
+// new data key if keys are set, FormatVersion otherwise
+func newFormat(keys encryption.KeyProvider) (uint32, *encryption.FileKey, error) {
+	if keys == nil {
+		return FormatVersion, nil, nil
+	}
+	key, err := encryption.NewFileKey(context.Background(), keys)
+	if err != nil {
+		return 0, nil, err
+	}
+	return EncryptedFormatVersion, key, nil
+}
+
+// EncodeCommand serializes a command as the FlatBuffers payload of an AOF record, the
+// form commands are also sent to replicas in
+func EncodeCommand(command types.AOFCommand) ([]byte, error) {
+	if command.Timestamp.IsZero() {
+		command.Timestamp = time.Now()
+	}
+	return (&AOFLogger{}).commandToFlatBuffers(command)
+}
+
+// DecodeCommand parses a payload written by EncodeCommand
+func DecodeCommand(data []byte) (types.AOFCommand, error) {
+	command, err := (&AOFLogger{}).decodeCommand(data)
+	if err != nil {
+		return types.AOFCommand{}, err
+	}
+	return *command, nil
+}
+
+// decodeCommand parses a record payload. Malformed FlatBuffers can make the generated
+// accessors panic, which is reported as an error instead.
+func (a *AOFLogger) decodeCommand(data []byte) (command *types.AOFCommand, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			command, err = nil, fmt.Errorf("malformed command: %v", r)
+		}
+	}()
+
+	return a.flatBuffersToCommand(data)
+}
+
+// Rewrite creates a new AOF file in the current format with optimized commands
 func (a *AOFLogger) Rewrite(ctx context.Context, snapshotCommands []types.AOFCommand) error {
+	a.commitMu.Lock()
+	defer a.commitMu.Unlock()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	// Create temporary file
 	tempPath := a.filePath + ".tmp"
-	tempFile, err := os.Create(tempPath)
+	version, key, err := a.createFile(ctx, tempPath, snapshotCommands)
 	if err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to create temporary AOF file", err)
+		return err
+	}
+	defer os.Remove(tempPath) // Clean up on error
+
+	// Close current file
+	if err := a.syncToFile(); err != nil {
+		a.finishPendingCommit(err)
+		return err
+	}
+	a.finishPendingCommit(nil)
+	if err := a.file.Close(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to close current AOF file", err)
+	}
+
+	// Replace old file with new one
+	if err := os.Rename(tempPath, a.filePath); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to replace AOF file", err)
+	}
+
+	// Reopen file for writing
+	file, err := os.OpenFile(a.filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to reopen AOF file after rewrite", err)
+	}
+	a.file = file
+	a.writer = bufio.NewWriter(a.file)
+	a.version = version
+	a.key = key
+	a.needsHeader = false
+	a.commandCount = int64(len(snapshotCommands))
+
+	return nil
+}
+
+// writeFile writes commands to a new synced AOF file in the current format
+func (a *AOFLogger) writeFile(ctx context.Context, path string, commands []types.AOFCommand) error {
+	_, _, err := a.createFile(ctx, path, commands)
+	return err
+}
+
+// createFile is writeFile returning the format and data key of the file, which is
+// encrypted with a new data key if the logger has keys
+func (a *AOFLogger) createFile(ctx context.Context, path string, commands []types.AOFCommand) (version uint32, key *encryption.FileKey, err error) {
+	// Rewritten files always use the current format
+	version, key, err = newFormat(a.keys)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, nil, utils.ErrPersistenceFailedWithCause("failed to create temporary AOF file", err)
 	}
 	defer func() {
-		tempFile.Close()
-		os.Remove(tempPath) // Clean up on error
+		file.Close()
+		if err != nil {
+			os.Remove(path) // Clean up on error
+		}
 	}()
 
-	writer := bufio.NewWriter(tempFile)
+	writer := bufio.NewWriter(file)
+	if err := writeHeader(writer, version, key); err != nil {
+		return 0, nil, utils.ErrPersistenceFailedWithCause("failed to write rewrite header", err)
+	}
 
 	// Write optimized commands
-	for _, command := range snapshotCommands {
+	for _, command := range commands {
 		// Convert command to FlatBuffers
 		data, err := a.commandToFlatBuffers(command)
 		if err != nil {
-			return utils.ErrPersistenceFailedWithCause("failed to serialize rewrite command", err)
+			return 0, nil, utils.ErrPersistenceFailedWithCause("failed to serialize rewrite command", err)
 		}
 
-		// Write length prefix
-		length := uint32(len(data))
-		if err := binary.Write(writer, binary.LittleEndian, length); err != nil {
-			return utils.ErrPersistenceFailedWithCause("failed to write rewrite length prefix", err)
+		// Write length prefix, checksum and FlatBuffers data
+		if key != nil {
+			data = key.Seal(data)
 		}
-
-		// Write FlatBuffers data
-		if _, err := writer.Write(data); err != nil {
-			return utils.ErrPersistenceFailedWithCause("failed to write rewrite command data", err)
+		if err := appendRecord(writer, version, data); err != nil {
+			return 0, nil, utils.ErrPersistenceFailedWithCause("failed to write rewrite command", err)
 		}
 
 		// Check for context cancellation
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return 0, nil, ctx.Err()
 		default:
 		}
 	}
 
 	// Flush and sync
 	if err := writer.Flush(); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to flush rewrite buffer", err)
-	}
-	if err := tempFile.Sync(); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to sync rewrite file", err)
-	}
-	if err := tempFile.Close(); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to close rewrite file", err)
-	}
-
-	// Close current file
-	if err := a.syncToFile(); err != nil {
-		return err
+		return 0, nil, utils.ErrPersistenceFailedWithCause("failed to flush rewrite buffer", err)
 	}
-	if err := a.file.Close(); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to close current AOF file", err)
+	if err := file.Sync(); err != nil {
+		return 0, nil, utils.ErrPersistenceFailedWithCause("failed to sync rewrite file", err)
 	}
-
-	// Replace old file with new one
-	if err := os.Rename(tempPath, a.filePath); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to replace AOF file", err)
+	if err := file.Close(); err != nil {
+		return 0, nil, utils.ErrPersistenceFailedWithCause("failed to close rewrite file", err)
 	}
 
-	// Reopen file for writing
-	a.file, err = os.OpenFile(a.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to reopen AOF file after rewrite", err)
-	}
-	a.writer = bufio.NewWriter(a.file)
-	a.commandCount = int64(len(snapshotCommands))
-
-	return nil
+	return version, key, nil
 }
 
 // commandToFlatBuffers converts a types.AOFCommand to FlatBuffers data
@@ -323,6 +720,21 @@ func (a *AOFLogger) commandToFlatBuffers(command types.AOFCommand) ([]byte, erro
 	case "DELETE_VECTORS":
 		commandType = fbaof.CommandTypeDELETE_VECTORS
 		argsOffset, err = a.deleteVectorsArgs(builder, command.Args)
+	case "RENAME_COLLECTION":
+		commandType = fbaof.CommandTypeRENAME_COLLECTION
+		argsOffset, err = a.renameCollectionArgs(builder, command.Args)
+	case "CLONE_COLLECTION":
+		commandType = fbaof.CommandTypeCLONE_COLLECTION
+		argsOffset, err = a.cloneCollectionArgs(builder, command.Args)
+	case "COPY_COLLECTION":
+		commandType = fbaof.CommandTypeCOPY_COLLECTION
+		argsOffset, err = a.copyCollectionArgs(builder, command.Args)
+	case "CREATE_PARTITION":
+		commandType = fbaof.CommandTypeCREATE_PARTITION
+		argsOffset, err = a.createPartitionArgs(builder, command.Args)
+	case "DROP_PARTITION":
+		commandType = fbaof.CommandTypeDROP_PARTITION
+		argsOffset, err = a.dropPartitionArgs(builder, command.Args)
 	default:
 		return nil, fmt.Errorf("unsupported command type: %s", command.Command)
 	}
@@ -376,6 +788,16 @@ func (a *AOFLogger) flatBuffersToCommand(data []byte) (*types.AOFCommand, error)
 		command.Command = "INSERT_VECTORS"
 	case fbaof.CommandTypeDELETE_VECTORS:
 		command.Command = "DELETE_VECTORS"
+	case fbaof.CommandTypeRENAME_COLLECTION:
+		command.Command = "RENAME_COLLECTION"
+	case fbaof.CommandTypeCLONE_COLLECTION:
+		command.Command = "CLONE_COLLECTION"
+	case fbaof.CommandTypeCOPY_COLLECTION:
+		command.Command = "COPY_COLLECTION"
+	case fbaof.CommandTypeCREATE_PARTITION:
+		command.Command = "CREATE_PARTITION"
+	case fbaof.CommandTypeDROP_PARTITION:
+		command.Command = "DROP_PARTITION"
 	default:
 		return nil, fmt.Errorf("unknown command type: %d", fbCommand.CommandType())
 	}
@@ -513,12 +935,127 @@ func (a *AOFLogger) deleteVectorsArgs(builder *flatbuffers.Builder, args map[str
 	}
 	idsVector := builder.EndVector(len(idOffsets))
 
+	// Optional partition filter
+	partitions, _ := args["partition_names"].([]string)
+	var partitionsVector flatbuffers.UOffsetT
+	if len(partitions) > 0 {
+		partitionOffsets := make([]flatbuffers.UOffsetT, len(partitions))
+		for i, partition := range partitions {
+			partitionOffsets[i] = builder.CreateString(partition)
+		}
+
+		fbaof.DeleteVectorsArgsStartPartitionNamesVector(builder, len(partitionOffsets))
+		for i := len(partitionOffsets) - 1; i >= 0; i-- {
+			builder.PrependUOffsetT(partitionOffsets[i])
+		}
+		partitionsVector = builder.EndVector(len(partitionOffsets))
+	}
+
 	fbaof.DeleteVectorsArgsStart(builder)
 	fbaof.DeleteVectorsArgsAddIds(builder, idsVector)
+	if len(partitions) > 0 {
+		fbaof.DeleteVectorsArgsAddPartitionNames(builder, partitionsVector)
+	}
 	return fbaof.DeleteVectorsArgsEnd(builder), nil
 }
 
 // Helper methods for creating complex types
+func (a *AOFLogger) renameCollectionArgs(builder *flatbuffers.Builder, args map[string]interface{}) (flatbuffers.UOffsetT, error) {
+	name, ok := args["name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid collection name")
+	}
+	newName, ok := args["new_name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid new collection name")
+	}
+
+	nameStr := builder.CreateString(name)
+	newNameStr := builder.CreateString(newName)
+	fbaof.RenameCollectionArgsStart(builder)
+	fbaof.RenameCollectionArgsAddName(builder, nameStr)
+	fbaof.RenameCollectionArgsAddNewName(builder, newNameStr)
+	return fbaof.RenameCollectionArgsEnd(builder), nil
+}
+
+func (a *AOFLogger) cloneCollectionArgs(builder *flatbuffers.Builder, args map[string]interface{}) (flatbuffers.UOffsetT, error) {
+	name, ok := args["name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid collection name")
+	}
+	targetName, ok := args["target_name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid target collection name")
+	}
+
+	nameStr := builder.CreateString(name)
+	targetNameStr := builder.CreateString(targetName)
+	fbaof.CloneCollectionArgsStart(builder)
+	fbaof.CloneCollectionArgsAddName(builder, nameStr)
+	fbaof.CloneCollectionArgsAddTargetName(builder, targetNameStr)
+	return fbaof.CloneCollectionArgsEnd(builder), nil
+}
+
+func (a *AOFLogger) copyCollectionArgs(builder *flatbuffers.Builder, args map[string]interface{}) (flatbuffers.UOffsetT, error) {
+	name, ok := args["name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid collection name")
+	}
+	targetDB, ok := args["target_database"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid target database")
+	}
+	targetName, ok := args["target_name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid target collection name")
+	}
+
+	nameStr := builder.CreateString(name)
+	targetDBStr := builder.CreateString(targetDB)
+	targetNameStr := builder.CreateString(targetName)
+	fbaof.CopyCollectionArgsStart(builder)
+	fbaof.CopyCollectionArgsAddName(builder, nameStr)
+	fbaof.CopyCollectionArgsAddTargetDatabase(builder, targetDBStr)
+	fbaof.CopyCollectionArgsAddTargetName(builder, targetNameStr)
+	return fbaof.CopyCollectionArgsEnd(builder), nil
+}
+
+func (a *AOFLogger) createPartitionArgs(builder *flatbuffers.Builder, args map[string]interface{}) (flatbuffers.UOffsetT, error) {
+	name, ok := args["name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid collection name")
+	}
+	partition, ok := args["partition"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid partition name")
+	}
+
+	nameStr := builder.CreateString(name)
+	partitionStr := builder.CreateString(partition)
+	fbaof.CreatePartitionArgsStart(builder)
+	fbaof.CreatePartitionArgsAddName(builder, nameStr)
+	fbaof.CreatePartitionArgsAddPartition(builder, partitionStr)
+	return fbaof.CreatePartitionArgsEnd(builder), nil
+}
+
+func (a *AOFLogger) dropPartitionArgs(builder *flatbuffers.Builder, args map[string]interface{}) (flatbuffers.UOffsetT, error) {
+	name, ok := args["name"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid collection name")
+	}
+	partition, ok := args["partition"].(string)
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid partition name")
+	}
+
+	nameStr := builder.CreateString(name)
+	partitionStr := builder.CreateString(partition)
+	fbaof.DropPartitionArgsStart(builder)
+	fbaof.DropPartitionArgsAddName(builder, nameStr)
+	fbaof.DropPartitionArgsAddPartition(builder, partitionStr)
+	return fbaof.DropPartitionArgsEnd(builder), nil
+}
+
 func (a *AOFLogger) createVector(builder *flatbuffers.Builder, vector types.Vector) (flatbuffers.UOffsetT, error) {
 	// Create elements vector
 	fbaof.VectorStartElementsVector(builder, len(vector.Elements))
@@ -527,19 +1064,28 @@ func (a *AOFLogger) createVector(builder *flatbuffers.Builder, vector types.Vect
 	}
 	elementsVector := builder.EndVector(len(vector.Elements))
 
-	// Convert metadata to JSON (simplified for now)
-	metadataStr := builder.CreateString("{}")
-	if vector.Metadata != nil {
-		// In a real implementation, you might want to serialize metadata properly
-		metadataStr = builder.CreateString("{}")
+	// Convert metadata to JSON string
+	metadataBytes, err := json.Marshal(vector.Metadata)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal vector metadata: %w", err)
 	}
+	metadataStr := builder.CreateString(string(metadataBytes))
 
 	idStr := builder.CreateString(fmt.Sprintf("%d", vector.ID))
 
+	var partitionStr flatbuffers.UOffsetT
+	if vector.Partition != "" {
+		partitionStr = builder.CreateString(vector.Partition)
+	}
+
 	fbaof.VectorStart(builder)
 	fbaof.VectorAddId(builder, idStr)
 	fbaof.VectorAddElements(builder, elementsVector)
 	fbaof.VectorAddMetadata(builder, metadataStr)
+	fbaof.VectorAddExpireAt(builder, vector.ExpireAt)
+	if vector.Partition != "" {
+		fbaof.VectorAddPartition(builder, partitionStr)
+	}
 	return fbaof.VectorEnd(builder), nil
 }
 
@@ -556,6 +1102,7 @@ func (a *AOFLogger) createCollectionConfig(builder *flatbuffers.Builder, config
 	fbaof.CollectionConfigAddName(builder, nameStr)
 	fbaof.CollectionConfigAddMetric(builder, fbaof.DistanceMetric(config.Metric))
 	fbaof.CollectionConfigAddHnswParams(builder, hnswOffset)
+	fbaof.CollectionConfigAddDefaultTtlSeconds(builder, config.DefaultTTLSeconds)
 	return fbaof.CollectionConfigEnd(builder), nil
 }
 
@@ -584,6 +1131,16 @@ func (a *AOFLogger) getArgsType(command string) fbaof.CommandArgs {
 		return fbaof.CommandArgsInsertVectorsArgs
 	case "DELETE_VECTORS":
 		return fbaof.CommandArgsDeleteVectorsArgs
+	case "RENAME_COLLECTION":
+		return fbaof.CommandArgsRenameCollectionArgs
+	case "CLONE_COLLECTION":
+		return fbaof.CommandArgsCloneCollectionArgs
+	case "COPY_COLLECTION":
+		return fbaof.CommandArgsCopyCollectionArgs
+	case "CREATE_PARTITION":
+		return fbaof.CommandArgsCreatePartitionArgs
+	case "DROP_PARTITION":
+		return fbaof.CommandArgsDropPartitionArgs
 	default:
 		return fbaof.CommandArgsNONE
 	}
@@ -625,6 +1182,7 @@ func (a *AOFLogger) parseCommandArgs(command *types.AOFCommand, argsTable *flatb
 						MaxLayers:      int(hnswParams.MaxLayers()),
 						Seed:           hnswParams.Seed(),
 					},
+					DefaultTTLSeconds: config.DefaultTtlSeconds(),
 				}
 				command.Args["config"] = collectionConfig
 			}
@@ -655,11 +1213,23 @@ func (a *AOFLogger) parseCommandArgs(command *types.AOFCommand, argsTable *flatb
 					continue
 				}
 
+				// Parse metadata; older logs carry "{}" for every vector
+				var metadata map[string]interface{}
+				if metadataBytes := vector.Metadata(); len(metadataBytes) > 0 {
+					if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+						return fmt.Errorf("failed to parse vector metadata: %w", err)
+					}
+				}
+				if len(metadata) == 0 {
+					metadata = nil
+				}
+
 				vectors[i] = types.Vector{
-					ID:       vectorID,
-					Elements: elements,
-					// Note: Metadata parsing could be enhanced for JSON
-					Metadata: nil,
+					ID:        vectorID,
+					Elements:  elements,
+					Metadata:  metadata,
+					ExpireAt:  vector.ExpireAt(),
+					Partition: string(vector.Partition()),
 				}
 			}
 		}
@@ -675,6 +1245,45 @@ func (a *AOFLogger) parseCommandArgs(command *types.AOFCommand, argsTable *flatb
 		}
 		command.Args["ids"] = ids
 
+		if args.PartitionNamesLength() > 0 {
+			partitions := make([]string, args.PartitionNamesLength())
+			for i := 0; i < args.PartitionNamesLength(); i++ {
+				partitions[i] = string(args.PartitionNames(i))
+			}
+			command.Args["partition_names"] = partitions
+		}
+
+	case "RENAME_COLLECTION":
+		args := &fbaof.RenameCollectionArgs{}
+		args.Init(argsTable.Bytes, argsTable.Pos)
+		command.Args["name"] = string(args.Name())
+		command.Args["new_name"] = string(args.NewName())
+
+	case "CLONE_COLLECTION":
+		args := &fbaof.CloneCollectionArgs{}
+		args.Init(argsTable.Bytes, argsTable.Pos)
+		command.Args["name"] = string(args.Name())
+		command.Args["target_name"] = string(args.TargetName())
+
+	case "COPY_COLLECTION":
+		args := &fbaof.CopyCollectionArgs{}
+		args.Init(argsTable.Bytes, argsTable.Pos)
+		command.Args["name"] = string(args.Name())
+		command.Args["target_database"] = string(args.TargetDatabase())
+		command.Args["target_name"] = string(args.TargetName())
+
+	case "CREATE_PARTITION":
+		args := &fbaof.CreatePartitionArgs{}
+		args.Init(argsTable.Bytes, argsTable.Pos)
+		command.Args["name"] = string(args.Name())
+		command.Args["partition"] = string(args.Partition())
+
+	case "DROP_PARTITION":
+		args := &fbaof.DropPartitionArgs{}
+		args.Init(argsTable.Bytes, argsTable.Pos)
+		command.Args["name"] = string(args.Name())
+		command.Args["partition"] = string(args.Partition())
+
 	default:
 		return fmt.Errorf("unknown command type for argument parsing: %s", command.Command)
 	}
@@ -684,9 +1293,18 @@ func (a *AOFLogger) parseCommandArgs(command *types.AOFCommand, argsTable *flatb
 
 // Truncate removes all content from the AOF file
 func (a *AOFLogger) Truncate() error {
+	a.commitMu.Lock()
+	defer a.commitMu.Unlock()
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	// Writers waiting for a sync are only released once their records are on disk
+	if err := a.syncToFile(); err != nil {
+		a.finishPendingCommit(err)
+		return err
+	}
+	a.finishPendingCommit(nil)
+
 	// Close current file
 	if err := a.file.Close(); err != nil {
 		return utils.ErrPersistenceFailedWithCause("failed to close AOF file for truncation", err)
@@ -702,15 +1320,18 @@ func (a *AOFLogger) Truncate() error {
 	a.writer = bufio.NewWriter(file)
 	a.commandCount = 0
 
-	return nil
+	return a.resetFormat()
 }
 
 // Close closes the AOF logger and stops background sync
 func (a *AOFLogger) Close() error {
 	// Stop background sync goroutines - need to close stopSync once for all strategies
-	if a.syncTicker != nil || a.smartSyncTicker != nil {
+	a.stopOnce.Do(func() {
 		close(a.stopSync)
-	}
+	})
+
+	// Wait for the committer to sync the last batch
+	a.commitWG.Wait()
 
 	// Wait for background sync to stop
 	if a.syncTicker != nil {
@@ -724,11 +1345,14 @@ func (a *AOFLogger) Close() error {
 
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	a.closed = true
 
 	// Final sync and close
 	if err := a.syncToFile(); err != nil {
+		a.finishPendingCommit(err)
 		return err
 	}
+	a.finishPendingCommit(nil)
 
 	return a.file.Close()
 }
@@ -748,6 +1372,7 @@ func (a *AOFLogger) GetStats() AOFStats {
 		CommandCount: a.commandCount,
 		FileSize:     fileSize,
 		LastSync:     a.lastSync,
+		SyncCount:    a.syncCount,
 		SyncStrategy: string(a.syncStrategy),
 	}
 }
@@ -763,9 +1388,73 @@ func (a *AOFLogger) syncToFile() error {
 		return utils.ErrPersistenceFailedWithCause("failed to sync AOF file", err)
 	}
 	a.lastSync = time.Now()
+	a.syncCount++
 	return nil
 }
 
+// finishPendingCommit releases the writers of the pending batch. Must be called with mu held.
+func (a *AOFLogger) finishPendingCommit(err error) {
+	if a.pendingCommit == nil {
+		return
+	}
+	a.pendingCommit.err = err
+	close(a.pendingCommit.done)
+	a.pendingCommit = nil
+}
+
+// startGroupCommit starts the committer goroutine for always strategy
+func (a *AOFLogger) startGroupCommit() {
+	a.commitWG.Add(1)
+
+	go func() {
+		defer a.commitWG.Done()
+
+		for {
+			select {
+			case <-a.commitReady:
+				a.commitPending()
+			case <-a.stopSync:
+				a.commitPending()
+				return
+			}
+		}
+	}()
+}
+
+// commitPending syncs the pending batch. The sync runs without mu so writers can
+// fill the next batch in the meantime.
+func (a *AOFLogger) commitPending() {
+	a.commitMu.Lock()
+	defer a.commitMu.Unlock()
+
+	a.mu.Lock()
+	batch := a.pendingCommit
+	a.pendingCommit = nil
+	if batch == nil {
+		a.mu.Unlock()
+		return
+	}
+	err := a.writer.Flush()
+	file := a.file
+	a.mu.Unlock()
+
+	if err != nil {
+		err = utils.ErrPersistenceFailedWithCause("failed to flush AOF buffer", err)
+	} else if err = file.Sync(); err != nil {
+		err = utils.ErrPersistenceFailedWithCause("failed to sync AOF file", err)
+	}
+
+	if err == nil {
+		a.mu.Lock()
+		a.lastSync = time.Now()
+		a.syncCount++
+		a.mu.Unlock()
+	}
+
+	batch.err = err
+	close(batch.done)
+}
+
 // startBackgroundSync starts the background sync goroutine for everysec strategy
 func (a *AOFLogger) startBackgroundSync() {
 	a.syncTicker = time.NewTicker(time.Second)
@@ -906,3 +1595,83 @@ func (cb *CommandBuilder) DeleteVectors(dbName, collName string, ids []string) t
 		Collection: collName,
 	}
 }
+
+// RenameCollection builds a command for renaming a collection
+func (cb *CommandBuilder) RenameCollection(dbName, collName, newName string) types.AOFCommand {
+	return types.AOFCommand{
+		Timestamp: time.Now(),
+		Command:   "RENAME_COLLECTION",
+		Args: map[string]interface{}{
+			"name":     collName,
+			"new_name": newName,
+		},
+		Database:   dbName,
+		Collection: collName,
+	}
+}
+
+// CloneCollection builds a command for cloning a collection within a database
+func (cb *CommandBuilder) CloneCollection(dbName, collName, targetName string) types.AOFCommand {
+	return types.AOFCommand{
+		Timestamp: time.Now(),
+		Command:   "CLONE_COLLECTION",
+		Args: map[string]interface{}{
+			"name":        collName,
+			"target_name": targetName,
+		},
+		Database:   dbName,
+		Collection: collName,
+	}
+}
+
+// CopyCollection builds a command for copying a collection to another database
+func (cb *CommandBuilder) CopyCollection(dbName, collName, targetDB, targetName string) types.AOFCommand {
+	return types.AOFCommand{
+		Timestamp: time.Now(),
+		Command:   "COPY_COLLECTION",
+		Args: map[string]interface{}{
+			"name":            collName,
+			"target_database": targetDB,
+			"target_name":     targetName,
+		},
+		Database:   dbName,
+		Collection: collName,
+	}
+}
+
+// DeleteVectorsFromPartitions builds a command for vector deletion restricted to the given partitions
+func (cb *CommandBuilder) DeleteVectorsFromPartitions(dbName, collName string, ids []string, partitions []string) types.AOFCommand {
+	command := cb.DeleteVectors(dbName, collName, ids)
+	if len(partitions) > 0 {
+		command.Args["partition_names"] = partitions
+	}
+	return command
+}
+
+// CreatePartition builds a command for creating a partition in a collection
+func (cb *CommandBuilder) CreatePartition(dbName, collName, partition string) types.AOFCommand {
+	return types.AOFCommand{
+		Timestamp: time.Now(),
+		Command:   "CREATE_PARTITION",
+		Args: map[string]interface{}{
+			"name":      collName,
+			"partition": partition,
+		},
+		Database:   dbName,
+		Collection: collName,
+	}
+}
+
+// DropPartition builds a command for dropping a partition from a collection
+func (cb *CommandBuilder) DropPartition(dbName, collName, partition string) types.AOFCommand {
+	return types.AOFCommand{
+		Timestamp: time.Now(),
+		Command:   "DROP_PARTITION",
+		Args: map[string]interface{}{
+			"name":      collName,
+			"partition": partition,
+		},
+		Database:   dbName,
+		Collection: collName,
+	}
+}
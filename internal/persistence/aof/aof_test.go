@@ -1,11 +1,16 @@
 package aof
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/scintirete/scintirete/internal/utils"
 	"github.com/scintirete/scintirete/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -330,3 +335,466 @@ func TestAOFLogger_SyncStrategies(t *testing.T) {
 		})
 	}
 }
+
+func TestAOFLogger_CollectionCopyCommands(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test.aof")
+
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+
+	builder := NewCommandBuilder()
+	commands := []types.AOFCommand{
+		builder.RenameCollection("db1", "coll", "renamed"),
+		builder.CloneCollection("db1", "renamed", "clone"),
+		builder.CopyCollection("db1", "clone", "db2", "copy"),
+	}
+
+	ctx := context.Background()
+	for _, command := range commands {
+		require.NoError(t, logger.WriteCommand(ctx, command))
+	}
+	require.NoError(t, logger.Close())
+
+	replayLogger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+	defer replayLogger.Close()
+
+	var replayed []types.AOFCommand
+	err = replayLogger.Replay(ctx, func(command types.AOFCommand) error {
+		replayed = append(replayed, command)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, replayed, len(commands))
+
+	for i, original := range commands {
+		assert.Equal(t, original.Command, replayed[i].Command)
+		assert.Equal(t, original.Database, replayed[i].Database)
+		assert.Equal(t, original.Args, replayed[i].Args)
+	}
+}
+
+func TestAOFLogger_VectorTTL(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test.aof")
+
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+
+	builder := NewCommandBuilder()
+	config := types.CollectionConfig{
+		Name:              "coll",
+		Metric:            types.DistanceMetricL2,
+		HNSWParams:        types.DefaultHNSWParams(),
+		DefaultTTLSeconds: 3600,
+	}
+	vectors := []types.Vector{
+		{ID: 1, Elements: []float32{1, 2, 3}, ExpireAt: 1700000000},
+		{ID: 2, Elements: []float32{4, 5, 6}},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateCollection("db1", "coll", config)))
+	require.NoError(t, logger.WriteCommand(ctx, builder.InsertVectors("db1", "coll", vectors)))
+	require.NoError(t, logger.Close())
+
+	replayLogger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+	defer replayLogger.Close()
+
+	var replayed []types.AOFCommand
+	err = replayLogger.Replay(ctx, func(command types.AOFCommand) error {
+		replayed = append(replayed, command)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, replayed, 2)
+
+	replayedConfig, ok := replayed[0].Args["config"].(types.CollectionConfig)
+	require.True(t, ok)
+	assert.Equal(t, int64(3600), replayedConfig.DefaultTTLSeconds)
+
+	replayedVectors, ok := replayed[1].Args["vectors"].([]types.Vector)
+	require.True(t, ok)
+	require.Len(t, replayedVectors, 2)
+	assert.Equal(t, int64(1700000000), replayedVectors[0].ExpireAt)
+	assert.Equal(t, int64(0), replayedVectors[1].ExpireAt)
+}
+
+func TestAOFLogger_VectorMetadata(t *testing.T) {
+	vectors := []types.Vector{
+		{ID: 1, Elements: []float32{1, 2}, Metadata: map[string]interface{}{"label": "a", "tags": []interface{}{"x", "y"}}},
+		{ID: 2, Elements: []float32{3, 4}},
+	}
+	data, err := EncodeCommand(NewCommandBuilder().InsertVectors("db1", "coll", vectors))
+	require.NoError(t, err)
+
+	command, err := DecodeCommand(data)
+	require.NoError(t, err)
+	assert.Equal(t, vectors, command.Args["vectors"])
+}
+
+func TestAOFLogger_PartitionCommands(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "test.aof")
+
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+
+	builder := NewCommandBuilder()
+	vectors := []types.Vector{
+		{ID: 1, Elements: []float32{1, 2, 3}, Partition: "hot"},
+		{ID: 2, Elements: []float32{4, 5, 6}},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreatePartition("db1", "coll", "hot")))
+	require.NoError(t, logger.WriteCommand(ctx, builder.InsertVectors("db1", "coll", vectors)))
+	require.NoError(t, logger.WriteCommand(ctx, builder.DeleteVectorsFromPartitions("db1", "coll", []string{"1"}, []string{"hot"})))
+	require.NoError(t, logger.WriteCommand(ctx, builder.DeleteVectors("db1", "coll", []string{"2"})))
+	require.NoError(t, logger.WriteCommand(ctx, builder.DropPartition("db1", "coll", "hot")))
+	require.NoError(t, logger.Close())
+
+	replayLogger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+	defer replayLogger.Close()
+
+	var replayed []types.AOFCommand
+	err = replayLogger.Replay(ctx, func(command types.AOFCommand) error {
+		replayed = append(replayed, command)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, replayed, 5)
+
+	assert.Equal(t, "CREATE_PARTITION", replayed[0].Command)
+	assert.Equal(t, "coll", replayed[0].Args["name"])
+	assert.Equal(t, "hot", replayed[0].Args["partition"])
+
+	replayedVectors, ok := replayed[1].Args["vectors"].([]types.Vector)
+	require.True(t, ok)
+	require.Len(t, replayedVectors, 2)
+	assert.Equal(t, "hot", replayedVectors[0].Partition)
+	assert.Equal(t, "", replayedVectors[1].Partition)
+
+	assert.Equal(t, []string{"hot"}, replayed[2].Args["partition_names"])
+	_, hasPartitions := replayed[3].Args["partition_names"]
+	assert.False(t, hasPartitions)
+
+	assert.Equal(t, "DROP_PARTITION", replayed[4].Command)
+	assert.Equal(t, "hot", replayed[4].Args["partition"])
+}
+
+// writeDatabaseCommands writes one CREATE_DATABASE command per name and returns the
+// file size after each record
+func writeDatabaseCommands(t *testing.T, filePath string, names ...string) []int64 {
+	t.Helper()
+
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	builder := NewCommandBuilder()
+	ends := make([]int64, 0, len(names))
+	for _, name := range names {
+		require.NoError(t, logger.WriteCommand(context.Background(), builder.CreateDatabase(name)))
+		ends = append(ends, logger.GetStats().FileSize)
+	}
+	return ends
+}
+
+// replayDatabaseNames replays the file with a new logger and returns the replayed database names
+func replayDatabaseNames(t *testing.T, filePath string, opts ReplayOptions) ([]string, ReplayReport, error) {
+	t.Helper()
+
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	var names []string
+	report, err := logger.ReplayWithOptions(context.Background(), opts, func(command types.AOFCommand) error {
+		names = append(names, command.Database)
+		return nil
+	})
+	return names, report, err
+}
+
+func TestAOFLogger_FormatHeader(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	writeDatabaseCommands(t, filePath, "db1")
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, encodeHeader(FormatVersion), data[:headerSize])
+
+	names, report, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1"}, names)
+	assert.Equal(t, FormatVersion, report.Version)
+
+	// Files from newer versions are refused
+	require.NoError(t, os.WriteFile(filePath, encodeHeader(EncryptedFormatVersion+1), 0644))
+	_, err = NewAOFLogger(filePath, SyncAlways)
+	assert.Error(t, err)
+}
+
+func TestAOFLogger_TornTail(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	ends := writeDatabaseCommands(t, filePath, "db1", "db2", "db3")
+
+	// A crash in the middle of the last record
+	require.NoError(t, os.Truncate(filePath, ends[2]-3))
+
+	names, report, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2"}, names)
+	assert.Equal(t, ends[2]-3-ends[1], report.TruncatedBytes)
+
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, ends[1], info.Size())
+
+	// Appending continues after the last intact record
+	writeDatabaseCommands(t, filePath, "db4")
+	names, report, err = replayDatabaseNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2", "db4"}, names)
+	assert.Zero(t, report.TruncatedBytes)
+}
+
+func TestAOFLogger_TornTailVariants(t *testing.T) {
+	t.Run("damaged last record", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "test.aof")
+		ends := writeDatabaseCommands(t, filePath, "db1", "db2")
+		corruptByte(t, filePath, ends[1]-1)
+
+		names, report, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db1"}, names)
+		assert.Equal(t, ends[1]-ends[0], report.TruncatedBytes)
+	})
+
+	t.Run("zero filled tail", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "test.aof")
+		ends := writeDatabaseCommands(t, filePath, "db1")
+		require.NoError(t, os.Truncate(filePath, ends[0]+4096))
+
+		names, report, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db1"}, names)
+		assert.Equal(t, int64(4096), report.TruncatedBytes)
+	})
+
+	t.Run("torn header", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "test.aof")
+		require.NoError(t, os.WriteFile(filePath, headerMagic[:3], 0644))
+
+		writeDatabaseCommands(t, filePath, "db1")
+		names, _, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db1"}, names)
+	})
+}
+
+func TestAOFLogger_CorruptRecord(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	ends := writeDatabaseCommands(t, filePath, "db1", "db2", "db3")
+
+	// Damage the payload of the middle record
+	corruptByte(t, filePath, ends[1]-1)
+
+	_, _, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+	assert.Equal(t, utils.ErrorCodeCorruptedData, utils.GetErrorCode(err))
+
+	names, report, err := replayDatabaseNames(t, filePath, ReplayOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db3"}, names)
+	assert.Equal(t, int64(1), report.SkippedRecords)
+	assert.Equal(t, int64(2), report.Commands)
+
+	// After a damaged length, repair resumes at the next intact record
+	corruptByte(t, filePath, ends[0]+3)
+
+	_, _, err = replayDatabaseNames(t, filePath, ReplayOptions{})
+	assert.Equal(t, utils.ErrorCodeCorruptedData, utils.GetErrorCode(err))
+
+	names, report, err = replayDatabaseNames(t, filePath, ReplayOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db3"}, names)
+	assert.Equal(t, int64(1), report.SkippedRecords)
+	assert.Equal(t, ends[1]-ends[0], report.SkippedBytes)
+	assert.Zero(t, report.TruncatedBytes)
+}
+
+func TestAOFLogger_DamagedLengthBeforeIntactRecords(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	ends := writeDatabaseCommands(t, filePath, "db1", "db2", "db3")
+
+	// A length running past the end of the file isn't a torn tail if intact records follow
+	corruptByte(t, filePath, ends[0]+2)
+
+	_, _, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+	assert.Equal(t, utils.ErrorCodeCorruptedData, utils.GetErrorCode(err))
+
+	names, report, err := replayDatabaseNames(t, filePath, ReplayOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db3"}, names)
+	assert.Equal(t, ends[1]-ends[0], report.SkippedBytes)
+
+	// The tail is still cut off when nothing intact follows
+	corruptByte(t, filePath, ends[1]+2)
+
+	names, report, err = replayDatabaseNames(t, filePath, ReplayOptions{Repair: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1"}, names)
+	assert.Equal(t, ends[2]-ends[0], report.TruncatedBytes)
+}
+
+func TestAOFLogger_RecordCandidates(t *testing.T) {
+	// Every command the writer produces passes the checks findRecord makes before the checksum
+	builder := NewCommandBuilder()
+	config := types.CollectionConfig{Name: "coll", Metric: types.DistanceMetricL2, HNSWParams: types.DefaultHNSWParams()}
+	for _, command := range []types.AOFCommand{
+		builder.CreateDatabase("db1"),
+		builder.DropDatabase("db1"),
+		builder.CreateCollection("db1", "coll", config),
+		builder.InsertVectors("db1", "coll", []types.Vector{{ID: 1, Elements: []float32{1, 2, 3}}}),
+		builder.DeleteVectors("db1", "coll", []string{"1"}),
+		builder.RenameCollection("db1", "coll", "other"),
+		builder.CreatePartition("db1", "coll", "p1"),
+	} {
+		data, err := EncodeCommand(command)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, len(data), minRecordSize(FormatVersion), command.Command)
+		assert.True(t, plausibleFlatBuffer(data, len(data)), command.Command)
+	}
+}
+
+func TestAOFLogger_LegacyFormat(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	builder := NewCommandBuilder()
+	ctx := context.Background()
+
+	// Version 0 files have no header and no checksums
+	var legacy bytes.Buffer
+	for _, name := range []string{"db1", "db2"} {
+		data, err := (&AOFLogger{}).commandToFlatBuffers(builder.CreateDatabase(name))
+		require.NoError(t, err)
+		require.NoError(t, appendRecord(&legacy, 0, data))
+	}
+	require.NoError(t, os.WriteFile(filePath, legacy.Bytes(), 0644))
+
+	// Appends stay in the legacy format
+	writeDatabaseCommands(t, filePath, "db3")
+	names, report, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2", "db3"}, names)
+	assert.Equal(t, uint32(0), report.Version)
+
+	// A rewrite upgrades the file
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+	require.NoError(t, logger.Rewrite(ctx, []types.AOFCommand{builder.CreateDatabase("db1")}))
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("db2")))
+	require.NoError(t, logger.Close())
+
+	names, report, err = replayDatabaseNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2"}, names)
+	assert.Equal(t, FormatVersion, report.Version)
+}
+
+// corruptByte flips the bits of the byte at offset
+func corruptByte(t *testing.T, filePath string, offset int64) {
+	t.Helper()
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	data[offset] ^= 0xff
+	require.NoError(t, os.WriteFile(filePath, data, 0644))
+}
+
+func TestAOFLogger_GroupCommit(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	builder := NewCommandBuilder()
+	const writers, writes = 32, 20
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				name := fmt.Sprintf("db_%d_%d", w, i)
+				if !assert.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase(name))) {
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(writers*writes), logger.GetStats().CommandCount)
+
+	// Writers appending while a sync is in progress share the next one
+	syncs := logger.GetStats().SyncCount
+	logger.commitMu.Lock()
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			assert.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase(fmt.Sprintf("db_%d", w))))
+		}(w)
+	}
+	require.Eventually(t, func() bool {
+		return logger.GetStats().CommandCount == writers*(writes+1)
+	}, 5*time.Second, time.Millisecond)
+	logger.commitMu.Unlock()
+	wg.Wait()
+	assert.Equal(t, syncs+1, logger.GetStats().SyncCount)
+	require.NoError(t, logger.Close())
+
+	names, _, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Len(t, names, writers*(writes+1))
+
+	// Writing after Close fails instead of waiting for a sync that never comes
+	assert.Error(t, logger.WriteCommand(ctx, builder.CreateDatabase("late")))
+}
+
+func TestAOFLogger_GroupCommitDurability(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	logger, err := NewAOFLogger(filePath, SyncAlways)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	ctx := context.Background()
+	builder := NewCommandBuilder()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase(fmt.Sprintf("db%d", i))))
+
+		// Every acknowledged record is readable from the file without closing the logger
+		var replayed int64
+		_, err := ReplayFile(ctx, filePath, ReplayOptions{}, func(types.AOFCommand) error {
+			replayed++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), replayed)
+	}
+
+	// Rewrite and Truncate swap the file under the committer
+	require.NoError(t, logger.Rewrite(ctx, []types.AOFCommand{builder.CreateDatabase("rewritten")}))
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("after_rewrite")))
+	require.NoError(t, logger.Truncate())
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("after_truncate")))
+
+	names, _, err := replayDatabaseNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"after_truncate"}, names)
+}
@@ -0,0 +1,163 @@
+package aof
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys returns encryption keys with the given IDs, the first one current
+func testKeys(t *testing.T, ids ...string) encryption.KeyProvider {
+	t.Helper()
+
+	var text string
+	for _, id := range ids {
+		key := sha256.Sum256([]byte(id))
+		text += id + ":" + hex.EncodeToString(key[:]) + "\n"
+	}
+	keys, err := encryption.ParseKeys(text)
+	require.NoError(t, err)
+	return keys
+}
+
+// replayNames replays a file without a logger and returns the replayed database names
+func replayNames(t *testing.T, filePath string, opts ReplayOptions) ([]string, ReplayReport, error) {
+	t.Helper()
+
+	var names []string
+	report, err := ReplayFile(context.Background(), filePath, opts, func(command types.AOFCommand) error {
+		names = append(names, command.Database)
+		return nil
+	})
+	return names, report, err
+}
+
+func TestAOFLogger_Encryption(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	keys := testKeys(t, "k1")
+	builder := NewCommandBuilder()
+
+	logger, err := NewAOFLoggerWithKeys(filePath, SyncAlways, keys)
+	require.NoError(t, err)
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("secret_db1")))
+	require.NoError(t, logger.Close())
+
+	// Appending to the file keeps its data key
+	logger, err = NewAOFLoggerWithKeys(filePath, SyncAlways, keys)
+	require.NoError(t, err)
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("secret_db2")))
+	require.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, encodeHeader(EncryptedFormatVersion), data[:headerSize])
+	assert.False(t, bytes.Contains(data, []byte("secret_db")), "database names are written in the clear")
+
+	names, report, err := replayNames(t, filePath, ReplayOptions{Keys: keys})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"secret_db1", "secret_db2"}, names)
+	assert.Equal(t, EncryptedFormatVersion, report.Version)
+	assert.Equal(t, "k1", report.KeyID)
+
+	// Without the key the file can't be read or appended to
+	_, _, err = replayNames(t, filePath, ReplayOptions{})
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	_, err = NewAOFLogger(filePath, SyncAlways)
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	_, _, err = replayNames(t, filePath, ReplayOptions{Keys: testKeys(t, "k2")})
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+}
+
+func TestAOFLogger_EncryptionRotation(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "test.aof")
+	builder := NewCommandBuilder()
+
+	// An unencrypted file stays unencrypted until it is rewritten
+	writeDatabaseCommands(t, filePath, "db1")
+	logger, err := NewAOFLoggerWithKeys(filePath, SyncAlways, testKeys(t, "k1"))
+	require.NoError(t, err)
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("db2")))
+	names, report, err := replayNames(t, filePath, ReplayOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db2"}, names)
+	assert.Equal(t, FormatVersion, report.Version)
+
+	require.NoError(t, logger.Rewrite(ctx, []types.AOFCommand{builder.CreateDatabase("db1"), builder.CreateDatabase("db2")}))
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("db3")))
+	require.NoError(t, logger.Close())
+	_, report, err = replayNames(t, filePath, ReplayOptions{Keys: testKeys(t, "k1")})
+	require.NoError(t, err)
+	assert.Equal(t, EncryptedFormatVersion, report.Version)
+	assert.Equal(t, "k1", report.KeyID)
+
+	// A new master key takes over with the next rewrite, the old one still reads the file until then
+	rotated := testKeys(t, "k2", "k1")
+	logger, err = NewAOFLoggerWithKeys(filePath, SyncAlways, rotated)
+	require.NoError(t, err)
+	defer logger.Close()
+	report, err = logger.ReplayWithOptions(ctx, ReplayOptions{}, func(types.AOFCommand) error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, "k1", report.KeyID)
+
+	require.NoError(t, logger.Rewrite(ctx, []types.AOFCommand{builder.CreateDatabase("db1")}))
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("db4")))
+	names, report, err = replayNames(t, filePath, ReplayOptions{Keys: testKeys(t, "k2")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db4"}, names)
+	assert.Equal(t, "k2", report.KeyID)
+
+	// An emptied log starts over with a new data key
+	require.NoError(t, logger.Truncate())
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("db6")))
+	names, _, err = replayNames(t, filePath, ReplayOptions{Keys: testKeys(t, "k2")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db6"}, names)
+}
+
+func TestRepairFile_Encrypted(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "test.aof")
+	keys := testKeys(t, "k1")
+	builder := NewCommandBuilder()
+
+	logger, err := NewAOFLoggerWithKeys(filePath, SyncAlways, keys)
+	require.NoError(t, err)
+	var ends []int64
+	for _, name := range []string{"db1", "db2", "db3"} {
+		require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase(name)))
+		ends = append(ends, logger.GetStats().FileSize)
+	}
+	require.NoError(t, logger.Close())
+
+	// Damage the second record
+	corruptByte(t, filePath, ends[1]-1)
+	_, _, err = replayNames(t, filePath, ReplayOptions{Keys: keys})
+	assert.Equal(t, utils.ErrorCodeCorruptedData, utils.GetErrorCode(err))
+
+	// The repaired copy is encrypted with the current key and holds the intact records
+	repairedPath := filepath.Join(dir, "repaired.aof")
+	_, err = RepairFile(ctx, filePath, repairedPath, nil)
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	rotated := testKeys(t, "k2", "k1")
+	report, err := RepairFile(ctx, filePath, repairedPath, rotated)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, report.SkippedRecords)
+
+	names, report, err := replayNames(t, repairedPath, ReplayOptions{Keys: rotated})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db1", "db3"}, names)
+	assert.Equal(t, "k2", report.KeyID)
+}
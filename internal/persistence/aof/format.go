@@ -0,0 +1,318 @@
+// Package aof provides the on-disk record format of the AOF file.
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+)
+
+// FormatVersion is the AOF format written by this version of Scintirete.
+//
+// Version 1 files start with an 8 byte header: the magic "SAOF" followed by the
+// version as a little-endian uint32. Every record is a little-endian uint32 length,
+// a CRC32C (Castagnoli) checksum of the payload and the FlatBuffers payload.
+//
+// Version 0 files have no header and records carry only the length prefix. They are
+// still read, and appended to in the same format until the next rewrite upgrades them.
+// A version 0 file can't be mistaken for a header: "SAOF" read as a length exceeds maxRecordSize.
+const FormatVersion uint32 = 1
+
+// EncryptedFormatVersion is the AOF format written with encryption keys.
+//
+// Version 2 is version 1 with the encryption envelope, which holds the wrapped data key
+// of the file, following the header. Every payload is sealed with AES-GCM under a random
+// nonce and stored as the nonce followed by the ciphertext; the checksum covers the
+// stored bytes. See the encryption package.
+const EncryptedFormatVersion uint32 = 2
+
+const (
+	headerSize    = 8
+	maxRecordSize = 100 * 1024 * 1024 // Max 100MB per command
+)
+
+var (
+	headerMagic = []byte("SAOF")
+	crcTable    = crc32.MakeTable(crc32.Castagnoli)
+)
+
+var (
+	// errTornRecord means a record was cut off by the end of the file
+	errTornRecord = errors.New("record is cut off by end of file")
+	// errChecksumMismatch means a record was read completely but its payload is damaged
+	errChecksumMismatch = errors.New("record checksum mismatch")
+	// errInvalidLength means the length prefix is damaged, so the next record has to be
+	// searched for with findRecord
+	errInvalidLength = errors.New("invalid record length")
+	// errDecryptFailed means a record passed its checksum but not the authentication of
+	// its encryption, so it was altered after it was written
+	errDecryptFailed = errors.New("record failed to decrypt")
+)
+
+// fileHeader is what readHeader found at the start of an AOF file
+type fileHeader struct {
+	version  uint32
+	offset   int64                // Offset of the first record
+	envelope *encryption.Envelope // Wrapped data key of encrypted files
+}
+
+// encodeHeader returns the file header of a format version
+func encodeHeader(version uint32) []byte {
+	header := make([]byte, headerSize)
+	copy(header, headerMagic)
+	binary.LittleEndian.PutUint32(header[len(headerMagic):], version)
+	return header
+}
+
+// writeHeader writes the file header of a format version, followed by the envelope of
+// key for encrypted files
+func writeHeader(w io.Writer, version uint32, key *encryption.FileKey) error {
+	if _, err := w.Write(encodeHeader(version)); err != nil {
+		return err
+	}
+	if key != nil {
+		_, err := w.Write(key.Envelope().Marshal())
+		return err
+	}
+	return nil
+}
+
+// readHeader detects the format version of an AOF file and finds its first record.
+// torn reports a file that ends inside the header, which only a crash while creating it leaves behind.
+func readHeader(file *os.File) (header fileHeader, torn bool, err error) {
+	buf := make([]byte, headerSize)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return header, false, err
+	}
+
+	switch {
+	case n == headerSize && bytes.Equal(buf[:len(headerMagic)], headerMagic):
+		header.version = binary.LittleEndian.Uint32(buf[len(headerMagic):])
+		header.offset = headerSize
+		if header.version > EncryptedFormatVersion {
+			return header, false, fmt.Errorf("unsupported AOF format version %d", header.version)
+		}
+		if header.version == EncryptedFormatVersion {
+			envelope, err := encryption.ReadEnvelope(io.NewSectionReader(file, headerSize, 1<<20))
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return fileHeader{version: header.version}, true, nil
+			}
+			if err != nil {
+				return header, false, err
+			}
+			header.envelope = &envelope
+			header.offset += int64(envelope.Size())
+		}
+		return header, false, nil
+	case n > 0 && n < headerSize && (bytes.HasPrefix(encodeHeader(FormatVersion), buf[:n]) ||
+		bytes.HasPrefix(encodeHeader(EncryptedFormatVersion), buf[:n])):
+		return fileHeader{version: FormatVersion}, true, nil
+	default:
+		return header, false, nil
+	}
+}
+
+// appendRecord writes a single record in the given format version
+func appendRecord(w io.Writer, version uint32, data []byte) error {
+	if len(data) > maxRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds the limit of %d bytes", len(data), maxRecordSize)
+	}
+
+	prefix := make([]byte, recordPrefixSize(version))
+	binary.LittleEndian.PutUint32(prefix, uint32(len(data)))
+	if version >= 1 {
+		binary.LittleEndian.PutUint32(prefix[4:], crc32.Checksum(data, crcTable))
+	}
+
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// recordPrefixSize returns the size of the length and checksum in front of each record
+func recordPrefixSize(version uint32) int {
+	if version == 0 {
+		return 4
+	}
+	return 8
+}
+
+// recordReader reads records sequentially from an AOF file
+type recordReader struct {
+	r       *bufio.Reader
+	version uint32
+	key     *encryption.FileKey // Decrypts the payloads of encrypted files
+	offset  int64               // Offset of the next record
+}
+
+// newRecordReader positions a reader at the first record of the file. key is the data
+// key of an encrypted file and nil otherwise.
+func newRecordReader(file *os.File, header fileHeader, key *encryption.FileKey) (*recordReader, error) {
+	if _, err := file.Seek(header.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &recordReader{
+		r:       bufio.NewReader(file),
+		version: header.version,
+		key:     key,
+		offset:  header.offset,
+	}, nil
+}
+
+// next returns the decrypted payload of the next record, or io.EOF at the end of the file.
+// The offset only moves past records whose boundaries are intact, which includes
+// records failing errChecksumMismatch or errDecryptFailed, so those can be skipped.
+func (r *recordReader) next() ([]byte, error) {
+	prefix := make([]byte, recordPrefixSize(r.version))
+	if _, err := io.ReadFull(r.r, prefix); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errTornRecord
+		}
+		return nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(prefix)
+	if length == 0 || length > maxRecordSize {
+		return nil, fmt.Errorf("%w %d", errInvalidLength, length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errTornRecord
+		}
+		return nil, err
+	}
+
+	r.offset += int64(len(prefix)) + int64(length)
+	if r.version >= 1 && crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(prefix[4:]) {
+		return nil, errChecksumMismatch
+	}
+
+	if r.key != nil {
+		plaintext, err := r.key.Open(data)
+		if err != nil {
+			return nil, errDecryptFailed
+		}
+		return plaintext, nil
+	}
+	return data, nil
+}
+
+// seek positions the reader at offset, the start of a record found by findRecord
+func (r *recordReader) seek(file *os.File, offset int64) error {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r.r.Reset(file)
+	r.offset = offset
+	return nil
+}
+
+// findRecord returns the offset of the first record at or after from with a valid length
+// and a matching checksum, or -1 if there is none before the end of the file. Version 0
+// records have no checksum to be recognized by, so none are found in those files.
+// Candidates are filtered by what the writer produces before their checksum is computed,
+// as most offsets of a damaged region have a length that fits the rest of the file.
+func findRecord(file *os.File, from, size int64, version uint32) (int64, error) {
+	if version == 0 {
+		return -1, nil
+	}
+
+	prefixSize := recordPrefixSize(version)
+	buf := make([]byte, 64*1024)
+	var payload []byte // Reused for candidates reaching past the window
+	head := make([]byte, 4)
+	// Windows overlap so every offset gets a complete prefix
+	for base := from; base+int64(prefixSize) <= size; base += int64(len(buf) - prefixSize + 1) {
+		n, err := file.ReadAt(buf, base)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return -1, err
+		}
+
+		for i := 0; i+prefixSize <= n; i++ {
+			offset := base + int64(i)
+			length := int(binary.LittleEndian.Uint32(buf[i:]))
+			if length < minRecordSize(version) || length > maxRecordSize || offset+int64(prefixSize)+int64(length) > size {
+				continue
+			}
+
+			// Check the root of a FlatBuffer before checksumming the whole payload
+			start := i + prefixSize
+			if version == FormatVersion {
+				if start+len(head) <= n {
+					copy(head, buf[start:])
+				} else if _, err := file.ReadAt(head, offset+int64(prefixSize)); err != nil {
+					return -1, err
+				}
+				if !plausibleFlatBuffer(head, length) {
+					continue
+				}
+			}
+
+			data := buf[start:min(start+length, n)]
+			if len(data) < length {
+				if cap(payload) < length {
+					payload = make([]byte, length)
+				}
+				data = payload[:length]
+				if _, err := file.ReadAt(data, offset+int64(prefixSize)); err != nil {
+					return -1, err
+				}
+			}
+			if crc32.Checksum(data, crcTable) == binary.LittleEndian.Uint32(buf[i+4:]) {
+				return offset, nil
+			}
+		}
+	}
+	return -1, nil
+}
+
+// minRecordSize returns the size of the smallest payload the writer produces: a FlatBuffer
+// holds at least its root offset and the table it points to, an encrypted payload at
+// least the nonce and the authentication tag
+func minRecordSize(version uint32) int {
+	if version == EncryptedFormatVersion {
+		return encryption.Overhead
+	}
+	return 8
+}
+
+// plausibleFlatBuffer reports whether a payload of length bytes starting with head can be
+// a FlatBuffer: its root offset points to an aligned table inside the payload
+func plausibleFlatBuffer(head []byte, length int) bool {
+	root := binary.LittleEndian.Uint32(head)
+	return root >= 4 && root%4 == 0 && int64(root)+4 <= int64(length)
+}
+
+// isZeroFilled reports whether the file contains only zero bytes from offset on.
+// File systems may leave a zero-filled tail when a crash interrupts an append.
+func isZeroFilled(file *os.File, offset int64) (bool, error) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.ReadAt(buf, offset)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false, nil
+			}
+		}
+		offset += int64(n)
+
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// FileType is the role of a file listed in a manifest
+type FileType string
+
+const (
+	FileTypeBase FileType = "b" // Snapshot the increments are applied to
+	FileTypeIncr FileType = "i" // Commands logged after the base, replayed in sequence order
+)
+
+// BaseFormat is the format of a base file
+type BaseFormat string
+
+const (
+	BaseRDB BaseFormat = "rdb" // RDB snapshot
+	BaseAOF BaseFormat = "aof" // Rewritten AOF commands
+)
+
+// ManifestEntry is a file of a multi-part AOF
+type ManifestEntry struct {
+	Name string    // File name within the AOF directory
+	Seq  int64     // Position in the log, a base precedes the increment with the same sequence
+	Type FileType  // Base or increment
+	Time time.Time // When an increment was started, or the point in time a base holds; zero if unknown
+}
+
+// Format returns the format of a base file from its extension
+func (e ManifestEntry) Format() BaseFormat {
+	if strings.HasSuffix(e.Name, "."+string(BaseRDB)) {
+		return BaseRDB
+	}
+	return BaseAOF
+}
+
+// Manifest lists the files making up a multi-part AOF. It is stored as text, one file
+// per line, with times in Unix nanoseconds:
+//
+//	file appendonly.aof.3.base.rdb seq 3 type b ts 1759320000000000000
+//	file appendonly.aof.3.incr.aof seq 3 type i ts 1759320000000000000
+//	file appendonly.aof.4.incr.aof seq 4 type i ts 1759323600000000000
+type Manifest struct {
+	Base  *ManifestEntry  // nil until the first snapshot or rewrite
+	Incrs []ManifestEntry // In sequence order, the last one receives new commands
+}
+
+// Files returns the base, if any, followed by the increments
+func (m *Manifest) Files() []ManifestEntry {
+	files := make([]ManifestEntry, 0, len(m.Incrs)+1)
+	if m.Base != nil {
+		files = append(files, *m.Base)
+	}
+	return append(files, m.Incrs...)
+}
+
+// clone returns a copy that can be changed without affecting m
+func (m *Manifest) clone() *Manifest {
+	clone := &Manifest{Incrs: append([]ManifestEntry(nil), m.Incrs...)}
+	if m.Base != nil {
+		base := *m.Base
+		clone.Base = &base
+	}
+	return clone
+}
+
+// last returns the increment receiving new commands
+func (m *Manifest) last() ManifestEntry {
+	return m.Incrs[len(m.Incrs)-1]
+}
+
+// encode renders the manifest in its text format
+func (m *Manifest) encode() []byte {
+	return encodeEntries(m.Files())
+}
+
+// encodeEntries renders manifest lines
+func encodeEntries(entries []ManifestEntry) []byte {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		fmt.Fprintf(&buf, "file %s seq %d type %s", entry.Name, entry.Seq, entry.Type)
+		if !entry.Time.IsZero() {
+			fmt.Fprintf(&buf, " ts %d", entry.Time.UnixNano())
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// ParseManifest reads a manifest. Keys it does not know are ignored so newer
+// manifests stay readable.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	entries, err := parseEntries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	for _, entry := range entries {
+		switch entry.Type {
+		case FileTypeBase:
+			if manifest.Base != nil {
+				return nil, utils.ErrCorruptedData("manifest lists more than one base file")
+			}
+			manifest.Base = &entry
+		case FileTypeIncr:
+			if n := len(manifest.Incrs); n > 0 && manifest.Incrs[n-1].Seq >= entry.Seq {
+				return nil, utils.ErrCorruptedData(fmt.Sprintf("manifest lists increment %d out of sequence", entry.Seq))
+			}
+			manifest.Incrs = append(manifest.Incrs, entry)
+		}
+	}
+
+	if len(manifest.Incrs) == 0 {
+		return nil, utils.ErrCorruptedData("manifest lists no increment file")
+	}
+	if manifest.Base != nil && manifest.Base.Seq > manifest.Incrs[0].Seq {
+		return nil, utils.ErrCorruptedData("manifest lists increments older than its base")
+	}
+	return manifest, nil
+}
+
+// parseEntries reads manifest lines
+func parseEntries(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields)%2 != 0 {
+			return nil, utils.ErrCorruptedData(fmt.Sprintf("manifest line %d has a key without value", line))
+		}
+
+		var entry ManifestEntry
+		hasSeq := false
+		for i := 0; i < len(fields); i += 2 {
+			switch value := fields[i+1]; fields[i] {
+			case "file":
+				entry.Name = value
+			case "seq":
+				seq, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || seq < 1 {
+					return nil, utils.ErrCorruptedData(fmt.Sprintf("manifest line %d has invalid sequence %q", line, value))
+				}
+				entry.Seq = seq
+				hasSeq = true
+			case "type":
+				entry.Type = FileType(value)
+			case "ts":
+				ts, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, utils.ErrCorruptedData(fmt.Sprintf("manifest line %d has invalid time %q", line, value))
+				}
+				entry.Time = time.Unix(0, ts)
+			}
+		}
+		if entry.Name == "" || !hasSeq || strings.ContainsAny(entry.Name, `/\`) {
+			return nil, utils.ErrCorruptedData(fmt.Sprintf("manifest line %d does not name a file with its sequence", line))
+		}
+
+		if entry.Type != FileTypeBase && entry.Type != FileTypeIncr {
+			return nil, utils.ErrCorruptedData(fmt.Sprintf("manifest line %d has unknown file type %q", line, entry.Type))
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.ErrRecoveryFailed("failed to read AOF manifest: " + err.Error())
+	}
+
+	return entries, nil
+}
+
+// ReadManifest reads the manifest at path. It returns nil if there is none.
+func ReadManifest(path string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, utils.ErrRecoveryFailed("failed to open AOF manifest: " + err.Error())
+	}
+	defer file.Close()
+
+	return ParseManifest(file)
+}
+
+// writeManifest replaces the manifest at path atomically, so a crash leaves either the
+// old or the new list of files
+func writeManifest(path string, manifest *Manifest) (err error) {
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to create AOF manifest", err)
+	}
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(tempPath) // Clean up on error
+		}
+	}()
+
+	if _, err = file.Write(manifest.encode()); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to write AOF manifest", err)
+	}
+	if err = file.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync AOF manifest", err)
+	}
+	if err = file.Close(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to close AOF manifest", err)
+	}
+	if err = os.Rename(tempPath, path); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to replace AOF manifest", err)
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes renames and new files in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to open AOF directory", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync AOF directory", err)
+	}
+	return nil
+}
@@ -0,0 +1,503 @@
+package aof
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// MultiPartOptions configures a multi-part AOF
+type MultiPartOptions struct {
+	Dir          string // Directory holding the manifest and its files
+	Name         string // Prefix of every file name, such as appendonly.aof
+	SyncStrategy SyncStrategy
+
+	// LegacyAOF and LegacyRDB are adopted when Dir has no manifest yet: a single-file AOF
+	// is moved in as the first increment and the RDB snapshot it applies to becomes the base
+	LegacyAOF string
+	LegacyRDB string
+
+	// ArchiveDir receives the files a rewrite replaces instead of deleting them, to
+	// restore earlier states from; see PlanRestore. Empty disables archiving.
+	ArchiveDir string
+
+	// Keys encrypt every increment and AOF base created from now on and decrypt existing
+	// ones; see NewAOFLoggerWithKeys. nil writes unencrypted files.
+	Keys encryption.KeyProvider
+}
+
+// MultiPartLogger is an AOF split into a base and numbered increments, listed by a
+// manifest. New commands go to the last increment. A rewrite rotates to a fresh
+// increment, writes the base for everything before it while commands keep coming in,
+// and then swaps the manifest, so writers are never held up by a rewrite.
+type MultiPartLogger struct {
+	mu           sync.RWMutex // Held for reading while appending to current, for writing while switching files
+	dir          string
+	archiveDir   string
+	name         string
+	syncStrategy SyncStrategy
+	keys         encryption.KeyProvider
+	manifest     *Manifest
+	current      *AOFLogger      // Logger of the last increment
+	counts       map[int64]int64 // Commands in the increments by sequence, as far as known
+	closed       bool
+}
+
+// OpenMultiPart opens the multi-part AOF in opts.Dir, creating it if needed. Files
+// in the directory that start with opts.Name but are not in the manifest, such as
+// those of an interrupted rewrite, are removed.
+func OpenMultiPart(opts MultiPartOptions) (*MultiPartLogger, error) {
+	if opts.Name == "" || strings.ContainsAny(opts.Name, " \t\n/\\") {
+		return nil, utils.ErrPersistenceFailed(fmt.Sprintf("invalid AOF file name %q", opts.Name))
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to create AOF directory", err)
+	}
+	if opts.ArchiveDir != "" {
+		if filepath.Clean(opts.ArchiveDir) == filepath.Clean(opts.Dir) {
+			return nil, utils.ErrPersistenceFailed("AOF archive directory must differ from the AOF directory")
+		}
+		if err := os.MkdirAll(opts.ArchiveDir, 0755); err != nil {
+			return nil, utils.ErrPersistenceFailedWithCause("failed to create AOF archive directory", err)
+		}
+	}
+
+	l := &MultiPartLogger{
+		dir:          opts.Dir,
+		archiveDir:   opts.ArchiveDir,
+		name:         opts.Name,
+		syncStrategy: opts.SyncStrategy,
+		keys:         opts.Keys,
+		counts:       make(map[int64]int64),
+	}
+
+	manifest, err := ReadManifest(l.manifestPath())
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		if manifest, err = l.adopt(opts.LegacyAOF, opts.LegacyRDB); err != nil {
+			return nil, err
+		}
+	}
+	l.manifest = manifest
+
+	if err := l.removeUnlisted(); err != nil {
+		return nil, err
+	}
+
+	l.current, err = NewAOFLoggerWithKeys(l.Path(manifest.last()), opts.SyncStrategy, opts.Keys)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// adopt creates the first manifest, taking over the files of a single-file AOF
+func (l *MultiPartLogger) adopt(legacyAOF, legacyRDB string) (*Manifest, error) {
+	manifest := &Manifest{Incrs: []ManifestEntry{l.entry(1, FileTypeIncr, BaseAOF)}}
+
+	if legacyRDB != "" {
+		if info, err := os.Stat(legacyRDB); err == nil {
+			base := l.entry(1, FileTypeBase, BaseRDB)
+			if err := LinkFile(legacyRDB, l.Path(base)); err != nil {
+				return nil, err
+			}
+			// The AOF was truncated when the snapshot was saved
+			base.Time = info.ModTime()
+			manifest.Incrs[0].Time = base.Time
+			manifest.Base = &base
+		}
+	}
+
+	// The file is moved before the manifest names it, so a crash in between adopts it on the next start
+	if legacyAOF != "" {
+		if _, err := os.Stat(legacyAOF); err == nil {
+			if err := os.Rename(legacyAOF, l.Path(manifest.Incrs[0])); err != nil {
+				return nil, utils.ErrPersistenceFailedWithCause("failed to move AOF file into the AOF directory", err)
+			}
+		}
+	}
+
+	if err := writeManifest(l.manifestPath(), manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// removeUnlisted deletes files left over by rewrites that did not finish
+func (l *MultiPartLogger) removeUnlisted() error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to list AOF directory", err)
+	}
+
+	listed := map[string]bool{filepath.Base(l.manifestPath()): true}
+	for _, entry := range l.manifest.Files() {
+		listed[entry.Name] = true
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || listed[entry.Name()] || !strings.HasPrefix(entry.Name(), l.name+".") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.dir, entry.Name())); err != nil {
+			return utils.ErrPersistenceFailedWithCause("failed to remove stale AOF file", err)
+		}
+	}
+	return nil
+}
+
+// WriteCommand appends a command to the last increment
+func (l *MultiPartLogger) WriteCommand(ctx context.Context, command types.AOFCommand) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.closed {
+		return utils.ErrPersistenceFailed("AOF logger is closed")
+	}
+	return l.current.WriteCommand(ctx, command)
+}
+
+// Manifest returns a copy of the current list of files
+func (l *MultiPartLogger) Manifest() *Manifest {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.manifest.clone()
+}
+
+// Base returns the base file, or nil if there is none yet
+func (l *MultiPartLogger) Base() *ManifestEntry {
+	return l.Manifest().Base
+}
+
+// Path returns the location of a file of the AOF
+func (l *MultiPartLogger) Path(entry ManifestEntry) string {
+	return filepath.Join(l.dir, entry.Name)
+}
+
+// Replay replays an AOF base and then every increment in sequence. An RDB base is left
+// to the caller, see Base. A torn tail is cut off the last increment like ReplayWithOptions
+// does; earlier increments were closed cleanly, so damage there is corruption.
+func (l *MultiPartLogger) Replay(ctx context.Context, opts ReplayOptions, handler func(types.AOFCommand) error) (ReplayReport, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if opts.Keys == nil {
+		opts.Keys = l.keys
+	}
+
+	var total ReplayReport
+	add := func(report ReplayReport) {
+		total.Version = report.Version
+		total.Commands += report.Commands
+		total.SkippedRecords += report.SkippedRecords
+		total.SkippedBytes += report.SkippedBytes
+		total.TruncatedBytes += report.TruncatedBytes
+	}
+
+	var closed []ManifestEntry
+	if base := l.manifest.Base; base != nil && base.Format() == BaseAOF {
+		closed = append(closed, *base)
+	}
+	closed = append(closed, l.manifest.Incrs[:len(l.manifest.Incrs)-1]...)
+
+	for _, entry := range closed {
+		report, err := ReplayFile(ctx, l.Path(entry), opts, handler)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		if report.TruncatedBytes > 0 && !opts.Repair {
+			return total, utils.ErrCorruptedData(fmt.Sprintf("%s ends in a damaged record", entry.Name))
+		}
+		add(report)
+		if entry.Type == FileTypeIncr {
+			l.counts[entry.Seq] = report.Commands
+		}
+	}
+
+	last := l.manifest.last()
+	report, err := l.current.ReplayWithOptions(ctx, opts, handler)
+	if err != nil {
+		return total, fmt.Errorf("%s: %w", last.Name, err)
+	}
+	add(report)
+	l.counts[last.Seq] = report.Commands
+
+	return total, nil
+}
+
+// Rotate closes the last increment and starts a new one, returning its sequence. The
+// commands before it can then be replaced with a base by CommitBase while new ones go
+// to the new increment.
+func (l *MultiPartLogger) Rotate() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, utils.ErrPersistenceFailed("AOF logger is closed")
+	}
+
+	last := l.manifest.last()
+	next := l.entry(last.Seq+1, FileTypeIncr, BaseAOF)
+	next.Time = time.Now()
+	logger, err := NewAOFLoggerWithKeys(l.Path(next), l.syncStrategy, l.keys)
+	if err != nil {
+		return 0, err
+	}
+
+	manifest := l.manifest.clone()
+	manifest.Incrs = append(manifest.Incrs, next)
+	if err := writeManifest(l.manifestPath(), manifest); err != nil {
+		logger.Close()
+		os.Remove(l.Path(next))
+		return 0, err
+	}
+
+	// The manifest already names the new increment, so the old one is closed even if that fails
+	written := l.current.GetStats().CommandCount
+	closeErr := l.current.Close()
+	l.counts[last.Seq] += written
+	l.current = logger
+	l.manifest = manifest
+	if closeErr != nil {
+		return 0, utils.ErrPersistenceFailedWithCause("failed to close AOF increment", closeErr)
+	}
+
+	return next.Seq, nil
+}
+
+// CommitBase replaces the base and the increments before seq with a new base, which
+// write creates at the given path. seq comes from Rotate, and the base must contain
+// exactly the commands logged before it. Replaced files are deleted.
+func (l *MultiPartLogger) CommitBase(seq int64, format BaseFormat, write func(path string) error) error {
+	base := l.entry(seq, FileTypeBase, format)
+	l.mu.RLock()
+	for _, incr := range l.manifest.Incrs {
+		if incr.Seq == seq {
+			base.Time = incr.Time // The base holds the state at the rotation
+		}
+	}
+	l.mu.RUnlock()
+
+	path := l.Path(base)
+	os.Remove(path) // Left over by a failed attempt
+	if err := write(path); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	if err := l.commit(seq, &base); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// RewriteBase rotates and writes commands as the new base. The commands must contain
+// every command logged so far, so writes have to be stopped until it returns.
+func (l *MultiPartLogger) RewriteBase(ctx context.Context, commands []types.AOFCommand) error {
+	seq, err := l.Rotate()
+	if err != nil {
+		return err
+	}
+
+	l.mu.RLock()
+	current := l.current
+	l.mu.RUnlock()
+	return l.CommitBase(seq, BaseAOF, func(path string) error {
+		return current.writeFile(ctx, path, commands)
+	})
+}
+
+// Truncate drops every increment logged so far and keeps the base. Archived increments
+// are still replayed by a restore.
+func (l *MultiPartLogger) Truncate() error {
+	seq, err := l.Rotate()
+	if err != nil {
+		return err
+	}
+
+	l.mu.RLock()
+	base := l.manifest.Base
+	l.mu.RUnlock()
+	return l.commit(seq, base)
+}
+
+// commit makes base the base of the AOF and drops the increments before seq. Dropped
+// files are archived if an archive directory is set.
+func (l *MultiPartLogger) commit(seq int64, base *ManifestEntry) error {
+	dropped, err := l.swapManifest(seq, base)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range dropped {
+		if l.archiveDir == "" {
+			os.Remove(l.Path(entry)) // Removed on the next start otherwise
+			continue
+		}
+		if err := l.archive(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// swapManifest replaces the manifest for commit and returns the files no longer listed
+func (l *MultiPartLogger) swapManifest(seq int64, base *ManifestEntry) ([]ManifestEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, utils.ErrPersistenceFailed("AOF logger is closed")
+	}
+	if last := l.manifest.last(); seq < l.manifest.Incrs[0].Seq || seq > last.Seq {
+		return nil, utils.ErrPersistenceFailed(fmt.Sprintf("AOF increment %d is no longer in the manifest", seq))
+	}
+
+	manifest := &Manifest{}
+	if base != nil {
+		baseCopy := *base
+		manifest.Base = &baseCopy
+	}
+	var dropped []ManifestEntry
+	if old := l.manifest.Base; old != nil && (base == nil || old.Name != base.Name) {
+		dropped = append(dropped, *old)
+	}
+	for _, incr := range l.manifest.Incrs {
+		if incr.Seq < seq {
+			dropped = append(dropped, incr)
+			continue
+		}
+		manifest.Incrs = append(manifest.Incrs, incr)
+	}
+
+	if err := writeManifest(l.manifestPath(), manifest); err != nil {
+		return nil, err
+	}
+	l.manifest = manifest
+	for _, entry := range dropped {
+		if entry.Type == FileTypeIncr {
+			delete(l.counts, entry.Seq)
+		}
+	}
+
+	return dropped, nil
+}
+
+// archive moves a file into the archive directory and adds it to the archive index
+func (l *MultiPartLogger) archive(entry ManifestEntry) error {
+	target := filepath.Join(l.archiveDir, entry.Name)
+	if err := os.Rename(l.Path(entry), target); err != nil {
+		// The archive may be on another file system
+		if err := LinkFile(l.Path(entry), target); err != nil {
+			return utils.ErrPersistenceFailedWithCause("failed to archive "+entry.Name, err)
+		}
+		os.Remove(l.Path(entry))
+	}
+	if err := syncDir(l.archiveDir); err != nil {
+		return err
+	}
+
+	index, err := os.OpenFile(l.archiveIndexPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to open AOF archive index", err)
+	}
+	defer index.Close()
+
+	if _, err := index.Write(encodeEntries([]ManifestEntry{entry})); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to write AOF archive index", err)
+	}
+	if err := index.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync AOF archive index", err)
+	}
+	return nil
+}
+
+// Close closes the last increment
+func (l *MultiPartLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return l.current.Close()
+}
+
+// GetStats returns statistics summed over the increments
+func (l *MultiPartLogger) GetStats() AOFStats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := l.current.GetStats()
+	last := l.manifest.last()
+	stats.CommandCount += l.counts[last.Seq]
+	for _, incr := range l.manifest.Incrs[:len(l.manifest.Incrs)-1] {
+		stats.CommandCount += l.counts[incr.Seq]
+		if info, err := os.Stat(l.Path(incr)); err == nil {
+			stats.FileSize += info.Size()
+		}
+	}
+	stats.IncrFiles = len(l.manifest.Incrs)
+	if l.manifest.Base != nil {
+		stats.BaseFile = l.manifest.Base.Name
+	}
+
+	return stats
+}
+
+// entry names the file of a base or an increment
+func (l *MultiPartLogger) entry(seq int64, fileType FileType, format BaseFormat) ManifestEntry {
+	name := fmt.Sprintf("%s.%d.incr.aof", l.name, seq)
+	if fileType == FileTypeBase {
+		name = fmt.Sprintf("%s.%d.base.%s", l.name, seq, format)
+	}
+	return ManifestEntry{Name: name, Seq: seq, Type: fileType}
+}
+
+func (l *MultiPartLogger) manifestPath() string {
+	return filepath.Join(l.dir, l.name+".manifest")
+}
+
+func (l *MultiPartLogger) archiveIndexPath() string {
+	return filepath.Join(l.archiveDir, l.name+".archive")
+}
+
+// LinkFile makes src available at dst, as a hard link if possible and as a copy otherwise
+func LinkFile(src, dst string) error {
+	os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return syncDir(filepath.Dir(dst))
+	}
+
+	data, err := os.Open(src)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to open file to copy", err)
+	}
+	defer data.Close()
+
+	file, err := os.Create(dst)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to create file copy", err)
+	}
+	defer file.Close()
+
+	if _, err := file.ReadFrom(data); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to copy file", err)
+	}
+	if err := file.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync file copy", err)
+	}
+	return syncDir(filepath.Dir(dst))
+}
@@ -0,0 +1,265 @@
+package aof
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scintirete/scintirete/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replayMultiPart returns the database names of the commands in a multi-part AOF
+func replayMultiPart(t *testing.T, logger *MultiPartLogger) []string {
+	var names []string
+	_, err := logger.Replay(context.Background(), ReplayOptions{}, func(command types.AOFCommand) error {
+		names = append(names, command.Database)
+		return nil
+	})
+	require.NoError(t, err)
+	return names
+}
+
+func TestMultiPartLogger_RotateAndCommitBase(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "appendonlydir")
+	opts := MultiPartOptions{Dir: dir, Name: "test.aof", SyncStrategy: SyncAlways}
+	ctx := context.Background()
+	builder := NewCommandBuilder()
+
+	logger, err := OpenMultiPart(opts)
+	require.NoError(t, err)
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("first")))
+
+	seq, err := logger.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), seq)
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("second")))
+
+	stats := logger.GetStats()
+	assert.Equal(t, int64(2), stats.CommandCount)
+	assert.Equal(t, 2, stats.IncrFiles)
+	assert.Equal(t, []string{"first", "second"}, replayMultiPart(t, logger))
+
+	// The base replaces the first increment, commands after the rotation stay
+	require.NoError(t, logger.CommitBase(seq, BaseAOF, func(path string) error {
+		return logger.current.writeFile(ctx, path, []types.AOFCommand{builder.CreateDatabase("base")})
+	}))
+	manifest := logger.Manifest()
+	require.NotNil(t, manifest.Base)
+	assert.Equal(t, "test.aof.2.base.aof", manifest.Base.Name)
+	require.Len(t, manifest.Incrs, 1)
+	assert.Equal(t, "test.aof.2.incr.aof", manifest.Incrs[0].Name)
+	assert.Equal(t, manifest.Incrs[0].Time, manifest.Base.Time)
+	assert.NoFileExists(t, filepath.Join(dir, "test.aof.1.incr.aof"))
+	assert.Equal(t, []string{"base", "second"}, replayMultiPart(t, logger))
+
+	// A failing base leaves the manifest alone
+	seq, err = logger.Rotate()
+	require.NoError(t, err)
+	assert.Error(t, logger.CommitBase(seq, BaseRDB, func(string) error { return os.ErrPermission }))
+	assert.Equal(t, "test.aof.2.base.aof", logger.Base().Name)
+	assert.NoFileExists(t, filepath.Join(dir, "test.aof.3.base.rdb"))
+
+	// Truncate keeps the base and drops the increments
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("third")))
+	require.NoError(t, logger.Truncate())
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("fourth")))
+	require.NoError(t, logger.Close())
+
+	// Files left by an interrupted rewrite are removed on the next start
+	stale := filepath.Join(dir, "test.aof.9.base.rdb")
+	require.NoError(t, os.WriteFile(stale, []byte("partial"), 0644))
+	unrelated := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(unrelated, []byte("keep"), 0644))
+
+	logger, err = OpenMultiPart(opts)
+	require.NoError(t, err)
+	defer logger.Close()
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, unrelated)
+	assert.Equal(t, []string{"base", "fourth"}, replayMultiPart(t, logger))
+
+	// Closed increments are checked as a whole, only the last one may have a torn tail
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("fifth")))
+	_, err = logger.Rotate()
+	require.NoError(t, err)
+	incrs := logger.Manifest().Incrs
+	closed := logger.Path(incrs[len(incrs)-2])
+	info, err := os.Stat(closed)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(closed, info.Size()-1))
+	_, err = logger.Replay(ctx, ReplayOptions{}, func(types.AOFCommand) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestMultiPartLogger_AdoptLegacyFiles(t *testing.T) {
+	dataDir := t.TempDir()
+	legacyAOF := filepath.Join(dataDir, "appendonly.aof")
+	legacyRDB := filepath.Join(dataDir, "vector.rdb")
+	ctx := context.Background()
+	builder := NewCommandBuilder()
+
+	single, err := NewAOFLogger(legacyAOF, SyncAlways)
+	require.NoError(t, err)
+	require.NoError(t, single.WriteCommand(ctx, builder.CreateDatabase("legacy")))
+	require.NoError(t, single.Close())
+	require.NoError(t, os.WriteFile(legacyRDB, []byte("snapshot"), 0644))
+
+	opts := MultiPartOptions{
+		Dir:          filepath.Join(dataDir, "appendonlydir"),
+		Name:         "appendonly.aof",
+		SyncStrategy: SyncEverySec,
+		LegacyAOF:    legacyAOF,
+		LegacyRDB:    legacyRDB,
+	}
+	logger, err := OpenMultiPart(opts)
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, legacyAOF)
+	assert.FileExists(t, legacyRDB)
+	base := logger.Base()
+	require.NotNil(t, base)
+	assert.Equal(t, BaseRDB, base.Format())
+	data, err := os.ReadFile(logger.Path(*base))
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data))
+
+	require.NoError(t, logger.WriteCommand(ctx, builder.CreateDatabase("new")))
+	assert.Equal(t, []string{"legacy", "new"}, replayMultiPart(t, logger))
+	require.NoError(t, logger.Close())
+
+	// Once a manifest exists the legacy paths are ignored
+	require.NoError(t, os.WriteFile(legacyAOF, nil, 0644))
+	logger, err = OpenMultiPart(opts)
+	require.NoError(t, err)
+	defer logger.Close()
+	assert.FileExists(t, legacyAOF)
+	assert.Equal(t, []string{"legacy", "new"}, replayMultiPart(t, logger))
+}
+
+func TestParseManifest(t *testing.T) {
+	manifest, err := ParseManifest(strings.NewReader(
+		"file a.aof.3.base.rdb seq 3 type b\n" +
+			"file a.aof.3.incr.aof seq 3 type i startts 1700000000\n" +
+			"\n" +
+			"file a.aof.4.incr.aof seq 4 type i\n"))
+	require.NoError(t, err)
+	require.NotNil(t, manifest.Base)
+	assert.Equal(t, BaseRDB, manifest.Base.Format())
+	assert.Len(t, manifest.Incrs, 2)
+
+	// Encoding round trips
+	reparsed, err := ParseManifest(strings.NewReader(string(manifest.encode())))
+	require.NoError(t, err)
+	assert.Equal(t, manifest, reparsed)
+
+	for _, invalid := range []string{
+		"",
+		"file a.aof.1.base.rdb seq 1 type b\n",
+		"file a.aof.1.incr.aof seq 1\n",
+		"file a.aof.1.incr.aof seq 1 type x\n",
+		"file a.aof.1.incr.aof seq one type i\n",
+		"file ../a.aof.1.incr.aof seq 1 type i\n",
+		"file a.aof.2.incr.aof seq 2 type i\nfile a.aof.1.incr.aof seq 1 type i\n",
+		"file a.aof.2.base.rdb seq 2 type b\nfile a.aof.1.incr.aof seq 1 type i\n",
+	} {
+		_, err := ParseManifest(strings.NewReader(invalid))
+		assert.Error(t, err, "manifest %q", invalid)
+	}
+}
+
+func TestMultiPartLogger_PlanRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	opts := MultiPartOptions{
+		Dir:          filepath.Join(dataDir, "appendonlydir"),
+		Name:         "test.aof",
+		SyncStrategy: SyncAlways,
+		ArchiveDir:   filepath.Join(dataDir, "archive"),
+	}
+	ctx := context.Background()
+	builder := NewCommandBuilder()
+	start := time.Now()
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) } // The rotation is at 0
+	write := func(logger *MultiPartLogger, name string, minutes int) {
+		command := builder.CreateDatabase(name)
+		command.Timestamp = at(minutes)
+		require.NoError(t, logger.WriteCommand(ctx, command))
+	}
+	replayPlan := func(plan *RestorePlan) []string {
+		var names []string
+		_, err := plan.Replay(ctx, ReplayOptions{}, func(command types.AOFCommand) error {
+			names = append(names, command.Database)
+			return nil
+		})
+		require.NoError(t, err)
+		return names
+	}
+
+	logger, err := OpenMultiPart(opts)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	// Increment 1 holds a and b, the base at 2 holds both, increment 2 holds c and d
+	write(logger, "a", -2)
+	write(logger, "b", -1)
+	seq, err := logger.Rotate()
+	require.NoError(t, err)
+	require.NoError(t, logger.CommitBase(seq, BaseAOF, func(path string) error {
+		return logger.current.writeFile(ctx, path, []types.AOFCommand{builder.CreateDatabase("a"), builder.CreateDatabase("b")})
+	}))
+	write(logger, "c", 1)
+	write(logger, "d", 2)
+
+	// Increment 1 was archived instead of deleted
+	assert.FileExists(t, filepath.Join(opts.ArchiveDir, "test.aof.1.incr.aof"))
+
+	// A time before the base replays the archived increment from an empty state
+	plan, err := logger.PlanRestore(RestoreTarget{Time: at(-2)})
+	require.NoError(t, err)
+	assert.Nil(t, plan.Base)
+	assert.Equal(t, []string{"a"}, replayPlan(plan))
+
+	// A time after the base starts from it
+	plan, err = logger.PlanRestore(RestoreTarget{Time: at(1)})
+	require.NoError(t, err)
+	require.NotNil(t, plan.Base)
+	assert.Equal(t, []string{"a", "b", "c"}, replayPlan(plan))
+
+	// A command position stops within its increment
+	plan, err = logger.PlanRestore(RestoreTarget{Seq: 1, Commands: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, replayPlan(plan))
+	plan, err = logger.PlanRestore(RestoreTarget{Seq: 2, Commands: 0})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, replayPlan(plan))
+	_, err = logger.PlanRestore(RestoreTarget{Seq: 5, Commands: 1})
+	assert.Error(t, err)
+
+	// Without the archived increment an early time can't be reached
+	require.NoError(t, os.Remove(filepath.Join(opts.ArchiveDir, "test.aof.1.incr.aof")))
+	plan, err = logger.PlanRestore(RestoreTarget{Time: at(-2)})
+	require.NoError(t, err)
+	_, err = plan.Replay(ctx, ReplayOptions{}, func(types.AOFCommand) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestParseRestoreTarget(t *testing.T) {
+	target, err := ParseRestoreTarget("2026-10-01T12:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC), target.Time.UTC())
+	assert.Equal(t, "2026-10-01T12:00:00Z", target.String())
+
+	target, err = ParseRestoreTarget("12:250")
+	require.NoError(t, err)
+	assert.Equal(t, RestoreTarget{Seq: 12, Commands: 250}, target)
+	assert.Equal(t, "12:250", target.String())
+
+	for _, invalid := range []string{"", "yesterday", "0:1", "1:-1", "a:1", "2026-10-01"} {
+		_, err := ParseRestoreTarget(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
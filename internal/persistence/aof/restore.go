@@ -0,0 +1,211 @@
+package aof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// RestoreTarget is the point in the log a restore stops at: either a time, or a number
+// of commands into an increment
+type RestoreTarget struct {
+	Time     time.Time // Replay the commands logged up to this time
+	Seq      int64     // Otherwise stop in the increment with this sequence
+	Commands int64     // after this many of its commands
+}
+
+// ParseRestoreTarget parses an RFC 3339 time such as 2026-10-01T12:00:00Z, or a command
+// position <increment>:<commands> such as 12:250 for the first 250 commands of
+// increment 12. Commands are numbered like scintirete-check -dump prints them.
+func ParseRestoreTarget(value string) (RestoreTarget, error) {
+	if seq, commands, ok := strings.Cut(value, ":"); ok && !strings.Contains(commands, ":") {
+		s, err1 := strconv.ParseInt(seq, 10, 64)
+		n, err2 := strconv.ParseInt(commands, 10, 64)
+		if err1 != nil || err2 != nil || s < 1 || n < 0 {
+			return RestoreTarget{}, fmt.Errorf("invalid command position %q, expected <increment>:<commands>", value)
+		}
+		return RestoreTarget{Seq: s, Commands: n}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return RestoreTarget{}, fmt.Errorf("invalid restore target %q, expected an RFC 3339 time or <increment>:<commands>", value)
+	}
+	return RestoreTarget{Time: t}, nil
+}
+
+// String formats the target like ParseRestoreTarget accepts it
+func (t RestoreTarget) String() string {
+	if t.Time.IsZero() {
+		return fmt.Sprintf("%d:%d", t.Seq, t.Commands)
+	}
+	return t.Time.Format(time.RFC3339Nano)
+}
+
+// RestorePlan lists the files that rebuild the state at a restore target
+type RestorePlan struct {
+	Base     *ManifestEntry // nil to start from an empty state
+	BasePath string
+	Incrs    []ManifestEntry // Increments replayed on top of the base, in sequence
+	paths    map[string]string
+	target   RestoreTarget
+	keys     encryption.KeyProvider // Keys of the logger, the default of Replay
+}
+
+// PlanRestore picks the files to rebuild the state at target from the current files and
+// the archive: the newest base from before the target and the increments following it.
+// Without archiving only targets after the current base can be reached.
+func (l *MultiPartLogger) PlanRestore(target RestoreTarget) (*RestorePlan, error) {
+	paths := make(map[string]string)
+	var files []ManifestEntry
+	if l.archiveDir != "" {
+		archived, err := l.readArchive()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range archived {
+			paths[entry.Name] = filepath.Join(l.archiveDir, entry.Name)
+			files = append(files, entry)
+		}
+	}
+	for _, entry := range l.Manifest().Files() {
+		if _, archived := paths[entry.Name]; !archived {
+			files = append(files, entry)
+		}
+		paths[entry.Name] = l.Path(entry)
+	}
+
+	var bases []ManifestEntry
+	incrs := make(map[int64]ManifestEntry)
+	var lastSeq int64
+	for _, entry := range files {
+		if entry.Type == FileTypeBase {
+			bases = append(bases, entry)
+			continue
+		}
+		incrs[entry.Seq] = entry
+		lastSeq = max(lastSeq, entry.Seq)
+	}
+
+	plan := &RestorePlan{paths: paths, target: target, keys: l.keys}
+	startSeq := int64(1)
+	for i := range bases {
+		base := bases[i]
+		before := base.Seq <= target.Seq
+		if target.Seq == 0 {
+			before = !base.Time.IsZero() && !base.Time.After(target.Time)
+		}
+		if before && (plan.Base == nil || base.Seq > plan.Base.Seq) {
+			plan.Base = &base
+			startSeq = base.Seq
+		}
+	}
+	if plan.Base == nil {
+		// Starting from scratch needs the log from the very first increment on
+		for _, base := range bases {
+			if base.Seq == 1 {
+				return nil, utils.ErrRecoveryFailed(fmt.Sprintf("no AOF base from before %s is available", target))
+			}
+		}
+	} else {
+		plan.BasePath = paths[plan.Base.Name]
+	}
+
+	endSeq := lastSeq
+	if target.Seq != 0 {
+		endSeq = target.Seq
+	}
+	for seq := startSeq; seq <= endSeq; seq++ {
+		incr, exists := incrs[seq]
+		if !exists {
+			return nil, utils.ErrRecoveryFailed(fmt.Sprintf("AOF increment %d needed to restore to %s is not available", seq, target))
+		}
+		plan.Incrs = append(plan.Incrs, incr)
+	}
+
+	return plan, nil
+}
+
+// errStopReplay ends the replay of a restore at its target
+var errStopReplay = errors.New("restore target reached")
+
+// Replay replays an AOF base and the increments up to the target. An RDB base is left to
+// the caller. Increments are read as they are, a torn tail is not cut off.
+func (p *RestorePlan) Replay(ctx context.Context, opts ReplayOptions, handler func(types.AOFCommand) error) (ReplayReport, error) {
+	var total ReplayReport
+	stopped := false
+	if opts.Keys == nil {
+		opts.Keys = p.keys
+	}
+
+	replay := func(entry ManifestEntry, accept func(types.AOFCommand, int64) bool) error {
+		var n int64
+		report, err := ReplayFile(ctx, p.paths[entry.Name], opts, func(command types.AOFCommand) error {
+			n++
+			if !accept(command, n) {
+				stopped = true
+				return errStopReplay
+			}
+			return handler(command)
+		})
+		total.Version = report.Version
+		total.Commands += report.Commands
+		total.SkippedRecords += report.SkippedRecords
+		total.SkippedBytes += report.SkippedBytes
+		total.TruncatedBytes += report.TruncatedBytes
+		if err != nil && !stopped {
+			return fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		return nil
+	}
+
+	if p.Base != nil && p.Base.Format() == BaseAOF {
+		if err := replay(*p.Base, func(types.AOFCommand, int64) bool { return true }); err != nil {
+			return total, err
+		}
+	}
+
+	for _, incr := range p.Incrs {
+		accept := func(command types.AOFCommand, _ int64) bool {
+			return !command.Timestamp.After(p.target.Time)
+		}
+		if p.target.Seq != 0 {
+			accept = func(_ types.AOFCommand, n int64) bool {
+				return incr.Seq < p.target.Seq || n <= p.target.Commands
+			}
+		}
+		if err := replay(incr, accept); err != nil || stopped {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// readArchive lists the archived files, oldest first
+func (l *MultiPartLogger) readArchive() ([]ManifestEntry, error) {
+	file, err := os.Open(l.archiveIndexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, utils.ErrRecoveryFailed("failed to open AOF archive index: " + err.Error())
+	}
+	defer file.Close()
+
+	entries, err := parseEntries(file)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, nil
+}
@@ -0,0 +1,216 @@
+// Package persistence provides backup operations of the persistence manager.
+package persistence
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// BackupResult is the outcome of CreateBackup
+type BackupResult struct {
+	Backup       rdb.BackupInfo
+	Pruned       []rdb.BackupInfo // Backups the retention policy no longer keeps, locally or on a target
+	UploadErrors map[string]error // Targets the backup could not be copied to, by name
+}
+
+// CreateBackup saves a snapshot of the database engine, keeps a copy of it in the
+// backup directory and copies it to the backup targets. Backups the retention policy no
+// longer keeps are deleted afterwards. A failed upload does not fail the backup, it is
+// reported in the result and retried by the next scheduled backup.
+func (m *Manager) CreateBackup(ctx context.Context) (BackupResult, error) {
+	if err := m.SaveEngineSnapshot(ctx); err != nil {
+		return BackupResult{}, err
+	}
+
+	path, err := m.backups.CreateBackup(ctx)
+	if err != nil {
+		return BackupResult{}, err
+	}
+	info, err := m.backups.GetBackup(filepath.Base(path))
+	if err != nil {
+		return BackupResult{}, err
+	}
+	result := BackupResult{Backup: info, UploadErrors: m.backups.Upload(ctx, info.Name)}
+	for _, target := range m.backups.Targets() {
+		if result.UploadErrors[target.Name()] == nil {
+			result.Backup.Targets = append(result.Backup.Targets, target.Name())
+		}
+	}
+	for target, err := range result.UploadErrors {
+		m.logger.Error(ctx, "Failed to copy backup to target", err, map[string]interface{}{
+			"component": "persistence_backup",
+			"backup":    info.Name,
+			"target":    target,
+		})
+	}
+
+	result.Pruned = m.prune(ctx, result.UploadErrors)
+
+	m.logger.Info(ctx, "Backup created", map[string]interface{}{
+		"component": "persistence_backup",
+		"backup":    info.Name,
+		"size":      info.Size,
+		"targets":   result.Backup.Targets,
+		"pruned":    len(result.Pruned),
+	})
+	return result, nil
+}
+
+// prune applies the retention policy to the backup directory and to the targets the
+// latest backup made it to. Errors only leave old backups behind, so they are logged.
+func (m *Manager) prune(ctx context.Context, uploadErrors map[string]error) []rdb.BackupInfo {
+	pruned, err := m.backups.Prune(m.config.BackupRetention)
+	if err != nil {
+		m.logger.Error(ctx, "Failed to apply backup retention policy", err, map[string]interface{}{
+			"component": "persistence_backup",
+		})
+	}
+
+	for _, target := range m.backups.Targets() {
+		if uploadErrors[target.Name()] != nil {
+			continue // Keep the older copies while the target misses the latest one
+		}
+		removed, err := m.backups.PruneTarget(ctx, target, m.config.BackupRetention)
+		if err != nil {
+			m.logger.Error(ctx, "Failed to apply backup retention policy", err, map[string]interface{}{
+				"component": "persistence_backup",
+				"target":    target.Name(),
+			})
+		}
+		pruned = append(pruned, removed...)
+	}
+	return pruned
+}
+
+// ListBackups returns the backups in the backup directory and on the backup targets,
+// newest first. A target that cannot be listed is logged and left out.
+func (m *Manager) ListBackups(ctx context.Context) ([]rdb.BackupInfo, error) {
+	backups, err := m.backups.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(backups))
+	for i, info := range backups {
+		index[info.Name] = i
+	}
+	for _, target := range m.backups.Targets() {
+		remote, err := m.backups.ListTarget(ctx, target)
+		if err != nil {
+			m.logger.Error(ctx, "Failed to list backup target", err, map[string]interface{}{
+				"component": "persistence_backup",
+				"target":    target.Name(),
+			})
+			continue
+		}
+		for _, info := range remote {
+			if i, ok := index[info.Name]; ok {
+				backups[i].Targets = append(backups[i].Targets, target.Name())
+				continue
+			}
+			index[info.Name] = len(backups)
+			backups = append(backups, info)
+		}
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool { return backups[i].ModTime.After(backups[j].ModTime) })
+	return backups, nil
+}
+
+// DeleteBackup deletes the backup with the given file name from the backup directory
+// and from the backup targets
+func (m *Manager) DeleteBackup(ctx context.Context, name string) error {
+	found := false
+	err := m.backups.DeleteBackup(name)
+	if err == nil {
+		found = true
+	} else if utils.GetErrorCode(err) != utils.ErrorCodeBackupNotFound {
+		return err
+	}
+	for _, target := range m.backups.Targets() {
+		err := target.Delete(ctx, name)
+		if err == nil {
+			found = true
+		} else if utils.GetErrorCode(err) != utils.ErrorCodeBackupNotFound {
+			return err
+		}
+	}
+	if !found {
+		return utils.ErrBackupNotFound(name)
+	}
+
+	m.logger.Info(ctx, "Backup deleted", map[string]interface{}{
+		"component": "persistence_backup",
+		"backup":    name,
+	})
+	return nil
+}
+
+// SyncBackups copies the local backups a target is missing to it and returns the
+// targets that failed
+func (m *Manager) SyncBackups(ctx context.Context) map[string]error {
+	return m.backups.SyncTargets(ctx)
+}
+
+// RestoreBackup replaces the state of the running database engine with a backup, which
+// is downloaded from a backup target if it is not in the backup directory. The backup is
+// read and checked in full first, so a damaged backup leaves the engine alone.
+// The AOF is then switched over to the backup as its base while writes are held, and the
+// engine swaps its state with RestoreFromSnapshot; writes after the restore are logged on
+// top of the backup. The command feed is reset, so replicas resynchronize in full.
+func (m *Manager) RestoreBackup(ctx context.Context, name string) (rdb.BackupInfo, error) {
+	m.mu.RLock()
+	applier := m.cmdApplier
+	m.mu.RUnlock()
+	if applier == nil {
+		return rdb.BackupInfo{}, utils.ErrPersistenceFailed("no database engine configured")
+	}
+
+	// A backup that is no longer local is downloaded from a target
+	backup, err := m.backups.GetBackup(name)
+	if utils.GetErrorCode(err) == utils.ErrorCodeBackupNotFound && len(m.backups.Targets()) > 0 {
+		backup, err = m.backups.Fetch(ctx, name)
+	}
+	if err != nil {
+		return rdb.BackupInfo{}, err
+	}
+	reader, err := rdb.NewRDBManager(backup.Path)
+	if err != nil {
+		return rdb.BackupInfo{}, err
+	}
+	reader.SetEncryption(m.config.KeyProvider)
+	snapshot, err := reader.Load(ctx)
+	if err != nil {
+		return rdb.BackupInfo{}, utils.ErrRecoveryFailed("failed to read backup " + name + ": " + err.Error())
+	}
+	if snapshot == nil {
+		return rdb.BackupInfo{}, utils.ErrBackupNotFound(name)
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	// The backup becomes the durable state before the engine changes
+	seq, err := m.replaceBase(applier, backup.Path, func() error {
+		return applier.ApplySnapshot(ctx, snapshot)
+	})
+	if err != nil {
+		m.logger.Error(ctx, "Failed to restore backup", err, map[string]interface{}{
+			"component": "persistence_backup",
+			"backup":    name,
+		})
+		return rdb.BackupInfo{}, err
+	}
+
+	m.logger.Info(ctx, "Backup restored", map[string]interface{}{
+		"component":     "persistence_backup",
+		"backup":        name,
+		"snapshot_time": snapshot.Timestamp,
+		"aof_increment": seq,
+	})
+	return backup, nil
+}
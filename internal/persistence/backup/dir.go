@@ -0,0 +1,163 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// checksumExt is the extension of the files a DirTarget keeps checksums in, in the
+// format of sha256sum
+const checksumExt = ".sha256"
+
+// DirTarget stores backups in a directory. Pointing it at the mount point of a remote
+// filesystem, such as NFS or an SFTP server mounted with SSHFS, keeps backups off the node.
+type DirTarget struct {
+	name string
+	dir  string
+}
+
+// NewDirTarget creates a target storing backups in dir
+func NewDirTarget(name, dir string) (*DirTarget, error) {
+	if dir == "" {
+		return nil, utils.ErrConfig(fmt.Sprintf("backup target '%s' needs a path", name))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to create backup target directory", err)
+	}
+	return &DirTarget{name: name, dir: dir}, nil
+}
+
+// Name returns the name of the target
+func (t *DirTarget) Name() string {
+	return t.name
+}
+
+// Put writes the object and its checksum to temporary files and renames them into place,
+// the checksum first, so an object that is visible always has its checksum
+func (t *DirTarget) Put(ctx context.Context, name string, r io.ReaderAt, size int64, checksum string) error {
+	if !validName(name) {
+		return utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	if err := t.writeFile(name+checksumExt, strings.NewReader(checksum+"  "+name+"\n")); err != nil {
+		return err
+	}
+	if err := t.writeFile(name, io.NewSectionReader(r, 0, size)); err != nil {
+		return err
+	}
+	return syncDir(t.dir)
+}
+
+// writeFile replaces the file name in the directory with the content of r
+func (t *DirTarget) writeFile(name string, r io.Reader) (err error) {
+	tempPath := filepath.Join(t.dir, "."+name+".tmp")
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to create file on backup target", err)
+	}
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(tempPath) // Clean up on error
+		}
+	}()
+
+	if _, err = io.Copy(file, r); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to write file on backup target", err)
+	}
+	if err = file.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync file on backup target", err)
+	}
+	if err = file.Close(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to close file on backup target", err)
+	}
+	if err = os.Rename(tempPath, filepath.Join(t.dir, name)); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to rename file on backup target", err)
+	}
+	return nil
+}
+
+// Get opens an object, checked against its checksum file if it has one
+func (t *DirTarget) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !validName(name) {
+		return nil, utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	file, err := os.Open(filepath.Join(t.dir, name))
+	if os.IsNotExist(err) {
+		return nil, utils.ErrBackupNotFound(name)
+	} else if err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to open backup on target", err)
+	}
+	return newCheckedReader(file, name, t.checksum(name)), nil
+}
+
+// List returns the objects in the directory
+func (t *DirTarget) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to read backup target directory", err)
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !validName(name) || strings.HasSuffix(name, checksumExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Name: name, Size: info.Size(), ModTime: info.ModTime(), Checksum: t.checksum(name)})
+	}
+	return objects, nil
+}
+
+// Delete removes an object and its checksum file
+func (t *DirTarget) Delete(ctx context.Context, name string) error {
+	if !validName(name) {
+		return utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	if err := os.Remove(filepath.Join(t.dir, name)); os.IsNotExist(err) {
+		return utils.ErrBackupNotFound(name)
+	} else if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to delete backup on target", err)
+	}
+	os.Remove(filepath.Join(t.dir, name+checksumExt))
+	return nil
+}
+
+// checksum reads the checksum file of an object, empty if there is none
+func (t *DirTarget) checksum(name string) string {
+	data, err := os.ReadFile(filepath.Join(t.dir, name+checksumExt))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// syncDir makes renames and new files in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to open backup target directory", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync backup target directory", err)
+	}
+	return nil
+}
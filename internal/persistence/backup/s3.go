@@ -0,0 +1,449 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+const (
+	// MinPartSize is the smallest part S3 accepts in a multipart upload, except for the last one
+	MinPartSize = 5 * 1024 * 1024
+	// DefaultPartSize is the part size of multipart uploads if none is configured
+	DefaultPartSize = 16 * 1024 * 1024
+
+	checksumHeader = "X-Amz-Meta-Sha256" // Object metadata holding the hex SHA-256 of the content
+	emptySHA256    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Target stores backups in a bucket of an S3-compatible object store. Requests are
+// signed with AWS Signature Version 4, which also makes the store check every request
+// body against its SHA-256. Files larger than the part size go up in a multipart upload.
+type S3Target struct {
+	name         string
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	prefix       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	pathStyle    bool
+	partSize     int64
+	client       *http.Client
+	now          func() time.Time
+}
+
+// NewS3Target creates a target storing backups in an S3 bucket
+func NewS3Target(config TargetConfig) (*S3Target, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, utils.ErrConfig(fmt.Sprintf("backup target '%s' needs an http or https endpoint", config.Name))
+	}
+	if config.Bucket == "" {
+		return nil, utils.ErrConfig(fmt.Sprintf("backup target '%s' needs a bucket", config.Name))
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, utils.ErrConfig(fmt.Sprintf("backup target '%s' needs an access key and a secret key", config.Name))
+	}
+
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	partSize := config.PartSize
+	if partSize == 0 {
+		partSize = DefaultPartSize
+	}
+	if partSize < MinPartSize {
+		return nil, utils.ErrConfig(fmt.Sprintf("backup target '%s' part size must be at least %d bytes", config.Name, MinPartSize))
+	}
+
+	return &S3Target{
+		name:         config.Name,
+		endpoint:     endpoint,
+		region:       region,
+		bucket:       config.Bucket,
+		prefix:       strings.TrimPrefix(config.Prefix, "/"),
+		accessKey:    config.AccessKey,
+		secretKey:    config.SecretKey,
+		sessionToken: config.SessionToken,
+		pathStyle:    config.PathStyle,
+		partSize:     partSize,
+		client:       &http.Client{},
+		now:          time.Now,
+	}, nil
+}
+
+// Name returns the name of the target
+func (t *S3Target) Name() string {
+	return t.name
+}
+
+// Put uploads an object with its checksum as metadata, in parts if it is larger than
+// the part size, and checks the stored size and checksum afterwards
+func (t *S3Target) Put(ctx context.Context, name string, r io.ReaderAt, size int64, checksum string) error {
+	if !validName(name) {
+		return utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	header := http.Header{checksumHeader: {checksum}}
+	if size <= t.partSize {
+		body := make([]byte, size)
+		if _, err := r.ReadAt(body, 0); err != nil && err != io.EOF {
+			return utils.ErrPersistenceFailedWithCause("failed to read backup", err)
+		}
+		resp, err := t.do(ctx, http.MethodPut, t.prefix+name, nil, header, body)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	} else if err := t.putMultipart(ctx, name, r, size, header); err != nil {
+		return err
+	}
+
+	// The store checked every request body, check that the object came out whole
+	resp, err := t.do(ctx, http.MethodHead, t.prefix+name, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.ContentLength != size || !strings.EqualFold(resp.Header.Get(checksumHeader), checksum) {
+		return utils.ErrCorruptedData(fmt.Sprintf("backup %s on target %s has %d bytes and checksum %q after upload, expected %d bytes and %q",
+			name, t.name, resp.ContentLength, resp.Header.Get(checksumHeader), size, checksum))
+	}
+	return nil
+}
+
+// putMultipart uploads an object in parts of the part size, one part in memory at a time.
+// A failed upload is aborted so the store drops the parts.
+func (t *S3Target) putMultipart(ctx context.Context, name string, r io.ReaderAt, size int64, header http.Header) (err error) {
+	key := t.prefix + name
+	resp, err := t.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = decodeXML(resp, &initiated)
+	if err != nil {
+		return err
+	}
+	uploadID := initiated.UploadID
+	defer func() {
+		if err != nil {
+			if resp, abortErr := t.do(context.WithoutCancel(ctx), http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil); abortErr == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	type completedPart struct {
+		PartNumber int
+		ETag       string
+	}
+	var complete struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}
+	buf := make([]byte, t.partSize)
+	for offset, number := int64(0), 1; offset < size; number++ {
+		part := buf[:min(t.partSize, size-offset)]
+		if _, err := r.ReadAt(part, offset); err != nil && err != io.EOF {
+			return utils.ErrPersistenceFailedWithCause("failed to read backup", err)
+		}
+
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := t.do(ctx, http.MethodPut, key, query, nil, part)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		offset += int64(len(part))
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to encode multipart upload", err)
+	}
+	resp, err = t.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, body)
+	if err != nil {
+		return err
+	}
+	// Completing can fail after the response started, with an error in a 200 response
+	var result struct {
+		XMLName xml.Name
+		s3Error
+	}
+	if err := decodeXML(resp, &result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return result.s3Error.err(t.name, http.StatusOK)
+	}
+	return nil
+}
+
+// Get downloads an object, checked against the checksum stored with it
+func (t *S3Target) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !validName(name) {
+		return nil, utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	resp, err := t.do(ctx, http.MethodGet, t.prefix+name, nil, nil, nil)
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			return nil, utils.ErrBackupNotFound(name)
+		}
+		return nil, err
+	}
+	return newCheckedReader(resp.Body, name, resp.Header.Get(checksumHeader)), nil
+}
+
+// List returns the objects under the prefix, without the ones in deeper "directories"
+func (t *S3Target) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	query := url.Values{"list-type": {"2"}, "prefix": {t.prefix}}
+	for {
+		resp, err := t.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := decodeXML(resp, &result); err != nil {
+			return nil, err
+		}
+
+		for _, content := range result.Contents {
+			name := strings.TrimPrefix(content.Key, t.prefix)
+			if validName(name) {
+				objects = append(objects, Object{Name: name, Size: content.Size, ModTime: content.LastModified})
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// Delete removes an object
+func (t *S3Target) Delete(ctx context.Context, name string) error {
+	if !validName(name) {
+		return utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	resp, err := t.do(ctx, http.MethodDelete, t.prefix+name, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for key, the bucket itself if key is empty. Responses other
+// than 2xx are turned into errors.
+func (t *S3Target) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *t.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if t.pathStyle {
+		path += "/" + t.bucket
+	} else {
+		u.Host = t.bucket + "." + u.Host
+	}
+	u.Path = path + "/" + key
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to create S3 request", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	t.sign(req, sha256Hex(body))
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause(fmt.Sprintf("request to backup target %s failed", t.name), err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var s3Err s3Error
+		if method != http.MethodHead {
+			xml.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&s3Err)
+		}
+		return nil, s3Err.err(t.name, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to req
+func (t *S3Target) sign(req *http.Request, payloadHash string) {
+	now := t.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if t.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", t.sessionToken)
+	}
+
+	signed := []string{"host"}
+	for name := range req.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") || name == "content-md5" || name == "content-type" {
+			signed = append(signed, name)
+		}
+	}
+	sort.Strings(signed)
+
+	scope := amzDate[:8] + "/" + t.region + "/s3/aws4_request"
+	signature := signature(t.secretKey, t.region, amzDate, canonicalRequest(req, req.URL.Host, signed))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.accessKey, scope, strings.Join(signed, ";"), signature))
+}
+
+// canonicalRequest renders req as AWS Signature Version 4 signs it, with the headers in
+// signed, which are lower case and sorted
+func canonicalRequest(req *http.Request, host string, signed []string) string {
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	b.WriteString(uri + "\n")
+	b.WriteString(canonicalQuery(req.URL.Query()) + "\n")
+	for _, name := range signed {
+		value := host
+		if name != "host" {
+			value = strings.Join(req.Header.Values(name), ",")
+		}
+		b.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	b.WriteString("\n" + strings.Join(signed, ";") + "\n")
+	b.WriteString(req.Header.Get("X-Amz-Content-Sha256"))
+	return b.String()
+}
+
+// signature signs a canonical request with a key derived from the secret key
+func signature(secretKey, region, amzDate, canonical string) string {
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), amzDate[:8])
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	if len(data) == 0 {
+		return emptySHA256
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalQuery encodes query sorted by key and value, escaped the way S3 signs it
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath escapes each segment of a path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape percent-encodes everything but the unreserved characters of RFC 3986
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// decodeXML decodes and closes a response body
+func decodeXML(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to decode S3 response", err)
+	}
+	return nil
+}
+
+// s3Error is the error document of S3
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e s3Error) err(target string, status int) error {
+	message := fmt.Sprintf("backup target %s responded with %d", target, status)
+	if e.Code != "" {
+		message += fmt.Sprintf(": %s: %s", e.Code, e.Message)
+	}
+	err := utils.ErrPersistenceFailed(message)
+	err.Context = map[string]interface{}{"status": status}
+	return err
+}
+
+// isStatus reports whether err is a response with the given status
+func isStatus(err error, status int) bool {
+	scintireteErr, ok := err.(*utils.ScintireteError)
+	return ok && scintireteErr.Context["status"] == status
+}
@@ -0,0 +1,379 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an in-process S3-compatible store with path-style addressing. It checks the
+// signature and payload hash of every request like S3 does.
+type fakeS3 struct {
+	t         *testing.T
+	bucket    string
+	accessKey string
+	secretKey string
+	pageSize  int // Keys per ListObjectsV2 page
+
+	mu        sync.Mutex
+	objects   map[string]fakeObject
+	uploads   map[string]map[int][]byte
+	nextID    int
+	completed int // Multipart uploads completed
+	failPart  int // Part number to reject, 0 for none
+}
+
+type fakeObject struct {
+	data     []byte
+	checksum string
+	modTime  time.Time
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{
+		t:         t,
+		bucket:    "backups",
+		accessKey: "AKIAFAKE",
+		secretKey: "fake-secret",
+		pageSize:  2,
+		objects:   make(map[string]fakeObject),
+		uploads:   make(map[string]map[int][]byte),
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(f.t, err)
+	if code := f.authenticate(r, body); code != "" {
+		f.error(w, http.StatusForbidden, code)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if bucket != f.bucket {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, query)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = map[int][]byte{0: []byte(r.Header.Get(checksumHeader))}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", f.bucket, key, id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		if number == f.failPart {
+			f.error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		parts[number] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.complete(w, key, query.Get("uploadId"), body)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = fakeObject{data: body, checksum: r.Header.Get(checksumHeader), modTime: time.Now()}
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set(checksumHeader, object.checksum)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// authenticate checks the signature and payload hash of a request and returns the S3
+// error code if they are wrong
+func (f *fakeS3) authenticate(r *http.Request, body []byte) string {
+	if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		return "XAmzContentSHA256Mismatch"
+	}
+
+	var credential, signedHeaders, sig string
+	for _, field := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			sig = value
+		}
+	}
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 || scope[0] != f.accessKey {
+		return "InvalidAccessKeyId"
+	}
+	canonical := canonicalRequest(r, r.Host, strings.Split(signedHeaders, ";"))
+	if signature(f.secretKey, scope[2], r.Header.Get("X-Amz-Date"), canonical) != sig {
+		return "SignatureDoesNotMatch"
+	}
+	return ""
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query map[string][]string) {
+	prefix := first(query["prefix"])
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > first(query["continuation-token"]) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+	}
+	var result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		object := f.objects[key]
+		result.Contents = append(result.Contents, content{Key: key, Size: len(object.data), LastModified: object.modTime.UTC().Format(time.RFC3339)})
+	}
+	require.NoError(f.t, xml.NewEncoder(w).Encode(result))
+}
+
+func (f *fakeS3) complete(w http.ResponseWriter, key, uploadID string, body []byte) {
+	parts, ok := f.uploads[uploadID]
+	if !ok {
+		f.error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	var request struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	require.NoError(f.t, xml.Unmarshal(body, &request))
+
+	var data []byte
+	for i, part := range request.Parts {
+		content, ok := parts[part.PartNumber]
+		if !ok || part.PartNumber != i+1 || part.ETag != etag(content) {
+			f.error(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		if i < len(request.Parts)-1 && len(content) < MinPartSize {
+			f.error(w, http.StatusBadRequest, "EntityTooSmall")
+			return
+		}
+		data = append(data, content...)
+	}
+	f.objects[key] = fakeObject{data: data, checksum: string(parts[0]), modTime: time.Now()}
+	delete(f.uploads, uploadID)
+	f.completed++
+	fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>", key, etag(data))
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	data := make([]byte, n)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func put(t *testing.T, target Target, name string, data []byte) error {
+	sum := sha256Hex(data)
+	return target.Put(context.Background(), name, bytes.NewReader(data), int64(len(data)), sum)
+}
+
+func TestS3Target(t *testing.T) {
+	ctx := context.Background()
+	fake, server := newFakeS3(t)
+	target, err := NewTarget(TargetConfig{
+		Name:      "s3",
+		Type:      "s3",
+		Endpoint:  server.URL,
+		Bucket:    fake.bucket,
+		Prefix:    "node1/",
+		AccessKey: fake.accessKey,
+		SecretKey: fake.secretKey,
+		PathStyle: true,
+		PartSize:  MinPartSize,
+	})
+	require.NoError(t, err)
+
+	// A small backup goes up in one request, a large one in parts
+	small := []byte("small backup")
+	require.NoError(t, put(t, target, "rdb_backup_20261018_120000.flatbuf", small))
+	assert.Equal(t, 0, fake.completed)
+	large := randomBytes(t, 2*MinPartSize+1024*1024)
+	require.NoError(t, put(t, target, "rdb_backup_20261018_130000.flatbuf", large))
+	assert.Equal(t, 1, fake.completed)
+	assert.Empty(t, fake.uploads)
+
+	// Listing pages through the prefix and leaves out other prefixes and nested keys
+	fake.objects["node1/nested/rdb_backup_20261018_140000.flatbuf"] = fakeObject{data: small}
+	fake.objects["node2/rdb_backup_20261018_140000.flatbuf"] = fakeObject{data: small}
+	fake.objects["node1/rdb_backup_20261018_150000.flatbuf"] = fakeObject{data: small}
+	objects, err := target.List(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, object := range objects {
+		names = append(names, object.Name)
+	}
+	assert.Equal(t, []string{"rdb_backup_20261018_120000.flatbuf", "rdb_backup_20261018_130000.flatbuf", "rdb_backup_20261018_150000.flatbuf"}, names)
+	assert.Equal(t, int64(len(large)), objects[1].Size)
+
+	// Downloads are checked against the checksum stored with them
+	reader, err := target.Get(ctx, "rdb_backup_20261018_130000.flatbuf")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, large, data)
+
+	corrupted := fake.objects["node1/rdb_backup_20261018_130000.flatbuf"]
+	corrupted.data = append([]byte(nil), corrupted.data...)
+	corrupted.data[len(corrupted.data)/2] ^= 0xff
+	fake.objects["node1/rdb_backup_20261018_130000.flatbuf"] = corrupted
+	reader, err = target.Get(ctx, "rdb_backup_20261018_130000.flatbuf")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, utils.ErrorCodeCorruptedData, utils.GetErrorCode(err))
+
+	_, err = target.Get(ctx, "rdb_backup_missing.flatbuf")
+	assert.Equal(t, utils.ErrorCodeBackupNotFound, utils.GetErrorCode(err))
+
+	require.NoError(t, target.Delete(ctx, "rdb_backup_20261018_120000.flatbuf"))
+	_, ok := fake.objects["node1/rdb_backup_20261018_120000.flatbuf"]
+	assert.False(t, ok)
+
+	// A failed part aborts the upload so the store drops the parts
+	fake.failPart = 2
+	assert.Error(t, put(t, target, "rdb_backup_20261018_160000.flatbuf", large))
+	assert.Empty(t, fake.uploads)
+	_, ok = fake.objects["node1/rdb_backup_20261018_160000.flatbuf"]
+	assert.False(t, ok)
+
+	// The store refuses requests signed with another key
+	wrong, err := NewS3Target(TargetConfig{Name: "s3", Endpoint: server.URL, Bucket: fake.bucket, AccessKey: fake.accessKey, SecretKey: "wrong", PathStyle: true})
+	require.NoError(t, err)
+	err = put(t, wrong, "rdb_backup_20261018_170000.flatbuf", small)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SignatureDoesNotMatch")
+}
+
+// TestSignature checks the signing against the GET object example of the AWS
+// Signature Version 4 documentation
+func TestSignature(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://examplebucket.s3.amazonaws.com/test.txt", nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	req.Header.Set("X-Amz-Date", "20130524T000000Z")
+
+	canonical := canonicalRequest(req, req.URL.Host, []string{"host", "range", "x-amz-content-sha256", "x-amz-date"})
+	assert.Equal(t, "f0e8bdb87c964420e857bd35b5d6ed310bd44f0170aba48dd91039c6036bdb41",
+		signature("wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", "us-east-1", "20130524T000000Z", canonical))
+}
+
+func TestDirTarget(t *testing.T) {
+	ctx := context.Background()
+	target, err := NewTarget(TargetConfig{Type: "dir", Path: t.TempDir()})
+	require.NoError(t, err)
+	assert.Equal(t, "dir", target.Name())
+
+	data := randomBytes(t, 4096)
+	require.NoError(t, put(t, target, "rdb_backup_20261018_120000.flatbuf", data))
+	assert.Error(t, put(t, target, "../escape.flatbuf", data))
+
+	objects, err := target.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, sha256Hex(data), objects[0].Checksum)
+
+	reader, err := target.Get(ctx, "rdb_backup_20261018_120000.flatbuf")
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, data, got)
+
+	// A copy that does not match its checksum is refused on download
+	require.NoError(t, target.Put(ctx, "rdb_backup_20261018_130000.flatbuf", bytes.NewReader(data), int64(len(data)), sha256Hex([]byte("other"))))
+	reader, err = target.Get(ctx, "rdb_backup_20261018_130000.flatbuf")
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, utils.ErrorCodeCorruptedData, utils.GetErrorCode(err))
+
+	require.NoError(t, target.Delete(ctx, "rdb_backup_20261018_130000.flatbuf"))
+	assert.Equal(t, utils.ErrorCodeBackupNotFound, utils.GetErrorCode(target.Delete(ctx, "rdb_backup_20261018_130000.flatbuf")))
+}
@@ -0,0 +1,131 @@
+// Package backup provides the targets backups are copied to, so they outlive the node
+// that took them: a directory, which may be a mounted remote filesystem, or an
+// S3-compatible object store.
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// Target stores backup files under flat names
+type Target interface {
+	// Name identifies the target in logs and listings
+	Name() string
+	// Put stores size bytes from r under name, replacing an older object. checksum is the
+	// hex SHA-256 of the content and is kept with it for Get to check.
+	Put(ctx context.Context, name string, r io.ReaderAt, size int64, checksum string) error
+	// Get opens an object. Reading it to the end fails if it does not match its checksum.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the objects of the target
+	List(ctx context.Context) ([]Object, error)
+	// Delete removes an object
+	Delete(ctx context.Context, name string) error
+}
+
+// Object is a file stored on a target
+type Object struct {
+	Name     string
+	Size     int64
+	ModTime  time.Time // When it was stored
+	Checksum string    // Hex SHA-256, empty if the target does not report it in listings
+}
+
+// TargetConfig configures a target
+type TargetConfig struct {
+	Name string // Defaults to the type
+	Type string // dir or s3
+
+	// dir
+	Path string // Directory, may be the mount point of a remote filesystem such as NFS or SSHFS
+
+	// s3
+	Endpoint     string // Such as https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Region       string // Defaults to us-east-1
+	Bucket       string
+	Prefix       string // Prepended to object names, such as scintirete/
+	AccessKey    string // Defaults to AWS_ACCESS_KEY_ID
+	SecretKey    string // Defaults to AWS_SECRET_ACCESS_KEY
+	SessionToken string // Defaults to AWS_SESSION_TOKEN
+	PathStyle    bool   // Address the bucket in the path instead of the host name, as most S3-compatible stores need
+	PartSize     int64  // Size of the parts of multipart uploads in bytes; files up to it are uploaded at once
+}
+
+// NewTarget creates the target described by config
+func NewTarget(config TargetConfig) (Target, error) {
+	if config.Name == "" {
+		config.Name = config.Type
+	}
+
+	switch strings.ToLower(config.Type) {
+	case "dir":
+		return NewDirTarget(config.Name, config.Path)
+	case "s3":
+		if config.AccessKey == "" && config.SecretKey == "" {
+			config.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+			config.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			if config.SessionToken == "" {
+				config.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+			}
+		}
+		return NewS3Target(config)
+	default:
+		return nil, utils.ErrConfig(fmt.Sprintf("unknown backup target type '%s', expected dir or s3", config.Type))
+	}
+}
+
+// FileChecksum returns the hex SHA-256 of a file
+func FileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", utils.ErrPersistenceFailedWithCause("failed to open backup", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", utils.ErrPersistenceFailedWithCause("failed to read backup", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkedReader fails at the end of its content if the content does not match checksum
+type checkedReader struct {
+	io.ReadCloser
+	name     string
+	checksum string
+	hash     hash.Hash
+}
+
+// newCheckedReader checks r against checksum; an empty checksum is not checked
+func newCheckedReader(r io.ReadCloser, name, checksum string) io.ReadCloser {
+	if checksum == "" {
+		return r
+	}
+	return &checkedReader{ReadCloser: r, name: name, checksum: strings.ToLower(checksum), hash: sha256.New()}
+}
+
+func (r *checkedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.checksum {
+			return n, utils.ErrCorruptedData(fmt.Sprintf("backup %s has checksum %s, expected %s", r.name, sum, r.checksum))
+		}
+	}
+	return n, err
+}
+
+// validName reports whether name can be stored on a target
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,61 @@
+// Package persistence provides the persistence side of cluster mode.
+package persistence
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// Reset discards the state of the database engine along with everything logged so far.
+// A cluster node resets on startup, because its state is rebuilt from the Raft snapshot
+// and log, which would otherwise be applied on top of what the AOF recovered.
+func (m *Manager) Reset(ctx context.Context) error {
+	m.mu.RLock()
+	applier := m.cmdApplier
+	m.mu.RUnlock()
+	if applier == nil {
+		return utils.ErrPersistenceFailed("no database engine configured")
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	// An empty snapshot becomes the base of the AOF
+	path := filepath.Join(m.config.DataDir, syncFilename)
+	empty, err := rdb.NewRDBManagerWithCompression(path, m.rdbManager.Compression())
+	if err != nil {
+		return err
+	}
+	empty.SetEncryption(m.config.KeyProvider)
+	defer os.Remove(path)
+
+	if err := empty.SaveStream(ctx, emptyState{}); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to save empty snapshot", err)
+	}
+
+	seq, err := m.replaceBase(applier, path, func() error {
+		return applier.ApplySnapshotStream(ctx, func(rdb.SnapshotVisitor) error {
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info(ctx, "Discarded local state", map[string]interface{}{
+		"component":     "persistence_cluster",
+		"aof_increment": seq,
+	})
+	return nil
+}
+
+// emptyState is a database state without databases
+type emptyState struct{}
+
+func (emptyState) StreamDatabaseState(ctx context.Context, visitor rdb.StateVisitor) error {
+	return nil
+}
@@ -13,7 +13,11 @@ import (
 type DatabaseEngine interface {
 	// Snapshot operations
 	GetDatabaseState(ctx context.Context) (map[string]rdb.DatabaseState, error)
+	StreamDatabaseState(ctx context.Context, visitor rdb.StateVisitor) error
+	CutSnapshot(ctx context.Context, mark func() error) (rdb.SnapshotCut, error)
+	HoldWrites(fn func() error) error
 	RestoreFromSnapshot(ctx context.Context, snapshot *rdb.RDBSnapshot) error
+	RestoreFromSnapshotStream(ctx context.Context, load func(rdb.SnapshotVisitor) error) error
 
 	// Command replay operations
 	ApplyCommand(ctx context.Context, command types.AOFCommand) error
@@ -43,6 +47,12 @@ func (ca *CommandApplier) ApplySnapshot(ctx context.Context, snapshot *rdb.RDBSn
 	return ca.engine.RestoreFromSnapshot(ctx, snapshot)
 }
 
+// ApplySnapshotStream replaces the database engine's state with the snapshot that load
+// passes to its visitor, one collection at a time
+func (ca *CommandApplier) ApplySnapshotStream(ctx context.Context, load func(rdb.SnapshotVisitor) error) error {
+	return ca.engine.RestoreFromSnapshotStream(ctx, load)
+}
+
 // ApplyCommand applies a single AOF command to the database engine
 func (ca *CommandApplier) ApplyCommand(ctx context.Context, command types.AOFCommand) error {
 	return ca.engine.ApplyCommand(ctx, command)
@@ -53,7 +63,29 @@ func (ca *CommandApplier) GetDatabaseState(ctx context.Context) (map[string]rdb.
 	return ca.engine.GetDatabaseState(ctx)
 }
 
+// StreamDatabaseState passes the state of all databases to visitor one collection at a time
+func (ca *CommandApplier) StreamDatabaseState(ctx context.Context, visitor rdb.StateVisitor) error {
+	return ca.engine.StreamDatabaseState(ctx, visitor)
+}
+
+// CutSnapshot freezes the database engine's state at the moment mark runs
+func (ca *CommandApplier) CutSnapshot(ctx context.Context, mark func() error) (rdb.SnapshotCut, error) {
+	return ca.engine.CutSnapshot(ctx, mark)
+}
+
+// HoldWrites runs fn while no change to the database engine is waiting to be logged
+func (ca *CommandApplier) HoldWrites(fn func() error) error {
+	return ca.engine.HoldWrites(fn)
+}
+
 // GetOptimizedCommands gets a list of optimized commands for AOF rewrite
 func (ca *CommandApplier) GetOptimizedCommands(ctx context.Context) ([]types.AOFCommand, error) {
 	return ca.engine.GetOptimizedCommands(ctx)
 }
+
+// discardSnapshot reads a snapshot without applying it, for recovery without an engine
+type discardSnapshot struct{}
+
+func (discardSnapshot) VisitDatabase(rdb.DatabaseSnapshot) error { return nil }
+
+func (discardSnapshot) VisitCollection(string, rdb.CollectionSnapshot) error { return nil }
@@ -0,0 +1,208 @@
+// Package encryption provides envelope encryption of the files Scintirete persists.
+//
+// Every file is encrypted with AES-256-GCM under a data key of its own, which is
+// generated when the file is created and stored in it, wrapped by a master key of a
+// KeyProvider. A new master key therefore takes effect with the next file written,
+// such as the next snapshot or AOF rewrite, while older files still need the master key
+// they were written with.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+const (
+	// DataKeySize is the size of data and master keys, which select AES-256
+	DataKeySize = 32
+	// NonceSize is the size of the AES-GCM nonces
+	NonceSize = 12
+	// Overhead is the size Seal adds to a plaintext
+	Overhead = NonceSize + 16
+
+	maxEnvelopeField = 4096 // Far above any key ID or wrapped key
+)
+
+// KeyProvider wraps and unwraps data keys with master keys, such as keys kept in a file
+// or a key management service
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the current master key and returns the ID of
+	// that master key along with the wrapped key
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped with the master key keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Envelope is the wrapped data key stored in an encrypted file.
+//
+// It is encoded as the length of the key ID (uint16), the key ID, the length of the
+// wrapped key (uint16) and the wrapped key, with little-endian integers.
+type Envelope struct {
+	KeyID      string // Master key the data key is wrapped with
+	WrappedKey []byte
+}
+
+// Size returns the length of the encoded envelope
+func (e Envelope) Size() int {
+	return 4 + len(e.KeyID) + len(e.WrappedKey)
+}
+
+// Marshal encodes the envelope
+func (e Envelope) Marshal() []byte {
+	data := make([]byte, 0, e.Size())
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(e.KeyID)))
+	data = append(data, e.KeyID...)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(e.WrappedKey)))
+	return append(data, e.WrappedKey...)
+}
+
+// ReadEnvelope reads an encoded envelope. A reader that ends inside the envelope
+// returns io.ErrUnexpectedEOF.
+func ReadEnvelope(r io.Reader) (Envelope, error) {
+	keyID, err := readField(r)
+	if err != nil {
+		return Envelope{}, err
+	}
+	wrapped, err := readField(r)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{KeyID: string(keyID), WrappedKey: wrapped}, nil
+}
+
+// readField reads a uint16 length and as many bytes
+func readField(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	n := binary.LittleEndian.Uint16(length[:])
+	if n == 0 || n > maxEnvelopeField {
+		return nil, fmt.Errorf("invalid encryption envelope field length %d", n)
+	}
+	field := make([]byte, n)
+	if _, err := io.ReadFull(r, field); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return field, nil
+}
+
+// FileKey is the data key of a single file
+type FileKey struct {
+	envelope Envelope
+	aead     cipher.AEAD
+}
+
+// NewFileKey generates a data key for a new file and wraps it with the current master
+// key of keys
+func NewFileKey(ctx context.Context, keys KeyProvider) (*FileKey, error) {
+	dataKey := make([]byte, DataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to generate data key", err)
+	}
+
+	keyID, wrapped, err := keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to wrap data key", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &FileKey{envelope: Envelope{KeyID: keyID, WrappedKey: wrapped}, aead: aead}, nil
+}
+
+// OpenFileKey unwraps the data key of an existing file. It fails with ErrConfig if keys
+// is nil or does not have the master key.
+func OpenFileKey(ctx context.Context, keys KeyProvider, envelope Envelope) (*FileKey, error) {
+	if keys == nil {
+		return nil, utils.ErrConfig(fmt.Sprintf("file is encrypted with master key '%s' but no encryption key is configured", envelope.KeyID))
+	}
+
+	dataKey, err := keys.UnwrapKey(ctx, envelope.KeyID, envelope.WrappedKey)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, err
+		}
+		return nil, utils.ErrConfig(fmt.Sprintf("failed to unwrap data key with master key '%s': %v", envelope.KeyID, err))
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &FileKey{envelope: envelope, aead: aead}, nil
+}
+
+// Envelope returns the wrapped data key to store in the file
+func (k *FileKey) Envelope() Envelope {
+	return k.envelope
+}
+
+// Seal encrypts a plaintext under a random nonce and returns the nonce followed by the
+// ciphertext. Random nonces suit files that are appended to across restarts; a data key
+// should seal no more than 2^32 of them.
+func (k *FileKey) Seal(plaintext []byte) []byte {
+	sealed := make([]byte, NonceSize, Overhead+len(plaintext))
+	if _, err := rand.Read(sealed); err != nil {
+		panic("encryption: failed to generate nonce: " + err.Error()) // crypto/rand does not fail on supported platforms
+	}
+	return k.aead.Seal(sealed, sealed, plaintext, nil)
+}
+
+// Open decrypts the output of Seal
+func (k *FileKey) Open(sealed []byte) ([]byte, error) {
+	if len(sealed) < Overhead {
+		return nil, errors.New("encrypted data is too short")
+	}
+	return k.aead.Open(nil, sealed[:NonceSize], sealed[NonceSize:], nil)
+}
+
+// SealAt encrypts the index-th block of a file written in one go, using the index as
+// the nonce. Every index may only be sealed once per data key; in return blocks can
+// neither be reordered nor dropped from the middle without OpenAt noticing.
+func (k *FileKey) SealAt(dst []byte, index uint64, plaintext []byte) []byte {
+	return k.aead.Seal(dst, indexNonce(index), plaintext, nil)
+}
+
+// OpenAt decrypts the index-th block sealed by SealAt, appending the plaintext to dst
+func (k *FileKey) OpenAt(dst []byte, index uint64, ciphertext []byte) ([]byte, error) {
+	return k.aead.Open(dst, indexNonce(index), ciphertext, nil)
+}
+
+// indexNonce returns the nonce of a block index
+func indexNonce(index uint64) []byte {
+	nonce := make([]byte, NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], index)
+	return nonce
+}
+
+// newAEAD creates AES-256-GCM with a 32 byte key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != DataKeySize {
+		return nil, utils.ErrConfig(fmt.Sprintf("encryption keys must be %d bytes, got %d", DataKeySize, len(key)))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, utils.ErrConfig("invalid encryption key: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, utils.ErrConfig("invalid encryption key: " + err.Error())
+	}
+	return aead, nil
+}
@@ -0,0 +1,161 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys returns a provider with the given key IDs, the first one current. Keys are
+// derived from their IDs so providers built separately agree on them.
+func testKeys(t *testing.T, ids ...string) *StaticKeys {
+	t.Helper()
+
+	var text string
+	for _, id := range ids {
+		key := sha256.Sum256([]byte(id))
+		text += id + ":" + hex.EncodeToString(key[:]) + "\n"
+	}
+	keys, err := ParseKeys(text)
+	require.NoError(t, err)
+	return keys
+}
+
+func TestParseKeys(t *testing.T) {
+	generated, err := GenerateKey()
+	require.NoError(t, err)
+
+	keys, err := ParseKeys("# rotated 2026-10-01\nnew:" + generated + "\n\nold:" + hex.EncodeToString(make([]byte, DataKeySize)))
+	require.NoError(t, err)
+	assert.Equal(t, "new", keys.CurrentKeyID())
+
+	// Commas separate keys given in a single environment variable
+	keys, err = ParseKeys("a:" + generated + ",b:" + generated)
+	require.NoError(t, err)
+	assert.Equal(t, "a", keys.CurrentKeyID())
+
+	for _, text := range []string{
+		"",
+		"# only a comment",
+		generated,                             // No ID
+		"a:" + generated + "\na:" + generated, // Duplicate ID
+		"a:c2hvcnQ=",                          // 5 bytes
+		"a:not a key",
+	} {
+		_, err := ParseKeys(text)
+		assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err), "keys %q", text)
+	}
+}
+
+func TestNewKeyProvider(t *testing.T) {
+	generated, err := GenerateKey()
+	require.NoError(t, err)
+
+	keys, err := NewKeyProvider("", "")
+	require.NoError(t, err)
+	assert.Nil(t, keys)
+
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("file:"+generated+"\n"), 0600))
+	keys, err = NewKeyProvider(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "file", keys.(*StaticKeys).CurrentKeyID())
+
+	t.Setenv("SCINTIRETE_TEST_KEYS", "env:"+generated)
+	keys, err = NewKeyProvider("", "SCINTIRETE_TEST_KEYS")
+	require.NoError(t, err)
+	assert.Equal(t, "env", keys.(*StaticKeys).CurrentKeyID())
+
+	_, err = NewKeyProvider(filepath.Join(t.TempDir(), "missing"), "")
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	keys, err = NewKeyProvider("", "SCINTIRETE_TEST_UNSET_KEYS")
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	assert.Nil(t, keys)
+}
+
+func TestFileKey(t *testing.T) {
+	ctx := context.Background()
+	keys := testKeys(t, "k1")
+
+	key, err := NewFileKey(ctx, keys)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", key.Envelope().KeyID)
+
+	sealed := key.Seal([]byte("payload"))
+	assert.Len(t, sealed, len("payload")+Overhead)
+	assert.NotEqual(t, sealed, key.Seal([]byte("payload")), "nonces must be random")
+
+	// The envelope survives a round trip through a file
+	envelope, err := ReadEnvelope(bytes.NewReader(key.Envelope().Marshal()))
+	require.NoError(t, err)
+	opened, err := OpenFileKey(ctx, keys, envelope)
+	require.NoError(t, err)
+	plaintext, err := opened.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), plaintext)
+
+	// Blocks only open at their index
+	block := opened.SealAt(nil, 7, []byte("block"))
+	plaintext, err = key.OpenAt(nil, 7, block)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("block"), plaintext)
+	_, err = key.OpenAt(nil, 8, block)
+	assert.Error(t, err)
+
+	// Altered data fails authentication
+	sealed[len(sealed)-1] ^= 1
+	_, err = key.Open(sealed)
+	assert.Error(t, err)
+	_, err = key.Open(sealed[:Overhead-1])
+	assert.Error(t, err)
+
+	// A cut off envelope is reported as such
+	marshaled := key.Envelope().Marshal()
+	_, err = ReadEnvelope(bytes.NewReader(marshaled[:len(marshaled)-1]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestFileKey_Rotation(t *testing.T) {
+	ctx := context.Background()
+
+	before, err := NewFileKey(ctx, testKeys(t, "k1"))
+	require.NoError(t, err)
+	sealed := before.Seal([]byte("payload"))
+
+	// After the rotation new files use the new key, old files still open
+	rotated := testKeys(t, "k2", "k1")
+	after, err := NewFileKey(ctx, rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", after.Envelope().KeyID)
+
+	opened, err := OpenFileKey(ctx, rotated, before.Envelope())
+	require.NoError(t, err)
+	plaintext, err := opened.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), plaintext)
+
+	// Without the old key, or with another key under its ID, the file can't be opened
+	_, err = OpenFileKey(ctx, testKeys(t, "k2"), before.Envelope())
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	wrong, err := ParseKeys("k1:" + hex.EncodeToString(bytes.Repeat([]byte("x"), DataKeySize)))
+	require.NoError(t, err)
+	_, err = OpenFileKey(ctx, wrong, before.Envelope())
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	_, err = OpenFileKey(ctx, nil, before.Envelope())
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+
+	// A wrapped key can't be moved to another key ID
+	moved := before.Envelope()
+	moved.KeyID = "k2"
+	_, err = OpenFileKey(ctx, rotated, moved)
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package encryption
+
+import (
+	"bufio"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// StaticKeys is a KeyProvider holding its master keys in memory, such as keys read from
+// a key file or an environment variable. The first key is the current one; the others
+// only unwrap data keys of files written before a rotation.
+type StaticKeys struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeys parses master keys given as "id:key" entries separated by newlines or commas,
+// the first of which is the current key. Keys are 32 bytes encoded in base64 or hex.
+// Blank lines and lines starting with '#' are ignored.
+//
+// To rotate the master key, put the new key first and keep the old ones until every file
+// written with them has been rewritten.
+func ParseKeys(text string) (*StaticKeys, error) {
+	sk := &StaticKeys{keys: make(map[string]cipher.AEAD)}
+
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(text, ",", "\n")))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(line, ":")
+		id, encoded = strings.TrimSpace(id), strings.TrimSpace(encoded)
+		if !ok || id == "" || encoded == "" {
+			return nil, utils.ErrConfig("encryption keys must be given as 'id:key'")
+		}
+		if len(id) > 255 {
+			return nil, utils.ErrConfig(fmt.Sprintf("encryption key ID '%s...' is too long", id[:16]))
+		}
+		if _, exists := sk.keys[id]; exists {
+			return nil, utils.ErrConfig(fmt.Sprintf("duplicate encryption key ID '%s'", id))
+		}
+
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, utils.ErrConfig(fmt.Sprintf("invalid encryption key '%s': %v", id, err))
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		sk.keys[id] = aead
+		if sk.current == "" {
+			sk.current = id
+		}
+	}
+
+	if sk.current == "" {
+		return nil, utils.ErrConfig("no encryption key given")
+	}
+	return sk, nil
+}
+
+// LoadKeyFile reads master keys from a file in the format of ParseKeys
+func LoadKeyFile(path string) (*StaticKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, utils.ErrConfig(fmt.Sprintf("failed to read encryption key file '%s': %v", path, err))
+	}
+	return ParseKeys(string(data))
+}
+
+// NewKeyProvider loads master keys from a key file or, if keyFile is empty, from the
+// environment variable keyEnv. It returns nil if neither is set, which leaves files
+// unencrypted.
+func NewKeyProvider(keyFile, keyEnv string) (KeyProvider, error) {
+	var keys *StaticKeys
+	var err error
+	switch {
+	case keyFile != "":
+		keys, err = LoadKeyFile(keyFile)
+	case keyEnv != "":
+		text, ok := os.LookupEnv(keyEnv)
+		if !ok || strings.TrimSpace(text) == "" {
+			return nil, utils.ErrConfig(fmt.Sprintf("encryption key environment variable '%s' is not set", keyEnv))
+		}
+		keys, err = ParseKeys(text)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CurrentKeyID returns the ID of the key new data keys are wrapped with
+func (sk *StaticKeys) CurrentKeyID() string {
+	return sk.current
+}
+
+// WrapKey encrypts a data key with the current master key
+func (sk *StaticKeys) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	aead := sk.keys[sk.current]
+
+	wrapped := make([]byte, NonceSize, Overhead+len(dataKey))
+	if _, err := rand.Read(wrapped); err != nil {
+		return "", nil, err
+	}
+	// The key ID is authenticated so that a wrapped key cannot be moved to another ID
+	return sk.current, aead.Seal(wrapped, wrapped, dataKey, []byte(sk.current)), nil
+}
+
+// UnwrapKey decrypts a data key wrapped with the master key keyID
+func (sk *StaticKeys) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := sk.keys[keyID]
+	if !ok {
+		return nil, utils.ErrConfig(fmt.Sprintf("encryption key '%s' is not configured", keyID))
+	}
+	if len(wrapped) < Overhead {
+		return nil, utils.ErrCorruptedData(fmt.Sprintf("wrapped data key of encryption key '%s' is too short", keyID))
+	}
+
+	dataKey, err := aead.Open(nil, wrapped[:NonceSize], wrapped[NonceSize:], []byte(keyID))
+	if err != nil {
+		return nil, utils.ErrConfig(fmt.Sprintf("failed to unwrap data key with encryption key '%s': wrong key or damaged file", keyID))
+	}
+	return dataKey, nil
+}
+
+// GenerateKey returns a random master key encoded in base64
+func GenerateKey() (string, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// decodeKey decodes a 32 byte key given in hex or base64
+func decodeKey(encoded string) ([]byte, error) {
+	if len(encoded) == hex.EncodedLen(DataKeySize) {
+		if key, err := hex.DecodeString(encoded); err == nil {
+			return key, nil
+		}
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if key, err = base64.RawStdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("not base64 or hex")
+		}
+	}
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("keys must be %d bytes, got %d", DataKeySize, len(key))
+	}
+	return key, nil
+}
+
+var _ KeyProvider = (*StaticKeys)(nil)
@@ -3,6 +3,8 @@ package persistence
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"time"
@@ -10,6 +12,8 @@ import (
 	"github.com/scintirete/scintirete/internal/core"
 	"github.com/scintirete/scintirete/internal/observability/logger"
 	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/internal/persistence/backup"
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
 	"github.com/scintirete/scintirete/internal/persistence/rdb"
 	"github.com/scintirete/scintirete/internal/utils"
 	"github.com/scintirete/scintirete/pkg/types"
@@ -17,13 +21,18 @@ import (
 
 // Manager implements the unified persistence interface using FlatBuffers
 type Manager struct {
-	mu sync.RWMutex
+	mu     sync.RWMutex
+	saveMu sync.Mutex // Serializes snapshots and AOF rewrites, which both replace the AOF
 
 	// FlatBuffers-based persistence components
-	aofLogger  *aof.AOFLogger
+	aofLogger  *aof.MultiPartLogger
 	rdbManager *rdb.RDBManager
+	rdbPath    string
+	backups    *rdb.BackupManager
 	cmdBuilder *aof.CommandBuilder
 	cmdApplier *CommandApplier
+	feed       CommandFeed      // Receives logged commands, nil if none
+	onSnapshot SnapshotListener // Called after each RDB snapshot, nil if none
 	logger     core.Logger
 
 	// Configuration
@@ -48,13 +57,33 @@ type Manager struct {
 type Config struct {
 	DataDir         string
 	RDBFilename     string
-	AOFFilename     string
+	AOFFilename     string // Prefix of the files in AOFDirname
+	AOFDirname      string // Directory within DataDir holding the multi-part AOF; empty means appendonlydir
+	AOFArchiveDir   string // Directory replaced AOF files are kept in for point-in-time recovery, relative to DataDir; empty disables archiving
 	AOFSyncStrategy string
+	RDBCompression  string // none, zstd or lz4; empty means none
+
+	// Backups taken on request, kept in BackupDir relative to DataDir; empty means backups
+	BackupDir       string
+	BackupRetention rdb.RetentionPolicy   // Applied after each backup; zero keeps every backup
+	BackupTargets   []backup.TargetConfig // Backups are copied to these, where the retention policy applies too
+	BackupInterval  time.Duration         // How often to take a backup and copy it to the targets; 0 disables
 
 	// Background task intervals
 	RDBInterval    time.Duration // How often to create RDB snapshots
 	AOFRewriteSize int64         // Rewrite AOF when it exceeds this size
 
+	// AOFRepair skips corrupt AOF records during recovery instead of refusing to start
+	AOFRepair bool
+
+	// RestoreTo makes Recover rebuild the state at an earlier point from the archived
+	// AOF files instead of the latest state, and makes that state the current one
+	RestoreTo *aof.RestoreTarget
+
+	// Optional: KeyProvider encrypts the AOF, RDB snapshots and backups written from now
+	// on and decrypts existing ones; nil writes them unencrypted
+	KeyProvider encryption.KeyProvider
+
 	// Optional: Logger for persistence component
 	Logger core.Logger
 }
@@ -78,21 +107,62 @@ func NewManager(config Config) (*Manager, error) {
 
 // NewManagerWithEngine creates a new persistence manager with database engine support
 func NewManagerWithEngine(config Config, dbEngine DatabaseEngine) (*Manager, error) {
-	aofPath := config.DataDir + "/" + config.AOFFilename
-	rdbPath := config.DataDir + "/" + config.RDBFilename
-	syncStrategy := aof.SyncStrategy(config.AOFSyncStrategy)
+	if config.AOFDirname == "" {
+		config.AOFDirname = DefaultAOFDirname
+	}
+	if config.BackupDir == "" {
+		config.BackupDir = DefaultBackupDir
+	}
+	backupDir := config.BackupDir
+	if !filepath.IsAbs(backupDir) {
+		backupDir = filepath.Join(config.DataDir, backupDir)
+	}
+	rdbPath := filepath.Join(config.DataDir, config.RDBFilename)
+	archiveDir := config.AOFArchiveDir
+	if archiveDir != "" && !filepath.IsAbs(archiveDir) {
+		archiveDir = filepath.Join(config.DataDir, archiveDir)
+	}
 
-	// Create AOF logger
-	aofLogger, err := aof.NewAOFLogger(aofPath, syncStrategy)
+	// Open the multi-part AOF, moving a single-file AOF of an older version into it
+	aofLogger, err := aof.OpenMultiPart(aof.MultiPartOptions{
+		Dir:          filepath.Join(config.DataDir, config.AOFDirname),
+		Name:         config.AOFFilename,
+		SyncStrategy: aof.SyncStrategy(config.AOFSyncStrategy),
+		LegacyAOF:    filepath.Join(config.DataDir, config.AOFFilename),
+		LegacyRDB:    rdbPath,
+		ArchiveDir:   archiveDir,
+		Keys:         config.KeyProvider,
+	})
 	if err != nil {
 		return nil, utils.ErrPersistenceFailedWithCause("failed to create AOF logger", err)
 	}
 
 	// Create RDB manager
-	rdbManager, err := rdb.NewRDBManager(rdbPath)
+	compression, err := rdb.ParseCompression(config.RDBCompression)
 	if err != nil {
+		return nil, utils.ErrConfig(err.Error())
+	}
+	rdbManager, err := rdb.NewRDBManagerWithCompression(rdbPath, compression)
+	if err != nil {
+		aofLogger.Close()
 		return nil, utils.ErrPersistenceFailedWithCause("failed to create RDB manager", err)
 	}
+	rdbManager.SetEncryption(config.KeyProvider)
+	backups, err := rdb.NewBackupManager(rdbManager, backupDir)
+	if err != nil {
+		aofLogger.Close()
+		return nil, err
+	}
+	targets := make([]backup.Target, 0, len(config.BackupTargets))
+	for _, targetConfig := range config.BackupTargets {
+		target, err := backup.NewTarget(targetConfig)
+		if err != nil {
+			aofLogger.Close()
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	backups.SetTargets(targets)
 
 	// Set default intervals if not specified
 	if config.RDBInterval == 0 {
@@ -120,6 +190,8 @@ func NewManagerWithEngine(config Config, dbEngine DatabaseEngine) (*Manager, err
 	manager := &Manager{
 		aofLogger:  aofLogger,
 		rdbManager: rdbManager,
+		rdbPath:    rdbPath,
+		backups:    backups,
 		cmdBuilder: aof.NewCommandBuilder(),
 		config:     config,
 		stopTasks:  make(chan struct{}),
@@ -147,9 +219,31 @@ func (m *Manager) WriteAOF(ctx context.Context, command types.AOFCommand) error
 	m.lastAOFCommandTime = time.Now()
 	m.aofCommandsSinceRDB++
 	m.isDirty = true
+	feed := m.feed
 	m.mu.Unlock()
 
-	return m.aofLogger.WriteCommand(ctx, command)
+	// The feed gets the timestamp the AOF records
+	if command.Timestamp.IsZero() {
+		command.Timestamp = time.Now()
+	}
+	if err := m.aofLogger.WriteCommand(ctx, command); err != nil {
+		return err
+	}
+	if feed != nil {
+		feed.Append(command)
+	}
+	return nil
+}
+
+// SnapshotListener is called with the path of the RDB file once a snapshot was saved as
+// the base of the AOF. It must not block or call back into the manager.
+type SnapshotListener func(path string)
+
+// SetSnapshotListener sets the listener told about saved snapshots, nil for none
+func (m *Manager) SetSnapshotListener(listener SnapshotListener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onSnapshot = listener
 }
 
 // LoadFromRDB loads data from the latest RDB snapshot
@@ -182,13 +276,59 @@ func (m *Manager) Recover(ctx context.Context) error {
 		})
 	}
 
-	// Step 1: Load RDB snapshot if it exists
+	// Recover the latest state from the current files, or an earlier one from the archive
+	base := m.aofLogger.Base()
+	var basePath string
+	if base != nil {
+		basePath = m.aofLogger.Path(*base)
+	}
+	replay := m.aofLogger.Replay
+	if target := m.config.RestoreTo; target != nil {
+		if m.cmdApplier == nil {
+			return utils.ErrRecoveryFailed("restoring to an earlier point requires a database engine")
+		}
+		plan, err := m.aofLogger.PlanRestore(*target)
+		if err != nil {
+			return err
+		}
+		base, basePath, replay = plan.Base, plan.BasePath, plan.Replay
+		m.logger.Info(ctx, "Restoring state at an earlier point", map[string]interface{}{
+			"component":      "persistence_recovery",
+			"restore_to":     target.String(),
+			"aof_base":       basePath,
+			"aof_increments": len(plan.Incrs),
+		})
+	}
+
+	// Step 1: Load the RDB base of the AOF if it has one
 	m.logger.Debug(ctx, "Attempting to load RDB snapshot", map[string]interface{}{
 		"component": "persistence_recovery",
 		"format":    "FlatBuffers",
 	})
 
-	snapshot, err := m.rdbManager.Load(ctx)
+	// Collections are applied while the snapshot is read, so it is never in memory as a whole
+	var snapshot *rdb.RDBSnapshot
+	load := func(visitor rdb.SnapshotVisitor) error {
+		if base == nil || base.Format() != aof.BaseRDB {
+			return nil
+		}
+
+		file, err := os.Open(basePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		snapshot, err = rdb.ReadStreamWithKeys(file, m.config.KeyProvider, visitor)
+		return err
+	}
+
+	var err error
+	if m.cmdApplier != nil {
+		err = m.cmdApplier.ApplySnapshotStream(ctx, load)
+	} else {
+		err = load(discardSnapshot{})
+	}
 	if err != nil {
 		m.logger.Error(ctx, "Failed to load RDB snapshot", err, map[string]interface{}{
 			"component": "persistence_recovery",
@@ -197,22 +337,11 @@ func (m *Manager) Recover(ctx context.Context) error {
 	}
 
 	if snapshot != nil {
-		m.logger.Info(ctx, "RDB snapshot found, applying to database", map[string]interface{}{
-			"component":      "persistence_recovery",
-			"snapshot_time":  snapshot.Timestamp,
-			"database_count": len(snapshot.Databases),
-		})
-
-		// Apply RDB snapshot to database engine if command applier is available
 		if m.cmdApplier != nil {
-			if err := m.cmdApplier.ApplySnapshot(ctx, snapshot); err != nil {
-				m.logger.Error(ctx, "Failed to apply RDB snapshot to database engine", err, map[string]interface{}{
-					"component": "persistence_recovery",
-				})
-				return utils.ErrRecoveryFailed("failed to apply RDB snapshot: " + err.Error())
-			}
 			m.logger.Info(ctx, "RDB snapshot successfully applied to database engine", map[string]interface{}{
-				"component": "persistence_recovery",
+				"component":      "persistence_recovery",
+				"snapshot_time":  snapshot.Timestamp,
+				"database_count": snapshot.Metadata["total_databases"],
 			})
 		} else {
 			m.logger.Warn(ctx, "RDB snapshot loaded but cannot be applied: database engine not connected", map[string]interface{}{
@@ -220,8 +349,6 @@ func (m *Manager) Recover(ctx context.Context) error {
 			})
 		}
 
-		// 立即释放RDB快照数据 - 已经应用到数据库引擎中
-		snapshot = nil
 		// 主动触发GC释放RDB反序列化产生的大量临时对象
 		runtime.GC()
 		m.logger.Debug(ctx, "RDB snapshot data released and GC triggered", map[string]interface{}{
@@ -239,7 +366,7 @@ func (m *Manager) Recover(ctx context.Context) error {
 		"format":    "FlatBuffers",
 	})
 
-	err = m.aofLogger.Replay(ctx, func(command types.AOFCommand) error {
+	report, err := replay(ctx, aof.ReplayOptions{Repair: m.config.AOFRepair}, func(command types.AOFCommand) error {
 		commandCount++
 
 		// Log every 1000 commands to show progress
@@ -296,6 +423,35 @@ func (m *Manager) Recover(ctx context.Context) error {
 		return utils.ErrRecoveryFailed("failed to replay AOF: " + err.Error())
 	}
 
+	if report.TruncatedBytes > 0 {
+		m.logger.Warn(ctx, "Truncated damaged AOF tail", map[string]interface{}{
+			"component":       "persistence_recovery",
+			"truncated_bytes": report.TruncatedBytes,
+			"format_version":  report.Version,
+		})
+	}
+	if report.SkippedRecords > 0 {
+		m.logger.Warn(ctx, "Skipped corrupt AOF records in repair mode", map[string]interface{}{
+			"component":       "persistence_recovery",
+			"skipped_records": report.SkippedRecords,
+			"skipped_bytes":   report.SkippedBytes,
+			"format_version":  report.Version,
+		})
+	}
+	if report.SkippedRecords > 0 || m.config.RestoreTo != nil {
+		// Snapshot the recovered state as the new base so the corrupt records are gone for
+		// good, or the restored state is the one later starts recover
+		if m.cmdApplier != nil {
+			if err := m.rdbManager.SaveStream(ctx, m.cmdApplier); err != nil {
+				return utils.ErrRecoveryFailed("failed to save RDB snapshot of the recovered state: " + err.Error())
+			}
+			if err := m.commitRDBBase(); err != nil {
+				return utils.ErrRecoveryFailed("failed to make the recovered state the AOF base: " + err.Error())
+			}
+			m.stats.LastRDBSave = time.Now()
+		}
+	}
+
 	// 最终GC触发 - 清理AOF重放过程中的所有临时对象
 	runtime.GC()
 	m.logger.Info(ctx, "Final GC triggered after data recovery", map[string]interface{}{
@@ -329,38 +485,122 @@ func (m *Manager) Recover(ctx context.Context) error {
 	return nil
 }
 
-// SaveSnapshot creates an RDB snapshot with current database state
+// SaveSnapshot creates an RDB snapshot with the given database state and makes it the
+// base of the AOF. The state must include every write logged so far, so writes have to
+// be stopped until it returns; SaveEngineSnapshot saves while writes continue.
 func (m *Manager) SaveSnapshot(ctx context.Context, databases map[string]rdb.DatabaseState) error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Create snapshot
-	snapshot := m.rdbManager.CreateSnapshot(databases)
-
 	// Save to disk
-	if err := m.rdbManager.Save(ctx, snapshot); err != nil {
+	if err := m.rdbManager.Save(ctx, m.rdbManager.CreateSnapshot(databases)); err != nil {
 		return err
 	}
 
 	// Since RDB snapshot now contains all current data,
-	// truncate the AOF file to avoid replay conflicts
-	if err := m.aofLogger.Truncate(); err != nil {
-		m.logger.Error(ctx, "Failed to truncate AOF after RDB save", err, map[string]interface{}{
+	// it replaces the AOF files logged so far
+	if err := m.commitRDBBase(); err != nil {
+		m.logger.Error(ctx, "Failed to make RDB snapshot the AOF base", err, map[string]interface{}{
 			"component": "persistence_rdb_save",
 			"format":    "FlatBuffers",
 		})
-		return utils.ErrPersistenceFailedWithCause("failed to truncate AOF after RDB save", err)
+		return utils.ErrPersistenceFailedWithCause("failed to make RDB snapshot the AOF base", err)
 	}
 
 	m.stats.LastRDBSave = time.Now()
-	m.logger.Info(ctx, "RDB snapshot saved and AOF truncated successfully", map[string]interface{}{
-		"component":      "persistence_rdb_save",
-		"database_count": len(databases),
-		"format":         "FlatBuffers",
+	m.logger.Info(ctx, "RDB snapshot saved as AOF base successfully", map[string]interface{}{
+		"component": "persistence_rdb_save",
+		"format":    "FlatBuffers",
+	})
+	if m.onSnapshot != nil {
+		m.onSnapshot(m.rdbPath)
+	}
+	return nil
+}
+
+// SaveEngineSnapshot creates an RDB snapshot of the database engine at a single point in
+// time while requests keep being served. The AOF moves on to a new increment at that
+// point and the snapshot becomes the base for it, replacing the older files; writes
+// logged during the save stay in the new increment. This is also how the AOF is rewritten.
+func (m *Manager) SaveEngineSnapshot(ctx context.Context) error {
+	m.mu.RLock()
+	applier := m.cmdApplier
+	m.mu.RUnlock()
+	if applier == nil {
+		return utils.ErrPersistenceFailed("no database engine configured")
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	// No write is between the engine and the AOF while mark runs
+	var seq int64
+	var commandsAtCut int64
+	cut, err := applier.CutSnapshot(ctx, func() error {
+		var err error
+		seq, err = m.aofLogger.Rotate()
+		m.mu.RLock()
+		commandsAtCut = m.aofCommandsSinceRDB
+		m.mu.RUnlock()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer cut.Release()
+
+	// Save to disk
+	if err := m.rdbManager.SaveStream(ctx, cut); err != nil {
+		return err
+	}
+
+	// The snapshot contains everything logged before the increment seq
+	if err := m.aofLogger.CommitBase(seq, aof.BaseRDB, m.linkRDB); err != nil {
+		m.logger.Error(ctx, "Failed to make RDB snapshot the AOF base", err, map[string]interface{}{
+			"component":     "persistence_rdb_save",
+			"aof_increment": seq,
+		})
+		return utils.ErrPersistenceFailedWithCause("failed to make RDB snapshot the AOF base", err)
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	m.stats.LastRDBSave = now
+	m.lastRDBTime = now
+	m.aofCommandsSinceRDB -= commandsAtCut
+	m.isDirty = m.aofCommandsSinceRDB > 0
+	onSnapshot := m.onSnapshot
+	m.mu.Unlock()
+
+	m.logger.Info(ctx, "RDB snapshot saved as AOF base successfully", map[string]interface{}{
+		"component":     "persistence_rdb_save",
+		"aof_increment": seq,
+		"aof_commands":  commandsAtCut,
 	})
+	if onSnapshot != nil {
+		onSnapshot(m.rdbPath)
+	}
 	return nil
 }
 
+// commitRDBBase makes the RDB file the base of the AOF in place of everything logged so
+// far. Writes have to be stopped.
+func (m *Manager) commitRDBBase() error {
+	seq, err := m.aofLogger.Rotate()
+	if err != nil {
+		return err
+	}
+	return m.aofLogger.CommitBase(seq, aof.BaseRDB, m.linkRDB)
+}
+
+// linkRDB places the RDB file at path. Saving replaces the RDB file rather than
+// overwriting it, so a hard link keeps the content.
+func (m *Manager) linkRDB(path string) error {
+	return aof.LinkFile(m.rdbPath, path)
+}
+
 // StartBackgroundTasks starts periodic snapshot and AOF rewrite tasks
 func (m *Manager) StartBackgroundTasks(ctx context.Context) error {
 	m.taskWG.Add(2)
@@ -371,6 +611,12 @@ func (m *Manager) StartBackgroundTasks(ctx context.Context) error {
 	// Start AOF rewrite task
 	go m.runAOFRewriteTask(ctx)
 
+	// Start scheduled backup task
+	if m.config.BackupInterval > 0 {
+		m.taskWG.Add(1)
+		go m.runBackupTask(ctx)
+	}
+
 	return nil
 }
 
@@ -438,12 +684,16 @@ func (m *Manager) GetStats() Stats {
 	return stats
 }
 
-// RewriteAOF triggers an AOF rewrite
+// RewriteAOF replaces the AOF with a base holding the given commands. They must include
+// every write logged so far, so writes have to be stopped until it returns; the
+// background rewrite uses SaveEngineSnapshot instead.
 func (m *Manager) RewriteAOF(ctx context.Context, commands []types.AOFCommand) error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if err := m.aofLogger.Rewrite(ctx, commands); err != nil {
+	if err := m.aofLogger.RewriteBase(ctx, commands); err != nil {
 		return err
 	}
 
@@ -451,8 +701,10 @@ func (m *Manager) RewriteAOF(ctx context.Context, commands []types.AOFCommand) e
 	return nil
 }
 
-// TruncateAOF clears the AOF file (removes all content)
+// TruncateAOF drops the commands logged since the AOF base
 func (m *Manager) TruncateAOF(ctx context.Context) error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -490,6 +742,24 @@ func (m *Manager) LogDropCollection(ctx context.Context, dbName, collName string
 	return m.WriteAOF(ctx, command)
 }
 
+// LogRenameCollection logs a collection rename command
+func (m *Manager) LogRenameCollection(ctx context.Context, dbName, collName, newName string) error {
+	command := m.cmdBuilder.RenameCollection(dbName, collName, newName)
+	return m.WriteAOF(ctx, command)
+}
+
+// LogCloneCollection logs a collection clone command
+func (m *Manager) LogCloneCollection(ctx context.Context, dbName, collName, targetName string) error {
+	command := m.cmdBuilder.CloneCollection(dbName, collName, targetName)
+	return m.WriteAOF(ctx, command)
+}
+
+// LogCopyCollection logs a cross-database collection copy command
+func (m *Manager) LogCopyCollection(ctx context.Context, dbName, collName, targetDB, targetName string) error {
+	command := m.cmdBuilder.CopyCollection(dbName, collName, targetDB, targetName)
+	return m.WriteAOF(ctx, command)
+}
+
 // LogInsertVectors logs a vector insertion command
 func (m *Manager) LogInsertVectors(ctx context.Context, dbName, collName string, vectors []types.Vector) error {
 	command := m.cmdBuilder.InsertVectors(dbName, collName, vectors)
@@ -502,6 +772,24 @@ func (m *Manager) LogDeleteVectors(ctx context.Context, dbName, collName string,
 	return m.WriteAOF(ctx, command)
 }
 
+// LogDeleteVectorsFromPartitions logs a vector deletion command restricted to the given partitions
+func (m *Manager) LogDeleteVectorsFromPartitions(ctx context.Context, dbName, collName string, ids []string, partitions []string) error {
+	command := m.cmdBuilder.DeleteVectorsFromPartitions(dbName, collName, ids, partitions)
+	return m.WriteAOF(ctx, command)
+}
+
+// LogCreatePartition logs a partition creation command
+func (m *Manager) LogCreatePartition(ctx context.Context, dbName, collName, partition string) error {
+	command := m.cmdBuilder.CreatePartition(dbName, collName, partition)
+	return m.WriteAOF(ctx, command)
+}
+
+// LogDropPartition logs a partition deletion command
+func (m *Manager) LogDropPartition(ctx context.Context, dbName, collName, partition string) error {
+	command := m.cmdBuilder.DropPartition(dbName, collName, partition)
+	return m.WriteAOF(ctx, command)
+}
+
 // Background task implementations
 
 // runRDBSnapshotTask runs periodic RDB snapshots
@@ -522,24 +810,11 @@ func (m *Manager) runRDBSnapshotTask(ctx context.Context) {
 			m.mu.RUnlock()
 
 			if shouldCreateSnapshot {
-				databases, err := m.cmdApplier.GetDatabaseState(ctx)
-				if err != nil {
-					// Log error but continue running
-					m.logger.Error(ctx, "failed to get database state for RDB snapshot", err, nil)
-					continue
-				}
-
-				if err := m.SaveSnapshot(ctx, databases); err != nil {
+				// 快照成功后计数器只减去快照包含的命令
+				if err := m.SaveEngineSnapshot(ctx); err != nil {
 					// Log error but continue running
 					m.logger.Error(ctx, "failed to save RDB snapshot", err, nil)
 				} else {
-					// 更新状态：快照成功后重置计数器
-					m.mu.Lock()
-					m.aofCommandsSinceRDB = 0
-					m.lastRDBTime = time.Now()
-					m.isDirty = false
-					m.mu.Unlock()
-
 					m.logger.Info(ctx, "智能RDB快照创建成功", map[string]interface{}{
 						"reason": "数据变化达到阈值",
 					})
@@ -586,27 +861,24 @@ func (m *Manager) runAOFRewriteTask(ctx context.Context) {
 			shouldRewrite := currentFileSize > m.config.AOFRewriteSize &&
 				(lastKnownFileSize == 0 || currentFileSize > lastKnownFileSize*3/2)
 
-			if shouldRewrite && m.cmdApplier != nil {
+			if shouldRewrite && m.HasDatabaseEngine() {
 				m.logger.Info(ctx, "开始智能AOF重写", map[string]interface{}{
 					"current_size_mb":    currentFileSize / 1024 / 1024,
 					"threshold_mb":       m.config.AOFRewriteSize / 1024 / 1024,
 					"commands_since_rdb": m.aofCommandsSinceRDB,
 				})
 
-				commands, err := m.cmdApplier.GetOptimizedCommands(ctx)
-				if err != nil {
-					// Log error but continue running
-					m.logger.Error(ctx, "failed to get optimized commands for AOF rewrite", err, nil)
-					continue
-				}
-
-				if err := m.RewriteAOF(ctx, commands); err != nil {
+				// 以引擎快照作为新的base文件，重写期间写入不会被阻塞
+				if err := m.SaveEngineSnapshot(ctx); err != nil {
 					// Log error but continue running
 					m.logger.Error(ctx, "failed to rewrite AOF", err, nil)
 				} else {
 					lastKnownFileSize = currentFileSize
+					m.mu.Lock()
+					m.stats.LastAOFRewrite = time.Now()
+					m.mu.Unlock()
 					m.logger.Info(ctx, "智能AOF重写完成", map[string]interface{}{
-						"optimized_commands": len(commands),
+						"base_file": m.aofLogger.Base().Name,
 					})
 				}
 			}
@@ -619,14 +891,58 @@ func (m *Manager) runAOFRewriteTask(ctx context.Context) {
 	}
 }
 
+// runBackupTask takes a backup on every interval and copies it to the backup targets,
+// along with the backups earlier uploads failed for
+func (m *Manager) runBackupTask(ctx context.Context) {
+	defer m.taskWG.Done()
+
+	ticker := time.NewTicker(m.config.BackupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !m.HasDatabaseEngine() {
+				continue
+			}
+			if _, err := m.CreateBackup(ctx); err != nil {
+				// Log error but continue running
+				m.logger.Error(ctx, "failed to create scheduled backup", err, map[string]interface{}{
+					"component": "persistence_backup",
+				})
+				continue
+			}
+			for target, err := range m.SyncBackups(ctx) {
+				m.logger.Error(ctx, "failed to copy backups to target", err, map[string]interface{}{
+					"component": "persistence_backup",
+					"target":    target,
+				})
+			}
+
+		case <-m.stopTasks:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // Utility functions
 
+// DefaultAOFDirname is the directory within the data directory holding the multi-part AOF
+const DefaultAOFDirname = "appendonlydir"
+
+// DefaultBackupDir is the directory within the data directory backups are kept in
+const DefaultBackupDir = "backups"
+
 // DefaultConfig returns a default persistence configuration
 func DefaultConfig(dataDir string) Config {
 	return Config{
 		DataDir:         dataDir,
 		RDBFilename:     "vector.rdb",
 		AOFFilename:     "appendonly.aof",
+		AOFDirname:      DefaultAOFDirname,
+		BackupDir:       DefaultBackupDir,
 		AOFSyncStrategy: "everysec",
 		RDBInterval:     5 * time.Minute,
 		AOFRewriteSize:  5 * 1024 * 1024, // 5MB
@@ -654,6 +970,10 @@ func ValidateConfig(config Config) error {
 		return utils.ErrConfig("invalid AOF sync strategy: " + config.AOFSyncStrategy)
 	}
 
+	if _, err := rdb.ParseCompression(config.RDBCompression); err != nil {
+		return utils.ErrConfig(err.Error())
+	}
+
 	if config.RDBInterval <= 0 {
 		return utils.ErrConfig("RDB interval must be positive")
 	}
@@ -4,10 +4,18 @@ package persistence
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/scintirete/scintirete/internal/core/database"
 	"github.com/scintirete/scintirete/internal/observability/logger"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/internal/persistence/backup"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
 	"github.com/scintirete/scintirete/pkg/types"
 )
 
@@ -545,3 +553,564 @@ func TestRDBSnapshotWithAOFTruncation(t *testing.T) {
 		}
 	}
 }
+
+// TestAOFRepairRecovery tests that repair mode skips corrupt AOF records and leaves clean files behind
+func TestAOFRepairRecovery(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	testLogger, err := logger.NewFromConfigString("debug", "text")
+	if err != nil {
+		t.Fatalf("Failed to create test logger: %v", err)
+	}
+
+	config := Config{
+		DataDir:         tempDir,
+		RDBFilename:     "test.rdb",
+		AOFFilename:     "test.aof",
+		AOFSyncStrategy: "always",
+		Logger:          testLogger,
+	}
+
+	writer, err := NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create persistence manager: %v", err)
+	}
+	var ends []int64
+	for _, name := range []string{"db1", "db2", "db3"} {
+		if err := writer.LogCreateDatabase(ctx, name); err != nil {
+			t.Fatalf("Failed to log create database: %v", err)
+		}
+		ends = append(ends, writer.GetStats().AOFStats.FileSize)
+	}
+	writer.Stop(ctx)
+
+	// Damage the record of db2
+	aofPath := filepath.Join(tempDir, DefaultAOFDirname, "test.aof.1.incr.aof")
+	data, err := os.ReadFile(aofPath)
+	if err != nil {
+		t.Fatalf("Failed to read AOF: %v", err)
+	}
+	data[ends[1]-1] ^= 0xff
+	if err := os.WriteFile(aofPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write AOF: %v", err)
+	}
+
+	recoverDatabases := func(config Config) ([]string, error) {
+		engine := database.NewEngine()
+		manager, err := NewManagerWithEngine(config, engine)
+		if err != nil {
+			t.Fatalf("Failed to create persistence manager: %v", err)
+		}
+		defer manager.Stop(ctx)
+
+		if err := manager.Recover(ctx); err != nil {
+			return nil, err
+		}
+		databases, _ := engine.ListDatabases(ctx)
+		sort.Strings(databases)
+		return databases, nil
+	}
+
+	if _, err := recoverDatabases(config); err == nil {
+		t.Fatal("Expected recovery to fail on a corrupt AOF record")
+	}
+
+	repairConfig := config
+	repairConfig.AOFRepair = true
+	databases, err := recoverDatabases(repairConfig)
+	if err != nil {
+		t.Fatalf("Failed to recover in repair mode: %v", err)
+	}
+	if fmt.Sprint(databases) != "[db1 db3]" {
+		t.Errorf("Expected db1 and db3 after repair, got %v", databases)
+	}
+
+	// The repaired state was snapshotted, so a normal start works again
+	databases, err = recoverDatabases(config)
+	if err != nil {
+		t.Fatalf("Failed to recover after repair: %v", err)
+	}
+	if fmt.Sprint(databases) != "[db1 db3]" {
+		t.Errorf("Expected db1 and db3 after restart, got %v", databases)
+	}
+}
+
+// writingEngine makes writes through the persistence manager while a snapshot of the engine is saved
+type writingEngine struct {
+	*database.Engine
+	write func()
+}
+
+func (e *writingEngine) CutSnapshot(ctx context.Context, mark func() error) (rdb.SnapshotCut, error) {
+	cut, err := e.Engine.CutSnapshot(ctx, mark)
+	if err != nil {
+		return nil, err
+	}
+	return &writingCut{SnapshotCut: cut, write: e.write}, nil
+}
+
+type writingCut struct {
+	rdb.SnapshotCut
+	write func()
+}
+
+func (c *writingCut) StreamDatabaseState(ctx context.Context, visitor rdb.StateVisitor) error {
+	c.write()
+	return c.SnapshotCut.StreamDatabaseState(ctx, visitor)
+}
+
+// TestSaveEngineSnapshotWithConcurrentWrites tests that writes made while a snapshot is saved
+// are neither lost nor replayed on top of the snapshot twice
+func TestSaveEngineSnapshotWithConcurrentWrites(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	testLogger, err := logger.NewFromConfigString("error", "text")
+	if err != nil {
+		t.Fatalf("Failed to create test logger: %v", err)
+	}
+	config := Config{
+		DataDir:         tempDir,
+		RDBFilename:     "test.rdb",
+		AOFFilename:     "test.aof",
+		AOFSyncStrategy: "always",
+		Logger:          testLogger,
+	}
+
+	engine1 := &writingEngine{Engine: database.NewEngine()}
+	manager1, err := NewManagerWithEngine(config, engine1)
+	if err != nil {
+		t.Fatalf("Failed to create persistence manager: %v", err)
+	}
+
+	collConfig := types.CollectionConfig{
+		Name:       "vectors",
+		Metric:     types.DistanceMetricL2,
+		HNSWParams: types.HNSWParams{M: 16, EfConstruction: 200, EfSearch: 50},
+	}
+	insert := func(collName string, vectors []types.Vector) {
+		db, _ := engine1.GetDatabase(ctx, "db")
+		collection, err := db.GetCollection(ctx, collName)
+		if err != nil {
+			t.Fatalf("Failed to get collection: %v", err)
+		}
+		if err := collection.Insert(ctx, vectors); err != nil {
+			t.Fatalf("Failed to insert vectors: %v", err)
+		}
+		if err := manager1.LogInsertVectors(ctx, "db", collName, vectors); err != nil {
+			t.Fatalf("Failed to log insert: %v", err)
+		}
+	}
+
+	if err := engine1.CreateDatabase(ctx, "db"); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := manager1.LogCreateDatabase(ctx, "db"); err != nil {
+		t.Fatalf("Failed to log create database: %v", err)
+	}
+	db, _ := engine1.GetDatabase(ctx, "db")
+	if err := db.CreateCollection(ctx, collConfig); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := manager1.LogCreateCollection(ctx, "db", "vectors", collConfig); err != nil {
+		t.Fatalf("Failed to log create collection: %v", err)
+	}
+	insert("vectors", []types.Vector{{Elements: []float32{1, 2, 3}}, {Elements: []float32{4, 5, 6}}})
+
+	// Writes that land after the cut, the rename would fail if it was replayed twice
+	engine1.write = func() {
+		engine1.BeginWrite()
+		defer engine1.EndWrite()
+
+		insert("vectors", []types.Vector{{Elements: []float32{7, 8, 9}}})
+		if err := engine1.RenameCollection(ctx, "db", "vectors", "renamed"); err != nil {
+			t.Fatalf("Failed to rename collection: %v", err)
+		}
+		if err := manager1.LogRenameCollection(ctx, "db", "vectors", "renamed"); err != nil {
+			t.Fatalf("Failed to log rename: %v", err)
+		}
+	}
+
+	if err := manager1.SaveEngineSnapshot(ctx); err != nil {
+		t.Fatalf("Failed to save engine snapshot: %v", err)
+	}
+	stats := manager1.GetStats().AOFStats
+	if stats.CommandCount != 2 {
+		t.Errorf("Expected the 2 writes made during the save to stay in the AOF, got %d commands", stats.CommandCount)
+	}
+	if stats.BaseFile != "test.aof.2.base.rdb" || stats.IncrFiles != 1 {
+		t.Errorf("Expected the snapshot as base of a single increment, got base %q and %d increments", stats.BaseFile, stats.IncrFiles)
+	}
+	manager1.Stop(ctx)
+
+	engine2 := database.NewEngine()
+	manager2, err := NewManagerWithEngine(config, engine2)
+	if err != nil {
+		t.Fatalf("Failed to create second persistence manager: %v", err)
+	}
+	defer manager2.Stop(ctx)
+	if err := manager2.Recover(ctx); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+
+	db2, err := engine2.GetDatabase(ctx, "db")
+	if err != nil {
+		t.Fatalf("Failed to get database: %v", err)
+	}
+	collections, _ := db2.ListCollections(ctx)
+	if len(collections) != 1 || collections[0].Name != "renamed" || collections[0].VectorCount != 3 {
+		t.Errorf("Expected only collection renamed with 3 vectors, got %+v", collections)
+	}
+}
+
+// TestLegacyAOFMigration tests that the single AOF file and RDB snapshot of an older
+// version are moved into the AOF directory and recovered from there
+func TestLegacyAOFMigration(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	testLogger, err := logger.NewFromConfigString("error", "text")
+	if err != nil {
+		t.Fatalf("Failed to create test logger: %v", err)
+	}
+	config := Config{
+		DataDir:         tempDir,
+		RDBFilename:     "test.rdb",
+		AOFFilename:     "test.aof",
+		AOFSyncStrategy: "always",
+		Logger:          testLogger,
+	}
+
+	// Files as an older version left them
+	rdbManager, err := rdb.NewRDBManager(filepath.Join(tempDir, "test.rdb"))
+	if err != nil {
+		t.Fatalf("Failed to create RDB manager: %v", err)
+	}
+	snapshot := rdbManager.CreateSnapshot(map[string]rdb.DatabaseState{
+		"from_rdb": {Name: "from_rdb", CreatedAt: time.Now()},
+	})
+	if err := rdbManager.Save(ctx, snapshot); err != nil {
+		t.Fatalf("Failed to save RDB: %v", err)
+	}
+	legacyAOF := filepath.Join(tempDir, "test.aof")
+	aofLogger, err := aof.NewAOFLogger(legacyAOF, aof.SyncAlways)
+	if err != nil {
+		t.Fatalf("Failed to create AOF logger: %v", err)
+	}
+	if err := aofLogger.WriteCommand(ctx, aof.NewCommandBuilder().CreateDatabase("from_aof")); err != nil {
+		t.Fatalf("Failed to write AOF: %v", err)
+	}
+	aofLogger.Close()
+
+	recoverDatabases := func() []string {
+		engine := database.NewEngine()
+		manager, err := NewManagerWithEngine(config, engine)
+		if err != nil {
+			t.Fatalf("Failed to create persistence manager: %v", err)
+		}
+		defer manager.Stop(ctx)
+
+		if err := manager.Recover(ctx); err != nil {
+			t.Fatalf("Failed to recover: %v", err)
+		}
+		databases, _ := engine.ListDatabases(ctx)
+		sort.Strings(databases)
+		return databases
+	}
+
+	if databases := recoverDatabases(); fmt.Sprint(databases) != "[from_aof from_rdb]" {
+		t.Errorf("Expected databases from both legacy files, got %v", databases)
+	}
+	if _, err := os.Stat(legacyAOF); !os.IsNotExist(err) {
+		t.Errorf("Expected the legacy AOF to be moved, got %v", err)
+	}
+	manifest, err := aof.ReadManifest(filepath.Join(tempDir, DefaultAOFDirname, "test.aof.manifest"))
+	if err != nil || manifest == nil || manifest.Base == nil {
+		t.Fatalf("Expected a manifest with the RDB as base, got %+v (%v)", manifest, err)
+	}
+
+	// The RDB in the data directory is no longer read once it is the base
+	if err := rdbManager.Remove(); err != nil {
+		t.Fatalf("Failed to remove RDB: %v", err)
+	}
+	if databases := recoverDatabases(); fmt.Sprint(databases) != "[from_aof from_rdb]" {
+		t.Errorf("Expected the same databases after restart, got %v", databases)
+	}
+}
+
+// TestRestoreToEarlierPoint tests that an earlier state is rebuilt from archived AOF files
+// and stays the current state afterwards
+func TestRestoreToEarlierPoint(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	testLogger, err := logger.NewFromConfigString("error", "text")
+	if err != nil {
+		t.Fatalf("Failed to create test logger: %v", err)
+	}
+	config := Config{
+		DataDir:         tempDir,
+		RDBFilename:     "test.rdb",
+		AOFFilename:     "test.aof",
+		AOFArchiveDir:   "archive",
+		AOFSyncStrategy: "always",
+		Logger:          testLogger,
+	}
+
+	engine := database.NewEngine()
+	manager, err := NewManagerWithEngine(config, engine)
+	if err != nil {
+		t.Fatalf("Failed to create persistence manager: %v", err)
+	}
+	create := func(name string) {
+		if err := engine.CreateDatabase(ctx, name); err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		if err := manager.LogCreateDatabase(ctx, name); err != nil {
+			t.Fatalf("Failed to log create database: %v", err)
+		}
+	}
+	snapshot := func() {
+		if err := manager.SaveEngineSnapshot(ctx); err != nil {
+			t.Fatalf("Failed to save engine snapshot: %v", err)
+		}
+	}
+
+	create("db1")
+	snapshot()
+	create("db2")
+	target := time.Now()
+	time.Sleep(time.Until(target.Truncate(time.Second).Add(time.Second))) // Command times have second precision
+
+	// The mistake to go back from, the snapshot after it archives everything before
+	create("db3")
+	if err := engine.DropDatabase(ctx, "db1"); err != nil {
+		t.Fatalf("Failed to drop database: %v", err)
+	}
+	if err := manager.LogDropDatabase(ctx, "db1"); err != nil {
+		t.Fatalf("Failed to log drop database: %v", err)
+	}
+	snapshot()
+	manager.Stop(ctx)
+
+	recoverDatabases := func(config Config) []string {
+		engine := database.NewEngine()
+		manager, err := NewManagerWithEngine(config, engine)
+		if err != nil {
+			t.Fatalf("Failed to create persistence manager: %v", err)
+		}
+		defer manager.Stop(ctx)
+
+		if err := manager.Recover(ctx); err != nil {
+			t.Fatalf("Failed to recover: %v", err)
+		}
+		databases, _ := engine.ListDatabases(ctx)
+		sort.Strings(databases)
+		return databases
+	}
+
+	if databases := recoverDatabases(config); fmt.Sprint(databases) != "[db2 db3]" {
+		t.Errorf("Expected the latest state before restoring, got %v", databases)
+	}
+
+	restoreConfig := config
+	restoreConfig.RestoreTo = &aof.RestoreTarget{Time: target}
+	if databases := recoverDatabases(restoreConfig); fmt.Sprint(databases) != "[db1 db2]" {
+		t.Errorf("Expected the state at the target, got %v", databases)
+	}
+
+	// The restored state is what the next normal start recovers
+	if databases := recoverDatabases(config); fmt.Sprint(databases) != "[db1 db2]" {
+		t.Errorf("Expected the restored state after restart, got %v", databases)
+	}
+}
+
+// TestBackupAndRestore tests that restoring a backup swaps the state of the running engine
+// and that the restored state is what a restart recovers
+func TestBackupAndRestore(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	testLogger, err := logger.NewFromConfigString("error", "text")
+	if err != nil {
+		t.Fatalf("Failed to create test logger: %v", err)
+	}
+	config := Config{
+		DataDir:         tempDir,
+		RDBFilename:     "test.rdb",
+		AOFFilename:     "test.aof",
+		AOFSyncStrategy: "always",
+		BackupRetention: rdb.RetentionPolicy{KeepLast: 2},
+		Logger:          testLogger,
+	}
+
+	engine := database.NewEngine()
+	manager, err := NewManagerWithEngine(config, engine)
+	if err != nil {
+		t.Fatalf("Failed to create persistence manager: %v", err)
+	}
+	create := func(name string) {
+		if err := engine.CreateDatabase(ctx, name); err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		if err := manager.LogCreateDatabase(ctx, name); err != nil {
+			t.Fatalf("Failed to log create database: %v", err)
+		}
+	}
+	databases := func(engine *database.Engine) string {
+		names, _ := engine.ListDatabases(ctx)
+		sort.Strings(names)
+		return fmt.Sprint(names)
+	}
+
+	create("db1")
+	result, err := manager.CreateBackup(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	backup := result.Backup
+	if len(result.Pruned) != 0 {
+		t.Errorf("Expected no pruned backups, got %v", result.Pruned)
+	}
+	if filepath.Dir(backup.Path) != filepath.Join(tempDir, DefaultBackupDir) {
+		t.Errorf("Expected the backup in the backup directory, got %s", backup.Path)
+	}
+
+	// The oldest backup goes once there are more than the policy keeps
+	create("db2")
+	for i := 0; i < 2; i++ {
+		if _, err := manager.CreateBackup(ctx); err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+	}
+	backups, err := manager.ListBackups(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups after pruning, got %d", len(backups))
+	}
+	if _, err := manager.RestoreBackup(ctx, backup.Name); !utils.IsScintireteError(err) || err.(*utils.ScintireteError).Code != utils.ErrorCodeBackupNotFound {
+		t.Errorf("Expected the pruned backup to be gone, got %v", err)
+	}
+
+	// Restoring swaps the state in place, writes afterwards go on top of it
+	create("db3")
+	if _, err := manager.RestoreBackup(ctx, backups[0].Name); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if got := databases(engine); got != "[db1 db2]" {
+		t.Errorf("Expected the backup state after restoring, got %s", got)
+	}
+	create("db4")
+
+	// A damaged backup leaves the state alone
+	damaged := filepath.Join(tempDir, DefaultBackupDir, "rdb_backup_damaged.flatbuf")
+	if err := os.WriteFile(damaged, []byte("not a snapshot"), 0644); err != nil {
+		t.Fatalf("Failed to write damaged backup: %v", err)
+	}
+	if _, err := manager.RestoreBackup(ctx, filepath.Base(damaged)); err == nil {
+		t.Error("Expected restoring a damaged backup to fail")
+	}
+	if got := databases(engine); got != "[db1 db2 db4]" {
+		t.Errorf("Expected the state to be unchanged by a failed restore, got %s", got)
+	}
+	if err := manager.DeleteBackup(ctx, filepath.Base(damaged)); err != nil {
+		t.Errorf("Failed to delete backup: %v", err)
+	}
+	if err := manager.DeleteBackup(ctx, "../test.rdb"); err == nil {
+		t.Error("Expected a name outside the backup directory to be rejected")
+	}
+	manager.Stop(ctx)
+
+	// A restart recovers the restored state with the writes made after it
+	engine2 := database.NewEngine()
+	manager2, err := NewManagerWithEngine(config, engine2)
+	if err != nil {
+		t.Fatalf("Failed to create persistence manager: %v", err)
+	}
+	defer manager2.Stop(ctx)
+	if err := manager2.Recover(ctx); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+	if got := databases(engine2); got != "[db1 db2 db4]" {
+		t.Errorf("Expected the restored state after restart, got %s", got)
+	}
+}
+
+func TestBackupTargets(t *testing.T) {
+	tempDir := t.TempDir()
+	targetDir := filepath.Join(t.TempDir(), "remote")
+	ctx := context.Background()
+
+	testLogger, err := logger.NewFromConfigString("error", "text")
+	if err != nil {
+		t.Fatalf("Failed to create test logger: %v", err)
+	}
+	config := Config{
+		DataDir:         tempDir,
+		RDBFilename:     "test.rdb",
+		AOFFilename:     "test.aof",
+		AOFSyncStrategy: "always",
+		BackupTargets:   []backup.TargetConfig{{Name: "remote", Type: "dir", Path: targetDir}},
+		Logger:          testLogger,
+	}
+
+	engine := database.NewEngine()
+	manager, err := NewManagerWithEngine(config, engine)
+	if err != nil {
+		t.Fatalf("Failed to create persistence manager: %v", err)
+	}
+	defer manager.Stop(ctx)
+	if err := engine.CreateDatabase(ctx, "db1"); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := manager.LogCreateDatabase(ctx, "db1"); err != nil {
+		t.Fatalf("Failed to log create database: %v", err)
+	}
+
+	result, err := manager.CreateBackup(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	if len(result.UploadErrors) != 0 || fmt.Sprint(result.Backup.Targets) != "[remote]" {
+		t.Fatalf("Expected the backup on the target, got targets %v and errors %v", result.Backup.Targets, result.UploadErrors)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, result.Backup.Name)); err != nil {
+		t.Fatalf("Expected the backup in the target directory: %v", err)
+	}
+
+	// A backup that is gone locally is listed from the target and restored from it
+	if err := os.Remove(result.Backup.Path); err != nil {
+		t.Fatalf("Failed to remove local backup: %v", err)
+	}
+	backups, err := manager.ListBackups(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Path != "" || fmt.Sprint(backups[0].Targets) != "[remote]" {
+		t.Fatalf("Expected the backup only on the target, got %+v", backups)
+	}
+
+	if err := engine.DropDatabase(ctx, "db1"); err != nil {
+		t.Fatalf("Failed to drop database: %v", err)
+	}
+	if _, err := manager.RestoreBackup(ctx, result.Backup.Name); err != nil {
+		t.Fatalf("Failed to restore backup from target: %v", err)
+	}
+	if names, _ := engine.ListDatabases(ctx); fmt.Sprint(names) != "[db1]" {
+		t.Errorf("Expected the backup state after restoring, got %v", names)
+	}
+	if _, err := os.Stat(result.Backup.Path); err != nil {
+		t.Errorf("Expected the fetched backup in the backup directory: %v", err)
+	}
+
+	// Deleting removes every copy
+	if err := manager.DeleteBackup(ctx, result.Backup.Name); err != nil {
+		t.Fatalf("Failed to delete backup: %v", err)
+	}
+	if backups, _ := manager.ListBackups(ctx); len(backups) != 0 {
+		t.Errorf("Expected no backups after deleting, got %+v", backups)
+	}
+}
@@ -1,10 +1,21 @@
 package persistence
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/scintirete/scintirete/internal/observability/logger"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
 	"github.com/scintirete/scintirete/internal/persistence/rdb"
 	"github.com/scintirete/scintirete/pkg/types"
 )
@@ -479,6 +490,141 @@ func TestManager_SaveSnapshotTruncatesAOF(t *testing.T) {
 	}
 }
 
+// Environment variables that turn the test binary into the writer process of the crash harness
+const (
+	crashHelperDirEnv   = "SCINTIRETE_CRASH_HELPER_DIR"
+	crashHelperRoundEnv = "SCINTIRETE_CRASH_HELPER_ROUND"
+)
+
+// TestCrashHelperProcess is the writer process of TestAOFDurabilityAfterCrash. It recovers
+// the data directory like a restarting server, then logs from several goroutines with
+// the always strategy and prints "ack <id>" for every acknowledged write until it is killed.
+func TestCrashHelperProcess(t *testing.T) {
+	dataDir := os.Getenv(crashHelperDirEnv)
+	if dataDir == "" {
+		t.Skip("helper process for TestAOFDurabilityAfterCrash")
+	}
+	round := os.Getenv(crashHelperRoundEnv)
+
+	quietLogger, err := logger.NewFromConfigString("error", "text")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create logger: %v\n", err)
+		os.Exit(1)
+	}
+	config := DefaultConfig(dataDir)
+	config.AOFSyncStrategy = "always"
+	config.Logger = quietLogger
+
+	manager, err := NewManager(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create manager: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Recovery cuts off the torn tail the previous crash may have left
+	ctx := context.Background()
+	if err := manager.Recover(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to recover: %v\n", err)
+		os.Exit(1)
+	}
+
+	var out sync.Mutex
+	for w := 0; w < 8; w++ {
+		go func(w int) {
+			for i := 0; ; i++ {
+				id := fmt.Sprintf("%s-%d-%d", round, w, i)
+				if err := manager.LogDeleteVectors(ctx, "db", "coll", []string{id}); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to log %s: %v\n", id, err)
+					os.Exit(1)
+				}
+				out.Lock()
+				fmt.Printf("ack %s\n", id)
+				out.Unlock()
+			}
+		}(w)
+	}
+	select {} // Runs until killed
+}
+
+// TestAOFDurabilityAfterCrash kills a writer process in the middle of concurrent writes
+// and checks that every acknowledged write survives in the AOF. Killing the process
+// leaves the page cache intact, so this covers what the process had acknowledged but
+// still buffered, not power loss.
+func TestAOFDurabilityAfterCrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crash harness in short mode")
+	}
+
+	dataDir := t.TempDir()
+	acked := make(map[string]bool)
+
+	// Each round restarts on the file the previous crash left behind
+	for round := 0; round < 3; round++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCrashHelperProcess$")
+		cmd.Env = append(os.Environ(), crashHelperDirEnv+"="+dataDir, crashHelperRoundEnv+"="+strconv.Itoa(round))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatalf("Failed to create stdout pipe: %v", err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Failed to start writer process: %v", err)
+		}
+
+		// Kill the writer after a number of acknowledgements, then collect the ones still in the pipe
+		roundAcks := 0
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			id, ok := strings.CutPrefix(scanner.Text(), "ack ")
+			if !ok {
+				continue
+			}
+			acked[id] = true
+			roundAcks++
+			if roundAcks == 500 {
+				cmd.Process.Kill()
+			}
+		}
+		cmd.Wait()
+
+		if roundAcks < 500 {
+			t.Fatalf("Writer process exited after %d acknowledgements in round %d: %s", roundAcks, round, stderr.String())
+		}
+	}
+
+	logged := make(map[string]bool)
+	config := DefaultConfig(dataDir)
+	logger, err := aof.OpenMultiPart(aof.MultiPartOptions{
+		Dir:          filepath.Join(dataDir, config.AOFDirname),
+		Name:         config.AOFFilename,
+		SyncStrategy: aof.SyncNo,
+	})
+	if err != nil {
+		t.Fatalf("Failed to open AOF after crashes: %v", err)
+	}
+	defer logger.Close()
+	if _, err := logger.Replay(context.Background(), aof.ReplayOptions{}, func(command types.AOFCommand) error {
+		ids, _ := command.Args["ids"].([]string)
+		for _, id := range ids {
+			logged[id] = true
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to replay AOF after crashes: %v", err)
+	}
+
+	lost := 0
+	for id := range acked {
+		if !logged[id] {
+			lost++
+		}
+	}
+	if lost > 0 {
+		t.Errorf("Lost %d of %d acknowledged writes", lost, len(acked))
+	}
+}
+
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
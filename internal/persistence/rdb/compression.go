@@ -0,0 +1,260 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Compression selects how RDB snapshots are compressed on disk
+type Compression string
+
+const (
+	CompressionNone Compression = "none" // Store the FlatBuffers data as is
+	CompressionZstd Compression = "zstd" // Smallest files, slower than lz4
+	CompressionLZ4  Compression = "lz4"  // Fast compression with a lower ratio
+)
+
+// ParseCompression validates a compression name. An empty name selects none.
+func ParseCompression(name string) (Compression, error) {
+	switch compression := Compression(name); compression {
+	case "":
+		return CompressionNone, nil
+	case CompressionNone, CompressionZstd, CompressionLZ4:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("invalid RDB compression: %s", name)
+	}
+}
+
+// RDB file layout since format version 1:
+//
+//	magic "SRDB" | version uint32 | compression uint8 | 3 reserved bytes | uncompressed size uint64
+//
+// Version 1 follows the header with one FlatBuffers snapshot, which compressed files
+// split into blocks of rdbBlockSize bytes, each stored as its uncompressed and
+// compressed length (uint32) and the compressed data.
+//
+// Version 2 leaves the size at zero and follows the header with SnapshotChunk
+// FlatBuffers, each stored as its uncompressed length, stored length and CRC32C of the
+// stored bytes (uint32) and the chunk, compressed on its own. See stream.go.
+//
+// Version 3 is version 2 encrypted: the header is followed by the encryption envelope
+// holding the wrapped data key of the file, and every chunk is compressed, then sealed
+// with AES-GCM using its index as the nonce. The stored length and CRC32C cover the
+// sealed bytes. See the encryption package.
+// All integers are little-endian.
+//
+// Files written before the header existed hold the bare FlatBuffers snapshot, which
+// starts with the offset of its root table and can't be mistaken for the magic.
+const (
+	rdbFormatWhole     uint32 = 1
+	rdbFormatChunked   uint32 = 2
+	rdbFormatEncrypted uint32 = 3
+	rdbFormatVersion          = rdbFormatEncrypted
+	rdbHeaderSize             = 20
+	rdbBlockSize              = 4 * 1024 * 1024
+)
+
+var rdbMagic = []byte("SRDB")
+
+// compressionCodes are the header values of the compression methods
+var compressionCodes = map[Compression]byte{
+	CompressionNone: 0,
+	CompressionZstd: 1,
+	CompressionLZ4:  2,
+}
+
+// DetectCompression reads the compression of an RDB file from its header
+func DetectCompression(filePath string) (Compression, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, rdbHeaderSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return headerCompression(header[:n])
+}
+
+// headerCompression returns the compression recorded in the header at the start of data
+func headerCompression(data []byte) (Compression, error) {
+	_, compression, err := parseHeader(data)
+	return compression, err
+}
+
+// parseHeader returns the format version and compression recorded in the header at the
+// start of data. Files without a header are reported as version 0.
+func parseHeader(data []byte) (uint32, Compression, error) {
+	if len(data) < len(rdbMagic) || !bytes.Equal(data[:len(rdbMagic)], rdbMagic) {
+		return 0, CompressionNone, nil // Written before the header existed
+	}
+	if len(data) < rdbHeaderSize {
+		return 0, "", fmt.Errorf("RDB header is cut off")
+	}
+
+	version := binary.LittleEndian.Uint32(data[4:])
+	if version == 0 || version > rdbFormatVersion {
+		return 0, "", fmt.Errorf("unsupported RDB format version %d", version)
+	}
+	for compression, code := range compressionCodes {
+		if code == data[8] {
+			return version, compression, nil
+		}
+	}
+	return 0, "", fmt.Errorf("unknown RDB compression %d", data[8])
+}
+
+// writeHeader writes the RDB file header
+func writeHeader(w io.Writer, version uint32, compression Compression, size uint64) error {
+	code, ok := compressionCodes[compression]
+	if !ok {
+		return fmt.Errorf("invalid RDB compression: %s", compression)
+	}
+
+	header := make([]byte, rdbHeaderSize)
+	copy(header, rdbMagic)
+	binary.LittleEndian.PutUint32(header[4:], version)
+	header[8] = code
+	binary.LittleEndian.PutUint64(header[12:], size)
+	_, err := w.Write(header)
+	return err
+}
+
+// blockCodec compresses and decompresses independent blocks. The zstd coders are
+// created on first use and released by close. LZ4 blocks use the LZ4 block format.
+type blockCodec struct {
+	compression Compression
+	encoder     *zstd.Encoder
+	decoder     *zstd.Decoder
+	lz4         lz4.Compressor
+}
+
+// compress returns the compressed block, or the block itself without compression
+func (c *blockCodec) compress(block []byte) ([]byte, error) {
+	switch c.compression {
+	case CompressionZstd:
+		if c.encoder == nil {
+			encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			c.encoder = encoder
+		}
+		return c.encoder.EncodeAll(block, nil), nil
+	case CompressionLZ4:
+		compressed := make([]byte, lz4.CompressBlockBound(len(block)))
+		n, err := c.lz4.CompressBlock(block, compressed)
+		if err != nil {
+			return nil, err
+		}
+		return compressed[:n], nil
+	default:
+		return block, nil
+	}
+}
+
+// decompress appends the block, which expands to rawSize bytes, to dst
+func (c *blockCodec) decompress(dst, block []byte, rawSize int) ([]byte, error) {
+	start := len(dst)
+	var err error
+	switch c.compression {
+	case CompressionZstd:
+		if c.decoder == nil {
+			if c.decoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1)); err != nil {
+				return nil, err
+			}
+		}
+		dst, err = c.decoder.DecodeAll(block, dst)
+	case CompressionLZ4:
+		// A sequence expands to at most 255 times its size, so a larger size is corrupt
+		if rawSize > 255*len(block) {
+			return nil, fmt.Errorf("lz4 block of %d bytes can't expand to %d bytes", len(block), rawSize)
+		}
+		dst = slices.Grow(dst, rawSize)
+		var n int
+		n, err = lz4.UncompressBlock(block, dst[start:start+rawSize])
+		dst = dst[:start+n]
+	default:
+		dst = append(dst, block...)
+	}
+	if err == nil && len(dst)-start != rawSize {
+		err = fmt.Errorf("block is %d bytes, expected %d", len(dst)-start, rawSize)
+	}
+	return dst, err
+}
+
+// close releases the zstd coders
+func (c *blockCodec) close() {
+	if c.encoder != nil {
+		c.encoder.Close()
+	}
+	if c.decoder != nil {
+		c.decoder.Close()
+	}
+}
+
+// decodeSnapshotFile returns the FlatBuffers data of a format version 1 or headerless
+// RDB file and the compression it used
+func decodeSnapshotFile(file []byte) ([]byte, Compression, error) {
+	version, compression, err := parseHeader(file)
+	if err != nil {
+		return nil, "", err
+	}
+	if version == 0 {
+		return file, CompressionNone, nil // Written before the header existed
+	}
+	if version != rdbFormatWhole {
+		return nil, "", fmt.Errorf("RDB format version %d holds chunks, not a single snapshot", version)
+	}
+
+	size := binary.LittleEndian.Uint64(file[12:])
+	body := file[rdbHeaderSize:]
+	if compression == CompressionNone {
+		if uint64(len(body)) != size {
+			return nil, "", fmt.Errorf("RDB data is %d bytes, header says %d", len(body), size)
+		}
+		return body, compression, nil
+	}
+	if size > uint64(len(body)/8+1)*rdbBlockSize {
+		return nil, "", fmt.Errorf("RDB header claims %d bytes, more than the blocks can hold", size)
+	}
+
+	codec := &blockCodec{compression: compression}
+	defer codec.close()
+
+	data := make([]byte, 0, size)
+	for len(body) > 0 {
+		if len(body) < 8 {
+			return nil, "", fmt.Errorf("RDB block header is cut off")
+		}
+		rawSize := int(binary.LittleEndian.Uint32(body))
+		compressedSize := int(binary.LittleEndian.Uint32(body[4:]))
+		body = body[8:]
+		if rawSize > rdbBlockSize || compressedSize > len(body) {
+			return nil, "", fmt.Errorf("RDB block is cut off or corrupt")
+		}
+
+		if data, err = codec.decompress(data, body[:compressedSize], rawSize); err != nil {
+			return nil, "", fmt.Errorf("failed to decompress RDB block: %w", err)
+		}
+
+		body = body[compressedSize:]
+	}
+
+	if uint64(len(data)) != size {
+		return nil, "", fmt.Errorf("RDB data is %d bytes, header says %d", len(data), size)
+	}
+	return data, compression, nil
+}
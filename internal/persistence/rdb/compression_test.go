@@ -0,0 +1,125 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeSnapshotFile wraps FlatBuffers data into the format version 1 file layout
+func encodeSnapshotFile(w io.Writer, data []byte, compression Compression) error {
+	if err := writeHeader(w, rdbFormatWhole, compression, uint64(len(data))); err != nil {
+		return err
+	}
+	if compression == CompressionNone {
+		_, err := w.Write(data)
+		return err
+	}
+
+	codec := &blockCodec{compression: compression}
+	defer codec.close()
+
+	prefix := make([]byte, 8)
+	for start := 0; start < len(data); start += rdbBlockSize {
+		block := data[start:min(start+rdbBlockSize, len(data))]
+		compressed, err := codec.compress(block)
+		if err != nil {
+			return err
+		}
+
+		binary.LittleEndian.PutUint32(prefix, uint32(len(block)))
+		binary.LittleEndian.PutUint32(prefix[4:], uint32(len(compressed)))
+		if _, err := w.Write(prefix); err != nil {
+			return err
+		}
+		if _, err := w.Write(compressed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLZ4Block(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rng.Read(random)
+
+	tests := map[string][]byte{
+		"empty":      {},
+		"short":      []byte("abc"),
+		"repetitive": bytes.Repeat([]byte("scintirete "), 10000),
+		"zeros":      make([]byte, 70000),
+		"random":     random,
+	}
+	codec := &blockCodec{compression: CompressionLZ4}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			compressed, err := codec.compress(src)
+			require.NoError(t, err)
+			decompressed, err := codec.decompress([]byte("prefix"), compressed, len(src))
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(append([]byte("prefix"), src...), decompressed))
+		})
+	}
+
+	compressed, err := codec.compress(tests["repetitive"])
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(tests["repetitive"])/10)
+
+	// Damaged blocks fail instead of panicking
+	for i := range compressed {
+		damaged := bytes.Clone(compressed)
+		damaged[i] ^= 0xff
+		codec.decompress(nil, damaged, len(tests["repetitive"]))
+	}
+	_, err = codec.decompress(nil, compressed[:len(compressed)/2], len(tests["repetitive"]))
+	assert.Error(t, err)
+
+	// A size no block can expand to is rejected before anything is allocated
+	_, err = codec.decompress(nil, compressed, math.MaxInt32)
+	assert.Error(t, err)
+}
+
+func TestSnapshotFileFormat(t *testing.T) {
+	data := bytes.Repeat([]byte("flatbuffers"), rdbBlockSize/5) // More than two blocks
+
+	for _, compression := range []Compression{CompressionNone, CompressionZstd, CompressionLZ4} {
+		t.Run(string(compression), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, encodeSnapshotFile(&buf, data, compression))
+
+			decoded, detected, err := decodeSnapshotFile(buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, compression, detected)
+			assert.True(t, bytes.Equal(data, decoded))
+
+			// A cut off file is detected
+			_, _, err = decodeSnapshotFile(buf.Bytes()[:buf.Len()-1])
+			assert.Error(t, err)
+		})
+	}
+
+	// Chunked files can't be decoded as a whole
+	var chunked bytes.Buffer
+	require.NoError(t, writeHeader(&chunked, rdbFormatChunked, CompressionNone, 0))
+	_, _, err := decodeSnapshotFile(chunked.Bytes())
+	assert.Error(t, err)
+
+	// Files without the header are returned as they are
+	decoded, detected, err := decodeSnapshotFile([]byte{12, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, CompressionNone, detected)
+	assert.Equal(t, []byte{12, 0, 0, 0}, decoded)
+
+	compression, err := ParseCompression("")
+	require.NoError(t, err)
+	assert.Equal(t, CompressionNone, compression)
+	_, err = ParseCompression("gzip")
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// SetEncryption sets the keys snapshots are encrypted with. Snapshots saved from then
+// on get a new data key wrapped with the current master key of keys, which rotates the
+// master key of the RDB file with its next save; Load decrypts files written with any
+// master key keys has. A nil provider saves unencrypted snapshots.
+func (r *RDBManager) SetEncryption(keys encryption.KeyProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = keys
+}
+
+// Encryption returns the keys snapshots are encrypted with, nil if they are not
+func (r *RDBManager) Encryption() encryption.KeyProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys
+}
+
+// openFileKey reads the encryption envelope following the header of a format version 3
+// file and unwraps its data key
+func (r *RDBManager) openFileKey(ctx context.Context, reader io.Reader) (*encryption.FileKey, error) {
+	envelope, err := encryption.ReadEnvelope(reader)
+	if err != nil {
+		return nil, utils.ErrCorruptedData("invalid RDB encryption envelope: " + err.Error())
+	}
+	return encryption.OpenFileKey(ctx, r.keys, envelope)
+}
+
+// DetectKeyID returns the ID of the master key an RDB file is encrypted with, or an
+// empty string if it is not encrypted
+func DetectKeyID(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, rdbHeaderSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	version, _, err := parseHeader(header[:n])
+	if err != nil || version != rdbFormatEncrypted {
+		return "", err
+	}
+
+	envelope, err := encryption.ReadEnvelope(file)
+	if err != nil {
+		return "", err
+	}
+	return envelope.KeyID, nil
+}
@@ -0,0 +1,129 @@
+package rdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys returns encryption keys with the given IDs, the first one current
+func testKeys(t *testing.T, ids ...string) encryption.KeyProvider {
+	t.Helper()
+
+	var text string
+	for _, id := range ids {
+		key := sha256.Sum256([]byte(id))
+		text += id + ":" + hex.EncodeToString(key[:]) + "\n"
+	}
+	keys, err := encryption.ParseKeys(text)
+	require.NoError(t, err)
+	return keys
+}
+
+func TestRDBManager_Encryption(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "test.rdb")
+	manager, err := NewRDBManagerWithCompression(filePath, CompressionLZ4)
+	require.NoError(t, err)
+	manager.SetEncryption(testKeys(t, "k1"))
+
+	snapshot := chunkedTestSnapshot()
+	require.NoError(t, manager.Save(ctx, snapshot))
+
+	loaded, err := manager.Load(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, snapshot.Databases, loaded.Databases)
+
+	keyID, err := DetectKeyID(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", keyID)
+	info, err := manager.GetInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "k1", info.KeyID)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(data, []byte("coll")), "collection names are written in the clear")
+
+	// Without the key, or with another one, the snapshot can't be loaded
+	reader, err := NewRDBManager(filePath)
+	require.NoError(t, err)
+	_, err = reader.Load(ctx)
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+	reader.SetEncryption(testKeys(t, "k2"))
+	_, err = reader.Load(ctx)
+	assert.Equal(t, utils.ErrorCodeConfig, utils.GetErrorCode(err))
+
+	file, err := os.Open(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = ReadStreamWithKeys(file, testKeys(t, "k1"), &visitRecorder{})
+	require.NoError(t, err)
+
+	// Damaged chunks fail their checksum or decryption
+	damaged := bytes.Clone(data)
+	damaged[len(damaged)/3] ^= 0xff
+	require.NoError(t, os.WriteFile(filePath, damaged, 0644))
+	_, err = manager.Load(ctx)
+	assert.Error(t, err)
+}
+
+func TestRDBManager_EncryptionRotation(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "test.rdb")
+	manager, err := NewRDBManager(filePath)
+	require.NoError(t, err)
+
+	// An unencrypted snapshot still loads once keys are configured
+	snapshot := graphTestSnapshot(10)
+	require.NoError(t, manager.Save(ctx, snapshot))
+	manager.SetEncryption(testKeys(t, "k1"))
+	loaded, err := manager.Load(ctx)
+	require.NoError(t, err)
+	nodes := snapshot.Databases["db"].Collections["coll"].HNSWGraph.Nodes
+	assert.Equal(t, nodes, loaded.Databases["db"].Collections["coll"].HNSWGraph.Nodes)
+	keyID, err := DetectKeyID(filePath)
+	require.NoError(t, err)
+	assert.Empty(t, keyID)
+
+	require.NoError(t, manager.Save(ctx, snapshot))
+	keyID, err = DetectKeyID(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", keyID)
+
+	// A new master key takes over with the next save, the old one still reads the file until then
+	manager.SetEncryption(testKeys(t, "k2", "k1"))
+	_, err = manager.Load(ctx)
+	require.NoError(t, err)
+	require.NoError(t, manager.Save(ctx, snapshot))
+	keyID, err = DetectKeyID(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keyID)
+
+	// Backups are encrypted like the snapshot and restore with the configured keys
+	backups, err := NewBackupManager(manager, filepath.Join(dir, "backups"))
+	require.NoError(t, err)
+	backupPath, err := backups.CreateBackup(ctx)
+	require.NoError(t, err)
+	keyID, err = DetectKeyID(backupPath)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keyID)
+
+	require.NoError(t, manager.Remove())
+	require.NoError(t, backups.RestoreFromBackup(ctx, backupPath))
+	manager.SetEncryption(testKeys(t, "k2"))
+	loaded, err = manager.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, nodes, loaded.Databases["db"].Collections["coll"].HNSWGraph.Nodes)
+}
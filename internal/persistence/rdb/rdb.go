@@ -2,11 +2,15 @@
 package rdb
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -14,6 +18,8 @@ import (
 	flatbuffers "github.com/google/flatbuffers/go"
 	"github.com/scintirete/scintirete/internal/core"
 	fbrdb "github.com/scintirete/scintirete/internal/flatbuffers/rdb"
+	"github.com/scintirete/scintirete/internal/persistence/backup"
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
 	"github.com/scintirete/scintirete/internal/utils"
 	"github.com/scintirete/scintirete/pkg/types"
 )
@@ -43,6 +49,16 @@ type CollectionSnapshot struct {
 	DeletedCount int64                  `json:"deleted_count"`
 	CreatedAt    time.Time              `json:"created_at"`
 	UpdatedAt    time.Time              `json:"updated_at"`
+	Partitions   []PartitionSnapshot    `json:"partitions,omitempty"` // Empty for snapshots without partitions
+}
+
+// PartitionSnapshot represents a snapshot of a single partition.
+// The default partition's graph is kept in CollectionSnapshot.HNSWGraph.
+type PartitionSnapshot struct {
+	Name      string             `json:"name"`
+	HNSWGraph *HNSWGraphSnapshot `json:"hnsw_graph,omitempty"`
+	Released  bool               `json:"released"`
+	CreatedAt time.Time          `json:"created_at"`
 }
 
 // HNSWGraphSnapshot represents a snapshot of the HNSW graph state
@@ -71,10 +87,12 @@ type LayerConnectionsSnapshot struct {
 
 // RDBInfo contains information about the RDB file
 type RDBInfo struct {
-	Exists  bool      `json:"exists"`
-	Size    int64     `json:"size,omitempty"`
-	ModTime time.Time `json:"mod_time,omitempty"`
-	Path    string    `json:"path,omitempty"`
+	Exists      bool        `json:"exists"`
+	Size        int64       `json:"size,omitempty"`
+	ModTime     time.Time   `json:"mod_time,omitempty"`
+	Path        string      `json:"path,omitempty"`
+	Compression Compression `json:"compression,omitempty"`
+	KeyID       string      `json:"key_id,omitempty"` // Master key of encrypted files
 }
 
 // DatabaseState represents the current state of a database for snapshotting
@@ -94,25 +112,46 @@ type CollectionState struct {
 	DeletedCount int64                  `json:"deleted_count"`
 	CreatedAt    time.Time              `json:"created_at"`
 	UpdatedAt    time.Time              `json:"updated_at"`
+	Partitions   []PartitionState       `json:"partitions,omitempty"`
+}
+
+// PartitionState represents the current state of a partition for snapshotting
+type PartitionState struct {
+	Name      string               `json:"name"`
+	HNSWGraph *core.HNSWGraphState `json:"hnsw_graph,omitempty"` // nil while the partition is released
+	Released  bool                 `json:"released"`
+	CreatedAt time.Time            `json:"created_at"`
 }
 
 // BackupInfo contains information about a backup file
 type BackupInfo struct {
 	Name    string    `json:"name"`
-	Path    string    `json:"path"`
+	Path    string    `json:"path"` // Empty if the backup is only on targets
 	Size    int64     `json:"size"`
 	ModTime time.Time `json:"mod_time"`
+	Targets []string  `json:"targets,omitempty"` // Backup targets holding a copy
 }
 
 // RDBManager handles RDB snapshot operations using FlatBuffers
 type RDBManager struct {
-	mu       sync.RWMutex
-	filePath string
-	tempDir  string
+	mu          sync.RWMutex
+	filePath    string
+	tempDir     string
+	compression Compression            // Applied by Save, Load detects it from the file header
+	keys        encryption.KeyProvider // Encrypts saved snapshots if set, see encryption.go
 }
 
-// NewRDBManager creates a new RDB manager
+// NewRDBManager creates a new RDB manager that writes uncompressed snapshots
 func NewRDBManager(filePath string) (*RDBManager, error) {
+	return NewRDBManagerWithCompression(filePath, CompressionNone)
+}
+
+// NewRDBManagerWithCompression creates a new RDB manager that compresses the snapshots it saves
+func NewRDBManagerWithCompression(filePath string, compression Compression) (*RDBManager, error) {
+	if _, ok := compressionCodes[compression]; !ok {
+		return nil, utils.ErrConfig("invalid RDB compression: " + string(compression))
+	}
+
 	// Ensure directory exists
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -125,23 +164,35 @@ func NewRDBManager(filePath string) (*RDBManager, error) {
 	}
 
 	return &RDBManager{
-		filePath: filePath,
-		tempDir:  tempDir,
+		filePath:    filePath,
+		tempDir:     tempDir,
+		compression: compression,
 	}, nil
 }
 
 // Save creates and saves an RDB snapshot using FlatBuffers
 func (r *RDBManager) Save(ctx context.Context, snapshot RDBSnapshot) error {
+	return r.save(ctx, func(sw *snapshotWriter) (map[string]interface{}, error) {
+		return snapshot.Metadata, snapshot.Walk(sw)
+	})
+}
+
+// SaveStream saves a snapshot of the state exported by source. Collections are converted
+// and written as source passes them, so saving needs memory for one collection at a time
+// instead of a copy of all data.
+func (r *RDBManager) SaveStream(ctx context.Context, source StateSource) error {
+	return r.save(ctx, func(sw *snapshotWriter) (map[string]interface{}, error) {
+		return nil, source.StreamDatabaseState(ctx, &stateWriter{sw: sw})
+	})
+}
+
+// save writes the snapshot produced by write to a temporary file and atomically replaces
+// the RDB file with it. write returns the metadata to store with the snapshot.
+func (r *RDBManager) save(ctx context.Context, write func(sw *snapshotWriter) (map[string]interface{}, error)) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Set snapshot metadata
-	snapshot.Version = "1.0"
-	snapshot.Timestamp = time.Now()
-	if snapshot.Metadata == nil {
-		snapshot.Metadata = make(map[string]interface{})
-	}
-	snapshot.Metadata["created_by"] = "scintirete"
+	timestamp := time.Now()
 
 	// Create temporary file
 	tempPath := filepath.Join(r.tempDir, fmt.Sprintf("rdb_%d.tmp", time.Now().UnixNano()))
@@ -154,8 +205,7 @@ func (r *RDBManager) Save(ctx context.Context, snapshot RDBSnapshot) error {
 		os.Remove(tempPath) // Clean up on error
 	}()
 
-	// Convert to FlatBuffers and write
-	if err := r.writeSnapshotFlatBuffers(tempFile, snapshot); err != nil {
+	if err := r.writeSnapshot(ctx, tempFile, timestamp, write); err != nil {
 		return err
 	}
 
@@ -175,54 +225,153 @@ func (r *RDBManager) Save(ctx context.Context, snapshot RDBSnapshot) error {
 	return nil
 }
 
+// writeSnapshot writes the snapshot produced by write to w in the compression and
+// encryption of r
+func (r *RDBManager) writeSnapshot(ctx context.Context, w io.Writer, timestamp time.Time, write func(sw *snapshotWriter) (map[string]interface{}, error)) error {
+	sw, err := newSnapshotWriter(ctx, r, w)
+	if err != nil {
+		return err
+	}
+	defer sw.close()
+
+	metadata, err := write(sw)
+	if err != nil {
+		return err
+	}
+
+	// Set snapshot metadata
+	header := RDBSnapshot{
+		Version:   "1.0",
+		Timestamp: timestamp,
+		Metadata:  make(map[string]interface{}, len(metadata)+1),
+	}
+	for key, value := range metadata {
+		header.Metadata[key] = value
+	}
+	header.Metadata["created_by"] = "scintirete"
+	return sw.finish(header)
+}
+
+// WriteStream writes an unencrypted snapshot of the state exported by source to w, such
+// as the snapshot a primary sends a replica. It needs memory for one collection at a time.
+func WriteStream(ctx context.Context, w io.Writer, source StateSource, compression Compression) error {
+	return (&RDBManager{compression: compression}).writeSnapshot(ctx, w, time.Now(), func(sw *snapshotWriter) (map[string]interface{}, error) {
+		return nil, source.StreamDatabaseState(ctx, &stateWriter{sw: sw})
+	})
+}
+
+// SaveFrom saves the snapshot read from reader, such as one written by WriteStream, in
+// the compression and encryption of r, one collection at a time
+func (r *RDBManager) SaveFrom(ctx context.Context, reader io.Reader) error {
+	return r.save(ctx, func(sw *snapshotWriter) (map[string]interface{}, error) {
+		snapshot, err := ReadStream(reader, sw)
+		if err != nil {
+			return nil, err
+		}
+		return snapshot.Metadata, nil
+	})
+}
+
+// Compression returns the compression snapshots are saved with
+func (r *RDBManager) Compression() Compression {
+	return r.compression
+}
+
 // Load loads an RDB snapshot from disk using FlatBuffers
 func (r *RDBManager) Load(ctx context.Context) (*RDBSnapshot, error) {
+	assembler := &snapshotAssembler{databases: make(map[string]DatabaseSnapshot)}
+	snapshot, err := r.LoadStream(ctx, assembler)
+	if err != nil || snapshot == nil {
+		return nil, err
+	}
+
+	snapshot.Databases = assembler.databases
+	return snapshot, nil
+}
+
+// LoadStream reads the RDB snapshot from disk and passes its databases and collections to
+// visitor as they are read. It returns the snapshot without databases, or nil if none
+// exists. Chunked files are read one collection at a time, older files as a whole.
+// The snapshot version is only checked at the end, after visitor saw every collection.
+func (r *RDBManager) LoadStream(ctx context.Context, visitor SnapshotVisitor) (*RDBSnapshot, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	// Check if file exists
-	if _, err := os.Stat(r.filePath); os.IsNotExist(err) {
+	file, err := os.Open(r.filePath)
+	if os.IsNotExist(err) {
 		return nil, nil // No snapshot exists, that's OK
 	} else if err != nil {
-		return nil, utils.ErrRecoveryFailed("failed to check RDB file: " + err.Error())
+		return nil, utils.ErrRecoveryFailed("failed to open RDB file: " + err.Error())
 	}
+	defer file.Close()
 
-	// Read all data
-	data, err := os.ReadFile(r.filePath)
-	if err != nil {
-		return nil, utils.ErrRecoveryFailed("failed to read RDB file: " + err.Error())
-	}
+	return r.readStream(ctx, file, visitor)
+}
 
-	// Parse FlatBuffers data
-	fbSnapshot := fbrdb.GetRootAsRDBSnapshot(data, 0)
+// ReadStream is LoadStream for a snapshot that is already open, such as a segment file
+// kept open while its path is replaced
+func ReadStream(reader io.Reader, visitor SnapshotVisitor) (*RDBSnapshot, error) {
+	return ReadStreamWithKeys(reader, nil, visitor)
+}
 
-	// Convert to Go struct
-	snapshot := &RDBSnapshot{
-		Version:   string(fbSnapshot.Version()),
-		Timestamp: time.Unix(fbSnapshot.Timestamp(), 0),
-		Databases: make(map[string]DatabaseSnapshot),
+// ReadStreamWithKeys is ReadStream for a snapshot that may be encrypted with keys
+func ReadStreamWithKeys(reader io.Reader, keys encryption.KeyProvider, visitor SnapshotVisitor) (*RDBSnapshot, error) {
+	return (&RDBManager{keys: keys}).readStream(context.Background(), reader, visitor)
+}
+
+// readStream detects the format of a snapshot and passes its databases and collections to visitor
+func (r *RDBManager) readStream(ctx context.Context, file io.Reader, visitor SnapshotVisitor) (*RDBSnapshot, error) {
+	reader := bufio.NewReaderSize(file, 1024*1024)
+	header, _ := reader.Peek(rdbHeaderSize) // Shorter files are handled by parseHeader
+	version, compression, err := parseHeader(header)
+	if err != nil {
+		return nil, utils.ErrCorruptedData(err.Error())
 	}
 
-	// Parse metadata
-	if metadataBytes := fbSnapshot.Metadata(); metadataBytes != nil {
-		if err := json.Unmarshal(metadataBytes, &snapshot.Metadata); err != nil {
-			return nil, utils.ErrCorruptedData("failed to parse metadata: " + err.Error())
+	var snapshot *RDBSnapshot
+	if version == rdbFormatChunked || version == rdbFormatEncrypted {
+		if _, err := reader.Discard(rdbHeaderSize); err != nil {
+			return nil, utils.ErrRecoveryFailed("failed to read RDB file: " + err.Error())
+		}
+		var key *encryption.FileKey
+		if version == rdbFormatEncrypted {
+			if key, err = r.openFileKey(ctx, reader); err != nil {
+				return nil, err
+			}
 		}
+		snapshot, err = r.readChunks(reader, compression, key, visitor)
+	} else {
+		snapshot, err = r.readWhole(reader, visitor)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Parse databases
-	for i := 0; i < fbSnapshot.DatabasesLength(); i++ {
-		fbDb := new(fbrdb.DatabaseSnapshot)
-		if !fbSnapshot.Databases(fbDb, i) {
-			return nil, utils.ErrCorruptedData("failed to parse database")
-		}
+	if err := validateHeader(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
 
-		dbSnapshot, err := r.parseDatabaseSnapshot(fbDb)
-		if err != nil {
-			return nil, err
-		}
+// readWhole parses a format version 1 or headerless RDB file at once and passes its
+// databases and collections to visitor
+func (r *RDBManager) readWhole(reader io.Reader, visitor SnapshotVisitor) (*RDBSnapshot, error) {
+	// Read all data
+	file, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, utils.ErrRecoveryFailed("failed to read RDB file: " + err.Error())
+	}
 
-		snapshot.Databases[dbSnapshot.Name] = *dbSnapshot
+	// Strip the header and decompress
+	data, _, err := decodeSnapshotFile(file)
+	if err != nil {
+		return nil, utils.ErrCorruptedData(err.Error())
+	}
+	file = nil
+
+	snapshot, err := r.parseWhole(data)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate snapshot
@@ -230,59 +379,48 @@ func (r *RDBManager) Load(ctx context.Context) (*RDBSnapshot, error) {
 		return nil, err
 	}
 
-	data = nil
-
+	if err := snapshot.Walk(visitor); err != nil {
+		return nil, err
+	}
+	snapshot.Databases = nil
 	return snapshot, nil
 }
 
-// writeSnapshotFlatBuffers writes the snapshot using FlatBuffers format
-func (r *RDBManager) writeSnapshotFlatBuffers(file *os.File, snapshot RDBSnapshot) error {
-	builder := flatbuffers.NewBuilder(0)
+// parseWhole converts a FlatBuffers RDBSnapshot to its Go struct
+func (r *RDBManager) parseWhole(data []byte) (snapshot *RDBSnapshot, err error) {
+	// These files have no checksums, damaged data shows as offsets out of range
+	defer func() {
+		if p := recover(); p != nil {
+			snapshot, err = nil, utils.ErrCorruptedData(fmt.Sprintf("failed to parse RDB file: %v", p))
+		}
+	}()
 
-	// Convert metadata to JSON string
-	metadataBytes, err := json.Marshal(snapshot.Metadata)
+	// Parse FlatBuffers data
+	fbSnapshot := fbrdb.GetRootAsRDBSnapshot(data, 0)
+
+	// Convert to Go struct
+	snapshot, err = parseSnapshotHeader(fbSnapshot)
 	if err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to marshal metadata", err)
+		return nil, err
 	}
+	snapshot.Databases = make(map[string]DatabaseSnapshot)
 
-	// Create databases vector
-	var dbSnapshots []flatbuffers.UOffsetT
-	for _, dbSnapshot := range snapshot.Databases {
-		dbOffset, err := r.createDatabaseSnapshot(builder, dbSnapshot)
-		if err != nil {
-			return err
+	// Parse databases
+	for i := 0; i < fbSnapshot.DatabasesLength(); i++ {
+		fbDb := new(fbrdb.DatabaseSnapshot)
+		if !fbSnapshot.Databases(fbDb, i) {
+			return nil, utils.ErrCorruptedData("failed to parse database")
 		}
-		dbSnapshots = append(dbSnapshots, dbOffset)
-	}
-
-	// Create databases vector
-	fbrdb.RDBSnapshotStartDatabasesVector(builder, len(dbSnapshots))
-	for i := len(dbSnapshots) - 1; i >= 0; i-- {
-		builder.PrependUOffsetT(dbSnapshots[i])
-	}
-	databasesVector := builder.EndVector(len(dbSnapshots))
-
-	// Create strings
-	versionStr := builder.CreateString(snapshot.Version)
-	metadataStr := builder.CreateString(string(metadataBytes))
 
-	// Create RDB snapshot
-	fbrdb.RDBSnapshotStart(builder)
-	fbrdb.RDBSnapshotAddVersion(builder, versionStr)
-	fbrdb.RDBSnapshotAddTimestamp(builder, snapshot.Timestamp.Unix())
-	fbrdb.RDBSnapshotAddDatabases(builder, databasesVector)
-	fbrdb.RDBSnapshotAddMetadata(builder, metadataStr)
-	rdbSnapshot := fbrdb.RDBSnapshotEnd(builder)
-
-	// Finish the FlatBuffer
-	builder.Finish(rdbSnapshot)
+		dbSnapshot, err := r.parseDatabaseSnapshot(fbDb)
+		if err != nil {
+			return nil, err
+		}
 
-	// Write to file
-	if _, err := file.Write(builder.FinishedBytes()); err != nil {
-		return utils.ErrPersistenceFailedWithCause("failed to write FlatBuffers data", err)
+		snapshot.Databases[dbSnapshot.Name] = *dbSnapshot
 	}
 
-	return nil
+	return snapshot, nil
 }
 
 // createDatabaseSnapshot creates a FlatBuffers DatabaseSnapshot
@@ -351,6 +489,22 @@ func (r *RDBManager) createCollectionSnapshot(builder *flatbuffers.Builder, coll
 		return 0, err
 	}
 
+	// Create partitions vector
+	var partitionOffsets []flatbuffers.UOffsetT
+	for _, partitionSnapshot := range collSnapshot.Partitions {
+		partitionOffset, err := r.createPartitionSnapshot(builder, partitionSnapshot)
+		if err != nil {
+			return 0, err
+		}
+		partitionOffsets = append(partitionOffsets, partitionOffset)
+	}
+
+	fbrdb.CollectionSnapshotStartPartitionsVector(builder, len(partitionOffsets))
+	for i := len(partitionOffsets) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(partitionOffsets[i])
+	}
+	partitionsVector := builder.EndVector(len(partitionOffsets))
+
 	// Create name string
 	nameStr := builder.CreateString(collSnapshot.Name)
 
@@ -366,10 +520,38 @@ func (r *RDBManager) createCollectionSnapshot(builder *flatbuffers.Builder, coll
 	fbrdb.CollectionSnapshotAddDeletedCount(builder, collSnapshot.DeletedCount)
 	fbrdb.CollectionSnapshotAddCreatedAt(builder, collSnapshot.CreatedAt.Unix())
 	fbrdb.CollectionSnapshotAddUpdatedAt(builder, collSnapshot.UpdatedAt.Unix())
+	fbrdb.CollectionSnapshotAddPartitions(builder, partitionsVector)
 
 	return fbrdb.CollectionSnapshotEnd(builder), nil
 }
 
+// createPartitionSnapshot creates a FlatBuffers PartitionSnapshot
+func (r *RDBManager) createPartitionSnapshot(builder *flatbuffers.Builder, partitionSnapshot PartitionSnapshot) (flatbuffers.UOffsetT, error) {
+	// Create HNSW graph
+	var hnswGraphOffset flatbuffers.UOffsetT
+	if partitionSnapshot.HNSWGraph != nil {
+		var err error
+		hnswGraphOffset, err = r.createHNSWGraph(builder, *partitionSnapshot.HNSWGraph)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Create name string
+	nameStr := builder.CreateString(partitionSnapshot.Name)
+
+	// Create partition snapshot
+	fbrdb.PartitionSnapshotStart(builder)
+	fbrdb.PartitionSnapshotAddName(builder, nameStr)
+	if partitionSnapshot.HNSWGraph != nil {
+		fbrdb.PartitionSnapshotAddHnswGraph(builder, hnswGraphOffset)
+	}
+	fbrdb.PartitionSnapshotAddReleased(builder, partitionSnapshot.Released)
+	fbrdb.PartitionSnapshotAddCreatedAt(builder, partitionSnapshot.CreatedAt.Unix())
+
+	return fbrdb.PartitionSnapshotEnd(builder), nil
+}
+
 // createVector creates a FlatBuffers Vector
 func (r *RDBManager) createVector(builder *flatbuffers.Builder, vector types.Vector) (flatbuffers.UOffsetT, error) {
 	// Create elements vector
@@ -388,12 +570,20 @@ func (r *RDBManager) createVector(builder *flatbuffers.Builder, vector types.Vec
 	// Create strings
 	idStr := builder.CreateString(fmt.Sprintf("%d", vector.ID))
 	metadataStr := builder.CreateString(string(metadataBytes))
+	var partitionStr flatbuffers.UOffsetT
+	if vector.Partition != "" {
+		partitionStr = builder.CreateString(vector.Partition)
+	}
 
 	// Create vector
 	fbrdb.VectorStart(builder)
 	fbrdb.VectorAddId(builder, idStr)
 	fbrdb.VectorAddElements(builder, elementsVector)
 	fbrdb.VectorAddMetadata(builder, metadataStr)
+	fbrdb.VectorAddExpireAt(builder, vector.ExpireAt)
+	if vector.Partition != "" {
+		fbrdb.VectorAddPartition(builder, partitionStr)
+	}
 
 	return fbrdb.VectorEnd(builder), nil
 }
@@ -414,6 +604,7 @@ func (r *RDBManager) createCollectionConfig(builder *flatbuffers.Builder, config
 	fbrdb.CollectionConfigAddName(builder, nameStr)
 	fbrdb.CollectionConfigAddMetric(builder, fbrdb.DistanceMetric(config.Metric))
 	fbrdb.CollectionConfigAddHnswParams(builder, hnswOffset)
+	fbrdb.CollectionConfigAddDefaultTtlSeconds(builder, config.DefaultTTLSeconds)
 
 	return fbrdb.CollectionConfigEnd(builder), nil
 }
@@ -511,7 +702,32 @@ func (r *RDBManager) createHNSWNode(builder *flatbuffers.Builder, node HNSWNodeS
 
 // createLayerConnections creates a FlatBuffers LayerConnections
 func (r *RDBManager) createLayerConnections(builder *flatbuffers.Builder, layerConn LayerConnectionsSnapshot) (flatbuffers.UOffsetT, error) {
-	// Create connected node IDs vector
+	// Create connected node IDs vector, as integers unless an ID isn't one
+	ids := make([]uint64, len(layerConn.ConnectedNodeIDs))
+	for i, nodeID := range layerConn.ConnectedNodeIDs {
+		id, err := strconv.ParseUint(nodeID, 10, 64)
+		if err != nil {
+			return r.createLegacyLayerConnections(builder, layerConn), nil
+		}
+		ids[i] = id
+	}
+
+	fbrdb.LayerConnectionsStartConnectedIdsVector(builder, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		builder.PrependUint64(ids[i])
+	}
+	connectedIDsVector := builder.EndVector(len(ids))
+
+	// Create layer connections
+	fbrdb.LayerConnectionsStart(builder)
+	fbrdb.LayerConnectionsAddLayer(builder, int32(layerConn.Layer))
+	fbrdb.LayerConnectionsAddConnectedIds(builder, connectedIDsVector)
+
+	return fbrdb.LayerConnectionsEnd(builder), nil
+}
+
+// createLegacyLayerConnections stores connected node IDs as strings
+func (r *RDBManager) createLegacyLayerConnections(builder *flatbuffers.Builder, layerConn LayerConnectionsSnapshot) flatbuffers.UOffsetT {
 	var connectedNodeIDs []flatbuffers.UOffsetT
 	for _, nodeID := range layerConn.ConnectedNodeIDs {
 		nodeIDStr := builder.CreateString(nodeID)
@@ -524,12 +740,11 @@ func (r *RDBManager) createLayerConnections(builder *flatbuffers.Builder, layerC
 	}
 	connectedNodeIDsVector := builder.EndVector(len(connectedNodeIDs))
 
-	// Create layer connections
 	fbrdb.LayerConnectionsStart(builder)
 	fbrdb.LayerConnectionsAddLayer(builder, int32(layerConn.Layer))
 	fbrdb.LayerConnectionsAddConnectedNodeIds(builder, connectedNodeIDsVector)
 
-	return fbrdb.LayerConnectionsEnd(builder), nil
+	return fbrdb.LayerConnectionsEnd(builder)
 }
 
 // parseDatabaseSnapshot parses a FlatBuffers DatabaseSnapshot to Go struct
@@ -599,6 +814,29 @@ func (r *RDBManager) parseCollectionSnapshot(fbColl *fbrdb.CollectionSnapshot) (
 		collSnapshot.HNSWGraph = hnswGraph
 	}
 
+	// Parse partitions if present
+	for i := 0; i < fbColl.PartitionsLength(); i++ {
+		fbPartition := new(fbrdb.PartitionSnapshot)
+		if !fbColl.Partitions(fbPartition, i) {
+			return nil, utils.ErrCorruptedData("failed to parse partition")
+		}
+
+		partitionSnapshot := PartitionSnapshot{
+			Name:      string(fbPartition.Name()),
+			Released:  fbPartition.Released(),
+			CreatedAt: time.Unix(fbPartition.CreatedAt(), 0),
+		}
+		if fbGraph := fbPartition.HnswGraph(nil); fbGraph != nil {
+			hnswGraph, err := r.parseHNSWGraph(fbGraph)
+			if err != nil {
+				return nil, err
+			}
+			partitionSnapshot.HNSWGraph = hnswGraph
+		}
+
+		collSnapshot.Partitions = append(collSnapshot.Partitions, partitionSnapshot)
+	}
+
 	return collSnapshot, nil
 }
 
@@ -625,9 +863,11 @@ func (r *RDBManager) parseVector(fbVec *fbrdb.Vector) (*types.Vector, error) {
 	}
 
 	return &types.Vector{
-		ID:       id,
-		Elements: elements,
-		Metadata: metadata,
+		ID:        id,
+		Elements:  elements,
+		Metadata:  metadata,
+		ExpireAt:  fbVec.ExpireAt(),
+		Partition: string(fbVec.Partition()),
 	}, nil
 }
 
@@ -640,9 +880,10 @@ func (r *RDBManager) parseCollectionConfig(fbConfig *fbrdb.CollectionConfig) (*t
 	}
 
 	return &types.CollectionConfig{
-		Name:       string(fbConfig.Name()),
-		Metric:     types.DistanceMetric(fbConfig.Metric()),
-		HNSWParams: *hnswParams,
+		Name:              string(fbConfig.Name()),
+		Metric:            types.DistanceMetric(fbConfig.Metric()),
+		HNSWParams:        *hnswParams,
+		DefaultTTLSeconds: fbConfig.DefaultTtlSeconds(),
 	}, nil
 }
 
@@ -731,7 +972,13 @@ func (r *RDBManager) parseLayerConnections(fbLayerConn *fbrdb.LayerConnections)
 		Layer: int(fbLayerConn.Layer()),
 	}
 
-	// Parse connected node IDs
+	// Parse connected node IDs, stored as strings by older snapshots
+	if n := fbLayerConn.ConnectedIdsLength(); n > 0 {
+		layerConn.ConnectedNodeIDs = make([]string, n)
+		for i := 0; i < n; i++ {
+			layerConn.ConnectedNodeIDs[i] = strconv.FormatUint(fbLayerConn.ConnectedIds(i), 10)
+		}
+	}
 	for i := 0; i < fbLayerConn.ConnectedNodeIdsLength(); i++ {
 		nodeID := string(fbLayerConn.ConnectedNodeIds(i))
 		layerConn.ConnectedNodeIDs = append(layerConn.ConnectedNodeIDs, nodeID)
@@ -742,17 +989,8 @@ func (r *RDBManager) parseLayerConnections(fbLayerConn *fbrdb.LayerConnections)
 
 // validateSnapshot validates the integrity of a loaded snapshot
 func (r *RDBManager) validateSnapshot(snapshot *RDBSnapshot) error {
-	if snapshot.Version == "" {
-		return utils.ErrCorruptedData("RDB snapshot missing version")
-	}
-
-	if snapshot.Timestamp.IsZero() {
-		return utils.ErrCorruptedData("RDB snapshot missing timestamp")
-	}
-
-	// Validate version compatibility
-	if snapshot.Version != "1.0" {
-		return utils.ErrCorruptedData(fmt.Sprintf("unsupported RDB version: %s", snapshot.Version))
+	if err := validateHeader(snapshot); err != nil {
+		return err
 	}
 
 	// Validate databases
@@ -766,21 +1004,48 @@ func (r *RDBManager) validateSnapshot(snapshot *RDBSnapshot) error {
 			if collSnapshot.Name != collName {
 				return utils.ErrCorruptedData(fmt.Sprintf("collection name mismatch: key=%s, name=%s", collName, collSnapshot.Name))
 			}
-
-			if len(collSnapshot.Vectors) != int(collSnapshot.VectorCount) {
-				return utils.ErrCorruptedData(fmt.Sprintf("vector count mismatch in collection %s: expected=%d, actual=%d",
-					collName, collSnapshot.VectorCount, len(collSnapshot.Vectors)))
+			if err := validateCollection(collSnapshot); err != nil {
+				return err
 			}
+		}
+	}
 
-			// Validate vectors have consistent dimensions if any exist
-			if len(collSnapshot.Vectors) > 0 {
-				expectedDim := len(collSnapshot.Vectors[0].Elements)
-				for i, vector := range collSnapshot.Vectors {
-					if len(vector.Elements) != expectedDim {
-						return utils.ErrCorruptedData(fmt.Sprintf("inconsistent vector dimension in collection %s: vector[%d] has %d dimensions, expected %d",
-							collName, i, len(vector.Elements), expectedDim))
-					}
-				}
+	return nil
+}
+
+// validateHeader validates the version and timestamp of a loaded snapshot
+func validateHeader(snapshot *RDBSnapshot) error {
+	if snapshot.Version == "" {
+		return utils.ErrCorruptedData("RDB snapshot missing version")
+	}
+
+	if snapshot.Timestamp.IsZero() {
+		return utils.ErrCorruptedData("RDB snapshot missing timestamp")
+	}
+
+	// Validate version compatibility
+	if snapshot.Version != "1.0" {
+		return utils.ErrCorruptedData(fmt.Sprintf("unsupported RDB version: %s", snapshot.Version))
+	}
+
+	return nil
+}
+
+// validateCollection validates the vectors of a loaded collection
+func validateCollection(collSnapshot CollectionSnapshot) error {
+	collName := collSnapshot.Name
+	if len(collSnapshot.Vectors) != int(collSnapshot.VectorCount) {
+		return utils.ErrCorruptedData(fmt.Sprintf("vector count mismatch in collection %s: expected=%d, actual=%d",
+			collName, collSnapshot.VectorCount, len(collSnapshot.Vectors)))
+	}
+
+	// Validate vectors have consistent dimensions if any exist
+	if len(collSnapshot.Vectors) > 0 {
+		expectedDim := len(collSnapshot.Vectors[0].Elements)
+		for i, vector := range collSnapshot.Vectors {
+			if len(vector.Elements) != expectedDim {
+				return utils.ErrCorruptedData(fmt.Sprintf("inconsistent vector dimension in collection %s: vector[%d] has %d dimensions, expected %d",
+					collName, i, len(vector.Elements), expectedDim))
 			}
 		}
 	}
@@ -811,11 +1076,23 @@ func (r *RDBManager) GetInfo() (*RDBInfo, error) {
 		return nil, utils.ErrPersistenceFailedWithCause("failed to get RDB file info", err)
 	}
 
+	compression, err := DetectCompression(r.filePath)
+	if err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to read RDB header", err)
+	}
+
+	keyID, err := DetectKeyID(r.filePath)
+	if err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to read RDB header", err)
+	}
+
 	return &RDBInfo{
-		Exists:  true,
-		Size:    info.Size(),
-		ModTime: info.ModTime(),
-		Path:    r.filePath,
+		Exists:      true,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Path:        r.filePath,
+		Compression: compression,
+		KeyID:       keyID,
 	}, nil
 }
 
@@ -848,17 +1125,7 @@ func (r *RDBManager) CreateSnapshot(databases map[string]DatabaseState) RDBSnaps
 		}
 
 		for collName, collState := range dbState.Collections {
-			collSnapshot := CollectionSnapshot{
-				Name:         collName,
-				Config:       collState.Config,
-				Vectors:      collState.Vectors,
-				HNSWGraph:    ConvertHNSWGraphState(collState.HNSWGraph), // Convert and include HNSW graph
-				VectorCount:  collState.VectorCount,
-				DeletedCount: collState.DeletedCount,
-				CreatedAt:    collState.CreatedAt,
-				UpdatedAt:    collState.UpdatedAt,
-			}
-			dbSnapshot.Collections[collName] = collSnapshot
+			dbSnapshot.Collections[collName] = collectionSnapshotFromState(collName, collState)
 		}
 
 		snapshot.Databases[dbName] = dbSnapshot
@@ -880,10 +1147,103 @@ func (r *RDBManager) CreateSnapshot(databases map[string]DatabaseState) RDBSnaps
 	return snapshot
 }
 
+// collectionSnapshotFromState converts the exported state of a collection into its snapshot
+func collectionSnapshotFromState(collName string, collState CollectionState) CollectionSnapshot {
+	collSnapshot := CollectionSnapshot{
+		Name:         collName,
+		Config:       collState.Config,
+		Vectors:      collState.Vectors,
+		HNSWGraph:    ConvertHNSWGraphState(collState.HNSWGraph), // Convert and include HNSW graph
+		VectorCount:  collState.VectorCount,
+		DeletedCount: collState.DeletedCount,
+		CreatedAt:    collState.CreatedAt,
+		UpdatedAt:    collState.UpdatedAt,
+	}
+
+	// The default partition graph is already stored in HNSWGraph
+	for _, partitionState := range collState.Partitions {
+		partitionSnapshot := PartitionSnapshot{
+			Name:      partitionState.Name,
+			Released:  partitionState.Released,
+			CreatedAt: partitionState.CreatedAt,
+		}
+		if partitionState.Name != types.DefaultPartitionName {
+			partitionSnapshot.HNSWGraph = ConvertHNSWGraphState(partitionState.HNSWGraph)
+		}
+		collSnapshot.Partitions = append(collSnapshot.Partitions, partitionSnapshot)
+	}
+
+	return collSnapshot
+}
+
+// CollectionStateFromSnapshot converts a collection snapshot back into the state it was
+// created from, for snapshots written again from a stored copy
+func CollectionStateFromSnapshot(collSnapshot CollectionSnapshot) (CollectionState, error) {
+	graph, err := ConvertHNSWGraphSnapshot(collSnapshot.HNSWGraph)
+	if err != nil {
+		return CollectionState{}, err
+	}
+
+	collState := CollectionState{
+		Name:         collSnapshot.Name,
+		Config:       collSnapshot.Config,
+		Vectors:      collSnapshot.Vectors,
+		HNSWGraph:    graph,
+		VectorCount:  collSnapshot.VectorCount,
+		DeletedCount: collSnapshot.DeletedCount,
+		CreatedAt:    collSnapshot.CreatedAt,
+		UpdatedAt:    collSnapshot.UpdatedAt,
+	}
+
+	for _, partitionSnapshot := range collSnapshot.Partitions {
+		partitionState := PartitionState{
+			Name:      partitionSnapshot.Name,
+			Released:  partitionSnapshot.Released,
+			CreatedAt: partitionSnapshot.CreatedAt,
+		}
+		if partitionSnapshot.Name == types.DefaultPartitionName {
+			partitionState.HNSWGraph = graph
+		} else if partitionState.HNSWGraph, err = ConvertHNSWGraphSnapshot(partitionSnapshot.HNSWGraph); err != nil {
+			return CollectionState{}, err
+		}
+		collState.Partitions = append(collState.Partitions, partitionState)
+	}
+
+	return collState, nil
+}
+
+// copyFrom rewrites the snapshot of source into r, in the compression of r, one
+// collection at a time. It reports false if source has no snapshot.
+func (r *RDBManager) copyFrom(ctx context.Context, source *RDBManager) (bool, error) {
+	found := true
+	err := r.save(ctx, func(sw *snapshotWriter) (map[string]interface{}, error) {
+		snapshot, err := source.LoadStream(ctx, sw)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot == nil {
+			found = false
+			return nil, errNoSnapshot
+		}
+		return snapshot.Metadata, nil
+	})
+	if !found {
+		return false, nil
+	}
+	return true, err
+}
+
+// errNoSnapshot aborts copyFrom without writing a file
+var errNoSnapshot = errors.New("no RDB snapshot")
+
+// backupExt is the extension of backup files
+const backupExt = ".flatbuf"
+
 // BackupManager handles RDB backup operations
 type BackupManager struct {
 	rdbManager *RDBManager
 	backupDir  string
+	targets    []backup.Target // Backups are copied to these, see targets.go
 }
 
 // NewBackupManager creates a new backup manager
@@ -900,34 +1260,37 @@ func NewBackupManager(rdbManager *RDBManager, backupDir string) (*BackupManager,
 
 // CreateBackup creates a timestamped backup of the current RDB file
 func (bm *BackupManager) CreateBackup(ctx context.Context) (string, error) {
-	// Load current snapshot
-	snapshot, err := bm.rdbManager.Load(ctx)
-	if err != nil {
-		return "", err
-	}
-	if snapshot == nil {
-		return "", utils.ErrPersistenceFailed("no RDB snapshot to backup")
-	}
-
-	// Create backup filename with timestamp
+	// Create backup filename with timestamp, numbered if there already is one of that second
 	timestamp := time.Now().Format("20060102_150405")
-	backupFilename := fmt.Sprintf("rdb_backup_%s.flatbuf", timestamp)
+	backupFilename := fmt.Sprintf("rdb_backup_%s%s", timestamp, backupExt)
 	backupPath := filepath.Join(bm.backupDir, backupFilename)
+	for n := 2; ; n++ {
+		if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+			break
+		}
+		backupFilename = fmt.Sprintf("rdb_backup_%s_%d%s", timestamp, n, backupExt)
+		backupPath = filepath.Join(bm.backupDir, backupFilename)
+	}
 
-	// Save backup using FlatBuffers format
-	tempManager, err := NewRDBManager(backupPath)
+	// Copy the snapshot using FlatBuffers format, compressed and encrypted like the snapshot
+	tempManager, err := NewRDBManagerWithCompression(backupPath, bm.rdbManager.compression)
 	if err != nil {
 		return "", err
 	}
+	tempManager.SetEncryption(bm.rdbManager.Encryption())
 
-	if err := tempManager.Save(ctx, *snapshot); err != nil {
+	found, err := tempManager.copyFrom(ctx, bm.rdbManager)
+	if err != nil {
 		return "", err
 	}
+	if !found {
+		return "", utils.ErrPersistenceFailed("no RDB snapshot to backup")
+	}
 
 	return backupPath, nil
 }
 
-// ListBackups returns a list of available backups
+// ListBackups returns a list of available backups, newest first
 func (bm *BackupManager) ListBackups() ([]BackupInfo, error) {
 	entries, err := os.ReadDir(bm.backupDir)
 	if err != nil {
@@ -940,7 +1303,7 @@ func (bm *BackupManager) ListBackups() ([]BackupInfo, error) {
 			continue
 		}
 
-		if filepath.Ext(entry.Name()) == ".flatbuf" {
+		if filepath.Ext(entry.Name()) == backupExt {
 			info, err := entry.Info()
 			if err != nil {
 				continue
@@ -955,9 +1318,40 @@ func (bm *BackupManager) ListBackups() ([]BackupInfo, error) {
 		}
 	}
 
+	sort.SliceStable(backups, func(i, j int) bool { return backups[i].ModTime.After(backups[j].ModTime) })
 	return backups, nil
 }
 
+// GetBackup returns the backup with the given file name
+func (bm *BackupManager) GetBackup(name string) (BackupInfo, error) {
+	if name == "" || filepath.Base(name) != name || filepath.Ext(name) != backupExt {
+		return BackupInfo{}, utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	path := filepath.Join(bm.backupDir, name)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return BackupInfo{}, utils.ErrBackupNotFound(name)
+	} else if err != nil {
+		return BackupInfo{}, utils.ErrPersistenceFailedWithCause("failed to read backup", err)
+	}
+
+	return BackupInfo{Name: name, Path: path, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// DeleteBackup removes the backup with the given file name
+func (bm *BackupManager) DeleteBackup(name string) error {
+	backup, err := bm.GetBackup(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(backup.Path); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to delete backup", err)
+	}
+	return nil
+}
+
 // RestoreFromBackup restores the database from a backup file
 func (bm *BackupManager) RestoreFromBackup(ctx context.Context, backupPath string) error {
 	// Load backup
@@ -965,17 +1359,17 @@ func (bm *BackupManager) RestoreFromBackup(ctx context.Context, backupPath strin
 	if err != nil {
 		return err
 	}
+	backupManager.SetEncryption(bm.rdbManager.Encryption())
 
-	snapshot, err := backupManager.Load(ctx)
+	// Save as current RDB
+	found, err := bm.rdbManager.copyFrom(ctx, backupManager)
 	if err != nil {
 		return err
 	}
-	if snapshot == nil {
+	if !found {
 		return utils.ErrRecoveryFailed("backup file is empty")
 	}
-
-	// Save as current RDB
-	return bm.rdbManager.Save(ctx, *snapshot)
+	return nil
 }
 
 // ConvertHNSWGraphState converts core.HNSWGraphState to RDB HNSWGraphSnapshot
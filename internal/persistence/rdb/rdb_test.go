@@ -1,11 +1,18 @@
 package rdb
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/scintirete/scintirete/internal/core"
+	fbrdb "github.com/scintirete/scintirete/internal/flatbuffers/rdb"
 	"github.com/scintirete/scintirete/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -260,3 +267,295 @@ func TestRDBManager_CreateSnapshot(t *testing.T) {
 	assert.Equal(t, uint64(1), vector.ID)
 	assert.Equal(t, []float32{0.1, 0.2, 0.3}, vector.Elements)
 }
+
+// graphTestSnapshot returns a snapshot with a small HNSW graph of n nodes
+func graphTestSnapshot(n int) RDBSnapshot {
+	graph := &HNSWGraphSnapshot{EntryPointID: "1", Size: n}
+	for i := 1; i <= n; i++ {
+		node := HNSWNodeSnapshot{
+			ID:       strconv.Itoa(i),
+			Elements: []float32{float32(i % 7), float32(i % 3), 0.5},
+			LayerConnections: []LayerConnectionsSnapshot{
+				{Layer: 0, ConnectedNodeIDs: []string{strconv.Itoa(i%n + 1), strconv.Itoa((i+1)%n + 1)}},
+			},
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	return RDBSnapshot{
+		Databases: map[string]DatabaseSnapshot{
+			"db": {
+				Name: "db",
+				Collections: map[string]CollectionSnapshot{
+					"coll": {
+						Name:      "coll",
+						Config:    types.CollectionConfig{Name: "coll", Metric: types.DistanceMetricL2},
+						HNSWGraph: graph,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRDBManager_Compression(t *testing.T) {
+	ctx := context.Background()
+	snapshot := graphTestSnapshot(2000)
+
+	sizes := make(map[Compression]int64)
+	for _, compression := range []Compression{CompressionNone, CompressionZstd, CompressionLZ4} {
+		t.Run(string(compression), func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "test.rdb")
+			manager, err := NewRDBManagerWithCompression(filePath, compression)
+			require.NoError(t, err)
+			require.NoError(t, manager.Save(ctx, snapshot))
+
+			info, err := manager.GetInfo()
+			require.NoError(t, err)
+			sizes[compression] = info.Size
+
+			// Any manager detects the compression from the header
+			reader, err := NewRDBManager(filePath)
+			require.NoError(t, err)
+			loaded, err := reader.Load(ctx)
+			require.NoError(t, err)
+			require.NotNil(t, loaded)
+
+			graph := loaded.Databases["db"].Collections["coll"].HNSWGraph
+			require.NotNil(t, graph)
+			assert.Equal(t, snapshot.Databases["db"].Collections["coll"].HNSWGraph.Nodes, graph.Nodes)
+		})
+	}
+
+	assert.Less(t, sizes[CompressionZstd], sizes[CompressionNone])
+	assert.Less(t, sizes[CompressionLZ4], sizes[CompressionNone])
+
+	_, err := NewRDBManagerWithCompression(filepath.Join(t.TempDir(), "test.rdb"), "gzip")
+	assert.Error(t, err)
+}
+
+// marshalSnapshot builds the single FlatBuffers snapshot written before the chunked format
+func marshalSnapshot(t *testing.T, r *RDBManager, snapshot RDBSnapshot) []byte {
+	t.Helper()
+	builder := flatbuffers.NewBuilder(0)
+
+	var dbOffsets []flatbuffers.UOffsetT
+	for _, dbSnapshot := range snapshot.Databases {
+		dbOffset, err := r.createDatabaseSnapshot(builder, dbSnapshot)
+		require.NoError(t, err)
+		dbOffsets = append(dbOffsets, dbOffset)
+	}
+	fbrdb.RDBSnapshotStartDatabasesVector(builder, len(dbOffsets))
+	databasesVector := prependOffsets(builder, dbOffsets)
+	versionStr := builder.CreateString("1.0")
+	metadataStr := builder.CreateString("{}")
+
+	fbrdb.RDBSnapshotStart(builder)
+	fbrdb.RDBSnapshotAddVersion(builder, versionStr)
+	fbrdb.RDBSnapshotAddTimestamp(builder, time.Now().Unix())
+	fbrdb.RDBSnapshotAddDatabases(builder, databasesVector)
+	fbrdb.RDBSnapshotAddMetadata(builder, metadataStr)
+	builder.Finish(fbrdb.RDBSnapshotEnd(builder))
+	return builder.FinishedBytes()
+}
+
+func TestRDBManager_LegacyFormat(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "test.rdb")
+	manager, err := NewRDBManager(filePath)
+	require.NoError(t, err)
+
+	// IDs that aren't integers fall back to strings
+	snapshot := graphTestSnapshot(3)
+	graph := snapshot.Databases["db"].Collections["coll"].HNSWGraph
+	graph.Nodes[0].LayerConnections[0].ConnectedNodeIDs = []string{"a", "2"}
+	require.NoError(t, manager.Save(ctx, snapshot))
+	loaded, err := manager.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, graph.Nodes, loaded.Databases["db"].Collections["coll"].HNSWGraph.Nodes)
+
+	// Snapshots written before the header existed are the bare FlatBuffers data
+	data := marshalSnapshot(t, manager, snapshot)
+	require.NoError(t, os.WriteFile(filePath, data, 0644))
+
+	loaded, err = manager.Load(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	loadedGraph := loaded.Databases["db"].Collections["coll"].HNSWGraph
+	assert.Equal(t, []string{"a", "2"}, loadedGraph.Nodes[0].LayerConnections[0].ConnectedNodeIDs)
+	assert.Equal(t, graph.Nodes[1].LayerConnections, loadedGraph.Nodes[1].LayerConnections)
+
+	// Format version 1 files hold the same data compressed as a whole
+	var buf bytes.Buffer
+	require.NoError(t, encodeSnapshotFile(&buf, data, CompressionZstd))
+	require.NoError(t, os.WriteFile(filePath, buf.Bytes(), 0644))
+
+	loaded, err = manager.Load(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, graph.Nodes, loaded.Databases["db"].Collections["coll"].HNSWGraph.Nodes)
+}
+
+// chunkedTestSnapshot returns a snapshot whose collection spans several chunks
+func chunkedTestSnapshot() RDBSnapshot {
+	snapshot := graphTestSnapshot(700)
+	coll := snapshot.Databases["db"].Collections["coll"]
+	for i := range coll.HNSWGraph.Nodes {
+		elements := make([]float32, 2048) // 8KB per node, so the graph needs two chunks
+		elements[i%2048] = float32(i)
+		coll.HNSWGraph.Nodes[i].Elements = elements
+		coll.Vectors = append(coll.Vectors, types.Vector{ID: uint64(i + 1), Elements: elements[:4]})
+	}
+	coll.VectorCount = int64(len(coll.Vectors))
+	coll.CreatedAt = time.Unix(10, 0)
+	coll.UpdatedAt = time.Unix(20, 0)
+
+	extra := graphTestSnapshot(5).Databases["db"].Collections["coll"].HNSWGraph
+	coll.Partitions = []PartitionSnapshot{
+		{Name: types.DefaultPartitionName, CreatedAt: time.Unix(100, 0)},
+		{Name: "extra", HNSWGraph: extra, CreatedAt: time.Unix(200, 0)},
+		{Name: "released", Released: true, CreatedAt: time.Unix(300, 0)},
+	}
+	snapshot.Databases["db"] = DatabaseSnapshot{
+		Name:        "db",
+		Collections: map[string]CollectionSnapshot{"coll": coll},
+		CreatedAt:   time.Unix(1, 0),
+	}
+	snapshot.Databases["empty"] = DatabaseSnapshot{Name: "empty", Collections: map[string]CollectionSnapshot{}, CreatedAt: time.Unix(2, 0)}
+	return snapshot
+}
+
+// visitRecorder records the order of visits
+type visitRecorder struct {
+	visits []string
+}
+
+func (v *visitRecorder) VisitDatabase(db DatabaseSnapshot) error {
+	v.visits = append(v.visits, db.Name)
+	return nil
+}
+
+func (v *visitRecorder) VisitCollection(dbName string, coll CollectionSnapshot) error {
+	v.visits = append(v.visits, dbName+"/"+coll.Name)
+	return nil
+}
+
+func TestRDBManager_Chunks(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "test.rdb")
+	manager, err := NewRDBManagerWithCompression(filePath, CompressionLZ4)
+	require.NoError(t, err)
+
+	snapshot := chunkedTestSnapshot()
+	require.NoError(t, manager.Save(ctx, snapshot))
+
+	loaded, err := manager.Load(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, snapshot.Databases, loaded.Databases)
+	assert.EqualValues(t, 2, loaded.Metadata["total_databases"])
+	assert.EqualValues(t, 700, loaded.Metadata["total_vectors"])
+
+	// Databases come before their collections
+	recorder := &visitRecorder{}
+	header, err := manager.LoadStream(ctx, recorder)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", header.Version)
+	assert.Nil(t, header.Databases)
+	assert.Equal(t, []string{"db", "db/coll", "empty"}, recorder.visits)
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+
+	// A file cut off anywhere fails to load, including right before the end chunk
+	for _, size := range []int{rdbHeaderSize, len(data) / 2, len(data) - 1} {
+		require.NoError(t, os.WriteFile(filePath, data[:size], 0644))
+		_, err := manager.Load(ctx)
+		assert.Error(t, err, "size %d", size)
+	}
+
+	// Damaged chunks fail their checksum
+	damaged := bytes.Clone(data)
+	damaged[len(damaged)/3] ^= 0xff
+	require.NoError(t, os.WriteFile(filePath, damaged, 0644))
+	_, err = manager.Load(ctx)
+	assert.Error(t, err)
+}
+
+// stateSource streams fixed database state
+type stateSource map[string]DatabaseState
+
+func (s stateSource) StreamDatabaseState(ctx context.Context, visitor StateVisitor) error {
+	for _, dbState := range s {
+		if err := visitor.VisitDatabase(DatabaseState{Name: dbState.Name, CreatedAt: dbState.CreatedAt}); err != nil {
+			return err
+		}
+		for _, collState := range dbState.Collections {
+			if err := visitor.VisitCollection(dbState.Name, collState); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestRDBManager_SaveStream(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// A single node, since graph states don't order their nodes
+	graph := &core.HNSWGraphState{
+		Nodes:      map[uint64]*core.HNSWNodeState{1: {ID: 1, Vector: []float32{1, 0}, Connections: [][]uint64{{}, {}}}},
+		EntryPoint: 1,
+		MaxLayer:   1,
+		Size:       1,
+	}
+	state := stateSource{
+		"db": {
+			Name:      "db",
+			CreatedAt: time.Unix(1000, 0),
+			Collections: map[string]CollectionState{
+				"coll": {
+					Name:        "coll",
+					Config:      types.CollectionConfig{Name: "coll", Metric: types.DistanceMetricCosine},
+					Vectors:     []types.Vector{{ID: 1, Elements: []float32{1, 0}}},
+					HNSWGraph:   graph,
+					VectorCount: 1,
+					Partitions:  []PartitionState{{Name: types.DefaultPartitionName, HNSWGraph: graph}},
+				},
+			},
+		},
+	}
+
+	streamed, err := NewRDBManagerWithCompression(filepath.Join(dir, "streamed.rdb"), CompressionZstd)
+	require.NoError(t, err)
+	require.NoError(t, streamed.SaveStream(ctx, state))
+
+	// Streaming writes the same snapshot as converting the state up front
+	whole, err := NewRDBManager(filepath.Join(dir, "whole.rdb"))
+	require.NoError(t, err)
+	require.NoError(t, whole.Save(ctx, whole.CreateSnapshot(state)))
+
+	streamedSnapshot, err := streamed.Load(ctx)
+	require.NoError(t, err)
+	wholeSnapshot, err := whole.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, wholeSnapshot.Databases, streamedSnapshot.Databases)
+	assert.Equal(t, wholeSnapshot.Metadata, streamedSnapshot.Metadata)
+
+	// A failing source leaves the previous snapshot in place
+	assert.Error(t, streamed.SaveStream(ctx, failingSource{}))
+	reloaded, err := streamed.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, streamedSnapshot.Databases, reloaded.Databases)
+}
+
+// failingSource fails after the first database
+type failingSource struct{}
+
+func (failingSource) StreamDatabaseState(ctx context.Context, visitor StateVisitor) error {
+	if err := visitor.VisitDatabase(DatabaseState{Name: "other"}); err != nil {
+		return err
+	}
+	return errors.New("export failed")
+}
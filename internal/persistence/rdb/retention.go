@@ -0,0 +1,93 @@
+package rdb
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// RetentionPolicy decides which backups Prune keeps. A backup is kept as soon as one of
+// the rules keeps it; with no rule set every backup is kept.
+type RetentionPolicy struct {
+	KeepLast   int // Number of most recent backups to keep
+	KeepDaily  int // Number of most recent days to keep the newest backup of
+	KeepWeekly int // Number of most recent ISO weeks to keep the newest backup of
+}
+
+// IsZero reports whether the policy keeps every backup
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0
+}
+
+// Select splits backups into the ones the policy keeps and the ones it drops. Days and
+// weeks are those of the backup times in their own location, and only days and weeks
+// that have a backup count.
+func (p RetentionPolicy) Select(backups []BackupInfo) (keep, drop []BackupInfo) {
+	sorted := append([]BackupInfo(nil), backups...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ModTime.After(sorted[j].ModTime) })
+	if p.IsZero() {
+		return sorted, nil
+	}
+
+	kept := make([]bool, len(sorted))
+	for i := 0; i < len(sorted) && i < p.KeepLast; i++ {
+		kept[i] = true
+	}
+	keepNewestPer(sorted, kept, p.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPer(sorted, kept, p.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	for i, backup := range sorted {
+		if kept[i] {
+			keep = append(keep, backup)
+		} else {
+			drop = append(drop, backup)
+		}
+	}
+	return keep, drop
+}
+
+// keepNewestPer marks the newest backup of each of the n most recent periods. backups
+// are sorted newest first.
+func keepNewestPer(backups []BackupInfo, kept []bool, n int, period func(time.Time) string) {
+	seen := make(map[string]bool)
+	for i, backup := range backups {
+		if len(seen) >= n {
+			return
+		}
+		key := period(backup.ModTime)
+		if !seen[key] {
+			seen[key] = true
+			kept[i] = true
+		}
+	}
+}
+
+// Prune deletes the backups policy does not keep and returns them
+func (bm *BackupManager) Prune(policy RetentionPolicy) ([]BackupInfo, error) {
+	if policy.IsZero() {
+		return nil, nil
+	}
+
+	backups, err := bm.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	_, drop := policy.Select(backups)
+	var removed []BackupInfo
+	for _, backup := range drop {
+		if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+			return removed, utils.ErrPersistenceFailedWithCause("failed to delete backup "+backup.Name, err)
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
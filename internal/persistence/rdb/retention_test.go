@@ -0,0 +1,85 @@
+package rdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionPolicy_Select(t *testing.T) {
+	// One backup every 12 hours over four weeks, newest first
+	start := time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC) // A Sunday
+	var backups []BackupInfo
+	for i := 0; i < 56; i++ {
+		backups = append(backups, BackupInfo{Name: start.Add(-time.Duration(i) * 12 * time.Hour).Format(time.RFC3339), ModTime: start.Add(-time.Duration(i) * 12 * time.Hour)})
+	}
+	names := func(backups []BackupInfo) []string {
+		var names []string
+		for _, backup := range backups {
+			names = append(names, backup.Name)
+		}
+		return names
+	}
+
+	keep, drop := RetentionPolicy{}.Select(backups)
+	assert.Len(t, keep, 56)
+	assert.Empty(t, drop)
+
+	keep, _ = RetentionPolicy{KeepLast: 3}.Select(backups)
+	assert.Equal(t, []string{"2026-10-18T20:00:00Z", "2026-10-18T08:00:00Z", "2026-10-17T20:00:00Z"}, names(keep))
+
+	// The newest backup of each day
+	keep, _ = RetentionPolicy{KeepDaily: 2}.Select(backups)
+	assert.Equal(t, []string{"2026-10-18T20:00:00Z", "2026-10-17T20:00:00Z"}, names(keep))
+
+	// The newest backup of each ISO week, which ends on Sunday
+	keep, _ = RetentionPolicy{KeepWeekly: 3}.Select(backups)
+	assert.Equal(t, []string{"2026-10-18T20:00:00Z", "2026-10-11T20:00:00Z", "2026-10-04T20:00:00Z"}, names(keep))
+
+	// Rules add up, a backup kept by several rules counts once
+	keep, drop = RetentionPolicy{KeepLast: 2, KeepDaily: 2, KeepWeekly: 2}.Select(backups)
+	assert.Equal(t, []string{"2026-10-18T20:00:00Z", "2026-10-18T08:00:00Z", "2026-10-17T20:00:00Z", "2026-10-11T20:00:00Z"}, names(keep))
+	assert.Len(t, drop, 52)
+}
+
+func TestBackupManager_PruneAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := NewRDBManager(filepath.Join(dir, "test.rdb"))
+	require.NoError(t, err)
+	require.NoError(t, manager.Save(context.Background(), manager.CreateSnapshot(nil)))
+
+	backups, err := NewBackupManager(manager, filepath.Join(dir, "backups"))
+	require.NoError(t, err)
+	var created []string
+	for i := 0; i < 3; i++ {
+		path, err := backups.CreateBackup(context.Background())
+		require.NoError(t, err)
+		created = append(created, filepath.Base(path))
+		// Backups of the same second get distinct names, the times tell their order
+		require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Duration(i)*time.Minute)))
+	}
+	assert.Len(t, created, 3)
+	assert.NotEqual(t, created[0], created[1])
+
+	removed, err := backups.Prune(RetentionPolicy{KeepLast: 2})
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, created[0], removed[0].Name)
+
+	list, err := backups.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, created[2], list[0].Name)
+
+	require.NoError(t, backups.DeleteBackup(created[2]))
+	assert.Error(t, backups.DeleteBackup(created[2]))
+	for _, invalid := range []string{"", "../test.rdb", "temp", "test.rdb"} {
+		assert.Error(t, backups.DeleteBackup(invalid), invalid)
+	}
+	assert.FileExists(t, filepath.Join(dir, "test.rdb"))
+}
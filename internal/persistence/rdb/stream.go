@@ -0,0 +1,583 @@
+package rdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	fbrdb "github.com/scintirete/scintirete/internal/flatbuffers/rdb"
+	"github.com/scintirete/scintirete/internal/persistence/encryption"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// Snapshots are streamed as a DATABASE chunk per database, followed by a COLLECTION
+// chunk per collection with its vectors and graph nodes in VECTORS and NODES chunks of
+// about rdbChunkSize bytes, and a final END chunk. Saving and loading therefore only
+// hold a single collection in memory besides the live data.
+const (
+	rdbChunkSize     = rdbBlockSize
+	rdbChunkFrame    = 12                // Uncompressed length, stored length and CRC32C
+	rdbMaxChunkBytes = 256 * 1024 * 1024 // Far above any chunk the writer produces
+)
+
+var chunkCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// StateVisitor receives database state one database or collection at a time
+type StateVisitor interface {
+	// VisitDatabase is called for each database before its collections. Collections is empty.
+	VisitDatabase(db DatabaseState) error
+	// VisitCollection is called for each collection of the database visited last
+	VisitCollection(dbName string, coll CollectionState) error
+}
+
+// StateSource exports database state one collection at a time
+type StateSource interface {
+	StreamDatabaseState(ctx context.Context, visitor StateVisitor) error
+}
+
+// SnapshotCut is database state frozen at a single point in time. It can be streamed
+// once while the live state keeps changing and must be released afterwards.
+type SnapshotCut interface {
+	StateSource
+	// Release frees the state kept for the cut
+	Release()
+}
+
+// SnapshotVisitor receives a snapshot one database or collection at a time
+type SnapshotVisitor interface {
+	// VisitDatabase is called for each database before its collections. Collections is empty.
+	VisitDatabase(db DatabaseSnapshot) error
+	// VisitCollection is called for each collection of the database visited last
+	VisitCollection(dbName string, coll CollectionSnapshot) error
+}
+
+// Walk passes the databases and collections of the snapshot to visitor in name order
+func (s *RDBSnapshot) Walk(visitor SnapshotVisitor) error {
+	for _, dbName := range slices.Sorted(maps.Keys(s.Databases)) {
+		dbSnapshot := s.Databases[dbName]
+		if err := visitor.VisitDatabase(DatabaseSnapshot{
+			Name:        dbSnapshot.Name,
+			Collections: make(map[string]CollectionSnapshot),
+			CreatedAt:   dbSnapshot.CreatedAt,
+		}); err != nil {
+			return err
+		}
+
+		for _, collName := range slices.Sorted(maps.Keys(dbSnapshot.Collections)) {
+			if err := visitor.VisitCollection(dbSnapshot.Name, dbSnapshot.Collections[collName]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotAssembler collects streamed databases and collections into a whole snapshot
+type snapshotAssembler struct {
+	databases map[string]DatabaseSnapshot
+}
+
+func (a *snapshotAssembler) VisitDatabase(db DatabaseSnapshot) error {
+	a.databases[db.Name] = db
+	return nil
+}
+
+func (a *snapshotAssembler) VisitCollection(dbName string, coll CollectionSnapshot) error {
+	a.databases[dbName].Collections[coll.Name] = coll
+	return nil
+}
+
+// stateWriter converts exported state into snapshots as it passes them to the writer
+type stateWriter struct {
+	sw *snapshotWriter
+}
+
+func (s *stateWriter) VisitDatabase(db DatabaseState) error {
+	return s.sw.VisitDatabase(DatabaseSnapshot{Name: db.Name, CreatedAt: db.CreatedAt})
+}
+
+func (s *stateWriter) VisitCollection(dbName string, coll CollectionState) error {
+	return s.sw.VisitCollection(dbName, collectionSnapshotFromState(coll.Name, coll))
+}
+
+// snapshotWriter writes a snapshot in the chunked format. It is a SnapshotVisitor,
+// so databases and collections are written as they are visited.
+type snapshotWriter struct {
+	r       *RDBManager
+	w       *bufio.Writer
+	codec   *blockCodec
+	builder *flatbuffers.Builder
+	frame   []byte
+	key     *encryption.FileKey // nil for unencrypted files
+	sealed  []byte              // Reused between chunks
+	index   uint64              // Chunks written so far
+
+	database    string // Database visited last
+	databases   int
+	collections int
+	vectors     int64
+}
+
+// newSnapshotWriter writes the file header and returns a writer for the chunks. If r has
+// encryption keys, the file is encrypted with a new data key.
+func newSnapshotWriter(ctx context.Context, r *RDBManager, w io.Writer) (*snapshotWriter, error) {
+	sw := &snapshotWriter{
+		r:       r,
+		w:       bufio.NewWriter(w),
+		codec:   &blockCodec{compression: r.compression},
+		builder: flatbuffers.NewBuilder(0),
+		frame:   make([]byte, rdbChunkFrame),
+	}
+
+	version := rdbFormatChunked
+	if r.keys != nil {
+		key, err := encryption.NewFileKey(ctx, r.keys)
+		if err != nil {
+			return nil, err
+		}
+		sw.key = key
+		version = rdbFormatEncrypted
+	}
+
+	if err := writeHeader(sw.w, version, r.compression, 0); err != nil {
+		return nil, utils.ErrPersistenceFailedWithCause("failed to write RDB header", err)
+	}
+	if sw.key != nil {
+		if _, err := sw.w.Write(sw.key.Envelope().Marshal()); err != nil {
+			return nil, utils.ErrPersistenceFailedWithCause("failed to write RDB header", err)
+		}
+	}
+	return sw, nil
+}
+
+// VisitDatabase writes the chunk that starts a database
+func (sw *snapshotWriter) VisitDatabase(db DatabaseSnapshot) error {
+	db.Collections = nil
+	dbOffset, err := sw.r.createDatabaseSnapshot(sw.builder, db)
+	if err != nil {
+		return err
+	}
+
+	fbrdb.SnapshotChunkStart(sw.builder)
+	fbrdb.SnapshotChunkAddKind(sw.builder, fbrdb.ChunkKindDATABASE)
+	fbrdb.SnapshotChunkAddDatabase(sw.builder, dbOffset)
+	if err := sw.writeChunk(fbrdb.SnapshotChunkEnd(sw.builder)); err != nil {
+		return err
+	}
+
+	sw.database = db.Name
+	sw.databases++
+	return nil
+}
+
+// VisitCollection writes the chunks of a collection of the database visited last
+func (sw *snapshotWriter) VisitCollection(dbName string, coll CollectionSnapshot) error {
+	if dbName != sw.database {
+		return utils.ErrPersistenceFailed(fmt.Sprintf("collection %s of database %s written outside its database", coll.Name, dbName))
+	}
+
+	// The header carries everything but the vectors and graph nodes
+	header := coll
+	header.Vectors = nil
+	header.HNSWGraph = graphHeader(coll.HNSWGraph)
+	header.Partitions = make([]PartitionSnapshot, len(coll.Partitions))
+	for i, partition := range coll.Partitions {
+		partition.HNSWGraph = graphHeader(partition.HNSWGraph)
+		header.Partitions[i] = partition
+	}
+
+	collOffset, err := sw.r.createCollectionSnapshot(sw.builder, header)
+	if err != nil {
+		return err
+	}
+	fbrdb.SnapshotChunkStart(sw.builder)
+	fbrdb.SnapshotChunkAddKind(sw.builder, fbrdb.ChunkKindCOLLECTION)
+	fbrdb.SnapshotChunkAddCollection(sw.builder, collOffset)
+	if err := sw.writeChunk(fbrdb.SnapshotChunkEnd(sw.builder)); err != nil {
+		return err
+	}
+
+	if err := sw.writeVectors(coll.Vectors); err != nil {
+		return err
+	}
+	if coll.HNSWGraph != nil {
+		if err := sw.writeNodes("", coll.HNSWGraph.Nodes); err != nil {
+			return err
+		}
+	}
+	for _, partition := range coll.Partitions {
+		if partition.HNSWGraph != nil {
+			if err := sw.writeNodes(partition.Name, partition.HNSWGraph.Nodes); err != nil {
+				return err
+			}
+		}
+	}
+
+	sw.collections++
+	sw.vectors += coll.VectorCount
+	return nil
+}
+
+// graphHeader returns a copy of graph without its nodes
+func graphHeader(graph *HNSWGraphSnapshot) *HNSWGraphSnapshot {
+	if graph == nil {
+		return nil
+	}
+	header := *graph
+	header.Nodes = nil
+	return &header
+}
+
+// writeVectors writes vectors in chunks of about rdbChunkSize bytes
+func (sw *snapshotWriter) writeVectors(vectors []types.Vector) error {
+	var offsets []flatbuffers.UOffsetT
+	for i := range vectors {
+		offset, err := sw.r.createVector(sw.builder, vectors[i])
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, offset)
+		if sw.builder.Offset() < rdbChunkSize && i < len(vectors)-1 {
+			continue
+		}
+
+		fbrdb.SnapshotChunkStartVectorsVector(sw.builder, len(offsets))
+		vectorsVector := prependOffsets(sw.builder, offsets)
+		fbrdb.SnapshotChunkStart(sw.builder)
+		fbrdb.SnapshotChunkAddKind(sw.builder, fbrdb.ChunkKindVECTORS)
+		fbrdb.SnapshotChunkAddVectors(sw.builder, vectorsVector)
+		if err := sw.writeChunk(fbrdb.SnapshotChunkEnd(sw.builder)); err != nil {
+			return err
+		}
+		offsets = offsets[:0]
+	}
+	return nil
+}
+
+// writeNodes writes the nodes of a partition's graph in chunks of about rdbChunkSize bytes.
+// An empty partition name refers to the graph in CollectionSnapshot.HNSWGraph.
+func (sw *snapshotWriter) writeNodes(partition string, nodes []HNSWNodeSnapshot) error {
+	var offsets []flatbuffers.UOffsetT
+	for i := range nodes {
+		offset, err := sw.r.createHNSWNode(sw.builder, nodes[i])
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, offset)
+		if sw.builder.Offset() < rdbChunkSize && i < len(nodes)-1 {
+			continue
+		}
+
+		fbrdb.SnapshotChunkStartNodesVector(sw.builder, len(offsets))
+		nodesVector := prependOffsets(sw.builder, offsets)
+		var partitionStr flatbuffers.UOffsetT
+		if partition != "" {
+			partitionStr = sw.builder.CreateString(partition)
+		}
+		fbrdb.SnapshotChunkStart(sw.builder)
+		fbrdb.SnapshotChunkAddKind(sw.builder, fbrdb.ChunkKindNODES)
+		fbrdb.SnapshotChunkAddNodes(sw.builder, nodesVector)
+		if partition != "" {
+			fbrdb.SnapshotChunkAddPartition(sw.builder, partitionStr)
+		}
+		if err := sw.writeChunk(fbrdb.SnapshotChunkEnd(sw.builder)); err != nil {
+			return err
+		}
+		offsets = offsets[:0]
+	}
+	return nil
+}
+
+// prependOffsets fills a started vector with offsets and ends it
+func prependOffsets(builder *flatbuffers.Builder, offsets []flatbuffers.UOffsetT) flatbuffers.UOffsetT {
+	for i := len(offsets) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(offsets[i])
+	}
+	return builder.EndVector(len(offsets))
+}
+
+// finish writes the END chunk with the snapshot's version, timestamp and metadata,
+// adding the totals of what was written, and flushes the file
+func (sw *snapshotWriter) finish(snapshot RDBSnapshot) error {
+	metadata := make(map[string]interface{}, len(snapshot.Metadata)+3)
+	maps.Copy(metadata, snapshot.Metadata)
+	metadata["total_databases"] = sw.databases
+	metadata["total_collections"] = sw.collections
+	metadata["total_vectors"] = sw.vectors
+
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to marshal metadata", err)
+	}
+
+	fbrdb.RDBSnapshotStartDatabasesVector(sw.builder, 0)
+	databasesVector := sw.builder.EndVector(0)
+	versionStr := sw.builder.CreateString(snapshot.Version)
+	metadataStr := sw.builder.CreateString(string(metadataBytes))
+
+	fbrdb.RDBSnapshotStart(sw.builder)
+	fbrdb.RDBSnapshotAddVersion(sw.builder, versionStr)
+	fbrdb.RDBSnapshotAddTimestamp(sw.builder, snapshot.Timestamp.Unix())
+	fbrdb.RDBSnapshotAddDatabases(sw.builder, databasesVector)
+	fbrdb.RDBSnapshotAddMetadata(sw.builder, metadataStr)
+	snapshotOffset := fbrdb.RDBSnapshotEnd(sw.builder)
+
+	fbrdb.SnapshotChunkStart(sw.builder)
+	fbrdb.SnapshotChunkAddKind(sw.builder, fbrdb.ChunkKindEND)
+	fbrdb.SnapshotChunkAddSnapshot(sw.builder, snapshotOffset)
+	if err := sw.writeChunk(fbrdb.SnapshotChunkEnd(sw.builder)); err != nil {
+		return err
+	}
+
+	if err := sw.w.Flush(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to write RDB chunk", err)
+	}
+	return nil
+}
+
+// writeChunk finishes the chunk in the builder, compresses, encrypts and frames it, and
+// resets the builder
+func (sw *snapshotWriter) writeChunk(chunk flatbuffers.UOffsetT) error {
+	sw.builder.Finish(chunk)
+	data := sw.builder.FinishedBytes()
+
+	stored, err := sw.codec.compress(data)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to compress RDB chunk", err)
+	}
+	if sw.key != nil {
+		sw.sealed = sw.key.SealAt(sw.sealed[:0], sw.index, stored)
+		stored = sw.sealed
+	}
+	sw.index++
+
+	binary.LittleEndian.PutUint32(sw.frame, uint32(len(data)))
+	binary.LittleEndian.PutUint32(sw.frame[4:], uint32(len(stored)))
+	binary.LittleEndian.PutUint32(sw.frame[8:], crc32.Checksum(stored, chunkCRCTable))
+	if _, err := sw.w.Write(sw.frame); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to write RDB chunk", err)
+	}
+	if _, err := sw.w.Write(stored); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to write RDB chunk", err)
+	}
+
+	sw.builder.Reset()
+	return nil
+}
+
+// close releases the compressor
+func (sw *snapshotWriter) close() {
+	sw.codec.close()
+}
+
+// snapshotReader reads the chunks of a format version 2 or 3 RDB file
+type snapshotReader struct {
+	r      io.Reader
+	codec  *blockCodec
+	key    *encryption.FileKey // nil for unencrypted files
+	index  uint64              // Chunks read so far
+	frame  []byte
+	stored []byte // Reused between chunks
+	opened []byte // Reused between chunks
+	data   []byte // Reused between chunks
+}
+
+// next reads the next chunk, which stays valid until the following call
+func (sr *snapshotReader) next() (*fbrdb.SnapshotChunk, error) {
+	if _, err := io.ReadFull(sr.r, sr.frame); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, utils.ErrCorruptedData("RDB file ends before the end of the snapshot")
+		}
+		return nil, utils.ErrRecoveryFailed("failed to read RDB file: " + err.Error())
+	}
+
+	rawSize := binary.LittleEndian.Uint32(sr.frame)
+	storedSize := binary.LittleEndian.Uint32(sr.frame[4:])
+	checksum := binary.LittleEndian.Uint32(sr.frame[8:])
+	if rawSize > rdbMaxChunkBytes || storedSize > rdbMaxChunkBytes {
+		return nil, utils.ErrCorruptedData(fmt.Sprintf("RDB chunk of %d bytes is implausibly large", max(rawSize, storedSize)))
+	}
+
+	sr.stored = slices.Grow(sr.stored[:0], int(storedSize))[:storedSize]
+	if _, err := io.ReadFull(sr.r, sr.stored); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, utils.ErrCorruptedData("RDB file ends inside a chunk")
+		}
+		return nil, utils.ErrRecoveryFailed("failed to read RDB file: " + err.Error())
+	}
+	if crc32.Checksum(sr.stored, chunkCRCTable) != checksum {
+		return nil, utils.ErrCorruptedData("RDB chunk checksum mismatch")
+	}
+
+	compressed := sr.stored
+	if sr.key != nil {
+		opened, err := sr.key.OpenAt(sr.opened[:0], sr.index, sr.stored)
+		if err != nil {
+			return nil, utils.ErrCorruptedData("RDB chunk failed to decrypt: wrong key or damaged file")
+		}
+		sr.opened, compressed = opened, opened
+	}
+	sr.index++
+
+	data, err := sr.codec.decompress(sr.data[:0], compressed, int(rawSize))
+	if err != nil {
+		return nil, utils.ErrCorruptedData("failed to decompress RDB chunk: " + err.Error())
+	}
+	sr.data = data
+	if len(data) < flatbuffers.SizeUOffsetT {
+		return nil, utils.ErrCorruptedData("RDB chunk is empty")
+	}
+	return fbrdb.GetRootAsSnapshotChunk(data, 0), nil
+}
+
+// readChunks passes the databases and collections of a chunked RDB file to visitor and
+// returns the snapshot from the END chunk, without databases. key decrypts the chunks of
+// encrypted files and is nil otherwise.
+func (r *RDBManager) readChunks(reader io.Reader, compression Compression, key *encryption.FileKey, visitor SnapshotVisitor) (*RDBSnapshot, error) {
+	sr := &snapshotReader{
+		r:     reader,
+		codec: &blockCodec{compression: compression},
+		key:   key,
+		frame: make([]byte, rdbChunkFrame),
+	}
+	defer sr.codec.close()
+
+	// A collection is complete once the next chunk that isn't part of it arrives
+	var dbName string
+	var pending *CollectionSnapshot
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		coll := *pending
+		pending = nil
+		if err := validateCollection(coll); err != nil {
+			return err
+		}
+		return visitor.VisitCollection(dbName, coll)
+	}
+
+	for {
+		chunk, err := sr.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch chunk.Kind() {
+		case fbrdb.ChunkKindDATABASE:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			fbDb := chunk.Database(nil)
+			if fbDb == nil {
+				return nil, utils.ErrCorruptedData("RDB database chunk without database")
+			}
+			dbSnapshot, err := r.parseDatabaseSnapshot(fbDb)
+			if err != nil {
+				return nil, err
+			}
+			dbName = dbSnapshot.Name
+			if err := visitor.VisitDatabase(*dbSnapshot); err != nil {
+				return nil, err
+			}
+
+		case fbrdb.ChunkKindCOLLECTION:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			fbColl := chunk.Collection(nil)
+			if fbColl == nil || dbName == "" {
+				return nil, utils.ErrCorruptedData("RDB collection chunk without collection or database")
+			}
+			if pending, err = r.parseCollectionSnapshot(fbColl); err != nil {
+				return nil, err
+			}
+
+		case fbrdb.ChunkKindVECTORS:
+			if pending == nil {
+				return nil, utils.ErrCorruptedData("RDB vectors chunk outside a collection")
+			}
+			fbVec := new(fbrdb.Vector)
+			for i := 0; i < chunk.VectorsLength(); i++ {
+				if !chunk.Vectors(fbVec, i) {
+					return nil, utils.ErrCorruptedData("failed to parse vector")
+				}
+				vector, err := r.parseVector(fbVec)
+				if err != nil {
+					return nil, err
+				}
+				pending.Vectors = append(pending.Vectors, *vector)
+			}
+
+		case fbrdb.ChunkKindNODES:
+			if pending == nil {
+				return nil, utils.ErrCorruptedData("RDB nodes chunk outside a collection")
+			}
+			graph := chunkGraph(pending, string(chunk.Partition()))
+			if graph == nil {
+				return nil, utils.ErrCorruptedData(fmt.Sprintf("RDB nodes chunk for unknown graph %q in collection %s", chunk.Partition(), pending.Name))
+			}
+			fbNode := new(fbrdb.HNSWNode)
+			for i := 0; i < chunk.NodesLength(); i++ {
+				if !chunk.Nodes(fbNode, i) {
+					return nil, utils.ErrCorruptedData("failed to parse HNSW node")
+				}
+				node, err := r.parseHNSWNode(fbNode)
+				if err != nil {
+					return nil, err
+				}
+				graph.Nodes = append(graph.Nodes, *node)
+			}
+
+		case fbrdb.ChunkKindEND:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			fbSnapshot := chunk.Snapshot(nil)
+			if fbSnapshot == nil {
+				return nil, utils.ErrCorruptedData("RDB end chunk without snapshot")
+			}
+			return parseSnapshotHeader(fbSnapshot)
+
+		default:
+			return nil, utils.ErrCorruptedData(fmt.Sprintf("unknown RDB chunk kind %d", chunk.Kind()))
+		}
+	}
+}
+
+// chunkGraph returns the graph a NODES chunk belongs to, nil if the collection has no such graph
+func chunkGraph(coll *CollectionSnapshot, partition string) *HNSWGraphSnapshot {
+	if partition == "" {
+		return coll.HNSWGraph
+	}
+	for i := range coll.Partitions {
+		if coll.Partitions[i].Name == partition {
+			return coll.Partitions[i].HNSWGraph
+		}
+	}
+	return nil
+}
+
+// parseSnapshotHeader parses the version, timestamp and metadata of a FlatBuffers RDBSnapshot
+func parseSnapshotHeader(fbSnapshot *fbrdb.RDBSnapshot) (*RDBSnapshot, error) {
+	snapshot := &RDBSnapshot{
+		Version:   string(fbSnapshot.Version()),
+		Timestamp: time.Unix(fbSnapshot.Timestamp(), 0),
+	}
+	if metadataBytes := fbSnapshot.Metadata(); metadataBytes != nil {
+		if err := json.Unmarshal(metadataBytes, &snapshot.Metadata); err != nil {
+			return nil, utils.ErrCorruptedData("failed to parse metadata: " + err.Error())
+		}
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,241 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/persistence/backup"
+	"github.com/scintirete/scintirete/internal/utils"
+)
+
+// SetTargets sets the targets backups are copied to
+func (bm *BackupManager) SetTargets(targets []backup.Target) {
+	bm.targets = targets
+}
+
+// Targets returns the targets backups are copied to
+func (bm *BackupManager) Targets() []backup.Target {
+	return bm.targets
+}
+
+// Upload copies a local backup to every target that does not hold it yet and returns
+// the errors of the targets that failed by target name
+func (bm *BackupManager) Upload(ctx context.Context, name string) map[string]error {
+	info, err := bm.GetBackup(name)
+	if err != nil {
+		return bm.failAll(err)
+	}
+	checksum, err := backup.FileChecksum(info.Path)
+	if err != nil {
+		return bm.failAll(err)
+	}
+
+	failed := make(map[string]error)
+	for _, target := range bm.targets {
+		objects, err := target.List(ctx)
+		if err == nil && holds(objects, info, checksum) {
+			continue
+		}
+		if err == nil {
+			err = upload(ctx, target, info, checksum)
+		}
+		if err != nil {
+			failed[target.Name()] = err
+		}
+	}
+	return failed
+}
+
+// SyncTargets copies the local backups missing on a target to it, such as the ones an
+// earlier upload failed for, and returns the errors of the targets that failed
+func (bm *BackupManager) SyncTargets(ctx context.Context) map[string]error {
+	backups, err := bm.ListBackups()
+	if err != nil {
+		return bm.failAll(err)
+	}
+
+	failed := make(map[string]error)
+	for _, target := range bm.targets {
+		objects, err := target.List(ctx)
+		if err != nil {
+			failed[target.Name()] = err
+			continue
+		}
+		for _, info := range backups {
+			if holds(objects, info, "") {
+				continue
+			}
+			checksum, err := backup.FileChecksum(info.Path)
+			if err == nil {
+				err = upload(ctx, target, info, checksum)
+			}
+			if err != nil {
+				failed[target.Name()] = err
+				break
+			}
+		}
+	}
+	return failed
+}
+
+// ListTarget returns the backups on a target, newest first. Their times are taken from
+// their names, which keeps them when a copy is uploaded later than it was taken.
+func (bm *BackupManager) ListTarget(ctx context.Context, target backup.Target) ([]BackupInfo, error) {
+	objects, err := target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []BackupInfo
+	for _, object := range objects {
+		if filepath.Ext(object.Name) != backupExt || strings.HasPrefix(object.Name, ".") {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:    object.Name,
+			Size:    object.Size,
+			ModTime: backupTime(object.Name, object.ModTime),
+			Targets: []string{target.Name()},
+		})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool { return backups[i].ModTime.After(backups[j].ModTime) })
+	return backups, nil
+}
+
+// PruneTarget deletes the backups on a target policy does not keep and returns them
+func (bm *BackupManager) PruneTarget(ctx context.Context, target backup.Target, policy RetentionPolicy) ([]BackupInfo, error) {
+	if policy.IsZero() {
+		return nil, nil
+	}
+
+	backups, err := bm.ListTarget(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	_, drop := policy.Select(backups)
+	var removed []BackupInfo
+	for _, info := range drop {
+		if err := target.Delete(ctx, info.Name); err != nil && utils.GetErrorCode(err) != utils.ErrorCodeBackupNotFound {
+			return removed, err
+		}
+		removed = append(removed, info)
+	}
+	return removed, nil
+}
+
+// Fetch downloads a backup from the first target holding it into the backup directory.
+// The download is checked against its checksum before it takes the name of the backup.
+func (bm *BackupManager) Fetch(ctx context.Context, name string) (BackupInfo, error) {
+	if name == "" || filepath.Base(name) != name || filepath.Ext(name) != backupExt {
+		return BackupInfo{}, utils.ErrInvalidParameters(fmt.Sprintf("invalid backup name '%s'", name))
+	}
+
+	var lastErr error = utils.ErrBackupNotFound(name)
+	for _, target := range bm.targets {
+		reader, err := target.Get(ctx, name)
+		if err != nil {
+			if utils.GetErrorCode(err) != utils.ErrorCodeBackupNotFound {
+				lastErr = err
+			}
+			continue
+		}
+		err = bm.download(name, reader)
+		reader.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return bm.GetBackup(name)
+	}
+	return BackupInfo{}, lastErr
+}
+
+// download writes a backup to a temporary file and renames it into the backup directory
+func (bm *BackupManager) download(name string, r io.Reader) (err error) {
+	tempPath := filepath.Join(bm.backupDir, "."+name+".tmp")
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to create backup file", err)
+	}
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(tempPath) // Clean up on error
+		}
+	}()
+
+	if _, err = io.Copy(file, r); err != nil {
+		if utils.IsScintireteError(err) {
+			return err
+		}
+		return utils.ErrPersistenceFailedWithCause("failed to download backup", err)
+	}
+	if err = file.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync backup file", err)
+	}
+	if err = file.Close(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to close backup file", err)
+	}
+
+	// Keep the time the backup was taken, which the retention policy goes by
+	modTime := backupTime(name, time.Now())
+	os.Chtimes(tempPath, modTime, modTime)
+	if err = os.Rename(tempPath, filepath.Join(bm.backupDir, name)); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to rename backup file", err)
+	}
+	return nil
+}
+
+// failAll reports err for every target
+func (bm *BackupManager) failAll(err error) map[string]error {
+	failed := make(map[string]error, len(bm.targets))
+	for _, target := range bm.targets {
+		failed[target.Name()] = err
+	}
+	return failed
+}
+
+// upload copies a local backup to a target
+func upload(ctx context.Context, target backup.Target, info BackupInfo, checksum string) error {
+	file, err := os.Open(info.Path)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to open backup", err)
+	}
+	defer file.Close()
+
+	return target.Put(ctx, info.Name, file, info.Size, checksum)
+}
+
+// holds reports whether objects include a copy of a backup. The checksum is compared
+// if both are known.
+func holds(objects []backup.Object, info BackupInfo, checksum string) bool {
+	for _, object := range objects {
+		if object.Name != info.Name {
+			continue
+		}
+		return object.Size == info.Size && (checksum == "" || object.Checksum == "" || strings.EqualFold(object.Checksum, checksum))
+	}
+	return false
+}
+
+// backupTime returns the time a backup was taken from its name, or fallback if the name
+// does not have one
+func backupTime(name string, fallback time.Time) time.Time {
+	stamp := strings.TrimPrefix(strings.TrimSuffix(name, backupExt), "rdb_backup_")
+	if len(stamp) < len("20060102_150405") {
+		return fallback
+	}
+	t, err := time.ParseInLocation("20060102_150405", stamp[:len("20060102_150405")], time.Local)
+	if err != nil {
+		return fallback
+	}
+	return t
+}
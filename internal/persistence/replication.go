@@ -0,0 +1,199 @@
+// Package persistence provides the persistence side of replication.
+package persistence
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// syncFilename is where a snapshot received from a primary is saved before it replaces
+// the state, within DataDir
+const syncFilename = "replica-sync.rdb"
+
+// CommandFeed receives every command logged to the AOF, such as the replication backlog
+// of a primary. Commands logged concurrently may reach it in a different order than the
+// AOF, which only happens for writes that don't depend on each other.
+type CommandFeed interface {
+	// Append is called once a command is logged, with its timestamp set
+	Append(command types.AOFCommand)
+	// Reset is called when the state is replaced other than by logged commands, such as
+	// by restoring a backup, so that the commands before no longer lead to it
+	Reset()
+}
+
+// CommandFeeds passes logged commands to several feeds, in order
+type CommandFeeds []CommandFeed
+
+// Append passes command to every feed
+func (f CommandFeeds) Append(command types.AOFCommand) {
+	for _, feed := range f {
+		feed.Append(command)
+	}
+}
+
+// Reset resets every feed
+func (f CommandFeeds) Reset() {
+	for _, feed := range f {
+		feed.Reset()
+	}
+}
+
+// SetCommandFeed sets the feed logged commands are passed to, nil for none
+func (m *Manager) SetCommandFeed(feed CommandFeed) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.feed = feed
+}
+
+// commandFeed returns the feed logged commands are passed to
+func (m *Manager) commandFeed() CommandFeed {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.feed
+}
+
+// WriteSnapshot writes an unencrypted snapshot of the database engine at a single point in
+// time to w, such as the snapshot a primary sends a replica. mark runs at that point,
+// while no write is between the engine and the AOF, to record the position of the command
+// feed the snapshot corresponds to. Snapshots and AOF rewrites wait until it returns.
+func (m *Manager) WriteSnapshot(ctx context.Context, w io.Writer, mark func()) error {
+	snapshot, err := m.CutSnapshot(ctx, mark)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	return snapshot.WriteTo(ctx, w)
+}
+
+// Snapshot is the state of the database engine frozen by CutSnapshot
+type Snapshot struct {
+	m   *Manager
+	cut rdb.SnapshotCut
+}
+
+// CutSnapshot freezes the state of the database engine at a single point in time, to be
+// written later while requests keep changing it, such as a Raft snapshot taken between
+// two applied commands. mark runs as WriteSnapshot describes. Snapshots and AOF rewrites
+// wait until the snapshot is released.
+func (m *Manager) CutSnapshot(ctx context.Context, mark func()) (*Snapshot, error) {
+	m.mu.RLock()
+	applier := m.cmdApplier
+	m.mu.RUnlock()
+	if applier == nil {
+		return nil, utils.ErrPersistenceFailed("no database engine configured")
+	}
+
+	m.saveMu.Lock()
+	cut, err := applier.CutSnapshot(ctx, func() error {
+		mark()
+		return nil
+	})
+	if err != nil {
+		m.saveMu.Unlock()
+		return nil, err
+	}
+	return &Snapshot{m: m, cut: cut}, nil
+}
+
+// WriteTo writes the snapshot to w, unencrypted in the compression of the RDB file
+func (s *Snapshot) WriteTo(ctx context.Context, w io.Writer) error {
+	return rdb.WriteStream(ctx, w, s.cut, s.m.rdbManager.Compression())
+}
+
+// Release frees the state kept for the snapshot. It must be called exactly once.
+func (s *Snapshot) Release() {
+	s.cut.Release()
+	s.m.saveMu.Unlock()
+}
+
+// LoadSnapshot replaces the state of the database engine with the snapshot read from
+// reader, such as the snapshot a primary sends a replica. The snapshot is saved in the
+// data directory first, in the compression and encryption of the RDB file, so a broken
+// transfer leaves the engine alone; it then becomes the base of the AOF in place of
+// everything logged so far.
+func (m *Manager) LoadSnapshot(ctx context.Context, reader io.Reader) error {
+	m.mu.RLock()
+	applier := m.cmdApplier
+	m.mu.RUnlock()
+	if applier == nil {
+		return utils.ErrPersistenceFailed("no database engine configured")
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	path := filepath.Join(m.config.DataDir, syncFilename)
+	received, err := rdb.NewRDBManagerWithCompression(path, m.rdbManager.Compression())
+	if err != nil {
+		return err
+	}
+	received.SetEncryption(m.config.KeyProvider)
+	defer os.Remove(path)
+
+	if err := received.SaveFrom(ctx, reader); err != nil {
+		return utils.ErrRecoveryFailed("failed to receive snapshot: " + err.Error())
+	}
+
+	seq, err := m.replaceBase(applier, path, func() error {
+		return applier.ApplySnapshotStream(ctx, func(visitor rdb.SnapshotVisitor) error {
+			_, err := received.LoadStream(ctx, visitor)
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.stats.LastRDBSave = time.Now()
+	m.mu.Unlock()
+
+	m.logger.Info(ctx, "Loaded snapshot", map[string]interface{}{
+		"component":     "persistence_replication",
+		"aof_increment": seq,
+	})
+	return nil
+}
+
+// replaceBase makes the RDB file at path the base of the AOF in place of everything
+// logged so far, then replaces the state of the engine with apply, while writes are held.
+// The base is committed first, so a crash on the way recovers the new state rather than a
+// mix of both. saveMu must be held.
+func (m *Manager) replaceBase(applier *CommandApplier, path string, apply func() error) (int64, error) {
+	var seq int64
+	err := applier.HoldWrites(func() error {
+		var err error
+		if seq, err = m.aofLogger.Rotate(); err != nil {
+			return err
+		}
+		if err := m.aofLogger.CommitBase(seq, aof.BaseRDB, func(basePath string) error {
+			return aof.LinkFile(path, basePath)
+		}); err != nil {
+			return utils.ErrPersistenceFailedWithCause("failed to make snapshot the AOF base", err)
+		}
+
+		if err := apply(); err != nil {
+			return utils.ErrRecoveryFailed("snapshot is the AOF base but the engine failed to load it, restart to recover it: " + err.Error())
+		}
+
+		m.mu.Lock()
+		m.aofCommandsSinceRDB = 0
+		m.isDirty = false
+		feed := m.feed
+		m.mu.Unlock()
+		if feed != nil {
+			feed.Reset()
+		}
+		return nil
+	})
+	return seq, err
+}
@@ -0,0 +1,190 @@
+// Package replication provides primary–replica replication of Scintirete servers.
+//
+// A primary numbers every command it logs to the AOF with a replication offset and keeps
+// the most recent ones in a backlog. A replica connects with the replication ID and offset
+// it last applied: if the backlog still has the commands after that offset, the primary
+// continues from there (partial resynchronization); otherwise it sends a snapshot of its
+// state and continues after the offset the snapshot corresponds to (full
+// resynchronization). Commands are then streamed as they are logged.
+package replication
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// DefaultBacklogSize is the size of the backlog if none is configured
+const DefaultBacklogSize = 64 * 1024 * 1024
+
+// Config configures replication of a server
+type Config struct {
+	ReplicaOf       string // Primary to follow at startup, host:port; empty starts as a primary
+	PrimaryPassword string // Password the primary accepts
+	ReplicaName     string // Shown in the replication info of the primary, defaults to the host name
+	BacklogSize     int64  // Bytes of commands kept for partial resynchronizations of replicas
+}
+
+var (
+	// errFeedReset reports that the replication ID changed, so a replica has to resynchronize in full
+	errFeedReset = errors.New("replication ID changed, the replica needs a full resynchronization")
+	// errBacklogOverflow reports that the commands after an offset are no longer in the backlog
+	errBacklogOverflow = errors.New("commands after the offset are no longer in the backlog, the replica needs a full resynchronization")
+)
+
+// Entry is a command in the backlog
+type Entry struct {
+	Offset  int64
+	Command []byte // AOF record payload, see aof.EncodeCommand
+}
+
+// Feed is the replication backlog of a primary. It implements persistence.CommandFeed.
+type Feed struct {
+	mu      sync.Mutex
+	id      string        // Replication ID, new whenever the offsets start over
+	offset  int64         // Offset of the last command appended
+	entries []Entry       // The most recent commands, oldest first
+	size    int64         // Bytes of the entries
+	maxSize int64         // Oldest entries are dropped beyond this size
+	notify  chan struct{} // Closed and replaced when commands are appended or the feed is reset
+	logger  core.Logger
+}
+
+// NewFeed creates a feed with a new replication ID that keeps up to maxSize bytes of
+// commands, DefaultBacklogSize if maxSize is not positive
+func NewFeed(maxSize int64, logger core.Logger) *Feed {
+	if maxSize <= 0 {
+		maxSize = DefaultBacklogSize
+	}
+	return &Feed{
+		id:      newReplicationID(),
+		maxSize: maxSize,
+		notify:  make(chan struct{}),
+		logger:  logger,
+	}
+}
+
+// Append adds a logged command to the backlog
+func (f *Feed) Append(command types.AOFCommand) {
+	data, err := aof.EncodeCommand(command)
+	if err != nil {
+		// Replicas can't get the command, so they have to start over
+		f.logger.Error(context.Background(), "Failed to encode command for replication", err, map[string]interface{}{
+			"command": command.Command,
+		})
+		f.Reset()
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.offset++
+	f.entries = append(f.entries, Entry{Offset: f.offset, Command: data})
+	f.size += int64(len(data))
+	for len(f.entries) > 1 && f.size > f.maxSize {
+		f.size -= int64(len(f.entries[0].Command))
+		f.entries[0] = Entry{}
+		f.entries = f.entries[1:]
+	}
+	f.wake()
+}
+
+// Reset starts a new replication ID with an empty backlog
+func (f *Feed) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.id = newReplicationID()
+	f.offset = 0
+	f.entries = nil
+	f.size = 0
+	f.wake()
+}
+
+// Position returns the replication ID and the offset of the last command appended
+func (f *Feed) Position() (string, int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.id, f.offset
+}
+
+// Backlog returns the offset of the oldest command in the backlog, or the next offset if
+// it is empty, and the size of the backlog in bytes
+func (f *Feed) Backlog() (int64, int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.entries) == 0 {
+		return f.offset + 1, 0
+	}
+	return f.entries[0].Offset, f.size
+}
+
+// CanContinue reports whether the feed can continue after offset for a replica that
+// synchronized with replication ID id
+func (f *Feed) CanContinue(id string, offset int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.continues(id, offset)
+}
+
+// continues is CanContinue with mu held
+func (f *Feed) continues(id string, offset int64) bool {
+	if id != f.id || offset > f.offset {
+		return false
+	}
+	return offset == f.offset || (len(f.entries) > 0 && f.entries[0].Offset <= offset+1)
+}
+
+// Read returns the commands after offset, up to about maxBytes but at least one if there
+// is any. If there are none yet, it returns a channel that is closed once there are. It
+// fails once the commands after offset are gone, because the backlog overflowed or the
+// feed was reset.
+func (f *Feed) Read(id string, offset int64, maxBytes int) ([]Entry, <-chan struct{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id != f.id {
+		return nil, nil, errFeedReset
+	}
+	if !f.continues(id, offset) {
+		return nil, nil, errBacklogOverflow
+	}
+	if offset == f.offset {
+		return nil, f.notify, nil
+	}
+
+	start := int(offset + 1 - f.entries[0].Offset)
+	var entries []Entry
+	size := 0
+	for _, entry := range f.entries[start:] {
+		if len(entries) > 0 && size+len(entry.Command) > maxBytes {
+			break
+		}
+		entries = append(entries, entry)
+		size += len(entry.Command)
+	}
+	return entries, nil, nil
+}
+
+// wake notifies readers waiting for commands. mu must be held.
+func (f *Feed) wake() {
+	close(f.notify)
+	f.notify = make(chan struct{})
+}
+
+// newReplicationID returns a random 40 character replication ID, as Redis uses
+func newReplicationID() string {
+	id := make([]byte, 20)
+	if _, err := rand.Read(id); err != nil {
+		panic("replication: failed to generate replication ID: " + err.Error())
+	}
+	return hex.EncodeToString(id)
+}
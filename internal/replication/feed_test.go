@@ -0,0 +1,112 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/scintirete/scintirete/internal/observability/logger"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFeed(t *testing.T, maxSize int64) *Feed {
+	t.Helper()
+	log, err := logger.NewFromConfigString("error", "text")
+	require.NoError(t, err)
+	return NewFeed(maxSize, log)
+}
+
+func createDatabase(name string) types.AOFCommand {
+	return types.AOFCommand{Command: "CREATE_DATABASE", Args: map[string]interface{}{"name": name}}
+}
+
+func TestFeedReadContinuesAfterOffset(t *testing.T) {
+	feed := newTestFeed(t, 0)
+	id, offset := feed.Position()
+	assert.Len(t, id, 40)
+	assert.Zero(t, offset)
+
+	// Nothing after the current offset yet
+	entries, notify, err := feed.Read(id, 0, maxCommandBytes)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	require.NotNil(t, notify)
+
+	feed.Append(createDatabase("a"))
+	feed.Append(createDatabase("b"))
+	feed.Append(createDatabase("c"))
+	select {
+	case <-notify:
+	default:
+		t.Fatal("readers waiting for commands were not notified")
+	}
+
+	entries, _, err = feed.Read(id, 1, maxCommandBytes)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(2), entries[0].Offset)
+	assert.Equal(t, int64(3), entries[1].Offset)
+
+	command, err := aof.DecodeCommand(entries[0].Command)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE_DATABASE", command.Command)
+	assert.Equal(t, "b", command.Args["name"])
+	assert.False(t, command.Timestamp.IsZero())
+
+	// At least one command is returned even if it exceeds the limit
+	entries, _, err = feed.Read(id, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, int64(1), entries[0].Offset)
+}
+
+func TestFeedBacklogOverflow(t *testing.T) {
+	command, err := aof.EncodeCommand(createDatabase("a"))
+	require.NoError(t, err)
+	feed := newTestFeed(t, int64(2*len(command)))
+	id, _ := feed.Position()
+
+	for i := 0; i < 5; i++ {
+		feed.Append(createDatabase("a"))
+	}
+
+	first, size := feed.Backlog()
+	assert.Equal(t, int64(4), first)
+	assert.Equal(t, int64(2*len(command)), size)
+
+	// Offset 3 was applied, so 4 and 5 are still there
+	assert.True(t, feed.CanContinue(id, 3))
+	assert.True(t, feed.CanContinue(id, 5))
+	assert.False(t, feed.CanContinue(id, 2))
+	assert.False(t, feed.CanContinue(id, 6))
+	assert.False(t, feed.CanContinue("other", 5))
+
+	_, _, err = feed.Read(id, 2, maxCommandBytes)
+	assert.ErrorIs(t, err, errBacklogOverflow)
+}
+
+func TestFeedReset(t *testing.T) {
+	feed := newTestFeed(t, 0)
+	id, _ := feed.Position()
+	feed.Append(createDatabase("a"))
+
+	_, notify, err := feed.Read(id, 1, maxCommandBytes)
+	require.NoError(t, err)
+
+	feed.Reset()
+	select {
+	case <-notify:
+	default:
+		t.Fatal("readers waiting for commands were not notified")
+	}
+
+	newID, offset := feed.Position()
+	assert.NotEqual(t, id, newID)
+	assert.Zero(t, offset)
+	assert.False(t, feed.CanContinue(id, 1))
+	assert.True(t, feed.CanContinue(newID, 0))
+
+	_, _, err = feed.Read(id, 1, maxCommandBytes)
+	assert.ErrorIs(t, err, errFeedReset)
+}
@@ -0,0 +1,211 @@
+// Package replication provides the primary side of replication.
+package replication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"google.golang.org/grpc"
+)
+
+const (
+	// HeartbeatInterval is how often an idle primary tells its replicas its offset
+	HeartbeatInterval = time.Second
+
+	chunkSize       = 1024 * 1024     // Snapshot bytes per message
+	maxCommandBytes = 4 * 1024 * 1024 // Command bytes per message, unless a single command is larger
+)
+
+// SnapshotSource writes snapshots for full resynchronizations, see
+// persistence.Manager.WriteSnapshot
+type SnapshotSource interface {
+	WriteSnapshot(ctx context.Context, w io.Writer, mark func()) error
+}
+
+// ConnectedReplica describes a replica streaming from the primary
+type ConnectedReplica struct {
+	Name        string
+	Address     string
+	Offset      int64 // Offset of the last command sent
+	ConnectedAt time.Time
+	FullSync    bool // Whether the connection started with a full resynchronization
+}
+
+// Primary serves the commands of a feed to replicas
+type Primary struct {
+	feed   *Feed
+	source SnapshotSource
+	logger core.Logger
+
+	mu       sync.Mutex
+	replicas map[*ConnectedReplica]struct{}
+}
+
+// NewPrimary creates a primary serving feed, with full resynchronizations from source
+func NewPrimary(feed *Feed, source SnapshotSource, logger core.Logger) *Primary {
+	return &Primary{
+		feed:     feed,
+		source:   source,
+		logger:   logger,
+		replicas: make(map[*ConnectedReplica]struct{}),
+	}
+}
+
+// Feed returns the feed the primary serves
+func (p *Primary) Feed() *Feed {
+	return p.feed
+}
+
+// Replicas returns the connected replicas, in the order they connected
+func (p *Primary) Replicas() []ConnectedReplica {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	replicas := make([]ConnectedReplica, 0, len(p.replicas))
+	for replica := range p.replicas {
+		replicas = append(replicas, *replica)
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].ConnectedAt.Before(replicas[j].ConnectedAt) })
+	return replicas
+}
+
+// Sync streams the feed to a replica until ctx ends or the replica falls too far
+// behind. It starts with a snapshot unless the feed can continue after the replication
+// ID and offset of the request.
+func (p *Primary) Sync(ctx context.Context, req *pb.SyncRequest, address string, stream grpc.ServerStreamingServer[pb.SyncResponse]) error {
+	replica := &ConnectedReplica{Name: req.ReplicaName, Address: address, ConnectedAt: time.Now()}
+
+	id, offset := req.ReplicationId, req.Offset
+	if id != "" && p.feed.CanContinue(id, offset) {
+		if err := stream.Send(&pb.SyncResponse{Event: &pb.SyncResponse_Start{Start: &pb.SyncStart{ReplicationId: id, Offset: offset}}}); err != nil {
+			return err
+		}
+	} else {
+		replica.FullSync = true
+		var err error
+		if id, offset, err = p.sendSnapshot(ctx, stream); err != nil {
+			return err
+		}
+	}
+	replica.Offset = offset
+
+	p.mu.Lock()
+	p.replicas[replica] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.replicas, replica)
+		p.mu.Unlock()
+	}()
+
+	p.logger.Info(ctx, "Replica connected", map[string]interface{}{
+		"replica":        replica.Name,
+		"address":        address,
+		"full_sync":      replica.FullSync,
+		"replication_id": id,
+		"offset":         offset,
+	})
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		entries, notify, err := p.feed.Read(id, offset, maxCommandBytes)
+		if err != nil {
+			return fmt.Errorf("replica %s at offset %d: %w", replica.Name, offset, err)
+		}
+
+		if len(entries) > 0 {
+			commands := make([]*pb.ReplicatedCommand, len(entries))
+			for i, entry := range entries {
+				commands[i] = &pb.ReplicatedCommand{Offset: entry.Offset, Command: entry.Command}
+			}
+			if err := stream.Send(&pb.SyncResponse{Event: &pb.SyncResponse_Commands{Commands: &pb.ReplicatedCommands{Commands: commands}}}); err != nil {
+				return err
+			}
+			offset = entries[len(entries)-1].Offset
+
+			p.mu.Lock()
+			replica.Offset = offset
+			p.mu.Unlock()
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		case now := <-heartbeat.C:
+			_, current := p.feed.Position()
+			if err := stream.Send(&pb.SyncResponse{Event: &pb.SyncResponse_Heartbeat{Heartbeat: &pb.ReplicationHeartbeat{
+				Offset:      current,
+				TimestampMs: now.UnixMilli(),
+			}}}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendSnapshot streams a snapshot of the current state and returns the replication ID
+// and offset it corresponds to
+func (p *Primary) sendSnapshot(ctx context.Context, stream grpc.ServerStreamingServer[pb.SyncResponse]) (string, int64, error) {
+	if err := stream.Send(&pb.SyncResponse{Event: &pb.SyncResponse_Start{Start: &pb.SyncStart{FullSync: true}}}); err != nil {
+		return "", 0, err
+	}
+
+	var id string
+	var offset int64
+	writer := &chunkWriter{stream: stream, buf: make([]byte, 0, chunkSize)}
+	err := p.source.WriteSnapshot(ctx, writer, func() {
+		id, offset = p.feed.Position()
+	})
+	if err == nil {
+		err = writer.flush()
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to send snapshot: %w", err)
+	}
+
+	if err := stream.Send(&pb.SyncResponse{Event: &pb.SyncResponse_SnapshotEnd{SnapshotEnd: &pb.SnapshotEnd{ReplicationId: id, Offset: offset}}}); err != nil {
+		return "", 0, err
+	}
+	return id, offset, nil
+}
+
+// chunkWriter sends what is written to it as snapshot chunks
+type chunkWriter struct {
+	stream grpc.ServerStreamingServer[pb.SyncResponse]
+	buf    []byte
+}
+
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	written := len(data)
+	for len(data) > 0 {
+		n := min(len(data), chunkSize-len(w.buf))
+		w.buf = append(w.buf, data[:n]...)
+		data = data[n:]
+		if len(w.buf) == chunkSize {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush sends the buffered bytes
+func (w *chunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	// Sent messages must not be modified, so every chunk gets a new buffer
+	err := w.stream.Send(&pb.SyncResponse{Event: &pb.SyncResponse_SnapshotChunk{SnapshotChunk: w.buf}})
+	w.buf = make([]byte, 0, chunkSize)
+	return err
+}
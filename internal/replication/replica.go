@@ -0,0 +1,342 @@
+// Package replication provides the replica side of replication.
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// linkTimeout is how long a replica waits for any message before it reconnects
+	linkTimeout = 10 * HeartbeatInterval
+
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+	maxRecvSize   = 256 * 1024 * 1024 // A single command can be as large as the primary accepts
+)
+
+// Store is the local state a replica keeps in sync with its primary
+type Store interface {
+	// LoadSnapshot replaces the state with the snapshot read from reader
+	LoadSnapshot(ctx context.Context, reader io.Reader) error
+	// ApplyCommand applies a command of the primary and logs it locally
+	ApplyCommand(ctx context.Context, command types.AOFCommand) error
+}
+
+// ReplicaConfig configures a replica
+type ReplicaConfig struct {
+	Primary  string // gRPC address of the primary, host:port
+	Password string // Password the primary accepts
+	Name     string // Shown in the replication info of the primary
+}
+
+// ReplicaStatus describes the state of a replica
+type ReplicaStatus struct {
+	Primary       string
+	ReplicationID string // Replication ID synchronized with, empty before the first full resynchronization
+	Offset        int64  // Offset of the last command applied
+	PrimaryOffset int64  // Offset of the primary as last reported
+	LinkUp        bool
+	LastIO        time.Time // When the last message from the primary arrived
+	Lag           time.Duration
+	FullSyncs     int64
+	PartialSyncs  int64
+	LastError     string
+}
+
+// LagCommands returns how many commands of the primary are not applied yet
+func (s ReplicaStatus) LagCommands() int64 {
+	return max(s.PrimaryOffset-s.Offset, 0)
+}
+
+// Replica follows a primary: it synchronizes with it and applies its commands to a
+// store, reconnecting with a partial resynchronization whenever the link breaks
+type Replica struct {
+	config ReplicaConfig
+	store  Store
+	logger core.Logger
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu            sync.Mutex
+	id            string
+	offset        int64
+	primaryOffset int64
+	appliedAt     time.Time // Primary timestamp of the last command applied
+	linkUp        bool
+	lastIO        time.Time
+	fullSyncs     int64
+	partialSyncs  int64
+	lastError     string
+}
+
+// StartReplica starts following the primary of config until Stop is called
+func StartReplica(config ReplicaConfig, store Store, logger core.Logger) *Replica {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Replica{
+		config: config,
+		store:  store,
+		logger: logger,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go r.run(ctx)
+	return r
+}
+
+// Stop stops following the primary and waits until no command is applied anymore
+func (r *Replica) Stop() {
+	r.cancel()
+	<-r.done
+}
+
+// Primary returns the address of the primary
+func (r *Replica) Primary() string {
+	return r.config.Primary
+}
+
+// Status returns the state of the replica
+func (r *Replica) Status() ReplicaStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := ReplicaStatus{
+		Primary:       r.config.Primary,
+		ReplicationID: r.id,
+		Offset:        r.offset,
+		PrimaryOffset: max(r.primaryOffset, r.offset),
+		LinkUp:        r.linkUp,
+		LastIO:        r.lastIO,
+		FullSyncs:     r.fullSyncs,
+		PartialSyncs:  r.partialSyncs,
+		LastError:     r.lastError,
+	}
+	if status.LagCommands() > 0 && !r.appliedAt.IsZero() {
+		status.Lag = time.Since(r.appliedAt)
+	}
+	return status
+}
+
+// run synchronizes with the primary until ctx ends, retrying with backoff
+func (r *Replica) run(ctx context.Context) {
+	defer close(r.done)
+
+	delay := minRetryDelay
+	for {
+		synced, err := r.sync(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.mu.Lock()
+		r.linkUp = false
+		if err != nil {
+			r.lastError = err.Error()
+		}
+		r.mu.Unlock()
+		r.logger.Warn(ctx, "Replication link to primary lost", map[string]interface{}{
+			"primary": r.config.Primary,
+			"error":   fmt.Sprint(err),
+		})
+
+		if synced {
+			delay = minRetryDelay
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// sync connects to the primary and applies what it streams until the link breaks. It
+// reports whether synchronization started.
+func (r *Replica) sync(ctx context.Context) (bool, error) {
+	conn, err := grpc.NewClient(r.config.Primary,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxRecvSize)),
+	)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r.mu.Lock()
+	id, offset := r.id, r.offset
+	r.mu.Unlock()
+	stream, err := pb.NewScintireteServiceClient(conn).Sync(ctx, &pb.SyncRequest{
+		Auth:          &pb.AuthInfo{Password: r.config.Password},
+		ReplicationId: id,
+		Offset:        offset,
+		ReplicaName:   r.config.Name,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// A primary that stops sending, even heartbeats, is treated as gone
+	watchdog := time.AfterFunc(linkTimeout, cancel)
+	defer watchdog.Stop()
+
+	msg, err := stream.Recv()
+	if err != nil {
+		return false, err
+	}
+	start := msg.GetStart()
+	if start == nil {
+		return false, errors.New("primary did not start the synchronization")
+	}
+	watchdog.Reset(linkTimeout)
+
+	if start.FullSync {
+		if err := r.receiveSnapshot(ctx, stream, watchdog); err != nil {
+			return true, err
+		}
+	} else {
+		if start.ReplicationId != id || start.Offset != offset {
+			return false, fmt.Errorf("primary continued at %s:%d instead of %s:%d", start.ReplicationId, start.Offset, id, offset)
+		}
+		r.mu.Lock()
+		r.partialSyncs++
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	r.linkUp = true
+	r.lastIO = time.Now()
+	r.lastError = ""
+	r.mu.Unlock()
+	r.logger.Info(ctx, "Synchronized with primary", map[string]interface{}{
+		"primary":        r.config.Primary,
+		"full_sync":      start.FullSync,
+		"replication_id": r.Status().ReplicationID,
+		"offset":         r.Status().Offset,
+	})
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return true, err
+		}
+		watchdog.Reset(linkTimeout)
+
+		r.mu.Lock()
+		r.lastIO = time.Now()
+		r.mu.Unlock()
+
+		switch event := msg.Event.(type) {
+		case *pb.SyncResponse_Commands:
+			if err := r.apply(ctx, event.Commands.Commands); err != nil {
+				return true, err
+			}
+		case *pb.SyncResponse_Heartbeat:
+			r.mu.Lock()
+			r.primaryOffset = event.Heartbeat.Offset
+			r.mu.Unlock()
+		default:
+			return true, fmt.Errorf("unexpected replication message %T", msg.Event)
+		}
+	}
+}
+
+// receiveSnapshot loads the snapshot of a full resynchronization into the store
+func (r *Replica) receiveSnapshot(ctx context.Context, stream grpc.ServerStreamingClient[pb.SyncResponse], watchdog *time.Timer) error {
+	// The state is being replaced, so a broken transfer must not be continued from
+	r.mu.Lock()
+	r.id, r.offset = "", 0
+	r.mu.Unlock()
+
+	reader, writer := io.Pipe()
+	loaded := make(chan error, 1)
+	go func() {
+		err := r.store.LoadSnapshot(ctx, reader)
+		reader.CloseWithError(err)
+		loaded <- err
+	}()
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			writer.CloseWithError(err)
+			<-loaded
+			return err
+		}
+		watchdog.Reset(linkTimeout)
+
+		switch event := msg.Event.(type) {
+		case *pb.SyncResponse_SnapshotChunk:
+			if _, err := writer.Write(event.SnapshotChunk); err != nil {
+				writer.CloseWithError(err)
+				return fmt.Errorf("failed to load snapshot: %w", <-loaded)
+			}
+		case *pb.SyncResponse_SnapshotEnd:
+			writer.Close()
+			// Loading can take a while after the last chunk
+			watchdog.Stop()
+			if err := <-loaded; err != nil {
+				return fmt.Errorf("failed to load snapshot: %w", err)
+			}
+			watchdog.Reset(linkTimeout)
+
+			r.mu.Lock()
+			r.id, r.offset = event.SnapshotEnd.ReplicationId, event.SnapshotEnd.Offset
+			r.primaryOffset = r.offset
+			r.appliedAt = time.Time{}
+			r.fullSyncs++
+			r.mu.Unlock()
+			return nil
+		default:
+			err := fmt.Errorf("unexpected replication message %T during snapshot", msg.Event)
+			writer.CloseWithError(err)
+			<-loaded
+			return err
+		}
+	}
+}
+
+// apply applies commands in offset order
+func (r *Replica) apply(ctx context.Context, commands []*pb.ReplicatedCommand) error {
+	for _, replicated := range commands {
+		r.mu.Lock()
+		offset := r.offset
+		r.mu.Unlock()
+		if replicated.Offset != offset+1 {
+			return fmt.Errorf("expected command at offset %d, got %d", offset+1, replicated.Offset)
+		}
+
+		command, err := aof.DecodeCommand(replicated.Command)
+		if err == nil {
+			err = r.store.ApplyCommand(ctx, command)
+		}
+		if err != nil {
+			// The state no longer follows the primary, so only a full resynchronization helps
+			r.mu.Lock()
+			r.id = ""
+			r.mu.Unlock()
+			return fmt.Errorf("failed to apply command at offset %d: %w", replicated.Offset, err)
+		}
+
+		r.mu.Lock()
+		r.offset = replicated.Offset
+		r.primaryOffset = max(r.primaryOffset, r.offset)
+		r.appliedAt = command.Timestamp
+		r.mu.Unlock()
+	}
+	return nil
+}
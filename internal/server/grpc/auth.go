@@ -23,3 +23,9 @@ func (s *Server) authenticate(auth *pb.AuthInfo) error {
 
 	return nil
 }
+
+// Authenticate checks a password the way requests are authenticated, for front-ends that
+// authenticate a connection once, such as the RESP listener
+func (s *Server) Authenticate(password string) error {
+	return s.authenticate(&pb.AuthInfo{Password: password})
+}
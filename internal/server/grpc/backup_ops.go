@@ -0,0 +1,161 @@
+// Package grpc provides backup operations for the gRPC server.
+package grpc
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/persistence/rdb"
+	"github.com/scintirete/scintirete/internal/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateBackup saves a snapshot into the backup directory, copies it to the backup targets
+// and applies the retention policy
+func (s *Server) CreateBackup(ctx context.Context, req *pb.CreateBackupRequest) (*pb.CreateBackupResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	result, err := s.persistence.CreateBackup(ctx)
+	if err != nil {
+		return nil, s.backupError(err)
+	}
+
+	prunedNames := make([]string, 0, len(result.Pruned))
+	for _, info := range result.Pruned {
+		prunedNames = append(prunedNames, info.Name)
+	}
+	uploadErrors := make(map[string]string, len(result.UploadErrors))
+	for target, err := range result.UploadErrors {
+		uploadErrors[target] = err.Error()
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "CreateBackup", "", "", req.Auth, map[string]interface{}{
+		"operation_type": "backup",
+		"backup":         result.Backup.Name,
+		"targets":        result.Backup.Targets,
+		"pruned":         prunedNames,
+	})
+
+	s.updateRequestStats()
+	return &pb.CreateBackupResponse{
+		Backup:          backupInfo(result.Backup),
+		Pruned:          prunedNames,
+		DurationSeconds: time.Since(startTime).Seconds(),
+		UploadErrors:    uploadErrors,
+	}, nil
+}
+
+// ListBackups returns the backups in the backup directory and on the backup targets, newest first
+func (s *Server) ListBackups(ctx context.Context, req *pb.ListBackupsRequest) (*pb.ListBackupsResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	backups, err := s.persistence.ListBackups(ctx)
+	if err != nil {
+		return nil, s.backupError(err)
+	}
+
+	resp := &pb.ListBackupsResponse{Backups: make([]*pb.BackupInfo, 0, len(backups))}
+	for _, backup := range backups {
+		resp.Backups = append(resp.Backups, backupInfo(backup))
+	}
+
+	s.updateRequestStats()
+	return resp, nil
+}
+
+// RestoreBackup replaces all databases of the running server with a backup
+func (s *Server) RestoreBackup(ctx context.Context, req *pb.RestoreBackupRequest) (*pb.RestoreBackupResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state, only the Raft log that of a cluster node
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkStandalone("RestoreBackup"); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "backup name cannot be empty")
+	}
+
+	startTime := time.Now()
+	if _, err := s.persistence.RestoreBackup(ctx, req.Name); err != nil {
+		return nil, s.backupError(err)
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "RestoreBackup", "", "", req.Auth, map[string]interface{}{
+		"operation_type": "backup",
+		"backup":         req.Name,
+	})
+
+	s.updateRequestStats()
+	return &pb.RestoreBackupResponse{
+		Success:         true,
+		Message:         "Backup restored successfully",
+		DurationSeconds: time.Since(startTime).Seconds(),
+	}, nil
+}
+
+// DeleteBackup removes a backup from the backup directory and the backup targets
+func (s *Server) DeleteBackup(ctx context.Context, req *pb.DeleteBackupRequest) (*pb.DeleteBackupResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "backup name cannot be empty")
+	}
+
+	if err := s.persistence.DeleteBackup(ctx, req.Name); err != nil {
+		return nil, s.backupError(err)
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "DeleteBackup", "", "", req.Auth, map[string]interface{}{
+		"operation_type": "backup",
+		"backup":         req.Name,
+	})
+
+	s.updateRequestStats()
+	return &pb.DeleteBackupResponse{
+		Success: true,
+		Message: "Backup deleted successfully",
+	}, nil
+}
+
+// backupError converts an error of a backup operation to a gRPC status error
+func (s *Server) backupError(err error) error {
+	if utils.IsScintireteError(err) {
+		return s.convertError(err)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// backupInfo converts a backup file to its protobuf message
+func backupInfo(backup rdb.BackupInfo) *pb.BackupInfo {
+	return &pb.BackupInfo{
+		Name:      backup.Name,
+		Size:      backup.Size,
+		CreatedAt: backup.ModTime.Unix(),
+		Local:     backup.Path != "",
+		Targets:   backup.Targets,
+	}
+}
@@ -0,0 +1,335 @@
+// Package grpc provides cluster operations for the gRPC server.
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/cluster"
+	"github.com/scintirete/scintirete/internal/core/database"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClusterApply has the leader of the cluster apply a write forwarded by a follower
+func (s *Server) ClusterApply(ctx context.Context, req *pb.ClusterApplyRequest) (*pb.ClusterApplyResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkClusterNode(); err != nil {
+		return nil, err
+	}
+
+	command, err := aof.DecodeCommand(req.Command)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "malformed command: %v", err)
+	}
+
+	result, err := s.cluster.Propose(ctx, command)
+	if err != nil {
+		return nil, s.writeError(err)
+	}
+
+	s.updateRequestStats()
+	return &pb.ClusterApplyResponse{
+		Index:        result.Index,
+		InsertedIds:  result.InsertedIDs,
+		DeletedCount: result.Deleted,
+	}, nil
+}
+
+// AddClusterNode adds a node to the cluster as a voter
+func (s *Server) AddClusterNode(ctx context.Context, req *pb.AddClusterNodeRequest) (*pb.AddClusterNodeResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkClusterNode(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node ID cannot be empty")
+	}
+	if _, _, err := net.SplitHostPort(req.RaftAddress); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid Raft address: %v", err)
+	}
+	if _, _, err := net.SplitHostPort(req.Address); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid address: %v", err)
+	}
+
+	// Only the leader changes the membership
+	if !s.cluster.IsLeader() {
+		client, err := s.cluster.LeaderClient()
+		if err != nil {
+			return nil, s.writeError(err)
+		}
+		return client.AddClusterNode(ctx, req)
+	}
+
+	if err := s.cluster.AddNode(ctx, req.NodeId, req.RaftAddress, req.Address); err != nil {
+		return nil, s.writeError(err)
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "AddClusterNode", "", "", req.Auth, map[string]interface{}{
+		"operation_type": "cluster",
+		"node_id":        req.NodeId,
+		"raft_address":   req.RaftAddress,
+		"address":        req.Address,
+	})
+
+	s.updateRequestStats()
+	return &pb.AddClusterNodeResponse{
+		Success: true,
+		Message: "Node " + req.NodeId + " added to the cluster",
+	}, nil
+}
+
+// RemoveClusterNode removes a node from the cluster
+func (s *Server) RemoveClusterNode(ctx context.Context, req *pb.RemoveClusterNodeRequest) (*pb.RemoveClusterNodeResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkClusterNode(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node ID cannot be empty")
+	}
+
+	// Only the leader changes the membership
+	if !s.cluster.IsLeader() {
+		client, err := s.cluster.LeaderClient()
+		if err != nil {
+			return nil, s.writeError(err)
+		}
+		return client.RemoveClusterNode(ctx, req)
+	}
+
+	if err := s.cluster.RemoveNode(ctx, req.NodeId); err != nil {
+		return nil, s.writeError(err)
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "RemoveClusterNode", "", "", req.Auth, map[string]interface{}{
+		"operation_type": "cluster",
+		"node_id":        req.NodeId,
+	})
+
+	s.updateRequestStats()
+	return &pb.RemoveClusterNodeResponse{
+		Success: true,
+		Message: "Node " + req.NodeId + " removed from the cluster",
+	}, nil
+}
+
+// GetClusterStatus returns the state of this node and the members of its cluster
+func (s *Server) GetClusterStatus(ctx context.Context, req *pb.GetClusterStatusRequest) (*pb.ClusterStatus, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkClusterNode(); err != nil {
+		return nil, err
+	}
+
+	st, err := s.cluster.Status()
+	if err != nil {
+		return nil, s.writeError(err)
+	}
+
+	resp := &pb.ClusterStatus{
+		NodeId:        st.NodeID,
+		State:         st.State,
+		LeaderId:      st.LeaderID,
+		LeaderAddress: st.LeaderAddress,
+		Term:          st.Term,
+		CommitIndex:   st.CommitIndex,
+		AppliedIndex:  st.AppliedIndex,
+	}
+	for _, member := range st.Members {
+		resp.Nodes = append(resp.Nodes, &pb.ClusterNode{
+			NodeId:      member.ID,
+			RaftAddress: member.RaftAddress,
+			Address:     member.Address,
+			Voter:       member.Voter,
+			Leader:      member.Leader,
+		})
+	}
+
+	s.updateRequestStats()
+	return resp, nil
+}
+
+// checkClusterNode rejects cluster operations on servers not in cluster mode
+func (s *Server) checkClusterNode() error {
+	if s.cluster == nil {
+		return status.Error(codes.FailedPrecondition, "server is not running in cluster mode")
+	}
+	return nil
+}
+
+// checkStandalone rejects operations that replace the state of a cluster node, whose
+// state only the Raft log changes
+func (s *Server) checkStandalone(operation string) error {
+	if s.cluster != nil {
+		return status.Errorf(codes.FailedPrecondition, "%s is not supported in cluster mode", operation)
+	}
+	return nil
+}
+
+// startCluster joins the Raft group. The state is rebuilt from the Raft snapshot and log,
+// so what the AOF holds is discarded first.
+func (s *Server) startCluster(ctx context.Context) error {
+	if err := s.persistence.Reset(ctx); err != nil {
+		return err
+	}
+
+	// Only the leader expires vectors, through the log
+	s.engine.PauseExpiry(true)
+
+	config := s.config.ClusterConfig
+	if config.Password == "" && len(s.config.Passwords) > 0 {
+		config.Password = s.config.Passwords[0]
+	}
+	node, err := cluster.Start(config, clusterStore{s: s}, s.logger)
+	if err != nil {
+		return err
+	}
+	s.cluster = node
+
+	expiryCtx, cancel := context.WithCancel(ctx)
+	s.stopCluster = func() {
+		cancel()
+		if err := node.Shutdown(); err != nil {
+			s.logger.Error(ctx, "Failed to shut down cluster node", err, nil)
+		}
+	}
+	go s.runClusterExpiry(expiryCtx)
+	return nil
+}
+
+// runClusterExpiry proposes the deletion of expired vectors while the node is the leader,
+// so that every node removes them at the same point of the log
+func (s *Server) runClusterExpiry(ctx context.Context) {
+	ticker := time.NewTicker(database.DefaultExpireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !s.cluster.IsLeader() {
+				continue
+			}
+			for _, expired := range s.engine.ExpiredVectors(now) {
+				command := s.commands.DeleteVectors(expired.Database, expired.Collection, expired.IDs)
+				if _, err := s.cluster.Propose(ctx, command); err != nil {
+					s.logger.Warn(ctx, "Failed to expire vectors", map[string]interface{}{
+						"database":     expired.Database,
+						"collection":   expired.Collection,
+						"vector_count": len(expired.IDs),
+						"error":        err.Error(),
+					})
+					break
+				}
+			}
+		}
+	}
+}
+
+// setInsertedIDs sets the IDs the cluster generated for inserted vectors, which the
+// collection of a standalone server sets itself
+func setInsertedIDs(vectors []types.Vector, result cluster.Result) {
+	for i := 0; i < len(vectors) && i < len(result.InsertedIDs); i++ {
+		vectors[i].ID = result.InsertedIDs[i]
+	}
+}
+
+// clusterStore applies the Raft log of a cluster node to the engine and logs it to the
+// AOF of the node
+type clusterStore struct {
+	s *Server
+}
+
+func (c clusterStore) ApplyCommand(ctx context.Context, command types.AOFCommand) (cluster.Result, error) {
+	// Hold off snapshot cuts until the change is logged
+	c.s.engine.BeginWrite()
+	defer c.s.engine.EndWrite()
+
+	// Removed vectors are counted the same on every node, as they apply the same commands
+	var dbName, collName string
+	switch command.Command {
+	case "DELETE_VECTORS":
+		dbName, collName = command.Database, command.Collection
+	case "DROP_PARTITION":
+		dbName = command.Database
+		collName, _ = command.Args["name"].(string)
+	}
+	before := c.vectorCount(ctx, dbName, collName)
+
+	if err := c.s.engine.ApplyCommand(ctx, command); err != nil {
+		return cluster.Result{}, err
+	}
+	if err := c.s.persistence.WriteAOF(ctx, command); err != nil {
+		return cluster.Result{}, err
+	}
+
+	var result cluster.Result
+	if collName != "" {
+		result.Deleted = before - c.vectorCount(ctx, dbName, collName)
+	}
+	// The collection set the IDs of the vectors it generated
+	if vectors, ok := command.Args["vectors"].([]types.Vector); ok && command.Command == "INSERT_VECTORS" {
+		result.InsertedIDs = make([]uint64, len(vectors))
+		for i, vector := range vectors {
+			result.InsertedIDs[i] = vector.ID
+		}
+	}
+	return result, nil
+}
+
+func (c clusterStore) CutSnapshot(ctx context.Context) (cluster.Snapshot, error) {
+	snapshot, err := c.s.persistence.CutSnapshot(ctx, func() {})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (c clusterStore) LoadSnapshot(ctx context.Context, reader io.Reader) error {
+	return c.s.persistence.LoadSnapshot(ctx, reader)
+}
+
+// vectorCount returns the number of vectors in a collection, 0 if there is none
+func (c clusterStore) vectorCount(ctx context.Context, dbName, collName string) int64 {
+	if collName == "" {
+		return 0
+	}
+	db, err := c.s.engine.GetDatabase(ctx, dbName)
+	if err != nil {
+		return 0
+	}
+	collection, err := db.GetCollection(ctx, collName)
+	if err != nil {
+		return 0
+	}
+	count, _ := collection.Count(ctx)
+	return count
+}
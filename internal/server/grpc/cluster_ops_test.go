@@ -0,0 +1,332 @@
+// Package grpc provides tests for cluster mode in the gRPC server.
+package grpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/cluster"
+	"github.com/scintirete/scintirete/internal/core/database"
+	"github.com/scintirete/scintirete/internal/embedding"
+	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// clusterTestServer is a cluster node serving gRPC on addr
+type clusterTestServer struct {
+	*Server
+	addr string
+	stop func()
+}
+
+// startClusterTestServer starts a cluster node listening on local ports
+func startClusterTestServer(t *testing.T, nodeID string, bootstrap bool) *clusterTestServer {
+	t.Helper()
+
+	// Other nodes forward writes to the gRPC address, so it is known before the node starts
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	dataDir := t.TempDir()
+	srv, err := NewServer(server.ServerConfig{
+		Passwords: []string{"test-password"},
+		PersistenceConfig: persistence.Config{
+			DataDir:         dataDir,
+			RDBFilename:     "dump.rdb",
+			AOFFilename:     "appendonly.aof",
+			AOFSyncStrategy: "no",
+		},
+		EmbeddingConfig: embedding.Config{
+			BaseURL: "http://localhost:8080",
+			APIKey:  "test-key",
+		},
+		ClusterConfig: cluster.Config{
+			NodeID:    nodeID,
+			BindAddr:  "127.0.0.1:0",
+			Address:   listener.Addr().String(),
+			DataDir:   filepath.Join(dataDir, "raft"),
+			Bootstrap: bootstrap,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	if err := srv.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterScintireteServiceServer(grpcServer, srv)
+	go grpcServer.Serve(listener)
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			grpcServer.Stop()
+			srv.Stop(ctx)
+		})
+	}
+	t.Cleanup(stop)
+
+	return &clusterTestServer{Server: srv, addr: listener.Addr().String(), stop: stop}
+}
+
+// waitForClusterLeader waits until one of nodes leads the cluster and followers know
+// where to forward writes
+func waitForClusterLeader(t *testing.T, nodes ...*clusterTestServer) *clusterTestServer {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		for _, node := range nodes {
+			st, err := node.GetClusterStatus(context.Background(), &pb.GetClusterStatusRequest{
+				Auth: &pb.AuthInfo{Password: "test-password"},
+			})
+			if err == nil && st.State == "leader" && st.LeaderAddress == node.addr {
+				return node
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("No cluster leader elected")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForClusterFollower waits until node follows leader
+func waitForClusterFollower(t *testing.T, node, leader *clusterTestServer) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		st, err := node.GetClusterStatus(context.Background(), &pb.GetClusterStatusRequest{
+			Auth: &pb.AuthInfo{Password: "test-password"},
+		})
+		if err == nil && st.State == "follower" && st.LeaderAddress == leader.addr {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Node does not follow %s: %v, %v", leader.addr, st, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForClusterVectorCount waits until the test collection of srv holds count vectors
+func waitForClusterVectorCount(t *testing.T, srv *Server, count int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		info, err := srv.GetCollectionInfo(context.Background(), &pb.GetCollectionInfoRequest{
+			Auth:           &pb.AuthInfo{Password: "test-password"},
+			DbName:         "testdb",
+			CollectionName: "testcoll",
+		})
+		if err == nil && info.VectorCount == count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Test collection did not reach %d vectors: %v, %v", count, info, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestClusterMode(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+
+	first := startClusterTestServer(t, "n1", true)
+	waitForClusterLeader(t, first)
+	second := startClusterTestServer(t, "n2", false)
+	third := startClusterTestServer(t, "n3", false)
+
+	if _, err := first.AddClusterNode(ctx, &pb.AddClusterNodeRequest{
+		Auth: auth, NodeId: "n2", RaftAddress: second.cluster.RaftAddress(), Address: second.addr,
+	}); err != nil {
+		t.Fatalf("AddClusterNode failed: %v", err)
+	}
+	waitForClusterFollower(t, second, first)
+	// Followers forward membership changes to the leader
+	if _, err := second.AddClusterNode(ctx, &pb.AddClusterNodeRequest{
+		Auth: auth, NodeId: "n3", RaftAddress: third.cluster.RaftAddress(), Address: third.addr,
+	}); err != nil {
+		t.Fatalf("AddClusterNode through a follower failed: %v", err)
+	}
+	waitForClusterFollower(t, third, first)
+
+	st, err := third.GetClusterStatus(ctx, &pb.GetClusterStatusRequest{Auth: auth})
+	if err != nil {
+		t.Fatalf("GetClusterStatus failed: %v", err)
+	}
+	if st.NodeId != "n3" || st.LeaderId != "n1" || len(st.Nodes) != 3 {
+		t.Errorf("Expected n3 following n1 in a cluster of 3, got %+v", st)
+	}
+
+	// Writes to a follower are forwarded to the leader and readable on the follower at once
+	setupTestData(t, second.Server)
+	if count := replicationTestVectorCount(t, second.Server); count != 3 {
+		t.Errorf("Expected 3 vectors on the follower that took the writes, got %d", count)
+	}
+	waitForClusterVectorCount(t, first.Server, 3)
+	waitForClusterVectorCount(t, third.Server, 3)
+
+	// IDs and counts are those the leader returned
+	insertResp, err := third.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Vectors:        []*pb.Vector{{Elements: []float32{1, 1, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	if len(insertResp.InsertedIds) != 1 || insertResp.InsertedIds[0] != 4 {
+		t.Errorf("Expected inserted ID 4, got %v", insertResp.InsertedIds)
+	}
+	deleteResp, err := third.DeleteVectors(ctx, &pb.DeleteVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Ids:            []uint64{1, 99},
+	})
+	if err != nil {
+		t.Fatalf("DeleteVectors failed: %v", err)
+	}
+	if deleteResp.DeletedCount != 1 {
+		t.Errorf("Expected 1 deleted vector, got %d", deleteResp.DeletedCount)
+	}
+
+	// Errors of the leader reach the client of the follower
+	_, err = second.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "testdb"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+
+	// Only the Raft log changes the state of a cluster node
+	_, err = second.RestoreBackup(ctx, &pb.RestoreBackupRequest{Auth: auth, Name: "backup.rdb"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition for RestoreBackup, got %v", err)
+	}
+	_, err = second.ReplicaOf(ctx, &pb.ReplicaOfRequest{Auth: auth, Primary: first.addr})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition for ReplicaOf, got %v", err)
+	}
+
+	// The others fail over when the leader goes away
+	first.stop()
+	leader := waitForClusterLeader(t, second, third)
+	follower := second
+	if leader == second {
+		follower = third
+	}
+	waitForClusterFollower(t, follower, leader)
+	if _, err := follower.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Vectors:        []*pb.Vector{{Elements: []float32{0, 1, 1}}},
+	}); err != nil {
+		t.Fatalf("InsertVectors after failover failed: %v", err)
+	}
+	waitForClusterVectorCount(t, leader.Server, 4)
+	waitForClusterVectorCount(t, follower.Server, 4)
+}
+
+func TestClusterInsertResolvedBeforeProposing(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+
+	first := startClusterTestServer(t, "n1", true)
+	waitForClusterLeader(t, first)
+	second := startClusterTestServer(t, "n2", false)
+	if _, err := first.AddClusterNode(ctx, &pb.AddClusterNodeRequest{
+		Auth: auth, NodeId: "n2", RaftAddress: second.cluster.RaftAddress(), Address: second.addr,
+	}); err != nil {
+		t.Fatalf("AddClusterNode failed: %v", err)
+	}
+	waitForClusterFollower(t, second, first)
+
+	if _, err := first.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "testdb"}); err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	ttl := int64(3600)
+	if _, err := first.CreateCollection(ctx, &pb.CreateCollectionRequest{
+		Auth:              auth,
+		DbName:            "testdb",
+		CollectionName:    "testcoll",
+		MetricType:        pb.DistanceMetric_L2,
+		DefaultTtlSeconds: &ttl,
+	}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+
+	// The default TTL is resolved into the command, so every node expires the vector alike
+	before := time.Now().Unix()
+	if _, err := first.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Vectors:        []*pb.Vector{{Elements: []float32{1, 0, 0}}},
+	}); err != nil {
+		t.Fatalf("InsertVectors failed: %v", err)
+	}
+	after := time.Now().Unix()
+	waitForClusterVectorCount(t, second.Server, 1)
+
+	var expireAt []int64
+	for _, node := range []*clusterTestServer{first, second} {
+		db, err := node.engine.GetDatabase(ctx, "testdb")
+		if err != nil {
+			t.Fatalf("GetDatabase failed: %v", err)
+		}
+		collection, err := db.GetCollection(ctx, "testcoll")
+		if err != nil {
+			t.Fatalf("GetCollection failed: %v", err)
+		}
+		vector, err := collection.Get(ctx, "1")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		expireAt = append(expireAt, vector.ExpireAt)
+	}
+	if expireAt[0] < before+ttl || expireAt[0] > after+ttl || expireAt[1] != expireAt[0] {
+		t.Errorf("Expected the same expiry within [%d, %d] on every node, got %v", before+ttl, after+ttl, expireAt)
+	}
+
+	// Writes over the memory limit are rejected before they reach the Raft log
+	first.engine.SetMemoryLimit(database.MemoryLimit{MaxBytes: 1})
+	_, err := first.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Vectors:        []*pb.Vector{{Elements: []float32{0, 1, 0}}},
+	})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted over the memory limit, got %v", err)
+	}
+	if count := replicationTestVectorCount(t, second.Server); count != 1 {
+		t.Errorf("Expected the rejected write to leave the follower alone, got %d vectors", count)
+	}
+}
+
+func TestClusterOperationsRequireClusterMode(t *testing.T) {
+	srv, _ := startReplicationTestServer(t)
+	_, err := srv.GetClusterStatus(context.Background(), &pb.GetClusterStatusRequest{
+		Auth: &pb.AuthInfo{Password: "test-password"},
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+}
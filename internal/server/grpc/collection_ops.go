@@ -18,6 +18,11 @@ func (s *Server) CreateCollection(ctx context.Context, req *pb.CreateCollectionR
 		return nil, err
 	}
 
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	// Validate input
 	if req.DbName == "" {
 		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
@@ -42,6 +47,22 @@ func (s *Server) CreateCollection(ctx context.Context, req *pb.CreateCollectionR
 		config.HNSWParams = types.DefaultHNSWParams()
 	}
 
+	// Set collection default TTL
+	if req.DefaultTtlSeconds != nil {
+		if *req.DefaultTtlSeconds < 0 {
+			return nil, status.Error(codes.InvalidArgument, "default TTL must not be negative")
+		}
+		config.DefaultTTLSeconds = *req.DefaultTtlSeconds
+	}
+
+	// A list of shards creates a sharded collection, routed by this server
+	if len(req.Shards) > 0 {
+		return s.createShardedCollection(ctx, req, config)
+	}
+	if s.isSharded(req.DbName, req.CollectionName) {
+		return nil, s.convertError(utils.ErrCollectionAlreadyExists(req.DbName, req.CollectionName))
+	}
+
 	// Get database
 	db, err := s.engine.GetDatabase(ctx, req.DbName)
 	if err != nil {
@@ -52,16 +73,10 @@ func (s *Server) CreateCollection(ctx context.Context, req *pb.CreateCollectionR
 	}
 
 	// Create collection
-	if err := db.CreateCollection(ctx, config); err != nil {
-		if utils.IsScintireteError(err) {
-			return nil, s.convertError(err)
-		}
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
-	// Log to persistence
-	if err := s.persistence.LogCreateCollection(ctx, req.DbName, req.CollectionName, config); err != nil {
-		return nil, status.Error(codes.Internal, "failed to log create collection operation")
+	if _, err := s.applyWrite(ctx, s.commands.CreateCollection(req.DbName, req.CollectionName, config), func() error {
+		return db.CreateCollection(ctx, config)
+	}); err != nil {
+		return nil, err
 	}
 
 	// Log to audit
@@ -96,6 +111,11 @@ func (s *Server) DropCollection(ctx context.Context, req *pb.DropCollectionReque
 		return nil, err
 	}
 
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	// Validate input
 	if req.DbName == "" {
 		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
@@ -104,6 +124,11 @@ func (s *Server) DropCollection(ctx context.Context, req *pb.DropCollectionReque
 		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
 	}
 
+	// Sharded collections are dropped on their shards
+	if s.isSharded(req.DbName, req.CollectionName) {
+		return s.dropShardedCollection(ctx, req)
+	}
+
 	// Get database
 	db, err := s.engine.GetDatabase(ctx, req.DbName)
 	if err != nil {
@@ -121,16 +146,10 @@ func (s *Server) DropCollection(ctx context.Context, req *pb.DropCollectionReque
 	}
 
 	// Drop collection
-	if err := db.DropCollection(ctx, req.CollectionName); err != nil {
-		if utils.IsScintireteError(err) {
-			return nil, s.convertError(err)
-		}
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
-	// Log to persistence
-	if err := s.persistence.LogDropCollection(ctx, req.DbName, req.CollectionName); err != nil {
-		return nil, status.Error(codes.Internal, "failed to log drop collection operation")
+	if _, err := s.applyWrite(ctx, s.commands.DropCollection(req.DbName, req.CollectionName), func() error {
+		return db.DropCollection(ctx, req.CollectionName)
+	}); err != nil {
+		return nil, err
 	}
 
 	// Log to audit
@@ -163,6 +182,16 @@ func (s *Server) GetCollectionInfo(ctx context.Context, req *pb.GetCollectionInf
 		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
 	}
 
+	// Sum up the shards of a sharded collection
+	if s.isSharded(req.DbName, req.CollectionName) {
+		info, err := s.shards.Info(ctx, req.DbName, req.CollectionName)
+		if err != nil {
+			return nil, s.writeError(err)
+		}
+		s.updateRequestStats()
+		return info, nil
+	}
+
 	// Get database
 	db, err := s.engine.GetDatabase(ctx, req.DbName)
 	if err != nil {
@@ -218,6 +247,266 @@ func (s *Server) ListCollections(ctx context.Context, req *pb.ListCollectionsReq
 		pbCollections[i] = collection.ToProto()
 	}
 
+	// Add the sharded collections of the database
+	for _, m := range s.shards.List(req.DbName) {
+		info, err := s.shards.Info(ctx, req.DbName, m.Collection)
+		if err != nil {
+			return nil, s.writeError(err)
+		}
+		pbCollections = append(pbCollections, info)
+	}
+
 	s.updateRequestStats()
 	return &pb.ListCollectionsResponse{Collections: pbCollections}, nil
 }
+
+// RenameCollection renames a collection within a database
+func (s *Server) RenameCollection(ctx context.Context, req *pb.RenameCollectionRequest) (*pb.RenameCollectionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if req.NewName == "" {
+		return nil, status.Error(codes.InvalidArgument, "new collection name cannot be empty")
+	}
+
+	// Rename collection
+	if _, err := s.applyWrite(ctx, s.commands.RenameCollection(req.DbName, req.CollectionName, req.NewName), func() error {
+		return s.engine.RenameCollection(ctx, req.DbName, req.CollectionName, req.NewName)
+	}); err != nil {
+		return nil, err
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "RenameCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+		"new_name":       req.NewName,
+	})
+
+	s.updateRequestStats()
+	return &pb.RenameCollectionResponse{
+		DbName:  req.DbName,
+		OldName: req.CollectionName,
+		NewName: req.NewName,
+		Success: true,
+		Message: "Collection renamed successfully",
+	}, nil
+}
+
+// CloneCollection deep copies a collection, including its HNSW graph, within a database
+func (s *Server) CloneCollection(ctx context.Context, req *pb.CloneCollectionRequest) (*pb.CloneCollectionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if req.TargetName == "" {
+		return nil, status.Error(codes.InvalidArgument, "target collection name cannot be empty")
+	}
+
+	// Clone collection
+	if _, err := s.applyWrite(ctx, s.commands.CloneCollection(req.DbName, req.CollectionName, req.TargetName), func() error {
+		return s.engine.CloneCollection(ctx, req.DbName, req.CollectionName, req.TargetName)
+	}); err != nil {
+		return nil, err
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "CloneCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+		"target_name":    req.TargetName,
+	})
+
+	// Get cloned collection info for response
+	var vectorCount int64
+	if db, err := s.engine.GetDatabase(ctx, req.DbName); err == nil {
+		if info, err := db.GetCollectionInfo(ctx, req.TargetName); err == nil {
+			vectorCount = info.VectorCount
+		}
+	}
+
+	s.updateRequestStats()
+	return &pb.CloneCollectionResponse{
+		DbName:      req.DbName,
+		SourceName:  req.CollectionName,
+		TargetName:  req.TargetName,
+		Success:     true,
+		Message:     "Collection cloned successfully",
+		VectorCount: vectorCount,
+	}, nil
+}
+
+// CopyCollection deep copies a collection, including its HNSW graph, into another database
+func (s *Server) CopyCollection(ctx context.Context, req *pb.CopyCollectionRequest) (*pb.CopyCollectionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if req.TargetDbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "target database name cannot be empty")
+	}
+
+	// Default to the source collection name
+	targetName := req.TargetName
+	if targetName == "" {
+		targetName = req.CollectionName
+	}
+
+	// Copy collection
+	command := s.commands.CopyCollection(req.DbName, req.CollectionName, req.TargetDbName, targetName)
+	if _, err := s.applyWrite(ctx, command, func() error {
+		return s.engine.CopyCollection(ctx, req.DbName, req.CollectionName, req.TargetDbName, targetName)
+	}); err != nil {
+		return nil, err
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "CopyCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+		"target_db_name": req.TargetDbName,
+		"target_name":    targetName,
+	})
+
+	// Get copied collection info for response
+	var vectorCount int64
+	if db, err := s.engine.GetDatabase(ctx, req.TargetDbName); err == nil {
+		if info, err := db.GetCollectionInfo(ctx, targetName); err == nil {
+			vectorCount = info.VectorCount
+		}
+	}
+
+	s.updateRequestStats()
+	return &pb.CopyCollectionResponse{
+		SourceDbName: req.DbName,
+		SourceName:   req.CollectionName,
+		TargetDbName: req.TargetDbName,
+		TargetName:   targetName,
+		Success:      true,
+		Message:      "Collection copied successfully",
+		VectorCount:  vectorCount,
+	}, nil
+}
+
+// LoadCollection loads a released collection back into memory
+func (s *Server) LoadCollection(ctx context.Context, req *pb.LoadCollectionRequest) (*pb.LoadCollectionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+
+	// Load collection
+	if err := s.engine.LoadCollection(ctx, req.DbName, req.CollectionName); err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "LoadCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+	})
+
+	// Get loaded collection info for response
+	var collectionInfo *pb.CollectionInfo
+	if db, err := s.engine.GetDatabase(ctx, req.DbName); err == nil {
+		if info, err := db.GetCollectionInfo(ctx, req.CollectionName); err == nil {
+			collectionInfo = info.ToProto()
+		}
+	}
+
+	s.updateRequestStats()
+	return &pb.LoadCollectionResponse{
+		DbName:         req.DbName,
+		CollectionName: req.CollectionName,
+		Success:        true,
+		Message:        "Collection loaded successfully",
+		Info:           collectionInfo,
+	}, nil
+}
+
+// ReleaseCollection writes a collection to disk and frees its memory
+func (s *Server) ReleaseCollection(ctx context.Context, req *pb.ReleaseCollectionRequest) (*pb.ReleaseCollectionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+
+	// Release collection
+	freedBytes, err := s.engine.ReleaseCollection(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "ReleaseCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+		"freed_bytes":    freedBytes,
+	})
+
+	s.updateRequestStats()
+	return &pb.ReleaseCollectionResponse{
+		DbName:         req.DbName,
+		CollectionName: req.CollectionName,
+		Success:        true,
+		Message:        "Collection released successfully",
+		FreedBytes:     freedBytes,
+	}, nil
+}
@@ -0,0 +1,107 @@
+// Package grpc provides unit tests for collection operations in the gRPC server.
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRenameCloneCopyCollection(t *testing.T) {
+	srv := createTestServerForVectorOps(t)
+	setupTestData(t, srv)
+
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+
+	// Rename
+	renameResp, err := srv.RenameCollection(ctx, &pb.RenameCollectionRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		NewName:        "renamed",
+	})
+	if err != nil {
+		t.Fatalf("RenameCollection failed: %v", err)
+	}
+	if !renameResp.Success || renameResp.NewName != "renamed" {
+		t.Errorf("Unexpected rename response: %+v", renameResp)
+	}
+
+	_, err = srv.GetCollectionInfo(ctx, &pb.GetCollectionInfoRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for old collection name, got %v", err)
+	}
+
+	// Clone
+	cloneResp, err := srv.CloneCollection(ctx, &pb.CloneCollectionRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "renamed",
+		TargetName:     "cloned",
+	})
+	if err != nil {
+		t.Fatalf("CloneCollection failed: %v", err)
+	}
+	if cloneResp.VectorCount != 3 {
+		t.Errorf("Expected 3 cloned vectors, got %d", cloneResp.VectorCount)
+	}
+
+	_, err = srv.CloneCollection(ctx, &pb.CloneCollectionRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "renamed",
+		TargetName:     "cloned",
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists when cloning onto existing collection, got %v", err)
+	}
+
+	// Copy across databases, defaulting the target name
+	if _, err := srv.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "otherdb"}); err != nil {
+		t.Fatalf("Failed to create target database: %v", err)
+	}
+	copyResp, err := srv.CopyCollection(ctx, &pb.CopyCollectionRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "cloned",
+		TargetDbName:   "otherdb",
+	})
+	if err != nil {
+		t.Fatalf("CopyCollection failed: %v", err)
+	}
+	if copyResp.TargetName != "cloned" || copyResp.VectorCount != 3 {
+		t.Errorf("Unexpected copy response: %+v", copyResp)
+	}
+
+	searchResp, err := srv.Search(ctx, &pb.SearchRequest{
+		Auth:           auth,
+		DbName:         "otherdb",
+		CollectionName: "cloned",
+		QueryVector:    []float32{1.0, 0.0, 0.0},
+		TopK:           1,
+	})
+	if err != nil {
+		t.Fatalf("Search on copied collection failed: %v", err)
+	}
+	if len(searchResp.Results) != 1 || searchResp.Results[0].Id != 1 {
+		t.Errorf("Unexpected search results on copied collection: %+v", searchResp.Results)
+	}
+
+	// Validation
+	_, err = srv.CopyCollection(ctx, &pb.CopyCollectionRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "cloned",
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without target database, got %v", err)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 
 	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
-	"github.com/scintirete/scintirete/internal/utils"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -17,23 +16,21 @@ func (s *Server) CreateDatabase(ctx context.Context, req *pb.CreateDatabaseReque
 		return nil, err
 	}
 
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	// Validate input
 	if req.Name == "" {
 		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
 	}
 
 	// Create database
-	if err := s.engine.CreateDatabase(ctx, req.Name); err != nil {
-		if utils.IsScintireteError(err) {
-			return nil, s.convertError(err)
-		}
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
-	// Log to persistence
-	if err := s.persistence.LogCreateDatabase(ctx, req.Name); err != nil {
-		// Database was created but logging failed - this is serious
-		return nil, status.Error(codes.Internal, "failed to log create database operation")
+	if _, err := s.applyWrite(ctx, s.commands.CreateDatabase(req.Name), func() error {
+		return s.engine.CreateDatabase(ctx, req.Name)
+	}); err != nil {
+		return nil, err
 	}
 
 	// Log to audit
@@ -56,6 +53,11 @@ func (s *Server) DropDatabase(ctx context.Context, req *pb.DropDatabaseRequest)
 		return nil, err
 	}
 
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	// Validate input
 	if req.Name == "" {
 		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
@@ -69,17 +71,17 @@ func (s *Server) DropDatabase(ctx context.Context, req *pb.DropDatabaseRequest)
 		}
 	}
 
-	// Drop database
-	if err := s.engine.DropDatabase(ctx, req.Name); err != nil {
-		if utils.IsScintireteError(err) {
-			return nil, s.convertError(err)
-		}
-		return nil, status.Error(codes.Internal, err.Error())
+	// Drop the sharded collections of the database on their shards first
+	droppedCollections += int32(len(s.shards.List(req.Name)))
+	if err := s.shards.DropDatabase(ctx, req.Name); err != nil {
+		return nil, s.writeError(err)
 	}
 
-	// Log to persistence
-	if err := s.persistence.LogDropDatabase(ctx, req.Name); err != nil {
-		return nil, status.Error(codes.Internal, "failed to log drop database operation")
+	// Drop database
+	if _, err := s.applyWrite(ctx, s.commands.DropDatabase(req.Name), func() error {
+		return s.engine.DropDatabase(ctx, req.Name)
+	}); err != nil {
+		return nil, err
 	}
 
 	// Log to audit
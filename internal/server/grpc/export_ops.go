@@ -0,0 +1,219 @@
+// Package grpc provides collection export operations for the gRPC server.
+package grpc
+
+import (
+	"context"
+	"sort"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultExportBatch = 1000
+	maxExportBatch     = 10000
+)
+
+// ExportCollection streams the info, the vectors and optionally the HNSW graphs of a collection
+func (s *Server) ExportCollection(req *pb.ExportCollectionRequest, stream grpc.ServerStreamingServer[pb.ExportCollectionResponse]) error {
+	return s.ExportVectors(stream.Context(), req, stream.Send)
+}
+
+// ExportVectors passes the messages of the export of a collection to send, until the
+// export is complete or send fails. The HTTP gateway writes them as JSON lines.
+// Vectors are scanned a batch at a time while writes go on, so the export is not a
+// snapshot of a single point in time.
+func (s *Server) ExportVectors(ctx context.Context, req *pb.ExportCollectionRequest, send func(*pb.ExportCollectionResponse) error) error {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if req.BatchSize < 0 || req.BatchSize > maxExportBatch {
+		return status.Errorf(codes.InvalidArgument, "batch size must be between 1 and %d", maxExportBatch)
+	}
+	batch := int(req.BatchSize)
+	if batch == 0 {
+		batch = defaultExportBatch
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "ExportCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "export",
+		"partition_name": req.PartitionName,
+		"include_graph":  req.IncludeGraph,
+	})
+	s.updateRequestStats()
+
+	if s.isSharded(req.DbName, req.CollectionName) {
+		return s.exportSharded(ctx, req, batch, send)
+	}
+
+	// Get database
+	db, err := s.engine.GetDatabase(ctx, req.DbName)
+	if err != nil {
+		return s.writeError(err)
+	}
+
+	// Get collection
+	collection, err := db.GetCollection(ctx, req.CollectionName)
+	if err != nil {
+		return s.writeError(err)
+	}
+	if req.PartitionName != "" {
+		if _, err := collection.GetPartitionInfo(ctx, req.PartitionName); err != nil {
+			return s.writeError(err)
+		}
+	}
+
+	if err := send(&pb.ExportCollectionResponse{Info: collection.Info().ToProto()}); err != nil {
+		return err
+	}
+
+	// Vectors in ascending ID order
+	var afterID uint64
+	for {
+		vectors, err := collection.Scan(ctx, afterID, batch)
+		if err != nil {
+			return s.writeError(err)
+		}
+		if len(vectors) == 0 {
+			break
+		}
+		afterID = vectors[len(vectors)-1].ID
+
+		pbVectors := make([]*pb.Vector, 0, len(vectors))
+		for _, vector := range vectors {
+			if req.PartitionName != "" && partitionOf(vector) != req.PartitionName {
+				continue
+			}
+			id := vector.ID
+			pbVectors = append(pbVectors, &pb.Vector{
+				Id:       &id,
+				Elements: vector.Elements,
+				Metadata: mapToStruct(vector.Metadata),
+				ExpireAt: expireAtToProto(vector.ExpireAt),
+			})
+		}
+		if len(pbVectors) > 0 {
+			if err := send(&pb.ExportCollectionResponse{Vectors: pbVectors}); err != nil {
+				return err
+			}
+		}
+		if len(vectors) < batch {
+			break
+		}
+	}
+
+	if !req.IncludeGraph {
+		return nil
+	}
+	graphs, err := collection.GraphStates(ctx)
+	if err != nil {
+		return s.writeError(err)
+	}
+	names := make([]string, 0, len(graphs))
+	for name := range graphs {
+		if req.PartitionName == "" || name == req.PartitionName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := sendGraph(name, graphs[name], batch, send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportSharded exports a sharded collection, merging the vectors of its shards
+func (s *Server) exportSharded(ctx context.Context, req *pb.ExportCollectionRequest, batch int, send func(*pb.ExportCollectionResponse) error) error {
+	if req.IncludeGraph {
+		return status.Error(codes.InvalidArgument, "sharded collections have a graph per shard, export the graphs from the shards")
+	}
+	if req.PartitionName != "" {
+		return status.Error(codes.InvalidArgument, "sharded collections have no partitions")
+	}
+
+	info, err := s.shards.Info(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return s.writeError(err)
+	}
+	if err := send(&pb.ExportCollectionResponse{Info: info}); err != nil {
+		return err
+	}
+
+	var afterID uint64
+	for {
+		vectors, err := s.shards.Scan(ctx, req.DbName, req.CollectionName, afterID, batch)
+		if err != nil {
+			return s.writeError(err)
+		}
+		if len(vectors) == 0 {
+			return nil
+		}
+		if err := send(&pb.ExportCollectionResponse{Vectors: vectors}); err != nil {
+			return err
+		}
+		if len(vectors) < batch {
+			return nil
+		}
+		afterID = vectors[len(vectors)-1].GetId()
+	}
+}
+
+// sendGraph sends the header of the graph of a partition, then its nodes in ascending ID
+// order, batch nodes per message
+func sendGraph(partition string, graph core.HNSWGraphState, batch int, send func(*pb.ExportCollectionResponse) error) error {
+	if err := send(&pb.ExportCollectionResponse{Graph: &pb.HnswGraphHeader{
+		PartitionName: partition,
+		EntryPoint:    graph.EntryPoint,
+		MaxLayer:      int32(graph.MaxLayer),
+		NodeCount:     int64(len(graph.Nodes)),
+	}}); err != nil {
+		return err
+	}
+
+	ids := make([]uint64, 0, len(graph.Nodes))
+	for id := range graph.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for start := 0; start < len(ids); start += batch {
+		end := min(start+batch, len(ids))
+		nodes := make([]*pb.HnswGraphNode, 0, end-start)
+		for _, id := range ids[start:end] {
+			node := graph.Nodes[id]
+			layers := make([]*pb.HnswNeighbors, len(node.Connections))
+			for layer, neighbors := range node.Connections {
+				layers[layer] = &pb.HnswNeighbors{Ids: neighbors}
+			}
+			nodes = append(nodes, &pb.HnswGraphNode{Id: id, Deleted: node.Deleted, Layers: layers})
+		}
+		if err := send(&pb.ExportCollectionResponse{GraphNodes: nodes}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionOf returns the partition a vector is stored in
+func partitionOf(vector types.Vector) string {
+	if vector.Partition == "" {
+		return types.DefaultPartitionName
+	}
+	return vector.Partition
+}
@@ -0,0 +1,114 @@
+// Package grpc provides tests for collection exports of the gRPC server.
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exportTestCollection returns the messages of an export of req
+func exportTestCollection(t *testing.T, srv *Server, req *pb.ExportCollectionRequest) []*pb.ExportCollectionResponse {
+	t.Helper()
+
+	var messages []*pb.ExportCollectionResponse
+	err := srv.ExportVectors(context.Background(), req, func(resp *pb.ExportCollectionResponse) error {
+		messages = append(messages, resp)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	return messages
+}
+
+func TestExportCollection(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+	srv, _ := startReplicationTestServer(t)
+	setupTestData(t, srv)
+
+	if _, err := srv.CreatePartition(ctx, &pb.CreatePartitionRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll", PartitionName: "p1"}); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+	if _, err := srv.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		PartitionName:  "p1",
+		Vectors:        []*pb.Vector{{Elements: []float32{1, 1, 0}}, {Elements: []float32{0, 1, 1}}},
+	}); err != nil {
+		t.Fatalf("Failed to insert vectors: %v", err)
+	}
+
+	// The info first, then the vectors in batches of two in ascending ID order
+	messages := exportTestCollection(t, srv, &pb.ExportCollectionRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll", BatchSize: 2})
+	if messages[0].Info == nil || messages[0].Info.Name != "testcoll" || messages[0].Info.VectorCount != 5 {
+		t.Fatalf("Expected the collection info first, got %+v", messages[0])
+	}
+	var ids []uint64
+	for _, message := range messages[1:] {
+		if len(message.Vectors) == 0 || len(message.Vectors) > 2 {
+			t.Errorf("Expected batches of up to 2 vectors, got %+v", message)
+		}
+		for _, vector := range message.Vectors {
+			ids = append(ids, vector.GetId())
+		}
+	}
+	if len(ids) != 5 {
+		t.Fatalf("Expected 5 vectors exported, got %v", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("Expected ascending IDs, got %v", ids)
+		}
+	}
+	if metadata := messages[1].Vectors[0].Metadata.AsMap(); metadata["category"] != "A" {
+		t.Errorf("Expected the metadata exported, got %v", metadata)
+	}
+
+	// A partition with its graph after the vectors
+	messages = exportTestCollection(t, srv, &pb.ExportCollectionRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll", PartitionName: "p1", IncludeGraph: true})
+	if len(messages) != 4 {
+		t.Fatalf("Expected info, vectors, graph header and nodes, got %d messages", len(messages))
+	}
+	if len(messages[1].Vectors) != 2 {
+		t.Errorf("Expected the 2 vectors of the partition, got %d", len(messages[1].Vectors))
+	}
+	header := messages[2].Graph
+	if header == nil || header.PartitionName != "p1" || header.NodeCount != 2 {
+		t.Fatalf("Expected the graph header of the partition, got %+v", messages[2])
+	}
+	nodes := messages[3].GraphNodes
+	if len(nodes) != 2 || nodes[0].Id != messages[1].Vectors[0].GetId() {
+		t.Fatalf("Expected the nodes of the partition, got %+v", nodes)
+	}
+	if len(nodes[0].Layers) == 0 || len(nodes[0].Layers[0].Ids) != 1 || nodes[0].Layers[0].Ids[0] != nodes[1].Id {
+		t.Errorf("Expected the two nodes connected on layer 0, got %+v", nodes[0].Layers)
+	}
+}
+
+func TestExportCollectionValidates(t *testing.T) {
+	auth := &pb.AuthInfo{Password: "test-password"}
+	srv, _ := startReplicationTestServer(t)
+	setupTestData(t, srv)
+
+	send := func(*pb.ExportCollectionResponse) error { return nil }
+	for _, tc := range []struct {
+		req  *pb.ExportCollectionRequest
+		code codes.Code
+	}{
+		{&pb.ExportCollectionRequest{DbName: "testdb", CollectionName: "testcoll"}, codes.Unauthenticated},
+		{&pb.ExportCollectionRequest{Auth: auth, DbName: "testdb"}, codes.InvalidArgument},
+		{&pb.ExportCollectionRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll", BatchSize: maxExportBatch + 1}, codes.InvalidArgument},
+		{&pb.ExportCollectionRequest{Auth: auth, DbName: "testdb", CollectionName: "missing"}, codes.NotFound},
+		{&pb.ExportCollectionRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll", PartitionName: "missing"}, codes.NotFound},
+	} {
+		if err := srv.ExportVectors(context.Background(), tc.req, send); status.Code(err) != tc.code {
+			t.Errorf("Expected %v for %+v, got %v", tc.code, tc.req, err)
+		}
+	}
+}
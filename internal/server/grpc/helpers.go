@@ -5,9 +5,15 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"strings"
+	"time"
 
 	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/cluster"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/core/database"
 	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -17,18 +23,24 @@ import (
 func (s *Server) convertError(err error) error {
 	if scintErr, ok := err.(*utils.ScintireteError); ok {
 		switch scintErr.Code {
-		case utils.ErrorCodeDatabaseNotFound, utils.ErrorCodeCollectionNotFound, utils.ErrorCodeVectorNotFound:
+		case utils.ErrorCodeDatabaseNotFound, utils.ErrorCodeCollectionNotFound, utils.ErrorCodeVectorNotFound,
+			utils.ErrorCodePartitionNotFound, utils.ErrorCodeBackupNotFound:
 			return status.Error(codes.NotFound, scintErr.Message)
-		case utils.ErrorCodeDatabaseAlreadyExists, utils.ErrorCodeCollectionAlreadyExists:
+		case utils.ErrorCodeDatabaseAlreadyExists, utils.ErrorCodeCollectionAlreadyExists,
+			utils.ErrorCodePartitionAlreadyExists:
 			return status.Error(codes.AlreadyExists, scintErr.Message)
+		case utils.ErrorCodePartitionNotLoaded, utils.ErrorCodeCollectionNotLoaded, utils.ErrorCodeReadOnly:
+			return status.Error(codes.FailedPrecondition, scintErr.Message)
 		case utils.ErrorCodeInvalidParameters, utils.ErrorCodeDimensionMismatch:
 			return status.Error(codes.InvalidArgument, scintErr.Message)
 		case utils.ErrorCodeUnauthorized:
 			return status.Error(codes.Unauthenticated, scintErr.Message)
 		case utils.ErrorCodeForbidden:
 			return status.Error(codes.PermissionDenied, scintErr.Message)
-		case utils.ErrorCodeRateLimited:
+		case utils.ErrorCodeRateLimited, utils.ErrorCodeResource:
 			return status.Error(codes.ResourceExhausted, scintErr.Message)
+		case utils.ErrorCodeCollectionReleased:
+			return status.Error(codes.Unavailable, scintErr.Message)
 		default:
 			return status.Error(codes.Internal, scintErr.Message)
 		}
@@ -37,6 +49,48 @@ func (s *Server) convertError(err error) error {
 	return status.Error(codes.Internal, err.Error())
 }
 
+// applyWrite makes the change command describes: apply makes it to the engine, then the
+// command is logged to the AOF. A cluster node has the Raft group apply the command on
+// every node instead, and the result tells what applying it returned.
+func (s *Server) applyWrite(ctx context.Context, command types.AOFCommand, apply func() error) (cluster.Result, error) {
+	if s.cluster != nil {
+		result, err := s.cluster.Apply(ctx, command)
+		if err != nil {
+			return cluster.Result{}, s.writeError(err)
+		}
+		return result, nil
+	}
+
+	// Hold off snapshot cuts until the change is logged
+	s.engine.BeginWrite()
+	defer s.engine.EndWrite()
+
+	if err := apply(); err != nil {
+		return cluster.Result{}, s.writeError(err)
+	}
+
+	if err := s.persistence.WriteAOF(ctx, command); err != nil {
+		// The engine changed but logging failed - this is serious
+		operation := strings.ToLower(strings.ReplaceAll(command.Command, "_", " "))
+		return cluster.Result{}, status.Errorf(codes.Internal, "failed to log %s operation", operation)
+	}
+	return cluster.Result{}, nil
+}
+
+// writeError converts an error of a write to a gRPC status error
+func (s *Server) writeError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if utils.IsScintireteError(err) {
+		return s.convertError(err)
+	}
+	if cluster.IsUnavailable(err) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
 // isNotFoundError checks if an error is a "not found" type error
 func isNotFoundError(err error) bool {
 	if err == nil {
@@ -48,7 +102,8 @@ func isNotFoundError(err error) bool {
 		switch scintireteErr.Code {
 		case utils.ErrorCodeDatabaseNotFound,
 			utils.ErrorCodeCollectionNotFound,
-			utils.ErrorCodeVectorNotFound:
+			utils.ErrorCodeVectorNotFound,
+			utils.ErrorCodePartitionNotFound:
 			return true
 		}
 	}
@@ -88,3 +143,114 @@ func (s *Server) logAuditOperation(ctx context.Context, operation, database, col
 		s.auditLogger.LogOperation(ctx, operation, database, collection, userID, metadata)
 	}
 }
+
+// handleExpiredVectors logs vectors tombstoned by the TTL expirer to the AOF
+func (s *Server) handleExpiredVectors(ctx context.Context, expired database.ExpiredVectors, err error) {
+	if err != nil {
+		s.logger.Error(ctx, "TTL expiry pass failed", err, nil)
+		return
+	}
+
+	if err := s.persistence.LogDeleteVectors(ctx, expired.Database, expired.Collection, expired.IDs); err != nil {
+		s.logger.Error(ctx, "AOF write failed for expired vectors", err, map[string]interface{}{
+			"database":     expired.Database,
+			"collection":   expired.Collection,
+			"vector_count": len(expired.IDs),
+		})
+		return
+	}
+
+	s.logger.Debug(ctx, "Expired vectors removed", map[string]interface{}{
+		"database":     expired.Database,
+		"collection":   expired.Collection,
+		"vector_count": len(expired.IDs),
+	})
+}
+
+// insertWrite inserts vectors into a collection through applyWrite. The maxmemory policy
+// is enforced and the default TTL of the collection resolved before the command is made,
+// so that every node of a cluster applies the very same vectors.
+func (s *Server) insertWrite(ctx context.Context, dbName, collName string, collection core.Collection, vectors []types.Vector) (cluster.Result, error) {
+	// Enforce maxmemory before growing the collection
+	if err := s.reserveMemory(ctx, dbName, collName, vectors); err != nil {
+		return cluster.Result{}, err
+	}
+
+	// Vectors without an explicit expiry expire the default TTL after now, not after
+	// whenever the command is applied or replayed
+	if ttl := collection.Info().DefaultTTLSeconds; ttl > 0 {
+		expireAt := time.Now().Unix() + ttl
+		for i := range vectors {
+			if vectors[i].ExpireAt == 0 {
+				vectors[i].ExpireAt = expireAt
+			}
+		}
+	}
+
+	return s.applyWrite(ctx, s.commands.InsertVectors(dbName, collName, vectors), func() error {
+		return collection.Insert(ctx, vectors)
+	})
+}
+
+// reserveMemory applies the maxmemory policy before inserting vectors into a collection.
+// Vectors evicted by volatile-ttl are logged to the AOF as deletes, or deleted through
+// the Raft log on a cluster node.
+func (s *Server) reserveMemory(ctx context.Context, dbName, collName string, vectors []types.Vector) error {
+	evicted, err := s.engine.ReserveMemory(ctx, dbName, collName, vectors)
+	for _, batch := range evicted {
+		var logErr error
+		if s.cluster != nil {
+			_, logErr = s.cluster.Apply(ctx, s.commands.DeleteVectors(batch.Database, batch.Collection, batch.IDs))
+		} else {
+			logErr = s.persistence.LogDeleteVectors(ctx, batch.Database, batch.Collection, batch.IDs)
+		}
+		if logErr != nil {
+			s.logger.Error(ctx, "Failed to log evicted vectors", logErr, map[string]interface{}{
+				"database":     batch.Database,
+				"collection":   batch.Collection,
+				"vector_count": len(batch.IDs),
+			})
+			continue
+		}
+
+		s.logger.Info(ctx, "Vectors evicted by maxmemory policy", map[string]interface{}{
+			"database":     batch.Database,
+			"collection":   batch.Collection,
+			"vector_count": len(batch.IDs),
+		})
+	}
+
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return s.convertError(err)
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// resolveExpireAt converts the optional TTL fields of a protobuf vector to an absolute
+// Unix timestamp. expire_at takes precedence over ttl_seconds; 0 means no explicit expiry.
+func resolveExpireAt(vector *pb.Vector, now time.Time) (int64, error) {
+	if vector.ExpireAt != nil {
+		if *vector.ExpireAt <= 0 {
+			return 0, fmt.Errorf("expire_at must be a positive Unix timestamp")
+		}
+		return *vector.ExpireAt, nil
+	}
+	if vector.TtlSeconds != nil {
+		if *vector.TtlSeconds <= 0 {
+			return 0, fmt.Errorf("ttl_seconds must be positive")
+		}
+		return now.Unix() + *vector.TtlSeconds, nil
+	}
+	return 0, nil
+}
+
+// expireAtToProto returns the expiry for protobuf responses, nil if the vector never expires
+func expireAtToProto(expireAt int64) *int64 {
+	if expireAt == 0 {
+		return nil
+	}
+	return &expireAt
+}
@@ -0,0 +1,296 @@
+// Package grpc provides partition operations for the gRPC server.
+package grpc
+
+import (
+	"context"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreatePartition creates a new named partition in a collection
+func (s *Server) CreatePartition(ctx context.Context, req *pb.CreatePartitionRequest) (*pb.PartitionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if err := validatePartitionRequest(req.DbName, req.CollectionName, req.PartitionName); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.partitionCollection(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create partition
+	if _, err := s.applyWrite(ctx, s.commands.CreatePartition(req.DbName, req.CollectionName, req.PartitionName), func() error {
+		return collection.CreatePartition(ctx, req.PartitionName)
+	}); err != nil {
+		return nil, err
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "CreatePartition", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "partition_management",
+		"partition_name": req.PartitionName,
+	})
+
+	s.updateRequestStats()
+	return s.partitionResponse(ctx, collection, req.DbName, req.CollectionName, req.PartitionName, "Partition created successfully"), nil
+}
+
+// DropPartition removes a partition and all of its vectors from a collection
+func (s *Server) DropPartition(ctx context.Context, req *pb.DropPartitionRequest) (*pb.DropPartitionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if err := validatePartitionRequest(req.DbName, req.CollectionName, req.PartitionName); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.partitionCollection(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop partition
+	var droppedVectors int64
+	result, err := s.applyWrite(ctx, s.commands.DropPartition(req.DbName, req.CollectionName, req.PartitionName), func() error {
+		var err error
+		droppedVectors, err = collection.DropPartition(ctx, req.PartitionName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.cluster != nil {
+		droppedVectors = result.Deleted
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "DropPartition", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type":  "partition_management",
+		"partition_name":  req.PartitionName,
+		"dropped_vectors": droppedVectors,
+	})
+
+	s.updateRequestStats()
+	return &pb.DropPartitionResponse{
+		DbName:         req.DbName,
+		CollectionName: req.CollectionName,
+		PartitionName:  req.PartitionName,
+		Success:        true,
+		Message:        "Partition dropped successfully",
+		DroppedVectors: droppedVectors,
+	}, nil
+}
+
+// ListPartitions returns statistics for all partitions of a collection
+func (s *Server) ListPartitions(ctx context.Context, req *pb.ListPartitionsRequest) (*pb.ListPartitionsResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+
+	collection, err := s.partitionCollection(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to protobuf
+	infos := collection.ListPartitions(ctx)
+	partitions := make([]*pb.PartitionInfo, len(infos))
+	for i, info := range infos {
+		partitions[i] = info.ToProto()
+	}
+
+	s.updateRequestStats()
+	return &pb.ListPartitionsResponse{
+		Partitions: partitions,
+	}, nil
+}
+
+// LoadPartition rebuilds the index of a released partition so it can be searched again
+func (s *Server) LoadPartition(ctx context.Context, req *pb.LoadPartitionRequest) (*pb.PartitionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if err := validatePartitionRequest(req.DbName, req.CollectionName, req.PartitionName); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.partitionCollection(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load partition
+	if err := collection.LoadPartition(ctx, req.PartitionName); err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "LoadPartition", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "partition_management",
+		"partition_name": req.PartitionName,
+	})
+
+	s.updateRequestStats()
+	return s.partitionResponse(ctx, collection, req.DbName, req.CollectionName, req.PartitionName, "Partition loaded successfully"), nil
+}
+
+// ReleasePartition frees the index of a partition, excluding it from searches
+func (s *Server) ReleasePartition(ctx context.Context, req *pb.ReleasePartitionRequest) (*pb.PartitionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if err := validatePartitionRequest(req.DbName, req.CollectionName, req.PartitionName); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.partitionCollection(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Release partition
+	if err := collection.ReleasePartition(ctx, req.PartitionName); err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "ReleasePartition", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "partition_management",
+		"partition_name": req.PartitionName,
+	})
+
+	s.updateRequestStats()
+	return s.partitionResponse(ctx, collection, req.DbName, req.CollectionName, req.PartitionName, "Partition released successfully"), nil
+}
+
+// CompactPartition removes deleted vectors of a partition and rebuilds its index
+func (s *Server) CompactPartition(ctx context.Context, req *pb.CompactPartitionRequest) (*pb.PartitionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if err := validatePartitionRequest(req.DbName, req.CollectionName, req.PartitionName); err != nil {
+		return nil, err
+	}
+
+	collection, err := s.partitionCollection(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compact partition
+	if err := collection.CompactPartition(ctx, req.PartitionName); err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "CompactPartition", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "partition_management",
+		"partition_name": req.PartitionName,
+	})
+
+	s.updateRequestStats()
+	return s.partitionResponse(ctx, collection, req.DbName, req.CollectionName, req.PartitionName, "Partition compacted successfully"), nil
+}
+
+// validatePartitionRequest checks the names shared by all single-partition requests
+func validatePartitionRequest(dbName, collName, partitionName string) error {
+	if dbName == "" {
+		return status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if collName == "" {
+		return status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if partitionName == "" {
+		return status.Error(codes.InvalidArgument, "partition name cannot be empty")
+	}
+	return nil
+}
+
+// partitionCollection looks up the collection targeted by a partition request
+func (s *Server) partitionCollection(ctx context.Context, dbName, collName string) (core.Collection, error) {
+	// Get database
+	db, err := s.engine.GetDatabase(ctx, dbName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Get collection
+	collection, err := db.GetCollection(ctx, collName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return collection, nil
+}
+
+// partitionResponse builds a successful partition response including current partition statistics
+func (s *Server) partitionResponse(ctx context.Context, collection core.Collection, dbName, collName, partitionName, message string) *pb.PartitionResponse {
+	var partitionInfo *pb.PartitionInfo
+	if info, err := collection.GetPartitionInfo(ctx, partitionName); err == nil {
+		partitionInfo = info.ToProto()
+	}
+
+	return &pb.PartitionResponse{
+		DbName:         dbName,
+		CollectionName: collName,
+		Success:        true,
+		Message:        message,
+		Info:           partitionInfo,
+	}
+}
@@ -0,0 +1,235 @@
+// Package grpc provides replication operations for the gRPC server.
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/replication"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Sync streams the commands logged to the AOF to a replica, after a snapshot unless the
+// replica can continue where it left off
+func (s *Server) Sync(req *pb.SyncRequest, stream grpc.ServerStreamingServer[pb.SyncResponse]) error {
+	ctx := stream.Context()
+
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return err
+	}
+
+	// The commands a replica applies come from its own primary
+	if replica := s.replica.Load(); replica != nil {
+		return status.Errorf(codes.FailedPrecondition, "server is a replica of %s, chained replication is not supported", replica.Primary())
+	}
+
+	var address string
+	if p, ok := peer.FromContext(ctx); ok {
+		address = p.Addr.String()
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "Sync", "", "", req.Auth, map[string]interface{}{
+		"operation_type": "replication",
+		"replica":        req.ReplicaName,
+		"address":        address,
+		"replication_id": req.ReplicationId,
+		"offset":         req.Offset,
+	})
+	s.updateRequestStats()
+
+	err := s.primary.Sync(ctx, req, address, stream)
+	if ctx.Err() != nil {
+		// The replica disconnected
+		return nil
+	}
+	s.logger.Warn(ctx, "Replication stream to replica ended", map[string]interface{}{
+		"replica": req.ReplicaName,
+		"address": address,
+		"error":   fmt.Sprint(err),
+	})
+	return status.Error(codes.Aborted, fmt.Sprint(err))
+}
+
+// ReplicaOf makes the server a read-only replica of a primary, or a primary again if no
+// primary is given
+func (s *Server) ReplicaOf(ctx context.Context, req *pb.ReplicaOfRequest) (*pb.ReplicaOfResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Cluster nodes replicate through the Raft log
+	if err := s.checkStandalone("ReplicaOf"); err != nil {
+		return nil, err
+	}
+
+	var message string
+	if req.Primary == "" {
+		if s.stopReplica(ctx) {
+			message = "Stopped replication, server is a primary"
+		} else {
+			message = "Server is already a primary"
+		}
+	} else {
+		if _, _, err := net.SplitHostPort(req.Primary); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "primary must be host:port: %v", err)
+		}
+		s.startReplica(ctx, req.Primary, req.PrimaryPassword)
+		message = fmt.Sprintf("Replicating from %s", req.Primary)
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "ReplicaOf", "", "", req.Auth, map[string]interface{}{
+		"operation_type": "replication",
+		"primary":        req.Primary,
+	})
+
+	s.updateRequestStats()
+	return &pb.ReplicaOfResponse{
+		Success: true,
+		Message: message,
+	}, nil
+}
+
+// startReplica starts following primary in place of the primary followed so far. The
+// state of the server is replaced by that of the primary on the first synchronization.
+func (s *Server) startReplica(ctx context.Context, primary, password string) {
+	s.replicaMu.Lock()
+	defer s.replicaMu.Unlock()
+
+	if replica := s.replica.Load(); replica != nil {
+		replica.Stop()
+	}
+
+	// Expired vectors are removed when the deletes of the primary arrive
+	s.engine.PauseExpiry(true)
+	// Replicas of this server followed a state that is about to be replaced
+	s.primary.Feed().Reset()
+
+	name := s.config.ReplicationConfig.ReplicaName
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	s.replica.Store(replication.StartReplica(replication.ReplicaConfig{
+		Primary:  primary,
+		Password: password,
+		Name:     name,
+	}, replicaStore{s}, s.logger))
+
+	s.logger.Info(ctx, "Replicating from primary", map[string]interface{}{
+		"primary": primary,
+		"name":    name,
+	})
+}
+
+// stopReplica stops following the primary and accepts writes again. It reports whether
+// the server was a replica.
+func (s *Server) stopReplica(ctx context.Context) bool {
+	s.replicaMu.Lock()
+	defer s.replicaMu.Unlock()
+
+	replica := s.replica.Load()
+	if replica == nil {
+		return false
+	}
+	// Writes are rejected until no command of the primary is applied anymore
+	replica.Stop()
+	s.replica.Store(nil)
+
+	// Offsets start over, as replicas of this server follow its own writes from now on
+	s.primary.Feed().Reset()
+	s.engine.PauseExpiry(false)
+
+	s.logger.Info(ctx, "Stopped replication, server is a primary", map[string]interface{}{
+		"primary": replica.Primary(),
+	})
+	return true
+}
+
+// checkWritable rejects writes while the server is a replica, whose state only its primary
+// changes
+func (s *Server) checkWritable() error {
+	if replica := s.replica.Load(); replica != nil {
+		return s.convertError(utils.ErrReadOnly(replica.Primary()))
+	}
+	return nil
+}
+
+// replicationInfo describes the role of the server and the state of its replication
+func (s *Server) replicationInfo() *pb.ReplicationInfo {
+	if replica := s.replica.Load(); replica != nil {
+		st := replica.Status()
+		info := &pb.ReplicationInfo{
+			Role:          "replica",
+			ReplicationId: st.ReplicationID,
+			Offset:        st.Offset,
+			Primary:       st.Primary,
+			LinkUp:        st.LinkUp,
+			PrimaryOffset: st.PrimaryOffset,
+			LagCommands:   st.LagCommands(),
+			LagSeconds:    st.Lag.Seconds(),
+			FullSyncs:     st.FullSyncs,
+			PartialSyncs:  st.PartialSyncs,
+			LastError:     st.LastError,
+		}
+		if !st.LastIO.IsZero() {
+			info.LastIoSeconds = time.Since(st.LastIO).Seconds()
+		}
+		return info
+	}
+
+	feed := s.primary.Feed()
+	id, offset := feed.Position()
+	first, size := feed.Backlog()
+	info := &pb.ReplicationInfo{
+		Role:               "primary",
+		ReplicationId:      id,
+		Offset:             offset,
+		BacklogFirstOffset: first,
+		BacklogBytes:       size,
+	}
+	for _, replica := range s.primary.Replicas() {
+		info.Replicas = append(info.Replicas, &pb.ConnectedReplica{
+			Name:        replica.Name,
+			Address:     replica.Address,
+			Offset:      replica.Offset,
+			LagCommands: max(offset-replica.Offset, 0),
+			ConnectedAt: replica.ConnectedAt.Unix(),
+			FullSync:    replica.FullSync,
+		})
+	}
+	return info
+}
+
+// replicaStore applies what a replica receives from its primary to the engine and logs
+// it to the AOF of the replica
+type replicaStore struct {
+	s *Server
+}
+
+func (r replicaStore) LoadSnapshot(ctx context.Context, reader io.Reader) error {
+	return r.s.persistence.LoadSnapshot(ctx, reader)
+}
+
+func (r replicaStore) ApplyCommand(ctx context.Context, command types.AOFCommand) error {
+	// Hold off snapshot cuts until the change is logged
+	r.s.engine.BeginWrite()
+	defer r.s.engine.EndWrite()
+
+	if err := r.s.engine.ApplyCommand(ctx, command); err != nil {
+		return err
+	}
+	return r.s.persistence.WriteAOF(ctx, command)
+}
@@ -0,0 +1,197 @@
+// Package grpc provides tests for replication in the gRPC server.
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/embedding"
+	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// startReplicationTestServer starts a server listening on a local port
+func startReplicationTestServer(t *testing.T) (*Server, listeningServer) {
+	t.Helper()
+
+	srv, err := NewServer(server.ServerConfig{
+		Passwords: []string{"test-password"},
+		PersistenceConfig: persistence.Config{
+			DataDir:         t.TempDir(),
+			RDBFilename:     "dump.rdb",
+			AOFFilename:     "appendonly.aof",
+			AOFSyncStrategy: "no",
+		},
+		EmbeddingConfig: embedding.Config{
+			BaseURL: "http://localhost:8080",
+			APIKey:  "test-key",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	if err := srv.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { srv.Stop(ctx) })
+
+	return srv, serveReplicationTestServer(t, srv, "127.0.0.1:0")
+}
+
+// listeningServer is a gRPC server serving a Server on addr
+type listeningServer struct {
+	*grpc.Server
+	addr string
+}
+
+// serveReplicationTestServer serves srv on addr until the test ends
+func serveReplicationTestServer(t *testing.T, srv *Server, addr string) listeningServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterScintireteServiceServer(grpcServer, srv)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return listeningServer{Server: grpcServer, addr: listener.Addr().String()}
+}
+
+// waitForReplica waits until the replica applied every command of the primary
+func waitForReplica(t *testing.T, primary, replica *Server) *pb.ReplicationInfo {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		_, offset := primary.primary.Feed().Position()
+		info := replica.replicationInfo()
+		if info.LinkUp && info.LagCommands == 0 && info.Offset == offset && info.ReplicationId != "" {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Replica did not catch up with primary offset %d: %+v", offset, info)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// replicationTestVectorCount returns the number of vectors in the test collection
+func replicationTestVectorCount(t *testing.T, srv *Server) int64 {
+	t.Helper()
+
+	info, err := srv.GetCollectionInfo(context.Background(), &pb.GetCollectionInfoRequest{
+		Auth:           &pb.AuthInfo{Password: "test-password"},
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+	})
+	if err != nil {
+		t.Fatalf("Failed to get collection info: %v", err)
+	}
+	return info.VectorCount
+}
+
+func TestReplicaOf(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+
+	primary, primaryListener := startReplicationTestServer(t)
+	primaryAddr := primaryListener.addr
+	replica, _ := startReplicationTestServer(t)
+
+	// Data written before the replica connects arrives with the snapshot
+	setupTestData(t, primary)
+
+	if _, err := replica.ReplicaOf(ctx, &pb.ReplicaOfRequest{Auth: auth, Primary: primaryAddr, PrimaryPassword: "test-password"}); err != nil {
+		t.Fatalf("ReplicaOf failed: %v", err)
+	}
+	info := waitForReplica(t, primary, replica)
+	if info.Role != "replica" || info.FullSyncs != 1 {
+		t.Errorf("Expected a replica after one full resynchronization, got %+v", info)
+	}
+	if count := replicationTestVectorCount(t, replica); count != 3 {
+		t.Errorf("Expected 3 vectors from the snapshot, got %d", count)
+	}
+
+	// Data written afterwards is streamed, metadata included
+	metadata, _ := structpb.NewStruct(map[string]interface{}{"category": "streamed"})
+	if _, err := primary.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Vectors:        []*pb.Vector{{Elements: []float32{1.0, 1.0, 1.0}, Metadata: metadata}},
+	}); err != nil {
+		t.Fatalf("Failed to insert vector on primary: %v", err)
+	}
+	waitForReplica(t, primary, replica)
+	if count := replicationTestVectorCount(t, replica); count != 4 {
+		t.Errorf("Expected 4 vectors after streaming, got %d", count)
+	}
+
+	primaryInfo := primary.replicationInfo()
+	if primaryInfo.Role != "primary" || len(primaryInfo.Replicas) != 1 {
+		t.Errorf("Expected a primary with one replica, got %+v", primaryInfo)
+	}
+
+	// The replica serves searches but rejects writes
+	resp, err := replica.Search(ctx, &pb.SearchRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		QueryVector:    []float32{1.0, 1.0, 1.0},
+		TopK:           1,
+	})
+	if err != nil {
+		t.Fatalf("Search on replica failed: %v", err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Distance != 0 {
+		t.Errorf("Expected the streamed vector as the nearest result, got %+v", resp.Results)
+	} else if category := resp.Results[0].Metadata.AsMap()["category"]; category != "streamed" {
+		t.Errorf("Expected the streamed vector's metadata on the replica, got %+v", resp.Results[0].Metadata)
+	}
+	_, err = replica.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "other"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition for a write on a replica, got %v", err)
+	}
+
+	// A broken link continues where it left off
+	replicationID := info.ReplicationId
+	primaryListener.Stop()
+	serveReplicationTestServer(t, primary, primaryAddr)
+	if _, err := primary.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Vectors:        []*pb.Vector{{Elements: []float32{2.0, 2.0, 2.0}}},
+	}); err != nil {
+		t.Fatalf("Failed to insert vector on primary: %v", err)
+	}
+	info = waitForReplica(t, primary, replica)
+	if info.FullSyncs != 1 || info.PartialSyncs != 1 || info.ReplicationId != replicationID {
+		t.Errorf("Expected a partial resynchronization with replication ID %s, got %+v", replicationID, info)
+	}
+	if count := replicationTestVectorCount(t, replica); count != 5 {
+		t.Errorf("Expected 5 vectors after the partial resynchronization, got %d", count)
+	}
+
+	// Promotion accepts writes again
+	if _, err := replica.ReplicaOf(ctx, &pb.ReplicaOfRequest{Auth: auth}); err != nil {
+		t.Fatalf("ReplicaOf no one failed: %v", err)
+	}
+	if _, err := replica.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "other"}); err != nil {
+		t.Errorf("Expected writes after promotion, got %v", err)
+	}
+	if info := replica.replicationInfo(); info.Role != "primary" || info.ReplicationId == replicationID {
+		t.Errorf("Expected a primary with a new replication ID, got %+v", info)
+	}
+}
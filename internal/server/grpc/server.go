@@ -4,10 +4,14 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/cdc"
+	"github.com/scintirete/scintirete/internal/cluster"
 	"github.com/scintirete/scintirete/internal/core"
 	"github.com/scintirete/scintirete/internal/core/database"
 	"github.com/scintirete/scintirete/internal/embedding"
@@ -15,9 +19,19 @@ import (
 	"github.com/scintirete/scintirete/internal/observability/audit"
 	"github.com/scintirete/scintirete/internal/observability/logger"
 	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/persistence/aof"
+	"github.com/scintirete/scintirete/internal/replication"
 	"github.com/scintirete/scintirete/internal/server"
+	"github.com/scintirete/scintirete/internal/sharding"
+	"github.com/scintirete/scintirete/internal/webhook"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// shardRegistryFilename is the file in the data directory that keeps the shard maps of
+// the sharded collections a server coordinates
+const shardRegistryFilename = "shards.json"
+
 // Server implements the ScintireteService gRPC interface
 type Server struct {
 	pb.UnimplementedScintireteServiceServer
@@ -32,6 +46,24 @@ type Server struct {
 	auth          server.Authenticator
 	systemMonitor *monitoring.SystemMonitor
 
+	// Replication
+	primary   *replication.Primary
+	replicaMu sync.Mutex                          // Serializes starting and stopping the replica
+	replica   atomic.Pointer[replication.Replica] // Set while the server follows a primary
+
+	// Cluster mode
+	cluster     *cluster.Node // Set once a cluster node started
+	stopCluster func()
+
+	// Sharded collections this server coordinates
+	shards *sharding.Router
+
+	// Change events for watchers and webhooks
+	changes  *cdc.Hub
+	webhooks *webhook.Dispatcher
+
+	commands *aof.CommandBuilder
+
 	// Statistics
 	startTime    time.Time
 	requestCount int64
@@ -41,6 +73,8 @@ type Server struct {
 func NewServer(config server.ServerConfig) (*Server, error) {
 	// Create database engine
 	engine := database.NewEngine()
+	engine.SetMemoryLimit(config.MemoryLimit)
+	engine.SetLoadPolicy(config.LoadPolicy)
 
 	// Create persistence manager with database engine connection
 	persistenceManager, err := persistence.NewManagerWithEngine(config.PersistenceConfig, engine)
@@ -68,6 +102,12 @@ func NewServer(config server.ServerConfig) (*Server, error) {
 		"component": "grpc_server",
 	})
 
+	// Pass logged commands to the replication backlog for replicas and to the change
+	// backlog for watchers
+	feed := replication.NewFeed(config.ReplicationConfig.BacklogSize, serverLogger)
+	changes := cdc.NewHub(config.CDCConfig, serverLogger)
+	persistenceManager.SetCommandFeed(persistence.CommandFeeds{feed, changes})
+
 	// Create audit logger
 	var auditLogger *audit.Logger
 	if config.EnableAuditLog {
@@ -89,6 +129,24 @@ func NewServer(config server.ServerConfig) (*Server, error) {
 	// Create system monitor for performance monitoring
 	systemMonitor := monitoring.NewSystemMonitor(serverLogger, config.MonitoringConfig)
 
+	// Route the sharded collections this server coordinates
+	shardingConfig := config.ShardingConfig
+	if shardingConfig.Password == "" && len(config.Passwords) > 0 {
+		shardingConfig.Password = config.Passwords[0]
+	}
+	registryPath := filepath.Join(config.PersistenceConfig.DataDir, shardRegistryFilename)
+	shards, err := sharding.NewRouter(shardingConfig, registryPath, serverLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shard registry: %w", err)
+	}
+
+	// Deliver change events to the configured webhooks
+	webhooks, err := webhook.NewDispatcher(config.WebhookConfig, changes, serverLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhooks: %w", err)
+	}
+	persistenceManager.SetSnapshotListener(webhooks.SnapshotSaved)
+
 	return &Server{
 		engine:        engine,
 		persistence:   persistenceManager,
@@ -98,6 +156,11 @@ func NewServer(config server.ServerConfig) (*Server, error) {
 		auditLogger:   auditLogger,
 		auth:          auth,
 		systemMonitor: systemMonitor,
+		primary:       replication.NewPrimary(feed, persistenceManager, serverLogger),
+		shards:        shards,
+		changes:       changes,
+		webhooks:      webhooks,
+		commands:      aof.NewCommandBuilder(),
 		startTime:     time.Now(),
 	}, nil
 }
@@ -115,11 +178,29 @@ func (s *Server) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to start persistence background tasks: %w", err)
 	}
 
-	// Recover from persistent data
-	if err := s.persistence.Recover(ctx); err != nil {
-		return fmt.Errorf("failed to recover from persistent data: %w", err)
+	if s.config.ClusterConfig.NodeID != "" {
+		// Rebuild the state from the Raft snapshot and log
+		if err := s.startCluster(ctx); err != nil {
+			return fmt.Errorf("failed to start cluster node: %w", err)
+		}
+	} else {
+		// Recover from persistent data
+		if err := s.persistence.Recover(ctx); err != nil {
+			return fmt.Errorf("failed to recover from persistent data: %w", err)
+		}
+
+		// Start TTL expirer after recovery so replayed vectors are expired too
+		s.engine.StartExpirer(ctx, database.DefaultExpireInterval, s.handleExpiredVectors)
+
+		// Follow the configured primary
+		if primary := s.config.ReplicationConfig.ReplicaOf; primary != "" {
+			s.startReplica(ctx, primary, s.config.ReplicationConfig.PrimaryPassword)
+		}
 	}
 
+	// Deliver the changes made from now on to webhooks
+	s.webhooks.Start(ctx)
+
 	s.logger.Info(ctx, "Server started successfully", map[string]interface{}{
 		"system_monitoring_enabled": s.config.MonitoringConfig.Enabled,
 		"monitoring_interval":       fmt.Sprintf("%ds", int(s.config.MonitoringConfig.Interval.Seconds())),
@@ -134,6 +215,29 @@ func (s *Server) Stop(ctx context.Context) error {
 	// Stop system monitoring
 	s.systemMonitor.Stop()
 
+	// Stop applying commands of the primary before persistence stops
+	s.replicaMu.Lock()
+	if replica := s.replica.Load(); replica != nil {
+		replica.Stop()
+	}
+	s.replicaMu.Unlock()
+
+	// Leave the cluster before persistence stops
+	if s.stopCluster != nil {
+		s.stopCluster()
+	}
+
+	// Close the connections to the shards of sharded collections
+	if err := s.shards.Close(); err != nil {
+		s.logger.Warn(ctx, "Failed to close shard connections", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Stop delivering to webhooks, undelivered payloads go to the dead-letter file
+	s.persistence.SetSnapshotListener(nil)
+	s.webhooks.Stop()
+
 	// Stop persistence manager
 	if err := s.persistence.Stop(ctx); err != nil {
 		return fmt.Errorf("failed to stop persistence manager: %w", err)
@@ -192,20 +296,8 @@ func (s *Server) Save(ctx context.Context, req *pb.SaveRequest) (*pb.SaveRespons
 
 	startTime := time.Now()
 
-	// Get current database state
-	databases, err := s.engine.GetDatabaseState(ctx)
-	if err != nil {
-		s.logger.Error(ctx, "Failed to get database state for RDB save", err, map[string]interface{}{
-			"operation": "save_rdb",
-		})
-		return &pb.SaveResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to get database state: %v", err),
-		}, nil
-	}
-
-	// Perform synchronous save
-	err = s.persistence.SaveSnapshot(ctx, databases)
+	// Perform synchronous save of a point-in-time cut, streamed one collection at a time
+	err := s.persistence.SaveEngineSnapshot(ctx)
 	if err != nil {
 		s.logger.Error(ctx, "Failed to save RDB snapshot", err, map[string]interface{}{
 			"operation": "save_rdb",
@@ -265,19 +357,8 @@ func (s *Server) BgSave(ctx context.Context, req *pb.BgSaveRequest) (*pb.BgSaveR
 		})
 		startTime := time.Now()
 
-		// Get current database state
-		databases, err := s.engine.GetDatabaseState(saveCtx)
-		if err != nil {
-			s.logger.Error(saveCtx, "Background RDB save failed - could not get database state", err, map[string]interface{}{
-				"operation": "bgsave",
-				"job_id":    jobID,
-				"duration":  time.Since(startTime),
-			})
-			return
-		}
-
-		// Perform save
-		err = s.persistence.SaveSnapshot(saveCtx, databases)
+		// Perform save of a point-in-time cut, streamed one collection at a time
+		err := s.persistence.SaveEngineSnapshot(saveCtx)
 		duration := time.Since(startTime)
 
 		if err != nil {
@@ -301,3 +382,53 @@ func (s *Server) BgSave(ctx context.Context, req *pb.BgSaveRequest) (*pb.BgSaveR
 		JobId:   jobID,
 	}, nil
 }
+
+// GetServerInfo returns runtime information about the server, including memory limit state
+func (s *Server) GetServerInfo(ctx context.Context, req *pb.GetServerInfoRequest) (*pb.ServerInfo, error) {
+	defer s.updateRequestStats()
+
+	// Authenticate the request
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.engine.GetStats(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	serverStats := s.GetStats()
+
+	return &pb.ServerInfo{
+		UptimeSeconds:    int64(serverStats.Uptime.Seconds()),
+		TotalRequests:    serverStats.RequestCount,
+		TotalDatabases:   int32(stats.TotalDatabases),
+		TotalCollections: int32(stats.TotalCollections),
+		TotalVectors:     stats.TotalVectors,
+		Memory:           stats.Memory.ToProto(),
+		Replication:      s.replicationInfo(),
+		Webhooks:         s.webhookInfo(),
+	}, nil
+}
+
+// webhookInfo returns the delivery metrics of the webhooks
+func (s *Server) webhookInfo() []*pb.WebhookInfo {
+	stats := s.webhooks.Stats()
+	infos := make([]*pb.WebhookInfo, len(stats))
+	for i, hook := range stats {
+		infos[i] = &pb.WebhookInfo{
+			Name:          hook.Name,
+			Url:           hook.URL,
+			Delivered:     hook.Delivered,
+			Retries:       hook.Retries,
+			DeadLettered:  hook.DeadLettered,
+			Missed:        hook.Missed,
+			Pending:       int32(hook.Pending),
+			LastError:     hook.LastError,
+			LastLatencyMs: float64(hook.LastLatency.Microseconds()) / 1000,
+		}
+		if !hook.LastDeliveryAt.IsZero() {
+			infos[i].LastDeliveryAt = hook.LastDeliveryAt.Unix()
+		}
+	}
+	return infos
+}
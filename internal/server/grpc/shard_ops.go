@@ -0,0 +1,361 @@
+// Package grpc provides sharded collection operations for the gRPC server.
+package grpc
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReshardCollection moves the vectors of a sharded collection to a new list of shards
+func (s *Server) ReshardCollection(ctx context.Context, req *pb.ReshardCollectionRequest) (*pb.ReshardCollectionResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if len(req.Shards) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "shards cannot be empty")
+	}
+
+	// Move the vectors, which may take a while
+	start := time.Now()
+	moved, err := s.shards.Reshard(ctx, req.DbName, req.CollectionName, req.Shards)
+	if err != nil {
+		return nil, s.writeError(err)
+	}
+	duration := time.Since(start)
+
+	// Log to audit
+	s.logAuditOperation(ctx, "ReshardCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+		"shards":         req.Shards,
+		"moved_vectors":  moved,
+	})
+
+	// Get collection info for response
+	info, err := s.shards.Info(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		info = nil
+	}
+
+	s.updateRequestStats()
+	return &pb.ReshardCollectionResponse{
+		Success:         true,
+		Message:         "Collection resharded successfully",
+		MovedVectors:    moved,
+		DurationSeconds: duration.Seconds(),
+		Info:            info,
+	}, nil
+}
+
+// ShardInsertVectors inserts vectors with the IDs the coordinator of a sharded collection
+// handed out. Vectors the collection holds already are skipped, so that a resharding can be
+// repeated, and deleted ones are replaced, so that a vector can move back to a shard.
+func (s *Server) ShardInsertVectors(ctx context.Context, req *pb.ShardInsertVectorsRequest) (*pb.ShardInsertVectorsResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if len(req.Vectors) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no vectors provided")
+	}
+
+	// Convert protobuf vectors to internal format, keeping their IDs
+	vectors := make([]types.Vector, len(req.Vectors))
+	ids := make([]uint64, len(req.Vectors))
+	now := time.Now()
+	for i, pbVector := range req.Vectors {
+		if pbVector.GetId() == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "vector[%d]: ID is required", i)
+		}
+		if len(pbVector.Elements) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "vector elements cannot be empty")
+		}
+
+		metadata := make(map[string]interface{})
+		if pbVector.Metadata != nil {
+			metadata = pbVector.Metadata.AsMap()
+		}
+		expireAt, err := resolveExpireAt(pbVector, now)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "vector[%d]: %v", i, err)
+		}
+
+		ids[i] = pbVector.GetId()
+		vectors[i] = types.Vector{
+			ID:       pbVector.GetId(),
+			Elements: pbVector.Elements,
+			Metadata: metadata,
+			ExpireAt: expireAt,
+		}
+	}
+
+	// Get database
+	db, err := s.engine.GetDatabase(ctx, req.DbName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Get collection
+	collection, err := db.GetCollection(ctx, req.CollectionName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Skip the vectors the collection holds already
+	skipped := collection.ExistingIDs(ids)
+	if len(skipped) > 0 {
+		skip := make(map[uint64]bool, len(skipped))
+		for _, id := range skipped {
+			skip[id] = true
+		}
+		kept := vectors[:0]
+		for _, vector := range vectors {
+			if !skip[vector.ID] {
+				kept = append(kept, vector)
+			}
+		}
+		vectors = kept
+	}
+
+	// Insert vectors, logged with their IDs
+	if len(vectors) > 0 {
+		if _, err := s.insertWrite(ctx, req.DbName, req.CollectionName, collection, vectors); err != nil {
+			return nil, err
+		}
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "ShardInsertVectors", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "vector_data",
+		"vector_count":   len(vectors),
+		"skipped_count":  len(skipped),
+	})
+
+	s.updateRequestStats()
+	return &pb.ShardInsertVectorsResponse{
+		InsertedCount: int32(len(vectors)),
+		SkippedIds:    skipped,
+	}, nil
+}
+
+// ScanVectors pages through the live vectors of a collection in ascending ID order
+func (s *Server) ScanVectors(ctx context.Context, req *pb.ScanVectorsRequest) (*pb.ScanVectorsResponse, error) {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	if req.DbName == "" {
+		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if req.CollectionName == "" {
+		return nil, status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	if req.Limit <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be positive")
+	}
+
+	// Get database
+	db, err := s.engine.GetDatabase(ctx, req.DbName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Get collection
+	collection, err := db.GetCollection(ctx, req.CollectionName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Scan the next page
+	vectors, err := collection.Scan(ctx, req.AfterId, int(req.Limit))
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Convert to protobuf
+	pbVectors := make([]*pb.Vector, len(vectors))
+	for i, vector := range vectors {
+		id := vector.ID
+		pbVectors[i] = &pb.Vector{
+			Id:       &id,
+			Elements: vector.Elements,
+			Metadata: mapToStruct(vector.Metadata),
+			ExpireAt: expireAtToProto(vector.ExpireAt),
+		}
+	}
+
+	s.updateRequestStats()
+	return &pb.ScanVectorsResponse{Vectors: pbVectors}, nil
+}
+
+// isSharded reports whether a collection is a sharded collection this server coordinates
+func (s *Server) isSharded(dbName, collName string) bool {
+	_, ok := s.shards.Get(dbName, collName)
+	return ok
+}
+
+// createShardedCollection creates a collection whose vectors are spread over req.Shards
+func (s *Server) createShardedCollection(ctx context.Context, req *pb.CreateCollectionRequest, config types.CollectionConfig) (*pb.CreateCollectionResponse, error) {
+	// The shard map is kept by this server alone, not replicated by the Raft log
+	if err := s.checkStandalone("creating a sharded collection"); err != nil {
+		return nil, err
+	}
+
+	// The database must exist here too, so that the collection is listed in it
+	db, err := s.engine.GetDatabase(ctx, req.DbName)
+	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if _, err := db.GetCollection(ctx, req.CollectionName); err == nil {
+		return nil, s.convertError(utils.ErrCollectionAlreadyExists(req.DbName, req.CollectionName))
+	}
+
+	// Create the collection on every shard
+	if _, err := s.shards.Create(ctx, req.DbName, config, req.Shards); err != nil {
+		return nil, s.writeError(err)
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "CreateCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+		"metric_type":    config.Metric.String(),
+		"hnsw_params":    config.HNSWParams,
+		"shards":         req.Shards,
+	})
+
+	// Get collection info for response
+	info, err := s.shards.Info(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		info = nil
+	}
+
+	s.updateRequestStats()
+	return &pb.CreateCollectionResponse{
+		DbName:         req.DbName,
+		CollectionName: req.CollectionName,
+		Success:        true,
+		Message:        "Sharded collection created successfully",
+		Info:           info,
+	}, nil
+}
+
+// dropShardedCollection drops a sharded collection on all of its shards
+func (s *Server) dropShardedCollection(ctx context.Context, req *pb.DropCollectionRequest) (*pb.DropCollectionResponse, error) {
+	dropped, err := s.shards.Drop(ctx, req.DbName, req.CollectionName)
+	if err != nil {
+		return nil, s.writeError(err)
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "DropCollection", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "collection_management",
+		"sharded":        true,
+	})
+
+	s.updateRequestStats()
+	return &pb.DropCollectionResponse{
+		DbName:         req.DbName,
+		CollectionName: req.CollectionName,
+		Success:        true,
+		Message:        "Collection dropped successfully",
+		DroppedVectors: dropped,
+	}, nil
+}
+
+// insertSharded inserts vectors into a sharded collection and returns their IDs. TTLs are
+// resolved here, so that every shard expires the vectors at the same time.
+func (s *Server) insertSharded(ctx context.Context, dbName, collName, partitionName string, vectors []*pb.Vector) ([]uint64, error) {
+	if partitionName != "" {
+		return nil, status.Error(codes.InvalidArgument, "sharded collections have no partitions")
+	}
+
+	routed := make([]*pb.Vector, len(vectors))
+	now := time.Now()
+	for i, vector := range vectors {
+		if len(vector.Elements) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "vector elements cannot be empty")
+		}
+		expireAt, err := resolveExpireAt(vector, now)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "vector[%d]: %v", i, err)
+		}
+		routed[i] = &pb.Vector{
+			Elements: vector.Elements,
+			Metadata: vector.Metadata,
+			ExpireAt: expireAtToProto(expireAt),
+		}
+	}
+
+	ids, err := s.shards.Insert(ctx, dbName, collName, routed)
+	if err != nil {
+		return nil, s.writeError(err)
+	}
+	return ids, nil
+}
+
+// searchSharded searches every shard of a sharded collection and merges their results
+func (s *Server) searchSharded(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if len(req.PartitionNames) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "sharded collections have no partitions")
+	}
+
+	results, err := s.shards.Search(ctx, req)
+	if err != nil {
+		return nil, s.writeError(err)
+	}
+
+	s.updateRequestStats()
+	return &pb.SearchResponse{Results: results}, nil
+}
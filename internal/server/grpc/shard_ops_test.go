@@ -0,0 +1,416 @@
+// Package grpc provides tests for sharded collections in the gRPC server.
+package grpc
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/embedding"
+	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/server"
+	"github.com/scintirete/scintirete/internal/sharding"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startShardTestServer starts a standalone server serving gRPC on a local port and
+// returns it with its address
+func startShardTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv, err := NewServer(server.ServerConfig{
+		Passwords: []string{"test-password"},
+		PersistenceConfig: persistence.Config{
+			DataDir:         t.TempDir(),
+			RDBFilename:     "dump.rdb",
+			AOFFilename:     "appendonly.aof",
+			AOFSyncStrategy: "no",
+		},
+		EmbeddingConfig: embedding.Config{
+			BaseURL: "http://localhost:8080",
+			APIKey:  "test-key",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	if err := srv.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterScintireteServiceServer(grpcServer, srv)
+	go grpcServer.Serve(listener)
+
+	var once sync.Once
+	t.Cleanup(func() {
+		once.Do(func() {
+			grpcServer.Stop()
+			srv.Stop(ctx)
+		})
+	})
+	return srv, listener.Addr().String()
+}
+
+// shardVectorCount returns how many vectors a shard holds for a sharded collection
+func shardVectorCount(t *testing.T, srv *Server, collName string) int64 {
+	t.Helper()
+
+	info, err := srv.GetCollectionInfo(context.Background(), &pb.GetCollectionInfoRequest{
+		Auth:           &pb.AuthInfo{Password: "test-password"},
+		DbName:         "db",
+		CollectionName: sharding.ShardCollection(collName),
+	})
+	if status.Code(err) == codes.NotFound {
+		return 0
+	} else if err != nil {
+		t.Fatalf("Failed to get shard info: %v", err)
+	}
+	return info.VectorCount
+}
+
+// nearestIDs returns the IDs of the k vectors closest to query by L2 distance
+func nearestIDs(vectors map[uint64][]float32, query []float32, k int) []uint64 {
+	distance := func(v []float32) float32 {
+		var sum float32
+		for i := range v {
+			d := v[i] - query[i]
+			sum += d * d
+		}
+		return sum
+	}
+
+	ids := make([]uint64, 0, len(vectors))
+	for id := range vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return distance(vectors[ids[i]]) < distance(vectors[ids[j]]) })
+	return ids[:k]
+}
+
+func TestShardedCollectionRoutesAndReshards(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+
+	// The coordinator is a shard too
+	coordinator, coordinatorAddr := startShardTestServer(t)
+	shard2, shard2Addr := startShardTestServer(t)
+	shard3, shard3Addr := startShardTestServer(t)
+
+	if _, err := coordinator.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "db"}); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	created, err := coordinator.CreateCollection(ctx, &pb.CreateCollectionRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+		MetricType:     pb.DistanceMetric_L2,
+		Shards:         []string{coordinatorAddr, shard2Addr},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sharded collection: %v", err)
+	}
+	if !slices.Equal(created.Info.GetShards(), []string{coordinatorAddr, shard2Addr}) {
+		t.Fatalf("Expected shards in collection info, got %v", created.Info.GetShards())
+	}
+
+	// Insert vectors, each routed to the shard that owns its ID
+	rng := rand.New(rand.NewSource(1))
+	vectors := make(map[uint64][]float32)
+	insert := func(count int) {
+		t.Helper()
+		batch := make([]*pb.Vector, count)
+		for i := range batch {
+			batch[i] = &pb.Vector{Elements: []float32{rng.Float32(), rng.Float32(), rng.Float32()}}
+		}
+		resp, err := coordinator.InsertVectors(ctx, &pb.InsertVectorsRequest{
+			Auth:           auth,
+			DbName:         "db",
+			CollectionName: "docs",
+			Vectors:        batch,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert vectors: %v", err)
+		}
+		for i, id := range resp.InsertedIds {
+			if _, exists := vectors[id]; exists {
+				t.Fatalf("ID %d handed out twice", id)
+			}
+			vectors[id] = batch[i].Elements
+		}
+	}
+	insert(300)
+
+	if n := shardVectorCount(t, coordinator, "docs"); n == 0 || n == 300 {
+		t.Fatalf("Expected the vectors to be spread over both shards, coordinator holds %d", n)
+	}
+	if shardVectorCount(t, coordinator, "docs")+shardVectorCount(t, shard2, "docs") != 300 {
+		t.Fatalf("Expected the shards to hold 300 vectors together")
+	}
+
+	// Partitions are not supported, and neither are vectors of another dimension
+	_, err = coordinator.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+		PartitionName:  "p1",
+		Vectors:        []*pb.Vector{{Elements: []float32{1, 2, 3}}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for a partition, got %v", err)
+	}
+	_, err = coordinator.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+		Vectors:        []*pb.Vector{{Elements: []float32{1, 2}}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for another dimension, got %v", err)
+	}
+
+	// Searches merge the results of all shards
+	efSearch := int32(400)
+	checkSearch := func() {
+		t.Helper()
+		query := []float32{0.5, 0.5, 0.5}
+		resp, err := coordinator.Search(ctx, &pb.SearchRequest{
+			Auth:           auth,
+			DbName:         "db",
+			CollectionName: "docs",
+			QueryVector:    query,
+			TopK:           10,
+			EfSearch:       &efSearch,
+		})
+		if err != nil {
+			t.Fatalf("Failed to search: %v", err)
+		}
+		got := make([]uint64, len(resp.Results))
+		for i, result := range resp.Results {
+			got[i] = result.Id
+		}
+		if want := nearestIDs(vectors, query, 10); !slices.Equal(got, want) {
+			t.Fatalf("Expected nearest IDs %v, got %v", want, got)
+		}
+	}
+	checkSearch()
+
+	// Deletes reach the owning shards
+	var deleteIDs []uint64
+	for id := range vectors {
+		if len(deleteIDs) == 20 {
+			break
+		}
+		deleteIDs = append(deleteIDs, id)
+	}
+	deleted, err := coordinator.DeleteVectors(ctx, &pb.DeleteVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+		Ids:            deleteIDs,
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete vectors: %v", err)
+	}
+	if deleted.DeletedCount != 20 {
+		t.Fatalf("Expected 20 deleted vectors, got %d", deleted.DeletedCount)
+	}
+	for _, id := range deleteIDs {
+		delete(vectors, id)
+	}
+	checkSearch()
+
+	// Add a third shard
+	resharded, err := coordinator.ReshardCollection(ctx, &pb.ReshardCollectionRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+		Shards:         []string{coordinatorAddr, shard2Addr, shard3Addr},
+	})
+	if err != nil {
+		t.Fatalf("Failed to reshard: %v", err)
+	}
+	moved := shardVectorCount(t, shard3, "docs")
+	if moved == 0 || resharded.MovedVectors != moved {
+		t.Fatalf("Expected the moved vectors to be on the new shard, moved %d, new shard holds %d",
+			resharded.MovedVectors, moved)
+	}
+	if resharded.Info.VectorCount != 280 || len(resharded.Info.ReshardingFrom) != 0 {
+		t.Fatalf("Expected 280 vectors after resharding, got %v", resharded.Info)
+	}
+	checkSearch()
+
+	// New vectors go to all three shards, with IDs not handed out before
+	insert(100)
+	info, err := coordinator.GetCollectionInfo(ctx, &pb.GetCollectionInfoRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+	})
+	if err != nil {
+		t.Fatalf("Failed to get collection info: %v", err)
+	}
+	if info.VectorCount != 380 || len(info.Shards) != 3 {
+		t.Fatalf("Expected 380 vectors on 3 shards, got %v", info)
+	}
+	checkSearch()
+
+	// Remove the second shard again: its vectors move and its collection is dropped
+	if _, err := coordinator.ReshardCollection(ctx, &pb.ReshardCollectionRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+		Shards:         []string{coordinatorAddr, shard3Addr},
+	}); err != nil {
+		t.Fatalf("Failed to reshard: %v", err)
+	}
+	if shardVectorCount(t, shard2, "docs") != 0 {
+		t.Fatalf("Expected the removed shard to be emptied")
+	}
+	checkSearch()
+
+	// The collection is listed by the coordinator
+	list, err := coordinator.ListCollections(ctx, &pb.ListCollectionsRequest{Auth: auth, DbName: "db"})
+	if err != nil {
+		t.Fatalf("Failed to list collections: %v", err)
+	}
+	found := false
+	for _, collection := range list.Collections {
+		if collection.Name == "docs" {
+			found = collection.VectorCount == 380
+		}
+	}
+	if !found {
+		t.Fatalf("Expected the sharded collection with 380 vectors in %v", list.Collections)
+	}
+
+	// Dropping it drops it on every shard
+	dropped, err := coordinator.DropCollection(ctx, &pb.DropCollectionRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+	})
+	if err != nil {
+		t.Fatalf("Failed to drop collection: %v", err)
+	}
+	if dropped.DroppedVectors != 380 {
+		t.Fatalf("Expected 380 dropped vectors, got %d", dropped.DroppedVectors)
+	}
+	if shardVectorCount(t, coordinator, "docs")+shardVectorCount(t, shard3, "docs") != 0 {
+		t.Fatalf("Expected no shard to hold the collection")
+	}
+	_, err = coordinator.GetCollectionInfo(ctx, &pb.GetCollectionInfoRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs",
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound after dropping, got %v", err)
+	}
+}
+
+func TestShardInsertVectorsSkipsExistingIDs(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+	srv, _ := startShardTestServer(t)
+
+	if _, err := srv.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "db"}); err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if _, err := srv.CreateCollection(ctx, &pb.CreateCollectionRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs.shard",
+		MetricType:     pb.DistanceMetric_L2,
+	}); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	vector := func(id uint64) *pb.Vector {
+		return &pb.Vector{Id: &id, Elements: []float32{float32(id), 0}}
+	}
+	resp, err := srv.ShardInsertVectors(ctx, &pb.ShardInsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs.shard",
+		Vectors:        []*pb.Vector{vector(7), vector(3)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert vectors: %v", err)
+	}
+	if resp.InsertedCount != 2 || len(resp.SkippedIds) != 0 {
+		t.Fatalf("Expected 2 inserted vectors, got %v", resp)
+	}
+
+	// A repeated copy skips what is there, and a deleted vector moving back replaces it
+	if _, err := srv.DeleteVectors(ctx, &pb.DeleteVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs.shard",
+		Ids:            []uint64{3},
+	}); err != nil {
+		t.Fatalf("Failed to delete vector: %v", err)
+	}
+	resp, err = srv.ShardInsertVectors(ctx, &pb.ShardInsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs.shard",
+		Vectors:        []*pb.Vector{vector(3), vector(7), vector(9)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert vectors: %v", err)
+	}
+	if resp.InsertedCount != 2 || !slices.Equal(resp.SkippedIds, []uint64{7}) {
+		t.Fatalf("Expected 2 inserted and 7 skipped, got %v", resp)
+	}
+
+	// Vectors without IDs are rejected
+	_, err = srv.ShardInsertVectors(ctx, &pb.ShardInsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs.shard",
+		Vectors:        []*pb.Vector{{Elements: []float32{1, 2}}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument without an ID, got %v", err)
+	}
+
+	// Scanning pages through the live vectors by ID
+	scan, err := srv.ScanVectors(ctx, &pb.ScanVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs.shard",
+		Limit:          1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to scan vectors: %v", err)
+	}
+	if len(scan.Vectors) != 1 || scan.Vectors[0].GetId() != 3 {
+		t.Fatalf("Expected vector 3 first, got %v", scan.Vectors)
+	}
+	scan, err = srv.ScanVectors(ctx, &pb.ScanVectorsRequest{
+		Auth:           auth,
+		DbName:         "db",
+		CollectionName: "docs.shard",
+		AfterId:        3,
+		Limit:          10,
+	})
+	if err != nil {
+		t.Fatalf("Failed to scan vectors: %v", err)
+	}
+	if len(scan.Vectors) != 2 || scan.Vectors[0].GetId() != 7 || scan.Vectors[1].GetId() != 9 || len(scan.Vectors[1].Elements) != 2 {
+		t.Fatalf("Expected vectors 7 and 9 after 3, got %v", scan.Vectors)
+	}
+}
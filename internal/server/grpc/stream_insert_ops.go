@@ -0,0 +1,108 @@
+// Package grpc provides streaming vector insertion for the gRPC server.
+package grpc
+
+import (
+	"context"
+	"io"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamInsertVectors inserts the chunks of vectors a client streams, acknowledging each
+func (s *Server) StreamInsertVectors(stream grpc.BidiStreamingServer[pb.StreamInsertVectorsRequest, pb.StreamInsertVectorsResponse]) error {
+	return s.InsertVectorStream(stream.Context(), stream.Recv, stream.Send)
+}
+
+// InsertVectorStream inserts the chunks recv returns until io.EOF, each as a single
+// logged write, and passes the acknowledgement of a chunk with the IDs generated for it
+// to send once the chunk is persisted. The HTTP gateway reads the chunks from JSON lines.
+//
+// Chunks are inserted one at a time and the next one is only received after the last
+// one is acknowledged, so a client sending faster than the server persists is held back
+// by the flow control of the transport. A failing chunk ends the stream; the chunks
+// acknowledged before it stay inserted.
+func (s *Server) InsertVectorStream(ctx context.Context, recv func() (*pb.StreamInsertVectorsRequest, error), send func(*pb.StreamInsertVectorsResponse) error) error {
+	first, err := recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Authenticate
+	if err := s.authenticate(first.Auth); err != nil {
+		return err
+	}
+
+	// Validate input
+	if first.DbName == "" {
+		return status.Error(codes.InvalidArgument, "database name cannot be empty")
+	}
+	if first.CollectionName == "" {
+		return status.Error(codes.InvalidArgument, "collection name cannot be empty")
+	}
+	s.updateRequestStats()
+
+	var total int64
+	for chunk := first; ; {
+		if err := checkStreamChunk(first, chunk); err != nil {
+			return err
+		}
+
+		// Only the primary of a replica changes its state, a role that can change mid-stream
+		if err := s.checkWritable(); err != nil {
+			return err
+		}
+
+		insertedIds, err := s.insertVectors(ctx, first.DbName, first.CollectionName, first.PartitionName, chunk.Vectors)
+		if err != nil {
+			return status.Errorf(status.Code(err), "chunk %d: %s", chunk.Sequence, status.Convert(err).Message())
+		}
+		total += int64(len(insertedIds))
+
+		// Log to audit
+		s.logAuditOperation(ctx, "StreamInsertVectors", first.DbName, first.CollectionName, first.Auth, map[string]interface{}{
+			"operation_type": "vector_data",
+			"vector_count":   len(insertedIds),
+			"sequence":       chunk.Sequence,
+		})
+
+		if err := send(&pb.StreamInsertVectorsResponse{
+			Sequence:      chunk.Sequence,
+			InsertedIds:   insertedIds,
+			InsertedCount: int32(len(insertedIds)),
+			TotalInserted: total,
+		}); err != nil {
+			return err
+		}
+
+		chunk, err = recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkStreamChunk validates a chunk of a stream against the first chunk, which sets
+// the target of the stream
+func checkStreamChunk(first, chunk *pb.StreamInsertVectorsRequest) error {
+	if len(chunk.Vectors) == 0 {
+		return status.Errorf(codes.InvalidArgument, "chunk %d: no vectors provided", chunk.Sequence)
+	}
+	if chunk == first {
+		return nil
+	}
+	if (chunk.DbName != "" && chunk.DbName != first.DbName) ||
+		(chunk.CollectionName != "" && chunk.CollectionName != first.CollectionName) ||
+		(chunk.PartitionName != "" && chunk.PartitionName != first.PartitionName) {
+		return status.Errorf(codes.InvalidArgument, "chunk %d: a stream inserts into the collection and partition of its first chunk", chunk.Sequence)
+	}
+	return nil
+}
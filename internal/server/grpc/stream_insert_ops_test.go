@@ -0,0 +1,106 @@
+// Package grpc provides tests for streaming vector insertion of the gRPC server.
+package grpc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamTestChunks inserts chunks through a stream and returns its acknowledgements
+func streamTestChunks(srv *Server, chunks ...*pb.StreamInsertVectorsRequest) ([]*pb.StreamInsertVectorsResponse, error) {
+	var acks []*pb.StreamInsertVectorsResponse
+	err := srv.InsertVectorStream(context.Background(), func() (*pb.StreamInsertVectorsRequest, error) {
+		if len(chunks) == 0 {
+			return nil, io.EOF
+		}
+		chunk := chunks[0]
+		chunks = chunks[1:]
+		return chunk, nil
+	}, func(ack *pb.StreamInsertVectorsResponse) error {
+		acks = append(acks, ack)
+		return nil
+	})
+	return acks, err
+}
+
+func TestStreamInsertVectors(t *testing.T) {
+	auth := &pb.AuthInfo{Password: "test-password"}
+	srv, _ := startReplicationTestServer(t)
+	setupTestData(t, srv)
+	_, before := srv.primary.Feed().Position()
+
+	acks, err := streamTestChunks(srv,
+		&pb.StreamInsertVectorsRequest{
+			Auth:           auth,
+			DbName:         "testdb",
+			CollectionName: "testcoll",
+			Sequence:       1,
+			Vectors:        []*pb.Vector{{Elements: []float32{1, 1, 0}}, {Elements: []float32{0, 1, 1}}},
+		},
+		&pb.StreamInsertVectorsRequest{Sequence: 2, Vectors: []*pb.Vector{{Elements: []float32{1, 0, 1}}}},
+	)
+	if err != nil {
+		t.Fatalf("Failed to stream vectors: %v", err)
+	}
+
+	// An acknowledgement with the generated IDs per chunk
+	if len(acks) != 2 {
+		t.Fatalf("Expected 2 acknowledgements, got %d", len(acks))
+	}
+	if acks[0].Sequence != 1 || acks[0].InsertedCount != 2 || len(acks[0].InsertedIds) != 2 || acks[0].TotalInserted != 2 {
+		t.Errorf("Unexpected acknowledgement of the first chunk: %+v", acks[0])
+	}
+	if acks[1].Sequence != 2 || acks[1].InsertedCount != 1 || acks[1].TotalInserted != 3 {
+		t.Errorf("Unexpected acknowledgement of the second chunk: %+v", acks[1])
+	}
+	if acks[1].InsertedIds[0] <= acks[0].InsertedIds[1] {
+		t.Errorf("Expected ascending IDs across chunks, got %v and %v", acks[0].InsertedIds, acks[1].InsertedIds)
+	}
+
+	// Each chunk is one logged command
+	if _, after := srv.primary.Feed().Position(); after-before != 2 {
+		t.Errorf("Expected 2 logged commands, got %d", after-before)
+	}
+
+	info, err := srv.GetCollectionInfo(context.Background(), &pb.GetCollectionInfoRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll"})
+	if err != nil {
+		t.Fatalf("Failed to get collection info: %v", err)
+	}
+	if info.VectorCount != 6 {
+		t.Errorf("Expected 6 vectors, got %d", info.VectorCount)
+	}
+}
+
+func TestStreamInsertVectorsStopsAtFailingChunk(t *testing.T) {
+	auth := &pb.AuthInfo{Password: "test-password"}
+	srv, _ := startReplicationTestServer(t)
+	setupTestData(t, srv)
+
+	first := &pb.StreamInsertVectorsRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll", Sequence: 1, Vectors: []*pb.Vector{{Elements: []float32{1, 1, 0}}}}
+	for _, tc := range []struct {
+		name   string
+		chunks []*pb.StreamInsertVectorsRequest
+		code   codes.Code
+		acks   int
+	}{
+		{"unauthenticated", []*pb.StreamInsertVectorsRequest{{DbName: "testdb", CollectionName: "testcoll", Vectors: first.Vectors}}, codes.Unauthenticated, 0},
+		{"no collection", []*pb.StreamInsertVectorsRequest{{Auth: auth, DbName: "testdb", Vectors: first.Vectors}}, codes.InvalidArgument, 0},
+		{"missing collection", []*pb.StreamInsertVectorsRequest{{Auth: auth, DbName: "testdb", CollectionName: "missing", Vectors: first.Vectors}}, codes.NotFound, 0},
+		{"empty chunk", []*pb.StreamInsertVectorsRequest{first, {Sequence: 2}}, codes.InvalidArgument, 1},
+		{"other collection", []*pb.StreamInsertVectorsRequest{first, {CollectionName: "other", Sequence: 2, Vectors: first.Vectors}}, codes.InvalidArgument, 1},
+		{"wrong dimension", []*pb.StreamInsertVectorsRequest{first, {Sequence: 2, Vectors: []*pb.Vector{{Elements: []float32{1}}}}}, codes.InvalidArgument, 1},
+	} {
+		acks, err := streamTestChunks(srv, tc.chunks...)
+		if status.Code(err) != tc.code {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.code, err)
+		}
+		if len(acks) != tc.acks {
+			t.Errorf("%s: expected %d acknowledgements, got %d", tc.name, tc.acks, len(acks))
+		}
+	}
+}
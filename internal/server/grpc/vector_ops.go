@@ -4,6 +4,7 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -21,6 +22,11 @@ func (s *Server) InsertVectors(ctx context.Context, req *pb.InsertVectorsRequest
 		return nil, err
 	}
 
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	// Validate input
 	if req.DbName == "" {
 		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
@@ -32,9 +38,36 @@ func (s *Server) InsertVectors(ctx context.Context, req *pb.InsertVectorsRequest
 		return nil, status.Error(codes.InvalidArgument, "no vectors provided")
 	}
 
+	insertedIds, err := s.insertVectors(ctx, req.DbName, req.CollectionName, req.PartitionName, req.Vectors)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "InsertVectors", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "vector_data",
+		"vector_count":   len(insertedIds),
+	})
+
+	s.updateRequestStats()
+	return &pb.InsertVectorsResponse{
+		InsertedIds:   insertedIds,
+		InsertedCount: int32(len(insertedIds)),
+	}, nil
+}
+
+// insertVectors adds vectors to a collection as a single logged write and returns the
+// IDs generated for them, in the order of the vectors
+func (s *Server) insertVectors(ctx context.Context, dbName, collName, partitionName string, pbVectors []*pb.Vector) ([]uint64, error) {
+	// Route sharded collections to the shards that own the new IDs
+	if s.isSharded(dbName, collName) {
+		return s.insertSharded(ctx, dbName, collName, partitionName, pbVectors)
+	}
+
 	// Convert protobuf vectors to internal format
-	vectors := make([]types.Vector, len(req.Vectors))
-	for i, pbVector := range req.Vectors {
+	vectors := make([]types.Vector, len(pbVectors))
+	now := time.Now()
+	for i, pbVector := range pbVectors {
 		if len(pbVector.Elements) == 0 {
 			return nil, status.Error(codes.InvalidArgument, "vector elements cannot be empty")
 		}
@@ -45,15 +78,23 @@ func (s *Server) InsertVectors(ctx context.Context, req *pb.InsertVectorsRequest
 			metadata = pbVector.Metadata.AsMap()
 		}
 
+		// Resolve optional TTL to an absolute expiry
+		expireAt, err := resolveExpireAt(pbVector, now)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "vector[%d]: %v", i, err)
+		}
+
 		vectors[i] = types.Vector{
-			ID:       0, // Will be auto-generated by the collection
-			Elements: pbVector.Elements,
-			Metadata: metadata,
+			ID:        0, // Will be auto-generated by the collection
+			Elements:  pbVector.Elements,
+			Metadata:  metadata,
+			ExpireAt:  expireAt,
+			Partition: partitionName,
 		}
 	}
 
 	// Get database
-	db, err := s.engine.GetDatabase(ctx, req.DbName)
+	db, err := s.engine.GetDatabase(ctx, dbName)
 	if err != nil {
 		if utils.IsScintireteError(err) {
 			return nil, s.convertError(err)
@@ -62,7 +103,7 @@ func (s *Server) InsertVectors(ctx context.Context, req *pb.InsertVectorsRequest
 	}
 
 	// Get collection
-	collection, err := db.GetCollection(ctx, req.CollectionName)
+	collection, err := db.GetCollection(ctx, collName)
 	if err != nil {
 		if utils.IsScintireteError(err) {
 			return nil, s.convertError(err)
@@ -70,36 +111,19 @@ func (s *Server) InsertVectors(ctx context.Context, req *pb.InsertVectorsRequest
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	// Insert vectors
-	if err := collection.Insert(ctx, vectors); err != nil {
-		if utils.IsScintireteError(err) {
-			return nil, s.convertError(err)
-		}
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
-	// Log to persistence
-	if err := s.persistence.LogInsertVectors(ctx, req.DbName, req.CollectionName, vectors); err != nil {
-		return nil, status.Error(codes.Internal, "failed to log insert vectors operation")
+	// Insert vectors, logged with the IDs the collection generates
+	result, err := s.insertWrite(ctx, dbName, collName, collection, vectors)
+	if err != nil {
+		return nil, err
 	}
-
-	// Log to audit
-	s.logAuditOperation(ctx, "InsertVectors", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
-		"operation_type": "vector_data",
-		"vector_count":   len(vectors),
-	})
+	setInsertedIDs(vectors, result)
 
 	// Extract the generated IDs from the vectors (collection modifies the vectors with generated IDs)
 	insertedIds := make([]uint64, len(vectors))
 	for i, vector := range vectors {
 		insertedIds[i] = vector.ID
 	}
-
-	s.updateRequestStats()
-	return &pb.InsertVectorsResponse{
-		InsertedIds:   insertedIds,
-		InsertedCount: int32(len(vectors)),
-	}, nil
+	return insertedIds, nil
 }
 
 // DeleteVectors marks vectors as deleted by their IDs
@@ -109,6 +133,11 @@ func (s *Server) DeleteVectors(ctx context.Context, req *pb.DeleteVectorsRequest
 		return nil, err
 	}
 
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	// Validate input
 	if req.DbName == "" {
 		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
@@ -120,6 +149,24 @@ func (s *Server) DeleteVectors(ctx context.Context, req *pb.DeleteVectorsRequest
 		return nil, status.Error(codes.InvalidArgument, "no IDs provided")
 	}
 
+	// Route sharded collections to the shards that own the IDs
+	if s.isSharded(req.DbName, req.CollectionName) {
+		if len(req.PartitionNames) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "sharded collections have no partitions")
+		}
+		deletedCount, err := s.shards.Delete(ctx, req.DbName, req.CollectionName, req.Ids)
+		if err != nil {
+			return nil, s.writeError(err)
+		}
+		s.logAuditOperation(ctx, "DeleteVectors", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+			"operation_type":  "vector_data",
+			"requested_count": len(req.Ids),
+			"actual_deleted":  deletedCount,
+		})
+		s.updateRequestStats()
+		return &pb.DeleteVectorsResponse{DeletedCount: int32(deletedCount)}, nil
+	}
+
 	// Get database
 	db, err := s.engine.GetDatabase(ctx, req.DbName)
 	if err != nil {
@@ -144,18 +191,19 @@ func (s *Server) DeleteVectors(ctx context.Context, req *pb.DeleteVectorsRequest
 		stringIds[i] = fmt.Sprintf("%d", id)
 	}
 
-	// Delete vectors
-	deletedCount, err := collection.Delete(ctx, stringIds)
+	// Delete vectors, optionally restricted to the requested partitions
+	var deletedCount int
+	command := s.commands.DeleteVectorsFromPartitions(req.DbName, req.CollectionName, stringIds, req.PartitionNames)
+	result, err := s.applyWrite(ctx, command, func() error {
+		var err error
+		deletedCount, err = collection.DeleteFromPartitions(ctx, stringIds, req.PartitionNames)
+		return err
+	})
 	if err != nil {
-		if utils.IsScintireteError(err) {
-			return nil, s.convertError(err)
-		}
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
 	}
-
-	// Log to persistence
-	if err := s.persistence.LogDeleteVectors(ctx, req.DbName, req.CollectionName, stringIds); err != nil {
-		return nil, status.Error(codes.Internal, "failed to log delete vectors operation")
+	if s.cluster != nil {
+		deletedCount = int(result.Deleted)
 	}
 
 	// Log to audit
@@ -190,9 +238,15 @@ func (s *Server) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchR
 		return nil, status.Error(codes.InvalidArgument, "top_k must be positive")
 	}
 
+	// Search every shard of sharded collections
+	if s.isSharded(req.DbName, req.CollectionName) {
+		return s.searchSharded(ctx, req)
+	}
+
 	// Convert search parameters
 	params := types.SearchParams{
-		TopK: int(req.TopK),
+		TopK:       int(req.TopK),
+		Partitions: req.PartitionNames,
 	}
 	if req.EfSearch != nil {
 		efSearch := int(*req.EfSearch)
@@ -250,6 +304,7 @@ func (s *Server) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchR
 			Vector: &pb.Vector{
 				Id:       &vectorId,
 				Metadata: metadata,
+				ExpireAt: expireAtToProto(result.Vector.ExpireAt),
 			},
 		}
 
@@ -271,6 +326,11 @@ func (s *Server) EmbedAndInsert(ctx context.Context, req *pb.EmbedAndInsertReque
 		return nil, err
 	}
 
+	// Only the primary of a replica changes its state
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	// Validate input
 	if req.DbName == "" {
 		return nil, status.Error(codes.InvalidArgument, "database name cannot be empty")
@@ -308,6 +368,32 @@ func (s *Server) EmbedAndInsert(ctx context.Context, req *pb.EmbedAndInsertReque
 		return nil, status.Errorf(codes.Internal, "failed to get embeddings: %v", err)
 	}
 
+	// Route sharded collections to the shards that own the new IDs
+	if s.isSharded(req.DbName, req.CollectionName) {
+		pbVectors := make([]*pb.Vector, len(vectors))
+		for i, vector := range vectors {
+			pbVectors[i] = &pb.Vector{
+				Elements: vector.Elements,
+				Metadata: mapToStruct(vector.Metadata),
+			}
+		}
+		insertedIds, err := s.insertSharded(ctx, req.DbName, req.CollectionName, req.PartitionName, pbVectors)
+		if err != nil {
+			return nil, err
+		}
+		s.logAuditOperation(ctx, "EmbedAndInsert", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+			"operation_type":  "auxiliary",
+			"embedding_model": model,
+			"text_count":      len(texts),
+			"vector_count":    len(insertedIds),
+		})
+		s.updateRequestStats()
+		return &pb.EmbedAndInsertResponse{
+			InsertedIds:   insertedIds,
+			InsertedCount: int32(len(insertedIds)),
+		}, nil
+	}
+
 	// Get database
 	db, err := s.engine.GetDatabase(ctx, req.DbName)
 	if err != nil {
@@ -326,25 +412,16 @@ func (s *Server) EmbedAndInsert(ctx context.Context, req *pb.EmbedAndInsertReque
 		return nil, status.Errorf(codes.Internal, "failed to get collection: %v", err)
 	}
 
-	// Insert vectors
-	if err := coll.Insert(ctx, vectors); err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to insert vectors: %v", err)
+	// Insert vectors into the requested partition. The actual data operation (INSERT_VECTORS)
+	// is what is logged to the AOF - this is what actually happened at data level
+	for i := range vectors {
+		vectors[i].Partition = req.PartitionName
 	}
-
-	// Log the actual data operation (INSERT_VECTORS) to AOF - this is what actually happened at data level
-	if err := s.persistence.LogInsertVectors(ctx, req.DbName, req.CollectionName, vectors); err != nil {
-		// Log error but don't fail the operation - AOF write failure shouldn't block operation
-		if s.logger != nil {
-			s.logger.Error(ctx, "AOF write failed for EmbedAndInsert operation", err, map[string]interface{}{
-				"operation":    "EmbedAndInsert",
-				"database":     req.DbName,
-				"collection":   req.CollectionName,
-				"vector_count": len(vectors),
-			})
-		}
-		// Note: Operation continues successfully even if AOF write fails
-		// This ensures user operations aren't blocked by persistence issues
+	result, err := s.insertWrite(ctx, req.DbName, req.CollectionName, coll, vectors)
+	if err != nil {
+		return nil, err
 	}
+	setInsertedIDs(vectors, result)
 
 	// Log the auxiliary operation (EmbedAndInsert) to audit log for tracking purposes
 	s.logAuditOperation(ctx, "EmbedAndInsert", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
@@ -400,6 +477,19 @@ func (s *Server) EmbedAndSearch(ctx context.Context, req *pb.EmbedAndSearchReque
 		return nil, status.Errorf(codes.Internal, "failed to get query embedding: %v", err)
 	}
 
+	// Search every shard of sharded collections
+	if s.isSharded(req.DbName, req.CollectionName) {
+		return s.searchSharded(ctx, &pb.SearchRequest{
+			DbName:         req.DbName,
+			CollectionName: req.CollectionName,
+			QueryVector:    queryEmbedding,
+			TopK:           req.TopK,
+			EfSearch:       req.EfSearch,
+			IncludeVector:  req.IncludeVector,
+			PartitionNames: req.PartitionNames,
+		})
+	}
+
 	// Get database
 	db, err := s.engine.GetDatabase(ctx, req.DbName)
 	if err != nil {
@@ -420,7 +510,8 @@ func (s *Server) EmbedAndSearch(ctx context.Context, req *pb.EmbedAndSearchReque
 
 	// Prepare search parameters
 	searchParams := types.SearchParams{
-		TopK: int(req.TopK),
+		TopK:       int(req.TopK),
+		Partitions: req.PartitionNames,
 	}
 	if req.EfSearch != nil {
 		efSearch := int(*req.EfSearch)
@@ -430,6 +521,9 @@ func (s *Server) EmbedAndSearch(ctx context.Context, req *pb.EmbedAndSearchReque
 	// Perform search
 	results, err := coll.Search(ctx, queryEmbedding, searchParams)
 	if err != nil {
+		if utils.IsScintireteError(err) {
+			return nil, s.convertError(err)
+		}
 		return nil, status.Errorf(codes.Internal, "failed to perform search: %v", err)
 	}
 
@@ -451,6 +545,7 @@ func (s *Server) EmbedAndSearch(ctx context.Context, req *pb.EmbedAndSearchReque
 			Vector: &pb.Vector{
 				Id:       &vectorId,
 				Metadata: mapToStruct(result.Vector.Metadata),
+				ExpireAt: expireAtToProto(result.Vector.ExpireAt),
 			},
 		}
 
@@ -0,0 +1,57 @@
+// Package grpc provides change stream operations for the gRPC server.
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/cdc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Watch streams the changes of a database or collection from an offset on
+func (s *Server) Watch(req *pb.WatchRequest, stream grpc.ServerStreamingServer[pb.WatchEvent]) error {
+	return s.WatchChanges(stream.Context(), req, stream.Send)
+}
+
+// WatchChanges passes the changes of a database or collection from an offset on to send,
+// until ctx ends or send fails. The HTTP gateway streams them as server-sent events.
+func (s *Server) WatchChanges(ctx context.Context, req *pb.WatchRequest, send func(*pb.WatchEvent) error) error {
+	// Authenticate
+	if err := s.authenticate(req.Auth); err != nil {
+		return err
+	}
+
+	// Validate request
+	if req.CollectionName != "" && req.DbName == "" {
+		return status.Error(codes.InvalidArgument, "database name is required to watch a collection")
+	}
+	if req.FromOffset < 0 {
+		return status.Error(codes.InvalidArgument, "from_offset must be non-negative")
+	}
+
+	// Log to audit
+	s.logAuditOperation(ctx, "Watch", req.DbName, req.CollectionName, req.Auth, map[string]interface{}{
+		"operation_type": "watch",
+		"stream_id":      req.StreamId,
+		"from_offset":    req.FromOffset,
+	})
+	s.updateRequestStats()
+
+	filter := cdc.Filter{Database: req.DbName, Collection: req.CollectionName}
+	err := s.changes.Watch(ctx, filter, req.StreamId, req.FromOffset, send)
+	switch {
+	case ctx.Err() != nil:
+		// The watcher disconnected
+		return nil
+	case errors.Is(err, cdc.ErrOffsetUnavailable):
+		return status.Error(codes.OutOfRange, err.Error())
+	case status.Code(err) != codes.Unknown:
+		return err
+	default:
+		return status.Error(codes.Aborted, err.Error())
+	}
+}
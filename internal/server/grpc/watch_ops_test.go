@@ -0,0 +1,163 @@
+// Package grpc provides tests for the change stream of the gRPC server.
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchTestStream watches the changes of req and returns the channel they arrive on and
+// the channel the error the watch ended with arrives on
+func watchTestStream(t *testing.T, srv *Server, req *pb.WatchRequest) (<-chan *pb.WatchEvent, <-chan error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	events := make(chan *pb.WatchEvent, 64)
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.WatchChanges(ctx, req, func(event *pb.WatchEvent) error {
+			events <- event
+			return nil
+		})
+	}()
+	return events, done
+}
+
+// nextWatchEvent returns the next event other than a heartbeat
+func nextWatchEvent(t *testing.T, events <-chan *pb.WatchEvent) *pb.WatchEvent {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type != pb.ChangeType_HEARTBEAT {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for a change event")
+		}
+	}
+}
+
+func TestWatchStreamsChanges(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+	srv, _ := startReplicationTestServer(t)
+
+	// Watching a collection starts with a heartbeat at the current offset
+	events, _ := watchTestStream(t, srv, &pb.WatchRequest{Auth: auth, DbName: "testdb", CollectionName: "testcoll"})
+	select {
+	case event := <-events:
+		if event.Type != pb.ChangeType_HEARTBEAT || event.Offset != 0 || event.StreamId == "" {
+			t.Fatalf("Expected a heartbeat at offset 0 first, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the first heartbeat")
+	}
+
+	setupTestData(t, srv)
+	if _, err := srv.CreateCollection(ctx, &pb.CreateCollectionRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "othercoll",
+		MetricType:     pb.DistanceMetric_L2,
+	}); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	insertResp, err := srv.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Vectors:        []*pb.Vector{{Elements: []float32{1.0, 1.0, 1.0}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert vector: %v", err)
+	}
+	if _, err := srv.DeleteVectors(ctx, &pb.DeleteVectorsRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		Ids:            insertResp.InsertedIds,
+	}); err != nil {
+		t.Fatalf("Failed to delete vector: %v", err)
+	}
+
+	// The database is created, then the collection; othercoll is filtered out
+	expected := []pb.ChangeType{
+		pb.ChangeType_CREATE_DATABASE,
+		pb.ChangeType_CREATE_COLLECTION,
+		pb.ChangeType_INSERT,
+		pb.ChangeType_INSERT,
+		pb.ChangeType_DELETE,
+	}
+	var received []*pb.WatchEvent
+	for _, changeType := range expected {
+		event := nextWatchEvent(t, events)
+		if event.Type != changeType {
+			t.Fatalf("Expected a %s event, got %+v", changeType, event)
+		}
+		received = append(received, event)
+	}
+	if create := received[1]; create.CollectionName != "testcoll" || create.MetricType != pb.DistanceMetric_L2 || create.HnswConfig.GetM() != 16 {
+		t.Errorf("Unexpected collection creation event: %+v", create)
+	}
+	if insert := received[2]; len(insert.Vectors) != 3 || insert.Vectors[0].GetId() == 0 || insert.Vectors[0].Metadata.AsMap()["category"] != "A" {
+		t.Errorf("Unexpected insert event: %+v", insert)
+	}
+	if deletion := received[4]; len(deletion.Ids) != 1 || deletion.Ids[0] != insertResp.InsertedIds[0] {
+		t.Errorf("Expected the delete of %v, got %+v", insertResp.InsertedIds, deletion)
+	}
+	for i := 1; i < len(received); i++ {
+		if received[i].Offset <= received[i-1].Offset || received[i].StreamId != received[0].StreamId {
+			t.Errorf("Expected increasing offsets of one stream, got %+v after %+v", received[i], received[i-1])
+		}
+	}
+
+	// A watcher resumes after the last event it received
+	resumed, _ := watchTestStream(t, srv, &pb.WatchRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		StreamId:       received[3].StreamId,
+		FromOffset:     received[3].Offset + 1,
+	})
+	if event := nextWatchEvent(t, resumed); event.Type != pb.ChangeType_DELETE || event.Offset != received[4].Offset {
+		t.Errorf("Expected to resume with the delete, got %+v", event)
+	}
+}
+
+func TestWatchRejectsUnavailableOffsets(t *testing.T) {
+	ctx := context.Background()
+	auth := &pb.AuthInfo{Password: "test-password"}
+	srv, _ := startReplicationTestServer(t)
+	setupTestData(t, srv)
+	streamID, offset := srv.changes.Position()
+
+	tests := []struct {
+		name string
+		req  *pb.WatchRequest
+		code codes.Code
+	}{
+		{"collection without database", &pb.WatchRequest{Auth: auth, CollectionName: "testcoll"}, codes.InvalidArgument},
+		{"negative offset", &pb.WatchRequest{Auth: auth, FromOffset: -1}, codes.InvalidArgument},
+		{"offset ahead", &pb.WatchRequest{Auth: auth, StreamId: streamID, FromOffset: offset + 2}, codes.OutOfRange},
+		{"earlier stream", &pb.WatchRequest{Auth: auth, StreamId: "earlier", FromOffset: 1}, codes.OutOfRange},
+		{"wrong password", &pb.WatchRequest{Auth: &pb.AuthInfo{Password: "wrong"}}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := srv.WatchChanges(ctx, tt.req, func(*pb.WatchEvent) error { return nil })
+			if status.Code(err) != tt.code {
+				t.Errorf("Expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Package http provides backup operation handlers for the HTTP server.
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+)
+
+// handleCreateBackup handles backup creation requests
+func (h *Server) handleCreateBackup(c *gin.Context) {
+	req := &pb.CreateBackupRequest{
+		Auth: getAuthFromContext(c),
+	}
+
+	resp, err := h.grpcServer.CreateBackup(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusCreated, resp)
+}
+
+// handleListBackups handles backup list requests
+func (h *Server) handleListBackups(c *gin.Context) {
+	req := &pb.ListBackupsRequest{
+		Auth: getAuthFromContext(c),
+	}
+
+	resp, err := h.grpcServer.ListBackups(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleRestoreBackup handles requests to restore a backup into the running server
+func (h *Server) handleRestoreBackup(c *gin.Context) {
+	req := &pb.RestoreBackupRequest{
+		Auth: getAuthFromContext(c),
+		Name: c.Param("backup_name"),
+	}
+
+	resp, err := h.grpcServer.RestoreBackup(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleDeleteBackup handles backup deletion requests
+func (h *Server) handleDeleteBackup(c *gin.Context) {
+	req := &pb.DeleteBackupRequest{
+		Auth: getAuthFromContext(c),
+		Name: c.Param("backup_name"),
+	}
+
+	resp, err := h.grpcServer.DeleteBackup(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
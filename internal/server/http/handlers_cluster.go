@@ -0,0 +1,59 @@
+// Package http provides cluster handlers for the HTTP server.
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+)
+
+// handleGetClusterStatus handles requests for the state of the node and its cluster
+func (h *Server) handleGetClusterStatus(c *gin.Context) {
+	req := &pb.GetClusterStatusRequest{
+		Auth: getAuthFromContext(c),
+	}
+
+	resp, err := h.grpcServer.GetClusterStatus(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleAddClusterNode handles requests to add a node to the cluster
+func (h *Server) handleAddClusterNode(c *gin.Context) {
+	var req pb.AddClusterNodeRequest
+
+	if err := h.bindJSON(c, &req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	req.Auth = getAuthFromContext(c)
+
+	resp, err := h.grpcServer.AddClusterNode(c.Request.Context(), &req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleRemoveClusterNode handles requests to remove a node from the cluster
+func (h *Server) handleRemoveClusterNode(c *gin.Context) {
+	req := &pb.RemoveClusterNodeRequest{
+		Auth:   getAuthFromContext(c),
+		NodeId: c.Param("node_id"),
+	}
+
+	resp, err := h.grpcServer.RemoveClusterNode(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
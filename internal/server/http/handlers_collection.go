@@ -102,3 +102,173 @@ func (h *Server) handleListCollections(c *gin.Context) {
 
 	h.respondJSON(c, http.StatusOK, resp)
 }
+
+// handleRenameCollection handles collection rename requests
+func (h *Server) handleRenameCollection(c *gin.Context) {
+	dbName := c.Param("db_name")
+	collName := c.Param("coll_name")
+	auth := getAuthFromContext(c)
+
+	var req pb.RenameCollectionRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	// Set names from URL path and auth
+	req.DbName = dbName
+	req.CollectionName = collName
+	req.Auth = auth
+
+	// Validate required fields
+	if req.NewName == "" {
+		h.respondError(c, http.StatusBadRequest, "New collection name is required", nil)
+		return
+	}
+
+	resp, err := h.grpcServer.RenameCollection(c.Request.Context(), &req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleCloneCollection handles collection clone requests
+func (h *Server) handleCloneCollection(c *gin.Context) {
+	dbName := c.Param("db_name")
+	collName := c.Param("coll_name")
+	auth := getAuthFromContext(c)
+
+	var req pb.CloneCollectionRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	// Set names from URL path and auth
+	req.DbName = dbName
+	req.CollectionName = collName
+	req.Auth = auth
+
+	// Validate required fields
+	if req.TargetName == "" {
+		h.respondError(c, http.StatusBadRequest, "Target collection name is required", nil)
+		return
+	}
+
+	resp, err := h.grpcServer.CloneCollection(c.Request.Context(), &req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusCreated, resp)
+}
+
+// handleCopyCollection handles cross-database collection copy requests
+func (h *Server) handleCopyCollection(c *gin.Context) {
+	dbName := c.Param("db_name")
+	collName := c.Param("coll_name")
+	auth := getAuthFromContext(c)
+
+	var req pb.CopyCollectionRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	// Set names from URL path and auth
+	req.DbName = dbName
+	req.CollectionName = collName
+	req.Auth = auth
+
+	// Validate required fields
+	if req.TargetDbName == "" {
+		h.respondError(c, http.StatusBadRequest, "Target database name is required", nil)
+		return
+	}
+
+	resp, err := h.grpcServer.CopyCollection(c.Request.Context(), &req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusCreated, resp)
+}
+
+// handleLoadCollection handles requests to load a released collection into memory
+func (h *Server) handleLoadCollection(c *gin.Context) {
+	dbName := c.Param("db_name")
+	collName := c.Param("coll_name")
+	auth := getAuthFromContext(c)
+
+	req := &pb.LoadCollectionRequest{
+		Auth:           auth,
+		DbName:         dbName,
+		CollectionName: collName,
+	}
+
+	resp, err := h.grpcServer.LoadCollection(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleReleaseCollection handles requests to release a collection to disk
+func (h *Server) handleReleaseCollection(c *gin.Context) {
+	dbName := c.Param("db_name")
+	collName := c.Param("coll_name")
+	auth := getAuthFromContext(c)
+
+	req := &pb.ReleaseCollectionRequest{
+		Auth:           auth,
+		DbName:         dbName,
+		CollectionName: collName,
+	}
+
+	resp, err := h.grpcServer.ReleaseCollection(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleReshardCollection handles requests to move a sharded collection to a new list of shards
+func (h *Server) handleReshardCollection(c *gin.Context) {
+	dbName := c.Param("db_name")
+	collName := c.Param("coll_name")
+	auth := getAuthFromContext(c)
+
+	var req pb.ReshardCollectionRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	// Set names from URL path and auth
+	req.DbName = dbName
+	req.CollectionName = collName
+	req.Auth = auth
+
+	// Validate required fields
+	if len(req.Shards) == 0 {
+		h.respondError(c, http.StatusBadRequest, "Shards are required", nil)
+		return
+	}
+
+	resp, err := h.grpcServer.ReshardCollection(c.Request.Context(), &req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
@@ -67,3 +67,20 @@ func (h *Server) handleListDatabases(c *gin.Context) {
 
 	h.respondJSON(c, http.StatusOK, resp)
 }
+
+// handleGetServerInfo handles server info requests, including memory usage and eviction statistics
+func (h *Server) handleGetServerInfo(c *gin.Context) {
+	auth := getAuthFromContext(c)
+
+	req := &pb.GetServerInfoRequest{
+		Auth: auth,
+	}
+
+	resp, err := h.grpcServer.GetServerInfo(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,77 @@
+// Package http provides collection export handlers for the HTTP server.
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+)
+
+// exportRecord is a line of an export, as the CLI imports JSON lines files
+type exportRecord struct {
+	ID       uint64                 `json:"id"`
+	Elements []float32              `json:"elements"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	ExpireAt int64                  `json:"expire_at,omitempty"`
+}
+
+// handleExportCollection streams the vectors of a collection as JSON lines in ascending
+// ID order. HNSW graphs are exported over gRPC only.
+func (h *Server) handleExportCollection(c *gin.Context) {
+	req := pb.ExportCollectionRequest{
+		Auth:           getAuthFromContext(c),
+		DbName:         c.Param("db_name"),
+		CollectionName: c.Param("coll_name"),
+		PartitionName:  c.Query("partition_name"),
+	}
+	if size := c.Query("batch_size"); size != "" {
+		batchSize, err := strconv.ParseInt(size, 10, 32)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, "Invalid batch_size", err)
+			return
+		}
+		req.BatchSize = int32(batchSize)
+	}
+
+	// Errors before the collection info are answered as usual, the stream starts with it
+	started := false
+	encoder := json.NewEncoder(c.Writer)
+	err := h.grpcServer.ExportVectors(c.Request.Context(), &req, func(resp *pb.ExportCollectionResponse) error {
+		if !started {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Header("X-Accel-Buffering", "no")
+			c.Status(http.StatusOK)
+			started = true
+		}
+
+		for _, vector := range resp.Vectors {
+			record := exportRecord{
+				ID:       vector.GetId(),
+				Elements: vector.Elements,
+				ExpireAt: vector.GetExpireAt(),
+			}
+			if vector.Metadata != nil {
+				record.Metadata = vector.Metadata.AsMap()
+			}
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
+		return
+	}
+	if !started {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	// Tell the client why the export broke off, a line that importing the file fails at
+	encoder.Encode(map[string]string{"error": err.Error()})
+	c.Writer.Flush()
+}
@@ -0,0 +1,130 @@
+// Package http provides partition operation handlers for the HTTP server.
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+)
+
+// handleCreatePartition handles partition creation requests
+func (h *Server) handleCreatePartition(c *gin.Context) {
+	dbName := c.Param("db_name")
+	collName := c.Param("coll_name")
+	auth := getAuthFromContext(c)
+
+	var req pb.CreatePartitionRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	// Set names from URL path and auth
+	req.DbName = dbName
+	req.CollectionName = collName
+	req.Auth = auth
+
+	// Validate required fields
+	if req.PartitionName == "" {
+		h.respondError(c, http.StatusBadRequest, "Partition name is required", nil)
+		return
+	}
+
+	resp, err := h.grpcServer.CreatePartition(c.Request.Context(), &req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusCreated, resp)
+}
+
+// handleDropPartition handles partition deletion requests
+func (h *Server) handleDropPartition(c *gin.Context) {
+	req := &pb.DropPartitionRequest{
+		Auth:           getAuthFromContext(c),
+		DbName:         c.Param("db_name"),
+		CollectionName: c.Param("coll_name"),
+		PartitionName:  c.Param("partition_name"),
+	}
+
+	resp, err := h.grpcServer.DropPartition(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleListPartitions handles partition listing requests
+func (h *Server) handleListPartitions(c *gin.Context) {
+	req := &pb.ListPartitionsRequest{
+		Auth:           getAuthFromContext(c),
+		DbName:         c.Param("db_name"),
+		CollectionName: c.Param("coll_name"),
+	}
+
+	resp, err := h.grpcServer.ListPartitions(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleLoadPartition handles partition load requests
+func (h *Server) handleLoadPartition(c *gin.Context) {
+	req := &pb.LoadPartitionRequest{
+		Auth:           getAuthFromContext(c),
+		DbName:         c.Param("db_name"),
+		CollectionName: c.Param("coll_name"),
+		PartitionName:  c.Param("partition_name"),
+	}
+
+	resp, err := h.grpcServer.LoadPartition(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleReleasePartition handles partition release requests
+func (h *Server) handleReleasePartition(c *gin.Context) {
+	req := &pb.ReleasePartitionRequest{
+		Auth:           getAuthFromContext(c),
+		DbName:         c.Param("db_name"),
+		CollectionName: c.Param("coll_name"),
+		PartitionName:  c.Param("partition_name"),
+	}
+
+	resp, err := h.grpcServer.ReleasePartition(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// handleCompactPartition handles partition compaction requests
+func (h *Server) handleCompactPartition(c *gin.Context) {
+	req := &pb.CompactPartitionRequest{
+		Auth:           getAuthFromContext(c),
+		DbName:         c.Param("db_name"),
+		CollectionName: c.Param("coll_name"),
+		PartitionName:  c.Param("partition_name"),
+	}
+
+	resp, err := h.grpcServer.CompactPartition(c.Request.Context(), req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,29 @@
+// Package http provides replication handlers for the HTTP server.
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+)
+
+// handleReplicaOf handles requests to follow a primary, or to become a primary again
+// when the primary is empty
+func (h *Server) handleReplicaOf(c *gin.Context) {
+	var req pb.ReplicaOfRequest
+
+	if err := h.bindJSON(c, &req); err != nil {
+		h.respondError(c, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	req.Auth = getAuthFromContext(c)
+
+	resp, err := h.grpcServer.ReplicaOf(c.Request.Context(), &req)
+	if err != nil {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,115 @@
+// Package http provides streaming vector insertion handlers for the HTTP server.
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultStreamChunk = 1000
+	maxStreamChunk     = 10000
+)
+
+// handleStreamInsertVectors inserts the vectors of a JSON lines upload, one vector per
+// line in the format of the vectors of an insert request, in chunks of chunk_size lines.
+// Each chunk is persisted as one write and acknowledged with a JSON line holding the
+// IDs generated for it as soon as it is, while the upload goes on.
+func (h *Server) handleStreamInsertVectors(c *gin.Context) {
+	chunkSize := defaultStreamChunk
+	if size := c.Query("chunk_size"); size != "" {
+		var err error
+		chunkSize, err = strconv.Atoi(size)
+		if err != nil || chunkSize <= 0 || chunkSize > maxStreamChunk {
+			h.respondError(c, http.StatusBadRequest, "Invalid chunk_size, expected 1 to "+strconv.Itoa(maxStreamChunk), err)
+			return
+		}
+	}
+
+	// Acknowledgements are written while the body is still being read
+	http.NewResponseController(c.Writer).EnableFullDuplex()
+
+	body := bufio.NewReaderSize(c.Request.Body, 1<<20)
+	line := 0
+	var sequence uint64
+	recv := func() (*pb.StreamInsertVectorsRequest, error) {
+		var vectors []*pb.Vector
+		for len(vectors) < chunkSize {
+			data, err := body.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if len(data) > 0 {
+				line++
+			}
+			if data = bytes.TrimSpace(data); len(data) > 0 {
+				var vector pb.Vector
+				if err := h.unmarshaler.Unmarshal(data, &vector); err != nil {
+					return nil, status.Errorf(codes.InvalidArgument, "line %d: %v", line, err)
+				}
+				vectors = append(vectors, &vector)
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+		if len(vectors) == 0 {
+			return nil, io.EOF
+		}
+
+		sequence++
+		chunk := &pb.StreamInsertVectorsRequest{Sequence: sequence, Vectors: vectors}
+		if sequence == 1 {
+			chunk.Auth = getAuthFromContext(c)
+			chunk.DbName = c.Param("db_name")
+			chunk.CollectionName = c.Param("coll_name")
+			chunk.PartitionName = c.Query("partition_name")
+		}
+		return chunk, nil
+	}
+
+	// Acknowledgements have to fit on a single line
+	marshaler := h.marshaler
+	marshaler.Indent = ""
+
+	// Errors before the first acknowledgement are answered as usual, the stream starts with it
+	started := false
+	err := h.grpcServer.InsertVectorStream(c.Request.Context(), recv, func(ack *pb.StreamInsertVectorsResponse) error {
+		if !started {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Header("X-Accel-Buffering", "no")
+			c.Status(http.StatusOK)
+			started = true
+		}
+
+		data, err := marshaler.Marshal(ack)
+		if err != nil {
+			return err
+		}
+		if _, err := c.Writer.Write(append(data, '\n')); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	switch {
+	case err == nil && !started:
+		h.respondError(c, http.StatusBadRequest, "Vectors are required", nil)
+	case err == nil:
+	case !started:
+		h.handleGRPCError(c, err)
+	default:
+		// Tell the client why the upload broke off, the chunks acknowledged before stay inserted
+		json.NewEncoder(c.Writer).Encode(map[string]string{"error": err.Error()})
+		c.Writer.Flush()
+	}
+}
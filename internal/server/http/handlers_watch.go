@@ -0,0 +1,80 @@
+// Package http provides change stream handlers for the HTTP server.
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+)
+
+// handleWatch streams the changes of a database or collection as server-sent events. The
+// ID of each event is "<stream_id>:<offset>", so a client reconnecting with the
+// Last-Event-ID header continues after the last event it received.
+func (h *Server) handleWatch(c *gin.Context) {
+	req := pb.WatchRequest{
+		Auth:           getAuthFromContext(c),
+		DbName:         c.Query("db_name"),
+		CollectionName: c.Query("collection_name"),
+		StreamId:       c.Query("stream_id"),
+	}
+	if from := c.Query("from_offset"); from != "" {
+		offset, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, "Invalid from_offset", err)
+			return
+		}
+		req.FromOffset = offset
+	}
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		streamID, last, found := strings.Cut(lastEventID, ":")
+		offset, err := strconv.ParseInt(last, 10, 64)
+		if !found || err != nil {
+			h.respondError(c, http.StatusBadRequest, "Invalid Last-Event-ID, expected <stream_id>:<offset>", err)
+			return
+		}
+		req.StreamId = streamID
+		req.FromOffset = offset + 1
+	}
+
+	// Event data has to fit on a single line
+	marshaler := h.marshaler
+	marshaler.Indent = ""
+
+	// Errors before the first event are answered as usual, the stream starts with it
+	started := false
+	err := h.grpcServer.WatchChanges(c.Request.Context(), &req, func(event *pb.WatchEvent) error {
+		if !started {
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Header("X-Accel-Buffering", "no") // Keep reverse proxies from buffering events
+			c.Status(http.StatusOK)
+			started = true
+		}
+
+		data, err := marshaler.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Writer, "id: %s:%d\nevent: %s\ndata: %s\n\n", event.StreamId, event.Offset, event.Type, data); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
+		return
+	}
+	if !started {
+		h.handleGRPCError(c, err)
+		return
+	}
+
+	// Tell the client why the stream ended, such as falling out of the backlog
+	fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", strconv.Quote(err.Error()))
+	c.Writer.Flush()
+}
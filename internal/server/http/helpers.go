@@ -70,8 +70,14 @@ func (h *Server) handleGRPCError(c *gin.Context, err error) {
 		h.respondError(c, http.StatusBadRequest, errMsg, nil)
 	} else if strings.Contains(errMsg, "Unauthenticated") || strings.Contains(errMsg, "unauthorized") {
 		h.respondError(c, http.StatusUnauthorized, errMsg, nil)
-	} else if strings.Contains(errMsg, "AlreadyExists") {
+	} else if strings.Contains(errMsg, "AlreadyExists") || strings.Contains(errMsg, "FailedPrecondition") {
 		h.respondError(c, http.StatusConflict, errMsg, nil)
+	} else if strings.Contains(errMsg, "ResourceExhausted") {
+		h.respondError(c, http.StatusInsufficientStorage, errMsg, nil)
+	} else if strings.Contains(errMsg, "OutOfRange") {
+		h.respondError(c, http.StatusGone, errMsg, nil)
+	} else if strings.Contains(errMsg, "Unavailable") {
+		h.respondError(c, http.StatusServiceUnavailable, errMsg, nil)
 	} else {
 		h.respondError(c, http.StatusInternalServerError, errMsg, nil)
 	}
@@ -14,19 +14,55 @@ func (h *Server) setupRoutes() {
 	protected := api.Group("")
 	protected.Use(authMiddleware())
 	{
+		// Server information requiring auth
+		protected.GET("/info", h.handleGetServerInfo)
+
 		// Database operations requiring auth
 		protected.POST("/databases", h.handleCreateDatabase)
 		protected.DELETE("/databases/:db_name", h.handleDropDatabase)
 		protected.GET("/databases", h.handleListDatabases)
 
+		// Backup operations requiring auth
+		protected.POST("/backups", h.handleCreateBackup)
+		protected.GET("/backups", h.handleListBackups)
+		protected.POST("/backups/:backup_name/restore", h.handleRestoreBackup)
+		protected.DELETE("/backups/:backup_name", h.handleDeleteBackup)
+
+		// Replication requiring auth, its state is part of /info
+		protected.POST("/replication/replicaof", h.handleReplicaOf)
+
+		// Cluster membership requiring auth
+		protected.GET("/cluster", h.handleGetClusterStatus)
+		protected.POST("/cluster/nodes", h.handleAddClusterNode)
+		protected.DELETE("/cluster/nodes/:node_id", h.handleRemoveClusterNode)
+
+		// Change stream requiring auth, as server-sent events
+		protected.GET("/watch", h.handleWatch)
+
 		// Collection operations requiring auth
 		protected.POST("/databases/:db_name/collections", h.handleCreateCollection)
 		protected.DELETE("/databases/:db_name/collections/:coll_name", h.handleDropCollection)
 		protected.GET("/databases/:db_name/collections/:coll_name", h.handleGetCollectionInfo)
 		protected.GET("/databases/:db_name/collections", h.handleListCollections)
+		protected.POST("/databases/:db_name/collections/:coll_name/rename", h.handleRenameCollection)
+		protected.POST("/databases/:db_name/collections/:coll_name/clone", h.handleCloneCollection)
+		protected.POST("/databases/:db_name/collections/:coll_name/copy", h.handleCopyCollection)
+		protected.POST("/databases/:db_name/collections/:coll_name/load", h.handleLoadCollection)
+		protected.POST("/databases/:db_name/collections/:coll_name/release", h.handleReleaseCollection)
+		protected.POST("/databases/:db_name/collections/:coll_name/reshard", h.handleReshardCollection)
+		protected.GET("/databases/:db_name/collections/:coll_name/export", h.handleExportCollection)
+
+		// Partition operations requiring auth
+		protected.POST("/databases/:db_name/collections/:coll_name/partitions", h.handleCreatePartition)
+		protected.GET("/databases/:db_name/collections/:coll_name/partitions", h.handleListPartitions)
+		protected.DELETE("/databases/:db_name/collections/:coll_name/partitions/:partition_name", h.handleDropPartition)
+		protected.POST("/databases/:db_name/collections/:coll_name/partitions/:partition_name/load", h.handleLoadPartition)
+		protected.POST("/databases/:db_name/collections/:coll_name/partitions/:partition_name/release", h.handleReleasePartition)
+		protected.POST("/databases/:db_name/collections/:coll_name/partitions/:partition_name/compact", h.handleCompactPartition)
 
 		// Vector operations requiring auth
 		protected.POST("/databases/:db_name/collections/:coll_name/vectors", h.handleInsertVectors)
+		protected.POST("/databases/:db_name/collections/:coll_name/vectors/stream", h.handleStreamInsertVectors)
 		protected.DELETE("/databases/:db_name/collections/:coll_name/vectors", h.handleDeleteVectors)
 		protected.POST("/databases/:db_name/collections/:coll_name/search", h.handleSearch)
 
@@ -5,11 +5,16 @@ import (
 	"context"
 	"time"
 
+	"github.com/scintirete/scintirete/internal/cdc"
+	"github.com/scintirete/scintirete/internal/cluster"
 	"github.com/scintirete/scintirete/internal/config"
 	"github.com/scintirete/scintirete/internal/core"
 	"github.com/scintirete/scintirete/internal/core/database"
 	"github.com/scintirete/scintirete/internal/embedding"
 	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/replication"
+	"github.com/scintirete/scintirete/internal/sharding"
+	"github.com/scintirete/scintirete/internal/webhook"
 )
 
 // ServerConfig contains server configuration shared by gRPC and HTTP servers
@@ -29,6 +34,25 @@ type ServerConfig struct {
 
 	// Monitoring
 	MonitoringConfig config.RuntimeMonitoringConfig `toml:"monitoring"`
+
+	// Memory limit and collection loading
+	MemoryLimit database.MemoryLimit `toml:"memory"`
+	LoadPolicy  database.LoadPolicy  `toml:"load"`
+
+	// Replication
+	ReplicationConfig replication.Config `toml:"replication"`
+
+	// Cluster mode, off without a node ID
+	ClusterConfig cluster.Config `toml:"cluster"`
+
+	// Connections to the shards of sharded collections
+	ShardingConfig sharding.Config `toml:"sharding"`
+
+	// Change events kept for watchers
+	CDCConfig cdc.Config `toml:"cdc"`
+
+	// Endpoints change events are posted to
+	WebhookConfig webhook.Config `toml:"webhook"`
 }
 
 // Stats contains server statistics
@@ -0,0 +1,711 @@
+// Package resp provides the commands of the Redis-compatible front-end.
+package resp
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultSimCount is the number of results of VSIM without COUNT, as in Redis
+const defaultSimCount = 10
+
+// replyError is an error replied as is, starting with its error code
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+var (
+	errSyntax = replyError("ERR syntax error")
+	errNoAuth = replyError("NOAUTH Authentication required.")
+)
+
+// command is a command of the front-end
+type command struct {
+	run    func(s *Server, ctx context.Context, sess *session, w *writer, args [][]byte) error
+	arity  int  // Number of arguments with the command name, at least -arity if negative
+	noAuth bool // Allowed before the connection is authenticated
+}
+
+var commands = map[string]command{
+	// Connection
+	"AUTH":   {run: (*Server).auth, arity: -2, noAuth: true},
+	"HELLO":  {run: (*Server).hello, arity: -1, noAuth: true},
+	"QUIT":   {run: (*Server).quit, arity: -1, noAuth: true},
+	"PING":   {run: (*Server).ping, arity: -1},
+	"ECHO":   {run: (*Server).echo, arity: 2},
+	"SELECT": {run: (*Server).selectDatabase, arity: 2},
+	"CLIENT": {run: (*Server).client, arity: -2},
+	"COMMAND": {run: func(_ *Server, _ context.Context, _ *session, w *writer, _ [][]byte) error {
+		// redis-cli asks for command docs on start, an empty reply leaves hints off
+		w.array(0)
+		return nil
+	}, arity: -1},
+
+	// Persistence
+	"SAVE":   {run: (*Server).save, arity: 1},
+	"BGSAVE": {run: (*Server).bgsave, arity: -1},
+
+	// Vector sets
+	"VADD":     {run: (*Server).vadd, arity: -4},
+	"VSIM":     {run: (*Server).vsim, arity: -4},
+	"VREM":     {run: (*Server).vrem, arity: -3},
+	"VCARD":    {run: (*Server).vcard, arity: 2},
+	"VDIM":     {run: (*Server).vdim, arity: 2},
+	"VINFO":    {run: (*Server).vinfo, arity: 2},
+	"VEMB":     {run: (*Server).vemb, arity: 3},
+	"VGETATTR": {run: (*Server).vgetattr, arity: 3},
+}
+
+// execute runs a command and writes its reply. It reports whether the connection is to
+// be closed.
+func (s *Server) execute(ctx context.Context, sess *session, w *writer, args [][]byte) bool {
+	name := strings.ToUpper(string(args[0]))
+	cmd, ok := commands[name]
+	switch {
+	case !ok:
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	case (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity):
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	case !cmd.noAuth && sess.auth == nil:
+		w.error(string(errNoAuth))
+		return false
+	}
+
+	if err := cmd.run(s, ctx, sess, w, args); err != nil {
+		writeError(w, err)
+	}
+	return name == "QUIT"
+}
+
+// writeError replies with an error, mapping gRPC status codes onto Redis error codes
+func writeError(w *writer, err error) {
+	var reply replyError
+	if errors.As(err, &reply) {
+		w.error(string(reply))
+		return
+	}
+
+	st := status.Convert(err)
+	switch {
+	case st.Code() == codes.Unauthenticated:
+		w.error("NOAUTH " + st.Message())
+	case st.Code() == codes.FailedPrecondition && strings.Contains(st.Message(), "read-only"):
+		w.error("READONLY " + st.Message())
+	default:
+		w.error("ERR " + st.Message())
+	}
+}
+
+// --- Connection ---
+
+// auth authenticates the connection: AUTH [username] password. The username is ignored,
+// as Scintirete has passwords only.
+func (s *Server) auth(_ context.Context, sess *session, w *writer, args [][]byte) error {
+	if len(args) > 3 {
+		return errSyntax
+	}
+	if err := s.authenticate(sess, string(args[len(args)-1])); err != nil {
+		return err
+	}
+	w.simple("OK")
+	return nil
+}
+
+// authenticate checks a password and authenticates the connection with it
+func (s *Server) authenticate(sess *session, password string) error {
+	if err := s.grpcServer.Authenticate(password); err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			return replyError("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		return err
+	}
+	sess.auth = &pb.AuthInfo{Password: password}
+	return nil
+}
+
+// hello negotiates the protocol version and replies with the state of the connection:
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (s *Server) hello(_ context.Context, sess *session, w *writer, args [][]byte) error {
+	proto := w.proto
+	if len(args) > 1 {
+		version, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return replyError("ERR Protocol version is not an integer or out of range")
+		}
+		if version != 2 && version != 3 {
+			return replyError("NOPROTO unsupported protocol version")
+		}
+		proto = version
+	}
+
+	var password, name string
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			password = string(args[i+2])
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			name = string(args[i+1])
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if password != "" {
+		if err := s.authenticate(sess, password); err != nil {
+			return err
+		}
+	}
+	if sess.auth == nil {
+		return replyError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+	if name != "" {
+		sess.name = name
+	}
+
+	w.proto = proto
+	w.mapHeader(6)
+	w.bulk("server")
+	w.bulk("scintirete")
+	w.bulk("proto")
+	w.integer(int64(proto))
+	w.bulk("id")
+	w.integer(sess.id)
+	w.bulk("mode")
+	w.bulk("standalone")
+	w.bulk("role")
+	w.bulk("master")
+	w.bulk("modules")
+	w.array(0)
+	return nil
+}
+
+func (s *Server) quit(_ context.Context, _ *session, w *writer, _ [][]byte) error {
+	w.simple("OK")
+	return nil
+}
+
+func (s *Server) ping(_ context.Context, _ *session, w *writer, args [][]byte) error {
+	switch len(args) {
+	case 1:
+		w.simple("PONG")
+	case 2:
+		w.bulk(string(args[1]))
+	default:
+		return replyError("ERR wrong number of arguments for 'ping' command")
+	}
+	return nil
+}
+
+func (s *Server) echo(_ context.Context, _ *session, w *writer, args [][]byte) error {
+	w.bulk(string(args[1]))
+	return nil
+}
+
+// selectDatabase sets the database of keys without one. Databases are named, so
+// SELECT 0 selects the database named "0".
+func (s *Server) selectDatabase(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	name := string(args[1])
+	resp, err := s.grpcServer.ListDatabases(ctx, &pb.ListDatabasesRequest{Auth: sess.auth})
+	if err != nil {
+		return err
+	}
+	for _, database := range resp.Names {
+		if database == name {
+			sess.database = name
+			w.simple("OK")
+			return nil
+		}
+	}
+	return replyError(fmt.Sprintf("ERR database '%s' not found", name))
+}
+
+// client implements the CLIENT subcommands clients send on connecting
+func (s *Server) client(_ context.Context, sess *session, w *writer, args [][]byte) error {
+	switch strings.ToUpper(string(args[1])) {
+	case "SETNAME":
+		if len(args) != 3 {
+			return errSyntax
+		}
+		sess.name = string(args[2])
+		w.simple("OK")
+	case "GETNAME":
+		if sess.name == "" {
+			w.null()
+		} else {
+			w.bulk(sess.name)
+		}
+	case "ID":
+		w.integer(sess.id)
+	case "SETINFO":
+		w.simple("OK")
+	default:
+		return replyError(fmt.Sprintf("ERR unknown subcommand '%s'", args[1]))
+	}
+	return nil
+}
+
+// --- Persistence ---
+
+func (s *Server) save(ctx context.Context, sess *session, w *writer, _ [][]byte) error {
+	resp, err := s.grpcServer.Save(ctx, &pb.SaveRequest{Auth: sess.auth})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return replyError("ERR " + resp.Message)
+	}
+	w.simple("OK")
+	return nil
+}
+
+// bgsave starts a background save: BGSAVE [SCHEDULE]. Saves never wait for each other,
+// so SCHEDULE is accepted and has no effect.
+func (s *Server) bgsave(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	if len(args) > 2 || (len(args) == 2 && !strings.EqualFold(string(args[1]), "SCHEDULE")) {
+		return errSyntax
+	}
+	resp, err := s.grpcServer.BgSave(ctx, &pb.BgSaveRequest{Auth: sess.auth})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return replyError("ERR " + resp.Message)
+	}
+	w.simple("Background saving started")
+	return nil
+}
+
+// --- Vector sets ---
+
+// vadd inserts a vector and replies with the ID the server assigns it:
+// VADD key (FP32 blob | VALUES num value ...) [SETATTR json] [TTL seconds] [PARTITION name]
+func (s *Server) vadd(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	dbName, collName, err := sess.key(args[1])
+	if err != nil {
+		return err
+	}
+	elements, i, err := parseVector(args, 2)
+	if err != nil {
+		return err
+	}
+
+	vector := &pb.Vector{Elements: elements}
+	var partition string
+	for start := i; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "SETATTR":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			i++
+			if vector.Metadata, err = parseAttributes(args[i]); err != nil {
+				return err
+			}
+		case "TTL":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			i++
+			ttl, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil || ttl <= 0 {
+				return replyError("ERR invalid TTL, expected a positive number of seconds")
+			}
+			vector.TtlSeconds = &ttl
+		case "PARTITION":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			i++
+			partition = string(args[i])
+		default:
+			if i == start {
+				return replyError("ERR element names are not supported, VADD replies with the ID the server assigns to the vector")
+			}
+			return errSyntax
+		}
+	}
+
+	resp, err := s.grpcServer.InsertVectors(ctx, &pb.InsertVectorsRequest{
+		Auth:           sess.auth,
+		DbName:         dbName,
+		CollectionName: collName,
+		PartitionName:  partition,
+		Vectors:        []*pb.Vector{vector},
+	})
+	if err != nil {
+		return err
+	}
+	w.integer(int64(resp.InsertedIds[0]))
+	return nil
+}
+
+// vsim searches for the vectors nearest to a vector or to the vector with an ID:
+// VSIM key (ELE id | FP32 blob | VALUES num value ...) [WITHSCORES] [WITHATTRIBS]
+// [COUNT n] [EF n] [PARTITION name]. Scores are the distances of the metric of the
+// collection.
+func (s *Server) vsim(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	dbName, collName, err := sess.key(args[1])
+	if err != nil {
+		return err
+	}
+
+	var query []float32
+	var i int
+	if strings.EqualFold(string(args[2]), "ELE") {
+		id, err := parseID(args[3])
+		if err != nil {
+			return err
+		}
+		vector, err := s.lookup(ctx, sess, dbName, collName, id)
+		if err != nil {
+			return err
+		}
+		if vector == nil {
+			return replyError("ERR element not found in the set")
+		}
+		query, i = vector.Elements, 4
+	} else if query, i, err = parseVector(args, 2); err != nil {
+		return err
+	}
+
+	req := &pb.SearchRequest{
+		Auth:           sess.auth,
+		DbName:         dbName,
+		CollectionName: collName,
+		QueryVector:    query,
+		TopK:           defaultSimCount,
+	}
+	var withScores, withAttribs bool
+	for ; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "WITHSCORES":
+			withScores = true
+		case "WITHATTRIBS":
+			withAttribs = true
+		case "COUNT", "EF":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			i++
+			n, err := strconv.Atoi(string(args[i]))
+			if err != nil || n <= 0 || n > math.MaxInt32 {
+				return replyError(fmt.Sprintf("ERR invalid %s, expected a positive integer", option))
+			}
+			if option == "COUNT" {
+				req.TopK = int32(n)
+			} else {
+				ef := int32(n)
+				req.EfSearch = &ef
+			}
+		case "PARTITION":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			i++
+			req.PartitionNames = append(req.PartitionNames, string(args[i]))
+		default:
+			return errSyntax
+		}
+	}
+
+	resp, err := s.grpcServer.Search(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// RESP3 replies map IDs to scores, attributes or both as Redis does
+	if !withScores && !withAttribs {
+		w.array(len(resp.Results))
+	} else if w.proto >= 3 {
+		w.mapHeader(len(resp.Results))
+	} else {
+		fields := 1
+		if withScores {
+			fields++
+		}
+		if withAttribs {
+			fields++
+		}
+		w.array(fields * len(resp.Results))
+	}
+	for _, result := range resp.Results {
+		w.bulk(strconv.FormatUint(result.Id, 10))
+		if withScores && withAttribs && w.proto >= 3 {
+			w.array(2)
+		}
+		if withScores {
+			w.double(float64(result.Distance))
+		}
+		if withAttribs {
+			writeAttributes(w, result.Metadata)
+		}
+	}
+	return nil
+}
+
+// vrem deletes vectors by their IDs and replies with the number deleted: VREM key id [id ...]
+func (s *Server) vrem(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	dbName, collName, err := sess.key(args[1])
+	if err != nil {
+		return err
+	}
+	ids := make([]uint64, len(args)-2)
+	for i, arg := range args[2:] {
+		if ids[i], err = parseID(arg); err != nil {
+			return err
+		}
+	}
+
+	resp, err := s.grpcServer.DeleteVectors(ctx, &pb.DeleteVectorsRequest{
+		Auth:           sess.auth,
+		DbName:         dbName,
+		CollectionName: collName,
+		Ids:            ids,
+	})
+	if err != nil {
+		return err
+	}
+	w.integer(int64(resp.DeletedCount))
+	return nil
+}
+
+func (s *Server) vcard(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	info, err := s.collectionInfo(ctx, sess, args[1])
+	if err != nil {
+		return err
+	}
+	w.integer(info.VectorCount)
+	return nil
+}
+
+func (s *Server) vdim(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	info, err := s.collectionInfo(ctx, sess, args[1])
+	if err != nil {
+		return err
+	}
+	w.integer(int64(info.Dimension))
+	return nil
+}
+
+// vinfo replies with the configuration and statistics of a collection as a map
+func (s *Server) vinfo(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	info, err := s.collectionInfo(ctx, sess, args[1])
+	if err != nil {
+		return err
+	}
+
+	w.mapHeader(10)
+	w.bulk("metric")
+	w.bulk(info.MetricType.String())
+	w.bulk("vector-dim")
+	w.integer(int64(info.Dimension))
+	w.bulk("size")
+	w.integer(info.VectorCount)
+	w.bulk("deleted")
+	w.integer(info.DeletedCount)
+	w.bulk("memory-bytes")
+	w.integer(info.MemoryBytes)
+	w.bulk("hnsw-m")
+	w.integer(int64(info.GetHnswConfig().GetM()))
+	w.bulk("hnsw-ef-construction")
+	w.integer(int64(info.GetHnswConfig().GetEfConstruction()))
+	w.bulk("default-ttl")
+	w.integer(info.DefaultTtlSeconds)
+	w.bulk("load-state")
+	w.bulk(info.LoadState.String())
+	w.bulk("partitions")
+	w.array(len(info.Partitions))
+	for _, partition := range info.Partitions {
+		w.bulk(partition.Name)
+	}
+	return nil
+}
+
+// vemb replies with the elements of the vector with an ID, null if there is none
+func (s *Server) vemb(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	vector, err := s.lookupKey(ctx, sess, args[1], args[2])
+	if err != nil {
+		return err
+	}
+	if vector == nil {
+		w.null()
+		return nil
+	}
+	w.array(len(vector.Elements))
+	for _, element := range vector.Elements {
+		w.double(float64(element))
+	}
+	return nil
+}
+
+// vgetattr replies with the metadata of the vector with an ID as JSON, null if there is
+// no vector or it has no metadata
+func (s *Server) vgetattr(ctx context.Context, sess *session, w *writer, args [][]byte) error {
+	vector, err := s.lookupKey(ctx, sess, args[1], args[2])
+	if err != nil {
+		return err
+	}
+	if vector == nil {
+		w.null()
+		return nil
+	}
+	writeAttributes(w, vector.Metadata)
+	return nil
+}
+
+// --- Helpers ---
+
+// key returns the database and collection of a key, <database>:<collection> or a
+// collection of the selected database
+func (sess *session) key(key []byte) (string, string, error) {
+	if dbName, collName, found := strings.Cut(string(key), ":"); found {
+		if dbName == "" || collName == "" {
+			return "", "", replyError("ERR invalid key, expected <database>:<collection>")
+		}
+		return dbName, collName, nil
+	}
+	if sess.database == "" {
+		return "", "", replyError("ERR no database selected, use SELECT <database> or a key of the form <database>:<collection>")
+	}
+	return sess.database, string(key), nil
+}
+
+func (s *Server) collectionInfo(ctx context.Context, sess *session, key []byte) (*pb.CollectionInfo, error) {
+	dbName, collName, err := sess.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.grpcServer.GetCollectionInfo(ctx, &pb.GetCollectionInfoRequest{
+		Auth:           sess.auth,
+		DbName:         dbName,
+		CollectionName: collName,
+	})
+}
+
+func (s *Server) lookupKey(ctx context.Context, sess *session, key, element []byte) (*pb.Vector, error) {
+	dbName, collName, err := sess.key(key)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseID(element)
+	if err != nil {
+		return nil, err
+	}
+	return s.lookup(ctx, sess, dbName, collName, id)
+}
+
+// lookup returns the vector with an ID, nil if there is none
+func (s *Server) lookup(ctx context.Context, sess *session, dbName, collName string, id uint64) (*pb.Vector, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	resp, err := s.grpcServer.ScanVectors(ctx, &pb.ScanVectorsRequest{
+		Auth:           sess.auth,
+		DbName:         dbName,
+		CollectionName: collName,
+		AfterId:        id - 1,
+		Limit:          1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Vectors) == 0 || resp.Vectors[0].GetId() != id {
+		return nil, nil
+	}
+	return resp.Vectors[0], nil
+}
+
+// parseVector parses a vector at args[i], FP32 followed by little-endian float32s or
+// VALUES followed by their count and the values. It returns the index after the vector.
+func parseVector(args [][]byte, i int) ([]float32, int, error) {
+	if i+1 >= len(args) {
+		return nil, 0, errSyntax
+	}
+	switch strings.ToUpper(string(args[i])) {
+	case "FP32":
+		blob := args[i+1]
+		if len(blob) == 0 || len(blob)%4 != 0 {
+			return nil, 0, replyError("ERR invalid FP32 blob, expected a multiple of 4 bytes")
+		}
+		elements := make([]float32, len(blob)/4)
+		for j := range elements {
+			elements[j] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*j:]))
+		}
+		return elements, i + 2, nil
+	case "VALUES":
+		count, err := strconv.Atoi(string(args[i+1]))
+		if err != nil || count <= 0 {
+			return nil, 0, replyError("ERR invalid vector length, expected a positive integer")
+		}
+		if i+2+count > len(args) {
+			return nil, 0, replyError("ERR fewer values than the vector length")
+		}
+		elements := make([]float32, count)
+		for j := range elements {
+			value, err := strconv.ParseFloat(string(args[i+2+j]), 32)
+			if err != nil {
+				return nil, 0, replyError(fmt.Sprintf("ERR invalid vector value '%s'", args[i+2+j]))
+			}
+			elements[j] = float32(value)
+		}
+		return elements, i + 2 + count, nil
+	default:
+		return nil, 0, replyError("ERR expected FP32 or VALUES before the vector")
+	}
+}
+
+// parseID parses the ID of a vector, which is its element name
+func parseID(arg []byte) (uint64, error) {
+	id, err := strconv.ParseUint(string(arg), 10, 64)
+	if err != nil {
+		return 0, replyError(fmt.Sprintf("ERR invalid element '%s', elements are the numeric IDs of vectors", arg))
+	}
+	return id, nil
+}
+
+// parseAttributes parses the attributes of SETATTR, a JSON object
+func parseAttributes(arg []byte) (*structpb.Struct, error) {
+	var attributes map[string]interface{}
+	if err := json.Unmarshal(arg, &attributes); err != nil || attributes == nil {
+		return nil, replyError("ERR invalid JSON in SETATTR, expected an object")
+	}
+	metadata, err := structpb.NewStruct(attributes)
+	if err != nil {
+		return nil, replyError("ERR invalid JSON in SETATTR: " + err.Error())
+	}
+	return metadata, nil
+}
+
+// writeAttributes writes metadata as JSON, null if there is none
+func writeAttributes(w *writer, metadata *structpb.Struct) {
+	if len(metadata.GetFields()) == 0 {
+		w.null()
+		return
+	}
+	data, err := json.Marshal(metadata.AsMap())
+	if err != nil {
+		w.null()
+		return
+	}
+	w.bulk(string(data))
+}
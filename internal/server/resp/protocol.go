@@ -0,0 +1,227 @@
+// Package resp provides the RESP protocol of the Redis-compatible front-end.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	maxArgs      = 1 << 20  // Arguments of a command
+	maxBulkBytes = 64 << 20 // Bytes of an argument, enough for a FP32 blob of 16M dimensions
+	maxInline    = 64 << 10 // Bytes of an inline command
+
+	// Limits before the connection is authenticated, enough for AUTH and HELLO
+	maxUnauthArgs      = 10
+	maxUnauthBulkBytes = 16 << 10
+)
+
+// errProtocol is returned for requests that are not RESP; the connection is closed after
+// replying, as the rest of the stream can't be trusted
+var errProtocol = errors.New("protocol error")
+
+// reader reads commands as arrays of bulk strings, or as inline commands for telnet
+type reader struct {
+	r *bufio.Reader
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// readCommand reads the arguments of the next command, empty for empty inline lines.
+// Connections that are not authenticated yet may only send small commands.
+func (r *reader) readCommand(authenticated bool) ([][]byte, error) {
+	argLimit, bulkLimit := maxArgs, maxBulkBytes
+	if !authenticated {
+		argLimit, bulkLimit = maxUnauthArgs, maxUnauthBulkBytes
+	}
+
+	line, err := r.readLine(maxInline)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return splitInline(line)
+	}
+
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count > argLimit {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+
+	// Grow the arguments as they arrive rather than trusting the announced count
+	args := make([][]byte, 0, min(count, 16))
+	for len(args) < count {
+		header, err := r.readLine(64)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got '%s'", errProtocol, header)
+		}
+		size, err := strconv.Atoi(string(header[1:]))
+		if err != nil || size < 0 || size > bulkLimit {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r.r, arg); err != nil {
+			return nil, err
+		}
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// readLine reads a line without its line ending, of at most limit bytes
+func (r *reader) readLine(limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > limit+2 {
+			return nil, fmt.Errorf("%w: too big request", errProtocol)
+		}
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// splitInline splits an inline command at spaces, keeping quoted arguments together
+func splitInline(line []byte) ([][]byte, error) {
+	var args [][]byte
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		var arg []byte
+		if quote := line[i]; quote == '"' || quote == '\'' {
+			i++
+			for ; i < len(line) && line[i] != quote; i++ {
+				if line[i] == '\\' && quote == '"' && i+1 < len(line) {
+					i++
+				}
+				arg = append(arg, line[i])
+			}
+			if i == len(line) {
+				return nil, fmt.Errorf("%w: unbalanced quotes in request", errProtocol)
+			}
+			i++
+		} else {
+			for ; i < len(line) && line[i] != ' ' && line[i] != '\t'; i++ {
+				arg = append(arg, line[i])
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// writer writes replies in the protocol version a connection negotiated with HELLO.
+// RESP2 has no maps, doubles or null type; they are written as flat arrays, bulk strings
+// and null bulk strings.
+type writer struct {
+	w     *bufio.Writer
+	proto int
+}
+
+func newWriter(w io.Writer) *writer {
+	return &writer{w: bufio.NewWriterSize(w, 64<<10), proto: 2}
+}
+
+func (w *writer) simple(s string) {
+	w.w.WriteByte('+')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// error writes an error reply; message starts with the error code, such as ERR
+func (w *writer) error(message string) {
+	w.w.WriteByte('-')
+	for i := 0; i < len(message); i++ {
+		// Error lines can't be broken
+		if c := message[i]; c == '\r' || c == '\n' {
+			w.w.WriteByte(' ')
+		} else {
+			w.w.WriteByte(c)
+		}
+	}
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.w.WriteByte(':')
+	w.w.WriteString(strconv.FormatInt(n, 10))
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) bulk(s string) {
+	w.w.WriteByte('$')
+	w.w.WriteString(strconv.Itoa(len(s)))
+	w.w.WriteString("\r\n")
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.w.WriteString("_\r\n")
+		return
+	}
+	w.w.WriteString("$-1\r\n")
+}
+
+func (w *writer) double(f float64) {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if w.proto < 3 {
+		w.bulk(s)
+		return
+	}
+	w.w.WriteByte(',')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// array starts an array of n elements, which are written next
+func (w *writer) array(n int) {
+	w.w.WriteByte('*')
+	w.w.WriteString(strconv.Itoa(n))
+	w.w.WriteString("\r\n")
+}
+
+// mapHeader starts a map of n pairs, whose keys and values are written next
+func (w *writer) mapHeader(n int) {
+	if w.proto < 3 {
+		w.array(2 * n)
+		return
+	}
+	w.w.WriteByte('%')
+	w.w.WriteString(strconv.Itoa(n))
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) flush() error {
+	return w.w.Flush()
+}
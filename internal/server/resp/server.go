@@ -0,0 +1,142 @@
+// Package resp provides a Redis-compatible front-end for Scintirete, so vectors can be
+// managed with redis-cli and Redis clients. It speaks RESP2 and, after HELLO 3, RESP3,
+// and maps the vector set commands of Redis onto the engine through the gRPC service.
+package resp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	grpcserver "github.com/scintirete/scintirete/internal/server/grpc"
+)
+
+// Server serves the RESP protocol on the listeners passed to Serve
+type Server struct {
+	grpcServer *grpcserver.Server
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+	nextID    atomic.Int64
+}
+
+// session is the state of a connection
+type session struct {
+	id       int64
+	auth     *pb.AuthInfo // Set by AUTH or HELLO, nil until then
+	database string       // Set by SELECT, the database of keys without one
+	name     string       // Set by CLIENT SETNAME
+}
+
+// NewServer creates a new RESP server
+func NewServer(grpcServer *grpcserver.Server) *Server {
+	return &Server{
+		grpcServer: grpcServer,
+		listeners:  make(map[net.Listener]struct{}),
+		conns:      make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts connections on lis until Close is called, serving each on its own goroutine
+func (s *Server) Serve(lis net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		lis.Close()
+		return net.ErrClosed
+	}
+	s.listeners[lis] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, lis)
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the listeners, closes the connections and waits for their commands to end
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for lis := range s.listeners {
+		lis.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// serveConn reads commands from a connection and replies to them in order
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := newReader(conn)
+	w := newWriter(conn)
+	sess := &session{id: s.nextID.Add(1)}
+	for {
+		args, err := r.readCommand(sess.auth != nil)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR Protocol error: " + strings.TrimPrefix(err.Error(), errProtocol.Error()+": "))
+				w.flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.execute(ctx, sess, w, args)
+
+		// Replies to pipelined commands are written together
+		if r.r.Buffered() == 0 || quit {
+			if err := w.flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
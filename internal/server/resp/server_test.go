@@ -0,0 +1,318 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/embedding"
+	"github.com/scintirete/scintirete/internal/persistence"
+	"github.com/scintirete/scintirete/internal/server"
+	grpcserver "github.com/scintirete/scintirete/internal/server/grpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "test-password"
+
+// testError is an error reply
+type testError string
+
+// testClient is a minimal RESP client
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// startTestServer serves a server with the collection testdb.testcoll of dimension 3 over
+// RESP and returns a client connected to it
+func startTestServer(t *testing.T) *testClient {
+	t.Helper()
+	ctx := context.Background()
+
+	grpcSrv, err := grpcserver.NewServer(server.ServerConfig{
+		Passwords: []string{testPassword},
+		PersistenceConfig: persistence.Config{
+			DataDir:         t.TempDir(),
+			RDBFilename:     "dump.rdb",
+			AOFFilename:     "appendonly.aof",
+			AOFSyncStrategy: "no",
+		},
+		EmbeddingConfig: embedding.Config{
+			BaseURL: "http://localhost:8080",
+			APIKey:  "test-key",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, grpcSrv.Start(ctx))
+	t.Cleanup(func() { grpcSrv.Stop(ctx) })
+
+	auth := &pb.AuthInfo{Password: testPassword}
+	_, err = grpcSrv.CreateDatabase(ctx, &pb.CreateDatabaseRequest{Auth: auth, Name: "testdb"})
+	require.NoError(t, err)
+	_, err = grpcSrv.CreateCollection(ctx, &pb.CreateCollectionRequest{
+		Auth:           auth,
+		DbName:         "testdb",
+		CollectionName: "testcoll",
+		MetricType:     pb.DistanceMetric_L2,
+		HnswConfig:     &pb.HnswConfig{M: 16, EfConstruction: 200},
+	})
+	require.NoError(t, err)
+
+	srv := NewServer(grpcSrv)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Close() })
+
+	return dialTestServer(t, lis.Addr().String())
+}
+
+func dialTestServer(t *testing.T, addr string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// send writes commands without reading their replies
+func (c *testClient) send(commands ...[]interface{}) {
+	c.t.Helper()
+	var buf []byte
+	for _, args := range commands {
+		buf = fmt.Appendf(buf, "*%d\r\n", len(args))
+		for _, arg := range args {
+			s := fmt.Sprint(arg)
+			if b, ok := arg.([]byte); ok {
+				s = string(b)
+			}
+			buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(s), s)
+		}
+	}
+	_, err := c.conn.Write(buf)
+	require.NoError(c.t, err)
+}
+
+// do sends a command and returns its reply
+func (c *testClient) do(args ...interface{}) interface{} {
+	c.t.Helper()
+	c.send(args)
+	reply, err := c.read()
+	require.NoError(c.t, err)
+	return reply
+}
+
+// read reads a reply: strings, int64s, float64s, nil, testErrors, slices and maps
+func (c *testClient) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("short line %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return testError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case ',':
+		return strconv.ParseFloat(payload, 64)
+	case '_':
+		return nil, nil
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case '%':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		items := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := c.read()
+			if err != nil {
+				return nil, err
+			}
+			if items[fmt.Sprint(key)], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+// fp32 encodes a vector as the blob of FP32
+func fp32(elements ...float32) []byte {
+	blob := make([]byte, 4*len(elements))
+	for i, element := range elements {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(element))
+	}
+	return blob
+}
+
+func TestAuthentication(t *testing.T) {
+	c := startTestServer(t)
+
+	assert.Equal(t, testError("NOAUTH Authentication required."), c.do("PING"))
+	assert.Equal(t, testError("NOAUTH Authentication required."), c.do("VCARD", "testdb:testcoll"))
+	assert.Contains(t, c.do("AUTH", "wrong"), "WRONGPASS")
+	assert.Contains(t, c.do("HELLO", "3"), "NOAUTH")
+	assert.Equal(t, "OK", c.do("AUTH", "default", testPassword))
+	assert.Equal(t, "PONG", c.do("ping"))
+	assert.Equal(t, "hello", c.do("ECHO", "hello"))
+	assert.Contains(t, c.do("NOSUCHCOMMAND"), "ERR unknown command")
+	assert.Contains(t, c.do("VCARD"), "ERR wrong number of arguments")
+}
+
+func TestUnauthenticatedLimits(t *testing.T) {
+	c := startTestServer(t)
+
+	// Commands larger than AUTH and HELLO need are refused before authentication
+	args := make([]interface{}, 11)
+	for i := range args {
+		args[i] = "ECHO"
+	}
+	assert.Equal(t, testError("ERR Protocol error: invalid multibulk length"), c.do(args...))
+	_, err := c.read()
+	assert.Equal(t, io.EOF, err, "protocol errors close the connection")
+
+	c = dialTestServer(t, c.conn.RemoteAddr().String())
+	assert.Equal(t, testError("ERR Protocol error: invalid bulk length"), c.do("AUTH", strings.Repeat("x", 32<<10)))
+
+	// Authenticated connections send large commands
+	c = dialTestServer(t, c.conn.RemoteAddr().String())
+	assert.Equal(t, "OK", c.do("AUTH", testPassword))
+	assert.Equal(t, strings.Repeat("x", 32<<10), c.do("ECHO", strings.Repeat("x", 32<<10)))
+}
+
+func TestVectorCommands(t *testing.T) {
+	c := startTestServer(t)
+	require.Equal(t, "OK", c.do("AUTH", testPassword))
+
+	// Keys name a collection of the selected database, or carry the database
+	assert.Contains(t, c.do("VCARD", "testcoll"), "no database selected")
+	assert.Contains(t, c.do("SELECT", "missing"), "not found")
+	require.Equal(t, "OK", c.do("SELECT", "testdb"))
+
+	id1, ok := c.do("VADD", "testcoll", "VALUES", 3, 1, 0, 0, "SETATTR", `{"category":"A"}`).(int64)
+	require.True(t, ok)
+	id2, ok := c.do("VADD", "testdb:testcoll", "FP32", fp32(0, 1, 0)).(int64)
+	require.True(t, ok)
+	assert.Greater(t, id2, id1)
+	assert.Contains(t, c.do("VADD", "testcoll", "VALUES", 3, 0, 0, 1, "element"), "element names are not supported")
+	assert.Contains(t, c.do("VADD", "testcoll", "VALUES", 2, 0, 1), "ERR")
+	assert.Contains(t, c.do("VADD", "testdb:missing", "VALUES", 3, 0, 0, 1), "ERR")
+
+	assert.Equal(t, int64(2), c.do("VCARD", "testcoll"))
+	assert.Equal(t, int64(3), c.do("VDIM", "testdb:testcoll"))
+
+	// Elements are IDs, scores distances
+	ids := []interface{}{strconv.FormatInt(id1, 10), strconv.FormatInt(id2, 10)}
+	assert.Equal(t, ids, c.do("VSIM", "testcoll", "VALUES", 3, 1, 0, 0))
+	assert.Equal(t, ids[:1], c.do("VSIM", "testcoll", "VALUES", 3, 1, 0, 0, "COUNT", 1))
+	assert.Equal(t, []interface{}{ids[1], "0"}, c.do("VSIM", "testcoll", "ELE", id2, "WITHSCORES", "COUNT", 1))
+	assert.Equal(t, []interface{}{ids[0], `{"category":"A"}`}, c.do("VSIM", "testcoll", "FP32", fp32(1, 0, 0), "WITHATTRIBS", "COUNT", 1, "EF", 50))
+	assert.Contains(t, c.do("VSIM", "testcoll", "VALUES", 3, 1, 0, 0, "COUNT", 0), "ERR")
+
+	assert.Equal(t, `{"category":"A"}`, c.do("VGETATTR", "testcoll", id1))
+	assert.Nil(t, c.do("VGETATTR", "testcoll", id2))
+	assert.Equal(t, []interface{}{"0", "1", "0"}, c.do("VEMB", "testcoll", id2))
+	assert.Nil(t, c.do("VEMB", "testcoll", id2+100))
+
+	assert.Equal(t, int64(1), c.do("VREM", "testcoll", id1, id2+100))
+	assert.Equal(t, int64(1), c.do("VCARD", "testcoll"))
+	assert.Contains(t, c.do("VREM", "testcoll", "element"), "numeric IDs")
+}
+
+func TestRESP3(t *testing.T) {
+	c := startTestServer(t)
+
+	hello, ok := c.do("HELLO", 3, "AUTH", "default", testPassword, "SETNAME", "ops").(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, int64(3), hello["proto"])
+	assert.Equal(t, "ops", c.do("CLIENT", "GETNAME"))
+
+	id, ok := c.do("VADD", "testdb:testcoll", "VALUES", 3, 1, 0, 0).(int64)
+	require.True(t, ok)
+
+	info, ok := c.do("VINFO", "testdb:testcoll").(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "L2", info["metric"])
+	assert.Equal(t, int64(3), info["vector-dim"])
+	assert.Equal(t, int64(1), info["size"])
+	assert.Equal(t, int64(16), info["hnsw-m"])
+
+	// Maps of IDs to scores, doubles and nulls of RESP3
+	assert.Equal(t, map[string]interface{}{strconv.FormatInt(id, 10): 0.0}, c.do("VSIM", "testdb:testcoll", "VALUES", 3, 1, 0, 0, "WITHSCORES"))
+	assert.Equal(t, []interface{}{1.0, 0.0, 0.0}, c.do("VEMB", "testdb:testcoll", id))
+	assert.Nil(t, c.do("VGETATTR", "testdb:testcoll", id))
+}
+
+func TestPersistenceCommands(t *testing.T) {
+	c := startTestServer(t)
+	require.Equal(t, "OK", c.do("AUTH", testPassword))
+
+	assert.Equal(t, "OK", c.do("SAVE"))
+	assert.Equal(t, "Background saving started", c.do("BGSAVE"))
+	assert.Equal(t, "Background saving started", c.do("BGSAVE", "SCHEDULE"))
+	assert.Equal(t, testError("ERR syntax error"), c.do("BGSAVE", "NOW"))
+}
+
+func TestPipelineAndInlineCommands(t *testing.T) {
+	c := startTestServer(t)
+
+	// Replies come in the order of the pipelined commands
+	c.send([]interface{}{"AUTH", testPassword}, []interface{}{"VCARD", "testdb:testcoll"}, []interface{}{"PING", "done"})
+	for _, want := range []interface{}{"OK", int64(0), "done"} {
+		reply, err := c.read()
+		require.NoError(t, err)
+		assert.Equal(t, want, reply)
+	}
+
+	// Inline commands as telnet sends them
+	_, err := c.conn.Write([]byte("ECHO \"a b\"\r\nQUIT\r\n"))
+	require.NoError(t, err)
+	for _, want := range []interface{}{"a b", "OK"} {
+		reply, err := c.read()
+		require.NoError(t, err)
+		assert.Equal(t, want, reply)
+	}
+	_, err = c.read()
+	assert.Equal(t, io.EOF, err, "QUIT closes the connection")
+}
@@ -0,0 +1,784 @@
+// Package sharding provides the router that coordinates sharded collections.
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultTimeout bounds each request to a shard unless configured otherwise
+	DefaultTimeout = 30 * time.Second
+
+	// scanBatch is how many vectors a resharding moves at a time
+	scanBatch = 256
+
+	maxRecvSize = 256 * 1024 * 1024 // Search results and scanned pages can be as large as shards send
+)
+
+// Config configures the router of a coordinator
+type Config struct {
+	Password string        // Password the shards accept
+	Timeout  time.Duration // Bounds each request to a shard, DefaultTimeout if 0
+}
+
+// Router routes the requests for the sharded collections a coordinator created to their
+// shards over gRPC
+type Router struct {
+	config   Config
+	registry *Registry
+	logger   core.Logger
+
+	adminMu sync.Mutex // Serializes creating, dropping and resharding collections
+
+	locksMu sync.Mutex
+	locks   map[string]*sync.RWMutex // Held for reading by writes, for writing while a resharding moves a batch
+
+	connMu sync.Mutex
+	conns  map[string]*grpc.ClientConn
+}
+
+// NewRouter creates a router with the registry at registryPath
+func NewRouter(config Config, registryPath string, logger core.Logger) (*Router, error) {
+	registry, err := OpenRegistry(registryPath)
+	if err != nil {
+		return nil, err
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	return &Router{
+		config:   config,
+		registry: registry,
+		logger:   logger,
+		locks:    make(map[string]*sync.RWMutex),
+		conns:    make(map[string]*grpc.ClientConn),
+	}, nil
+}
+
+// Close closes the connections to the shards
+func (r *Router) Close() error {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	var errs []error
+	for address, conn := range r.conns {
+		errs = append(errs, conn.Close())
+		delete(r.conns, address)
+	}
+	return errors.Join(errs...)
+}
+
+// Get returns the shard map of a collection, false if it is not a sharded collection
+func (r *Router) Get(dbName, collName string) (ShardMap, bool) {
+	return r.registry.Get(dbName, collName)
+}
+
+// List returns the shard maps of the sharded collections of a database by name
+func (r *Router) List(dbName string) []ShardMap {
+	return r.registry.List(dbName)
+}
+
+// Create creates a sharded collection: the collection that holds its vectors is created
+// on every shard, and the database too where it is missing
+func (r *Router) Create(ctx context.Context, dbName string, config types.CollectionConfig, shards []string) (ShardMap, error) {
+	if err := ValidateShards(shards); err != nil {
+		return ShardMap{}, err
+	}
+
+	r.adminMu.Lock()
+	defer r.adminMu.Unlock()
+
+	if _, exists := r.registry.Get(dbName, config.Name); exists {
+		return ShardMap{}, utils.ErrCollectionAlreadyExists(dbName, config.Name)
+	}
+
+	for i, address := range shards {
+		if err := r.createShard(ctx, address, dbName, config, false); err != nil {
+			// Leave no collection behind on the shards created so far
+			for _, created := range shards[:i] {
+				r.dropShard(ctx, created, dbName, config.Name)
+			}
+			return ShardMap{}, err
+		}
+	}
+
+	m := ShardMap{
+		Database:   dbName,
+		Collection: config.Name,
+		Config:     config,
+		Shards:     slices.Clone(shards),
+		Version:    1,
+	}
+	if err := r.registry.Put(m); err != nil {
+		for _, address := range shards {
+			r.dropShard(ctx, address, dbName, config.Name)
+		}
+		return ShardMap{}, err
+	}
+
+	r.logger.Info(ctx, "Created sharded collection", map[string]interface{}{
+		"database":   dbName,
+		"collection": config.Name,
+		"shards":     shards,
+	})
+	return m, nil
+}
+
+// Drop drops a sharded collection on all of its shards and returns how many vectors it held
+func (r *Router) Drop(ctx context.Context, dbName, collName string) (int64, error) {
+	r.adminMu.Lock()
+	defer r.adminMu.Unlock()
+
+	m, exists := r.registry.Get(dbName, collName)
+	if !exists {
+		return 0, utils.ErrCollectionNotFound(dbName, collName)
+	}
+
+	var dropped int64
+	for _, address := range m.Addresses() {
+		n, err := r.dropShard(ctx, address, dbName, collName)
+		if err != nil {
+			return dropped, err
+		}
+		dropped += n
+	}
+	if err := r.registry.Delete(dbName, collName); err != nil {
+		return dropped, err
+	}
+
+	r.logger.Info(ctx, "Dropped sharded collection", map[string]interface{}{
+		"database":        dbName,
+		"collection":      collName,
+		"dropped_vectors": dropped,
+	})
+	return dropped, nil
+}
+
+// DropDatabase drops the sharded collections of a database
+func (r *Router) DropDatabase(ctx context.Context, dbName string) error {
+	for _, m := range r.registry.List(dbName) {
+		if _, err := r.Drop(ctx, dbName, m.Collection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Info sums up the collection info of every shard
+func (r *Router) Info(ctx context.Context, dbName, collName string) (*pb.CollectionInfo, error) {
+	m, exists := r.registry.Get(dbName, collName)
+	if !exists {
+		return nil, utils.ErrCollectionNotFound(dbName, collName)
+	}
+
+	addresses := m.Addresses()
+	infos := make([]*pb.CollectionInfo, len(addresses))
+	err := r.each(ctx, addresses, func(ctx context.Context, i int, client pb.ScintireteServiceClient) error {
+		info, err := client.GetCollectionInfo(ctx, &pb.GetCollectionInfoRequest{
+			Auth:           r.auth(),
+			DbName:         dbName,
+			CollectionName: ShardCollection(collName),
+		})
+		infos[i] = info
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &pb.CollectionInfo{
+		Name:              collName,
+		MetricType:        m.Config.Metric.ToProto(),
+		HnswConfig:        m.Config.HNSWParams.ToProto(),
+		DefaultTtlSeconds: m.Config.DefaultTTLSeconds,
+		LoadState:         pb.LoadState_LOADED,
+		Shards:            m.Shards,
+		ReshardingFrom:    m.Previous,
+	}
+	partitions := make(map[string]*pb.PartitionInfo)
+	for _, shardInfo := range infos {
+		info.Dimension = max(info.Dimension, shardInfo.Dimension)
+		info.VectorCount += shardInfo.VectorCount
+		info.DeletedCount += shardInfo.DeletedCount
+		info.MemoryBytes += shardInfo.MemoryBytes
+		if shardInfo.LoadState != pb.LoadState_LOADED {
+			info.LoadState = shardInfo.LoadState
+		}
+		for _, p := range shardInfo.Partitions {
+			partition, ok := partitions[p.Name]
+			if !ok {
+				partition = &pb.PartitionInfo{Name: p.Name, Loaded: true}
+				partitions[p.Name] = partition
+				info.Partitions = append(info.Partitions, partition)
+			}
+			partition.VectorCount += p.VectorCount
+			partition.DeletedCount += p.DeletedCount
+			partition.MemoryBytes += p.MemoryBytes
+			partition.Loaded = partition.Loaded && p.Loaded
+		}
+	}
+	return info, nil
+}
+
+// Insert hands out IDs for vectors and inserts each of them on the shard that owns its ID.
+// It returns the IDs in the order of the vectors.
+func (r *Router) Insert(ctx context.Context, dbName, collName string, vectors []*pb.Vector) ([]uint64, error) {
+	lock := r.lock(dbName, collName)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	m, exists := r.registry.Get(dbName, collName)
+	if !exists {
+		return nil, utils.ErrCollectionNotFound(dbName, collName)
+	}
+
+	// Every shard fixes its dimension on its own, so the collection checks it as a whole
+	dimension, err := r.registry.SetDimension(dbName, collName, len(vectors[0].Elements))
+	if err != nil {
+		return nil, err
+	}
+	for _, vector := range vectors {
+		if len(vector.Elements) != dimension {
+			return nil, utils.ErrDimensionMismatch(dimension, len(vector.Elements))
+		}
+	}
+
+	first, err := r.registry.AllocateIDs(dbName, collName, len(vectors))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, len(vectors))
+	groups := make(map[string][]*pb.Vector)
+	for i, vector := range vectors {
+		id := first + uint64(i)
+		ids[i] = id
+		owner := m.Owner(id)
+		groups[owner] = append(groups[owner], &pb.Vector{
+			Id:       &id,
+			Elements: vector.Elements,
+			Metadata: vector.Metadata,
+			ExpireAt: vector.ExpireAt,
+		})
+	}
+
+	if err := r.insertGroups(ctx, dbName, collName, groups); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Delete deletes vectors on the shards that own them and returns how many were deleted.
+// While a resharding is in progress, the shards that owned them before are asked too.
+func (r *Router) Delete(ctx context.Context, dbName, collName string, ids []uint64) (int64, error) {
+	lock := r.lock(dbName, collName)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	m, exists := r.registry.Get(dbName, collName)
+	if !exists {
+		return 0, utils.ErrCollectionNotFound(dbName, collName)
+	}
+
+	groups := make(map[string][]uint64)
+	for _, id := range ids {
+		owner := m.Owner(id)
+		groups[owner] = append(groups[owner], id)
+		if previous := m.PreviousOwner(id); previous != "" && previous != owner {
+			groups[previous] = append(groups[previous], id)
+		}
+	}
+
+	addresses := sortedKeys(groups)
+	counts := make([]int64, len(addresses))
+	err := r.each(ctx, addresses, func(ctx context.Context, i int, client pb.ScintireteServiceClient) error {
+		resp, err := client.DeleteVectors(ctx, &pb.DeleteVectorsRequest{
+			Auth:           r.auth(),
+			DbName:         dbName,
+			CollectionName: ShardCollection(collName),
+			Ids:            groups[addresses[i]],
+		})
+		if err == nil {
+			counts[i] = int64(resp.DeletedCount)
+		}
+		return err
+	})
+
+	var deleted int64
+	for _, count := range counts {
+		deleted += count
+	}
+	return deleted, err
+}
+
+// Search searches every shard and merges their results by distance into the top_k of the
+// whole collection
+func (r *Router) Search(ctx context.Context, req *pb.SearchRequest) ([]*pb.SearchResultItem, error) {
+	m, exists := r.registry.Get(req.DbName, req.CollectionName)
+	if !exists {
+		return nil, utils.ErrCollectionNotFound(req.DbName, req.CollectionName)
+	}
+
+	addresses := m.Addresses()
+	results := make([][]*pb.SearchResultItem, len(addresses))
+	err := r.each(ctx, addresses, func(ctx context.Context, i int, client pb.ScintireteServiceClient) error {
+		resp, err := client.Search(ctx, &pb.SearchRequest{
+			Auth:           r.auth(),
+			DbName:         req.DbName,
+			CollectionName: ShardCollection(req.CollectionName),
+			QueryVector:    req.QueryVector,
+			TopK:           req.TopK,
+			EfSearch:       req.EfSearch,
+			IncludeVector:  req.IncludeVector,
+			PartitionNames: req.PartitionNames,
+		})
+		if err == nil {
+			results[i] = resp.Results
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeResults(results, int(req.TopK)), nil
+}
+
+// Scan returns up to limit vectors of the collection with an ID greater than afterID in
+// ascending ID order, merged from a page of every shard. A vector that a resharding has
+// copied but not removed yet is returned once.
+func (r *Router) Scan(ctx context.Context, dbName, collName string, afterID uint64, limit int) ([]*pb.Vector, error) {
+	m, exists := r.registry.Get(dbName, collName)
+	if !exists {
+		return nil, utils.ErrCollectionNotFound(dbName, collName)
+	}
+
+	addresses := m.Addresses()
+	pages := make([][]*pb.Vector, len(addresses))
+	err := r.each(ctx, addresses, func(ctx context.Context, i int, client pb.ScintireteServiceClient) error {
+		resp, err := client.ScanVectors(ctx, &pb.ScanVectorsRequest{
+			Auth:           r.auth(),
+			DbName:         dbName,
+			CollectionName: ShardCollection(collName),
+			AfterId:        afterID,
+			Limit:          int32(limit),
+		})
+		if err == nil {
+			pages[i] = resp.Vectors
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mergePages(pages, limit), nil
+}
+
+// mergePages merges pages of vectors in ascending ID order into the first limit of them,
+// keeping each ID once
+func mergePages(pages [][]*pb.Vector, limit int) []*pb.Vector {
+	var vectors []*pb.Vector
+	for _, page := range pages {
+		vectors = append(vectors, page...)
+	}
+	sort.Slice(vectors, func(i, j int) bool { return vectors[i].GetId() < vectors[j].GetId() })
+
+	merged := vectors[:0]
+	for _, vector := range vectors {
+		if len(merged) == limit {
+			break
+		}
+		if len(merged) > 0 && merged[len(merged)-1].GetId() == vector.GetId() {
+			continue
+		}
+		merged = append(merged, vector)
+	}
+	return merged
+}
+
+// mergeResults merges the results of the shards by distance into the top k. A vector that
+// a resharding has copied but not removed yet is found twice, and kept once.
+func mergeResults(results [][]*pb.SearchResultItem, k int) []*pb.SearchResultItem {
+	var merged []*pb.SearchResultItem
+	for _, shardResults := range results {
+		merged = append(merged, shardResults...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Distance < merged[j].Distance })
+
+	seen := make(map[uint64]bool, len(merged))
+	top := make([]*pb.SearchResultItem, 0, min(len(merged), k))
+	for _, item := range merged {
+		if seen[item.Id] {
+			continue
+		}
+		seen[item.Id] = true
+		top = append(top, item)
+		if len(top) >= k {
+			break
+		}
+	}
+	return top
+}
+
+// Reshard moves the vectors of a collection to a new list of shards and returns how many
+// were moved. Reads and writes go on meanwhile: new vectors go to the new shards, deletes
+// and searches reach both. An interrupted resharding resumes when it is repeated with the
+// same shards.
+func (r *Router) Reshard(ctx context.Context, dbName, collName string, shards []string) (int64, error) {
+	if err := ValidateShards(shards); err != nil {
+		return 0, err
+	}
+
+	r.adminMu.Lock()
+	defer r.adminMu.Unlock()
+
+	m, exists := r.registry.Get(dbName, collName)
+	if !exists {
+		return 0, utils.ErrCollectionNotFound(dbName, collName)
+	}
+
+	if m.Resharding() {
+		if !slices.Equal(m.Shards, shards) {
+			return 0, utils.ErrInvalidParameters(fmt.Sprintf(
+				"collection '%s' is being resharded to %v, repeat it with the same shards to resume", collName, m.Shards))
+		}
+	} else {
+		if slices.Equal(m.Shards, shards) {
+			return 0, nil
+		}
+		for _, address := range shards {
+			if slices.Contains(m.Shards, address) {
+				continue
+			}
+			if err := r.createShard(ctx, address, dbName, m.Config, true); err != nil {
+				return 0, err
+			}
+		}
+
+		// From now on writes go to the new shards, once those routed by the old map are done
+		m.Previous = m.Shards
+		m.Shards = slices.Clone(shards)
+		m.Version++
+		if err := r.switchMap(m, nil); err != nil {
+			return 0, err
+		}
+		r.logger.Info(ctx, "Resharding collection", map[string]interface{}{
+			"database":   dbName,
+			"collection": collName,
+			"from":       m.Previous,
+			"to":         m.Shards,
+			"version":    m.Version,
+		})
+	}
+
+	var moved int64
+	for _, address := range m.Previous {
+		n, err := r.moveVectors(ctx, m, address)
+		moved += n
+		if err != nil {
+			return moved, err
+		}
+	}
+
+	// Shards that are not in the list anymore hold no vectors now
+	removed := m.Previous
+	m.Previous = nil
+	if err := r.switchMap(m, func() error {
+		for _, address := range removed {
+			if slices.Contains(m.Shards, address) {
+				continue
+			}
+			if _, err := r.dropShard(ctx, address, dbName, collName); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return moved, err
+	}
+
+	r.logger.Info(ctx, "Resharded collection", map[string]interface{}{
+		"database":      dbName,
+		"collection":    collName,
+		"shards":        m.Shards,
+		"version":       m.Version,
+		"moved_vectors": moved,
+	})
+	return moved, nil
+}
+
+// switchMap replaces the shard map of a collection while no write is routed by it,
+// after running prepare if it is not nil
+func (r *Router) switchMap(m ShardMap, prepare func() error) error {
+	lock := r.lock(m.Database, m.Collection)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if prepare != nil {
+		if err := prepare(); err != nil {
+			return err
+		}
+	}
+	return r.registry.Put(m)
+}
+
+// moveVectors moves the vectors of a shard that other shards own under m to them, one
+// batch at a time. Deletes wait while a batch is scanned and moved, so none is lost or
+// copied back in between.
+func (r *Router) moveVectors(ctx context.Context, m ShardMap, address string) (int64, error) {
+	client, err := r.client(address)
+	if err != nil {
+		return 0, err
+	}
+
+	var moved int64
+	var afterID uint64
+	for {
+		n, last, done, err := r.moveBatch(ctx, m, address, client, afterID)
+		moved += n
+		if err != nil || done {
+			return moved, err
+		}
+		afterID = last
+	}
+}
+
+// moveBatch scans the next batch of vectors of a shard after afterID, copies those that
+// other shards own to them, then deletes them on the shard they came from. It returns how
+// many were moved, the last ID scanned and whether the shard was scanned to the end.
+func (r *Router) moveBatch(ctx context.Context, m ShardMap, address string, client pb.ScintireteServiceClient, afterID uint64) (int64, uint64, bool, error) {
+	lock := r.lock(m.Database, m.Collection)
+	lock.Lock()
+	defer lock.Unlock()
+
+	callCtx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	resp, err := client.ScanVectors(callCtx, &pb.ScanVectorsRequest{
+		Auth:           r.auth(),
+		DbName:         m.Database,
+		CollectionName: ShardCollection(m.Collection),
+		AfterId:        afterID,
+		Limit:          scanBatch,
+	})
+	cancel()
+	if err != nil {
+		return 0, afterID, false, shardError(address, err)
+	}
+	if len(resp.Vectors) == 0 {
+		return 0, afterID, true, nil
+	}
+	last := resp.Vectors[len(resp.Vectors)-1].GetId()
+	done := len(resp.Vectors) < scanBatch
+
+	groups := make(map[string][]*pb.Vector)
+	var ids []uint64
+	for _, vector := range resp.Vectors {
+		if owner := m.Owner(vector.GetId()); owner != address {
+			groups[owner] = append(groups[owner], vector)
+			ids = append(ids, vector.GetId())
+		}
+	}
+	if len(ids) == 0 {
+		return 0, last, done, nil
+	}
+
+	// Vectors copied before an interruption are skipped by the new owner
+	if err := r.insertGroups(ctx, m.Database, m.Collection, groups); err != nil {
+		return 0, afterID, false, err
+	}
+
+	callCtx, cancel = context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+	if _, err := client.DeleteVectors(callCtx, &pb.DeleteVectorsRequest{
+		Auth:           r.auth(),
+		DbName:         m.Database,
+		CollectionName: ShardCollection(m.Collection),
+		Ids:            ids,
+	}); err != nil {
+		return 0, afterID, false, shardError(address, err)
+	}
+	return int64(len(ids)), last, done, nil
+}
+
+// insertGroups inserts the vectors of every group on the shard it is keyed by
+func (r *Router) insertGroups(ctx context.Context, dbName, collName string, groups map[string][]*pb.Vector) error {
+	addresses := sortedKeys(groups)
+	return r.each(ctx, addresses, func(ctx context.Context, i int, client pb.ScintireteServiceClient) error {
+		_, err := client.ShardInsertVectors(ctx, &pb.ShardInsertVectorsRequest{
+			Auth:           r.auth(),
+			DbName:         dbName,
+			CollectionName: ShardCollection(collName),
+			Vectors:        groups[addresses[i]],
+		})
+		return err
+	})
+}
+
+// createShard creates the collection of a sharded collection on a shard, and the database
+// if it is missing. With existingOK a collection that exists already is kept.
+func (r *Router) createShard(ctx context.Context, address, dbName string, config types.CollectionConfig, existingOK bool) error {
+	client, err := r.client(address)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	if _, err := client.CreateDatabase(ctx, &pb.CreateDatabaseRequest{
+		Auth: r.auth(),
+		Name: dbName,
+	}); err != nil && status.Code(err) != codes.AlreadyExists {
+		return shardError(address, err)
+	}
+
+	hnswConfig := config.HNSWParams.ToProto()
+	defaultTTL := config.DefaultTTLSeconds
+	if _, err := client.CreateCollection(ctx, &pb.CreateCollectionRequest{
+		Auth:              r.auth(),
+		DbName:            dbName,
+		CollectionName:    ShardCollection(config.Name),
+		MetricType:        config.Metric.ToProto(),
+		HnswConfig:        hnswConfig,
+		DefaultTtlSeconds: &defaultTTL,
+	}); err != nil && !(existingOK && status.Code(err) == codes.AlreadyExists) {
+		return shardError(address, err)
+	}
+	return nil
+}
+
+// dropShard drops the collection of a sharded collection on a shard and returns how many
+// vectors it held. A shard without it has nothing to drop.
+func (r *Router) dropShard(ctx context.Context, address, dbName, collName string) (int64, error) {
+	client, err := r.client(address)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	resp, err := client.DropCollection(ctx, &pb.DropCollectionRequest{
+		Auth:           r.auth(),
+		DbName:         dbName,
+		CollectionName: ShardCollection(collName),
+	})
+	if status.Code(err) == codes.NotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, shardError(address, err)
+	}
+	return resp.DroppedVectors, nil
+}
+
+// lock returns the lock of a collection
+func (r *Router) lock(dbName, collName string) *sync.RWMutex {
+	r.locksMu.Lock()
+	defer r.locksMu.Unlock()
+
+	key := mapKey(dbName, collName)
+	lock, ok := r.locks[key]
+	if !ok {
+		lock = &sync.RWMutex{}
+		r.locks[key] = lock
+	}
+	return lock
+}
+
+// client returns a client of the gRPC service of a shard
+func (r *Router) client(address string) (pb.ScintireteServiceClient, error) {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	conn, ok := r.conns[address]
+	if !ok {
+		var err error
+		conn, err = grpc.NewClient(address,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxRecvSize)),
+		)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "shard %s: %v", address, err)
+		}
+		r.conns[address] = conn
+	}
+	return pb.NewScintireteServiceClient(conn), nil
+}
+
+// auth returns the credentials sent to the shards
+func (r *Router) auth() *pb.AuthInfo {
+	return &pb.AuthInfo{Password: r.config.Password}
+}
+
+// each calls call for the client of every address in parallel, each with its own timeout,
+// and returns the first error
+func (r *Router) each(ctx context.Context, addresses []string, call func(ctx context.Context, i int, client pb.ScintireteServiceClient) error) error {
+	errs := make([]error, len(addresses))
+	var wg sync.WaitGroup
+	for i, address := range addresses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := r.client(address)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			callCtx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+			defer cancel()
+			if err := call(callCtx, i, client); err != nil {
+				errs[i] = shardError(address, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shardError names the shard a request failed on and keeps the gRPC code of the error
+func shardError(address string, err error) error {
+	st := status.Convert(err)
+	return status.Errorf(st.Code(), "shard %s: %s", address, st.Message())
+}
+
+// validateAddress checks that address is host:port
+func validateAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host == "" || port == "" {
+		return errors.New("host and port are required")
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
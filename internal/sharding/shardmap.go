@@ -0,0 +1,322 @@
+// Package sharding provides sharded collections: the vectors of a collection are
+// hash-partitioned by ID across several servers, and the server that created the
+// collection coordinates it, routing writes to the owning shard and fanning searches out.
+package sharding
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/scintirete/scintirete/internal/utils"
+	"github.com/scintirete/scintirete/pkg/types"
+)
+
+// ShardSuffix is appended to the name of a sharded collection to name the collection
+// that holds its vectors on every shard, so that a coordinator can be a shard too
+const ShardSuffix = ".shard"
+
+// idBlock is how many IDs are reserved in the registry file at a time
+const idBlock = 4096
+
+// ShardCollection returns the name of the collection that holds the vectors of a
+// sharded collection on its shards
+func ShardCollection(name string) string {
+	return name + ShardSuffix
+}
+
+// ShardMap describes where the vectors of a sharded collection are
+type ShardMap struct {
+	Database   string                 `json:"database"`
+	Collection string                 `json:"collection"`
+	Config     types.CollectionConfig `json:"config"`
+	Dimension  int                    `json:"dimension,omitempty"` // Fixed by the first insert
+	Shards     []string               `json:"shards"`              // gRPC addresses of the shards, host:port
+	Previous   []string               `json:"previous,omitempty"`  // Shards before a resharding that is in progress
+	Version    uint64                 `json:"version"`             // Incremented by every resharding
+	NextID     uint64                 `json:"next_id"`             // First ID not reserved yet
+}
+
+// Resharding reports whether vectors are being moved to a new list of shards
+func (m ShardMap) Resharding() bool {
+	return len(m.Previous) > 0
+}
+
+// Owner returns the shard that holds the vector with the given ID
+func (m ShardMap) Owner(id uint64) string {
+	return m.Shards[jumpHash(id, len(m.Shards))]
+}
+
+// PreviousOwner returns the shard that held the vector with the given ID before the
+// resharding in progress, empty if none is
+func (m ShardMap) PreviousOwner(id uint64) string {
+	if !m.Resharding() {
+		return ""
+	}
+	return m.Previous[jumpHash(id, len(m.Previous))]
+}
+
+// Addresses returns every shard that may hold vectors, the current ones followed by
+// those of a resharding in progress
+func (m ShardMap) Addresses() []string {
+	addresses := slices.Clone(m.Shards)
+	for _, address := range m.Previous {
+		if !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// clone returns a copy of the map that shares no slices with it
+func (m ShardMap) clone() ShardMap {
+	m.Shards = slices.Clone(m.Shards)
+	m.Previous = slices.Clone(m.Previous)
+	return m
+}
+
+// jumpHash maps key to one of buckets buckets with the jump consistent hash of Lamping
+// and Veach: growing the number of buckets by one moves only 1/buckets of the keys
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// ValidateShards checks a list of shard addresses
+func ValidateShards(shards []string) error {
+	if len(shards) == 0 {
+		return utils.ErrInvalidParameters("a sharded collection needs at least one shard")
+	}
+	for i, address := range shards {
+		if err := validateAddress(address); err != nil {
+			return utils.ErrInvalidParameters(fmt.Sprintf("invalid shard address '%s': %v", address, err))
+		}
+		if slices.Contains(shards[:i], address) {
+			return utils.ErrInvalidParameters(fmt.Sprintf("shard %s is listed twice", address))
+		}
+	}
+	return nil
+}
+
+// registryFile is the content of the registry file
+type registryFile struct {
+	Collections []ShardMap `json:"collections"`
+}
+
+// Registry keeps the shard maps of the collections a coordinator routes in a JSON file,
+// replaced atomically on every change
+type Registry struct {
+	path string
+
+	mu   sync.Mutex
+	maps map[string]*ShardMap
+	next map[string]uint64 // Next ID to hand out by collection, below the NextID in the file
+}
+
+// OpenRegistry opens the registry at path, empty if the file does not exist yet
+func OpenRegistry(path string) (*Registry, error) {
+	r := &Registry{
+		path: path,
+		maps: make(map[string]*ShardMap),
+		next: make(map[string]uint64),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, utils.ErrRecoveryFailed("failed to read shard registry: " + err.Error())
+	}
+
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, utils.ErrCorruptedData("malformed shard registry: " + err.Error())
+	}
+	for i := range file.Collections {
+		m := file.Collections[i]
+		key := mapKey(m.Database, m.Collection)
+		r.maps[key] = &m
+		// IDs reserved before a restart may have been handed out
+		r.next[key] = m.NextID
+	}
+	return r, nil
+}
+
+// Get returns the shard map of a collection
+func (r *Registry) Get(dbName, collName string) (ShardMap, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.maps[mapKey(dbName, collName)]
+	if !ok {
+		return ShardMap{}, false
+	}
+	return m.clone(), true
+}
+
+// List returns the shard maps of the collections of a database by name
+func (r *Registry) List(dbName string) []ShardMap {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var maps []ShardMap
+	for _, m := range r.maps {
+		if m.Database == dbName {
+			maps = append(maps, m.clone())
+		}
+	}
+	sort.Slice(maps, func(i, j int) bool { return maps[i].Collection < maps[j].Collection })
+	return maps
+}
+
+// Put adds or replaces the shard map of a collection. IDs reserved before stay reserved.
+func (r *Registry) Put(m ShardMap) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := mapKey(m.Database, m.Collection)
+	m = m.clone()
+	if existing, ok := r.maps[key]; ok {
+		m.NextID = max(m.NextID, existing.NextID)
+	}
+	m.NextID = max(m.NextID, 1)
+
+	previous, existed := r.maps[key]
+	r.maps[key] = &m
+	if err := r.saveLocked(); err != nil {
+		if existed {
+			r.maps[key] = previous
+		} else {
+			delete(r.maps, key)
+		}
+		return err
+	}
+	r.next[key] = max(r.next[key], 1)
+	return nil
+}
+
+// Delete removes the shard map of a collection
+func (r *Registry) Delete(dbName, collName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := mapKey(dbName, collName)
+	m, ok := r.maps[key]
+	if !ok {
+		return nil
+	}
+	delete(r.maps, key)
+	if err := r.saveLocked(); err != nil {
+		r.maps[key] = m
+		return err
+	}
+	delete(r.next, key)
+	return nil
+}
+
+// SetDimension fixes the dimension of a collection unless its first insert did already,
+// and returns the dimension the collection has
+func (r *Registry) SetDimension(dbName, collName string, dimension int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.maps[mapKey(dbName, collName)]
+	if !ok {
+		return 0, utils.ErrCollectionNotFound(dbName, collName)
+	}
+	if m.Dimension != 0 {
+		return m.Dimension, nil
+	}
+
+	m.Dimension = dimension
+	if err := r.saveLocked(); err != nil {
+		m.Dimension = 0
+		return 0, err
+	}
+	return dimension, nil
+}
+
+// AllocateIDs hands out count consecutive IDs of a collection and returns the first one.
+// IDs are reserved in the file in blocks, so that none is handed out twice across restarts.
+func (r *Registry) AllocateIDs(dbName, collName string, count int) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := mapKey(dbName, collName)
+	m, ok := r.maps[key]
+	if !ok {
+		return 0, utils.ErrCollectionNotFound(dbName, collName)
+	}
+
+	first := r.next[key]
+	end := first + uint64(count)
+	if end > m.NextID {
+		reserved := m.NextID
+		m.NextID = end + idBlock
+		if err := r.saveLocked(); err != nil {
+			m.NextID = reserved
+			return 0, err
+		}
+	}
+	r.next[key] = end
+	return first, nil
+}
+
+// saveLocked replaces the registry file. Caller must hold r.mu.
+func (r *Registry) saveLocked() (err error) {
+	file := registryFile{Collections: make([]ShardMap, 0, len(r.maps))}
+	for _, m := range r.maps {
+		file.Collections = append(file.Collections, *m)
+	}
+	sort.Slice(file.Collections, func(i, j int) bool {
+		return mapKey(file.Collections[i].Database, file.Collections[i].Collection) <
+			mapKey(file.Collections[j].Database, file.Collections[j].Collection)
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to encode shard registry", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to create shard registry directory", err)
+	}
+	tempPath := r.path + ".tmp"
+	f, err := os.Create(tempPath)
+	if err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to create shard registry", err)
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tempPath) // Clean up on error
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to write shard registry", err)
+	}
+	if err = f.Sync(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to sync shard registry", err)
+	}
+	if err = f.Close(); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to close shard registry", err)
+	}
+	if err = os.Rename(tempPath, r.path); err != nil {
+		return utils.ErrPersistenceFailedWithCause("failed to replace shard registry", err)
+	}
+	return nil
+}
+
+// mapKey identifies a collection in the registry
+func mapKey(dbName, collName string) string {
+	return dbName + "/" + collName
+}
@@ -0,0 +1,154 @@
+package sharding
+
+import (
+	"path/filepath"
+	"testing"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJumpHashMovesKeysOnlyToNewShard(t *testing.T) {
+	counts := make([]int, 3)
+	moved := 0
+	for id := uint64(1); id <= 3000; id++ {
+		before := jumpHash(id, 3)
+		after := jumpHash(id, 4)
+		counts[before]++
+		if before != after {
+			moved++
+			// Growing by one shard only moves keys to the new one
+			assert.Equal(t, 3, after)
+		}
+	}
+
+	// Sequential IDs spread evenly, and a quarter of them moves to the fourth shard
+	for _, count := range counts {
+		assert.InDelta(t, 1000, count, 150)
+	}
+	assert.InDelta(t, 750, moved, 150)
+}
+
+func TestShardMapOwners(t *testing.T) {
+	m := ShardMap{Shards: []string{"a:1", "b:1", "c:1"}}
+	assert.False(t, m.Resharding())
+	assert.Empty(t, m.PreviousOwner(7))
+	assert.Equal(t, []string{"a:1", "b:1", "c:1"}, m.Addresses())
+
+	m.Previous = []string{"c:1", "d:1"}
+	assert.True(t, m.Resharding())
+	assert.Equal(t, []string{"a:1", "b:1", "c:1", "d:1"}, m.Addresses())
+	for id := uint64(1); id <= 100; id++ {
+		assert.Contains(t, m.Shards, m.Owner(id))
+		assert.Contains(t, m.Previous, m.PreviousOwner(id))
+	}
+}
+
+func TestValidateShards(t *testing.T) {
+	assert.NoError(t, ValidateShards([]string{"127.0.0.1:9090", "shard-2:9090"}))
+	assert.Error(t, ValidateShards(nil))
+	assert.Error(t, ValidateShards([]string{"127.0.0.1"}))
+	assert.Error(t, ValidateShards([]string{":9090"}))
+	assert.Error(t, ValidateShards([]string{"a:1", "b:1", "a:1"}))
+}
+
+func TestRegistryPersistsMapsAndIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shards.json")
+	registry, err := OpenRegistry(path)
+	require.NoError(t, err)
+
+	m := ShardMap{
+		Database:   "db",
+		Collection: "docs",
+		Config:     types.CollectionConfig{Name: "docs", Metric: types.DistanceMetricL2, HNSWParams: types.DefaultHNSWParams()},
+		Shards:     []string{"a:1", "b:1"},
+		Version:    1,
+	}
+	require.NoError(t, registry.Put(m))
+
+	first, err := registry.AllocateIDs("db", "docs", 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), first)
+	first, err = registry.AllocateIDs("db", "docs", 5)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(11), first)
+
+	// Replacing the map keeps the IDs handed out
+	m.Previous, m.Shards, m.Version = m.Shards, []string{"a:1", "b:1", "c:1"}, 2
+	require.NoError(t, registry.Put(m))
+	first, err = registry.AllocateIDs("db", "docs", 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(16), first)
+
+	_, err = registry.AllocateIDs("db", "missing", 1)
+	assert.Error(t, err)
+
+	// The first insert fixes the dimension
+	dimension, err := registry.SetDimension("db", "docs", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, dimension)
+	dimension, err = registry.SetDimension("db", "docs", 5)
+	require.NoError(t, err)
+	assert.Equal(t, 3, dimension)
+
+	// A restart skips the rest of the reserved block, so no ID is handed out twice
+	reopened, err := OpenRegistry(path)
+	require.NoError(t, err)
+	got, ok := reopened.Get("db", "docs")
+	require.True(t, ok)
+	assert.Equal(t, []string{"a:1", "b:1", "c:1"}, got.Shards)
+	assert.Equal(t, []string{"a:1", "b:1"}, got.Previous)
+	assert.Equal(t, uint64(2), got.Version)
+	assert.Equal(t, types.DistanceMetricL2, got.Config.Metric)
+	assert.Equal(t, 3, got.Dimension)
+
+	first, err = reopened.AllocateIDs("db", "docs", 1)
+	require.NoError(t, err)
+	assert.Greater(t, first, uint64(16))
+
+	assert.Len(t, reopened.List("db"), 1)
+	assert.Empty(t, reopened.List("other"))
+
+	require.NoError(t, reopened.Delete("db", "docs"))
+	_, ok = reopened.Get("db", "docs")
+	assert.False(t, ok)
+	reopened, err = OpenRegistry(path)
+	require.NoError(t, err)
+	assert.Empty(t, reopened.List("db"))
+}
+
+func TestMergeResultsKeepsTopKOnce(t *testing.T) {
+	results := [][]*pb.SearchResultItem{
+		{{Id: 1, Distance: 0.1}, {Id: 4, Distance: 0.4}},
+		{{Id: 2, Distance: 0.2}, {Id: 3, Distance: 0.3}},
+		// A vector that a resharding copied but not removed yet
+		{{Id: 2, Distance: 0.2}},
+	}
+
+	merged := mergeResults(results, 3)
+	require.Len(t, merged, 3)
+	assert.Equal(t, uint64(1), merged[0].Id)
+	assert.Equal(t, uint64(2), merged[1].Id)
+	assert.Equal(t, uint64(3), merged[2].Id)
+
+	assert.Len(t, mergeResults(results, 10), 4)
+}
+
+func TestMergePagesKeepsIDsOnce(t *testing.T) {
+	vector := func(id uint64) *pb.Vector { return &pb.Vector{Id: &id} }
+	pages := [][]*pb.Vector{
+		{vector(1), vector(5), vector(6)},
+		{vector(2), vector(3), vector(4)},
+		// A vector that a resharding copied but not removed yet
+		{vector(3)},
+	}
+
+	var ids []uint64
+	for _, v := range mergePages(pages, 4) {
+		ids = append(ids, v.GetId())
+	}
+	assert.Equal(t, []uint64{1, 2, 3, 4}, ids)
+	assert.Len(t, mergePages(pages, 10), 6)
+}
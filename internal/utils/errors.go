@@ -15,6 +15,7 @@ const (
 	ErrorCodeConfig   ErrorCode = 1001
 	ErrorCodeTimeout  ErrorCode = 1002
 	ErrorCodeResource ErrorCode = 1003
+	ErrorCodeReadOnly ErrorCode = 1004
 
 	// Authentication errors (2000-2999)
 	ErrorCodeUnauthorized ErrorCode = 2000
@@ -31,12 +32,18 @@ const (
 	ErrorCodeInvalidVectorID         ErrorCode = 3006
 	ErrorCodeInvalidParameters       ErrorCode = 3007
 	ErrorCodeEmptyCollection         ErrorCode = 3008
+	ErrorCodePartitionNotFound       ErrorCode = 3009
+	ErrorCodePartitionAlreadyExists  ErrorCode = 3010
+	ErrorCodePartitionNotLoaded      ErrorCode = 3011
+	ErrorCodeCollectionNotLoaded     ErrorCode = 3012
+	ErrorCodeCollectionReleased      ErrorCode = 3013
 
 	// Persistence errors (4000-4999)
 	ErrorCodePersistenceFailed ErrorCode = 4000
 	ErrorCodeRecoveryFailed    ErrorCode = 4001
 	ErrorCodeCorruptedData     ErrorCode = 4002
 	ErrorCodeDiskSpace         ErrorCode = 4003
+	ErrorCodeBackupNotFound    ErrorCode = 4004
 
 	// Algorithm errors (5000-5999)
 	ErrorCodeIndexBuildFailed ErrorCode = 5000
@@ -62,6 +69,8 @@ func (ec ErrorCode) String() string {
 		return "TIMEOUT"
 	case ErrorCodeResource:
 		return "RESOURCE"
+	case ErrorCodeReadOnly:
+		return "READ_ONLY"
 
 	// Authentication errors
 	case ErrorCodeUnauthorized:
@@ -90,6 +99,16 @@ func (ec ErrorCode) String() string {
 		return "INVALID_PARAMETERS"
 	case ErrorCodeEmptyCollection:
 		return "EMPTY_COLLECTION"
+	case ErrorCodePartitionNotFound:
+		return "PARTITION_NOT_FOUND"
+	case ErrorCodePartitionAlreadyExists:
+		return "PARTITION_ALREADY_EXISTS"
+	case ErrorCodePartitionNotLoaded:
+		return "PARTITION_NOT_LOADED"
+	case ErrorCodeCollectionNotLoaded:
+		return "COLLECTION_NOT_LOADED"
+	case ErrorCodeCollectionReleased:
+		return "COLLECTION_RELEASED"
 
 	// Persistence errors
 	case ErrorCodePersistenceFailed:
@@ -100,6 +119,8 @@ func (ec ErrorCode) String() string {
 		return "CORRUPTED_DATA"
 	case ErrorCodeDiskSpace:
 		return "DISK_SPACE"
+	case ErrorCodeBackupNotFound:
+		return "BACKUP_NOT_FOUND"
 
 	// Algorithm errors
 	case ErrorCodeIndexBuildFailed:
@@ -195,6 +216,14 @@ func ErrTimeout(message string) *ScintireteError {
 	return NewError(ErrorCodeTimeout, message)
 }
 
+func ErrOutOfMemory(usedBytes, maxBytes int64) *ScintireteError {
+	return NewError(ErrorCodeResource, fmt.Sprintf("command not allowed when used memory (%d bytes) > 'maxmemory' (%d bytes)", usedBytes, maxBytes))
+}
+
+func ErrReadOnly(primary string) *ScintireteError {
+	return NewError(ErrorCodeReadOnly, fmt.Sprintf("server is a read-only replica of %s, send writes to the primary", primary))
+}
+
 // Authentication errors
 func ErrUnauthorized(message string) *ScintireteError {
 	return NewError(ErrorCodeUnauthorized, message)
@@ -249,6 +278,33 @@ func ErrEmptyCollection(dbName, collName string) *ScintireteError {
 		fmt.Sprintf("collection '%s' in database '%s' is empty", collName, dbName))
 }
 
+func ErrPartitionNotFound(collName, partitionName string) *ScintireteError {
+	return NewError(ErrorCodePartitionNotFound,
+		fmt.Sprintf("partition '%s' not found in collection '%s'", partitionName, collName))
+}
+
+func ErrPartitionAlreadyExists(collName, partitionName string) *ScintireteError {
+	return NewError(ErrorCodePartitionAlreadyExists,
+		fmt.Sprintf("partition '%s' already exists in collection '%s'", partitionName, collName))
+}
+
+func ErrPartitionNotLoaded(collName, partitionName string) *ScintireteError {
+	return NewError(ErrorCodePartitionNotLoaded,
+		fmt.Sprintf("partition '%s' in collection '%s' is not loaded", partitionName, collName))
+}
+
+func ErrCollectionNotLoaded(dbName, collName string) *ScintireteError {
+	return NewError(ErrorCodeCollectionNotLoaded,
+		fmt.Sprintf("collection '%s' in database '%s' is released and must be loaded first", collName, dbName))
+}
+
+// ErrCollectionReleased is returned by a collection released to disk while a request held
+// it. Retrying looks the collection up again.
+func ErrCollectionReleased(collName string) *ScintireteError {
+	return NewError(ErrorCodeCollectionReleased,
+		fmt.Sprintf("collection '%s' was released from memory during the request, retry it", collName))
+}
+
 // Persistence errors
 func ErrPersistenceFailed(message string) *ScintireteError {
 	return NewError(ErrorCodePersistenceFailed, message)
@@ -266,6 +322,10 @@ func ErrCorruptedData(message string) *ScintireteError {
 	return NewError(ErrorCodeCorruptedData, message)
 }
 
+func ErrBackupNotFound(name string) *ScintireteError {
+	return NewError(ErrorCodeBackupNotFound, fmt.Sprintf("backup '%s' not found", name))
+}
+
 // Algorithm errors
 func ErrIndexBuildFailed(message string) *ScintireteError {
 	return NewError(ErrorCodeIndexBuildFailed, message)
@@ -0,0 +1,72 @@
+package vectorfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonRecord is a line of a JSON lines file, as the HTTP API has vectors
+type jsonRecord struct {
+	ID         *uint64                `json:"id"`
+	Elements   []float32              `json:"elements"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	TTLSeconds int64                  `json:"ttl_seconds,omitempty"`
+	ExpireAt   int64                  `json:"expire_at,omitempty"`
+}
+
+// jsonlReader reads a record per line, skipping blank lines
+type jsonlReader struct {
+	file  *os.File
+	lines *bufio.Reader
+	line  int
+	count uint64
+}
+
+func newJSONLReader(file *os.File) *jsonlReader {
+	return &jsonlReader{file: file, lines: bufio.NewReaderSize(file, 1<<20)}
+}
+
+func (r *jsonlReader) Read() (Record, error) {
+	for {
+		line, err := readLine(r.lines)
+		if err != nil {
+			return Record{}, err
+		}
+		r.line++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var value jsonRecord
+		if err := json.Unmarshal(line, &value); err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		if len(value.Elements) == 0 {
+			return Record{}, fmt.Errorf("line %d: no elements", r.line)
+		}
+
+		record := Record{
+			ID:         r.count,
+			Elements:   value.Elements,
+			Metadata:   value.Metadata,
+			TTLSeconds: value.TTLSeconds,
+			ExpireAt:   value.ExpireAt,
+		}
+		if value.ID != nil {
+			record.ID = *value.ID
+		}
+		r.count++
+		return record, nil
+	}
+}
+
+func (r *jsonlReader) Total() int64 {
+	return -1
+}
+
+func (r *jsonlReader) Close() error {
+	return r.file.Close()
+}
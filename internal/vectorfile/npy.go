@@ -0,0 +1,219 @@
+package vectorfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const npyMagic = "\x93NUMPY"
+
+var (
+	npyDescr        = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	npyFortranOrder = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShape        = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// npyReader reads the rows of a two-dimensional NumPy array of floats or integers
+type npyReader struct {
+	file   *os.File
+	data   *bufio.Reader
+	order  binary.ByteOrder
+	kind   byte // f, i or u
+	size   int  // Bytes per element
+	rows   int64
+	dim    int
+	row    int64
+	buffer []byte
+}
+
+func newNPYReader(file *os.File) (*npyReader, error) {
+	data := bufio.NewReaderSize(file, 1<<20)
+	prefix := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(data, prefix); err != nil {
+		return nil, errors.New("not a npy file: too short")
+	}
+	if string(prefix[:len(npyMagic)]) != npyMagic {
+		return nil, errors.New("not a npy file: missing magic")
+	}
+
+	var headerSize int
+	switch major := prefix[len(npyMagic)]; major {
+	case 1:
+		var size uint16
+		if err := binary.Read(data, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		headerSize = int(size)
+	case 2, 3:
+		var size uint32
+		if err := binary.Read(data, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		if size > 1<<20 {
+			return nil, fmt.Errorf("npy header of %d bytes is too large", size)
+		}
+		headerSize = int(size)
+	default:
+		return nil, fmt.Errorf("npy format version %d isn't supported", major)
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(data, header); err != nil {
+		return nil, fmt.Errorf("truncated npy header: %w", err)
+	}
+
+	r := &npyReader{file: file, data: data}
+	if err := r.parseHeader(string(header)); err != nil {
+		return nil, err
+	}
+	r.buffer = make([]byte, r.dim*r.size)
+	return r, nil
+}
+
+// parseHeader parses the Python dict literal describing the array
+func (r *npyReader) parseHeader(header string) error {
+	descr := npyDescr.FindStringSubmatch(header)
+	fortranOrder := npyFortranOrder.FindStringSubmatch(header)
+	shape := npyShape.FindStringSubmatch(header)
+	if descr == nil || fortranOrder == nil || shape == nil {
+		return fmt.Errorf("invalid npy header %q", header)
+	}
+	if fortranOrder[1] == "True" {
+		return errors.New("npy arrays in Fortran order aren't supported, save them in C order")
+	}
+
+	// Data type such as <f4: byte order, kind and size
+	dtype := descr[1]
+	if len(dtype) < 3 {
+		return fmt.Errorf("npy data type '%s' isn't supported", dtype)
+	}
+	switch dtype[0] {
+	case '<', '|', '=':
+		r.order = binary.LittleEndian
+	case '>':
+		r.order = binary.BigEndian
+	default:
+		return fmt.Errorf("npy data type '%s' isn't supported", dtype)
+	}
+	r.kind = dtype[1]
+	size, err := strconv.Atoi(dtype[2:])
+	if err != nil {
+		return fmt.Errorf("npy data type '%s' isn't supported", dtype)
+	}
+	r.size = size
+	switch {
+	case r.kind == 'f' && (size == 2 || size == 4 || size == 8):
+	case (r.kind == 'i' || r.kind == 'u') && (size == 1 || size == 2 || size == 4 || size == 8):
+	default:
+		return fmt.Errorf("npy data type '%s' isn't supported, expected floats or integers", dtype)
+	}
+
+	var dims []int64
+	for _, part := range strings.Split(shape[1], ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		dim, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || dim < 0 {
+			return fmt.Errorf("invalid npy shape (%s)", shape[1])
+		}
+		dims = append(dims, dim)
+	}
+	if len(dims) != 2 || dims[1] == 0 || dims[1] > math.MaxInt32 {
+		return fmt.Errorf("npy shape (%s) isn't a two-dimensional array of vectors", shape[1])
+	}
+	r.rows, r.dim = dims[0], int(dims[1])
+	return nil
+}
+
+func (r *npyReader) Read() (Record, error) {
+	if r.row >= r.rows {
+		return Record{}, io.EOF
+	}
+	if _, err := io.ReadFull(r.data, r.buffer); err != nil {
+		return Record{}, fmt.Errorf("npy data ends at row %d of %d", r.row, r.rows)
+	}
+
+	elements := make([]float32, r.dim)
+	for i := range elements {
+		elements[i] = r.element(r.buffer[i*r.size:])
+	}
+	record := Record{ID: uint64(r.row), Elements: elements}
+	r.row++
+	return record, nil
+}
+
+// element converts the element at the start of b
+func (r *npyReader) element(b []byte) float32 {
+	switch r.kind {
+	case 'f':
+		switch r.size {
+		case 2:
+			return halfToFloat32(r.order.Uint16(b))
+		case 4:
+			return math.Float32frombits(r.order.Uint32(b))
+		default:
+			return float32(math.Float64frombits(r.order.Uint64(b)))
+		}
+	case 'i':
+		switch r.size {
+		case 1:
+			return float32(int8(b[0]))
+		case 2:
+			return float32(int16(r.order.Uint16(b)))
+		case 4:
+			return float32(int32(r.order.Uint32(b)))
+		default:
+			return float32(int64(r.order.Uint64(b)))
+		}
+	default:
+		switch r.size {
+		case 1:
+			return float32(b[0])
+		case 2:
+			return float32(r.order.Uint16(b))
+		case 4:
+			return float32(r.order.Uint32(b))
+		default:
+			return float32(r.order.Uint64(b))
+		}
+	}
+}
+
+func (r *npyReader) Total() int64 {
+	return r.rows
+}
+
+func (r *npyReader) Close() error {
+	return r.file.Close()
+}
+
+// halfToFloat32 converts an IEEE 754 half precision float
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+
+	switch {
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal: normalize the mantissa
+		for mant&0x400 == 0 {
+			mant <<= 1
+			exp--
+		}
+		exp++
+		mant &= 0x3ff
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
@@ -0,0 +1,427 @@
+package vectorfile
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Names of the Parquet column of the elements, in order of preference
+var vectorColumns = []string{"elements", "vector", "embedding", "embeddings", "emb"}
+
+// parquetColumn describes a leaf column of the schema
+type parquetColumn struct {
+	path     []string // Names from the top-level field down to the leaf
+	kind     parquet.Kind
+	text     bool // BYTE_ARRAY annotated as a UTF-8 string, enum or JSON
+	unsigned bool // Integers annotated as unsigned
+	maxDef   int  // Definition level of a present value
+	maxRep   int  // Repetition level, 1 for a list
+	repeated int  // Definition level of the repeated field of a list
+}
+
+// name returns the name of the top-level field of the column
+func (c *parquetColumn) name() string {
+	return c.path[0]
+}
+
+func (c *parquetColumn) String() string {
+	return strings.Join(c.path, ".")
+}
+
+func (c *parquetColumn) numeric() bool {
+	switch c.kind {
+	case parquet.Float, parquet.Double, parquet.Int32, parquet.Int64:
+		return true
+	}
+	return false
+}
+
+// parquetReader reads a row group at a time. The elements come from a list column of
+// numbers, the ID from an integer id column, and the metadata from a JSON metadata
+// column merged with the values of all other columns.
+type parquetReader struct {
+	file     *os.File
+	parquet  *parquet.File
+	columns  []parquetColumn
+	elements int            // Column of the elements
+	id       int            // Column of the IDs, -1 if none
+	metadata int            // Column of JSON metadata, -1 if none
+	ttl      int            // Column of the times to live, -1 if none
+	expireAt int            // Column of the expiry times, -1 if none
+	fields   map[int]string // Other columns, by the metadata field they fill
+
+	group  int
+	rows   parquet.Rows // Of the current row group, nil before the next one
+	buf    []parquet.Row
+	next   int               // Row of buf to read next
+	values [][]parquet.Value // Of the row being read, by column
+	count  uint64
+}
+
+func newParquetReader(file *os.File, column string) (*parquetReader, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	f, err := parquet.OpenFile(file, stat.Size(), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &parquetReader{file: file, parquet: f, elements: -1, id: -1, metadata: -1, ttl: -1, expireAt: -1, fields: make(map[int]string)}
+	r.columns = parquetColumns(f.Schema())
+	r.values = make([][]parquet.Value, len(r.columns))
+	if err := r.mapColumns(column); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// parquetColumns returns the leaf columns of schema, in column order
+func parquetColumns(schema *parquet.Schema) []parquetColumn {
+	paths := schema.Columns()
+	columns := make([]parquetColumn, len(paths))
+	for i, path := range paths {
+		leaf, _ := schema.Lookup(path...)
+		typ := leaf.Node.Type()
+		col := parquetColumn{
+			path:   path,
+			kind:   typ.Kind(),
+			maxDef: leaf.MaxDefinitionLevel,
+			maxRep: leaf.MaxRepetitionLevel,
+		}
+		if logical := typ.LogicalType(); logical != nil {
+			col.text = col.kind == parquet.ByteArray && (logical.UTF8 != nil || logical.Enum != nil || logical.Json != nil)
+			col.unsigned = logical.Integer != nil && !logical.Integer.IsSigned
+		}
+
+		// Walk down to the leaf for the definition level of the list's repeated field
+		var node parquet.Node = schema
+		def := 0
+		for _, name := range path {
+			for _, field := range node.Fields() {
+				if field.Name() == name {
+					node = field
+					break
+				}
+			}
+			if node.Optional() || node.Repeated() {
+				def++
+			}
+			if node.Repeated() {
+				col.repeated = def
+			}
+		}
+		columns[i] = col
+	}
+	return columns
+}
+
+// mapColumns decides what each column of the file holds
+func (r *parquetReader) mapColumns(column string) error {
+	cols := r.columns
+	leaves := make(map[string]int, len(cols))
+	for _, col := range cols {
+		leaves[col.name()]++
+	}
+
+	// The elements are a list of numbers, picked by name
+	candidates := vectorColumns
+	if column != "" {
+		candidates = []string{column}
+	}
+	for _, name := range candidates {
+		for i, col := range cols {
+			if col.name() == name && leaves[name] == 1 && col.maxRep == 1 && col.numeric() {
+				r.elements = i
+				break
+			}
+		}
+		if r.elements >= 0 {
+			break
+		}
+	}
+	if r.elements < 0 && column == "" {
+		// Or the only list of numbers there is
+		for i, col := range cols {
+			if leaves[col.name()] == 1 && col.maxRep == 1 && col.numeric() {
+				if r.elements >= 0 {
+					return errors.New("several list columns could hold the elements, select one")
+				}
+				r.elements = i
+			}
+		}
+	}
+	if r.elements < 0 {
+		if column != "" {
+			return fmt.Errorf("column '%s' isn't a list of numbers", column)
+		}
+		return errors.New("no list column of numbers holds the elements")
+	}
+
+	for i, col := range cols {
+		if i == r.elements {
+			continue
+		}
+		if col.maxRep > 1 {
+			return fmt.Errorf("column %s: nested lists aren't supported", &col)
+		}
+		integer := col.maxRep == 0 && (col.kind == parquet.Int32 || col.kind == parquet.Int64)
+		switch {
+		case leaves[col.name()] > 1:
+			// A field of a struct, under its path
+			r.fields[i] = col.String()
+		case col.name() == "id" && integer:
+			r.id = i
+		case col.name() == "metadata" && col.maxRep == 0 && col.text:
+			r.metadata = i
+		case col.name() == "ttl_seconds" && integer:
+			r.ttl = i
+		case col.name() == "expire_at" && integer:
+			r.expireAt = i
+		default:
+			r.fields[i] = col.name()
+		}
+	}
+	return nil
+}
+
+func (r *parquetReader) Read() (Record, error) {
+	if err := r.readRow(); err != nil {
+		return Record{}, err
+	}
+
+	elements, err := r.float32s(r.elements)
+	if err != nil {
+		return Record{}, fmt.Errorf("row %d: %w", r.count, err)
+	}
+	if len(elements) == 0 {
+		return Record{}, fmt.Errorf("row %d: no elements", r.count)
+	}
+
+	record := Record{ID: r.count, Elements: elements}
+	r.count++
+	if r.id >= 0 {
+		switch id := r.value(r.id).(type) {
+		case int64:
+			if id < 0 {
+				return Record{}, fmt.Errorf("row %d: negative id %d", record.ID, id)
+			}
+			record.ID = uint64(id)
+		case uint64:
+			record.ID = id
+		}
+	}
+	if r.ttl >= 0 {
+		record.TTLSeconds = integerValue(r.value(r.ttl))
+	}
+	if r.expireAt >= 0 {
+		record.ExpireAt = integerValue(r.value(r.expireAt))
+	}
+
+	if r.metadata >= 0 {
+		if text, ok := r.value(r.metadata).(string); ok && text != "" {
+			if err := json.Unmarshal([]byte(text), &record.Metadata); err != nil {
+				return Record{}, fmt.Errorf("row %d: invalid metadata: %w", record.ID, err)
+			}
+		}
+	}
+	for i, field := range r.fields {
+		value := r.value(i)
+		if value == nil {
+			continue
+		}
+		if record.Metadata == nil {
+			record.Metadata = make(map[string]interface{}, len(r.fields))
+		}
+		record.Metadata[field] = metadataValue(value)
+	}
+	return record, nil
+}
+
+// readRow reads the next row into values, moving on to the next row group at the end
+// of one
+func (r *parquetReader) readRow() error {
+	for r.next >= len(r.buf) {
+		if r.rows == nil {
+			groups := r.parquet.RowGroups()
+			if r.group >= len(groups) {
+				return io.EOF
+			}
+			r.rows = groups[r.group].Rows()
+			r.group++
+			if r.buf == nil {
+				r.buf = make([]parquet.Row, 128)
+			}
+		}
+
+		n, err := r.rows.ReadRows(r.buf[:cap(r.buf)])
+		r.buf, r.next = r.buf[:n], 0
+		if errors.Is(err, io.EOF) {
+			r.rows.Close()
+			r.rows = nil
+		} else if err != nil {
+			return fmt.Errorf("row group %d: %w", r.group-1, err)
+		}
+	}
+
+	row := r.buf[r.next]
+	r.next++
+	for i := range r.values {
+		r.values[i] = nil
+	}
+	row.Range(func(column int, values []parquet.Value) bool {
+		if column < len(r.values) {
+			r.values[column] = values
+		}
+		return true
+	})
+	return nil
+}
+
+// value returns the value of column in the current row: nil if null, []interface{} for
+// a list, otherwise bool, int64, uint64 if unsigned, float32, float64, string for text
+// or []byte
+func (r *parquetReader) value(column int) interface{} {
+	col := &r.columns[column]
+	values := r.values[column]
+	if len(values) == 0 {
+		return nil
+	}
+	if col.maxRep == 0 {
+		return col.convert(values[0])
+	}
+
+	// A single value below the repeated level is an empty or null list
+	if len(values) == 1 && values[0].DefinitionLevel() < col.repeated {
+		if values[0].DefinitionLevel() < col.repeated-1 {
+			return nil
+		}
+		return []interface{}{}
+	}
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = col.convert(v)
+	}
+	return list
+}
+
+// float32s returns the numbers of the list column in the current row as float32, nil
+// if the row has none
+func (r *parquetReader) float32s(column int) ([]float32, error) {
+	col := &r.columns[column]
+	values := r.values[column]
+	if len(values) == 0 || (len(values) == 1 && values[0].DefinitionLevel() < col.repeated) {
+		return nil, nil
+	}
+
+	out := make([]float32, len(values))
+	for i, v := range values {
+		if v.DefinitionLevel() < col.maxDef {
+			return nil, fmt.Errorf("element %d is null", i)
+		}
+		switch col.kind {
+		case parquet.Float:
+			out[i] = v.Float()
+		case parquet.Double:
+			out[i] = float32(v.Double())
+		case parquet.Int32:
+			if col.unsigned {
+				out[i] = float32(v.Uint32())
+			} else {
+				out[i] = float32(v.Int32())
+			}
+		case parquet.Int64:
+			if col.unsigned {
+				out[i] = float32(v.Uint64())
+			} else {
+				out[i] = float32(v.Int64())
+			}
+		default:
+			return nil, fmt.Errorf("%s values aren't numbers", col.kind)
+		}
+	}
+	return out, nil
+}
+
+// convert returns a single value of the column, see value
+func (c *parquetColumn) convert(v parquet.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	switch c.kind {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		if c.unsigned {
+			return uint64(v.Uint32())
+		}
+		return int64(v.Int32())
+	case parquet.Int64:
+		if c.unsigned {
+			return v.Uint64()
+		}
+		return v.Int64()
+	case parquet.Float:
+		return v.Float()
+	case parquet.Double:
+		return v.Double()
+	default:
+		if c.text {
+			return string(v.ByteArray())
+		}
+		return append([]byte(nil), v.Bytes()...)
+	}
+}
+
+func (r *parquetReader) Total() int64 {
+	return r.parquet.NumRows()
+}
+
+func (r *parquetReader) Close() error {
+	if r.rows != nil {
+		r.rows.Close()
+		r.rows = nil
+	}
+	return r.file.Close()
+}
+
+func integerValue(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	}
+	return 0
+}
+
+// metadataValue converts a Parquet value to a JSON value. Binary values become strings,
+// base64 encoded if they aren't UTF-8.
+func metadataValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return base64.StdEncoding.EncodeToString(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = metadataValue(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
@@ -0,0 +1,98 @@
+package vectorfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// maxVecsDim bounds the dimension of corrupt .fvecs and .bvecs files
+const maxVecsDim = 1 << 20
+
+// vecsReader reads the .fvecs and .bvecs formats of the ANN benchmark datasets: each
+// vector is its little-endian int32 dimension followed by as many float32 or uint8
+type vecsReader struct {
+	file   *os.File
+	data   *bufio.Reader
+	width  int // Bytes per element, 4 for fvecs and 1 for bvecs
+	total  int64
+	count  uint64
+	buffer []byte
+}
+
+func newVecsReader(file *os.File, width int) (*vecsReader, error) {
+	r := &vecsReader{file: file, data: bufio.NewReaderSize(file, 1<<20), width: width, total: -1}
+
+	// The number of vectors follows from the size of the file if they share the first
+	// vector's dimension, as they do in benchmark datasets
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.data.Peek(4)
+	if err == io.EOF {
+		r.total = 0
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dim := int64(int32(binary.LittleEndian.Uint32(head)))
+	if dim <= 0 || dim > maxVecsDim {
+		return nil, fmt.Errorf("invalid dimension %d of the first vector", dim)
+	}
+	if recordSize := 4 + dim*int64(width); stat.Size()%recordSize == 0 {
+		r.total = stat.Size() / recordSize
+	}
+	return r, nil
+}
+
+func (r *vecsReader) Read() (Record, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(r.data, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Record{}, fmt.Errorf("vector %d is truncated", r.count)
+		}
+		return Record{}, err
+	}
+	dim := int32(binary.LittleEndian.Uint32(head[:]))
+	if dim <= 0 || dim > maxVecsDim {
+		return Record{}, fmt.Errorf("invalid dimension %d of vector %d", dim, r.count)
+	}
+
+	size := int(dim) * r.width
+	if cap(r.buffer) < size {
+		r.buffer = make([]byte, size)
+	}
+	buffer := r.buffer[:size]
+	if _, err := io.ReadFull(r.data, buffer); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, fmt.Errorf("vector %d is truncated", r.count)
+		}
+		return Record{}, err
+	}
+
+	elements := make([]float32, dim)
+	for i := range elements {
+		if r.width == 4 {
+			elements[i] = math.Float32frombits(binary.LittleEndian.Uint32(buffer[4*i:]))
+		} else {
+			elements[i] = float32(buffer[i])
+		}
+	}
+	record := Record{ID: r.count, Elements: elements}
+	r.count++
+	return record, nil
+}
+
+func (r *vecsReader) Total() int64 {
+	return r.total
+}
+
+func (r *vecsReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,183 @@
+// Package vectorfile reads vectors from portable file formats for bulk imports: JSON
+// lines, Parquet, NumPy .npy arrays and the .fvecs/.bvecs formats of ANN benchmarks.
+// Formats without metadata of their own take it from a JSON lines sidecar file.
+// Exports are written as JSON lines or Parquet.
+package vectorfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format is a file format of vectors
+type Format string
+
+const (
+	FormatJSONL   Format = "jsonl"   // One JSON object per line with elements and metadata
+	FormatParquet Format = "parquet" // A list column of elements, other columns become metadata
+	FormatNPY     Format = "npy"     // A two-dimensional NumPy array, one vector per row
+	FormatFvecs   Format = "fvecs"   // Little-endian int32 dimension, then as many float32
+	FormatBvecs   Format = "bvecs"   // Little-endian int32 dimension, then as many uint8
+)
+
+// ParseFormat parses a format name
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatJSONL, FormatParquet, FormatNPY, FormatFvecs, FormatBvecs:
+		return format, nil
+	case "ndjson", "json":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("unknown format '%s', expected jsonl, parquet, npy, fvecs or bvecs", name)
+	}
+}
+
+// DetectFormat returns the format of a file by its extension
+func DetectFormat(path string) (Format, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".jsonl", ".ndjson", ".json":
+		return FormatJSONL, nil
+	case ".parquet", ".pq":
+		return FormatParquet, nil
+	case ".npy":
+		return FormatNPY, nil
+	case ".fvecs":
+		return FormatFvecs, nil
+	case ".bvecs":
+		return FormatBvecs, nil
+	default:
+		return "", fmt.Errorf("can't tell the format of '%s' by its extension, specify one", path)
+	}
+}
+
+// Record is a vector read from a file
+type Record struct {
+	ID         uint64 // ID in the file, or the position of the record if the format has none
+	Elements   []float32
+	Metadata   map[string]interface{}
+	TTLSeconds int64 // Time to live, none if 0
+	ExpireAt   int64 // Unix time the vector expires at, none if 0
+}
+
+// Reader reads the records of a file in order
+type Reader interface {
+	// Read returns the next record, io.EOF after the last one
+	Read() (Record, error)
+	// Total returns the records of the file, -1 if unknown before reading it all
+	Total() int64
+	Close() error
+}
+
+// Options configures how a file is read
+type Options struct {
+	Format       Format // Detected by the extension if empty
+	MetadataPath string // JSON lines sidecar with the metadata of npy, fvecs and bvecs records
+	Column       string // Parquet column of the elements, found by name if empty
+}
+
+// Open opens the file at path for reading
+func Open(path string, options Options) (Reader, error) {
+	format := options.Format
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(path); err != nil {
+			return nil, err
+		}
+	}
+	if options.MetadataPath != "" && (format == FormatJSONL || format == FormatParquet) {
+		return nil, fmt.Errorf("%s files carry their own metadata, a sidecar is only read for npy, fvecs and bvecs", format)
+	}
+	if options.Column != "" && format != FormatParquet {
+		return nil, errors.New("a column is only selected in parquet files")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader Reader
+	switch format {
+	case FormatJSONL:
+		reader = newJSONLReader(file)
+	case FormatParquet:
+		reader, err = newParquetReader(file, options.Column)
+	case FormatNPY:
+		reader, err = newNPYReader(file)
+	case FormatFvecs:
+		reader, err = newVecsReader(file, 4)
+	case FormatBvecs:
+		reader, err = newVecsReader(file, 1)
+	default:
+		err = fmt.Errorf("unknown format '%s'", format)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	if options.MetadataPath == "" {
+		return reader, nil
+	}
+	sidecar, err := os.Open(options.MetadataPath)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &sidecarReader{Reader: reader, file: sidecar, lines: bufio.NewReader(sidecar)}, nil
+}
+
+// sidecarReader adds the metadata of a JSON lines file to the records of a reader, line
+// by line
+type sidecarReader struct {
+	Reader
+	file  *os.File
+	lines *bufio.Reader
+	line  int
+}
+
+func (r *sidecarReader) Read() (Record, error) {
+	record, err := r.Reader.Read()
+	if err != nil {
+		return Record{}, err
+	}
+
+	line, err := readLine(r.lines)
+	if err == io.EOF {
+		return Record{}, fmt.Errorf("metadata sidecar ends after %d lines, before the vectors", r.line)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	r.line++
+	if len(bytes.TrimSpace(line)) > 0 {
+		if err := json.Unmarshal(line, &record.Metadata); err != nil {
+			return Record{}, fmt.Errorf("metadata sidecar line %d: %w", r.line, err)
+		}
+	}
+	return record, nil
+}
+
+func (r *sidecarReader) Close() error {
+	r.file.Close()
+	return r.Reader.Close()
+}
+
+// readLine returns the next line of r without its line break, io.EOF after the last one
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
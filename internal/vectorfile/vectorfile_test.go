@@ -0,0 +1,255 @@
+package vectorfile
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+// readAll reads all records of the file at path
+func readAll(t *testing.T, path string, options Options) ([]Record, int64) {
+	t.Helper()
+	reader, err := Open(path, options)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var records []Record
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, reader.Total()
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+// npyFile returns a version 1 npy file of header and data
+func npyFile(header string, data []byte) []byte {
+	out := append([]byte(npyMagic), 1, 0)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(header)))
+	out = append(out, header...)
+	return append(out, data...)
+}
+
+func float32s(values ...float32) []byte {
+	var out []byte
+	for _, v := range values {
+		out = binary.LittleEndian.AppendUint32(out, math.Float32bits(v))
+	}
+	return out
+}
+
+func TestDetectFormat(t *testing.T) {
+	for path, format := range map[string]Format{
+		"a.jsonl":         FormatJSONL,
+		"a.NDJSON":        FormatJSONL,
+		"dir/a.parquet":   FormatParquet,
+		"sift_base.fvecs": FormatFvecs,
+		"bigann.bvecs":    FormatBvecs,
+		"emb.npy":         FormatNPY,
+	} {
+		detected, err := DetectFormat(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, format, detected, path)
+	}
+	_, err := DetectFormat("vectors.csv")
+	assert.Error(t, err)
+
+	format, err := ParseFormat("NDJSON")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSONL, format)
+	_, err = ParseFormat("csv")
+	assert.Error(t, err)
+}
+
+func TestReadJSONL(t *testing.T) {
+	path := writeFile(t, "vectors.jsonl", []byte(`{"elements": [1, 2], "metadata": {"tag": "a"}}
+
+{"id": 42, "elements": [3, 4], "ttl_seconds": 60}
+{"elements": [5, 6], "expire_at": 1700000000}`))
+
+	records, total := readAll(t, path, Options{})
+	assert.Equal(t, int64(-1), total)
+	require.Len(t, records, 3)
+	assert.Equal(t, Record{ID: 0, Elements: []float32{1, 2}, Metadata: map[string]interface{}{"tag": "a"}}, records[0])
+	assert.Equal(t, Record{ID: 42, Elements: []float32{3, 4}, TTLSeconds: 60}, records[1])
+	assert.Equal(t, Record{ID: 2, Elements: []float32{5, 6}, ExpireAt: 1700000000}, records[2])
+
+	reader, err := Open(writeFile(t, "bad.jsonl", []byte("{\"elements\": [1]}\n{\"metadata\": {}}\n")), Options{})
+	require.NoError(t, err)
+	defer reader.Close()
+	_, err = reader.Read()
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestReadNPYWithSidecar(t *testing.T) {
+	path := writeFile(t, "vectors.npy", npyFile("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }\n", float32s(1, 2, 3, 4, 5, 6)))
+	sidecar := writeFile(t, "metadata.jsonl", []byte("{\"tag\": \"a\"}\n\n"))
+
+	records, total := readAll(t, path, Options{MetadataPath: sidecar})
+	assert.Equal(t, int64(2), total)
+	require.Len(t, records, 2)
+	assert.Equal(t, Record{ID: 0, Elements: []float32{1, 2, 3}, Metadata: map[string]interface{}{"tag": "a"}}, records[0])
+	assert.Equal(t, Record{ID: 1, Elements: []float32{4, 5, 6}}, records[1])
+
+	// A sidecar shorter than the array
+	reader, err := Open(path, Options{MetadataPath: writeFile(t, "short.jsonl", []byte("{}\n"))})
+	require.NoError(t, err)
+	defer reader.Close()
+	_, err = reader.Read()
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.ErrorContains(t, err, "sidecar")
+}
+
+func TestReadNPYTypes(t *testing.T) {
+	// Half precision 1, -2 and 0.5
+	half := []byte{0x00, 0x3c, 0x00, 0xc0, 0x00, 0x38, 0x00, 0x00}
+	records, _ := readAll(t, writeFile(t, "half.npy", npyFile("{'descr': '<f2', 'fortran_order': False, 'shape': (2, 2), }", half)), Options{})
+	assert.Equal(t, []float32{1, -2}, records[0].Elements)
+	assert.Equal(t, []float32{0.5, 0}, records[1].Elements)
+
+	records, _ = readAll(t, writeFile(t, "u8.npy", npyFile("{'descr': '|u1', 'fortran_order': False, 'shape': (1, 3), }", []byte{0, 128, 255})), Options{})
+	assert.Equal(t, []float32{0, 128, 255}, records[0].Elements)
+
+	big := []byte{0x3f, 0xf0, 0, 0, 0, 0, 0, 0}
+	records, _ = readAll(t, writeFile(t, "f8.npy", npyFile("{'descr': '>f8', 'fortran_order': False, 'shape': (1, 1), }", big)), Options{})
+	assert.Equal(t, []float32{1}, records[0].Elements)
+
+	for name, header := range map[string]string{
+		"fortran":  "{'descr': '<f4', 'fortran_order': True, 'shape': (1, 1), }",
+		"one dim":  "{'descr': '<f4', 'fortran_order': False, 'shape': (4,), }",
+		"strings":  "{'descr': '<U8', 'fortran_order': False, 'shape': (1, 1), }",
+		"no shape": "{'descr': '<f4', 'fortran_order': False}",
+	} {
+		_, err := Open(writeFile(t, "bad.npy", npyFile(header, float32s(1, 2, 3, 4))), Options{})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestReadVecs(t *testing.T) {
+	var fvecs []byte
+	for _, vector := range [][]float32{{1, 2}, {3, 4}, {5, 6}} {
+		fvecs = binary.LittleEndian.AppendUint32(fvecs, 2)
+		fvecs = append(fvecs, float32s(vector...)...)
+	}
+	records, total := readAll(t, writeFile(t, "base.fvecs", fvecs), Options{})
+	assert.Equal(t, int64(3), total)
+	require.Len(t, records, 3)
+	assert.Equal(t, Record{ID: 2, Elements: []float32{5, 6}}, records[2])
+
+	bvecs := []byte{3, 0, 0, 0, 1, 2, 255}
+	records, total = readAll(t, writeFile(t, "base.bvecs", bvecs), Options{})
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, []float32{1, 2, 255}, records[0].Elements)
+
+	// A vector cut short
+	reader, err := Open(writeFile(t, "cut.fvecs", fvecs[:len(fvecs)-2]), Options{})
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(t, int64(-1), reader.Total())
+	for i := 0; i < 2; i++ {
+		_, err = reader.Read()
+		require.NoError(t, err)
+	}
+	_, err = reader.Read()
+	assert.ErrorContains(t, err, "truncated")
+}
+
+// pandasRow is a row of a Parquet file as other tools write them
+type pandasRow struct {
+	ID        int64     `parquet:"id"`
+	Embedding []float64 `parquet:"embedding,list"`
+	Title     string    `parquet:"title,dict"`
+	Score     *float32  `parquet:"score,optional"`
+	Tags      []string  `parquet:"tags,list"`
+	Source    struct {
+		Name string `parquet:"name"`
+		Page int32  `parquet:"page"`
+	} `parquet:"source"`
+}
+
+func TestReadParquet(t *testing.T) {
+	score := float32(0.5)
+	rows := []pandasRow{
+		{ID: 3, Embedding: []float64{1, 2}, Title: "a", Score: &score, Tags: []string{"x", "y"}},
+		{ID: 5, Embedding: []float64{3, 4}, Title: "a", Tags: []string{}},
+	}
+	rows[0].Source.Name, rows[0].Source.Page = "doc", 7
+
+	path := filepath.Join(t.TempDir(), "pandas.parquet")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	w := parquet.NewGenericWriter[pandasRow](file, parquet.Compression(&parquet.Zstd), parquet.DataPageVersion(2))
+	_, err = w.Write(rows)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, file.Close())
+
+	records, total := readAll(t, path, Options{})
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []Record{
+		{ID: 3, Elements: []float32{1, 2}, Metadata: map[string]interface{}{
+			"title": "a", "score": 0.5, "tags": []interface{}{"x", "y"}, "source.name": "doc", "source.page": int64(7),
+		}},
+		{ID: 5, Elements: []float32{3, 4}, Metadata: map[string]interface{}{
+			"title": "a", "tags": []interface{}{}, "source.name": "", "source.page": int64(0),
+		}},
+	}, records)
+
+	_, err = Open(path, Options{Column: "tags"})
+	assert.ErrorContains(t, err, "isn't a list of numbers")
+}
+
+func TestOpenValidatesOptions(t *testing.T) {
+	path := writeFile(t, "vectors.jsonl", []byte(`{"elements": [1]}`))
+	_, err := Open(path, Options{MetadataPath: path})
+	assert.Error(t, err, "JSON lines carry their own metadata")
+	_, err = Open(path, Options{Column: "embedding"})
+	assert.Error(t, err, "columns are only selected in parquet files")
+	_, err = Open(path, Options{Format: FormatParquet})
+	assert.Error(t, err, "not a parquet file")
+}
+
+func TestWriteAndReadBack(t *testing.T) {
+	records := []Record{
+		{ID: 7, Elements: []float32{1, 2}, Metadata: map[string]interface{}{"tag": "a", "n": 1.0}},
+		{ID: 9, Elements: []float32{3, 4}, ExpireAt: 1700000000},
+		{ID: 12, Elements: []float32{5, 6}, TTLSeconds: 60},
+	}
+	for _, format := range []Format{FormatJSONL, FormatParquet} {
+		path := filepath.Join(t.TempDir(), "export."+string(format))
+		w, err := Create(path, format)
+		require.NoError(t, err)
+		for _, record := range records {
+			require.NoError(t, w.Write(record))
+		}
+		require.NoError(t, w.Close())
+
+		read, total := readAll(t, path, Options{})
+		assert.Equal(t, records, read, format)
+		if format == FormatParquet {
+			assert.Equal(t, int64(3), total)
+		}
+	}
+
+	_, err := Create(filepath.Join(t.TempDir(), "export.npy"), FormatNPY)
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package vectorfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Writer writes records to a file in a format Open reads back
+type Writer interface {
+	Write(record Record) error
+	// Close completes the file, which is incomplete until Close succeeds
+	Close() error
+}
+
+// parquetRow is a row of the Parquet files Writer writes
+type parquetRow struct {
+	ID         uint64    `parquet:"id"`
+	Elements   []float32 `parquet:"elements,list"`
+	Metadata   *string   `parquet:"metadata,optional"` // A JSON object
+	TTLSeconds *int64    `parquet:"ttl_seconds,optional"`
+	ExpireAt   *int64    `parquet:"expire_at,optional"`
+}
+
+// parquetRowGroupSize is the size of the values a parquetWriter buffers before writing
+// them as a row group
+const parquetRowGroupSize = 64 << 20
+
+// Create creates or truncates the file at path to write records in format, which is
+// JSON lines or Parquet
+func Create(path string, format Format) (Writer, error) {
+	if format != FormatJSONL && format != FormatParquet {
+		return nil, fmt.Errorf("can't write the %s format, only jsonl and parquet", format)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	out := bufio.NewWriterSize(file, 1<<20)
+	if format == FormatJSONL {
+		return &jsonlWriter{file: file, out: out, encoder: json.NewEncoder(out)}, nil
+	}
+
+	w := parquet.NewGenericWriter[parquetRow](out, parquet.Compression(&parquet.Snappy))
+	return &parquetWriter{file: file, out: out, parquet: w}, nil
+}
+
+// jsonlWriter writes a record per line
+type jsonlWriter struct {
+	file    *os.File
+	out     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(record Record) error {
+	id := record.ID
+	return w.encoder.Encode(jsonRecord{
+		ID:         &id,
+		Elements:   record.Elements,
+		Metadata:   record.Metadata,
+		TTLSeconds: record.TTLSeconds,
+		ExpireAt:   record.ExpireAt,
+	})
+}
+
+func (w *jsonlWriter) Close() error {
+	return closeFile(w.file, w.out.Flush())
+}
+
+// parquetWriter writes rows of parquetRow, snappy compressed
+type parquetWriter struct {
+	file     *os.File
+	out      *bufio.Writer
+	parquet  *parquet.GenericWriter[parquetRow]
+	buffered int // Bytes of values buffered for the next row group
+}
+
+func (w *parquetWriter) Write(record Record) error {
+	row := parquetRow{ID: record.ID, Elements: record.Elements}
+	if len(record.Metadata) > 0 {
+		metadata, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return fmt.Errorf("vector %d: %w", record.ID, err)
+		}
+		text := string(metadata)
+		row.Metadata = &text
+	}
+	if record.TTLSeconds != 0 {
+		row.TTLSeconds = &record.TTLSeconds
+	}
+	if record.ExpireAt != 0 {
+		row.ExpireAt = &record.ExpireAt
+	}
+
+	if _, err := w.parquet.Write([]parquetRow{row}); err != nil {
+		return err
+	}
+	w.buffered += 8 + 4*len(row.Elements)
+	if row.Metadata != nil {
+		w.buffered += len(*row.Metadata)
+	}
+	if w.buffered >= parquetRowGroupSize {
+		w.buffered = 0
+		return w.parquet.Flush()
+	}
+	return nil
+}
+
+func (w *parquetWriter) Close() error {
+	err := w.parquet.Close()
+	if err == nil {
+		err = w.out.Flush()
+	}
+	return closeFile(w.file, err)
+}
+
+// closeFile syncs and closes a written file, returning the first error
+func closeFile(file *os.File, err error) error {
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
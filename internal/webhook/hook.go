@@ -0,0 +1,400 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/core"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Headers of a delivery
+const (
+	HeaderEvent     = "X-Scintirete-Event"
+	HeaderDelivery  = "X-Scintirete-Delivery"
+	HeaderTimestamp = "X-Scintirete-Timestamp"
+	HeaderSignature = "X-Scintirete-Signature"
+)
+
+// Payload is the JSON body posted for an event
+type Payload struct {
+	ID         string          `json:"id"` // <stream_id>:<offset> of the change, or snapshot-<unix nanoseconds>
+	Hook       string          `json:"hook"`
+	Event      string          `json:"event"` // The change type in lower case, such as create_collection, or snapshot
+	Timestamp  int64           `json:"timestamp"`
+	Database   string          `json:"database,omitempty"`
+	Collection string          `json:"collection,omitempty"`
+	Offset     int64           `json:"offset,omitempty"`
+	Count      int             `json:"count,omitempty"` // Vectors inserted or deleted
+	IDs        []uint64        `json:"ids,omitempty"`   // IDs of the vectors inserted or deleted
+	Change     json.RawMessage `json:"change,omitempty"`
+	Snapshot   *SnapshotInfo   `json:"snapshot,omitempty"`
+}
+
+// SnapshotInfo describes a saved RDB snapshot
+type SnapshotInfo struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	SavedAt int64  `json:"saved_at"`
+}
+
+// Signature returns the value of the signature header of a delivery: the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the secret of the hook, prefixed with "sha256="
+func Signature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// statusError is a delivery answered with a status other than 2xx
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("receiver answered %d %s", e.code, http.StatusText(e.code))
+}
+
+// retryable reports whether a failed delivery may succeed when retried: network errors,
+// timeouts, throttling and server errors may, other answers of the receiver won't
+func retryable(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.code == http.StatusRequestTimeout || statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+}
+
+// hook delivers the payloads queued for a webhook one at a time, in order
+type hook struct {
+	config     HookConfig
+	events     map[string]bool // nil for all
+	backoff    time.Duration
+	client     *http.Client
+	queue      chan Payload
+	deadLetter *deadLetterFile
+	logger     core.Logger
+
+	mu             sync.Mutex
+	delivered      int64
+	retries        int64
+	deadLettered   int64
+	missedCount    int64
+	lastError      string
+	lastDeliveryAt time.Time
+	lastLatency    time.Duration
+}
+
+func newHook(config HookConfig, backoff time.Duration, deadLetter *deadLetterFile, logger core.Logger) *hook {
+	switch {
+	case config.MaxRetries == 0:
+		config.MaxRetries = DefaultMaxRetries
+	case config.MaxRetries < 0:
+		config.MaxRetries = 0
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	h := &hook{
+		config:     config,
+		backoff:    backoff,
+		client:     &http.Client{},
+		queue:      make(chan Payload, queueSize),
+		deadLetter: deadLetter,
+		logger:     logger,
+	}
+	if len(config.Events) > 0 {
+		h.events = make(map[string]bool, len(config.Events))
+		for _, event := range config.Events {
+			h.events[event] = true
+		}
+	}
+	return h
+}
+
+// subscribed reports whether the hook delivers events of category
+func (h *hook) subscribed(category string) bool {
+	return h.events == nil || h.events[category]
+}
+
+// payload returns the payload of a change, false if the hook doesn't deliver it
+func (h *hook) payload(event *pb.WatchEvent) (Payload, bool, error) {
+	var category string
+	switch event.Type {
+	case pb.ChangeType_HEARTBEAT:
+		return Payload{}, false, nil
+	case pb.ChangeType_INSERT:
+		category = EventInsert
+	case pb.ChangeType_DELETE:
+		category = EventDelete
+	default:
+		category = EventDDL
+	}
+	if !h.subscribed(category) {
+		return Payload{}, false, nil
+	}
+
+	payload := Payload{
+		ID:         fmt.Sprintf("%s:%d", event.StreamId, event.Offset),
+		Hook:       h.config.Name,
+		Event:      eventName(event.Type),
+		Timestamp:  event.Timestamp,
+		Database:   event.DbName,
+		Collection: event.CollectionName,
+		Offset:     event.Offset,
+	}
+	switch category {
+	case EventInsert:
+		payload.Count = len(event.Vectors)
+		payload.IDs = make([]uint64, len(event.Vectors))
+		for i, vector := range event.Vectors {
+			payload.IDs[i] = vector.GetId()
+		}
+		if !h.config.IncludeVectors {
+			return payload, true, nil
+		}
+	case EventDelete:
+		payload.Count = len(event.Ids)
+		payload.IDs = event.Ids
+		return payload, true, nil
+	}
+
+	// The change as the Watch stream has it, with the targets of renames and copies
+	change, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(event)
+	if err != nil {
+		return Payload{}, false, fmt.Errorf("failed to encode change %d: %w", event.Offset, err)
+	}
+	payload.Change = change
+	return payload, true, nil
+}
+
+// enqueue queues payload, waiting while the queue is full
+func (h *hook) enqueue(ctx context.Context, payload Payload) error {
+	select {
+	case h.queue <- payload:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueueNow queues payload, or writes it to the dead-letter file if the queue is full
+func (h *hook) enqueueNow(payload Payload) {
+	select {
+	case h.queue <- payload:
+	default:
+		h.giveUp(context.Background(), payload, 0, errors.New("delivery queue is full"))
+	}
+}
+
+// run delivers queued payloads until ctx ends
+func (h *hook) run(ctx context.Context) {
+	for {
+		select {
+		case payload := <-h.queue:
+			h.deliver(ctx, payload)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drain writes the payloads still queued to the dead-letter file
+func (h *hook) drain() {
+	for {
+		select {
+		case payload := <-h.queue:
+			h.giveUp(context.Background(), payload, 0, errors.New("server stopped before delivery"))
+		default:
+			return
+		}
+	}
+}
+
+// deliver posts payload, retrying with exponential backoff while the failure may be
+// temporary, and writes it to the dead-letter file if it couldn't be delivered
+func (h *hook) deliver(ctx context.Context, payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		h.giveUp(ctx, payload, 0, err)
+		return
+	}
+
+	backoff := h.backoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := h.post(ctx, payload, body)
+		if err == nil {
+			h.mu.Lock()
+			h.delivered++
+			h.lastDeliveryAt = time.Now()
+			h.lastLatency = time.Since(start)
+			h.mu.Unlock()
+			return
+		}
+
+		h.mu.Lock()
+		h.lastError = err.Error()
+		h.mu.Unlock()
+		if ctx.Err() != nil || !retryable(err) || attempt > h.config.MaxRetries {
+			h.giveUp(ctx, payload, attempt, err)
+			return
+		}
+
+		h.mu.Lock()
+		h.retries++
+		h.mu.Unlock()
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			h.giveUp(ctx, payload, attempt, ctx.Err())
+			return
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// post makes one delivery attempt
+func (h *hook) post(ctx context.Context, payload Payload, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Scintirete-Webhook")
+	req.Header.Set(HeaderEvent, payload.Event)
+	req.Header.Set(HeaderDelivery, payload.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if h.config.Secret != "" {
+		req.Header.Set(HeaderSignature, Signature(h.config.Secret, timestamp, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024)) // Lets the connection be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// giveUp writes a payload that wasn't delivered to the dead-letter file
+func (h *hook) giveUp(ctx context.Context, payload Payload, attempts int, cause error) {
+	h.mu.Lock()
+	h.deadLettered++
+	h.mu.Unlock()
+
+	fields := map[string]interface{}{
+		"webhook":  h.config.Name,
+		"delivery": payload.ID,
+		"event":    payload.Event,
+		"attempts": attempts,
+	}
+	if h.deadLetter == nil {
+		h.logger.Error(ctx, "Webhook delivery failed", cause, fields)
+		return
+	}
+	if err := h.deadLetter.write(deadLetter{
+		Hook:     h.config.Name,
+		URL:      h.config.URL,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		FailedAt: time.Now().Unix(),
+		Payload:  payload,
+	}); err != nil {
+		h.logger.Error(ctx, "Failed to write undelivered webhook payload to the dead-letter file", err, fields)
+		return
+	}
+	h.logger.Warn(ctx, "Webhook delivery failed, payload written to the dead-letter file", map[string]interface{}{
+		"webhook":  h.config.Name,
+		"delivery": payload.ID,
+		"event":    payload.Event,
+		"attempts": attempts,
+		"error":    cause.Error(),
+	})
+}
+
+// missed counts a gap in the events delivered
+func (h *hook) missed() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.missedCount++
+}
+
+// stats returns the delivery metrics of the hook
+func (h *hook) stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return Stats{
+		Name:           h.config.Name,
+		URL:            h.config.URL,
+		Delivered:      h.delivered,
+		Retries:        h.retries,
+		DeadLettered:   h.deadLettered,
+		Missed:         h.missedCount,
+		Pending:        len(h.queue),
+		LastError:      h.lastError,
+		LastDeliveryAt: h.lastDeliveryAt,
+		LastLatency:    h.lastLatency,
+	}
+}
+
+// deadLetter is a line of the dead-letter file
+type deadLetter struct {
+	Hook     string  `json:"hook"`
+	URL      string  `json:"url"`
+	Attempts int     `json:"attempts"`
+	Error    string  `json:"error"`
+	FailedAt int64   `json:"failed_at"`
+	Payload  Payload `json:"payload"`
+}
+
+// deadLetterFile appends undelivered payloads to a file as JSON lines
+type deadLetterFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (f *deadLetterFile) write(record deadLetter) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
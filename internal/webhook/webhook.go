@@ -0,0 +1,250 @@
+// Package webhook delivers change events of databases and collections to HTTP endpoints,
+// for consumers that can't keep a Watch stream open. Each hook watches the change backlog
+// with its own filter and posts a signed JSON payload per event, retrying failed
+// deliveries with exponential backoff. Payloads that can't be delivered are appended to
+// a dead-letter file.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/scintirete/scintirete/gen/go/scintirete/v1"
+	"github.com/scintirete/scintirete/internal/cdc"
+	"github.com/scintirete/scintirete/internal/core"
+)
+
+// Event categories a hook subscribes to
+const (
+	EventDDL      = "ddl"      // Databases, collections and partitions created, dropped, renamed, cloned or copied, and resets
+	EventInsert   = "insert"   // Vectors inserted, one payload per insert request
+	EventDelete   = "delete"   // Vectors deleted, including expired and evicted ones
+	EventSnapshot = "snapshot" // RDB snapshots saved
+)
+
+const (
+	// DefaultMaxRetries is how often a failed delivery is retried if not configured
+	DefaultMaxRetries = 5
+	// DefaultTimeout bounds each delivery attempt if not configured
+	DefaultTimeout = 10 * time.Second
+	// DefaultRetryBackoff is the wait before the first retry, doubled for each further one
+	DefaultRetryBackoff = time.Second
+
+	maxRetryBackoff = time.Minute
+	queueSize       = 1024 // Payloads waiting for delivery per hook
+)
+
+// Config configures the webhooks of a server
+type Config struct {
+	Hooks          []HookConfig
+	DeadLetterPath string        // File undeliverable payloads are appended to as JSON lines, none if empty
+	RetryBackoff   time.Duration // Wait before the first retry, DefaultRetryBackoff if 0
+}
+
+// HookConfig configures a webhook
+type HookConfig struct {
+	Name           string
+	URL            string
+	Secret         string        // Key of the HMAC-SHA256 signature, unsigned if empty
+	Database       string        // Database whose events are delivered, all if empty
+	Collection     string        // Collection whose events are delivered, the whole database if empty
+	Events         []string      // Event categories delivered, all if empty
+	IncludeVectors bool          // Insert payloads carry the vectors, not only their IDs
+	MaxRetries     int           // Retries of a failed delivery, DefaultMaxRetries if 0, none if negative
+	Timeout        time.Duration // Bounds each delivery attempt, DefaultTimeout if 0
+}
+
+// Validate checks the webhook configuration
+func (c Config) Validate() error {
+	names := make(map[string]bool, len(c.Hooks))
+	for _, hook := range c.Hooks {
+		if hook.Name == "" {
+			return errors.New("webhook name is required")
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("duplicate webhook name '%s'", hook.Name)
+		}
+		names[hook.Name] = true
+
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook '%s' needs an http or https url, got '%s'", hook.Name, hook.URL)
+		}
+		if hook.Collection != "" && hook.Database == "" {
+			return fmt.Errorf("webhook '%s' needs a database to filter by collection", hook.Name)
+		}
+		for _, event := range hook.Events {
+			switch event {
+			case EventDDL, EventInsert, EventDelete, EventSnapshot:
+			default:
+				return fmt.Errorf("webhook '%s' has unknown event '%s', expected ddl, insert, delete or snapshot", hook.Name, event)
+			}
+		}
+		if hook.Timeout < 0 {
+			return fmt.Errorf("webhook '%s' timeout must be non-negative", hook.Name)
+		}
+	}
+	return nil
+}
+
+// Stats contains the delivery metrics of a webhook
+type Stats struct {
+	Name           string
+	URL            string
+	Delivered      int64         // Payloads delivered
+	Retries        int64         // Failed attempts that were retried
+	DeadLettered   int64         // Payloads given up on
+	Missed         int64         // Times the hook fell out of the change backlog and skipped events
+	Pending        int           // Payloads waiting for delivery
+	LastError      string        // Why the last failed attempt failed
+	LastDeliveryAt time.Time     // When a payload was last delivered
+	LastLatency    time.Duration // How long the last successful attempt took
+}
+
+// Dispatcher delivers the events of the change backlog to the configured webhooks
+type Dispatcher struct {
+	changes    *cdc.Hub
+	hooks      []*hook
+	deadLetter *deadLetterFile
+	logger     core.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher of the webhooks in config that watches changes
+func NewDispatcher(config Config, changes *cdc.Hub, logger core.Logger) (*Dispatcher, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = DefaultRetryBackoff
+	}
+
+	d := &Dispatcher{changes: changes, logger: logger}
+	if config.DeadLetterPath != "" {
+		d.deadLetter = &deadLetterFile{path: config.DeadLetterPath}
+	}
+	for _, hookConfig := range config.Hooks {
+		d.hooks = append(d.hooks, newHook(hookConfig, config.RetryBackoff, d.deadLetter, logger))
+	}
+	return d, nil
+}
+
+// Start starts watching changes and delivering them until Stop
+func (d *Dispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	for _, h := range d.hooks {
+		d.wg.Add(2)
+		go func() {
+			defer d.wg.Done()
+			d.watch(ctx, h)
+		}()
+		go func() {
+			defer d.wg.Done()
+			h.run(ctx)
+		}()
+	}
+	if len(d.hooks) > 0 {
+		d.logger.Info(ctx, "Webhooks started", map[string]interface{}{
+			"webhooks": len(d.hooks),
+		})
+	}
+}
+
+// Stop stops delivering. Payloads still waiting are written to the dead-letter file.
+func (d *Dispatcher) Stop() {
+	if d.cancel == nil {
+		return
+	}
+	d.cancel()
+	d.wg.Wait()
+	for _, h := range d.hooks {
+		h.drain()
+	}
+}
+
+// SnapshotSaved queues a snapshot payload for the hooks subscribed to snapshots. It
+// implements persistence.SnapshotListener and never blocks.
+func (d *Dispatcher) SnapshotSaved(path string) {
+	now := time.Now()
+	info := &SnapshotInfo{Path: path, SavedAt: now.Unix()}
+	if stat, err := os.Stat(path); err == nil {
+		info.Size = stat.Size()
+	}
+
+	for _, h := range d.hooks {
+		if !h.subscribed(EventSnapshot) {
+			continue
+		}
+		h.enqueueNow(Payload{
+			ID:        fmt.Sprintf("snapshot-%d", now.UnixNano()),
+			Hook:      h.config.Name,
+			Event:     EventSnapshot,
+			Timestamp: now.Unix(),
+			Snapshot:  info,
+		})
+	}
+}
+
+// Stats returns the delivery metrics of every webhook, in configuration order
+func (d *Dispatcher) Stats() []Stats {
+	stats := make([]Stats, len(d.hooks))
+	for i, h := range d.hooks {
+		stats[i] = h.stats()
+	}
+	return stats
+}
+
+// watch queues the payloads of the changes h is subscribed to, resuming after the last
+// change queued when the watch ends early
+func (d *Dispatcher) watch(ctx context.Context, h *hook) {
+	filter := cdc.Filter{Database: h.config.Database, Collection: h.config.Collection}
+	var streamID string
+	var next int64
+
+	for {
+		err := d.changes.Watch(ctx, filter, streamID, next, func(event *pb.WatchEvent) error {
+			streamID, next = event.StreamId, event.Offset+1
+			payload, ok, err := h.payload(event)
+			if err != nil {
+				d.logger.Error(ctx, "Failed to build webhook payload", err, map[string]interface{}{
+					"webhook": h.config.Name,
+				})
+				return nil
+			}
+			if !ok {
+				return nil
+			}
+			return h.enqueue(ctx, payload)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, cdc.ErrOffsetUnavailable) {
+			// Deliveries fell behind the backlog, the events in between are lost
+			h.missed()
+			d.logger.Error(ctx, "Webhook fell behind the change backlog, skipping to the latest change", err, map[string]interface{}{
+				"webhook": h.config.Name,
+			})
+			streamID, next = "", 0
+			continue
+		}
+		d.logger.Error(ctx, "Webhook stopped watching changes", err, map[string]interface{}{
+			"webhook": h.config.Name,
+		})
+		return
+	}
+}
+
+// eventName returns the name of a change in payloads, such as create_collection
+func eventName(changeType pb.ChangeType) string {
+	return strings.ToLower(changeType.String())
+}
@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scintirete/scintirete/internal/cdc"
+	"github.com/scintirete/scintirete/internal/observability/logger"
+	"github.com/scintirete/scintirete/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a local webhook endpoint answering with the statuses of its script, then 200
+type receiver struct {
+	*httptest.Server
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	script   []int
+	payloads []Payload
+}
+
+func newReceiver(t *testing.T, secret string, script ...int) *receiver {
+	r := &receiver{t: t, secret: secret, script: script}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.handle))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) handle(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	assert.NoError(r.t, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.secret != "" {
+		timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+		assert.NoError(r.t, err)
+		assert.Equal(r.t, Signature(r.secret, timestamp, body), req.Header.Get(HeaderSignature))
+	}
+	if len(r.script) > 0 {
+		status := r.script[0]
+		r.script = r.script[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	var payload Payload
+	assert.NoError(r.t, json.Unmarshal(body, &payload))
+	assert.Equal(r.t, payload.Event, req.Header.Get(HeaderEvent))
+	assert.Equal(r.t, payload.ID, req.Header.Get(HeaderDelivery))
+	r.payloads = append(r.payloads, payload)
+}
+
+// waitForPayloads waits until the receiver got count payloads
+func (r *receiver) waitForPayloads(count int) []Payload {
+	r.t.Helper()
+	require.Eventually(r.t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.payloads) >= count
+	}, 5*time.Second, 10*time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Payload(nil), r.payloads...)
+}
+
+// startDispatcher starts a dispatcher of config and waits until its hooks watch changes
+func startDispatcher(t *testing.T, config Config) (*Dispatcher, *cdc.Hub) {
+	t.Helper()
+	log, err := logger.NewFromConfigString("error", "text")
+	require.NoError(t, err)
+
+	changes := cdc.NewHub(cdc.Config{}, log)
+	dispatcher, err := NewDispatcher(config, changes, log)
+	require.NoError(t, err)
+	dispatcher.Start(context.Background())
+	t.Cleanup(dispatcher.Stop)
+
+	require.Eventually(t, func() bool {
+		_, _, watchers := changes.Backlog()
+		return watchers == len(config.Hooks)
+	}, 5*time.Second, 10*time.Millisecond)
+	return dispatcher, changes
+}
+
+func command(name, db, coll string, args map[string]interface{}) types.AOFCommand {
+	return types.AOFCommand{Timestamp: time.Now(), Command: name, Args: args, Database: db, Collection: coll}
+}
+
+func TestDispatcherDeliversSignedPayloads(t *testing.T) {
+	r := newReceiver(t, "s3cret")
+	_, changes := startDispatcher(t, Config{Hooks: []HookConfig{{
+		Name:     "mirror",
+		URL:      r.URL,
+		Secret:   "s3cret",
+		Database: "db",
+		Events:   []string{EventDDL, EventInsert, EventDelete},
+	}}})
+
+	vectors := []types.Vector{{ID: 1, Elements: []float32{1, 2}}, {ID: 2, Elements: []float32{3, 4}}}
+	changes.Append(command("CREATE_DATABASE", "db", "", map[string]interface{}{"name": "db"}))
+	changes.Append(command("INSERT_VECTORS", "db", "coll", map[string]interface{}{"vectors": vectors}))
+	changes.Append(command("INSERT_VECTORS", "other", "coll", map[string]interface{}{"vectors": vectors}))
+	changes.Append(command("DELETE_VECTORS", "db", "coll", map[string]interface{}{"ids": []string{"2"}}))
+
+	payloads := r.waitForPayloads(3)
+	require.Len(t, payloads, 3)
+
+	assert.Equal(t, "create_database", payloads[0].Event)
+	assert.Equal(t, "mirror", payloads[0].Hook)
+	assert.NotEmpty(t, payloads[0].Change, "DDL payloads carry the change")
+
+	assert.Equal(t, "insert", payloads[1].Event)
+	assert.Equal(t, "coll", payloads[1].Collection)
+	assert.Equal(t, 2, payloads[1].Count)
+	assert.Equal(t, []uint64{1, 2}, payloads[1].IDs)
+	assert.Empty(t, payloads[1].Change, "vectors are left out without include_vectors")
+
+	assert.Equal(t, "delete", payloads[2].Event)
+	assert.Equal(t, []uint64{2}, payloads[2].IDs)
+	assert.Equal(t, int64(4), payloads[2].Offset)
+}
+
+func TestDispatcherRetriesAndDeadLetters(t *testing.T) {
+	flaky := newReceiver(t, "", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	rejecting := newReceiver(t, "", http.StatusBadRequest)
+	deadLetterPath := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+
+	dispatcher, changes := startDispatcher(t, Config{
+		DeadLetterPath: deadLetterPath,
+		RetryBackoff:   10 * time.Millisecond,
+		Hooks: []HookConfig{
+			{Name: "flaky", URL: flaky.URL},
+			{Name: "rejecting", URL: rejecting.URL},
+		},
+	})
+	changes.Append(command("CREATE_DATABASE", "db", "", map[string]interface{}{"name": "db"}))
+
+	// Delivered on the third attempt
+	payloads := flaky.waitForPayloads(1)
+	assert.Equal(t, "create_database", payloads[0].Event)
+
+	// A client error isn't retried
+	require.Eventually(t, func() bool {
+		return dispatcher.Stats()[1].DeadLettered == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stats := dispatcher.Stats()
+	assert.Equal(t, int64(1), stats[0].Delivered)
+	assert.Equal(t, int64(2), stats[0].Retries)
+	assert.False(t, stats[0].LastDeliveryAt.IsZero())
+	assert.Zero(t, stats[1].Delivered)
+	assert.Zero(t, stats[1].Retries)
+	assert.Contains(t, stats[1].LastError, "400")
+
+	file, err := os.Open(deadLetterPath)
+	require.NoError(t, err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	require.True(t, scanner.Scan())
+	var record deadLetter
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+	assert.Equal(t, "rejecting", record.Hook)
+	assert.Equal(t, 1, record.Attempts)
+	assert.Equal(t, "create_database", record.Payload.Event)
+	assert.False(t, scanner.Scan(), "only the rejected payload is dead-lettered")
+}
+
+func TestDispatcherDeliversSnapshots(t *testing.T) {
+	r := newReceiver(t, "")
+	dispatcher, _ := startDispatcher(t, Config{Hooks: []HookConfig{
+		{Name: "snapshots", URL: r.URL, Events: []string{EventSnapshot}},
+	}})
+
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	require.NoError(t, os.WriteFile(path, []byte("snapshot"), 0600))
+	dispatcher.SnapshotSaved(path)
+
+	payloads := r.waitForPayloads(1)
+	assert.Equal(t, EventSnapshot, payloads[0].Event)
+	require.NotNil(t, payloads[0].Snapshot)
+	assert.Equal(t, path, payloads[0].Snapshot.Path)
+	assert.Equal(t, int64(8), payloads[0].Snapshot.Size)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks []HookConfig
+		valid bool
+	}{
+		{"valid", []HookConfig{{Name: "a", URL: "https://example.com/hook", Events: []string{EventInsert}}}, true},
+		{"missing name", []HookConfig{{URL: "https://example.com/hook"}}, false},
+		{"duplicate name", []HookConfig{{Name: "a", URL: "http://a"}, {Name: "a", URL: "http://b"}}, false},
+		{"bad url", []HookConfig{{Name: "a", URL: "ftp://example.com"}}, false},
+		{"collection without database", []HookConfig{{Name: "a", URL: "http://a", Collection: "c"}}, false},
+		{"unknown event", []HookConfig{{Name: "a", URL: "http://a", Events: []string{"update"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Config{Hooks: tt.hooks}.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
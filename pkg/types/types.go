@@ -60,11 +60,51 @@ func DistanceMetricFromProto(pbMetric pb.DistanceMetric) DistanceMetric {
 	}
 }
 
+// LoadState describes whether a collection is held in memory
+type LoadState int32
+
+const (
+	LoadStateUnspecified LoadState = 0
+	LoadStateLoaded      LoadState = 1 // In memory and ready to serve requests
+	LoadStateReleased    LoadState = 2 // Stored in a segment on disk
+	LoadStateLoading     LoadState = 3 // Being read back from its segment
+)
+
+// String returns the string representation of LoadState
+func (ls LoadState) String() string {
+	switch ls {
+	case LoadStateLoaded:
+		return "Loaded"
+	case LoadStateReleased:
+		return "Released"
+	case LoadStateLoading:
+		return "Loading"
+	default:
+		return "Unspecified"
+	}
+}
+
+// ToProto converts LoadState to protobuf enum
+func (ls LoadState) ToProto() pb.LoadState {
+	switch ls {
+	case LoadStateLoaded:
+		return pb.LoadState_LOADED
+	case LoadStateReleased:
+		return pb.LoadState_RELEASED
+	case LoadStateLoading:
+		return pb.LoadState_LOADING
+	default:
+		return pb.LoadState_LOAD_STATE_UNSPECIFIED
+	}
+}
+
 // Vector represents a vector with ID, elements, and metadata
 type Vector struct {
-	ID       uint64                 `json:"id"`
-	Elements []float32              `json:"elements"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	ID        uint64                 `json:"id"`
+	Elements  []float32              `json:"elements"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ExpireAt  int64                  `json:"expire_at,omitempty"` // Unix timestamp (seconds), 0 means never expires
+	Partition string                 `json:"partition,omitempty"` // Partition name, empty means the default partition
 }
 
 // Dimension returns the dimension of the vector
@@ -72,6 +112,11 @@ func (v *Vector) Dimension() int {
 	return len(v.Elements)
 }
 
+// IsExpired reports whether the vector has a TTL that has elapsed at the given time
+func (v *Vector) IsExpired(now time.Time) bool {
+	return v.ExpireAt > 0 && v.ExpireAt <= now.Unix()
+}
+
 // TextWithMetadata represents text data with metadata for embedding
 type TextWithMetadata struct {
 	ID       *uint64                `json:"id,omitempty"` // Optional, auto-generated if not provided
@@ -87,8 +132,9 @@ type SearchResult struct {
 
 // SearchParams contains parameters for vector search
 type SearchParams struct {
-	TopK     int  `json:"top_k"`
-	EfSearch *int `json:"ef_search,omitempty"` // HNSW-specific parameter
+	TopK       int      `json:"top_k"`
+	EfSearch   *int     `json:"ef_search,omitempty"`  // HNSW-specific parameter
+	Partitions []string `json:"partitions,omitempty"` // Partitions to search, empty means all loaded partitions
 }
 
 // HNSWParams contains HNSW algorithm parameters
@@ -138,6 +184,9 @@ type CollectionConfig struct {
 	Name       string         `json:"name"`
 	Metric     DistanceMetric `json:"metric"`
 	HNSWParams HNSWParams     `json:"hnsw_params"`
+
+	// DefaultTTLSeconds is applied to inserted vectors without an explicit expiry (0 disables it)
+	DefaultTTLSeconds int64 `json:"default_ttl_seconds,omitempty"`
 }
 
 // CollectionInfo contains metadata about a collection
@@ -151,10 +200,19 @@ type CollectionInfo struct {
 	HNSWConfig   HNSWParams     `json:"hnsw_config"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
+
+	DefaultTTLSeconds int64           `json:"default_ttl_seconds,omitempty"`
+	Partitions        []PartitionInfo `json:"partitions,omitempty"`
+	LoadState         LoadState       `json:"load_state"`
 }
 
 // ToProto converts CollectionInfo to protobuf message
 func (info CollectionInfo) ToProto() *pb.CollectionInfo {
+	partitions := make([]*pb.PartitionInfo, 0, len(info.Partitions))
+	for _, partition := range info.Partitions {
+		partitions = append(partitions, partition.ToProto())
+	}
+
 	return &pb.CollectionInfo{
 		Name:         info.Name,
 		Dimension:    int32(info.Dimension),
@@ -163,6 +221,35 @@ func (info CollectionInfo) ToProto() *pb.CollectionInfo {
 		MemoryBytes:  info.MemoryBytes,
 		MetricType:   info.MetricType.ToProto(),
 		HnswConfig:   info.HNSWConfig.ToProto(),
+
+		DefaultTtlSeconds: info.DefaultTTLSeconds,
+		Partitions:        partitions,
+		LoadState:         info.LoadState.ToProto(),
+	}
+}
+
+// DefaultPartitionName is the partition every collection starts with.
+// Vectors inserted without a partition name are stored here.
+const DefaultPartitionName = "_default"
+
+// PartitionInfo contains statistics about a single partition of a collection
+type PartitionInfo struct {
+	Name         string    `json:"name"`
+	VectorCount  int64     `json:"vector_count"`
+	DeletedCount int64     `json:"deleted_count"`
+	MemoryBytes  int64     `json:"memory_bytes"`
+	Loaded       bool      `json:"loaded"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ToProto converts PartitionInfo to protobuf message
+func (info PartitionInfo) ToProto() *pb.PartitionInfo {
+	return &pb.PartitionInfo{
+		Name:         info.Name,
+		VectorCount:  info.VectorCount,
+		DeletedCount: info.DeletedCount,
+		MemoryBytes:  info.MemoryBytes,
+		Loaded:       info.Loaded,
 	}
 }
 
@@ -244,6 +331,33 @@ type DatabaseStats struct {
 	// Insert metrics
 	InsertLatency    float64 `json:"insert_latency_avg_ms"`
 	InsertThroughput float64 `json:"insert_throughput_ops"`
+
+	// Memory limit metrics
+	Memory MemoryStats `json:"memory"`
+}
+
+// MemoryStats describes memory usage against the maxmemory limit and eviction activity
+type MemoryStats struct {
+	UsedBytes          int64  `json:"used_memory_bytes"`
+	MaxBytes           int64  `json:"maxmemory_bytes"` // 0 means unlimited
+	Policy             string `json:"maxmemory_policy"`
+	EvictedVectors     int64  `json:"evicted_vectors"`
+	EvictedCollections int64  `json:"evicted_collections"`
+	RejectedWrites     int64  `json:"rejected_writes"`
+	SpilledCollections int    `json:"spilled_collections"` // Collections currently held on disk
+}
+
+// ToProto converts MemoryStats to protobuf message
+func (stats MemoryStats) ToProto() *pb.MemoryInfo {
+	return &pb.MemoryInfo{
+		UsedMemoryBytes:    stats.UsedBytes,
+		MaxmemoryBytes:     stats.MaxBytes,
+		MaxmemoryPolicy:    stats.Policy,
+		EvictedVectors:     stats.EvictedVectors,
+		EvictedCollections: stats.EvictedCollections,
+		RejectedWrites:     stats.RejectedWrites,
+		SpilledCollections: int32(stats.SpilledCollections),
+	}
 }
 
 // RequestContext contains information about the current request
@@ -274,4 +388,7 @@ type MetricsSnapshot struct {
 	// Insert metrics
 	InsertLatency    float64 `json:"insert_latency_avg_ms"`
 	InsertThroughput float64 `json:"insert_throughput_ops"`
+
+	// Memory limit metrics
+	Memory MemoryStats `json:"memory"`
 }